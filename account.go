@@ -0,0 +1,344 @@
+package virtual_security
+
+import (
+	"sync"
+	"time"
+)
+
+// account - 口座
+type account struct {
+	Cash                  float64                     // 預り金
+	InitialMarginRate     float64                     // 委託保証金率
+	MaintenanceMarginRate float64                     // 最低委託保証金維持率
+	MarginPositionAmount  float64                     // 信用建玉金額
+	MarginProfit          float64                     // 信用建玉の評価損益
+	IsMarginCall          bool                        // 追証が発生しているか
+	MarginCalledAt        time.Time                   // 追証の発生日時
+	reservations          map[string]*cashReservation // 注文ごとの拘束金
+	marginReservations    map[string]*cashReservation // 信用新規注文ごとの建玉予定金額
+	mtx                   sync.Mutex
+}
+
+// cashReservation - 注文が拘束している金額の情報
+type cashReservation struct {
+	price    float64 // 拘束単価
+	quantity float64 // 拘束数量
+}
+
+// デバッグなどで必要になったときに使う
+//func (a *account) String() string {
+//	if b, err := json.Marshal(a); err != nil {
+//		return err.Error()
+//	} else {
+//		return string(b)
+//	}
+//}
+
+// deposit - 入金する
+func (a *account) deposit(amount float64) error {
+	if amount <= 0 {
+		return InvalidAmountError
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.Cash += amount
+	return nil
+}
+
+// withdraw - 出金する
+func (a *account) withdraw(amount float64) error {
+	if amount <= 0 {
+		return InvalidAmountError
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.buyingPower() < amount {
+		return NotEnoughCashError
+	}
+	a.Cash -= amount
+	return nil
+}
+
+// pay - 約定代金などを支払う
+func (a *account) pay(amount float64) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.Cash -= amount
+}
+
+// receive - 約定代金などを受け取る
+func (a *account) receive(amount float64) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.Cash += amount
+}
+
+// totalReservation - 拘束している金額の合計
+//   ロックは呼び出し元で取る
+func (a *account) totalReservation(reservations map[string]*cashReservation) float64 {
+	var total float64
+	for _, r := range reservations {
+		total += r.price * r.quantity
+	}
+	return total
+}
+
+// reservedCash - 注文が拘束している金額の合計
+//   ロックは呼び出し元で取る
+func (a *account) reservedCash() float64 {
+	return a.totalReservation(a.reservations)
+}
+
+// marginDeposit - 受入保証金 (預り金から注文の拘束金を除き、信用建玉の評価損益を加えたもの)
+//   ロックは呼び出し元で取る
+func (a *account) marginDeposit() float64 {
+	return a.Cash - a.reservedCash() + a.MarginProfit
+}
+
+// requiredMargin - 信用建玉と信用新規注文に必要な委託保証金
+//   ロックは呼び出し元で取る
+func (a *account) requiredMargin() float64 {
+	return (a.MarginPositionAmount + a.totalReservation(a.marginReservations)) * a.InitialMarginRate
+}
+
+// buyingPower - 買付余力
+//   ロックは呼び出し元で取る
+func (a *account) buyingPower() float64 {
+	return a.marginDeposit() - a.requiredMargin()
+}
+
+// maintenanceRatio - 委託保証金維持率
+//   ロックは呼び出し元で取る
+func (a *account) maintenanceRatio() float64 {
+	if a.MarginPositionAmount <= 0 {
+		return 0
+	}
+	return a.marginDeposit() / a.MarginPositionAmount
+}
+
+// reservable - 指定した金額を拘束できるかのチェック
+func (a *account) reservable(price float64, quantity float64) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.buyingPower() < price*quantity {
+		return NotEnoughBuyingPowerError
+	}
+	return nil
+}
+
+// rereservable - 注文が拘束している金額を、指定した金額で拘束し直せるかのチェック
+func (a *account) rereservable(orderCode string, price float64, quantity float64) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	var reserved float64
+	if r, ok := a.reservations[orderCode]; ok {
+		reserved = r.price * r.quantity
+	}
+	if a.buyingPower()+reserved < price*quantity {
+		return NotEnoughBuyingPowerError
+	}
+	return nil
+}
+
+// reserve - 注文に必要な金額を拘束する
+func (a *account) reserve(orderCode string, price float64, quantity float64) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.reservations == nil {
+		a.reservations = map[string]*cashReservation{}
+	}
+	a.reservations[orderCode] = &cashReservation{price: price, quantity: quantity}
+}
+
+// release - 注文が拘束している金額を数量分だけ開放する
+func (a *account) release(orderCode string, quantity float64) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	r, ok := a.reservations[orderCode]
+	if !ok {
+		return
+	}
+	r.quantity -= quantity
+	if r.quantity <= 0 {
+		delete(a.reservations, orderCode)
+	}
+}
+
+// releaseAll - 注文が拘束している金額をすべて開放する
+func (a *account) releaseAll(orderCode string) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	delete(a.reservations, orderCode)
+}
+
+// marginReservable - 指定した金額の信用建玉を建てられるだけの委託保証金があるかのチェック
+func (a *account) marginReservable(price float64, quantity float64) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.buyingPower() < price*quantity*a.InitialMarginRate {
+		return NotEnoughMarginError
+	}
+	return nil
+}
+
+// marginRereservable - 信用新規注文の建玉予定金額を、指定した金額で登録し直せるだけの委託保証金があるかのチェック
+func (a *account) marginRereservable(orderCode string, price float64, quantity float64) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	var reserved float64
+	if r, ok := a.marginReservations[orderCode]; ok {
+		reserved = r.price * r.quantity * a.InitialMarginRate
+	}
+	if a.buyingPower()+reserved < price*quantity*a.InitialMarginRate {
+		return NotEnoughMarginError
+	}
+	return nil
+}
+
+// reserveMargin - 信用新規注文の建玉予定金額を登録する
+func (a *account) reserveMargin(orderCode string, price float64, quantity float64) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.marginReservations == nil {
+		a.marginReservations = map[string]*cashReservation{}
+	}
+	a.marginReservations[orderCode] = &cashReservation{price: price, quantity: quantity}
+}
+
+// contractMargin - 信用新規注文の約定で、建玉予定金額を建玉金額に振り替える
+func (a *account) contractMargin(orderCode string, price float64, quantity float64) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if r, ok := a.marginReservations[orderCode]; ok {
+		r.quantity -= quantity
+		if r.quantity <= 0 {
+			delete(a.marginReservations, orderCode)
+		}
+	}
+	a.MarginPositionAmount += price * quantity
+}
+
+// releaseMargin - 信用新規注文の建玉予定金額をすべて開放する
+func (a *account) releaseMargin(orderCode string) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	delete(a.marginReservations, orderCode)
+}
+
+// exitMargin - 信用返済で確定した損益を受け渡し、建玉金額を減らす
+func (a *account) exitMargin(positionAmount float64, profit float64) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.Cash += profit
+	a.MarginPositionAmount -= positionAmount
+	if a.MarginPositionAmount < 0 {
+		a.MarginPositionAmount = 0
+	}
+}
+
+// evaluateMargin - 信用建玉の評価を更新し、委託保証金維持率が最低維持率を下回っていれば追証の状態にする
+func (a *account) evaluateMargin(positionAmount float64, profit float64, now time.Time) bool {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.MarginPositionAmount = positionAmount
+	a.MarginProfit = profit
+
+	// 最低維持率が設定されていなければ追証は発生しない
+	isMarginCall := a.MaintenanceMarginRate > 0 && positionAmount > 0 && a.maintenanceRatio() < a.MaintenanceMarginRate
+	if isMarginCall && !a.IsMarginCall {
+		a.MarginCalledAt = now
+	}
+	if !isMarginCall {
+		a.MarginCalledAt = time.Time{}
+	}
+	a.IsMarginCall = isMarginCall
+	return isMarginCall
+}
+
+// snapshot - 外部公開用の口座情報
+func (a *account) snapshot() *Account {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return &Account{
+		Cash:                 a.Cash,
+		ReservedCash:         a.reservedCash(),
+		BuyingPower:          a.buyingPower(),
+		MarginDeposit:        a.marginDeposit(),
+		MarginPositionAmount: a.MarginPositionAmount,
+		MarginProfit:         a.MarginProfit,
+		RequiredMargin:       a.requiredMargin(),
+		MaintenanceRatio:     a.maintenanceRatio(),
+		IsMarginCall:         a.IsMarginCall,
+		MarginCalledAt:       a.MarginCalledAt,
+	}
+}
+
+// state - 書き出すための口座の状態
+func (a *account) state() *accountState {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	state := &accountState{
+		Cash:                  a.Cash,
+		InitialMarginRate:     a.InitialMarginRate,
+		MaintenanceMarginRate: a.MaintenanceMarginRate,
+		MarginPositionAmount:  a.MarginPositionAmount,
+		MarginProfit:          a.MarginProfit,
+		IsMarginCall:          a.IsMarginCall,
+		MarginCalledAt:        a.MarginCalledAt,
+		Reservations:          map[string]*reservationState{},
+		MarginReservations:    map[string]*reservationState{},
+	}
+	for code, r := range a.reservations {
+		state.Reservations[code] = &reservationState{Price: r.price, Quantity: r.quantity}
+	}
+	for code, r := range a.marginReservations {
+		state.MarginReservations[code] = &reservationState{Price: r.price, Quantity: r.quantity}
+	}
+	return state
+}
+
+// restore - 書き出した口座の状態に戻す
+func (a *account) restore(state *accountState) {
+	if state == nil {
+		return
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.Cash = state.Cash
+	a.InitialMarginRate = state.InitialMarginRate
+	a.MaintenanceMarginRate = state.MaintenanceMarginRate
+	a.MarginPositionAmount = state.MarginPositionAmount
+	a.MarginProfit = state.MarginProfit
+	a.IsMarginCall = state.IsMarginCall
+	a.MarginCalledAt = state.MarginCalledAt
+	a.reservations = map[string]*cashReservation{}
+	for code, r := range state.Reservations {
+		a.reservations[code] = &cashReservation{price: r.Price, quantity: r.Quantity}
+	}
+	a.marginReservations = map[string]*cashReservation{}
+	for code, r := range state.MarginReservations {
+		a.marginReservations[code] = &cashReservation{price: r.Price, quantity: r.Quantity}
+	}
+}
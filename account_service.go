@@ -0,0 +1,354 @@
+package virtual_security
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+func newAccountService(clock iClock, account *account, isCashManaged bool, isMarginCallLiquidation bool, feeSchedules map[ExchangeType]FeeSchedule, marginCostRates MarginCostRates) iAccountService {
+	return &accountService{
+		clock:                   clock,
+		account:                 account,
+		isCashManaged:           isCashManaged,
+		isMarginCallLiquidation: isMarginCallLiquidation,
+		feeSchedules:            feeSchedules,
+		marginCostRates:         marginCostRates,
+	}
+}
+
+type iAccountService interface {
+	deposit(amount float64) error
+	withdraw(amount float64) error
+	getAccount() *Account
+	reserveStockOrder(order *stockOrder, price *symbolPrice) error
+	releaseStockOrder(order *stockOrder)
+	payStockContract(order *stockOrder, contract *Contract)
+	receiveStockContract(contract *Contract)
+	reserveModifiedStockOrder(order *stockOrder, limitPrice float64, quantity float64, price *symbolPrice) error
+	reserveMarginOrder(order *marginOrder, price *symbolPrice) error
+	reserveModifiedMarginOrder(order *marginOrder, limitPrice float64, quantity float64, price *symbolPrice) error
+	releaseMarginOrder(order *marginOrder)
+	contractMarginEntry(order *marginOrder, contract *Contract)
+	settleMarginContract(position *marginPosition, contract *Contract)
+	evaluateMarginPositions(positions []*marginPosition, prices map[string]float64, now time.Time) bool
+	settleFutureProfit(profit float64)
+	chargeContractFee(target FeeTarget, contract *Contract)
+	marginHoldingCost(position *marginPosition, quantity float64, now time.Time) MarginCost
+	getState() *accountState
+	restore(state *accountState)
+}
+
+type accountService struct {
+	clock                   iClock
+	account                 *account
+	isCashManaged           bool                         // 買付余力や委託保証金のチェックをするか
+	isMarginCallLiquidation bool                         // 追証が発生したら建玉を強制決済するか
+	feeSchedules            map[ExchangeType]FeeSchedule // 市場種別ごとの手数料の計算方法
+	marginCostRates         MarginCostRates              // 信用建玉の保有にかかる費用の料率
+	dailyAmounts            map[ExchangeType]*dailyAmount
+	mtx                     sync.Mutex
+}
+
+// dailyAmount - 1日の約定代金の合計
+type dailyAmount struct {
+	day    time.Time
+	amount float64
+}
+
+func (s *accountService) deposit(amount float64) error {
+	return s.account.deposit(amount)
+}
+
+func (s *accountService) withdraw(amount float64) error {
+	return s.account.withdraw(amount)
+}
+
+func (s *accountService) getAccount() *Account {
+	return s.account.snapshot()
+}
+
+// estimateOrderPrice - 注文に必要な金額を計算するための単価を見積もる
+//   指値があれば指値、逆指値なら発動後の指値か逆指値価格、成行なら買いは売り気配値、売りは買い気配値か現値を利用する
+//   買いの成行は売板を上の気配値まで約定していくので、売板があれば注文数量が約定する最も高い気配値を利用する
+func (s *accountService) estimateOrderPrice(side Side, executionCondition StockExecutionCondition, limitPrice float64, quantity float64, stopCondition *StockStopCondition, price *symbolPrice) float64 {
+	if executionCondition.IsStop() && stopCondition != nil {
+		if stopCondition.LimitPriceAfterHit > 0 {
+			return stopCondition.LimitPriceAfterHit
+		}
+		return stopCondition.StopPrice
+	}
+	if limitPrice > 0 {
+		return limitPrice
+	}
+	if price != nil {
+		if side == SideBuy && price.Ask > 0 {
+			return math.Max(price.Ask, price.askBoardPrice(quantity))
+		}
+		if side == SideSell && price.Bid > 0 {
+			return price.Bid
+		}
+		if price.Price > 0 {
+			return price.Price
+		}
+	}
+	return 0
+}
+
+// reserveStockOrder - 買い注文に必要な金額を拘束する
+//   買付余力を管理している場合、余力が足りなければエラーを返す
+func (s *accountService) reserveStockOrder(order *stockOrder, price *symbolPrice) error {
+	if order == nil {
+		return NilArgumentError
+	}
+	if order.Side != SideBuy {
+		return nil
+	}
+
+	estimated := s.estimateOrderPrice(order.Side, order.ExecutionCondition, order.LimitPrice, order.OrderQuantity, order.StopCondition, price)
+	if s.isCashManaged {
+		if estimated <= 0 {
+			return fmt.Errorf("cannot estimate order price(symbol code: %s), %w", order.SymbolCode, NoDataError)
+		}
+		if err := s.account.reservable(estimated, order.OrderQuantity); err != nil {
+			return err
+		}
+	}
+
+	s.account.reserve(order.Code, estimated, order.OrderQuantity)
+	return nil
+}
+
+// reserveModifiedStockOrder - 訂正する買い注文の拘束金額を、訂正後の指値と約定していない数量で拘束し直す
+//   買付余力を管理している場合、訂正前の拘束金額を戻しても買付余力が足りなければエラーを返す
+func (s *accountService) reserveModifiedStockOrder(order *stockOrder, limitPrice float64, quantity float64, price *symbolPrice) error {
+	if order == nil {
+		return NilArgumentError
+	}
+	if order.Side != SideBuy {
+		return nil
+	}
+
+	estimated := s.estimateOrderPrice(order.Side, order.ExecutionCondition, limitPrice, quantity, order.StopCondition, price)
+	if s.isCashManaged {
+		if estimated <= 0 {
+			return fmt.Errorf("cannot estimate order price(symbol code: %s), %w", order.SymbolCode, NoDataError)
+		}
+		if err := s.account.rereservable(order.Code, estimated, quantity); err != nil {
+			return err
+		}
+	}
+
+	s.account.reserve(order.Code, estimated, quantity)
+	return nil
+}
+
+// releaseStockOrder - 注文が拘束している金額を開放する
+func (s *accountService) releaseStockOrder(order *stockOrder) {
+	if order == nil {
+		return
+	}
+	s.account.releaseAll(order.Code)
+}
+
+// payStockContract - 買い注文の約定代金を支払い、約定した数量分の拘束を開放する
+func (s *accountService) payStockContract(order *stockOrder, contract *Contract) {
+	if order == nil || contract == nil {
+		return
+	}
+	s.account.release(order.Code, contract.Quantity)
+	s.account.pay(contract.Price * contract.Quantity)
+}
+
+// receiveStockContract - 売り注文の約定代金を受け取る
+func (s *accountService) receiveStockContract(contract *Contract) {
+	if contract == nil {
+		return
+	}
+	s.account.receive(contract.Price * contract.Quantity)
+}
+
+// reserveMarginOrder - 信用新規注文の建玉予定金額を登録する
+//   委託保証金を管理している場合、委託保証金が足りなければエラーを返す
+func (s *accountService) reserveMarginOrder(order *marginOrder, price *symbolPrice) error {
+	if order == nil {
+		return NilArgumentError
+	}
+	if order.TradeType != TradeTypeEntry {
+		return nil
+	}
+
+	estimated := s.estimateOrderPrice(order.Side, order.ExecutionCondition, order.LimitPrice, order.OrderQuantity, order.StopCondition, price)
+	if s.isCashManaged {
+		if estimated <= 0 {
+			return fmt.Errorf("cannot estimate order price(symbol code: %s), %w", order.SymbolCode, NoDataError)
+		}
+		if err := s.account.marginReservable(estimated, order.OrderQuantity); err != nil {
+			return err
+		}
+	}
+
+	s.account.reserveMargin(order.Code, estimated, order.OrderQuantity)
+	return nil
+}
+
+// reserveModifiedMarginOrder - 訂正する信用新規注文の建玉予定金額を、訂正後の指値と約定していない数量で登録し直す
+//   委託保証金を管理している場合、訂正前の建玉予定金額を戻しても委託保証金が足りなければエラーを返す
+func (s *accountService) reserveModifiedMarginOrder(order *marginOrder, limitPrice float64, quantity float64, price *symbolPrice) error {
+	if order == nil {
+		return NilArgumentError
+	}
+	if order.TradeType != TradeTypeEntry {
+		return nil
+	}
+
+	estimated := s.estimateOrderPrice(order.Side, order.ExecutionCondition, limitPrice, quantity, order.StopCondition, price)
+	if s.isCashManaged {
+		if estimated <= 0 {
+			return fmt.Errorf("cannot estimate order price(symbol code: %s), %w", order.SymbolCode, NoDataError)
+		}
+		if err := s.account.marginRereservable(order.Code, estimated, quantity); err != nil {
+			return err
+		}
+	}
+
+	s.account.reserveMargin(order.Code, estimated, quantity)
+	return nil
+}
+
+// releaseMarginOrder - 信用新規注文の建玉予定金額を開放する
+func (s *accountService) releaseMarginOrder(order *marginOrder) {
+	if order == nil {
+		return
+	}
+	s.account.releaseMargin(order.Code)
+}
+
+// contractMarginEntry - 信用新規注文の約定を建玉金額に反映する
+func (s *accountService) contractMarginEntry(order *marginOrder, contract *Contract) {
+	if order == nil || contract == nil {
+		return
+	}
+	s.account.contractMargin(order.Code, contract.Price, contract.Quantity)
+}
+
+// settleMarginContract - 信用返済の約定で確定した損益を受け渡す
+//   返済した数量の保有にかかった費用は約定に記録し、損益から差し引く
+func (s *accountService) settleMarginContract(position *marginPosition, contract *Contract) {
+	if position == nil || contract == nil {
+		return
+	}
+
+	profit := (contract.Price - position.Price) * contract.Quantity
+	if position.Side == SideSell {
+		profit = -profit
+	}
+	contract.HoldingCost = s.marginHoldingCost(position, contract.Quantity, contract.ContractedAt).Total()
+	s.account.exitMargin(position.Price*contract.Quantity, profit-contract.HoldingCost)
+}
+
+// marginHoldingCost - 信用建玉の数量をnowまで保有したときにかかる費用
+func (s *accountService) marginHoldingCost(position *marginPosition, quantity float64, now time.Time) MarginCost {
+	if position == nil {
+		return MarginCost{}
+	}
+	return s.marginCostRates.cost(s.clock, position.Side, position.SymbolCode, position.Price, quantity, position.ContractedAt, now)
+}
+
+// evaluateMarginPositions - 信用建玉を現在値で評価して委託保証金維持率を更新し、強制決済が必要かを返す
+//   現在値がない銘柄は約定価格で評価する
+func (s *accountService) evaluateMarginPositions(positions []*marginPosition, prices map[string]float64, now time.Time) bool {
+	var amount, profit float64
+	for _, p := range positions {
+		if p.OwnedQuantity <= 0 {
+			continue
+		}
+		amount += p.Price * p.OwnedQuantity
+
+		current, ok := prices[p.SymbolCode]
+		if !ok || current <= 0 {
+			continue
+		}
+		if p.Side == SideSell {
+			profit += (p.Price - current) * p.OwnedQuantity
+		} else {
+			profit += (current - p.Price) * p.OwnedQuantity
+		}
+	}
+
+	isMarginCall := s.account.evaluateMargin(amount, profit, now)
+	return isMarginCall && s.isMarginCallLiquidation
+}
+
+// settleFutureProfit - 先物の値洗いや返済で確定した損益を受け渡す
+func (s *accountService) settleFutureProfit(profit float64) {
+	if profit == 0 {
+		return
+	}
+	s.account.receive(profit)
+}
+
+// chargeContractFee - 約定の手数料を計算して約定に記録し、預り金から支払う
+//   1日の約定代金の合計は、約定日時の営業日ごとに市場種別ごとに集計する (先物の夜間は翌営業日に含める)
+func (s *accountService) chargeContractFee(target FeeTarget, contract *Contract) {
+	if contract == nil {
+		return
+	}
+	schedule, ok := s.feeSchedules[target.ExchangeType]
+	if !ok || schedule == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.dailyAmounts == nil {
+		s.dailyAmounts = map[ExchangeType]*dailyAmount{}
+	}
+	day := s.clock.getBusinessDay(target.ExchangeType, target.ContractedAt)
+	daily, ok := s.dailyAmounts[target.ExchangeType]
+	if !ok || !daily.day.Equal(day) {
+		daily = &dailyAmount{day: day}
+		s.dailyAmounts[target.ExchangeType] = daily
+	}
+	target.DailyAmount = daily.amount
+	daily.amount += target.Amount
+
+	fee := schedule.Fee(target)
+	if fee <= 0 {
+		return
+	}
+	contract.Fee = fee
+	s.account.pay(fee)
+}
+
+// getState - 書き出すための口座の状態
+func (s *accountService) getState() *accountState {
+	state := s.account.state()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	state.DailyAmounts = map[ExchangeType]*dailyAmountState{}
+	for exchangeType, daily := range s.dailyAmounts {
+		state.DailyAmounts[exchangeType] = &dailyAmountState{Day: daily.day, Amount: daily.amount}
+	}
+	return state
+}
+
+// restore - 書き出した口座の状態に戻す
+func (s *accountService) restore(state *accountState) {
+	if state == nil {
+		return
+	}
+	s.account.restore(state)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.dailyAmounts = map[ExchangeType]*dailyAmount{}
+	for exchangeType, daily := range state.DailyAmounts {
+		s.dailyAmounts[exchangeType] = &dailyAmount{day: daily.Day, amount: daily.Amount}
+	}
+}
@@ -0,0 +1,629 @@
+package virtual_security
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testAccountService struct {
+	iAccountService
+	deposit1                     error
+	depositHistory               []float64
+	withdraw1                    error
+	withdrawHistory              []float64
+	getAccount1                  *Account
+	reserveStockOrder1           error
+	reserveStockOrderHistory     []*stockOrder
+	releaseStockOrderHistory     []*stockOrder
+	payStockContractHistory      []*Contract
+	receiveStockContractHistory  []*Contract
+	settleMarginContractHistory  []*Contract
+	reserveMarginOrder1          error
+	reserveMarginOrderHistory    []*marginOrder
+	releaseMarginOrderHistory    []*marginOrder
+	contractMarginEntryHistory   []*Contract
+	evaluateMarginPositions1     bool
+	evaluateMarginPositionsCount int
+	settleFutureProfitHistory    []float64
+	chargeContractFeeHistory     []FeeTarget
+	marginHoldingCost1           MarginCost
+	reserveModified1             error
+	reserveModifiedHistory       []float64
+}
+
+func (t *testAccountService) reserveModifiedStockOrder(_ *stockOrder, limitPrice float64, quantity float64, _ *symbolPrice) error {
+	t.reserveModifiedHistory = append(t.reserveModifiedHistory, limitPrice, quantity)
+	return t.reserveModified1
+}
+
+func (t *testAccountService) reserveModifiedMarginOrder(_ *marginOrder, limitPrice float64, quantity float64, _ *symbolPrice) error {
+	t.reserveModifiedHistory = append(t.reserveModifiedHistory, limitPrice, quantity)
+	return t.reserveModified1
+}
+
+func (t *testAccountService) marginHoldingCost(*marginPosition, float64, time.Time) MarginCost {
+	return t.marginHoldingCost1
+}
+
+func (t *testAccountService) chargeContractFee(target FeeTarget, _ *Contract) {
+	t.chargeContractFeeHistory = append(t.chargeContractFeeHistory, target)
+}
+
+func (t *testAccountService) settleFutureProfit(profit float64) {
+	t.settleFutureProfitHistory = append(t.settleFutureProfitHistory, profit)
+}
+
+func (t *testAccountService) deposit(amount float64) error {
+	t.depositHistory = append(t.depositHistory, amount)
+	return t.deposit1
+}
+func (t *testAccountService) withdraw(amount float64) error {
+	t.withdrawHistory = append(t.withdrawHistory, amount)
+	return t.withdraw1
+}
+func (t *testAccountService) getAccount() *Account { return t.getAccount1 }
+func (t *testAccountService) reserveStockOrder(order *stockOrder, _ *symbolPrice) error {
+	t.reserveStockOrderHistory = append(t.reserveStockOrderHistory, order)
+	return t.reserveStockOrder1
+}
+func (t *testAccountService) releaseStockOrder(order *stockOrder) {
+	t.releaseStockOrderHistory = append(t.releaseStockOrderHistory, order)
+}
+func (t *testAccountService) payStockContract(_ *stockOrder, contract *Contract) {
+	t.payStockContractHistory = append(t.payStockContractHistory, contract)
+}
+func (t *testAccountService) receiveStockContract(contract *Contract) {
+	t.receiveStockContractHistory = append(t.receiveStockContractHistory, contract)
+}
+func (t *testAccountService) settleMarginContract(_ *marginPosition, contract *Contract) {
+	t.settleMarginContractHistory = append(t.settleMarginContractHistory, contract)
+}
+
+func (t *testAccountService) reserveMarginOrder(order *marginOrder, _ *symbolPrice) error {
+	t.reserveMarginOrderHistory = append(t.reserveMarginOrderHistory, order)
+	return t.reserveMarginOrder1
+}
+func (t *testAccountService) releaseMarginOrder(order *marginOrder) {
+	t.releaseMarginOrderHistory = append(t.releaseMarginOrderHistory, order)
+}
+func (t *testAccountService) contractMarginEntry(_ *marginOrder, contract *Contract) {
+	t.contractMarginEntryHistory = append(t.contractMarginEntryHistory, contract)
+}
+func (t *testAccountService) evaluateMarginPositions([]*marginPosition, map[string]float64, time.Time) bool {
+	t.evaluateMarginPositionsCount++
+	return t.evaluateMarginPositions1
+}
+
+func Test_newAccountService(t *testing.T) {
+	t.Parallel()
+	a := &account{Cash: 1_000_000}
+	feeSchedules := map[ExchangeType]FeeSchedule{ExchangeTypeStock: FlatFee(55)}
+	want := &accountService{clock: newClock(), account: a, isCashManaged: true, isMarginCallLiquidation: true, feeSchedules: feeSchedules, marginCostRates: MarginCostRates{BuyInterestRate: 0.028}}
+	got := newAccountService(newClock(), a, true, true, feeSchedules, MarginCostRates{BuyInterestRate: 0.028})
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_accountService_estimateOrderPrice(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name               string
+		side               Side
+		executionCondition StockExecutionCondition
+		limitPrice         float64
+		quantity           float64
+		stopCondition      *StockStopCondition
+		price              *symbolPrice
+		want               float64
+	}{
+		{name: "指値なら指値価格",
+			side: SideBuy, executionCondition: StockExecutionConditionLO, limitPrice: 1000,
+			price: &symbolPrice{Price: 900, Ask: 910},
+			want:  1000},
+		{name: "逆指値で発動後が指値なら発動後の指値価格",
+			side: SideBuy, executionCondition: StockExecutionConditionStop,
+			stopCondition: &StockStopCondition{StopPrice: 1100, ExecutionConditionAfterHit: StockExecutionConditionLO, LimitPriceAfterHit: 1200},
+			price:         &symbolPrice{Price: 900, Ask: 910},
+			want:          1200},
+		{name: "逆指値で発動後が成行なら逆指値価格",
+			side: SideBuy, executionCondition: StockExecutionConditionStop,
+			stopCondition: &StockStopCondition{StopPrice: 1100, ExecutionConditionAfterHit: StockExecutionConditionMO},
+			price:         &symbolPrice{Price: 900, Ask: 910},
+			want:          1100},
+		{name: "買いの成行で売り気配値があれば売り気配値",
+			side: SideBuy, executionCondition: StockExecutionConditionMO,
+			price: &symbolPrice{Price: 900, Ask: 910, Bid: 890},
+			want:  910},
+		{name: "買いの成行で売板があれば注文数量が約定する最も高い気配値",
+			side: SideBuy, executionCondition: StockExecutionConditionMO, quantity: 200,
+			price: &symbolPrice{Price: 900, Ask: 910, Bid: 890, AskBoard: []BoardLevel{{Price: 910, Quantity: 100}, {Price: 920, Quantity: 100}, {Price: 930, Quantity: 100}}},
+			want:  920},
+		{name: "売りの成行で買い気配値があれば買い気配値",
+			side: SideSell, executionCondition: StockExecutionConditionMO,
+			price: &symbolPrice{Price: 900, Ask: 910, Bid: 890},
+			want:  890},
+		{name: "成行で気配値がなければ現値",
+			side: SideBuy, executionCondition: StockExecutionConditionMO,
+			price: &symbolPrice{Price: 900},
+			want:  900},
+		{name: "成行で価格情報がなければ0",
+			side: SideBuy, executionCondition: StockExecutionConditionMO,
+			price: nil,
+			want:  0},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := (&accountService{}).estimateOrderPrice(test.side, test.executionCondition, test.limitPrice, test.quantity, test.stopCondition, test.price)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_accountService_reserveStockOrder(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name             string
+		service          *accountService
+		order            *stockOrder
+		price            *symbolPrice
+		want             error
+		wantReservedCash float64
+	}{
+		{name: "注文がnilならエラー",
+			service: &accountService{account: &account{}},
+			want:    NilArgumentError},
+		{name: "売り注文なら何もしない",
+			service: &accountService{account: &account{}, isCashManaged: true},
+			order:   &stockOrder{Code: "sor-1", Side: SideSell, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100},
+			want:    nil},
+		{name: "余力を管理していなければ余力が足りなくても拘束する",
+			service:          &accountService{account: &account{Cash: 0}},
+			order:            &stockOrder{Code: "sor-1", Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100},
+			want:             nil,
+			wantReservedCash: 100_000},
+		{name: "余力を管理していて余力が足りればを拘束する",
+			service:          &accountService{account: &account{Cash: 100_000}, isCashManaged: true},
+			order:            &stockOrder{Code: "sor-1", Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100},
+			want:             nil,
+			wantReservedCash: 100_000},
+		{name: "余力を管理していて余力が足りなければエラー",
+			service: &accountService{account: &account{Cash: 99_999}, isCashManaged: true},
+			order:   &stockOrder{Code: "sor-1", Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100},
+			want:    NotEnoughBuyingPowerError},
+		{name: "余力を管理していて注文金額が見積もれなければエラー",
+			service: &accountService{account: &account{Cash: 100_000}, isCashManaged: true},
+			order:   &stockOrder{Code: "sor-1", Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, OrderQuantity: 100},
+			want:    NoDataError},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.service.reserveStockOrder(test.order, test.price)
+			gotReservedCash := test.service.getAccount().ReservedCash
+			if !errors.Is(got, test.want) || !reflect.DeepEqual(test.wantReservedCash, gotReservedCash) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want, test.wantReservedCash, got, gotReservedCash)
+			}
+		})
+	}
+}
+
+func Test_accountService_stockContract(t *testing.T) {
+	t.Parallel()
+	service := &accountService{account: &account{Cash: 1_000_000}, isCashManaged: true}
+	order := &stockOrder{Code: "sor-1", Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 300}
+	if err := service.reserveStockOrder(order, nil); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	service.payStockContract(order, &Contract{Price: 990, Quantity: 100, ContractedAt: time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local)})
+	want1 := &Account{Cash: 901_000, ReservedCash: 200_000, BuyingPower: 701_000, MarginDeposit: 701_000}
+	got1 := service.getAccount()
+
+	service.releaseStockOrder(order)
+	want2 := &Account{Cash: 901_000, ReservedCash: 0, BuyingPower: 901_000, MarginDeposit: 901_000}
+	got2 := service.getAccount()
+
+	service.receiveStockContract(&Contract{Price: 1010, Quantity: 100})
+	want3 := &Account{Cash: 1_002_000, ReservedCash: 0, BuyingPower: 1_002_000, MarginDeposit: 1_002_000}
+	got3 := service.getAccount()
+
+	if !reflect.DeepEqual(want1, got1) || !reflect.DeepEqual(want2, got2) || !reflect.DeepEqual(want3, got3) {
+		t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(), want1, want2, want3, got1, got2, got3)
+	}
+}
+
+func Test_accountService_settleMarginContract(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		position *marginPosition
+		contract *Contract
+		want     float64
+	}{
+		{name: "買いポジションを高く返済したら利益を受け取る",
+			position: &marginPosition{Side: SideBuy, Price: 1000},
+			contract: &Contract{Price: 1100, Quantity: 100},
+			want:     1_010_000},
+		{name: "買いポジションを安く返済したら損失を支払う",
+			position: &marginPosition{Side: SideBuy, Price: 1000},
+			contract: &Contract{Price: 900, Quantity: 100},
+			want:     990_000},
+		{name: "売りポジションを安く返済したら利益を受け取る",
+			position: &marginPosition{Side: SideSell, Price: 1000},
+			contract: &Contract{Price: 900, Quantity: 100},
+			want:     1_010_000},
+		{name: "引数がnilなら何もしない",
+			position: nil,
+			contract: &Contract{Price: 900, Quantity: 100},
+			want:     1_000_000},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := &accountService{account: &account{Cash: 1_000_000}}
+			service.settleMarginContract(test.position, test.contract)
+			got := service.account.Cash
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_accountService_settleMarginContract_holdingCost(t *testing.T) {
+	t.Parallel()
+	service := &accountService{clock: newClock(), account: &account{Cash: 1_000_000}, marginCostRates: MarginCostRates{BuyInterestRate: 0.0365}}
+	position := &marginPosition{Side: SideBuy, Price: 1000, ContractedAt: time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local)}
+	contract := &Contract{Price: 1100, Quantity: 100, ContractedAt: time.Date(2021, 9, 2, 10, 0, 0, 0, time.Local)}
+
+	// 水曜日に建てて木曜日に返済すると、金曜日から月曜日の受渡日まで4日分の金利がかかる
+	service.settleMarginContract(position, contract)
+	if contract.HoldingCost != 40 || service.account.Cash != 1_009_960 {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), 40, 1_009_960, contract.HoldingCost, service.account.Cash)
+	}
+}
+
+func Test_accountService_reserveMarginOrder(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name               string
+		service            *accountService
+		order              *marginOrder
+		price              *symbolPrice
+		want               error
+		wantRequiredMargin float64
+	}{
+		{name: "注文がnilならエラー",
+			service: &accountService{account: &account{}},
+			want:    NilArgumentError},
+		{name: "返済注文なら何もしない",
+			service: &accountService{account: &account{InitialMarginRate: 0.3}, isCashManaged: true},
+			order:   &marginOrder{Code: "mor-1", TradeType: TradeTypeExit, Side: SideSell, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100},
+			want:    nil},
+		{name: "委託保証金を管理していなければ保証金が足りなくても登録する",
+			service:            &accountService{account: &account{InitialMarginRate: 0.3}},
+			order:              &marginOrder{Code: "mor-1", TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100},
+			want:               nil,
+			wantRequiredMargin: 30_000},
+		{name: "委託保証金が足りれば登録する",
+			service:            &accountService{account: &account{Cash: 30_000, InitialMarginRate: 0.3}, isCashManaged: true},
+			order:              &marginOrder{Code: "mor-1", TradeType: TradeTypeEntry, Side: SideSell, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100},
+			want:               nil,
+			wantRequiredMargin: 30_000},
+		{name: "委託保証金が足りなければエラー",
+			service: &accountService{account: &account{Cash: 29_999, InitialMarginRate: 0.3}, isCashManaged: true},
+			order:   &marginOrder{Code: "mor-1", TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100},
+			want:    NotEnoughMarginError},
+		{name: "委託保証金を管理していて価格を見積もれなければエラー",
+			service: &accountService{account: &account{Cash: 1_000_000, InitialMarginRate: 0.3}, isCashManaged: true},
+			order:   &marginOrder{Code: "mor-1", TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, OrderQuantity: 100},
+			want:    NoDataError},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.service.reserveMarginOrder(test.order, test.price)
+			gotRequiredMargin := test.service.account.requiredMargin()
+			if !errors.Is(got, test.want) || !reflect.DeepEqual(test.wantRequiredMargin, gotRequiredMargin) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want, test.wantRequiredMargin, got, gotRequiredMargin)
+			}
+		})
+	}
+}
+
+func Test_accountService_marginEntry(t *testing.T) {
+	t.Parallel()
+	service := &accountService{account: &account{Cash: 1_000_000, InitialMarginRate: 0.3}, isCashManaged: true}
+	order := &marginOrder{Code: "mor-1", TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 200}
+	if err := service.reserveMarginOrder(order, nil); err != nil {
+		t.Fatalf("%s error\nreserve: %+v\n", t.Name(), err)
+	}
+
+	// 一部約定したら約定分は建玉金額に振り替わる
+	service.contractMarginEntry(order, &Contract{Price: 990, Quantity: 100})
+	if got := service.account.MarginPositionAmount; got != 99_000 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 99_000, got)
+	}
+	if got := service.account.totalReservation(service.account.marginReservations); got != 100_000 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 100_000, got)
+	}
+
+	// 取り消したら残りの建玉予定金額は開放される
+	service.releaseMarginOrder(order)
+	if got := service.account.requiredMargin(); got != 29_700 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 29_700, got)
+	}
+}
+
+func Test_accountService_evaluateMarginPositions(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2021, 8, 2, 10, 0, 0, 0, time.Local)
+	positions := []*marginPosition{
+		{SymbolCode: "1234", Side: SideBuy, Price: 1000, OwnedQuantity: 100},
+		{SymbolCode: "5678", Side: SideSell, Price: 2000, OwnedQuantity: 100},
+		{SymbolCode: "9999", Side: SideBuy, Price: 500, OwnedQuantity: 0},
+	}
+	tests := []struct {
+		name               string
+		service            *accountService
+		prices             map[string]float64
+		want               bool
+		wantMarginProfit   float64
+		wantIsMarginCall   bool
+		wantMarginCalledAt time.Time
+	}{
+		{name: "維持率が最低維持率以上なら追証にならない",
+			service:          &accountService{account: &account{Cash: 100_000, InitialMarginRate: 0.3, MaintenanceMarginRate: 0.2}, isMarginCallLiquidation: true},
+			prices:           map[string]float64{"1234": 1100, "5678": 2100},
+			want:             false,
+			wantMarginProfit: 0},
+		{name: "維持率が最低維持率を下回ったら追証になる",
+			service:            &accountService{account: &account{Cash: 100_000, InitialMarginRate: 0.3, MaintenanceMarginRate: 0.2}},
+			prices:             map[string]float64{"1234": 600, "5678": 2100},
+			want:               false,
+			wantMarginProfit:   -50_000,
+			wantIsMarginCall:   true,
+			wantMarginCalledAt: now},
+		{name: "追証になって強制決済が有効ならtrue",
+			service:            &accountService{account: &account{Cash: 100_000, InitialMarginRate: 0.3, MaintenanceMarginRate: 0.2}, isMarginCallLiquidation: true},
+			prices:             map[string]float64{"1234": 600, "5678": 2100},
+			want:               true,
+			wantMarginProfit:   -50_000,
+			wantIsMarginCall:   true,
+			wantMarginCalledAt: now},
+		{name: "最低維持率が0なら追証にならない",
+			service:          &accountService{account: &account{Cash: 100_000}, isMarginCallLiquidation: true},
+			prices:           map[string]float64{"1234": 600, "5678": 2100},
+			want:             false,
+			wantMarginProfit: -50_000},
+		{name: "現在値がなければ評価損益は0",
+			service:          &accountService{account: &account{Cash: 100_000, InitialMarginRate: 0.3, MaintenanceMarginRate: 0.2}, isMarginCallLiquidation: true},
+			prices:           map[string]float64{},
+			want:             false,
+			wantMarginProfit: 0},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.service.evaluateMarginPositions(positions, test.prices, now)
+			a := test.service.account
+			if !reflect.DeepEqual(test.want, got) ||
+				a.MarginPositionAmount != 300_000 ||
+				a.MarginProfit != test.wantMarginProfit ||
+				a.IsMarginCall != test.wantIsMarginCall ||
+				!a.MarginCalledAt.Equal(test.wantMarginCalledAt) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantMarginProfit, test.wantIsMarginCall, test.wantMarginCalledAt,
+					got, a.MarginProfit, a.IsMarginCall, a.MarginCalledAt)
+			}
+		})
+	}
+}
+
+func Test_accountService_settleFutureProfit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		profit float64
+		want   float64
+	}{
+		{name: "利益なら受け取る", profit: 50_000, want: 1_050_000},
+		{name: "損失なら支払う", profit: -50_000, want: 950_000},
+		{name: "損益がなければ何もしない", profit: 0, want: 1_000_000},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := &accountService{account: &account{Cash: 1_000_000}}
+			service.settleFutureProfit(test.profit)
+			got := service.account.Cash
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_accountService_chargeContractFee(t *testing.T) {
+	t.Parallel()
+	day1 := time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local)
+	day2 := time.Date(2021, 9, 2, 10, 0, 0, 0, time.Local)
+	daily := DailyFee{{MaxAmount: 1_000_000, Fee: 0}, {MaxAmount: 2_000_000, Fee: 1_000}}
+	tests := []struct {
+		name      string
+		schedules map[ExchangeType]FeeSchedule
+		targets   []FeeTarget
+		wantFees  []float64
+		wantCash  float64
+	}{
+		{name: "手数料の計算方法がなければ0",
+			schedules: map[ExchangeType]FeeSchedule{ExchangeTypeStock: FlatFee(100)},
+			targets:   []FeeTarget{{ExchangeType: ExchangeTypeMargin, Amount: 100_000, ContractedAt: day1}},
+			wantFees:  []float64{0},
+			wantCash:  1_000_000},
+		{name: "約定ごとに手数料を記録して預り金から支払う",
+			schedules: map[ExchangeType]FeeSchedule{ExchangeTypeStock: FlatFee(100)},
+			targets:   []FeeTarget{{ExchangeType: ExchangeTypeStock, Amount: 100_000, ContractedAt: day1}, {ExchangeType: ExchangeTypeStock, Amount: 100_000, ContractedAt: day1}},
+			wantFees:  []float64{100, 100},
+			wantCash:  999_800},
+		{name: "1日の約定代金は日付ごとに集計する",
+			schedules: map[ExchangeType]FeeSchedule{ExchangeTypeStock: daily},
+			targets: []FeeTarget{
+				{ExchangeType: ExchangeTypeStock, Amount: 800_000, ContractedAt: day1},
+				{ExchangeType: ExchangeTypeStock, Amount: 800_000, ContractedAt: day1},
+				{ExchangeType: ExchangeTypeStock, Amount: 800_000, ContractedAt: day2},
+			},
+			wantFees: []float64{0, 1_000, 0},
+			wantCash: 999_000},
+		{name: "先物の夜間の約定代金は翌営業日に集計する",
+			schedules: map[ExchangeType]FeeSchedule{ExchangeTypeFuture: daily},
+			targets: []FeeTarget{
+				{ExchangeType: ExchangeTypeFuture, Amount: 800_000, ContractedAt: time.Date(2021, 9, 1, 20, 0, 0, 0, time.Local)},
+				{ExchangeType: ExchangeTypeFuture, Amount: 800_000, ContractedAt: day2},
+			},
+			wantFees: []float64{0, 1_000},
+			wantCash: 999_000},
+		{name: "金曜日の夜間の約定代金は月曜日に集計する",
+			schedules: map[ExchangeType]FeeSchedule{ExchangeTypeFuture: daily},
+			targets: []FeeTarget{
+				{ExchangeType: ExchangeTypeFuture, Amount: 800_000, ContractedAt: time.Date(2021, 9, 3, 20, 0, 0, 0, time.Local)},
+				{ExchangeType: ExchangeTypeFuture, Amount: 800_000, ContractedAt: time.Date(2021, 9, 6, 10, 0, 0, 0, time.Local)},
+			},
+			wantFees: []float64{0, 1_000},
+			wantCash: 999_000},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			a := &account{Cash: 1_000_000}
+			service := newAccountService(newClockWithCalendar(nil, newCalendar(nil), defaultTradingSchedule), a, false, false, test.schedules, MarginCostRates{})
+			got := make([]float64, len(test.targets))
+			for i, target := range test.targets {
+				contract := &Contract{}
+				service.chargeContractFee(target, contract)
+				got[i] = contract.Fee
+			}
+			if !reflect.DeepEqual(test.wantFees, got) || test.wantCash != a.Cash {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.wantFees, test.wantCash, got, a.Cash)
+			}
+		})
+	}
+}
+
+func Test_accountService_reserveModifiedStockOrder(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name             string
+		service          *accountService
+		order            *stockOrder
+		limitPrice       float64
+		quantity         float64
+		want             error
+		wantReservedCash float64
+	}{
+		{name: "注文がnilならエラー",
+			service: &accountService{account: &account{}},
+			want:    NilArgumentError},
+		{name: "売り注文なら何もしない",
+			service:    &accountService{account: &account{}, isCashManaged: true},
+			order:      &stockOrder{Code: "sor-1", Side: SideSell, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100},
+			limitPrice: 1100,
+			quantity:   100,
+			want:       nil},
+		{name: "訂正前の拘束金額を戻して余力が足りれば拘束し直す",
+			service:          &accountService{account: &account{Cash: 100_000, reservations: map[string]*cashReservation{"sor-1": {price: 1000, quantity: 100}}}, isCashManaged: true},
+			order:            &stockOrder{Code: "sor-1", Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100},
+			limitPrice:       1200,
+			quantity:         80,
+			want:             nil,
+			wantReservedCash: 96_000},
+		{name: "訂正前の拘束金額を戻しても余力が足りなければエラー",
+			service:          &accountService{account: &account{Cash: 100_000, reservations: map[string]*cashReservation{"sor-1": {price: 1000, quantity: 100}}}, isCashManaged: true},
+			order:            &stockOrder{Code: "sor-1", Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100},
+			limitPrice:       1100,
+			quantity:         100,
+			want:             NotEnoughBuyingPowerError,
+			wantReservedCash: 100_000},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.service.reserveModifiedStockOrder(test.order, test.limitPrice, test.quantity, nil)
+			gotReservedCash := test.service.getAccount().ReservedCash
+			if !errors.Is(got, test.want) || !reflect.DeepEqual(test.wantReservedCash, gotReservedCash) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want, test.wantReservedCash, got, gotReservedCash)
+			}
+		})
+	}
+}
+
+func Test_accountService_reserveModifiedMarginOrder(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name               string
+		service            *accountService
+		order              *marginOrder
+		limitPrice         float64
+		quantity           float64
+		want               error
+		wantRequiredMargin float64
+	}{
+		{name: "注文がnilならエラー",
+			service: &accountService{account: &account{}},
+			want:    NilArgumentError},
+		{name: "返済注文なら何もしない",
+			service:    &accountService{account: &account{InitialMarginRate: 0.3}, isCashManaged: true},
+			order:      &marginOrder{Code: "mor-1", TradeType: TradeTypeExit, Side: SideSell, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100},
+			limitPrice: 1100,
+			quantity:   100,
+			want:       nil},
+		{name: "訂正前の建玉予定金額を戻して保証金が足りれば登録し直す",
+			service:            &accountService{account: &account{Cash: 30_000, InitialMarginRate: 0.3, marginReservations: map[string]*cashReservation{"mor-1": {price: 1000, quantity: 100}}}, isCashManaged: true},
+			order:              &marginOrder{Code: "mor-1", TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100},
+			limitPrice:         1000,
+			quantity:           50,
+			want:               nil,
+			wantRequiredMargin: 15_000},
+		{name: "訂正前の建玉予定金額を戻しても保証金が足りなければエラー",
+			service:            &accountService{account: &account{Cash: 30_000, InitialMarginRate: 0.3, marginReservations: map[string]*cashReservation{"mor-1": {price: 1000, quantity: 100}}}, isCashManaged: true},
+			order:              &marginOrder{Code: "mor-1", TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100},
+			limitPrice:         1100,
+			quantity:           100,
+			want:               NotEnoughMarginError,
+			wantRequiredMargin: 30_000},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.service.reserveModifiedMarginOrder(test.order, test.limitPrice, test.quantity, nil)
+			gotRequiredMargin := test.service.getAccount().RequiredMargin
+			if !errors.Is(got, test.want) || !reflect.DeepEqual(test.wantRequiredMargin, gotRequiredMargin) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want, test.wantRequiredMargin, got, gotRequiredMargin)
+			}
+		})
+	}
+}
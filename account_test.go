@@ -0,0 +1,200 @@
+package virtual_security
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_account_deposit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		account  *account
+		arg      float64
+		want     error
+		wantCash float64
+	}{
+		{name: "0以下ならエラー", account: &account{Cash: 1000}, arg: 0, want: InvalidAmountError, wantCash: 1000},
+		{name: "預り金に加算する", account: &account{Cash: 1000}, arg: 500, want: nil, wantCash: 1500},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.account.deposit(test.arg)
+			if !errors.Is(got, test.want) || !reflect.DeepEqual(test.wantCash, test.account.Cash) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want, test.wantCash, got, test.account.Cash)
+			}
+		})
+	}
+}
+
+func Test_account_withdraw(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		account  *account
+		arg      float64
+		want     error
+		wantCash float64
+	}{
+		{name: "0以下ならエラー", account: &account{Cash: 1000}, arg: -1, want: InvalidAmountError, wantCash: 1000},
+		{name: "拘束されていない預り金が足りなければエラー",
+			account:  &account{Cash: 1000, reservations: map[string]*cashReservation{"sor-1": {price: 5, quantity: 100}}},
+			arg:      600,
+			want:     NotEnoughCashError,
+			wantCash: 1000},
+		{name: "預り金から減算する",
+			account:  &account{Cash: 1000, reservations: map[string]*cashReservation{"sor-1": {price: 5, quantity: 100}}},
+			arg:      500,
+			want:     nil,
+			wantCash: 500},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.account.withdraw(test.arg)
+			if !errors.Is(got, test.want) || !reflect.DeepEqual(test.wantCash, test.account.Cash) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want, test.wantCash, got, test.account.Cash)
+			}
+		})
+	}
+}
+
+func Test_account_reserve_release(t *testing.T) {
+	t.Parallel()
+	a := &account{Cash: 100_000}
+	a.reserve("sor-1", 100, 300)
+	a.reserve("sor-2", 200, 100)
+	got1 := a.buyingPower()
+
+	a.release("sor-1", 100)
+	got2 := a.buyingPower()
+
+	a.release("sor-1", 200)
+	a.releaseAll("sor-2")
+	got3 := a.buyingPower()
+
+	if !reflect.DeepEqual(float64(50_000), got1) || !reflect.DeepEqual(float64(60_000), got2) || !reflect.DeepEqual(float64(100_000), got3) || len(a.reservations) != 0 {
+		t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v\n", t.Name(), 50_000, 60_000, 100_000, 0, got1, got2, got3, len(a.reservations))
+	}
+}
+
+func Test_account_reservable(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		account *account
+		price   float64
+		qty     float64
+		want    error
+	}{
+		{name: "余力が足りればnil", account: &account{Cash: 100_000}, price: 1000, qty: 100, want: nil},
+		{name: "余力が足りなければエラー", account: &account{Cash: 100_000, reservations: map[string]*cashReservation{"sor-1": {price: 1, quantity: 1}}}, price: 1000, qty: 100, want: NotEnoughBuyingPowerError},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.account.reservable(test.price, test.qty)
+			if !errors.Is(got, test.want) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_account_rereservable(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		account *account
+		price   float64
+		qty     float64
+		want    error
+	}{
+		{name: "拘束していた金額を戻せば余力が足りるならnil", account: &account{Cash: 100_000, reservations: map[string]*cashReservation{"sor-1": {price: 900, quantity: 100}}}, price: 1000, qty: 100, want: nil},
+		{name: "拘束していた金額を戻しても余力が足りなければエラー", account: &account{Cash: 100_000, reservations: map[string]*cashReservation{"sor-1": {price: 900, quantity: 100}, "sor-2": {price: 1, quantity: 1}}}, price: 1000, qty: 100, want: NotEnoughBuyingPowerError},
+		{name: "拘束していなければ余力だけで判断する", account: &account{Cash: 99_999}, price: 1000, qty: 100, want: NotEnoughBuyingPowerError},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.account.rereservable("sor-1", test.price, test.qty)
+			if !errors.Is(got, test.want) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_account_marginRereservable(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		account *account
+		price   float64
+		qty     float64
+		want    error
+	}{
+		{name: "登録していた建玉予定金額を戻せば保証金が足りるならnil", account: &account{Cash: 30_000, InitialMarginRate: 0.3, marginReservations: map[string]*cashReservation{"mor-1": {price: 900, quantity: 100}}}, price: 1000, qty: 100, want: nil},
+		{name: "登録していた建玉予定金額を戻しても保証金が足りなければエラー", account: &account{Cash: 29_999, InitialMarginRate: 0.3, marginReservations: map[string]*cashReservation{"mor-1": {price: 900, quantity: 100}}}, price: 1000, qty: 100, want: NotEnoughMarginError},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.account.marginRereservable("mor-1", test.price, test.qty)
+			if !errors.Is(got, test.want) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_account_state_restore(t *testing.T) {
+	t.Parallel()
+	src := &account{
+		Cash:                  1_000_000,
+		InitialMarginRate:     0.3,
+		MaintenanceMarginRate: 0.2,
+		MarginPositionAmount:  300_000,
+		MarginProfit:          -10_000,
+		IsMarginCall:          true,
+		MarginCalledAt:        time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local),
+		reservations:          map[string]*cashReservation{"sor-1": {price: 1000, quantity: 100}},
+		marginReservations:    map[string]*cashReservation{"mor-1": {price: 900, quantity: 200}},
+	}
+	wantState := &accountState{
+		Cash:                  1_000_000,
+		InitialMarginRate:     0.3,
+		MaintenanceMarginRate: 0.2,
+		MarginPositionAmount:  300_000,
+		MarginProfit:          -10_000,
+		IsMarginCall:          true,
+		MarginCalledAt:        time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local),
+		Reservations:          map[string]*reservationState{"sor-1": {Price: 1000, Quantity: 100}},
+		MarginReservations:    map[string]*reservationState{"mor-1": {Price: 900, Quantity: 200}},
+	}
+	gotState := src.state()
+
+	// 書き出した状態に戻すと、今ある拘束金は捨てる
+	got := &account{Cash: 1, reservations: map[string]*cashReservation{"sor-2": {price: 1, quantity: 1}}}
+	got.restore(gotState)
+
+	// nilなら何もしない
+	got.restore(nil)
+
+	if !reflect.DeepEqual(wantState, gotState) || !reflect.DeepEqual(src, got) {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), wantState, src, gotState, got)
+	}
+}
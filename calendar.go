@@ -0,0 +1,102 @@
+package virtual_security
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// holidayLayouts - 休業日の一覧で読み込める日付の形式
+var holidayLayouts = []string{"2006/1/2", "2006-01-02"}
+
+// LoadHolidays - 祝日などの休業日の一覧を読み込む
+//   1行に1日で、カンマ区切りの最初の列の日付を 2006/1/2 か 2006-01-02 の形式で読み込むので、内閣府の祝日のCSVもそのまま読み込める
+//   空行と、日付として読めない1行目(見出し)は読み飛ばす
+func LoadHolidays(r io.Reader) ([]time.Time, error) {
+	holidays := make([]time.Time, 0)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		column := strings.TrimSpace(strings.SplitN(scanner.Text(), ",", 2)[0])
+		if column == "" {
+			continue
+		}
+
+		holiday, ok := parseHoliday(column)
+		if !ok {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line: %d, value: %s: %w", line, column, InvalidHolidayError)
+		}
+		holidays = append(holidays, holiday)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return holidays, nil
+}
+
+func parseHoliday(value string) (time.Time, bool) {
+	for _, layout := range holidayLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func newCalendar(holidays []time.Time) *calendar {
+	c := &calendar{holidays: map[string]struct{}{}}
+	for _, h := range holidays {
+		if !h.IsZero() {
+			c.holidays[h.Format("2006-01-02")] = struct{}{}
+		}
+	}
+	return c
+}
+
+// calendar - 取引所の営業日のカレンダー
+//   土日と年末年始(12/31から1/3)に加えて、登録した祝日などを休業日にする (nilなら土日と年末年始だけを休業日にする)
+type calendar struct {
+	holidays map[string]struct{} // 登録した休業日 (2006-01-02の形式)
+}
+
+// isBusinessDay - 指定した日時の日付が営業日か
+func (c *calendar) isBusinessDay(day time.Time) bool {
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false
+	}
+	if (day.Month() == time.December && day.Day() == 31) || (day.Month() == time.January && day.Day() <= 3) {
+		return false
+	}
+	if c == nil {
+		return true
+	}
+	_, ok := c.holidays[day.Format("2006-01-02")]
+	return !ok
+}
+
+// addBusinessDays - 指定した日付から営業日をdays日進めた営業日
+//   指定した日付が休業日なら次の営業日から数えるので、daysが0なら指定した日付以降で最初の営業日になる
+//   daysが負なら前の営業日に戻す
+func (c *calendar) addBusinessDays(day time.Time, days int) time.Time {
+	d := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	for !c.isBusinessDay(d) {
+		d = d.AddDate(0, 0, 1)
+	}
+	for ; days > 0; days-- {
+		d = d.AddDate(0, 0, 1)
+		for !c.isBusinessDay(d) {
+			d = d.AddDate(0, 0, 1)
+		}
+	}
+	for ; days < 0; days++ {
+		d = d.AddDate(0, 0, -1)
+		for !c.isBusinessDay(d) {
+			d = d.AddDate(0, 0, -1)
+		}
+	}
+	return d
+}
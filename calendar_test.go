@@ -0,0 +1,131 @@
+package virtual_security
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_LoadHolidays(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		arg     string
+		want    []time.Time
+		wantErr error
+	}{
+		{name: "空なら空の一覧を返す", arg: "", want: []time.Time{}},
+		{name: "見出しのある内閣府のCSVを読み込める",
+			arg: "国民の祝日・休日月日,国民の祝日・休日名称\n2021/9/20,敬老の日\n2021/9/23,秋分の日\n",
+			want: []time.Time{
+				time.Date(2021, 9, 20, 0, 0, 0, 0, time.Local),
+				time.Date(2021, 9, 23, 0, 0, 0, 0, time.Local),
+			}},
+		{name: "ハイフン区切りの日付と空行を読み込める",
+			arg: "2021-09-20\n\n 2021-09-23 \n",
+			want: []time.Time{
+				time.Date(2021, 9, 20, 0, 0, 0, 0, time.Local),
+				time.Date(2021, 9, 23, 0, 0, 0, 0, time.Local),
+			}},
+		{name: "2行目以降に日付として読めない行があればエラー",
+			arg:     "2021/9/20,敬老の日\nfoo,bar\n",
+			want:    nil,
+			wantErr: InvalidHolidayError},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := LoadHolidays(strings.NewReader(test.arg))
+			if !reflect.DeepEqual(test.want, got) || !errors.Is(err, test.wantErr) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want, test.wantErr, got, err)
+			}
+		})
+	}
+}
+
+func Test_newCalendar(t *testing.T) {
+	t.Parallel()
+	want := &calendar{holidays: map[string]struct{}{"2021-09-20": {}}}
+	got := newCalendar([]time.Time{time.Date(2021, 9, 20, 0, 0, 0, 0, time.Local), {}})
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_calendar_isBusinessDay(t *testing.T) {
+	t.Parallel()
+	holiday := newCalendar([]time.Time{time.Date(2021, 9, 20, 0, 0, 0, 0, time.Local)})
+	tests := []struct {
+		name     string
+		calendar *calendar
+		arg      time.Time
+		want     bool
+	}{
+		{name: "平日なら営業日", calendar: holiday, arg: time.Date(2021, 9, 17, 10, 0, 0, 0, time.Local), want: true},
+		{name: "土曜なら休業日", calendar: holiday, arg: time.Date(2021, 9, 18, 10, 0, 0, 0, time.Local), want: false},
+		{name: "日曜なら休業日", calendar: holiday, arg: time.Date(2021, 9, 19, 10, 0, 0, 0, time.Local), want: false},
+		{name: "登録した祝日なら休業日", calendar: holiday, arg: time.Date(2021, 9, 20, 10, 0, 0, 0, time.Local), want: false},
+		{name: "12/31なら休業日", calendar: holiday, arg: time.Date(2021, 12, 31, 10, 0, 0, 0, time.Local), want: false},
+		{name: "1/3なら休業日", calendar: holiday, arg: time.Date(2022, 1, 3, 10, 0, 0, 0, time.Local), want: false},
+		{name: "1/4の平日なら営業日", calendar: holiday, arg: time.Date(2022, 1, 4, 10, 0, 0, 0, time.Local), want: true},
+		{name: "カレンダーがnilなら祝日も営業日", calendar: nil, arg: time.Date(2021, 9, 20, 10, 0, 0, 0, time.Local), want: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.calendar.isBusinessDay(test.arg)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_calendar_addBusinessDays(t *testing.T) {
+	t.Parallel()
+	holiday := newCalendar([]time.Time{time.Date(2021, 9, 20, 0, 0, 0, 0, time.Local), time.Date(2021, 9, 23, 0, 0, 0, 0, time.Local)})
+	tests := []struct {
+		name string
+		arg1 time.Time
+		arg2 int
+		want time.Time
+	}{
+		{name: "営業日で0日なら同じ日の0時を返す",
+			arg1: time.Date(2021, 9, 17, 10, 0, 0, 0, time.Local),
+			arg2: 0,
+			want: time.Date(2021, 9, 17, 0, 0, 0, 0, time.Local)},
+		{name: "休業日で0日なら次の営業日を返す",
+			arg1: time.Date(2021, 9, 18, 10, 0, 0, 0, time.Local),
+			arg2: 0,
+			want: time.Date(2021, 9, 21, 0, 0, 0, 0, time.Local)},
+		{name: "土日祝日を飛ばして進める",
+			arg1: time.Date(2021, 9, 17, 10, 0, 0, 0, time.Local),
+			arg2: 3,
+			want: time.Date(2021, 9, 24, 0, 0, 0, 0, time.Local)},
+		{name: "負なら土日祝日を飛ばして戻す",
+			arg1: time.Date(2021, 9, 21, 10, 0, 0, 0, time.Local),
+			arg2: -1,
+			want: time.Date(2021, 9, 17, 0, 0, 0, 0, time.Local)},
+		{name: "年末年始を飛ばして進める",
+			arg1: time.Date(2021, 12, 30, 10, 0, 0, 0, time.Local),
+			arg2: 1,
+			want: time.Date(2022, 1, 4, 0, 0, 0, 0, time.Local)},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := holiday.addBusinessDays(test.arg1, test.arg2)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
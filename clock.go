@@ -6,41 +6,58 @@ import (
 
 type iClock interface {
 	now() time.Time
-	getStockSession(now time.Time) Session
 	getSession(exchangeType ExchangeType, now time.Time) Session
 	getBusinessDay(exchangeType ExchangeType, now time.Time) time.Time
+	isBusinessDay(day time.Time) bool
+	addBusinessDays(day time.Time, days int) time.Time
+}
+
+// Clock - 仮想証券会社が現在日時として利用する時計
+type Clock interface {
+	Now() time.Time
 }
 
 func newClock() iClock {
 	return &clock{}
 }
 
-type clock struct{}
+// newClockWithSource - 現在日時を外部から与えられた時計から取得するclockの生成
+func newClockWithSource(source Clock) iClock {
+	return &clock{source: source}
+}
 
-func (c *clock) now() time.Time {
-	return time.Now()
+// newClockWithCalendar - 休業日を指定したカレンダーで営業日を決め、指定した取引時間でセッションを決めるclockの生成 (sourceがnilなら実時間を利用する)
+func newClockWithCalendar(source Clock, calendar *calendar, schedule *tradingSchedule) iClock {
+	return &clock{source: source, calendar: calendar, schedule: schedule}
+}
+
+type clock struct {
+	source   Clock
+	calendar *calendar
+	schedule *tradingSchedule
 }
 
-func (c *clock) getStockSession(now time.Time) Session {
-	switch {
-	case contractableMorningSessionTime.between(now):
-		return SessionMorning
-	case contractableAfternoonSessionTime.between(now):
-		return SessionAfternoon
+func (c *clock) now() time.Time {
+	if c.source != nil {
+		return c.source.Now()
 	}
-	return SessionUnspecified
+	return time.Now()
 }
 
+// getSession - 指定した日時のセッション
+//   休業日にはセッションがなく、日付をまたぐセッションはセッションが始まった日が営業日の場合だけセッションになる
 func (c *clock) getSession(exchangeType ExchangeType, now time.Time) Session {
 	if now.IsZero() {
 		return SessionUnspecified
 	}
 
 	switch exchangeType {
-	case ExchangeTypeStock, ExchangeTypeMargin:
-		return c.getStockSession(now)
-	case ExchangeTypeFuture:
-		// TODO 先物の場合の振り分け
+	case ExchangeTypeStock, ExchangeTypeMargin, ExchangeTypeFuture:
+		p := c.schedule.sessionAt(exchangeType, now)
+		if p == nil || !c.isBusinessDay(p.day) {
+			return SessionUnspecified
+		}
+		return p.session
 	}
 	return SessionUnspecified
 }
@@ -52,9 +69,43 @@ func (c *clock) getBusinessDay(exchangeType ExchangeType, now time.Time) time.Ti
 
 	switch exchangeType {
 	case ExchangeTypeStock, ExchangeTypeMargin:
-		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		return c.addBusinessDays(now, 0)
 	case ExchangeTypeFuture:
-		// TODO 先物の場合の振り分け
+		return c.getFutureBusinessDay(now)
 	}
 	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 }
+
+// getFutureBusinessDay - 先物の営業日
+//   夜間の開始から翌営業日の取引になり、休業日は翌営業日に繰り越す
+func (c *clock) getFutureBusinessDay(now time.Time) time.Time {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if p := c.schedule.sessionAt(ExchangeTypeFuture, now); p != nil && p.session == SessionNight && p.day.Equal(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+	return c.addBusinessDays(day, 0)
+}
+
+// isBusinessDay - 指定した日時の日付が営業日か
+func (c *clock) isBusinessDay(day time.Time) bool {
+	return c.calendar.isBusinessDay(day)
+}
+
+// addBusinessDays - 指定した日付から営業日をdays日進めた営業日 (休業日なら次の営業日から数える)
+func (c *clock) addBusinessDays(day time.Time, days int) time.Time {
+	return c.calendar.addBusinessDays(day, days)
+}
+
+// orderExpiredAt - 注文の有効期限
+//   有効期限の指定があればその日付にし、なければ注文した営業日から有効期限の営業日数だけ有効にする (営業日数の指定もなければ注文した営業日だけ)
+func orderExpiredAt(clock iClock, exchangeType ExchangeType, expiredAt time.Time, expireBusinessDays int, now time.Time) time.Time {
+	if !expiredAt.IsZero() {
+		return time.Date(expiredAt.Year(), expiredAt.Month(), expiredAt.Day(), 0, 0, 0, 0, time.Local)
+	}
+
+	businessDay := clock.getBusinessDay(exchangeType, now)
+	if expireBusinessDays > 1 {
+		return clock.addBusinessDays(businessDay, expireBusinessDays-1)
+	}
+	return businessDay
+}
@@ -9,19 +9,21 @@ import (
 type testClock struct {
 	iClock
 	now1                   time.Time
-	getStockSession1       Session
-	getStockSessionHistory []time.Time
 	getSession1            Session
 	getBusinessDay1        time.Time
+	isBusinessDay1         bool
+	addBusinessDays1       time.Time
+	addBusinessDaysHistory []int
 }
 
-func (t *testClock) now() time.Time { return t.now1 }
-func (t *testClock) getStockSession(now time.Time) Session {
-	t.getStockSessionHistory = append(t.getStockSessionHistory, now)
-	return t.getStockSession1
-}
+func (t *testClock) now() time.Time                                   { return t.now1 }
 func (t *testClock) getSession(ExchangeType, time.Time) Session       { return t.getSession1 }
 func (t *testClock) getBusinessDay(ExchangeType, time.Time) time.Time { return t.getBusinessDay1 }
+func (t *testClock) isBusinessDay(time.Time) bool                     { return t.isBusinessDay1 }
+func (t *testClock) addBusinessDays(_ time.Time, days int) time.Time {
+	t.addBusinessDaysHistory = append(t.addBusinessDaysHistory, days)
+	return t.addBusinessDays1
+}
 
 func Test_newClock(t *testing.T) {
 	want := &clock{}
@@ -32,6 +34,31 @@ func Test_newClock(t *testing.T) {
 	}
 }
 
+type testSourceClock struct {
+	now1 time.Time
+}
+
+func (t *testSourceClock) Now() time.Time { return t.now1 }
+
+func Test_newClockWithSource(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 8, 24, 10, 0, 0, 0, time.Local)}
+	want := &clock{source: source}
+	got := newClockWithSource(source)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_clock_Now_WithSource(t *testing.T) {
+	t.Parallel()
+	want := time.Date(2021, 8, 24, 10, 0, 0, 0, time.Local)
+	got := (&clock{source: &testSourceClock{now1: want}}).now()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
 func Test_clock_Now1(t *testing.T) {
 	want := time.Now()
 	got := (&clock{}).now()
@@ -52,46 +79,14 @@ func Test_clock_Now2(t *testing.T) {
 	}
 }
 
-func Test_clock_GetStockSession(t *testing.T) {
-	t.Parallel()
-	tests := []struct {
-		name string
-		arg  time.Time
-		want Session
-	}{
-		{name: "前場前 なら unspecified", arg: time.Date(0, 1, 1, 8, 59, 59, 0, time.Local), want: SessionUnspecified},
-		{name: "前場開始時刻 なら morning", arg: time.Date(0, 1, 1, 9, 0, 0, 0, time.Local), want: SessionMorning},
-		{name: "前場中 なら morning", arg: time.Date(0, 1, 1, 10, 0, 0, 0, time.Local), want: SessionMorning},
-		{name: "前場終了時刻 なら morning", arg: time.Date(0, 1, 1, 11, 30, 0, 0, time.Local), want: SessionMorning},
-		{name: "前場終了後 なら morning", arg: time.Date(0, 1, 1, 11, 30, 5, 0, time.Local), want: SessionUnspecified},
-		{name: "前場後・後場前 なら unspecified", arg: time.Date(0, 1, 1, 12, 0, 0, 0, time.Local), want: SessionUnspecified},
-		{name: "後場開始時刻 なら afternoon", arg: time.Date(0, 1, 1, 12, 30, 0, 0, time.Local), want: SessionAfternoon},
-		{name: "後場中 なら afternoon", arg: time.Date(0, 1, 1, 13, 0, 0, 0, time.Local), want: SessionAfternoon},
-		{name: "後場終了時刻 なら afternoon", arg: time.Date(0, 1, 1, 15, 0, 0, 0, time.Local), want: SessionAfternoon},
-		{name: "後場終了後 なら unspecified", arg: time.Date(0, 1, 1, 15, 0, 5, 0, time.Local), want: SessionUnspecified},
-		{name: "後場後 なら unspecified", arg: time.Date(0, 1, 1, 15, 0, 6, 0, time.Local), want: SessionUnspecified},
-	}
-
-	for _, test := range tests {
-		test := test
-		t.Run(test.name, func(t *testing.T) {
-			t.Parallel()
-			clock := &clock{}
-			got := clock.getStockSession(test.arg)
-			if !reflect.DeepEqual(test.want, got) {
-				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
-			}
-		})
-	}
-}
-
 func Test_clock_GetBusinessDay(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
-		name string
-		arg1 ExchangeType
-		arg2 time.Time
-		want time.Time
+		name     string
+		holidays []time.Time
+		arg1     ExchangeType
+		arg2     time.Time
+		want     time.Time
 	}{
 		{name: "引数がゼロ値ならそのまま返す", arg1: ExchangeTypeUnspecified, arg2: time.Time{}, want: time.Time{}},
 		{name: "現物なら年月日をそのまま営業日にして返す",
@@ -102,6 +97,44 @@ func Test_clock_GetBusinessDay(t *testing.T) {
 			arg1: ExchangeTypeMargin,
 			arg2: time.Date(2021, 6, 29, 16, 29, 0, 0, time.Local),
 			want: time.Date(2021, 6, 29, 0, 0, 0, 0, time.Local)},
+		{name: "先物の日中なら年月日をそのまま営業日にして返す",
+			arg1: ExchangeTypeFuture,
+			arg2: time.Date(2021, 6, 29, 10, 0, 0, 0, time.Local),
+			want: time.Date(2021, 6, 29, 0, 0, 0, 0, time.Local)},
+		{name: "先物の夜間の17時以降なら翌営業日を返す",
+			arg1: ExchangeTypeFuture,
+			arg2: time.Date(2021, 6, 29, 18, 0, 0, 0, time.Local),
+			want: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local)},
+		{name: "先物の夜間の0時以降なら当日を営業日にして返す",
+			arg1: ExchangeTypeFuture,
+			arg2: time.Date(2021, 6, 30, 3, 0, 0, 0, time.Local),
+			want: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local)},
+		{name: "先物で金曜の夜間なら翌週月曜を営業日にして返す",
+			arg1: ExchangeTypeFuture,
+			arg2: time.Date(2021, 7, 2, 18, 0, 0, 0, time.Local),
+			want: time.Date(2021, 7, 5, 0, 0, 0, 0, time.Local)},
+		{name: "先物で土曜の夜間なら翌週月曜を営業日にして返す",
+			arg1: ExchangeTypeFuture,
+			arg2: time.Date(2021, 7, 3, 3, 0, 0, 0, time.Local),
+			want: time.Date(2021, 7, 5, 0, 0, 0, 0, time.Local)},
+		{name: "現物で土曜なら翌週月曜を営業日にして返す",
+			arg1: ExchangeTypeStock,
+			arg2: time.Date(2021, 7, 3, 10, 0, 0, 0, time.Local),
+			want: time.Date(2021, 7, 5, 0, 0, 0, 0, time.Local)},
+		{name: "現物で祝日なら翌営業日を返す",
+			holidays: []time.Time{time.Date(2021, 9, 20, 0, 0, 0, 0, time.Local)},
+			arg1:     ExchangeTypeStock,
+			arg2:     time.Date(2021, 9, 20, 10, 0, 0, 0, time.Local),
+			want:     time.Date(2021, 9, 21, 0, 0, 0, 0, time.Local)},
+		{name: "先物で祝日の前日の夜間なら祝日の翌営業日を返す",
+			holidays: []time.Time{time.Date(2021, 9, 20, 0, 0, 0, 0, time.Local)},
+			arg1:     ExchangeTypeFuture,
+			arg2:     time.Date(2021, 9, 17, 18, 0, 0, 0, time.Local),
+			want:     time.Date(2021, 9, 21, 0, 0, 0, 0, time.Local)},
+		{name: "現物で年末年始なら年明けの営業日を返す",
+			arg1: ExchangeTypeStock,
+			arg2: time.Date(2021, 12, 31, 10, 0, 0, 0, time.Local),
+			want: time.Date(2022, 1, 4, 0, 0, 0, 0, time.Local)},
 		{name: "上記以外は年月日をそのまま返す",
 			arg1: ExchangeTypeUnspecified,
 			arg2: time.Date(2021, 6, 29, 16, 29, 0, 0, time.Local),
@@ -112,7 +145,7 @@ func Test_clock_GetBusinessDay(t *testing.T) {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			c := &clock{}
+			c := &clock{calendar: newCalendar(test.holidays)}
 			got := c.getBusinessDay(test.arg1, test.arg2)
 			if !reflect.DeepEqual(test.want, got) {
 				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
@@ -123,11 +156,14 @@ func Test_clock_GetBusinessDay(t *testing.T) {
 
 func Test_clock_GetSession(t *testing.T) {
 	t.Parallel()
+	extended := newTradingSchedule(map[ExchangeType]SessionSchedule{ExchangeTypeStock: ExtendedStockSessionSchedule().WithHalfDays(time.Date(2021, 12, 30, 0, 0, 0, 0, time.Local))})
 	tests := []struct {
-		name string
-		arg1 ExchangeType
-		arg2 time.Time
-		want Session
+		name     string
+		schedule *tradingSchedule
+		holidays []time.Time
+		arg1     ExchangeType
+		arg2     time.Time
+		want     Session
 	}{
 		{name: "日時がゼロ値なら未指定",
 			arg1: ExchangeTypeStock,
@@ -161,13 +197,58 @@ func Test_clock_GetSession(t *testing.T) {
 			arg1: ExchangeTypeMargin,
 			arg2: time.Date(2021, 6, 30, 12, 0, 0, 0, time.Local),
 			want: SessionUnspecified},
+		{name: "先物で日中の時間なら日中",
+			arg1: ExchangeTypeFuture,
+			arg2: time.Date(2021, 6, 30, 10, 0, 0, 0, time.Local),
+			want: SessionDay},
+		{name: "先物で夜間の時間なら夜間",
+			arg1: ExchangeTypeFuture,
+			arg2: time.Date(2021, 6, 30, 2, 0, 0, 0, time.Local),
+			want: SessionNight},
+		{name: "先物で上記以外の時間なら未指定",
+			arg1: ExchangeTypeFuture,
+			arg2: time.Date(2021, 6, 30, 16, 0, 0, 0, time.Local),
+			want: SessionUnspecified},
+		{name: "現物で土曜なら前場の時間でも未指定",
+			arg1: ExchangeTypeStock,
+			arg2: time.Date(2021, 7, 3, 10, 0, 0, 0, time.Local),
+			want: SessionUnspecified},
+		{name: "信用で祝日なら後場の時間でも未指定",
+			holidays: []time.Time{time.Date(2021, 9, 20, 0, 0, 0, 0, time.Local)},
+			arg1:     ExchangeTypeMargin,
+			arg2:     time.Date(2021, 9, 20, 13, 0, 0, 0, time.Local),
+			want:     SessionUnspecified},
+		{name: "先物で金曜から始まった土曜の夜間なら夜間",
+			arg1: ExchangeTypeFuture,
+			arg2: time.Date(2021, 7, 3, 2, 0, 0, 0, time.Local),
+			want: SessionNight},
+		{name: "先物で祝日に始まる夜間なら未指定",
+			holidays: []time.Time{time.Date(2021, 9, 20, 0, 0, 0, 0, time.Local)},
+			arg1:     ExchangeTypeFuture,
+			arg2:     time.Date(2021, 9, 20, 18, 0, 0, 0, time.Local),
+			want:     SessionUnspecified},
+		{name: "先物で祝日の前日から始まった夜間なら夜間",
+			holidays: []time.Time{time.Date(2021, 9, 20, 0, 0, 0, 0, time.Local)},
+			arg1:     ExchangeTypeFuture,
+			arg2:     time.Date(2021, 9, 18, 2, 0, 0, 0, time.Local),
+			want:     SessionNight},
+		{name: "延長した取引時間なら15:10も後場",
+			schedule: extended,
+			arg1:     ExchangeTypeStock,
+			arg2:     time.Date(2021, 6, 30, 15, 10, 0, 0, time.Local),
+			want:     SessionAfternoon},
+		{name: "半日立会の日の後場の時間なら未指定",
+			schedule: extended,
+			arg1:     ExchangeTypeStock,
+			arg2:     time.Date(2021, 12, 30, 13, 0, 0, 0, time.Local),
+			want:     SessionUnspecified},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			clock := &clock{}
+			clock := &clock{calendar: newCalendar(test.holidays), schedule: test.schedule}
 			got := clock.getSession(test.arg1, test.arg2)
 			if !reflect.DeepEqual(test.want, got) {
 				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
@@ -175,3 +256,55 @@ func Test_clock_GetSession(t *testing.T) {
 		})
 	}
 }
+
+func Test_newClockWithCalendar(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 8, 24, 10, 0, 0, 0, time.Local)}
+	calendar := newCalendar([]time.Time{time.Date(2021, 9, 20, 0, 0, 0, 0, time.Local)})
+	schedule := newTradingSchedule(map[ExchangeType]SessionSchedule{ExchangeTypeStock: ExtendedStockSessionSchedule()})
+	want := &clock{source: source, calendar: calendar, schedule: schedule}
+	got := newClockWithCalendar(source, calendar, schedule)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_orderExpiredAt(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name                       string
+		clock                      *testClock
+		expiredAt                  time.Time
+		expireBusinessDays         int
+		want                       time.Time
+		wantAddBusinessDaysHistory []int
+	}{
+		{name: "有効期限の指定があればその年月日を返す",
+			clock:     &testClock{getBusinessDay1: time.Date(2021, 9, 17, 0, 0, 0, 0, time.Local)},
+			expiredAt: time.Date(2021, 9, 22, 10, 0, 0, 0, time.Local),
+			want:      time.Date(2021, 9, 22, 0, 0, 0, 0, time.Local)},
+		{name: "有効期限も営業日数も指定がなければ注文した営業日を返す",
+			clock: &testClock{getBusinessDay1: time.Date(2021, 9, 17, 0, 0, 0, 0, time.Local)},
+			want:  time.Date(2021, 9, 17, 0, 0, 0, 0, time.Local)},
+		{name: "営業日数が1なら注文した営業日を返す",
+			clock:              &testClock{getBusinessDay1: time.Date(2021, 9, 17, 0, 0, 0, 0, time.Local)},
+			expireBusinessDays: 1,
+			want:               time.Date(2021, 9, 17, 0, 0, 0, 0, time.Local)},
+		{name: "営業日数が2以上なら注文した営業日から営業日数-1日進めた営業日を返す",
+			clock:                      &testClock{getBusinessDay1: time.Date(2021, 9, 17, 0, 0, 0, 0, time.Local), addBusinessDays1: time.Date(2021, 9, 22, 0, 0, 0, 0, time.Local)},
+			expireBusinessDays:         3,
+			want:                       time.Date(2021, 9, 22, 0, 0, 0, 0, time.Local),
+			wantAddBusinessDaysHistory: []int{2}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := orderExpiredAt(test.clock, ExchangeTypeStock, test.expiredAt, test.expireBusinessDays, time.Date(2021, 9, 17, 10, 0, 0, 0, time.Local))
+			if !reflect.DeepEqual(test.want, got) || !reflect.DeepEqual(test.wantAddBusinessDaysHistory, test.clock.addBusinessDaysHistory) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want, test.wantAddBusinessDaysHistory, got, test.clock.addBusinessDaysHistory)
+			}
+		})
+	}
+}
@@ -0,0 +1,650 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	vs "gitlab.com/tsuchinaga/kabus-virtual-security"
+)
+
+// kabuステーションAPIのエラーコード
+const (
+	errorCodeInternal         = 4001001 // 内部エラー
+	errorCodeInvalidRequest   = 4001005 // パラメータ変換エラー
+	errorCodeInvalidParameter = 4001006 // 引数エラー
+	errorCodeInvalidAPIKey    = 4001009 // APIキー不一致
+	errorCodeInvalidPassword  = 4001013 // APIパスワード不一致
+	errorCodeOrder            = 4002001 // 発注や取消のエラー
+)
+
+// kabuステーションAPIの区分値
+const (
+	sideSell = "1" // 売
+	sideBuy  = "2" // 買
+
+	cashMarginStock       = 1 // 現物
+	cashMarginMarginEntry = 2 // 信用新規
+	cashMarginMarginExit  = 3 // 信用返済
+
+	tradeTypeEntry = 1 // 先物新規
+	tradeTypeExit  = 2 // 先物返済
+
+	timeInForceFAS = 1 // FAS
+
+	underOverUnder = 1 // 以下
+	underOverOver  = 2 // 以上
+
+	afterHitOrderTypeMO     = 1 // 成行
+	afterHitOrderTypeLO     = 2 // 指値
+	afterHitOrderTypeFunari = 3 // 不成
+
+	productAll    = 0 // すべて
+	productStock  = 1 // 現物
+	productMargin = 2 // 信用
+	productFuture = 3 // 先物
+
+	exchangeStock  = 1 // 東証
+	exchangeFuture = 2 // 日通し
+
+	securityTypeStock = 1 // 株式
+
+	accountTypeSpecific = 4 // 特定
+
+	marginTradeTypeSystem = 1 // 制度信用
+
+	stateWait       = 1 // 待機
+	stateProcessed  = 3 // 処理済
+	stateCanceling  = 4 // 訂正取消送信中
+	stateTerminated = 5 // 終了
+
+	ordTypeRegular = 1 // ザラバ
+
+	recTypeReceived   = 1 // 受付
+	recTypeCanceled   = 6 // 取消
+	recTypeContracted = 8 // 約定
+)
+
+var (
+	// 株式の執行条件
+	stockFrontOrderTypes = map[int]vs.StockExecutionCondition{
+		10: vs.StockExecutionConditionMO,
+		13: vs.StockExecutionConditionMOMO,
+		14: vs.StockExecutionConditionMOAO,
+		15: vs.StockExecutionConditionMOMC,
+		16: vs.StockExecutionConditionMOAC,
+		17: vs.StockExecutionConditionIOCMO,
+		20: vs.StockExecutionConditionLO,
+		21: vs.StockExecutionConditionLOMO,
+		22: vs.StockExecutionConditionLOAO,
+		23: vs.StockExecutionConditionLOMC,
+		24: vs.StockExecutionConditionLOAC,
+		25: vs.StockExecutionConditionFunariM,
+		26: vs.StockExecutionConditionFunariA,
+		27: vs.StockExecutionConditionIOCLO,
+		30: vs.StockExecutionConditionStop,
+	}
+
+	// 先物の執行条件
+	futureFrontOrderTypes = map[int]vs.FutureExecutionCondition{
+		120: vs.FutureExecutionConditionMO,
+		18:  vs.FutureExecutionConditionMOC,
+		20:  vs.FutureExecutionConditionLO,
+		28:  vs.FutureExecutionConditionLOC,
+		30:  vs.FutureExecutionConditionStop,
+	}
+
+	// 返済順序
+	//   仮想証券会社は約定日時の順でしか割り当てられないので、日付を優先する順序だけを受け付ける
+	closePositionOrders = map[int]vs.ExitPositionOrder{
+		0: vs.ExitPositionOrderFIFO,
+		1: vs.ExitPositionOrderFIFO,
+		2: vs.ExitPositionOrderLIFO,
+		3: vs.ExitPositionOrderLIFO,
+	}
+)
+
+// tokenRequest - トークン発行のリクエスト
+type tokenRequest struct {
+	APIPassword string `json:"APIPassword"`
+}
+
+// tokenResponse - トークン発行のレスポンス
+type tokenResponse struct {
+	ResultCode int    `json:"ResultCode"`
+	Token      string `json:"Token"`
+}
+
+// errorResponse - エラーのレスポンス
+type errorResponse struct {
+	Code    int    `json:"Code"`
+	Message string `json:"Message"`
+}
+
+// closePosition - 返済する建玉の指定
+type closePosition struct {
+	HoldID string  `json:"HoldID"`
+	Qty    float64 `json:"Qty"`
+}
+
+// reverseLimitOrder - 逆指値条件
+type reverseLimitOrder struct {
+	TriggerSec        int     `json:"TriggerSec"`
+	TriggerPrice      float64 `json:"TriggerPrice"`
+	UnderOver         int     `json:"UnderOver"`
+	AfterHitOrderType int     `json:"AfterHitOrderType"`
+	AfterHitPrice     float64 `json:"AfterHitPrice"`
+}
+
+// sendOrderRequest - 株式の注文発注のリクエスト
+type sendOrderRequest struct {
+	Password           string             `json:"Password"`
+	Symbol             string             `json:"Symbol"`
+	Exchange           int                `json:"Exchange"`
+	SecurityType       int                `json:"SecurityType"`
+	Side               string             `json:"Side"`
+	CashMargin         int                `json:"CashMargin"`
+	MarginTradeType    int                `json:"MarginTradeType"`
+	DelivType          int                `json:"DelivType"`
+	FundType           string             `json:"FundType"`
+	AccountType        int                `json:"AccountType"`
+	Qty                float64            `json:"Qty"`
+	ClosePositionOrder *int               `json:"ClosePositionOrder"`
+	ClosePositions     []closePosition    `json:"ClosePositions"`
+	Price              float64            `json:"Price"`
+	ExpireDay          int                `json:"ExpireDay"`
+	FrontOrderType     int                `json:"FrontOrderType"`
+	ReverseLimitOrder  *reverseLimitOrder `json:"ReverseLimitOrder"`
+}
+
+// sendOrderFutureRequest - 先物の注文発注のリクエスト
+type sendOrderFutureRequest struct {
+	Password           string             `json:"Password"`
+	Symbol             string             `json:"Symbol"`
+	Exchange           int                `json:"Exchange"`
+	TradeType          int                `json:"TradeType"`
+	TimeInForce        int                `json:"TimeInForce"`
+	Side               string             `json:"Side"`
+	Qty                float64            `json:"Qty"`
+	ClosePositionOrder *int               `json:"ClosePositionOrder"`
+	ClosePositions     []closePosition    `json:"ClosePositions"`
+	FrontOrderType     int                `json:"FrontOrderType"`
+	Price              float64            `json:"Price"`
+	ExpireDay          int                `json:"ExpireDay"`
+	ReverseLimitOrder  *reverseLimitOrder `json:"ReverseLimitOrder"`
+}
+
+// sendOrderResponse - 注文発注のレスポンス
+type sendOrderResponse struct {
+	Result  int    `json:"Result"`
+	OrderID string `json:"OrderId"`
+}
+
+// cancelOrderRequest - 注文取消のリクエスト
+type cancelOrderRequest struct {
+	OrderID  string `json:"OrderId"`
+	Password string `json:"Password"`
+}
+
+// order - 注文約定照会の注文
+type order struct {
+	ID              string         `json:"ID"`
+	State           int            `json:"State"`
+	OrderState      int            `json:"OrderState"`
+	OrdType         int            `json:"OrdType"`
+	RecvTime        time.Time      `json:"RecvTime"`
+	Symbol          string         `json:"Symbol"`
+	SymbolName      string         `json:"SymbolName"`
+	Exchange        int            `json:"Exchange"`
+	ExchangeName    string         `json:"ExchangeName"`
+	TimeInForce     int            `json:"TimeInForce,omitempty"`
+	Price           float64        `json:"Price"`
+	OrderQty        float64        `json:"OrderQty"`
+	CumQty          float64        `json:"CumQty"`
+	Side            string         `json:"Side"`
+	CashMargin      int            `json:"CashMargin"`
+	AccountType     int            `json:"AccountType"`
+	DelivType       int            `json:"DelivType"`
+	ExpireDay       int            `json:"ExpireDay"`
+	MarginTradeType int            `json:"MarginTradeType,omitempty"`
+	Details         []*orderDetail `json:"Details"`
+}
+
+// orderDetail - 注文約定照会の注文の明細
+type orderDetail struct {
+	SeqNum       int        `json:"SeqNum"`
+	ID           string     `json:"ID"`
+	RecType      int        `json:"RecType"`
+	State        int        `json:"State"`
+	TransactTime time.Time  `json:"TransactTime"`
+	OrdType      int        `json:"OrdType"`
+	Price        float64    `json:"Price"`
+	Qty          float64    `json:"Qty"`
+	ExecutionID  string     `json:"ExecutionID,omitempty"`
+	ExecutionDay *time.Time `json:"ExecutionDay,omitempty"`
+}
+
+// position - 残高照会の建玉
+type position struct {
+	ExecutionID     string  `json:"ExecutionID"`
+	AccountType     int     `json:"AccountType"`
+	Symbol          string  `json:"Symbol"`
+	SymbolName      string  `json:"SymbolName"`
+	Exchange        int     `json:"Exchange"`
+	ExchangeName    string  `json:"ExchangeName"`
+	SecurityType    int     `json:"SecurityType,omitempty"`
+	ExecutionDay    int     `json:"ExecutionDay"`
+	Price           float64 `json:"Price"`
+	LeavesQty       float64 `json:"LeavesQty"`
+	HoldQty         float64 `json:"HoldQty"`
+	Side            string  `json:"Side"`
+	MarginTradeType int     `json:"MarginTradeType,omitempty"`
+	CurrentPrice    float64 `json:"CurrentPrice"`
+	Valuation       float64 `json:"Valuation"`
+	ProfitLoss      float64 `json:"ProfitLoss"`
+	ProfitLossRate  float64 `json:"ProfitLossRate"`
+}
+
+// boardQuote - 時価情報・板情報の気配
+type boardQuote struct {
+	Price float64 `json:"Price"`
+	Qty   float64 `json:"Qty"`
+}
+
+// board - 時価情報・板情報
+//   kabuステーションAPIでは、BidPriceが売気配、AskPriceが買気配を表す
+type board struct {
+	Symbol           string      `json:"Symbol"`
+	SymbolName       string      `json:"SymbolName"`
+	Exchange         int         `json:"Exchange"`
+	ExchangeName     string      `json:"ExchangeName"`
+	CurrentPrice     float64     `json:"CurrentPrice"`
+	CurrentPriceTime *time.Time  `json:"CurrentPriceTime"`
+	BidQty           float64     `json:"BidQty"`
+	BidPrice         float64     `json:"BidPrice"`
+	BidTime          *time.Time  `json:"BidTime"`
+	Sell1            *boardQuote `json:"Sell1"`
+	Sell2            *boardQuote `json:"Sell2"`
+	Sell3            *boardQuote `json:"Sell3"`
+	Sell4            *boardQuote `json:"Sell4"`
+	Sell5            *boardQuote `json:"Sell5"`
+	Sell6            *boardQuote `json:"Sell6"`
+	Sell7            *boardQuote `json:"Sell7"`
+	Sell8            *boardQuote `json:"Sell8"`
+	Sell9            *boardQuote `json:"Sell9"`
+	Sell10           *boardQuote `json:"Sell10"`
+	AskQty           float64     `json:"AskQty"`
+	AskPrice         float64     `json:"AskPrice"`
+	AskTime          *time.Time  `json:"AskTime"`
+	Buy1             *boardQuote `json:"Buy1"`
+	Buy2             *boardQuote `json:"Buy2"`
+	Buy3             *boardQuote `json:"Buy3"`
+	Buy4             *boardQuote `json:"Buy4"`
+	Buy5             *boardQuote `json:"Buy5"`
+	Buy6             *boardQuote `json:"Buy6"`
+	Buy7             *boardQuote `json:"Buy7"`
+	Buy8             *boardQuote `json:"Buy8"`
+	Buy9             *boardQuote `json:"Buy9"`
+	Buy10            *boardQuote `json:"Buy10"`
+}
+
+// registerSymbol - PUSH配信の登録銘柄
+type registerSymbol struct {
+	Symbol   string `json:"Symbol"`
+	Exchange int    `json:"Exchange"`
+}
+
+// registerRequest - 銘柄登録や銘柄登録解除のリクエスト
+type registerRequest struct {
+	Symbols []*registerSymbol `json:"Symbols"`
+}
+
+// registerResponse - 銘柄登録や銘柄登録解除のレスポンス
+type registerResponse struct {
+	RegistList []*registerSymbol `json:"RegistList"`
+}
+
+// toSide - 売買区分を仮想証券会社の売買方向に変換する
+func toSide(side string) (vs.Side, error) {
+	switch side {
+	case sideSell:
+		return vs.SideSell, nil
+	case sideBuy:
+		return vs.SideBuy, nil
+	}
+	return vs.SideUnspecified, fmt.Errorf("invalid side(%s)", side)
+}
+
+// fromSide - 仮想証券会社の売買方向を売買区分に変換する
+func fromSide(side vs.Side) string {
+	if side == vs.SideSell {
+		return sideSell
+	}
+	return sideBuy
+}
+
+// toExpiredAt - yyyyMMddの注文有効期限を日時に変換する
+//   0なら当日を表すので、ゼロ値にして仮想証券会社に任せる
+func toExpiredAt(expireDay int) time.Time {
+	if expireDay == 0 {
+		return time.Time{}
+	}
+	return time.Date(expireDay/10000, time.Month(expireDay/100%100), expireDay%100, 0, 0, 0, 0, time.Local)
+}
+
+// toYYYYMMDD - 日時をyyyyMMddの数値に変換する
+func toYYYYMMDD(t time.Time) int {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Year()*10000 + int(t.Month())*100 + t.Day()
+}
+
+// toComparisonOperator - 以上・以下を仮想証券会社の比較方法に変換する
+//   仮想証券会社は「逆指値価格 比較方法 現在値」で判定するので、現在値が以下なら以上(GE)になる
+func toComparisonOperator(underOver int) (vs.ComparisonOperator, error) {
+	switch underOver {
+	case underOverUnder:
+		return vs.ComparisonOperatorGE, nil
+	case underOverOver:
+		return vs.ComparisonOperatorLE, nil
+	}
+	return "", fmt.Errorf("invalid under over(%d)", underOver)
+}
+
+// toExitPositionList - 返済する建玉の指定を仮想証券会社のエグジットポジションリストに変換する
+func toExitPositionList(positions []closePosition) []vs.ExitPosition {
+	if len(positions) == 0 {
+		return nil
+	}
+	list := make([]vs.ExitPosition, len(positions))
+	for i, p := range positions {
+		list[i] = vs.ExitPosition{PositionCode: p.HoldID, Quantity: p.Qty}
+	}
+	return list
+}
+
+// toMarginExitPositionList - 返済順序の指定から、返済する信用建玉を選んでエグジットポジションリストにする
+//   注文と同じ銘柄で売買方向が反対の建玉を、返済順序の順に返済できる数量だけ割り当てる
+//   建玉が足りなければ割り当てられた分だけを返し、注文数量との不一致は仮想証券会社のチェックでエラーにする
+func toMarginExitPositionList(positions []*vs.MarginPosition, symbolCode string, side vs.Side, quantity float64, exitPositionOrder vs.ExitPositionOrder) []vs.ExitPosition {
+	targets := make([]*vs.MarginPosition, 0)
+	for _, p := range positions {
+		if p.SymbolCode == symbolCode && p.Side != side && p.OwnedQuantity-p.HoldQuantity > 0 {
+			targets = append(targets, p)
+		}
+	}
+	sort.SliceStable(targets, func(i, j int) bool {
+		if exitPositionOrder == vs.ExitPositionOrderLIFO {
+			return targets[i].ContractedAt.After(targets[j].ContractedAt)
+		}
+		return targets[i].ContractedAt.Before(targets[j].ContractedAt)
+	})
+
+	list := make([]vs.ExitPosition, 0)
+	for _, p := range targets {
+		if quantity <= 0 {
+			break
+		}
+		q := p.OwnedQuantity - p.HoldQuantity
+		if q > quantity {
+			q = quantity
+		}
+		list = append(list, vs.ExitPosition{PositionCode: p.Code, Quantity: q})
+		quantity -= q
+	}
+	return list
+}
+
+// toStockStopCondition - 株式の逆指値条件を仮想証券会社の逆指値条件に変換する
+func toStockStopCondition(r *reverseLimitOrder) (*vs.StockStopCondition, error) {
+	if r == nil {
+		return nil, nil
+	}
+
+	operator, err := toComparisonOperator(r.UnderOver)
+	if err != nil {
+		return nil, err
+	}
+
+	var executionCondition vs.StockExecutionCondition
+	switch r.AfterHitOrderType {
+	case afterHitOrderTypeMO:
+		executionCondition = vs.StockExecutionConditionMO
+	case afterHitOrderTypeLO:
+		executionCondition = vs.StockExecutionConditionLO
+	case afterHitOrderTypeFunari:
+		executionCondition = vs.StockExecutionConditionFunariA
+	default:
+		return nil, fmt.Errorf("invalid after hit order type(%d)", r.AfterHitOrderType)
+	}
+
+	return &vs.StockStopCondition{
+		StopPrice:                  r.TriggerPrice,
+		ComparisonOperator:         operator,
+		ExecutionConditionAfterHit: executionCondition,
+		LimitPriceAfterHit:         r.AfterHitPrice,
+	}, nil
+}
+
+// toFutureStopCondition - 先物の逆指値条件を仮想証券会社の逆指値条件に変換する
+func toFutureStopCondition(r *reverseLimitOrder) (*vs.FutureStopCondition, error) {
+	if r == nil {
+		return nil, nil
+	}
+
+	operator, err := toComparisonOperator(r.UnderOver)
+	if err != nil {
+		return nil, err
+	}
+
+	var executionCondition vs.FutureExecutionCondition
+	switch r.AfterHitOrderType {
+	case afterHitOrderTypeMO:
+		executionCondition = vs.FutureExecutionConditionMO
+	case afterHitOrderTypeLO:
+		executionCondition = vs.FutureExecutionConditionLO
+	default:
+		return nil, fmt.Errorf("invalid after hit order type(%d)", r.AfterHitOrderType)
+	}
+
+	return &vs.FutureStopCondition{
+		StopPrice:                  r.TriggerPrice,
+		ComparisonOperator:         operator,
+		ExecutionConditionAfterHit: executionCondition,
+		LimitPriceAfterHit:         r.AfterHitPrice,
+	}, nil
+}
+
+// toState - 仮想証券会社の注文の状態を注文約定照会の状態に変換する
+func toState(status vs.OrderStatus) int {
+	switch status {
+	case vs.OrderStatusInOrder, vs.OrderStatusPart:
+		return stateProcessed
+	case vs.OrderStatusInCancel:
+		return stateCanceling
+	case vs.OrderStatusDone, vs.OrderStatusCanceled:
+		return stateTerminated
+	}
+	return stateWait
+}
+
+// toOrderDetails - 注文の受付、約定、取消を注文約定照会の明細にする
+func toOrderDetails(code string, status vs.OrderStatus, limitPrice float64, orderQuantity float64, canceledQuantity float64, orderedAt time.Time, canceledAt time.Time, contracts []*vs.Contract) []*orderDetail {
+	details := []*orderDetail{{
+		RecType:      recTypeReceived,
+		State:        stateProcessed,
+		TransactTime: orderedAt,
+		OrdType:      ordTypeRegular,
+		Price:        limitPrice,
+		Qty:          orderQuantity,
+	}}
+	for _, c := range contracts {
+		details = append(details, &orderDetail{
+			RecType:      recTypeContracted,
+			State:        stateProcessed,
+			TransactTime: c.ContractedAt,
+			OrdType:      ordTypeRegular,
+			Price:        c.Price,
+			Qty:          c.Quantity,
+			ExecutionID:  c.ContractCode,
+			ExecutionDay: timePointer(c.ContractedAt),
+		})
+	}
+	if status == vs.OrderStatusCanceled {
+		details = append(details, &orderDetail{
+			RecType:      recTypeCanceled,
+			State:        stateProcessed,
+			TransactTime: canceledAt,
+			OrdType:      ordTypeRegular,
+			Qty:          canceledQuantity,
+		})
+	}
+
+	for i, d := range details {
+		d.SeqNum = i + 1
+		d.ID = fmt.Sprintf("%s-%d", code, i+1)
+	}
+	return details
+}
+
+// fromStockOrder - 現物注文を注文約定照会の注文に変換する
+func fromStockOrder(o *vs.StockOrder) *order {
+	return &order{
+		ID:          o.Code,
+		State:       toState(o.OrderStatus),
+		OrderState:  toState(o.OrderStatus),
+		OrdType:     ordTypeRegular,
+		RecvTime:    o.OrderedAt,
+		Symbol:      o.SymbolCode,
+		Exchange:    exchangeStock,
+		Price:       o.LimitPrice,
+		OrderQty:    o.OrderQuantity,
+		CumQty:      o.ContractedQuantity,
+		Side:        fromSide(o.Side),
+		CashMargin:  cashMarginStock,
+		AccountType: accountTypeSpecific,
+		ExpireDay:   toYYYYMMDD(o.ExpiredAt),
+		Details:     toOrderDetails(o.Code, o.OrderStatus, o.LimitPrice, o.OrderQuantity, o.CanceledQuantity, o.OrderedAt, o.CanceledAt, o.Contracts),
+	}
+}
+
+// fromMarginOrder - 信用注文を注文約定照会の注文に変換する
+func fromMarginOrder(o *vs.MarginOrder) *order {
+	cashMargin := cashMarginMarginEntry
+	if o.TradeType == vs.TradeTypeExit {
+		cashMargin = cashMarginMarginExit
+	}
+	return &order{
+		ID:              o.Code,
+		State:           toState(o.OrderStatus),
+		OrderState:      toState(o.OrderStatus),
+		OrdType:         ordTypeRegular,
+		RecvTime:        o.OrderedAt,
+		Symbol:          o.SymbolCode,
+		Exchange:        exchangeStock,
+		Price:           o.LimitPrice,
+		OrderQty:        o.OrderQuantity,
+		CumQty:          o.ContractedQuantity,
+		Side:            fromSide(o.Side),
+		CashMargin:      cashMargin,
+		AccountType:     accountTypeSpecific,
+		ExpireDay:       toYYYYMMDD(o.ExpiredAt),
+		MarginTradeType: marginTradeTypeSystem,
+		Details:         toOrderDetails(o.Code, o.OrderStatus, o.LimitPrice, o.OrderQuantity, o.CanceledQuantity, o.OrderedAt, o.CanceledAt, o.Contracts),
+	}
+}
+
+// fromFutureOrder - 先物注文を注文約定照会の注文に変換する
+func fromFutureOrder(o *vs.FutureOrder) *order {
+	cashMargin := cashMarginMarginEntry
+	if o.TradeType == vs.TradeTypeExit {
+		cashMargin = cashMarginMarginExit
+	}
+	return &order{
+		ID:          o.Code,
+		State:       toState(o.OrderStatus),
+		OrderState:  toState(o.OrderStatus),
+		OrdType:     ordTypeRegular,
+		RecvTime:    o.OrderedAt,
+		Symbol:      o.SymbolCode,
+		Exchange:    exchangeFuture,
+		TimeInForce: timeInForceFAS,
+		Price:       o.LimitPrice,
+		OrderQty:    o.OrderQuantity,
+		CumQty:      o.ContractedQuantity,
+		Side:        fromSide(o.Side),
+		CashMargin:  cashMargin,
+		AccountType: accountTypeSpecific,
+		ExpireDay:   toYYYYMMDD(o.ExpiredAt),
+		Details:     toOrderDetails(o.Code, o.OrderStatus, o.LimitPrice, o.OrderQuantity, o.CanceledQuantity, o.OrderedAt, o.CanceledAt, o.Contracts),
+	}
+}
+
+// newPosition - 建玉を残高照会の建玉にする
+//   現在値があれば、現在値で評価する
+func newPosition(code string, symbolCode string, exchange int, side vs.Side, price float64, quantity float64, holdQuantity float64, multiplier float64, contractedAt time.Time, currentPrice float64) *position {
+	p := &position{
+		ExecutionID:  code,
+		AccountType:  accountTypeSpecific,
+		Symbol:       symbolCode,
+		Exchange:     exchange,
+		ExecutionDay: toYYYYMMDD(contractedAt),
+		Price:        price,
+		LeavesQty:    quantity,
+		HoldQty:      holdQuantity,
+		Side:         fromSide(side),
+	}
+	if currentPrice > 0 {
+		p.CurrentPrice = currentPrice
+		p.Valuation = currentPrice * quantity * multiplier
+		p.ProfitLoss = (currentPrice - price) * quantity * multiplier
+		if side == vs.SideSell {
+			p.ProfitLoss = -p.ProfitLoss
+		}
+		if price > 0 && quantity > 0 {
+			p.ProfitLossRate = p.ProfitLoss / (price * quantity * multiplier) * 100
+		}
+	}
+	return p
+}
+
+// newBoard - 銘柄の価格を時価情報・板情報にする
+func newBoard(exchange int, price *vs.SymbolPrice) *board {
+	b := &board{
+		Symbol:       price.SymbolCode,
+		Exchange:     exchange,
+		CurrentPrice: price.Price,
+		BidQty:       price.AskQuantity,
+		BidPrice:     price.Ask,
+		AskQty:       price.BidQuantity,
+		AskPrice:     price.Bid,
+	}
+	b.CurrentPriceTime = timePointer(price.PriceTime)
+	b.BidTime = timePointer(price.AskTime)
+	b.AskTime = timePointer(price.BidTime)
+
+	sells := []**boardQuote{&b.Sell1, &b.Sell2, &b.Sell3, &b.Sell4, &b.Sell5, &b.Sell6, &b.Sell7, &b.Sell8, &b.Sell9, &b.Sell10}
+	for i, l := range price.AskBoard {
+		if i >= len(sells) {
+			break
+		}
+		*sells[i] = &boardQuote{Price: l.Price, Qty: l.Quantity}
+	}
+	buys := []**boardQuote{&b.Buy1, &b.Buy2, &b.Buy3, &b.Buy4, &b.Buy5, &b.Buy6, &b.Buy7, &b.Buy8, &b.Buy9, &b.Buy10}
+	for i, l := range price.BidBoard {
+		if i >= len(buys) {
+			break
+		}
+		*buys[i] = &boardQuote{Price: l.Price, Qty: l.Quantity}
+	}
+	return b
+}
+
+// timePointer - ゼロ値ならnullにするためのポインタ
+func timePointer(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,60 @@
+// kabus-virtual-server - kabuステーションAPIを模した仮想証券会社のHTTPサーバ
+//   kabuステーションAPIと同じパスとリクエスト・レスポンスで、仮想証券会社に注文を出せる
+//   銘柄価格は /virtual/price に登録し、先物銘柄は /virtual/futuresymbol に登録する
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	vs "gitlab.com/tsuchinaga/kabus-virtual-security"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:18081", "待ち受けるアドレス")
+	apiPassword := flag.String("password", "", "トークン発行時に確認するAPIパスワード (空なら確認しない)")
+	cash := flag.Float64("cash", 0, "初期の預り金 (0なら買付余力や委託保証金を確認しない)")
+	extendedHours := flag.Bool("extended-hours", false, "現物・信用の取引時間を、東証が2024年11月5日から延長した15:30引けの取引時間にする")
+	holidays := flag.String("holidays", "", "休業日の一覧のCSVファイル (内閣府の祝日のCSVなど、空なら土日と年末年始だけを休業日にする)")
+	flag.Parse()
+
+	var options []vs.Option
+	if *cash > 0 {
+		options = append(options, vs.WithCash(*cash))
+	}
+	if *extendedHours {
+		options = append(options, vs.WithSessionSchedule(vs.ExchangeTypeStock, vs.ExtendedStockSessionSchedule()))
+	}
+	if *holidays != "" {
+		days, err := loadHolidays(*holidays)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		options = append(options, vs.WithHolidays(days...))
+	}
+	security := vs.NewVirtualSecurityWithOptions(options...)
+
+	// 実時間で注文の失効や板寄せ後の取消をする
+	scheduler := vs.NewScheduler(security, nil)
+	if err := scheduler.Start(time.Second); err != nil {
+		log.Fatalln(err)
+	}
+	defer scheduler.Stop()
+
+	log.Printf("listen: http://%s%s\n", *addr, apiPathPrefix)
+	if err := http.ListenAndServe(*addr, newServer(security, *apiPassword).handler()); err != nil {
+		log.Println(err)
+	}
+}
+
+func loadHolidays(path string) ([]time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return vs.LoadHolidays(f)
+}
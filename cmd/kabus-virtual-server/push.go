@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// register - 銘柄登録
+//   登録した銘柄の価格だけがPUSH配信される
+func (s *server) register(w http.ResponseWriter, r *http.Request) {
+	var req registerRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	if err := validateRegisterSymbols(req.Symbols); err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, err)
+		return
+	}
+
+	s.pushMtx.Lock()
+	defer s.pushMtx.Unlock()
+	for _, symbol := range req.Symbols {
+		if s.registeredIndex(symbol) < 0 {
+			s.registered = append(s.registered, &registerSymbol{Symbol: symbol.Symbol, Exchange: symbol.Exchange})
+		}
+	}
+	s.writeJSON(w, http.StatusOK, s.registerResponse())
+}
+
+// unregister - 銘柄登録解除
+func (s *server) unregister(w http.ResponseWriter, r *http.Request) {
+	var req registerRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	if err := validateRegisterSymbols(req.Symbols); err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, err)
+		return
+	}
+
+	s.pushMtx.Lock()
+	defer s.pushMtx.Unlock()
+	for _, symbol := range req.Symbols {
+		if i := s.registeredIndex(symbol); i >= 0 {
+			s.registered = append(s.registered[:i], s.registered[i+1:]...)
+		}
+	}
+	s.writeJSON(w, http.StatusOK, s.registerResponse())
+}
+
+// unregisterAll - 銘柄登録全解除
+func (s *server) unregisterAll(w http.ResponseWriter, _ *http.Request) {
+	s.pushMtx.Lock()
+	defer s.pushMtx.Unlock()
+	s.registered = nil
+	s.writeJSON(w, http.StatusOK, s.registerResponse())
+}
+
+// validateRegisterSymbols - 銘柄登録の銘柄を確認する
+func validateRegisterSymbols(symbols []*registerSymbol) error {
+	if len(symbols) == 0 {
+		return fmt.Errorf("symbols is empty")
+	}
+	for _, symbol := range symbols {
+		if symbol == nil || symbol.Symbol == "" || symbol.Exchange <= 0 {
+			return fmt.Errorf("invalid symbol(%+v)", symbol)
+		}
+	}
+	return nil
+}
+
+// registeredIndex - 登録銘柄の中での位置 (登録されていなければ-1)
+//   pushMtxをロックしてから呼び出す
+func (s *server) registeredIndex(symbol *registerSymbol) int {
+	for i, r := range s.registered {
+		if r.Symbol == symbol.Symbol && r.Exchange == symbol.Exchange {
+			return i
+		}
+	}
+	return -1
+}
+
+// registerResponse - 現在の登録銘柄をレスポンスにする
+//   pushMtxをロックしてから呼び出す
+func (s *server) registerResponse() *registerResponse {
+	list := make([]*registerSymbol, len(s.registered))
+	copy(list, s.registered)
+	return &registerResponse{RegistList: list}
+}
+
+// websocket - PUSH配信の接続を受け付ける
+func (s *server) websocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidRequest, err)
+		return
+	}
+
+	s.pushMtx.Lock()
+	s.clients[conn] = struct{}{}
+	s.pushMtx.Unlock()
+
+	go func() {
+		conn.serve()
+		s.pushMtx.Lock()
+		delete(s.clients, conn)
+		s.pushMtx.Unlock()
+	}()
+}
+
+// push - 登録されている銘柄なら、登録された価格を時価情報・板情報として接続中のクライアントに配信する
+func (s *server) push(symbolCode string) {
+	s.pushMtx.Lock()
+	defer s.pushMtx.Unlock()
+
+	if len(s.clients) == 0 {
+		return
+	}
+	var messages [][]byte
+	for _, r := range s.registered {
+		if r.Symbol != symbolCode {
+			continue
+		}
+		price, err := s.security.Price(symbolCode)
+		if err != nil {
+			return
+		}
+		message, err := json.Marshal(newBoard(r.Exchange, price))
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		messages = append(messages, message)
+	}
+
+	for conn := range s.clients {
+		for _, message := range messages {
+			if err := conn.writeText(message); err != nil {
+				conn.close()
+				delete(s.clients, conn)
+				break
+			}
+		}
+	}
+}
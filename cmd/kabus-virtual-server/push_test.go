@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	vs "gitlab.com/tsuchinaga/kabus-virtual-security"
+)
+
+// dialWebSocket - PUSH配信に接続する
+func dialWebSocket(t *testing.T, ts *httptest.Server) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/kabusapi/websocket", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols || res.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v, %+v\n", t.Name(), http.StatusSwitchingProtocols, res.StatusCode, res.Header)
+	}
+	return conn, br
+}
+
+func Test_server_register(t *testing.T) {
+	t.Parallel()
+	ts, _ := newTestServer(t, "")
+	token := issueToken(t, ts, "")
+
+	tests := []struct {
+		name       string
+		path       string
+		body       *registerRequest
+		wantStatus int
+		want       []*registerSymbol
+	}{
+		{name: "登録した銘柄が返される",
+			path:       "/kabusapi/register",
+			body:       &registerRequest{Symbols: []*registerSymbol{{Symbol: "1234", Exchange: 1}, {Symbol: "0001", Exchange: 1}}},
+			wantStatus: http.StatusOK,
+			want:       []*registerSymbol{{Symbol: "1234", Exchange: 1}, {Symbol: "0001", Exchange: 1}}},
+		{name: "登録済みの銘柄は重複しない",
+			path:       "/kabusapi/register",
+			body:       &registerRequest{Symbols: []*registerSymbol{{Symbol: "1234", Exchange: 1}, {Symbol: "1234", Exchange: 2}}},
+			wantStatus: http.StatusOK,
+			want:       []*registerSymbol{{Symbol: "1234", Exchange: 1}, {Symbol: "0001", Exchange: 1}, {Symbol: "1234", Exchange: 2}}},
+		{name: "市場のない銘柄はエラー",
+			path:       "/kabusapi/register",
+			body:       &registerRequest{Symbols: []*registerSymbol{{Symbol: "5678"}}},
+			wantStatus: http.StatusBadRequest},
+		{name: "登録解除した銘柄はなくなる",
+			path:       "/kabusapi/unregister",
+			body:       &registerRequest{Symbols: []*registerSymbol{{Symbol: "1234", Exchange: 1}}},
+			wantStatus: http.StatusOK,
+			want:       []*registerSymbol{{Symbol: "0001", Exchange: 1}, {Symbol: "1234", Exchange: 2}}},
+		{name: "全解除するとすべてなくなる",
+			path:       "/kabusapi/unregister/all",
+			wantStatus: http.StatusOK,
+			want:       []*registerSymbol{}},
+	}
+
+	// 登録銘柄は前のテストケースの結果を引き継ぐので、順番に実行する
+	for _, test := range tests {
+		var res registerResponse
+		var body interface{}
+		if test.body != nil {
+			body = test.body
+		}
+		status := doRequest(t, ts, http.MethodPut, test.path, token, body, &res)
+		if test.wantStatus != status || (status == http.StatusOK && !reflect.DeepEqual(test.want, res.RegistList)) {
+			t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", test.name, test.wantStatus, test.want, status, res.RegistList)
+		}
+	}
+}
+
+func Test_server_push(t *testing.T) {
+	t.Parallel()
+	clock := vs.NewSimulatedClock(time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local))
+	s := newServer(vs.NewVirtualSecurityWithOptions(vs.WithClock(clock)), "")
+	ts := httptest.NewServer(s.handler())
+	t.Cleanup(ts.Close)
+	token := issueToken(t, ts, "")
+	conn, br := dialWebSocket(t, ts)
+
+	if status := doRequest(t, ts, http.MethodPut, "/kabusapi/register", token, &registerRequest{Symbols: []*registerSymbol{{Symbol: "1234", Exchange: 1}}}, &registerResponse{}); status != http.StatusOK {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusOK, status)
+	}
+
+	// 登録していない銘柄は配信されず、登録した銘柄だけが配信される
+	for _, price := range []vs.RegisterPriceRequest{
+		{ExchangeType: vs.ExchangeTypeStock, SymbolCode: "0001", Price: 500, PriceTime: clock.Now()},
+		{ExchangeType: vs.ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: clock.Now(), Bid: 999, BidTime: clock.Now(), Ask: 1001, AskTime: clock.Now(),
+			AskBoard: []vs.BoardLevel{{Price: 1001, Quantity: 100}}},
+	} {
+		if status := doRequest(t, ts, http.MethodPost, "/virtual/price", "", price, nil); status != http.StatusNoContent {
+			t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusNoContent, status)
+		}
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	opcode, payload, err := readServerFrame(br)
+	if err != nil || opcode != webSocketOpText {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v, %+v\n", t.Name(), webSocketOpText, opcode, err)
+	}
+	var got board
+	if err := json.Unmarshal(payload, &got); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if got.Symbol != "1234" || got.Exchange != 1 || got.CurrentPrice != 1000 || got.BidPrice != 1001 || got.AskPrice != 999 ||
+		got.CurrentPriceTime == nil || !got.CurrentPriceTime.Equal(clock.Now()) || !reflect.DeepEqual(&boardQuote{Price: 1001, Qty: 100}, got.Sell1) {
+		t.Errorf("%s error\nwant: 1234, 1, 1000, 1001, 999, %+v\ngot: %s\n", t.Name(), clock.Now(), payload)
+	}
+
+	// 切断したクライアントは配信先から外れる
+	_, _ = conn.Write(maskedFrame(webSocketOpClose, nil))
+	_, _, _ = readServerFrame(br)
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.pushMtx.Lock()
+		clients := len(s.clients)
+		s.pushMtx.Unlock()
+		if clients == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 0, clients)
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func Test_server_websocket_error(t *testing.T) {
+	t.Parallel()
+	ts, _ := newTestServer(t, "")
+
+	var res errorResponse
+	if status := doRequest(t, ts, http.MethodGet, "/kabusapi/websocket", "", nil, &res); status != http.StatusBadRequest || res.Code != errorCodeInvalidRequest {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), http.StatusBadRequest, errorCodeInvalidRequest, status, res)
+	}
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	vs "gitlab.com/tsuchinaga/kabus-virtual-security"
+)
+
+const (
+	apiPathPrefix     = "/kabusapi"
+	virtualPathPrefix = "/virtual"
+)
+
+func newServer(security vs.VirtualSecurity, apiPassword string) *server {
+	return &server{
+		security:    security,
+		apiPassword: apiPassword,
+		newToken:    func() string { return strings.ReplaceAll(uuid.NewString(), "-", "") },
+		clients:     map[*webSocketConn]struct{}{},
+	}
+}
+
+// server - kabuステーションAPIを模したHTTPサーバ
+//   発行したトークンは最後の1つだけが有効で、X-API-KEYヘッダで指定されたトークンを確認する
+//   仮想証券会社の処理は仮想証券会社の中で1つずつ行なわれるので、リクエストごとのgoroutineやスケジューラから同時に呼び出してよい
+type server struct {
+	security    vs.VirtualSecurity
+	apiPassword string // APIパスワード (空ならチェックしない)
+	newToken    func() string
+	token       string
+	mtx         sync.Mutex                  // tokenのロック
+	registered  []*registerSymbol           // PUSH配信する銘柄
+	clients     map[*webSocketConn]struct{} // PUSH配信の接続
+	pushMtx     sync.Mutex
+}
+
+// handler - リクエストを処理するハンドラ
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(apiPathPrefix+"/token", s.method(http.MethodPost, s.issueToken))
+	mux.HandleFunc(apiPathPrefix+"/sendorder", s.method(http.MethodPost, s.authorized(s.sendOrder)))
+	mux.HandleFunc(apiPathPrefix+"/sendorder/future", s.method(http.MethodPost, s.authorized(s.sendOrderFuture)))
+	mux.HandleFunc(apiPathPrefix+"/cancelorder", s.method(http.MethodPut, s.authorized(s.cancelOrder)))
+	mux.HandleFunc(apiPathPrefix+"/orders", s.method(http.MethodGet, s.authorized(s.orders)))
+	mux.HandleFunc(apiPathPrefix+"/positions", s.method(http.MethodGet, s.authorized(s.positions)))
+	mux.HandleFunc(apiPathPrefix+"/board/", s.method(http.MethodGet, s.authorized(s.board)))
+	mux.HandleFunc(apiPathPrefix+"/register", s.method(http.MethodPut, s.authorized(s.register)))
+	mux.HandleFunc(apiPathPrefix+"/unregister", s.method(http.MethodPut, s.authorized(s.unregister)))
+	mux.HandleFunc(apiPathPrefix+"/unregister/all", s.method(http.MethodPut, s.authorized(s.unregisterAll)))
+	mux.HandleFunc(apiPathPrefix+"/websocket", s.method(http.MethodGet, s.websocket))
+
+	// kabuステーションAPIにはない、仮想証券会社を操作するためのエンドポイント
+	mux.HandleFunc(virtualPathPrefix+"/price", s.method(http.MethodPost, s.registerPrice))
+	mux.HandleFunc(virtualPathPrefix+"/stocksymbol", s.method(http.MethodPost, s.registerStockSymbol))
+	mux.HandleFunc(virtualPathPrefix+"/futuresymbol", s.method(http.MethodPost, s.registerFutureSymbol))
+	return mux
+}
+
+// method - 指定したHTTPメソッド以外のリクエストを拒否する
+func (s *server) method(method string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			s.writeError(w, http.StatusMethodNotAllowed, errorCodeInvalidRequest, fmt.Errorf("method not allowed(%s)", r.Method))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authorized - 発行済みのトークンを持たないリクエストを拒否する
+func (s *server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mtx.Lock()
+		token := s.token
+		s.mtx.Unlock()
+
+		if key := r.Header.Get("X-API-KEY"); token == "" || key != token {
+			s.writeError(w, http.StatusUnauthorized, errorCodeInvalidAPIKey, errors.New("invalid api key"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *server) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (s *server) writeError(w http.ResponseWriter, status int, code int, err error) {
+	s.writeJSON(w, status, &errorResponse{Code: code, Message: err.Error()})
+}
+
+// decode - リクエストボディをJSONとして読み込む
+func (s *server) decode(w http.ResponseWriter, r *http.Request, body interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidRequest, err)
+		return false
+	}
+	return true
+}
+
+// issueToken - トークン発行
+//   新しいトークンを発行すると、それまでのトークンは使えなくなる
+func (s *server) issueToken(w http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	if s.apiPassword != "" && req.APIPassword != s.apiPassword {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidPassword, errors.New("invalid api password"))
+		return
+	}
+
+	s.mtx.Lock()
+	s.token = s.newToken()
+	token := s.token
+	s.mtx.Unlock()
+
+	s.writeJSON(w, http.StatusOK, &tokenResponse{ResultCode: 0, Token: token})
+}
+
+// sendOrder - 株式の注文発注
+//   信用区分が現物なら現物注文、新規か返済なら信用注文にする
+//   信用の返済で返済建玉の指定がなく返済順序が指定されていれば、返済順序に従って保有中の建玉を割り当てる
+func (s *server) sendOrder(w http.ResponseWriter, r *http.Request) {
+	var req sendOrderRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+
+	side, err := toSide(req.Side)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, err)
+		return
+	}
+	executionCondition, ok := stockFrontOrderTypes[req.FrontOrderType]
+	if !ok {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, fmt.Errorf("invalid front order type(%d)", req.FrontOrderType))
+		return
+	}
+	stopCondition, err := toStockStopCondition(req.ReverseLimitOrder)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, err)
+		return
+	}
+
+	var res *vs.OrderResult
+	switch req.CashMargin {
+	case cashMarginStock:
+		var exitPositionOrder vs.ExitPositionOrder
+		if req.ClosePositionOrder != nil {
+			if exitPositionOrder, ok = closePositionOrders[*req.ClosePositionOrder]; !ok {
+				s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, fmt.Errorf("unsupported close position order(%d)", *req.ClosePositionOrder))
+				return
+			}
+		}
+		res, err = s.security.StockOrder(&vs.StockOrderRequest{
+			Side:               side,
+			ExecutionCondition: executionCondition,
+			SymbolCode:         req.Symbol,
+			Quantity:           req.Qty,
+			LimitPrice:         req.Price,
+			ExpiredAt:          toExpiredAt(req.ExpireDay),
+			StopCondition:      stopCondition,
+			ExitPositionList:   toExitPositionList(req.ClosePositions),
+			ExitPositionOrder:  exitPositionOrder,
+		})
+	case cashMarginMarginEntry, cashMarginMarginExit:
+		tradeType := vs.TradeTypeEntry
+		if req.CashMargin == cashMarginMarginExit {
+			tradeType = vs.TradeTypeExit
+		}
+		exitPositionList := toExitPositionList(req.ClosePositions)
+		if tradeType == vs.TradeTypeExit && len(exitPositionList) == 0 && req.ClosePositionOrder != nil {
+			exitPositionOrder, ok := closePositionOrders[*req.ClosePositionOrder]
+			if !ok {
+				s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, fmt.Errorf("unsupported close position order(%d)", *req.ClosePositionOrder))
+				return
+			}
+			positions, err := s.security.MarginPositions()
+			if err != nil {
+				s.writeError(w, http.StatusInternalServerError, errorCodeInternal, err)
+				return
+			}
+			exitPositionList = toMarginExitPositionList(positions, req.Symbol, side, req.Qty, exitPositionOrder)
+		}
+		res, err = s.security.MarginOrder(&vs.MarginOrderRequest{
+			TradeType:          tradeType,
+			Side:               side,
+			ExecutionCondition: executionCondition,
+			SymbolCode:         req.Symbol,
+			Quantity:           req.Qty,
+			LimitPrice:         req.Price,
+			ExpiredAt:          toExpiredAt(req.ExpireDay),
+			StopCondition:      stopCondition,
+			ExitPositionList:   exitPositionList,
+		})
+	default:
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, fmt.Errorf("invalid cash margin(%d)", req.CashMargin))
+		return
+	}
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeOrder, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, &sendOrderResponse{Result: 0, OrderID: res.OrderCode})
+}
+
+// sendOrderFuture - 先物の注文発注
+//   仮想証券会社の先物注文はFASだけなので、FAK, FOKはエラーにする
+func (s *server) sendOrderFuture(w http.ResponseWriter, r *http.Request) {
+	var req sendOrderFutureRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+
+	side, err := toSide(req.Side)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, err)
+		return
+	}
+	executionCondition, ok := futureFrontOrderTypes[req.FrontOrderType]
+	if !ok {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, fmt.Errorf("invalid front order type(%d)", req.FrontOrderType))
+		return
+	}
+	if req.TimeInForce != timeInForceFAS {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, fmt.Errorf("unsupported time in force(%d)", req.TimeInForce))
+		return
+	}
+	var tradeType vs.TradeType
+	switch req.TradeType {
+	case tradeTypeEntry:
+		tradeType = vs.TradeTypeEntry
+	case tradeTypeExit:
+		tradeType = vs.TradeTypeExit
+	default:
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, fmt.Errorf("invalid trade type(%d)", req.TradeType))
+		return
+	}
+	stopCondition, err := toFutureStopCondition(req.ReverseLimitOrder)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, err)
+		return
+	}
+
+	res, err := s.security.FutureOrder(&vs.FutureOrderRequest{
+		TradeType:          tradeType,
+		Side:               side,
+		ExecutionCondition: executionCondition,
+		SymbolCode:         req.Symbol,
+		Quantity:           req.Qty,
+		LimitPrice:         req.Price,
+		ExpiredAt:          toExpiredAt(req.ExpireDay),
+		StopCondition:      stopCondition,
+		ExitPositionList:   toExitPositionList(req.ClosePositions),
+	})
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeOrder, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, &sendOrderResponse{Result: 0, OrderID: res.OrderCode})
+}
+
+// cancelOrder - 注文取消
+//   注文コードの接頭辞から、現物、信用、先物のどの注文かを判断する
+func (s *server) cancelOrder(w http.ResponseWriter, r *http.Request) {
+	var req cancelOrderRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+
+	var err error
+	cancelRequest := &vs.CancelOrderRequest{OrderCode: req.OrderID}
+	switch {
+	case strings.HasPrefix(req.OrderID, "sor-"):
+		err = s.security.CancelStockOrder(cancelRequest)
+	case strings.HasPrefix(req.OrderID, "mor-"):
+		err = s.security.CancelMarginOrder(cancelRequest)
+	case strings.HasPrefix(req.OrderID, "for-"):
+		err = s.security.CancelFutureOrder(cancelRequest)
+	default:
+		err = fmt.Errorf("not found order(code: %s)", req.OrderID)
+	}
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeOrder, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, &sendOrderResponse{Result: 0, OrderID: req.OrderID})
+}
+
+// product - クエリの商品区分を読み込む (指定がなければすべて)
+func (s *server) product(r *http.Request) (int, error) {
+	q := r.URL.Query().Get("product")
+	if q == "" {
+		return productAll, nil
+	}
+	product, err := strconv.Atoi(q)
+	if err != nil || product < productAll || product > productFuture {
+		return 0, fmt.Errorf("invalid product(%s)", q)
+	}
+	return product, nil
+}
+
+// orders - 注文約定照会
+//   idを指定すれば、その注文だけを返す
+func (s *server) orders(w http.ResponseWriter, r *http.Request) {
+	product, err := s.product(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, err)
+		return
+	}
+
+	res := make([]*order, 0)
+	if product == productAll || product == productStock {
+		orders, err := s.security.StockOrders()
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, errorCodeInternal, err)
+			return
+		}
+		for _, o := range orders {
+			res = append(res, fromStockOrder(o))
+		}
+	}
+	if product == productAll || product == productMargin {
+		orders, err := s.security.MarginOrders()
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, errorCodeInternal, err)
+			return
+		}
+		for _, o := range orders {
+			res = append(res, fromMarginOrder(o))
+		}
+	}
+	if product == productAll || product == productFuture {
+		orders, err := s.security.FutureOrders()
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, errorCodeInternal, err)
+			return
+		}
+		for _, o := range orders {
+			res = append(res, fromFutureOrder(o))
+		}
+	}
+
+	if id := r.URL.Query().Get("id"); id != "" {
+		filtered := make([]*order, 0)
+		for _, o := range res {
+			if o.ID == id {
+				filtered = append(filtered, o)
+			}
+		}
+		res = filtered
+	}
+
+	s.writeJSON(w, http.StatusOK, res)
+}
+
+// positions - 残高照会
+//   保有数量の残っている建玉だけを返す
+func (s *server) positions(w http.ResponseWriter, r *http.Request) {
+	product, err := s.product(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, err)
+		return
+	}
+
+	res := make([]*position, 0)
+	if product == productAll || product == productStock {
+		positions, err := s.security.StockPositions()
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, errorCodeInternal, err)
+			return
+		}
+		for _, p := range positions {
+			if p.OwnedQuantity <= 0 {
+				continue
+			}
+			pos := newPosition(p.Code, p.SymbolCode, exchangeStock, p.Side, p.Price, p.OwnedQuantity, p.HoldQuantity, 1, p.ContractedAt, p.CurrentPrice)
+			pos.SecurityType = securityTypeStock
+			res = append(res, pos)
+		}
+	}
+	if product == productAll || product == productMargin {
+		positions, err := s.security.MarginPositions()
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, errorCodeInternal, err)
+			return
+		}
+		for _, p := range positions {
+			if p.OwnedQuantity <= 0 {
+				continue
+			}
+			pos := newPosition(p.Code, p.SymbolCode, exchangeStock, p.Side, p.Price, p.OwnedQuantity, p.HoldQuantity, 1, p.ContractedAt, p.CurrentPrice)
+			pos.SecurityType = securityTypeStock
+			pos.MarginTradeType = marginTradeTypeSystem
+			res = append(res, pos)
+		}
+	}
+	if product == productAll || product == productFuture {
+		positions, err := s.security.FuturePositions()
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, errorCodeInternal, err)
+			return
+		}
+		for _, p := range positions {
+			if p.OwnedQuantity <= 0 {
+				continue
+			}
+			res = append(res, newPosition(p.Code, p.SymbolCode, exchangeFuture, p.Side, p.Price, p.OwnedQuantity, p.HoldQuantity, p.Multiplier, p.ContractedAt, p.CurrentPrice))
+		}
+	}
+
+	s.writeJSON(w, http.StatusOK, res)
+}
+
+// board - 時価情報・板情報
+//   パスは /board/{銘柄コード}@{市場コード}
+func (s *server) board(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, apiPathPrefix+"/board/")
+	symbol, exchange := path, ""
+	if i := strings.LastIndex(path, "@"); i >= 0 {
+		symbol, exchange = path[:i], path[i+1:]
+	}
+	exchangeCode, err := strconv.Atoi(exchange)
+	if symbol == "" || err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, fmt.Errorf("invalid symbol(%s)", path))
+		return
+	}
+
+	price, err := s.security.Price(symbol)
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, errorCodeInvalidParameter, fmt.Errorf("not found price(symbol: %s), %w", symbol, err))
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, newBoard(exchangeCode, price))
+}
+
+// registerPrice - 銘柄価格の登録
+func (s *server) registerPrice(w http.ResponseWriter, r *http.Request) {
+	var req vs.RegisterPriceRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	if err := s.security.RegisterPrice(req); err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, err)
+		return
+	}
+	s.push(req.SymbolCode)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// registerStockSymbol - 株式銘柄の登録
+func (s *server) registerStockSymbol(w http.ResponseWriter, r *http.Request) {
+	var req vs.StockSymbol
+	if !s.decode(w, r, &req) {
+		return
+	}
+	if err := s.security.RegisterStockSymbol(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// registerFutureSymbol - 先物銘柄の登録
+func (s *server) registerFutureSymbol(w http.ResponseWriter, r *http.Request) {
+	var req vs.FutureSymbol
+	if !s.decode(w, r, &req) {
+		return
+	}
+	if err := s.security.RegisterFutureSymbol(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, errorCodeInvalidParameter, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	vs "gitlab.com/tsuchinaga/kabus-virtual-security"
+)
+
+func newTestServer(t *testing.T, apiPassword string) (*httptest.Server, *vs.SimulatedClock) {
+	t.Helper()
+	clock := vs.NewSimulatedClock(time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local))
+	security := vs.NewVirtualSecurityWithOptions(vs.WithClock(clock))
+	s := newServer(security, apiPassword)
+	ts := httptest.NewServer(s.handler())
+	t.Cleanup(ts.Close)
+	return ts, clock
+}
+
+func doRequest(t *testing.T, ts *httptest.Server, method string, path string, token string, body interface{}, res interface{}) int {
+	t.Helper()
+	var b []byte
+	if s, ok := body.(string); ok {
+		b = []byte(s)
+	} else if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			t.Fatalf("%s error\n%+v\n", t.Name(), err)
+		}
+	}
+	req, err := http.NewRequest(method, ts.URL+path, bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if token != "" {
+		req.Header.Set("X-API-KEY", token)
+	}
+	r, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	defer r.Body.Close()
+	if res != nil && r.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(r.Body).Decode(res); err != nil {
+			t.Fatalf("%s error\n%+v\n", t.Name(), err)
+		}
+	}
+	return r.StatusCode
+}
+
+func issueToken(t *testing.T, ts *httptest.Server, apiPassword string) string {
+	t.Helper()
+	var res tokenResponse
+	if status := doRequest(t, ts, http.MethodPost, "/kabusapi/token", "", &tokenRequest{APIPassword: apiPassword}, &res); status != http.StatusOK {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusOK, status)
+	}
+	return res.Token
+}
+
+func Test_server_token(t *testing.T) {
+	t.Parallel()
+	ts, _ := newTestServer(t, "password")
+
+	// パスワードが違えばトークンは発行されない
+	var errRes errorResponse
+	if status := doRequest(t, ts, http.MethodPost, "/kabusapi/token", "", &tokenRequest{APIPassword: "wrong"}, &errRes); status != http.StatusBadRequest || errRes.Code != errorCodeInvalidPassword {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), http.StatusBadRequest, errorCodeInvalidPassword, status, errRes.Code)
+	}
+
+	// トークンがなければ他のAPIは使えない
+	if status := doRequest(t, ts, http.MethodGet, "/kabusapi/orders", "", nil, &errRes); status != http.StatusUnauthorized || errRes.Code != errorCodeInvalidAPIKey {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), http.StatusUnauthorized, errorCodeInvalidAPIKey, status, errRes.Code)
+	}
+
+	// 新しいトークンを発行すると古いトークンは使えなくなる
+	token1 := issueToken(t, ts, "password")
+	if status := doRequest(t, ts, http.MethodGet, "/kabusapi/orders", token1, nil, &[]*order{}); status != http.StatusOK {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusOK, status)
+	}
+	token2 := issueToken(t, ts, "password")
+	if token1 == token2 {
+		t.Fatalf("%s error\nwant: not %+v\ngot: %+v\n", t.Name(), token1, token2)
+	}
+	if status := doRequest(t, ts, http.MethodGet, "/kabusapi/orders", token1, nil, &errRes); status != http.StatusUnauthorized {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusUnauthorized, status)
+	}
+	if status := doRequest(t, ts, http.MethodGet, "/kabusapi/orders", token2, nil, &[]*order{}); status != http.StatusOK {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusOK, status)
+	}
+}
+
+func Test_server_stockOrder(t *testing.T) {
+	t.Parallel()
+	ts, clock := newTestServer(t, "")
+	token := issueToken(t, ts, "")
+
+	// 価格を登録してから現物の成行買いを出すと、次の価格登録で約定して建玉ができる
+	price := vs.RegisterPriceRequest{ExchangeType: vs.ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: clock.Now(), Bid: 999, BidTime: clock.Now(), Ask: 1001, AskTime: clock.Now()}
+	if status := doRequest(t, ts, http.MethodPost, "/virtual/price", "", price, nil); status != http.StatusNoContent {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusNoContent, status)
+	}
+
+	var res1 sendOrderResponse
+	if status := doRequest(t, ts, http.MethodPost, "/kabusapi/sendorder", token, &sendOrderRequest{
+		Symbol: "1234", Exchange: 1, SecurityType: 1, Side: sideBuy, CashMargin: cashMarginStock, Qty: 100, FrontOrderType: 10,
+	}, &res1); status != http.StatusOK || res1.OrderID == "" {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v, %+v\n", t.Name(), http.StatusOK, status, res1)
+	}
+
+	clock.Set(time.Date(2021, 9, 1, 10, 0, 1, 0, time.Local))
+	price.Price, price.PriceTime, price.BidTime, price.AskTime = 1001, clock.Now(), clock.Now(), clock.Now()
+	if status := doRequest(t, ts, http.MethodPost, "/virtual/price", "", price, nil); status != http.StatusNoContent {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusNoContent, status)
+	}
+
+	var positions []*position
+	if status := doRequest(t, ts, http.MethodGet, "/kabusapi/positions?product=1", token, nil, &positions); status != http.StatusOK {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusOK, status)
+	}
+	if len(positions) != 1 || positions[0].Symbol != "1234" || positions[0].Side != sideBuy || positions[0].LeavesQty != 100 || positions[0].CurrentPrice != 1001 {
+		t.Errorf("%s error\nwant: 1234, %+v, 100, 1001\ngot: %+v\n", t.Name(), sideBuy, positions)
+	}
+
+	// 約定しない指値を出して取り消すと、注文一覧で取消済みになる
+	var res2 sendOrderResponse
+	if status := doRequest(t, ts, http.MethodPost, "/kabusapi/sendorder", token, &sendOrderRequest{
+		Symbol: "1234", Exchange: 1, SecurityType: 1, Side: sideBuy, CashMargin: cashMarginStock, Qty: 100, Price: 900, FrontOrderType: 20,
+	}, &res2); status != http.StatusOK {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusOK, status)
+	}
+	if status := doRequest(t, ts, http.MethodPut, "/kabusapi/cancelorder", token, &cancelOrderRequest{OrderID: res2.OrderID}, &sendOrderResponse{}); status != http.StatusOK {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusOK, status)
+	}
+
+	var orders []*order
+	if status := doRequest(t, ts, http.MethodGet, "/kabusapi/orders?product=1&id="+res2.OrderID, token, nil, &orders); status != http.StatusOK {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusOK, status)
+	}
+	if len(orders) != 1 || orders[0].ID != res2.OrderID || orders[0].State != stateTerminated || orders[0].CumQty != 0 {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v\n", t.Name(), res2.OrderID, stateTerminated, orders)
+	}
+
+	// 板情報は登録した価格から作られる
+	var b board
+	if status := doRequest(t, ts, http.MethodGet, "/kabusapi/board/1234@1", token, nil, &b); status != http.StatusOK {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusOK, status)
+	}
+	if b.Symbol != "1234" || b.Exchange != 1 || b.CurrentPrice != 1001 || b.BidPrice != 1001 || b.AskPrice != 999 {
+		t.Errorf("%s error\nwant: 1234, 1, 1001, 1001, 999\ngot: %+v\n", t.Name(), b)
+	}
+}
+
+func Test_server_marginOrder_closePositionOrder(t *testing.T) {
+	t.Parallel()
+	ts, clock := newTestServer(t, "")
+	token := issueToken(t, ts, "")
+
+	// 信用の新規買いを売り気配値を変えて2回約定させ、約定日時と約定価格の違う建玉を2つ作る
+	price := vs.RegisterPriceRequest{ExchangeType: vs.ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: clock.Now(), Bid: 999, BidTime: clock.Now(), Ask: 1001, AskTime: clock.Now()}
+	if status := doRequest(t, ts, http.MethodPost, "/virtual/price", "", price, nil); status != http.StatusNoContent {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusNoContent, status)
+	}
+	for i, ask := range []float64{1001, 1011} {
+		clock.Set(time.Date(2021, 9, 1, 10, 0, i+1, 0, time.Local))
+		price.Ask, price.PriceTime, price.BidTime, price.AskTime = ask, clock.Now(), clock.Now(), clock.Now()
+		if status := doRequest(t, ts, http.MethodPost, "/virtual/price", "", price, nil); status != http.StatusNoContent {
+			t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusNoContent, status)
+		}
+		if status := doRequest(t, ts, http.MethodPost, "/kabusapi/sendorder", token, &sendOrderRequest{
+			Symbol: "1234", Exchange: 1, SecurityType: 1, Side: sideBuy, CashMargin: cashMarginMarginEntry, Qty: 100, FrontOrderType: 10,
+		}, &sendOrderResponse{}); status != http.StatusOK {
+			t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusOK, status)
+		}
+	}
+
+	// 対応していない返済順序ならエラー
+	invalid := 9
+	var errRes errorResponse
+	if status := doRequest(t, ts, http.MethodPost, "/kabusapi/sendorder", token, &sendOrderRequest{
+		Symbol: "1234", Exchange: 1, SecurityType: 1, Side: sideSell, CashMargin: cashMarginMarginExit, Qty: 150, Price: 1100, FrontOrderType: 20, ClosePositionOrder: &invalid,
+	}, &errRes); status != http.StatusBadRequest || errRes.Code != errorCodeInvalidParameter {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), http.StatusBadRequest, errorCodeInvalidParameter, status, errRes.Code)
+	}
+
+	// 返済建玉を指定せずに返済順序を新しい順にすると、新しい建玉から返済数量を割り当てる
+	lifo := 2
+	if status := doRequest(t, ts, http.MethodPost, "/kabusapi/sendorder", token, &sendOrderRequest{
+		Symbol: "1234", Exchange: 1, SecurityType: 1, Side: sideSell, CashMargin: cashMarginMarginExit, Qty: 150, Price: 1100, FrontOrderType: 20, ClosePositionOrder: &lifo,
+	}, &sendOrderResponse{}); status != http.StatusOK {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusOK, status)
+	}
+
+	var positions []*position
+	if status := doRequest(t, ts, http.MethodGet, "/kabusapi/positions?product=2", token, nil, &positions); status != http.StatusOK {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusOK, status)
+	}
+	got := map[float64]float64{}
+	for _, p := range positions {
+		got[p.Price] = p.HoldQty
+	}
+	if want := map[float64]float64{1001: 50, 1011: 100}; !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_server_futureOrder(t *testing.T) {
+	t.Parallel()
+	ts, _ := newTestServer(t, "")
+	token := issueToken(t, ts, "")
+
+	// 先物銘柄を登録してから注文を出す
+	if status := doRequest(t, ts, http.MethodPost, "/virtual/futuresymbol", "", &vs.FutureSymbol{SymbolCode: "160060018", Multiplier: 100}, nil); status != http.StatusNoContent {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusNoContent, status)
+	}
+
+	var res sendOrderResponse
+	if status := doRequest(t, ts, http.MethodPost, "/kabusapi/sendorder/future", token, &sendOrderFutureRequest{
+		Symbol: "160060018", Exchange: 2, TradeType: tradeTypeEntry, TimeInForce: timeInForceFAS, Side: sideSell, Qty: 1, FrontOrderType: 20, Price: 30000,
+	}, &res); status != http.StatusOK {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusOK, status)
+	}
+
+	var orders []*order
+	if status := doRequest(t, ts, http.MethodGet, "/kabusapi/orders?product=3", token, nil, &orders); status != http.StatusOK {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), http.StatusOK, status)
+	}
+	want := []string{res.OrderID}
+	got := make([]string, 0)
+	for _, o := range orders {
+		got = append(got, o.ID)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_server_error(t *testing.T) {
+	t.Parallel()
+	ts, _ := newTestServer(t, "")
+	token := issueToken(t, ts, "")
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       interface{}
+		wantStatus int
+		wantCode   int
+	}{
+		{name: "メソッドが違えばエラー", method: http.MethodGet, path: "/kabusapi/sendorder", wantStatus: http.StatusMethodNotAllowed, wantCode: errorCodeInvalidRequest},
+		{name: "JSONとして読めなければエラー", method: http.MethodPost, path: "/kabusapi/sendorder", body: "{", wantStatus: http.StatusBadRequest, wantCode: errorCodeInvalidRequest},
+		{name: "売買区分が不正ならエラー", method: http.MethodPost, path: "/kabusapi/sendorder",
+			body:       &sendOrderRequest{Symbol: "1234", Side: "3", CashMargin: cashMarginStock, Qty: 100, FrontOrderType: 10},
+			wantStatus: http.StatusBadRequest, wantCode: errorCodeInvalidParameter},
+		{name: "執行条件が不正ならエラー", method: http.MethodPost, path: "/kabusapi/sendorder",
+			body:       &sendOrderRequest{Symbol: "1234", Side: sideBuy, CashMargin: cashMarginStock, Qty: 100, FrontOrderType: 99},
+			wantStatus: http.StatusBadRequest, wantCode: errorCodeInvalidParameter},
+		{name: "信用区分が不正ならエラー", method: http.MethodPost, path: "/kabusapi/sendorder",
+			body:       &sendOrderRequest{Symbol: "1234", Side: sideBuy, CashMargin: 9, Qty: 100, FrontOrderType: 10},
+			wantStatus: http.StatusBadRequest, wantCode: errorCodeInvalidParameter},
+		{name: "仮想証券会社が注文を受け付けなければエラー", method: http.MethodPost, path: "/kabusapi/sendorder",
+			body:       &sendOrderRequest{Symbol: "1234", Side: sideBuy, CashMargin: cashMarginStock, Qty: 0, FrontOrderType: 10},
+			wantStatus: http.StatusBadRequest, wantCode: errorCodeOrder},
+		{name: "先物のFAKはエラー", method: http.MethodPost, path: "/kabusapi/sendorder/future",
+			body:       &sendOrderFutureRequest{Symbol: "160060018", TradeType: tradeTypeEntry, TimeInForce: 2, Side: sideBuy, Qty: 1, FrontOrderType: 120},
+			wantStatus: http.StatusBadRequest, wantCode: errorCodeInvalidParameter},
+		{name: "呼値の単位の表が不正な株式銘柄はエラー", method: http.MethodPost, path: "/virtual/stocksymbol",
+			body:       &vs.StockSymbol{SymbolCode: "1234", TickSizeTable: "xxx"},
+			wantStatus: http.StatusBadRequest, wantCode: errorCodeInvalidParameter},
+		{name: "存在しない注文の取消はエラー", method: http.MethodPut, path: "/kabusapi/cancelorder",
+			body:       &cancelOrderRequest{OrderID: "xxx-1"},
+			wantStatus: http.StatusBadRequest, wantCode: errorCodeOrder},
+		{name: "商品区分が不正ならエラー", method: http.MethodGet, path: "/kabusapi/orders?product=9", wantStatus: http.StatusBadRequest, wantCode: errorCodeInvalidParameter},
+		{name: "市場コードがなければエラー", method: http.MethodGet, path: "/kabusapi/board/1234", wantStatus: http.StatusBadRequest, wantCode: errorCodeInvalidParameter},
+		{name: "価格のない銘柄の板情報はエラー", method: http.MethodGet, path: "/kabusapi/board/9999@1", wantStatus: http.StatusNotFound, wantCode: errorCodeInvalidParameter},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var res errorResponse
+			status := doRequest(t, ts, test.method, test.path, token, test.body, &res)
+			if test.wantStatus != status || test.wantCode != res.Code {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.wantStatus, test.wantCode, status, res)
+			}
+		})
+	}
+}
+
+func Test_server_concurrent(t *testing.T) {
+	t.Parallel()
+	clock := vs.NewSimulatedClock(time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local))
+	security := vs.NewVirtualSecurityWithOptions(vs.WithClock(clock))
+	ts := httptest.NewServer(newServer(security, "").handler())
+	t.Cleanup(ts.Close)
+	token := issueToken(t, ts, "")
+
+	// main.goと同じように、スケジューラを動かしながらリクエストを処理する
+	scheduler := vs.NewScheduler(security, nil)
+	if err := scheduler.Start(time.Millisecond); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	defer scheduler.Stop()
+
+	requests := []struct {
+		method string
+		path   string
+		token  string
+		body   interface{}
+	}{
+		{method: http.MethodPost, path: "/virtual/price", body: vs.RegisterPriceRequest{ExchangeType: vs.ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: clock.Now(), Bid: 999, BidTime: clock.Now(), Ask: 1001, AskTime: clock.Now()}},
+		{method: http.MethodPost, path: "/kabusapi/sendorder", token: token, body: &sendOrderRequest{Symbol: "1234", Exchange: 1, SecurityType: 1, Side: sideBuy, CashMargin: cashMarginStock, Qty: 100, FrontOrderType: 10}},
+		{method: http.MethodGet, path: "/kabusapi/orders", token: token},
+		{method: http.MethodGet, path: "/kabusapi/positions", token: token},
+		{method: http.MethodGet, path: "/kabusapi/board/1234@1", token: token},
+	}
+
+	// go test -raceで、リクエストの処理とスケジューラが競合しないことを確認する
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for _, r := range requests {
+			wg.Add(1)
+			go func(method string, path string, token string, body interface{}) {
+				defer wg.Done()
+				b, _ := json.Marshal(body)
+				req, err := http.NewRequest(method, ts.URL+path, bytes.NewReader(b))
+				if err != nil {
+					t.Errorf("%s error\n%+v\n", t.Name(), err)
+					return
+				}
+				req.Header.Set("X-API-KEY", token)
+				res, err := ts.Client().Do(req)
+				if err != nil {
+					t.Errorf("%s error\n%+v\n", t.Name(), err)
+					return
+				}
+				_ = res.Body.Close()
+			}(r.method, r.path, r.token, r.body)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RFC6455のオペコード
+const (
+	webSocketOpText  = 0x1
+	webSocketOpClose = 0x8
+	webSocketOpPing  = 0x9
+	webSocketOpPong  = 0xa
+)
+
+// webSocketGUID - Sec-WebSocket-Acceptを作るときに使う固定値
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWebSocketPayload - クライアントから受け取るフレームの上限
+//   PUSH配信ではクライアントから意味のあるデータを受け取らないので、制御フレームが読めれば十分
+const maxWebSocketPayload = 1 << 16
+
+// webSocketWriteTimeout - 受信しないクライアントで配信が止まらないようにする送信の期限
+const webSocketWriteTimeout = 5 * time.Second
+
+var (
+	errWebSocketHandshake = errors.New("invalid websocket handshake")
+	errWebSocketFrame     = errors.New("invalid websocket frame")
+)
+
+// webSocketConn - サーバ側のWebSocket接続
+//   PUSH配信に必要な、テキストフレームの送信と制御フレームの応答だけを扱う
+type webSocketConn struct {
+	conn      net.Conn
+	rw        *bufio.ReadWriter
+	mtx       sync.Mutex
+	closeOnce sync.Once
+}
+
+// upgradeWebSocket - HTTPのリクエストをWebSocketにアップグレードする
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*webSocketConn, error) {
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, errWebSocketHandshake
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, errWebSocketHandshake
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, errWebSocketHandshake
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, fmt.Errorf("response writer can not hijack, %w", errWebSocketHandshake)
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha1.New()
+	h.Write([]byte(key + webSocketGUID))
+	accept := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + accept + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &webSocketConn{conn: conn, rw: rw}, nil
+}
+
+// headerContains - カンマ区切りのヘッダに指定したトークンが含まれているか
+func headerContains(header http.Header, name string, token string) bool {
+	for _, v := range header.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeText - テキストフレームを送信する
+func (c *webSocketConn) writeText(payload []byte) error {
+	return c.writeFrame(webSocketOpText, payload)
+}
+
+// writeFrame - サーバからはマスクせずに1つのフレームで送信する
+func (c *webSocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout)); err != nil {
+		return err
+	}
+	header := []byte{0x80 | opcode}
+	switch l := len(payload); {
+	case l < 126:
+		header = append(header, byte(l))
+	case l <= 0xffff:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(l))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(l))
+	}
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// readFrame - クライアントからのフレームを1つ読み込む
+//   クライアントからのフレームは必ずマスクされている
+func (c *webSocketConn) readFrame() (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.rw, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := head[0] & 0x0f
+	if head[1]&0x80 == 0 {
+		return 0, nil, errWebSocketFrame
+	}
+
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxWebSocketPayload {
+		return 0, nil, errWebSocketFrame
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// serve - 接続が切れるまでクライアントからのフレームを読み、pingとcloseに応答する
+func (c *webSocketConn) serve() {
+	defer c.close()
+	for {
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case webSocketOpPing:
+			if err := c.writeFrame(webSocketOpPong, payload); err != nil {
+				return
+			}
+		case webSocketOpClose:
+			_ = c.writeFrame(webSocketOpClose, payload)
+			return
+		}
+	}
+}
+
+// close - 接続を閉じる (複数回呼ばれても1回だけ閉じる)
+func (c *webSocketConn) close() {
+	c.closeOnce.Do(func() { _ = c.conn.Close() })
+}
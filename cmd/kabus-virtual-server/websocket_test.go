@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// newTestWebSocketConn - 片側をサーバ側の接続にしたパイプを作る
+func newTestWebSocketConn(t *testing.T) (*webSocketConn, net.Conn) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+	return &webSocketConn{conn: server, rw: bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server))}, client
+}
+
+// maskedFrame - クライアントが送信する、マスクされたフレームを作る
+func maskedFrame(opcode byte, payload []byte) []byte {
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	frame := []byte{0x80 | opcode}
+	switch l := len(payload); {
+	case l < 126:
+		frame = append(frame, 0x80|byte(l))
+	default:
+		frame = append(frame, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(l))
+	}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+// readServerFrame - サーバが送信した、マスクされていないフレームを読み込む
+func readServerFrame(r io.Reader) (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return head[0] & 0x0f, payload, nil
+}
+
+func Test_headerContains(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{
+		{name: "ヘッダがなければfalse", header: http.Header{}, want: false},
+		{name: "大文字小文字を区別しない", header: http.Header{"Connection": {"Upgrade"}}, want: true},
+		{name: "カンマ区切りの中にあればtrue", header: http.Header{"Connection": {"keep-alive, Upgrade"}}, want: true},
+		{name: "別の値だけならfalse", header: http.Header{"Connection": {"keep-alive"}}, want: false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := headerContains(test.header, "Connection", "upgrade")
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_webSocketConn_writeText(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{name: "125byte以下は長さを1byteで表す", payload: bytes.Repeat([]byte("a"), 125)},
+		{name: "65535byte以下は長さを2byteで表す", payload: bytes.Repeat([]byte("a"), 126)},
+		{name: "65536byte以上は長さを8byteで表す", payload: bytes.Repeat([]byte("a"), 65536)},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			conn, client := newTestWebSocketConn(t)
+			errCh := make(chan error, 1)
+			go func() { errCh <- conn.writeText(test.payload) }()
+
+			opcode, payload, err := readServerFrame(client)
+			if err != nil {
+				t.Fatalf("%s error\n%+v\n", t.Name(), err)
+			}
+			if err := <-errCh; err != nil {
+				t.Fatalf("%s error\n%+v\n", t.Name(), err)
+			}
+			if opcode != webSocketOpText || !bytes.Equal(test.payload, payload) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), webSocketOpText, len(test.payload), opcode, len(payload))
+			}
+		})
+	}
+}
+
+func Test_webSocketConn_readFrame(t *testing.T) {
+	t.Parallel()
+	unmasked := []byte{0x80 | webSocketOpText, 1, 'a'}
+	tooLong := []byte{0x80 | webSocketOpText, 0x80 | 127, 0, 0, 0, 0, 0, 1, 0, 1}
+	tests := []struct {
+		name        string
+		frame       []byte
+		wantOpcode  byte
+		wantPayload []byte
+		wantError   error
+	}{
+		{name: "マスクを外して読み込む", frame: maskedFrame(webSocketOpText, []byte("hello")), wantOpcode: webSocketOpText, wantPayload: []byte("hello")},
+		{name: "長さが2byteのフレームも読み込める", frame: maskedFrame(webSocketOpText, bytes.Repeat([]byte("b"), 200)), wantOpcode: webSocketOpText, wantPayload: bytes.Repeat([]byte("b"), 200)},
+		{name: "マスクされていなければエラー", frame: unmasked, wantError: errWebSocketFrame},
+		{name: "上限を超える長さならエラー", frame: tooLong, wantError: errWebSocketFrame},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			conn, client := newTestWebSocketConn(t)
+			go func() { _, _ = client.Write(test.frame) }()
+
+			opcode, payload, err := conn.readFrame()
+			if test.wantOpcode != opcode || !bytes.Equal(test.wantPayload, payload) || !errors.Is(err, test.wantError) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(), test.wantOpcode, test.wantPayload, test.wantError, opcode, payload, err)
+			}
+		})
+	}
+}
+
+func Test_webSocketConn_serve(t *testing.T) {
+	t.Parallel()
+	conn, client := newTestWebSocketConn(t)
+	done := make(chan struct{})
+	go func() {
+		conn.serve()
+		close(done)
+	}()
+
+	// pingにはpongを返す
+	go func() { _, _ = client.Write(maskedFrame(webSocketOpPing, []byte("ping"))) }()
+	if opcode, payload, err := readServerFrame(client); err != nil || opcode != webSocketOpPong || string(payload) != "ping" {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(), webSocketOpPong, "ping", opcode, string(payload), err)
+	}
+
+	// closeにはcloseを返して終了する
+	go func() { _, _ = client.Write(maskedFrame(webSocketOpClose, nil)) }()
+	if opcode, _, err := readServerFrame(client); err != nil || opcode != webSocketOpClose {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v, %+v\n", t.Name(), webSocketOpClose, opcode, err)
+	}
+	<-done
+}
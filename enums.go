@@ -105,6 +105,15 @@ func (e StockExecutionCondition) IsFunari() bool {
 	return false
 }
 
+func (e StockExecutionCondition) IsIOC() bool {
+	switch e {
+	case StockExecutionConditionIOCMO, // IOC成行
+		StockExecutionConditionIOCLO: // IOC指値
+		return true
+	}
+	return false
+}
+
 func (e StockExecutionCondition) IsStop() bool {
 	switch e {
 	case StockExecutionConditionStop: // 逆指値
@@ -203,6 +212,78 @@ func (e StockExecutionCondition) isValid() bool {
 	return false
 }
 
+// FutureExecutionCondition - 先物執行条件
+type FutureExecutionCondition string
+
+const (
+	FutureExecutionConditionUnspecified FutureExecutionCondition = ""                        // 未指定
+	FutureExecutionConditionMO          FutureExecutionCondition = "market_order"            // 成行
+	FutureExecutionConditionLO          FutureExecutionCondition = "limit_order"             // 指値
+	FutureExecutionConditionMOC         FutureExecutionCondition = "market_order_on_closing" // 引成
+	FutureExecutionConditionLOC         FutureExecutionCondition = "limit_order_on_closing"  // 引指
+	FutureExecutionConditionStop        FutureExecutionCondition = "stop"                    // 逆指値
+)
+
+func (e FutureExecutionCondition) IsMarketOrder() bool {
+	switch e {
+	case FutureExecutionConditionMO, // 成行
+		FutureExecutionConditionMOC: // 引成
+		return true
+	}
+	return false
+}
+
+func (e FutureExecutionCondition) IsLimitOrder() bool {
+	switch e {
+	case FutureExecutionConditionLO, // 指値
+		FutureExecutionConditionLOC: // 引指
+		return true
+	}
+	return false
+}
+
+func (e FutureExecutionCondition) IsStop() bool {
+	switch e {
+	case FutureExecutionConditionStop: // 逆指値
+		return true
+	}
+	return false
+}
+
+func (e FutureExecutionCondition) IsContractableSession() bool {
+	switch e {
+	case FutureExecutionConditionMO,
+		FutureExecutionConditionLO,
+		FutureExecutionConditionStop:
+		return true
+	}
+	return false
+}
+
+func (e FutureExecutionCondition) IsContractableSessionClosing() bool {
+	switch e {
+	case FutureExecutionConditionMO,
+		FutureExecutionConditionLO,
+		FutureExecutionConditionMOC,
+		FutureExecutionConditionLOC,
+		FutureExecutionConditionStop:
+		return true
+	}
+	return false
+}
+
+func (e FutureExecutionCondition) isValid() bool {
+	switch e {
+	case FutureExecutionConditionMO,
+		FutureExecutionConditionLO,
+		FutureExecutionConditionMOC,
+		FutureExecutionConditionLOC,
+		FutureExecutionConditionStop:
+		return true
+	}
+	return false
+}
+
 // Side - 売買方向
 type Side string
 
@@ -227,6 +308,8 @@ const (
 	SessionUnspecified Session = ""          // 未指定
 	SessionMorning     Session = "morning"   // 前場
 	SessionAfternoon   Session = "afternoon" // 後場
+	SessionDay         Session = "day"       // 日中 (先物)
+	SessionNight       Session = "night"     // 夜間 (先物)
 )
 
 // PriceKind - 価格種別
@@ -287,3 +370,53 @@ func (e TradeType) isValid() bool {
 	}
 	return false
 }
+
+// ExitPositionOrder - エグジットするポジションの割り当て順序
+type ExitPositionOrder string
+
+const (
+	ExitPositionOrderUnspecified  ExitPositionOrder = ""              // 未指定 (約定日時の古い順)
+	ExitPositionOrderFIFO         ExitPositionOrder = "fifo"          // 約定日時の古い順
+	ExitPositionOrderLIFO         ExitPositionOrder = "lifo"          // 約定日時の新しい順
+	ExitPositionOrderHighestPrice ExitPositionOrder = "highest_price" // 約定価格の高い順
+)
+
+func (e ExitPositionOrder) isValid() bool {
+	switch e {
+	case ExitPositionOrderUnspecified, ExitPositionOrderFIFO, ExitPositionOrderLIFO, ExitPositionOrderHighestPrice:
+		return true
+	}
+	return false
+}
+
+// EventType - 注文やポジションの変化を通知するイベントの種別
+type EventType string
+
+const (
+	EventTypeUnspecified         EventType = ""                      // 未指定
+	EventTypeOrderAccepted       EventType = "order_accepted"        // 注文受付
+	EventTypeOrderContracted     EventType = "order_contracted"      // 全約定
+	EventTypeOrderPartContracted EventType = "order_part_contracted" // 部分約定
+	EventTypeOrderCanceled       EventType = "order_canceled"        // 取消
+	EventTypeOrderExpired        EventType = "order_expired"         // 失効
+	EventTypeOrderStopTriggered  EventType = "order_stop_triggered"  // 逆指値の発動
+	EventTypePositionOpened      EventType = "position_opened"       // ポジションの新規
+	EventTypePositionClosed      EventType = "position_closed"       // ポジションの返済
+)
+
+// TickSizeTable - 呼値の単位の表
+type TickSizeTable string
+
+const (
+	TickSizeTableUnspecified TickSizeTable = ""          // 未指定 (その他の銘柄の表)
+	TickSizeTableStandard    TickSizeTable = "standard"  // その他の銘柄
+	TickSizeTableTopix500    TickSizeTable = "topix_500" // TOPIX500構成銘柄
+)
+
+func (e TickSizeTable) isValid() bool {
+	switch e {
+	case TickSizeTableUnspecified, TickSizeTableStandard, TickSizeTableTopix500:
+		return true
+	}
+	return false
+}
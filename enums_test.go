@@ -322,6 +322,43 @@ func Test_StockExecutionCondition_IsFunari(t *testing.T) {
 	}
 }
 
+func Test_StockExecutionCondition_IsIOC(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name                    string
+		stockExecutionCondition StockExecutionCondition
+		want                    bool
+	}{
+		{name: "未指定 はIOC注文ではない", stockExecutionCondition: StockExecutionConditionUnspecified, want: false},
+		{name: "成行 はIOC注文ではない", stockExecutionCondition: StockExecutionConditionMO, want: false},
+		{name: "寄成(前場) はIOC注文ではない", stockExecutionCondition: StockExecutionConditionMOMO, want: false},
+		{name: "寄成(後場) はIOC注文ではない", stockExecutionCondition: StockExecutionConditionMOMC, want: false},
+		{name: "引成(前場) はIOC注文ではない", stockExecutionCondition: StockExecutionConditionMOAO, want: false},
+		{name: "引成(後場) はIOC注文ではない", stockExecutionCondition: StockExecutionConditionMOAC, want: false},
+		{name: "IOC成行 はIOC注文", stockExecutionCondition: StockExecutionConditionIOCMO, want: true},
+		{name: "指値 はIOC注文ではない", stockExecutionCondition: StockExecutionConditionLO, want: false},
+		{name: "寄指(前場) はIOC注文ではない", stockExecutionCondition: StockExecutionConditionLOMO, want: false},
+		{name: "寄指(後場) はIOC注文ではない", stockExecutionCondition: StockExecutionConditionLOAO, want: false},
+		{name: "引指(前場) はIOC注文ではない", stockExecutionCondition: StockExecutionConditionLOMC, want: false},
+		{name: "引指(後場) はIOC注文ではない", stockExecutionCondition: StockExecutionConditionLOAC, want: false},
+		{name: "IOC指値 はIOC注文", stockExecutionCondition: StockExecutionConditionIOCLO, want: true},
+		{name: "不成(前場) はIOC注文ではない", stockExecutionCondition: StockExecutionConditionFunariM, want: false},
+		{name: "不成(後場) はIOC注文ではない", stockExecutionCondition: StockExecutionConditionFunariA, want: false},
+		{name: "逆指値 はIOC注文ではない", stockExecutionCondition: StockExecutionConditionStop, want: false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.stockExecutionCondition.IsIOC()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
 func Test_StockExecutionCondition_IsStop(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -516,3 +553,164 @@ func Test_TradeType_isValid(t *testing.T) {
 		})
 	}
 }
+
+func Test_FutureExecutionCondition_IsContractableSession(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name                     string
+		futureExecutionCondition FutureExecutionCondition
+		want                     bool
+	}{
+		{name: "未指定 はfalse", futureExecutionCondition: FutureExecutionConditionUnspecified, want: false},
+		{name: "成行 はtrue", futureExecutionCondition: FutureExecutionConditionMO, want: true},
+		{name: "指値 はtrue", futureExecutionCondition: FutureExecutionConditionLO, want: true},
+		{name: "引成 はfalse", futureExecutionCondition: FutureExecutionConditionMOC, want: false},
+		{name: "引指 はfalse", futureExecutionCondition: FutureExecutionConditionLOC, want: false},
+		{name: "逆指値 はtrue", futureExecutionCondition: FutureExecutionConditionStop, want: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.futureExecutionCondition.IsContractableSession()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_FutureExecutionCondition_IsContractableSessionClosing(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name                     string
+		futureExecutionCondition FutureExecutionCondition
+		want                     bool
+	}{
+		{name: "未指定 はfalse", futureExecutionCondition: FutureExecutionConditionUnspecified, want: false},
+		{name: "成行 はtrue", futureExecutionCondition: FutureExecutionConditionMO, want: true},
+		{name: "指値 はtrue", futureExecutionCondition: FutureExecutionConditionLO, want: true},
+		{name: "引成 はtrue", futureExecutionCondition: FutureExecutionConditionMOC, want: true},
+		{name: "引指 はtrue", futureExecutionCondition: FutureExecutionConditionLOC, want: true},
+		{name: "逆指値 はtrue", futureExecutionCondition: FutureExecutionConditionStop, want: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.futureExecutionCondition.IsContractableSessionClosing()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_FutureExecutionCondition_IsMarketOrder(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name                     string
+		futureExecutionCondition FutureExecutionCondition
+		want                     bool
+	}{
+		{name: "未指定 はfalse", futureExecutionCondition: FutureExecutionConditionUnspecified, want: false},
+		{name: "成行 はtrue", futureExecutionCondition: FutureExecutionConditionMO, want: true},
+		{name: "指値 はfalse", futureExecutionCondition: FutureExecutionConditionLO, want: false},
+		{name: "引成 はtrue", futureExecutionCondition: FutureExecutionConditionMOC, want: true},
+		{name: "引指 はfalse", futureExecutionCondition: FutureExecutionConditionLOC, want: false},
+		{name: "逆指値 はfalse", futureExecutionCondition: FutureExecutionConditionStop, want: false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.futureExecutionCondition.IsMarketOrder()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_FutureExecutionCondition_IsLimitOrder(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name                     string
+		futureExecutionCondition FutureExecutionCondition
+		want                     bool
+	}{
+		{name: "未指定 はfalse", futureExecutionCondition: FutureExecutionConditionUnspecified, want: false},
+		{name: "成行 はfalse", futureExecutionCondition: FutureExecutionConditionMO, want: false},
+		{name: "指値 はtrue", futureExecutionCondition: FutureExecutionConditionLO, want: true},
+		{name: "引成 はfalse", futureExecutionCondition: FutureExecutionConditionMOC, want: false},
+		{name: "引指 はtrue", futureExecutionCondition: FutureExecutionConditionLOC, want: true},
+		{name: "逆指値 はfalse", futureExecutionCondition: FutureExecutionConditionStop, want: false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.futureExecutionCondition.IsLimitOrder()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_FutureExecutionCondition_isValid(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name                     string
+		futureExecutionCondition FutureExecutionCondition
+		want                     bool
+	}{
+		{name: "market_order は有効", futureExecutionCondition: FutureExecutionConditionMO, want: true},
+		{name: "limit_order は有効", futureExecutionCondition: FutureExecutionConditionLO, want: true},
+		{name: "market_order_on_closing は有効", futureExecutionCondition: FutureExecutionConditionMOC, want: true},
+		{name: "limit_order_on_closing は有効", futureExecutionCondition: FutureExecutionConditionLOC, want: true},
+		{name: "stop は有効", futureExecutionCondition: FutureExecutionConditionStop, want: true},
+		{name: "unspecified は無効", futureExecutionCondition: FutureExecutionConditionUnspecified, want: false},
+		{name: "foo は無効", futureExecutionCondition: FutureExecutionCondition("foo"), want: false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.futureExecutionCondition.isValid()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_TickSizeTable_isValid(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		tickSizeTable TickSizeTable
+		want          bool
+	}{
+		{name: "unspecified は有効", tickSizeTable: TickSizeTableUnspecified, want: true},
+		{name: "standard は有効", tickSizeTable: TickSizeTableStandard, want: true},
+		{name: "topix_500 は有効", tickSizeTable: TickSizeTableTopix500, want: true},
+		{name: "foo は無効", tickSizeTable: TickSizeTable("foo"), want: false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.tickSizeTable.isValid()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
@@ -22,4 +22,27 @@ var (
 	InvalidExitPositionError       = errors.New("invalid exit position error")
 	InvalidExitQuantityError       = errors.New("invalid exit quantity error")
 	InvalidExitPositionCodeError   = errors.New("invalid exit position code error")
+	InvalidAmountError             = errors.New("invalid amount error")
+	NotEnoughCashError             = errors.New("not enough cash error")
+	NotEnoughBuyingPowerError      = errors.New("not enough buying power error")
+	NotEnoughMarginError           = errors.New("not enough margin error")
+	InvalidExitPositionOrderError  = errors.New("invalid exit position order error")
+	InvalidBoardError              = errors.New("invalid board error")
+	UnregisteredSymbolError        = errors.New("unregistered symbol error")
+	ExpiredSymbolError             = errors.New("expired symbol error")
+	InvalidMultiplierError         = errors.New("invalid multiplier error")
+	InvalidPriceError              = errors.New("invalid price error")
+	InvalidIntervalError           = errors.New("invalid interval error")
+	RunningSchedulerError          = errors.New("running scheduler error")
+	InvalidReplayDataError         = errors.New("invalid replay data error")
+	InvalidTickSizeError           = errors.New("invalid tick size error")
+	InvalidTickSizeTableError      = errors.New("invalid tick size table error")
+	InvalidBasePriceError          = errors.New("invalid base price error")
+	PriceLimitExceededError        = errors.New("price limit exceeded error")
+	UnmodifiableOrderError         = errors.New("unmodifiable order error")
+	InvalidModificationError       = errors.New("invalid modification error")
+	InvalidSnapshotError           = errors.New("invalid snapshot error")
+	InvalidHolidayError            = errors.New("invalid holiday error")
+	UnsupportedSecurityError       = errors.New("unsupported security error")
+	PersistError                   = errors.New("persist error")
 )
@@ -0,0 +1,223 @@
+package virtual_security
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+func newEventService() iEventService {
+	return &eventService{}
+}
+
+type iEventService interface {
+	subscribe(handler EventHandler) (func(), error)
+	isSubscribed() bool
+	publish(before *tradingState, after *tradingState, now time.Time)
+}
+
+type eventService struct {
+	subscribers []*eventSubscriber
+	lastID      int
+	mtx         sync.Mutex
+}
+
+// eventSubscriber - イベントの購読者
+type eventSubscriber struct {
+	id      int
+	handler EventHandler
+}
+
+// tradingState - イベントの判定に使う、ある時点の注文とポジションの状態
+type tradingState struct {
+	orders    []*orderState
+	positions []*positionState
+}
+
+// orderState - イベントの判定に使う注文の状態
+type orderState struct {
+	exchangeType    ExchangeType
+	code            string
+	symbolCode      string
+	status          OrderStatus
+	orderQuantity   float64
+	contracts       []*Contract
+	isStopActivated bool
+	isExpired       bool
+	orderedAt       time.Time
+}
+
+// positionState - イベントの判定に使うポジションの状態
+type positionState struct {
+	exchangeType  ExchangeType
+	code          string
+	orderCode     string
+	symbolCode    string
+	ownedQuantity float64
+	contractedAt  time.Time
+}
+
+// sort - 通知する順番が変わらないように、注文は注文日時、ポジションは約定日時の順に並べる
+func (s *tradingState) sort() {
+	sort.SliceStable(s.orders, func(i, j int) bool {
+		if !s.orders[i].orderedAt.Equal(s.orders[j].orderedAt) {
+			return s.orders[i].orderedAt.Before(s.orders[j].orderedAt)
+		}
+		return s.orders[i].code < s.orders[j].code
+	})
+	sort.SliceStable(s.positions, func(i, j int) bool {
+		if !s.positions[i].contractedAt.Equal(s.positions[j].contractedAt) {
+			return s.positions[i].contractedAt.Before(s.positions[j].contractedAt)
+		}
+		return s.positions[i].code < s.positions[j].code
+	})
+}
+
+// subscribe - イベントを受け取るハンドラを登録し、登録を解除する関数を返す
+func (s *eventService) subscribe(handler EventHandler) (func(), error) {
+	if handler == nil {
+		return nil, NilArgumentError
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.lastID++
+	id := s.lastID
+	s.subscribers = append(s.subscribers, &eventSubscriber{id: id, handler: handler})
+
+	return func() { s.unsubscribe(id) }, nil
+}
+
+func (s *eventService) unsubscribe(id int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for i, sub := range s.subscribers {
+		if sub.id == id {
+			s.subscribers = append(s.subscribers[:i:i], s.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// isSubscribed - 購読者がいるか
+//   購読者がいなければ、状態を記録する必要もない
+func (s *eventService) isSubscribed() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return len(s.subscribers) > 0
+}
+
+// publish - 処理前後の状態を比べて、変化をイベントとして購読者に通知する
+//   ハンドラの中から仮想証券会社を操作できるように、ロックを取らずにハンドラを呼び出す
+func (s *eventService) publish(before *tradingState, after *tradingState, now time.Time) {
+	if before == nil || after == nil {
+		return
+	}
+
+	events := s.events(before, after, now)
+	if len(events) == 0 {
+		return
+	}
+
+	s.mtx.Lock()
+	subscribers := make([]*eventSubscriber, len(s.subscribers))
+	copy(subscribers, s.subscribers)
+	s.mtx.Unlock()
+
+	for _, e := range events {
+		for _, sub := range subscribers {
+			sub.handler(e)
+		}
+	}
+}
+
+// events - 処理前後の状態の差分からイベントを作る
+//   処理後になくなった注文やポジションは、一覧から削除されただけなのでイベントにしない
+func (s *eventService) events(before *tradingState, after *tradingState, now time.Time) []Event {
+	var events []Event
+
+	beforeOrders := make(map[string]*orderState, len(before.orders))
+	for _, o := range before.orders {
+		beforeOrders[o.code] = o
+	}
+	for _, o := range after.orders {
+		b, ok := beforeOrders[o.code]
+		if !ok {
+			events = append(events, s.orderEvent(EventTypeOrderAccepted, o, nil, now))
+			b = &orderState{}
+		}
+
+		if !b.isStopActivated && o.isStopActivated {
+			events = append(events, s.orderEvent(EventTypeOrderStopTriggered, o, nil, now))
+		}
+
+		// 約定ごとに、約定数量の累計が注文数量に達していれば全約定、達していなければ部分約定にする
+		var contracted float64
+		for i, c := range o.contracts {
+			contracted += c.Quantity
+			if i < len(b.contracts) {
+				continue
+			}
+			eventType := EventTypeOrderPartContracted
+			if contracted >= o.orderQuantity {
+				eventType = EventTypeOrderContracted
+			}
+			events = append(events, s.orderEvent(eventType, o, c, now))
+		}
+
+		if b.status != OrderStatusCanceled && o.status == OrderStatusCanceled {
+			eventType := EventTypeOrderCanceled
+			if o.isExpired {
+				eventType = EventTypeOrderExpired
+			}
+			events = append(events, s.orderEvent(eventType, o, nil, now))
+		}
+	}
+
+	beforePositions := make(map[string]*positionState, len(before.positions))
+	for _, p := range before.positions {
+		beforePositions[p.code] = p
+	}
+	for _, p := range after.positions {
+		b, ok := beforePositions[p.code]
+		if !ok && p.ownedQuantity > 0 {
+			events = append(events, s.positionEvent(EventTypePositionOpened, p, now))
+		}
+		if ok && b.ownedQuantity > 0 && p.ownedQuantity <= 0 {
+			events = append(events, s.positionEvent(EventTypePositionClosed, p, now))
+		}
+	}
+
+	return events
+}
+
+func (s *eventService) orderEvent(eventType EventType, order *orderState, contract *Contract, now time.Time) Event {
+	event := Event{
+		EventType:    eventType,
+		ExchangeType: order.exchangeType,
+		SymbolCode:   order.symbolCode,
+		OrderCode:    order.code,
+		OrderStatus:  order.status,
+		Contract:     contract,
+		OccurredAt:   now,
+	}
+	if contract != nil {
+		event.PositionCode = contract.PositionCode
+		event.OccurredAt = contract.ContractedAt
+	}
+	return event
+}
+
+func (s *eventService) positionEvent(eventType EventType, position *positionState, now time.Time) Event {
+	return Event{
+		EventType:    eventType,
+		ExchangeType: position.exchangeType,
+		SymbolCode:   position.symbolCode,
+		OrderCode:    position.orderCode,
+		PositionCode: position.code,
+		OccurredAt:   now,
+	}
+}
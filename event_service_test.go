@@ -0,0 +1,215 @@
+package virtual_security
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testEventService struct {
+	iEventService
+	subscribe1     func()
+	subscribe2     error
+	isSubscribed1  bool
+	publishHistory []*tradingState
+}
+
+func (t *testEventService) subscribe(EventHandler) (func(), error) {
+	return t.subscribe1, t.subscribe2
+}
+func (t *testEventService) isSubscribed() bool { return t.isSubscribed1 }
+func (t *testEventService) publish(_ *tradingState, after *tradingState, _ time.Time) {
+	t.publishHistory = append(t.publishHistory, after)
+}
+
+func Test_newEventService(t *testing.T) {
+	t.Parallel()
+	want := &eventService{}
+	got := newEventService()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_eventService_subscribe(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ハンドラがnilならエラー", func(t *testing.T) {
+		t.Parallel()
+		service := &eventService{}
+		got1, got2 := service.subscribe(nil)
+		if got1 != nil || !errors.Is(got2, NilArgumentError) || service.isSubscribed() {
+			t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(), true, NilArgumentError, false, got1 == nil, got2, service.isSubscribed())
+		}
+	})
+
+	t.Run("登録したハンドラは解除する関数を呼ぶまで購読者になる", func(t *testing.T) {
+		t.Parallel()
+		service := &eventService{}
+		unsubscribe1, _ := service.subscribe(func(Event) {})
+		unsubscribe2, _ := service.subscribe(func(Event) {})
+		if !service.isSubscribed() || len(service.subscribers) != 2 {
+			t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 2, len(service.subscribers))
+		}
+
+		unsubscribe1()
+		unsubscribe1()
+		if !service.isSubscribed() || len(service.subscribers) != 1 || service.subscribers[0].id != 2 {
+			t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 1, len(service.subscribers))
+		}
+
+		unsubscribe2()
+		if service.isSubscribed() {
+			t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), false, service.isSubscribed())
+		}
+	})
+}
+
+func Test_eventService_publish(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local)
+	before := &tradingState{}
+	after := &tradingState{orders: []*orderState{{exchangeType: ExchangeTypeStock, code: "sor-01", symbolCode: "1234", status: OrderStatusInOrder, orderQuantity: 100}}}
+
+	t.Run("状態がnilなら通知しない", func(t *testing.T) {
+		t.Parallel()
+		var got []Event
+		service := &eventService{}
+		_, _ = service.subscribe(func(e Event) { got = append(got, e) })
+		service.publish(nil, after, now)
+		if got != nil {
+			t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), nil, got)
+		}
+	})
+
+	t.Run("すべての購読者に登録順にイベントを通知する", func(t *testing.T) {
+		t.Parallel()
+		var got []string
+		service := &eventService{}
+		_, _ = service.subscribe(func(e Event) { got = append(got, "1:"+e.OrderCode) })
+		_, _ = service.subscribe(func(e Event) { got = append(got, "2:"+e.OrderCode) })
+		service.publish(before, after, now)
+		want := []string{"1:sor-01", "2:sor-01"}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+		}
+	})
+
+	t.Run("ハンドラの中で購読を解除できる", func(t *testing.T) {
+		t.Parallel()
+		var count int
+		service := &eventService{}
+		var unsubscribe func()
+		unsubscribe, _ = service.subscribe(func(Event) {
+			count++
+			unsubscribe()
+		})
+		service.publish(before, after, now)
+		service.publish(before, after, now)
+		if count != 1 {
+			t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 1, count)
+		}
+	})
+}
+
+func Test_eventService_events(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local)
+	contractedAt := time.Date(2021, 9, 1, 9, 59, 0, 0, time.Local)
+	tests := []struct {
+		name   string
+		before *tradingState
+		after  *tradingState
+		want   []Event
+	}{
+		{name: "変化がなければイベントはない",
+			before: &tradingState{
+				orders:    []*orderState{{code: "sor-01", status: OrderStatusInOrder, orderQuantity: 100}},
+				positions: []*positionState{{code: "spo-01", ownedQuantity: 100}}},
+			after: &tradingState{
+				orders:    []*orderState{{code: "sor-01", status: OrderStatusInOrder, orderQuantity: 100}},
+				positions: []*positionState{{code: "spo-01", ownedQuantity: 100}}},
+			want: nil},
+		{name: "新しい注文は受付になる",
+			before: &tradingState{},
+			after:  &tradingState{orders: []*orderState{{exchangeType: ExchangeTypeMargin, code: "mor-01", symbolCode: "1234", status: OrderStatusInOrder, orderQuantity: 100}}},
+			want:   []Event{{EventType: EventTypeOrderAccepted, ExchangeType: ExchangeTypeMargin, SymbolCode: "1234", OrderCode: "mor-01", OrderStatus: OrderStatusInOrder, OccurredAt: now}}},
+		{name: "逆指値の発動は発動になる",
+			before: &tradingState{orders: []*orderState{{code: "sor-01", symbolCode: "1234", status: OrderStatusWait, orderQuantity: 100}}},
+			after:  &tradingState{orders: []*orderState{{code: "sor-01", symbolCode: "1234", status: OrderStatusInOrder, orderQuantity: 100, isStopActivated: true}}},
+			want:   []Event{{EventType: EventTypeOrderStopTriggered, SymbolCode: "1234", OrderCode: "sor-01", OrderStatus: OrderStatusInOrder, OccurredAt: now}}},
+		{name: "新しい約定は、約定数量の累計が注文数量に達するまで部分約定、達したら全約定になる",
+			before: &tradingState{orders: []*orderState{{code: "sor-01", status: OrderStatusPart, orderQuantity: 300,
+				contracts: []*Contract{{ContractCode: "sco-01", Quantity: 100}}}}},
+			after: &tradingState{orders: []*orderState{{code: "sor-01", status: OrderStatusDone, orderQuantity: 300,
+				contracts: []*Contract{{ContractCode: "sco-01", Quantity: 100}, {ContractCode: "sco-02", PositionCode: "spo-02", Quantity: 100, ContractedAt: contractedAt}, {ContractCode: "sco-03", PositionCode: "spo-03", Quantity: 100, ContractedAt: contractedAt}}}}},
+			want: []Event{
+				{EventType: EventTypeOrderPartContracted, OrderCode: "sor-01", OrderStatus: OrderStatusDone, PositionCode: "spo-02", Contract: &Contract{ContractCode: "sco-02", PositionCode: "spo-02", Quantity: 100, ContractedAt: contractedAt}, OccurredAt: contractedAt},
+				{EventType: EventTypeOrderContracted, OrderCode: "sor-01", OrderStatus: OrderStatusDone, PositionCode: "spo-03", Contract: &Contract{ContractCode: "sco-03", PositionCode: "spo-03", Quantity: 100, ContractedAt: contractedAt}, OccurredAt: contractedAt}}},
+		{name: "取消は取消になる",
+			before: &tradingState{orders: []*orderState{{code: "sor-01", status: OrderStatusInOrder, orderQuantity: 100}}},
+			after:  &tradingState{orders: []*orderState{{code: "sor-01", status: OrderStatusCanceled, orderQuantity: 100}}},
+			want:   []Event{{EventType: EventTypeOrderCanceled, OrderCode: "sor-01", OrderStatus: OrderStatusCanceled, OccurredAt: now}}},
+		{name: "有効期限切れによる取消は失効になる",
+			before: &tradingState{orders: []*orderState{{code: "sor-01", status: OrderStatusInOrder, orderQuantity: 100}}},
+			after:  &tradingState{orders: []*orderState{{code: "sor-01", status: OrderStatusCanceled, orderQuantity: 100, isExpired: true}}},
+			want:   []Event{{EventType: EventTypeOrderExpired, OrderCode: "sor-01", OrderStatus: OrderStatusCanceled, OccurredAt: now}}},
+		{name: "取消済みの注文は取消にならない",
+			before: &tradingState{orders: []*orderState{{code: "sor-01", status: OrderStatusCanceled, orderQuantity: 100}}},
+			after:  &tradingState{orders: []*orderState{{code: "sor-01", status: OrderStatusCanceled, orderQuantity: 100, isExpired: true}}},
+			want:   nil},
+		{name: "新しいポジションは新規、保有数量がなくなったポジションは返済になる",
+			before: &tradingState{positions: []*positionState{{exchangeType: ExchangeTypeStock, code: "spo-01", orderCode: "sor-01", symbolCode: "1234", ownedQuantity: 100}}},
+			after: &tradingState{positions: []*positionState{
+				{exchangeType: ExchangeTypeStock, code: "spo-01", orderCode: "sor-01", symbolCode: "1234", ownedQuantity: 0},
+				{exchangeType: ExchangeTypeStock, code: "spo-02", orderCode: "sor-02", symbolCode: "1234", ownedQuantity: 100}}},
+			want: []Event{
+				{EventType: EventTypePositionClosed, ExchangeType: ExchangeTypeStock, SymbolCode: "1234", OrderCode: "sor-01", PositionCode: "spo-01", OccurredAt: now},
+				{EventType: EventTypePositionOpened, ExchangeType: ExchangeTypeStock, SymbolCode: "1234", OrderCode: "sor-02", PositionCode: "spo-02", OccurredAt: now}}},
+		{name: "一覧から削除された注文やポジションはイベントにならない",
+			before: &tradingState{
+				orders:    []*orderState{{code: "sor-01", status: OrderStatusDone, orderQuantity: 100}},
+				positions: []*positionState{{code: "spo-01", ownedQuantity: 100}}},
+			after: &tradingState{},
+			want:  nil},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := &eventService{}
+			got := service.events(test.before, test.after, now)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_tradingState_sort(t *testing.T) {
+	t.Parallel()
+	state := &tradingState{
+		orders: []*orderState{
+			{code: "sor-03", orderedAt: time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local)},
+			{code: "sor-02", orderedAt: time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local)},
+			{code: "sor-01", orderedAt: time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local)}},
+		positions: []*positionState{
+			{code: "spo-02", contractedAt: time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local)},
+			{code: "spo-01", contractedAt: time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local)}},
+	}
+	state.sort()
+
+	var got []string
+	for _, o := range state.orders {
+		got = append(got, o.code)
+	}
+	for _, p := range state.positions {
+		got = append(got, p.code)
+	}
+	want := []string{"sor-02", "sor-01", "sor-03", "spo-01", "spo-02"}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
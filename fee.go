@@ -0,0 +1,101 @@
+package virtual_security
+
+import "time"
+
+// CapitalGainsTaxRate - 上場株式等や先物取引の譲渡益にかかる税率 (所得税15.315%と住民税5%)
+const CapitalGainsTaxRate = 0.20315
+
+// FeeSchedule - 約定ごとの手数料の計算方法
+//   WithFeeScheduleで市場種別ごとに指定し、指定しなかった市場種別の手数料は0になる
+type FeeSchedule interface {
+	Fee(target FeeTarget) float64
+}
+
+// FeeTarget - 手数料を計算する約定の情報
+type FeeTarget struct {
+	ExchangeType ExchangeType // 市場種別
+	SymbolCode   string       // 銘柄コード
+	Side         Side         // 売買方向
+	TradeType    TradeType    // 取引区分 (現物は買いが新規、売りが返済)
+	Price        float64      // 約定価格
+	Quantity     float64      // 約定数量
+	Amount       float64      // 約定代金 (約定価格 × 約定数量 × 取引単位)
+	DailyAmount  float64      // この約定より前の、同じ市場種別の同じ営業日の約定代金の合計
+	ContractedAt time.Time    // 約定日時
+}
+
+// FeeFunc - 関数をFeeScheduleとして使うための型
+type FeeFunc func(target FeeTarget) float64
+
+// Fee - 関数で手数料を計算する
+func (f FeeFunc) Fee(target FeeTarget) float64 {
+	return f(target)
+}
+
+// FlatFee - 約定代金に関わらず、1約定ごとに決まった手数料がかかるプラン
+type FlatFee float64
+
+// Fee - 1約定ごとの手数料
+func (f FlatFee) Fee(FeeTarget) float64 {
+	return float64(f)
+}
+
+// FeeTier - 約定代金ごとの手数料の段階
+type FeeTier struct {
+	MaxAmount float64 // この段階の約定代金の上限 (0なら上限なし)
+	Fee       float64 // 手数料
+}
+
+// TieredFee - 1約定ごとの約定代金に応じて手数料が決まるプラン
+//   段階は上限の小さい順に並べ、約定代金が上限以下になる最初の段階の手数料を使う
+type TieredFee []FeeTier
+
+// Fee - 約定代金に応じた手数料
+func (f TieredFee) Fee(target FeeTarget) float64 {
+	return tierFee(f, target.Amount)
+}
+
+// DailyFee - 1日の約定代金の合計に応じて手数料が決まる定額プラン
+//   約定ごとに、その約定を含めた1日の手数料から、その約定の前までの1日の手数料を引いた差額を手数料にする
+//   そのため、1日の約定の手数料を合計すると、プランの手数料になる
+type DailyFee []FeeTier
+
+// Fee - 1日の手数料のうち、この約定で増えた分
+func (f DailyFee) Fee(target FeeTarget) float64 {
+	var before float64
+	if target.DailyAmount > 0 {
+		before = tierFee(f, target.DailyAmount)
+	}
+	return tierFee(f, target.DailyAmount+target.Amount) - before
+}
+
+// tierFee - 約定代金が上限以下になる最初の段階の手数料
+//   どの段階にも当てはまらなければ最後の段階の手数料を使う
+func tierFee(tiers []FeeTier, amount float64) float64 {
+	if len(tiers) == 0 {
+		return 0
+	}
+	for _, t := range tiers {
+		if t.MaxAmount <= 0 || amount <= t.MaxAmount {
+			return t.Fee
+		}
+	}
+	return tiers[len(tiers)-1].Fee
+}
+
+// newFeeTarget - 約定から手数料を計算するための情報を作る
+func newFeeTarget(exchangeType ExchangeType, symbolCode string, side Side, tradeType TradeType, multiplier float64, contract *Contract) FeeTarget {
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+	return FeeTarget{
+		ExchangeType: exchangeType,
+		SymbolCode:   symbolCode,
+		Side:         side,
+		TradeType:    tradeType,
+		Price:        contract.Price,
+		Quantity:     contract.Quantity,
+		Amount:       contract.Price * contract.Quantity * multiplier,
+		ContractedAt: contract.ContractedAt,
+	}
+}
@@ -0,0 +1,130 @@
+package virtual_security
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_FeeFunc_Fee(t *testing.T) {
+	t.Parallel()
+	fee := FeeFunc(func(target FeeTarget) float64 { return target.Quantity * 10 })
+	want := 30.0
+	got := fee.Fee(FeeTarget{Quantity: 3})
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_FlatFee_Fee(t *testing.T) {
+	t.Parallel()
+	want := 55.0
+	got := FlatFee(55).Fee(FeeTarget{Amount: 10_000_000})
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_TieredFee_Fee(t *testing.T) {
+	t.Parallel()
+	fee := TieredFee{{MaxAmount: 50_000, Fee: 55}, {MaxAmount: 100_000, Fee: 99}, {MaxAmount: 200_000, Fee: 115}, {Fee: 275}}
+	tests := []struct {
+		name string
+		arg  float64
+		want float64
+	}{
+		{name: "最初の段階の上限以下なら最初の段階の手数料", arg: 50_000, want: 55},
+		{name: "上限を超えたら次の段階の手数料", arg: 50_001, want: 99},
+		{name: "上限のない段階はすべての約定代金に当てはまる", arg: 10_000_000, want: 275},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := fee.Fee(FeeTarget{Amount: test.arg})
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_DailyFee_Fee(t *testing.T) {
+	t.Parallel()
+	fee := DailyFee{{MaxAmount: 1_000_000, Fee: 0}, {MaxAmount: 2_000_000, Fee: 1_278}, {MaxAmount: 3_000_000, Fee: 1_718}}
+	tests := []struct {
+		name  string
+		daily float64
+		arg   float64
+		want  float64
+	}{
+		{name: "1日の合計が最初の段階に収まれば最初の段階の手数料", daily: 0, arg: 500_000, want: 0},
+		{name: "1日の合計が次の段階に上がったら差額", daily: 800_000, arg: 500_000, want: 1_278},
+		{name: "1日の合計の段階が変わらなければ0", daily: 1_300_000, arg: 500_000, want: 0},
+		{name: "段階を飛び越えたら飛び越えた分の差額", daily: 500_000, arg: 2_000_000, want: 1_718},
+		{name: "最後の段階を超えたら最後の段階の手数料のまま", daily: 3_000_000, arg: 500_000, want: 0},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := fee.Fee(FeeTarget{Amount: test.arg, DailyAmount: test.daily})
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_tierFee(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		tiers []FeeTier
+		arg   float64
+		want  float64
+	}{
+		{name: "段階がなければ0", tiers: nil, arg: 100, want: 0},
+		{name: "当てはまる段階がなければ最後の段階", tiers: []FeeTier{{MaxAmount: 100, Fee: 10}, {MaxAmount: 200, Fee: 20}}, arg: 300, want: 20},
+		{name: "上限ちょうどはその段階", tiers: []FeeTier{{MaxAmount: 100, Fee: 10}, {MaxAmount: 200, Fee: 20}}, arg: 100, want: 10},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := tierFee(test.tiers, test.arg)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_newFeeTarget(t *testing.T) {
+	t.Parallel()
+	contractedAt := time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		name       string
+		multiplier float64
+		want       FeeTarget
+	}{
+		{name: "取引単位を掛けて約定代金を出す", multiplier: 1000,
+			want: FeeTarget{ExchangeType: ExchangeTypeFuture, SymbolCode: "167090019", Side: SideBuy, TradeType: TradeTypeEntry, Price: 28000, Quantity: 2, Amount: 56_000_000, ContractedAt: contractedAt}},
+		{name: "取引単位がなければ1として扱う", multiplier: 0,
+			want: FeeTarget{ExchangeType: ExchangeTypeFuture, SymbolCode: "167090019", Side: SideBuy, TradeType: TradeTypeEntry, Price: 28000, Quantity: 2, Amount: 56_000, ContractedAt: contractedAt}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := newFeeTarget(ExchangeTypeFuture, "167090019", SideBuy, TradeTypeEntry, test.multiplier, &Contract{Price: 28000, Quantity: 2, ContractedAt: contractedAt})
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
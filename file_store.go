@@ -0,0 +1,426 @@
+package virtual_security
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// iPersistentStore - 中身をファイルに書き出して永続化するストアのインターフェース
+type iPersistentStore interface {
+	persist() error
+}
+
+// fileStore - ストアの中身をJSONのファイルに読み書きする
+//   戻り値のない保存や削除の中で書き出せなかったエラーは、lastErrorで見られるように残し、仮想証券会社の処理の最後のpersistで返す
+type fileStore struct {
+	path string
+	mtx  sync.Mutex
+	err  error // 最後に書き出せなかったエラー
+}
+
+// load - ファイルの中身を読み込む (ファイルがなければ何もしない)
+func (f *fileStore) load(v interface{}) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	b, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// write - ファイルに書き出す
+//   書き出しの途中で止まっても前の中身が壊れないように、一時ファイルに書き出してから置き換える
+//   書き出せなかったエラーは次に書き出せるまで残しておき、lastErrorで取り出せるようにする
+func (f *fileStore) write(v interface{}) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.err = f.writeFile(v)
+	return f.err
+}
+
+func (f *fileStore) writeFile(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+// lastError - 最後に書き出せなかったエラー (最後の書き出しが成功していればnil)
+func (f *fileStore) lastError() error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	return f.err
+}
+
+// newFileStockOrderStore - ファイルに書き出す現物注文ストアの生成
+func newFileStockOrderStore(path string) (*fileStockOrderStore, error) {
+	store := &fileStockOrderStore{iStockOrderStore: newStockOrderStore(), file: &fileStore{path: path}}
+	var orders []*stockOrder
+	if err := store.file.load(&orders); err != nil {
+		return nil, fmt.Errorf("cannot load stock orders(path: %s): %w", path, err)
+	}
+	for _, o := range orders {
+		if o == nil {
+			continue
+		}
+		o.StopCondition.restoreActivation()
+		store.iStockOrderStore.save(o)
+	}
+	return store, nil
+}
+
+// fileStockOrderStore - 注文を保存するたびにファイルに書き出す現物注文ストア
+//   約定などで注文が変わってもストアには保存し直さないので、仮想証券会社の処理のたびにpersistで書き出す
+type fileStockOrderStore struct {
+	iStockOrderStore
+	file *fileStore
+}
+
+func (s *fileStockOrderStore) save(stockOrder *stockOrder) {
+	s.iStockOrderStore.save(stockOrder)
+	s.persist()
+}
+
+func (s *fileStockOrderStore) removeByCode(code string) {
+	s.iStockOrderStore.removeByCode(code)
+	s.persist()
+}
+
+func (s *fileStockOrderStore) persist() error {
+	return s.file.write(s.getAll())
+}
+
+// newFileStockPositionStore - ファイルに書き出す現物ポジションストアの生成
+func newFileStockPositionStore(path string) (*fileStockPositionStore, error) {
+	store := &fileStockPositionStore{iStockPositionStore: newStockPositionStore(), file: &fileStore{path: path}}
+	var positions []*stockPosition
+	if err := store.file.load(&positions); err != nil {
+		return nil, fmt.Errorf("cannot load stock positions(path: %s): %w", path, err)
+	}
+	for _, p := range positions {
+		store.iStockPositionStore.save(p)
+	}
+	return store, nil
+}
+
+// fileStockPositionStore - ポジションを保存するたびにファイルに書き出す現物ポジションストア
+type fileStockPositionStore struct {
+	iStockPositionStore
+	file *fileStore
+}
+
+func (s *fileStockPositionStore) save(stockPosition *stockPosition) {
+	s.iStockPositionStore.save(stockPosition)
+	s.persist()
+}
+
+func (s *fileStockPositionStore) removeByCode(code string) {
+	s.iStockPositionStore.removeByCode(code)
+	s.persist()
+}
+
+func (s *fileStockPositionStore) persist() error {
+	return s.file.write(s.getAll())
+}
+
+// newFileMarginOrderStore - ファイルに書き出す信用注文ストアの生成
+func newFileMarginOrderStore(path string) (*fileMarginOrderStore, error) {
+	store := &fileMarginOrderStore{iMarginOrderStore: newMarginOrderStore(), file: &fileStore{path: path}}
+	var orders []*marginOrder
+	if err := store.file.load(&orders); err != nil {
+		return nil, fmt.Errorf("cannot load margin orders(path: %s): %w", path, err)
+	}
+	for _, o := range orders {
+		if o == nil {
+			continue
+		}
+		o.StopCondition.restoreActivation()
+		store.iMarginOrderStore.save(o)
+	}
+	return store, nil
+}
+
+// fileMarginOrderStore - 注文を保存するたびにファイルに書き出す信用注文ストア
+type fileMarginOrderStore struct {
+	iMarginOrderStore
+	file *fileStore
+}
+
+func (s *fileMarginOrderStore) save(marginOrder *marginOrder) {
+	s.iMarginOrderStore.save(marginOrder)
+	s.persist()
+}
+
+func (s *fileMarginOrderStore) removeByCode(code string) {
+	s.iMarginOrderStore.removeByCode(code)
+	s.persist()
+}
+
+func (s *fileMarginOrderStore) persist() error {
+	return s.file.write(s.getAll())
+}
+
+// newFileMarginPositionStore - ファイルに書き出す信用ポジションストアの生成
+func newFileMarginPositionStore(path string) (*fileMarginPositionStore, error) {
+	store := &fileMarginPositionStore{iMarginPositionStore: newMarginPositionStore(), file: &fileStore{path: path}}
+	var positions []*marginPosition
+	if err := store.file.load(&positions); err != nil {
+		return nil, fmt.Errorf("cannot load margin positions(path: %s): %w", path, err)
+	}
+	for _, p := range positions {
+		store.iMarginPositionStore.save(p)
+	}
+	return store, nil
+}
+
+// fileMarginPositionStore - ポジションを保存するたびにファイルに書き出す信用ポジションストア
+type fileMarginPositionStore struct {
+	iMarginPositionStore
+	file *fileStore
+}
+
+func (s *fileMarginPositionStore) save(marginPosition *marginPosition) {
+	s.iMarginPositionStore.save(marginPosition)
+	s.persist()
+}
+
+func (s *fileMarginPositionStore) removeByCode(code string) {
+	s.iMarginPositionStore.removeByCode(code)
+	s.persist()
+}
+
+func (s *fileMarginPositionStore) persist() error {
+	return s.file.write(s.getAll())
+}
+
+// newFilePriceStore - ファイルに書き出す価格ストアの生成
+//   読み込んだ価格の有効期限は、価格の日時から決め直す
+func newFilePriceStore(clock iClock, path string) (*filePriceStore, error) {
+	store := &filePriceStore{iPriceStore: newPriceStore(clock), file: &fileStore{path: path}}
+	var prices []*storedPrice
+	if err := store.file.load(&prices); err != nil {
+		return nil, fmt.Errorf("cannot load prices(path: %s): %w", path, err)
+	}
+	if len(prices) > 0 {
+		store.iPriceStore.restore(toSymbolPrices(prices))
+	}
+	return store, nil
+}
+
+// filePriceStore - 価格を登録するたびにファイルに書き出す価格ストア
+type filePriceStore struct {
+	iPriceStore
+	file *fileStore
+}
+
+func (s *filePriceStore) set(price *symbolPrice) error {
+	if err := s.iPriceStore.set(price); err != nil {
+		return err
+	}
+	return s.persist()
+}
+
+func (s *filePriceStore) restore(prices []*symbolPrice) {
+	s.iPriceStore.restore(prices)
+	s.persist()
+}
+
+func (s *filePriceStore) persist() error {
+	return s.file.write(newStoredPrices(s.getAll()))
+}
+
+// newFileStockSymbolStore - ファイルに書き出す株式銘柄ストアの生成
+func newFileStockSymbolStore(path string) (*fileStockSymbolStore, error) {
+	store := &fileStockSymbolStore{iStockSymbolStore: newStockSymbolStore(), file: &fileStore{path: path}}
+	var symbols []*stockSymbol
+	if err := store.file.load(&symbols); err != nil {
+		return nil, fmt.Errorf("cannot load stock symbols(path: %s): %w", path, err)
+	}
+	for _, s := range symbols {
+		if s == nil {
+			continue
+		}
+		store.iStockSymbolStore.save(s)
+	}
+	return store, nil
+}
+
+// fileStockSymbolStore - 銘柄を保存するたびにファイルに書き出す株式銘柄ストア
+//   価格の登録で基準値段が変わってもストアには保存し直さないので、仮想証券会社の処理のたびにpersistで書き出す
+type fileStockSymbolStore struct {
+	iStockSymbolStore
+	file *fileStore
+}
+
+func (s *fileStockSymbolStore) save(stockSymbol *stockSymbol) {
+	s.iStockSymbolStore.save(stockSymbol)
+	s.persist()
+}
+
+func (s *fileStockSymbolStore) persist() error {
+	return s.file.write(s.getAll())
+}
+
+// newFileFutureOrderStore - ファイルに書き出す先物注文ストアの生成
+func newFileFutureOrderStore(path string) (*fileFutureOrderStore, error) {
+	store := &fileFutureOrderStore{iFutureOrderStore: newFutureOrderStore(), file: &fileStore{path: path}}
+	var orders []*futureOrder
+	if err := store.file.load(&orders); err != nil {
+		return nil, fmt.Errorf("cannot load future orders(path: %s): %w", path, err)
+	}
+	for _, o := range orders {
+		if o == nil {
+			continue
+		}
+		o.StopCondition.restoreActivation()
+		store.iFutureOrderStore.save(o)
+	}
+	return store, nil
+}
+
+// fileFutureOrderStore - 注文を保存するたびにファイルに書き出す先物注文ストア
+type fileFutureOrderStore struct {
+	iFutureOrderStore
+	file *fileStore
+}
+
+func (s *fileFutureOrderStore) save(futureOrder *futureOrder) {
+	s.iFutureOrderStore.save(futureOrder)
+	s.persist()
+}
+
+func (s *fileFutureOrderStore) removeByCode(code string) {
+	s.iFutureOrderStore.removeByCode(code)
+	s.persist()
+}
+
+func (s *fileFutureOrderStore) persist() error {
+	return s.file.write(s.getAll())
+}
+
+// newFileFuturePositionStore - ファイルに書き出す先物ポジションストアの生成
+func newFileFuturePositionStore(path string) (*fileFuturePositionStore, error) {
+	store := &fileFuturePositionStore{iFuturePositionStore: newFuturePositionStore(), file: &fileStore{path: path}}
+	var positions []*futurePosition
+	if err := store.file.load(&positions); err != nil {
+		return nil, fmt.Errorf("cannot load future positions(path: %s): %w", path, err)
+	}
+	for _, p := range positions {
+		store.iFuturePositionStore.save(p)
+	}
+	return store, nil
+}
+
+// fileFuturePositionStore - ポジションを保存するたびにファイルに書き出す先物ポジションストア
+type fileFuturePositionStore struct {
+	iFuturePositionStore
+	file *fileStore
+}
+
+func (s *fileFuturePositionStore) save(futurePosition *futurePosition) {
+	s.iFuturePositionStore.save(futurePosition)
+	s.persist()
+}
+
+func (s *fileFuturePositionStore) removeByCode(code string) {
+	s.iFuturePositionStore.removeByCode(code)
+	s.persist()
+}
+
+func (s *fileFuturePositionStore) persist() error {
+	return s.file.write(s.getAll())
+}
+
+// newFileFutureSymbolStore - ファイルに書き出す先物銘柄ストアの生成
+func newFileFutureSymbolStore(path string) (*fileFutureSymbolStore, error) {
+	store := &fileFutureSymbolStore{iFutureSymbolStore: newFutureSymbolStore(), file: &fileStore{path: path}}
+	var symbols []*futureSymbol
+	if err := store.file.load(&symbols); err != nil {
+		return nil, fmt.Errorf("cannot load future symbols(path: %s): %w", path, err)
+	}
+	for _, s := range symbols {
+		if s == nil {
+			continue
+		}
+		store.iFutureSymbolStore.save(s)
+	}
+	return store, nil
+}
+
+// fileFutureSymbolStore - 銘柄を保存するたびにファイルに書き出す先物銘柄ストア
+//   清算値やSQでの決済で銘柄が変わってもストアには保存し直さないので、仮想証券会社の処理のたびにpersistで書き出す
+type fileFutureSymbolStore struct {
+	iFutureSymbolStore
+	file *fileStore
+}
+
+func (s *fileFutureSymbolStore) save(futureSymbol *futureSymbol) {
+	s.iFutureSymbolStore.save(futureSymbol)
+	s.persist()
+}
+
+func (s *fileFutureSymbolStore) persist() error {
+	return s.file.write(s.getAll())
+}
+
+// newFileAccountStore - 口座の状態をファイルに書き出すストアの生成
+//   ファイルに口座の状態があれば、口座をその状態に戻す
+func newFileAccountStore(accountService iAccountService, path string) (*fileAccountStore, error) {
+	store := &fileAccountStore{accountService: accountService, file: &fileStore{path: path}}
+	var state *accountState
+	if err := store.file.load(&state); err != nil {
+		return nil, fmt.Errorf("cannot load account(path: %s): %w", path, err)
+	}
+	accountService.restore(state)
+	return store, nil
+}
+
+// fileAccountStore - 口座の状態をファイルに書き出すストア
+//   口座は処理のたびに変わるので、仮想証券会社の処理のたびにpersistで書き出す
+type fileAccountStore struct {
+	accountService iAccountService
+	file           *fileStore
+}
+
+func (s *fileAccountStore) persist() error {
+	return s.file.write(s.accountService.getState())
+}
+
+// newFileLedgerStore - 台帳に記録した約定をファイルに書き出すストアの生成
+//   ファイルに約定があれば、台帳をその約定に戻す
+func newFileLedgerStore(ledgerService iLedgerService, path string) (*fileLedgerStore, error) {
+	store := &fileLedgerStore{ledgerService: ledgerService, file: &fileStore{path: path}}
+	var trades []*Trade
+	if err := store.file.load(&trades); err != nil {
+		return nil, fmt.Errorf("cannot load trades(path: %s): %w", path, err)
+	}
+	ledgerService.restore(trades)
+	return store, nil
+}
+
+// fileLedgerStore - 台帳に記録した約定をファイルに書き出すストア
+//   約定は処理の最後に台帳に記録するので、仮想証券会社の処理のたびにpersistで書き出す
+type fileLedgerStore struct {
+	ledgerService iLedgerService
+	file          *fileStore
+}
+
+func (s *fileLedgerStore) persist() error {
+	return s.file.write(s.ledgerService.getTrades())
+}
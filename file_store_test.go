@@ -0,0 +1,384 @@
+package virtual_security
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_fileStore_load(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	// ファイルがなければ何もしない
+	var got1 []string
+	err1 := (&fileStore{path: filepath.Join(dir, "not_found.json")}).load(&got1)
+
+	// 書き出したものを読み込める
+	store := &fileStore{path: filepath.Join(dir, "store.json")}
+	want2 := []string{"foo", "bar"}
+	if err := store.write(want2); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	var got2 []string
+	err2 := store.load(&got2)
+
+	// JSONでなければエラー
+	broken := filepath.Join(dir, "broken.json")
+	if err := os.WriteFile(broken, []byte("{"), 0o644); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	var got3 []string
+	err3 := (&fileStore{path: broken}).load(&got3)
+
+	if got1 != nil || err1 != nil || !reflect.DeepEqual(want2, got2) || err2 != nil || err3 == nil {
+		t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v, %+v\n", t.Name(),
+			nil, nil, want2, nil, "error",
+			got1, err1, got2, err2, err3)
+	}
+}
+
+func Test_fileStore_write(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "store.json")
+	store := &fileStore{path: path}
+	if err := store.write([]int{1, 2}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if err := store.write([]int{3}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	want := "[3]"
+	b, _ := os.ReadFile(path)
+	_, tmpErr := os.Stat(path + ".tmp")
+	if want != string(b) || !os.IsNotExist(tmpErr) {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), want, true, string(b), tmpErr)
+	}
+}
+
+func Test_fileStore_write_error(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "store")
+	store := &fileStore{path: filepath.Join(dir, "store.json")}
+
+	// ディレクトリがなければ書き出せず、エラーが残る
+	err1 := store.write([]int{1})
+	last1 := store.lastError()
+
+	// 書き出せればエラーは消える
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	err2 := store.write([]int{2})
+	last2 := store.lastError()
+
+	if err1 == nil || last1 != err1 || err2 != nil || last2 != nil {
+		t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v\n", t.Name(), "error", "error", nil, nil, err1, last1, err2, last2)
+	}
+}
+
+func Test_fileStockOrderStore_save_error(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "store")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	store, err := newFileStockOrderStore(filepath.Join(dir, "stock_orders.json"))
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 戻り値のない保存で書き出せなかったエラーが、ストアに残る
+	store.save(&stockOrder{Code: "foo", OrderStatus: OrderStatusInOrder})
+	got := store.file.lastError()
+	if got == nil || !reflect.DeepEqual(got, store.persist()) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), "error", got)
+	}
+}
+
+func Test_newFileStockOrderStore(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "stock_orders.json")
+	store1, err := newFileStockOrderStore(path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	store1.save(&stockOrder{Code: "foo", OrderStatus: OrderStatusInOrder, OrderedAt: time.Date(2021, 8, 25, 10, 0, 0, 0, time.UTC)})
+	store1.save(&stockOrder{Code: "bar", OrderStatus: OrderStatusInOrder, StopCondition: &StockStopCondition{StopPrice: 1000, ActivatedAt: time.Date(2021, 8, 25, 10, 1, 0, 0, time.UTC)}})
+	store1.save(&stockOrder{Code: "baz", OrderStatus: OrderStatusInOrder})
+	store1.removeByCode("baz")
+
+	// 保存し直していない変更もpersistで書き出せる
+	order, _ := store1.getByCode("foo")
+	order.OrderStatus = OrderStatusDone
+	if err := store1.persist(); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 別のストアで読み込むと、書き出した注文と逆指値の発動状態が戻る
+	store2, err := newFileStockOrderStore(path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want := []*stockOrder{
+		{Code: "bar", OrderStatus: OrderStatusInOrder, StopCondition: &StockStopCondition{StopPrice: 1000, ActivatedAt: time.Date(2021, 8, 25, 10, 1, 0, 0, time.UTC), isActivate: true}},
+		{Code: "foo", OrderStatus: OrderStatusDone, OrderedAt: time.Date(2021, 8, 25, 10, 0, 0, 0, time.UTC)},
+	}
+	got := store2.getAll()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_newFileStockOrderStore_error(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "stock_orders.json")
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	got1, got2 := newFileStockOrderStore(path)
+	if got1 != nil || got2 == nil {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), nil, "error", got1, got2)
+	}
+}
+
+func Test_newFileStockPositionStore(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "stock_positions.json")
+	store1, err := newFileStockPositionStore(path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	store1.save(&stockPosition{Code: "foo", SymbolCode: "1234", OwnedQuantity: 100, Price: 1000})
+	store1.save(&stockPosition{Code: "bar", SymbolCode: "1234", OwnedQuantity: 200, Price: 1000})
+	store1.removeByCode("bar")
+
+	store2, err := newFileStockPositionStore(path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want := []*stockPosition{{Code: "foo", SymbolCode: "1234", OwnedQuantity: 100, Price: 1000}}
+	got := store2.getAll()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_newFileMarginOrderStore(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "margin_orders.json")
+	store1, err := newFileMarginOrderStore(path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	store1.save(&marginOrder{Code: "foo", TradeType: TradeTypeEntry, OrderStatus: OrderStatusInOrder, StopCondition: &StockStopCondition{StopPrice: 1000, ActivatedAt: time.Date(2021, 8, 25, 10, 1, 0, 0, time.UTC)}})
+	store1.save(&marginOrder{Code: "bar", TradeType: TradeTypeExit, OrderStatus: OrderStatusInOrder})
+	store1.removeByCode("bar")
+
+	store2, err := newFileMarginOrderStore(path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want := []*marginOrder{{Code: "foo", TradeType: TradeTypeEntry, OrderStatus: OrderStatusInOrder, StopCondition: &StockStopCondition{StopPrice: 1000, ActivatedAt: time.Date(2021, 8, 25, 10, 1, 0, 0, time.UTC), isActivate: true}}}
+	got := store2.getAll()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_newFileMarginPositionStore(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "margin_positions.json")
+	store1, err := newFileMarginPositionStore(path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	store1.save(&marginPosition{Code: "foo", SymbolCode: "1234", Side: SideBuy, OwnedQuantity: 100, Price: 1000})
+	store1.save(&marginPosition{Code: "bar", SymbolCode: "1234", Side: SideSell, OwnedQuantity: 200, Price: 1000})
+	store1.removeByCode("foo")
+
+	store2, err := newFileMarginPositionStore(path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want := []*marginPosition{{Code: "bar", SymbolCode: "1234", Side: SideSell, OwnedQuantity: 200, Price: 1000}}
+	got := store2.getAll()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_newFilePriceStore(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "prices.json")
+	clock := newClockWithSource(&testSourceClock{now1: time.Date(2021, 8, 25, 10, 0, 0, 0, time.UTC)})
+	store1, err := newFilePriceStore(clock, path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	price := &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 8, 25, 10, 0, 0, 0, time.UTC), contractedVolume: 100, upperLimit: 1300}
+	if err := store1.set(price); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 約定に使った数量や値幅制限も読み込める
+	store2, err := newFilePriceStore(clock, path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	got, err := store2.getBySymbolCode("1234")
+	if !reflect.DeepEqual(price, got) || err != nil {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), price, nil, got, err)
+	}
+}
+
+func Test_newFileStockSymbolStore(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "stock_symbols.json")
+	store1, err := newFileStockSymbolStore(path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	symbol := &stockSymbol{SymbolCode: "1234", TickSizeTable: TickSizeTableTopix500, BasePrice: 1000}
+	store1.save(symbol)
+
+	// 保存し直さなくても、persistで基準値段の変化を書き出せる
+	symbol.setPrice(1100, time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC))
+	if err := store1.persist(); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	store2, err := newFileStockSymbolStore(path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want := []*stockSymbol{{SymbolCode: "1234", TickSizeTable: TickSizeTableTopix500, BasePrice: 1000, LastPrice: 1100, LastBusinessDay: time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)}}
+	got := store2.getAll()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_newFileFutureOrderStore(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "future_orders.json")
+	store1, err := newFileFutureOrderStore(path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	store1.save(&futureOrder{Code: "foo", TradeType: TradeTypeEntry, OrderStatus: OrderStatusInOrder, StopCondition: &FutureStopCondition{StopPrice: 28000, ActivatedAt: time.Date(2021, 8, 25, 10, 1, 0, 0, time.UTC)}})
+	store1.save(&futureOrder{Code: "bar", TradeType: TradeTypeExit, OrderStatus: OrderStatusInOrder})
+	store1.removeByCode("bar")
+
+	store2, err := newFileFutureOrderStore(path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want := []*futureOrder{{Code: "foo", TradeType: TradeTypeEntry, OrderStatus: OrderStatusInOrder, StopCondition: &FutureStopCondition{StopPrice: 28000, ActivatedAt: time.Date(2021, 8, 25, 10, 1, 0, 0, time.UTC), isActivate: true}}}
+	got := store2.getAll()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_newFileFuturePositionStore(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "future_positions.json")
+	store1, err := newFileFuturePositionStore(path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	store1.save(&futurePosition{Code: "foo", SymbolCode: "NK225", Side: SideBuy, OwnedQuantity: 1, Price: 28000, Multiplier: 1000})
+	store1.save(&futurePosition{Code: "bar", SymbolCode: "NK225", Side: SideSell, OwnedQuantity: 2, Price: 28000, Multiplier: 1000})
+	store1.removeByCode("foo")
+
+	store2, err := newFileFuturePositionStore(path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want := []*futurePosition{{Code: "bar", SymbolCode: "NK225", Side: SideSell, OwnedQuantity: 2, Price: 28000, Multiplier: 1000}}
+	got := store2.getAll()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_newFileFutureSymbolStore(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "future_symbols.json")
+	store1, err := newFileFutureSymbolStore(path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	store1.save(&futureSymbol{SymbolCode: "NK225", Multiplier: 1000, LastTradingDay: time.Date(2021, 9, 9, 0, 0, 0, 0, time.UTC), SettlementPrice: 28000})
+
+	store2, err := newFileFutureSymbolStore(path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want := []*futureSymbol{{SymbolCode: "NK225", Multiplier: 1000, LastTradingDay: time.Date(2021, 9, 9, 0, 0, 0, 0, time.UTC), SettlementPrice: 28000}}
+	got := store2.getAll()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_newFileAccountStore(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "account.json")
+	acc := &account{Cash: 1_000_000}
+	service1 := newAccountService(newClock(), acc, true, false, map[ExchangeType]FeeSchedule{}, MarginCostRates{})
+	store1, err := newFileAccountStore(service1, path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	acc.reserve("foo", 1000, 100)
+	if err := store1.persist(); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// ファイルがあれば、生成した口座の預り金よりファイルの口座の状態を優先する
+	service2 := newAccountService(newClock(), &account{Cash: 500_000}, true, false, map[ExchangeType]FeeSchedule{}, MarginCostRates{})
+	if _, err := newFileAccountStore(service2, path); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want := service1.getAccount()
+	got := service2.getAccount()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_newFileLedgerStore(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "trades.json")
+	service1 := newLedgerService(0.2)
+	store1, err := newFileLedgerStore(service1, path)
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	day := time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)
+	service1.record(&Trade{ContractCode: "foo", PositionCode: "pos", ExchangeType: ExchangeTypeStock, TradeType: TradeTypeEntry, Side: SideBuy, Price: 1000, Quantity: 100, BusinessDay: day, ContractedAt: time.Date(2021, 9, 1, 10, 0, 0, 0, time.UTC)})
+	service1.record(&Trade{ContractCode: "bar", PositionCode: "pos", ExchangeType: ExchangeTypeStock, TradeType: TradeTypeExit, Side: SideSell, Price: 1100, Quantity: 100, BusinessDay: day, ContractedAt: time.Date(2021, 9, 1, 11, 0, 0, 0, time.UTC)})
+	if err := store1.persist(); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// ファイルがあれば、台帳をファイルの約定に戻し、税金の累計も引き継ぐ
+	service2 := newLedgerService(0.2)
+	if _, err := newFileLedgerStore(service2, path); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	service2.record(&Trade{ContractCode: "baz", ExchangeType: ExchangeTypeStock, TradeType: TradeTypeExit, Side: SideSell, Fee: 5000, BusinessDay: day, ContractedAt: time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)})
+	want1, want2 := service1.getTrades(), 5000.0
+	got1, got2 := service2.getTrades(), service2.totalProfit()
+	if !reflect.DeepEqual(want1, got1[:2]) || got2 != want2 || got1[2].Tax != -1000 {
+		t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(), want1, want2, -1000, got1, got2, got1[2].Tax)
+	}
+}
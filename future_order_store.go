@@ -0,0 +1,90 @@
+package virtual_security
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	futureOrderStoreSingleton      iFutureOrderStore
+	futureOrderStoreSingletonMutex sync.Mutex
+)
+
+func getFutureOrderStore() iFutureOrderStore {
+	futureOrderStoreSingletonMutex.Lock()
+	defer futureOrderStoreSingletonMutex.Unlock()
+
+	if futureOrderStoreSingleton == nil {
+		futureOrderStoreSingleton = newFutureOrderStore()
+	}
+	return futureOrderStoreSingleton
+}
+
+func newFutureOrderStore() iFutureOrderStore {
+	return &futureOrderStore{
+		store: map[string]*futureOrder{},
+	}
+}
+
+// iFutureOrderStore - 先物注文ストアのインターフェース
+type iFutureOrderStore interface {
+	getAll() []*futureOrder
+	getByCode(code string) (*futureOrder, error)
+	save(futureOrder *futureOrder)
+	removeByCode(code string)
+}
+
+// futureOrderStore - 先物注文のストア
+type futureOrderStore struct {
+	store map[string]*futureOrder
+	mtx   sync.Mutex
+}
+
+// getAll - ストアのすべての注文をコード順に並べて返す
+func (s *futureOrderStore) getAll() []*futureOrder {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	orders := make([]*futureOrder, len(s.store))
+	var i int
+	for _, order := range s.store {
+		orders[i] = order
+		i++
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].Code < orders[j].Code
+	})
+	return orders
+}
+
+// getByCode - コードを指定してデータを取得する
+func (s *futureOrderStore) getByCode(code string) (*futureOrder, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if order, ok := s.store[code]; ok {
+		return order, nil
+	} else {
+		return nil, NoDataError
+	}
+}
+
+// save - 注文をストアに追加する
+func (s *futureOrderStore) save(futureOrder *futureOrder) {
+	if futureOrder == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.store[futureOrder.Code] = futureOrder
+}
+
+// removeByCode - コードを指定して削除する
+func (s *futureOrderStore) removeByCode(code string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.store, code)
+}
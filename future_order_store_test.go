@@ -0,0 +1,195 @@
+package virtual_security
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testFutureOrderStore struct {
+	getAll1             []*futureOrder
+	getByCode1          *futureOrder
+	getByCode2          error
+	getByCodeHistory    []string
+	saveHistory         []*futureOrder
+	removeByCodeHistory []string
+}
+
+func (t *testFutureOrderStore) getAll() []*futureOrder { return t.getAll1 }
+func (t *testFutureOrderStore) getByCode(code string) (*futureOrder, error) {
+	t.getByCodeHistory = append(t.getByCodeHistory, code)
+	return t.getByCode1, t.getByCode2
+}
+func (t *testFutureOrderStore) save(order *futureOrder) {
+	if t.saveHistory == nil {
+		t.saveHistory = []*futureOrder{}
+	}
+	t.saveHistory = append(t.saveHistory, order)
+}
+func (t *testFutureOrderStore) removeByCode(code string) {
+	if t.removeByCodeHistory == nil {
+		t.removeByCodeHistory = []string{}
+	}
+	t.removeByCodeHistory = append(t.removeByCodeHistory, code)
+}
+
+func Test_newFutureOrderStore(t *testing.T) {
+	t.Parallel()
+	want := &futureOrderStore{store: map[string]*futureOrder{}}
+	got := newFutureOrderStore()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+	if got == newFutureOrderStore() {
+		t.Errorf("%s error\nwant: different store\ngot: same store\n", t.Name())
+	}
+}
+
+func Test_getFutureOrderStore(t *testing.T) {
+	got := getFutureOrderStore()
+	want := &futureOrderStore{store: map[string]*futureOrder{}}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_futureOrderStore_GetAll(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		store *futureOrderStore
+		want  []*futureOrder
+	}{
+		{name: "storeが空なら空配列が返される",
+			store: &futureOrderStore{store: map[string]*futureOrder{}},
+			want:  []*futureOrder{}},
+		{name: "storeにデータがあればコード順昇順にして返す",
+			store: &futureOrderStore{store: map[string]*futureOrder{
+				"foo": {Code: "foo"},
+				"bar": {Code: "bar"},
+				"baz": {Code: "baz"},
+			}},
+			want: []*futureOrder{{Code: "bar"}, {Code: "baz"}, {Code: "foo"}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.store.getAll()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futureOrderStore_GetByCode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		store *futureOrderStore
+		arg   string
+		want1 *futureOrder
+		want2 error
+	}{
+		{name: "storeに指定したコードがなければエラー",
+			store: &futureOrderStore{store: map[string]*futureOrder{}},
+			arg:   "foo",
+			want1: nil,
+			want2: NoDataError},
+		{name: "storeに指定したコードがあればその注文を返す",
+			store: &futureOrderStore{store: map[string]*futureOrder{
+				"foo": {Code: "foo"},
+				"bar": {Code: "bar"},
+				"baz": {Code: "baz"}}},
+			arg:   "foo",
+			want1: &futureOrder{Code: "foo"},
+			want2: nil},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got1, got2 := test.store.getByCode(test.arg)
+			if !reflect.DeepEqual(test.want1, got1) || !errors.Is(got2, test.want2) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want1, test.want2, got1, got2)
+			}
+		})
+	}
+}
+
+func Test_futureOrderStore_save(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		store     *futureOrderStore
+		arg       *futureOrder
+		wantStore map[string]*futureOrder
+	}{
+		{name: "引数がnilなら何もしない",
+			store: &futureOrderStore{store: map[string]*futureOrder{
+				"foo": {Code: "foo"},
+				"bar": {Code: "bar"},
+				"baz": {Code: "baz"}}},
+			arg:       nil,
+			wantStore: map[string]*futureOrder{"foo": {Code: "foo"}, "bar": {Code: "bar"}, "baz": {Code: "baz"}}},
+		{name: "storeにコードがなければ追加",
+			store: &futureOrderStore{store: map[string]*futureOrder{
+				"foo": {Code: "foo"},
+				"bar": {Code: "bar"},
+				"baz": {Code: "baz"}}},
+			arg:       &futureOrder{Code: "hoge"},
+			wantStore: map[string]*futureOrder{"foo": {Code: "foo"}, "bar": {Code: "bar"}, "baz": {Code: "baz"}, "hoge": {Code: "hoge"}}},
+		{name: "storeにコードがあれば上書き",
+			store: &futureOrderStore{store: map[string]*futureOrder{
+				"foo": {Code: "foo"},
+				"bar": {Code: "bar"},
+				"baz": {Code: "baz"}}},
+			arg:       &futureOrder{Code: "foo", ExecutionCondition: FutureExecutionConditionMO},
+			wantStore: map[string]*futureOrder{"foo": {Code: "foo", ExecutionCondition: FutureExecutionConditionMO}, "bar": {Code: "bar"}, "baz": {Code: "baz"}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			test.store.save(test.arg)
+			if !reflect.DeepEqual(test.wantStore, test.store.store) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.wantStore, test.store.store)
+			}
+		})
+	}
+}
+
+func Test_futureOrderStore_RemoveByCode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		store *futureOrderStore
+		arg   string
+		want  map[string]*futureOrder
+	}{
+		{name: "指定したコードがなければ何もない",
+			store: &futureOrderStore{store: map[string]*futureOrder{"1234": {Code: "1234"}, "2345": {Code: "2345"}, "3456": {Code: "3456"}}},
+			arg:   "0000",
+			want:  map[string]*futureOrder{"1234": {Code: "1234"}, "2345": {Code: "2345"}, "3456": {Code: "3456"}}},
+		{name: "指定したコードがあれば削除する",
+			store: &futureOrderStore{store: map[string]*futureOrder{"1234": {Code: "1234"}, "2345": {Code: "2345"}, "3456": {Code: "3456"}}},
+			arg:   "2345",
+			want:  map[string]*futureOrder{"1234": {Code: "1234"}, "3456": {Code: "3456"}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			test.store.removeByCode(test.arg)
+			got := test.store.store
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
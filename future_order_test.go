@@ -0,0 +1,488 @@
+package virtual_security
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_futureOrder_isDied(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		futureOrder *futureOrder
+		arg         time.Time
+		want        bool
+	}{
+		{name: "未終了の注文なら生きている",
+			futureOrder: &futureOrder{OrderStatus: OrderStatusInOrder},
+			arg:         time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
+			want:        false},
+		{name: "取消済み注文で、取消がborderより後なら生きている",
+			futureOrder: &futureOrder{OrderStatus: OrderStatusCanceled, CanceledAt: time.Date(2021, 6, 14, 11, 0, 0, 0, time.Local)},
+			arg:         time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
+			want:        false},
+		{name: "取消済み注文で、取消がborder丁度なら生きている",
+			futureOrder: &futureOrder{OrderStatus: OrderStatusCanceled, CanceledAt: time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local)},
+			arg:         time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
+			want:        false},
+		{name: "取消済み注文で、取消がborderより前なら死んでいる",
+			futureOrder: &futureOrder{OrderStatus: OrderStatusCanceled, CanceledAt: time.Date(2021, 6, 14, 9, 0, 0, 0, time.Local)},
+			arg:         time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
+			want:        true},
+		{name: "約定済み注文で、最後の約定がborderより後なら生きている",
+			futureOrder: &futureOrder{OrderStatus: OrderStatusDone, Contracts: []*Contract{
+				{ContractedAt: time.Date(2021, 6, 14, 9, 0, 0, 0, time.Local)},
+				{ContractedAt: time.Date(2021, 6, 14, 11, 0, 0, 0, time.Local)}}},
+			arg:  time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
+			want: false},
+		{name: "約定済み注文で、最後の約定がborder丁度なら生きている",
+			futureOrder: &futureOrder{OrderStatus: OrderStatusDone, Contracts: []*Contract{
+				{ContractedAt: time.Date(2021, 6, 14, 9, 0, 0, 0, time.Local)},
+				{ContractedAt: time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local)}}},
+			arg:  time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
+			want: false},
+		{name: "約定済み注文で、最後の約定がborderより前なら死んでいる",
+			futureOrder: &futureOrder{OrderStatus: OrderStatusDone, Contracts: []*Contract{
+				{ContractedAt: time.Date(2021, 6, 14, 9, 0, 0, 0, time.Local)},
+				{ContractedAt: time.Date(2021, 6, 14, 9, 30, 0, 0, time.Local)}}},
+			arg:  time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
+			want: true},
+		{name: "終了した注文で、取消も約定も情報が無かったら死んだものとする",
+			futureOrder: &futureOrder{OrderStatus: OrderStatusDone, Contracts: []*Contract{}},
+			arg:         time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
+			want:        true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.futureOrder.isDied(test.arg)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futureOrder_executionCondition(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		futureOrder *futureOrder
+		want        FutureExecutionCondition
+	}{
+		{name: "逆指値で待機中でなく逆指値条件があれば、逆指値発動後の条件が返される",
+			futureOrder: &futureOrder{
+				OrderStatus:        OrderStatusInOrder,
+				ExecutionCondition: FutureExecutionConditionStop,
+				StopCondition:      &FutureStopCondition{ExecutionConditionAfterHit: FutureExecutionConditionMO}},
+			want: FutureExecutionConditionMO},
+		{name: "逆指値で待機中でなくても、逆指値条件がなければそのまま返す",
+			futureOrder: &futureOrder{
+				OrderStatus:        OrderStatusInOrder,
+				ExecutionCondition: FutureExecutionConditionStop,
+				StopCondition:      nil},
+			want: FutureExecutionConditionStop},
+		{name: "逆指値でも待機中なら、そのまま返す",
+			futureOrder: &futureOrder{
+				OrderStatus:        OrderStatusWait,
+				ExecutionCondition: FutureExecutionConditionStop,
+				StopCondition:      &FutureStopCondition{ExecutionConditionAfterHit: FutureExecutionConditionMO}},
+			want: FutureExecutionConditionStop},
+		{name: "逆指値注文でなければそのまま返す",
+			futureOrder: &futureOrder{
+				OrderStatus:        OrderStatusInOrder,
+				ExecutionCondition: FutureExecutionConditionLO,
+				StopCondition:      &FutureStopCondition{ExecutionConditionAfterHit: FutureExecutionConditionMO}},
+			want: FutureExecutionConditionLO},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.futureOrder.executionCondition()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futureOrder_limitPrice(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		futureOrder *futureOrder
+		want        float64
+	}{
+		{name: "逆指値で待機中でなく逆指値条件があれば、逆指値発動後の指値価格が返される",
+			futureOrder: &futureOrder{
+				OrderStatus:        OrderStatusInOrder,
+				ExecutionCondition: FutureExecutionConditionStop,
+				LimitPrice:         1000,
+				StopCondition:      &FutureStopCondition{ExecutionConditionAfterHit: FutureExecutionConditionMO, LimitPriceAfterHit: 1500}},
+			want: 1500},
+		{name: "逆指値で待機中でなくても、逆指値条件がなければそのまま返す",
+			futureOrder: &futureOrder{
+				OrderStatus:        OrderStatusInOrder,
+				ExecutionCondition: FutureExecutionConditionStop,
+				StopCondition:      nil},
+			want: 0},
+		{name: "逆指値でも待機中なら、そのまま返す",
+			futureOrder: &futureOrder{
+				OrderStatus:        OrderStatusWait,
+				ExecutionCondition: FutureExecutionConditionStop,
+				StopCondition:      &FutureStopCondition{ExecutionConditionAfterHit: FutureExecutionConditionMO, LimitPriceAfterHit: 1500}},
+			want: 0},
+		{name: "逆指値注文でなければそのまま返す",
+			futureOrder: &futureOrder{
+				OrderStatus:        OrderStatusInOrder,
+				ExecutionCondition: FutureExecutionConditionLO,
+				StopCondition:      &FutureStopCondition{ExecutionConditionAfterHit: FutureExecutionConditionMO, LimitPriceAfterHit: 1500}},
+			want: 0},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.futureOrder.limitPrice()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futureOrder_activate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		futureOrder *futureOrder
+		arg1        *symbolPrice
+		arg2        time.Time
+		wantStatus  OrderStatus
+	}{
+		{name: "条件を満たせば注文中になる",
+			futureOrder: &futureOrder{
+				SymbolCode:         "1234",
+				OrderStatus:        OrderStatusWait,
+				ExecutionCondition: FutureExecutionConditionStop,
+				StopCondition: &FutureStopCondition{
+					StopPrice:                  100.0,
+					ComparisonOperator:         ComparisonOperatorLE,
+					ExecutionConditionAfterHit: FutureExecutionConditionMO,
+				}},
+			arg1:       &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 30, 20, 32, 0, 0, time.Local)},
+			arg2:       time.Date(2021, 5, 30, 20, 32, 0, 0, time.Local),
+			wantStatus: OrderStatusInOrder},
+		{name: "現在値の時間が5s前以前なら有効にならない",
+			futureOrder: &futureOrder{
+				SymbolCode:         "1234",
+				OrderStatus:        OrderStatusWait,
+				ExecutionCondition: FutureExecutionConditionStop,
+				StopCondition: &FutureStopCondition{
+					StopPrice:                  100.0,
+					ComparisonOperator:         ComparisonOperatorLE,
+					ExecutionConditionAfterHit: FutureExecutionConditionMO,
+				}},
+			arg1:       &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 30, 20, 31, 54, 0, time.Local)},
+			arg2:       time.Date(2021, 5, 30, 20, 32, 0, 0, time.Local),
+			wantStatus: OrderStatusWait},
+		{name: "価格情報がなければ何もしない",
+			futureOrder: &futureOrder{
+				SymbolCode:         "1234",
+				OrderStatus:        OrderStatusWait,
+				ExecutionCondition: FutureExecutionConditionStop,
+				StopCondition: &FutureStopCondition{
+					StopPrice:                  100.0,
+					ComparisonOperator:         ComparisonOperatorLE,
+					ExecutionConditionAfterHit: FutureExecutionConditionMO,
+				}},
+			arg1:       &symbolPrice{SymbolCode: "1234", Price: 0},
+			arg2:       time.Date(2021, 5, 30, 20, 32, 0, 0, time.Local),
+			wantStatus: OrderStatusWait},
+		{name: "逆指値条件が設定されていなければ何もしない",
+			futureOrder: &futureOrder{
+				SymbolCode:         "1234",
+				OrderStatus:        OrderStatusWait,
+				ExecutionCondition: FutureExecutionConditionStop},
+			arg1:       &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 30, 20, 32, 0, 0, time.Local)},
+			arg2:       time.Date(2021, 5, 30, 20, 32, 0, 0, time.Local),
+			wantStatus: OrderStatusWait},
+		{name: "逆指値注文でなければ何もしない",
+			futureOrder: &futureOrder{
+				SymbolCode:         "1234",
+				OrderStatus:        OrderStatusWait,
+				ExecutionCondition: FutureExecutionConditionMO,
+				StopCondition: &FutureStopCondition{
+					StopPrice:                  100.0,
+					ComparisonOperator:         ComparisonOperatorLE,
+					ExecutionConditionAfterHit: FutureExecutionConditionMO,
+				}},
+			arg1:       &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 30, 20, 32, 0, 0, time.Local)},
+			arg2:       time.Date(2021, 5, 30, 20, 32, 0, 0, time.Local),
+			wantStatus: OrderStatusWait},
+		{name: "注文の状態が待機でなければ何もしない",
+			futureOrder: &futureOrder{
+				SymbolCode:         "1234",
+				OrderStatus:        OrderStatusPart,
+				ExecutionCondition: FutureExecutionConditionStop,
+				StopCondition: &FutureStopCondition{
+					StopPrice:                  100.0,
+					ComparisonOperator:         ComparisonOperatorLE,
+					ExecutionConditionAfterHit: FutureExecutionConditionMO,
+				}},
+			arg1:       &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 30, 20, 32, 0, 0, time.Local)},
+			arg2:       time.Date(2021, 5, 30, 20, 32, 0, 0, time.Local),
+			wantStatus: OrderStatusPart},
+		{name: "銘柄が違えば何もしない",
+			futureOrder: &futureOrder{
+				SymbolCode:         "1234",
+				OrderStatus:        OrderStatusWait,
+				ExecutionCondition: FutureExecutionConditionStop,
+				StopCondition: &FutureStopCondition{
+					StopPrice:                  100.0,
+					ComparisonOperator:         ComparisonOperatorLE,
+					ExecutionConditionAfterHit: FutureExecutionConditionMO,
+				}},
+			arg1:       &symbolPrice{SymbolCode: "0000", Price: 1000, PriceTime: time.Date(2021, 5, 30, 20, 32, 0, 0, time.Local)},
+			arg2:       time.Date(2021, 5, 30, 20, 32, 0, 0, time.Local),
+			wantStatus: OrderStatusWait},
+		{name: "引数がnilなら何もしない",
+			futureOrder: &futureOrder{
+				SymbolCode:         "1234",
+				OrderStatus:        OrderStatusWait,
+				ExecutionCondition: FutureExecutionConditionStop,
+				StopCondition: &FutureStopCondition{
+					StopPrice:                  100.0,
+					ComparisonOperator:         ComparisonOperatorLE,
+					ExecutionConditionAfterHit: FutureExecutionConditionMO,
+				}},
+			arg1:       nil,
+			arg2:       time.Date(2021, 5, 30, 20, 32, 0, 0, time.Local),
+			wantStatus: OrderStatusWait},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			test.futureOrder.activate(test.arg1, test.arg2)
+			got1 := test.futureOrder.OrderStatus
+			if !reflect.DeepEqual(test.wantStatus, got1) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.wantStatus, got1)
+			}
+		})
+	}
+}
+
+func Test_futureOrder_isExpired(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		futureOrder *futureOrder
+		arg         time.Time
+		want        bool
+	}{
+		{name: "有効期限がゼロ値なら期限切れにならない",
+			futureOrder: &futureOrder{OrderStatus: OrderStatusInOrder, ExpiredAt: time.Time{}},
+			arg:         time.Date(2021, 6, 7, 13, 24, 0, 0, time.Local),
+			want:        false},
+		{name: "有効期限が営業日よりも過去なら期限切れになる",
+			futureOrder: &futureOrder{OrderStatus: OrderStatusInOrder, ExpiredAt: time.Date(2021, 6, 7, 0, 0, 0, 0, time.Local)},
+			arg:         time.Date(2021, 6, 8, 0, 0, 0, 0, time.Local),
+			want:        true},
+		{name: "有効期限が営業日と一致しているなら期限切れにならない",
+			futureOrder: &futureOrder{OrderStatus: OrderStatusInOrder, ExpiredAt: time.Date(2021, 6, 7, 0, 0, 0, 0, time.Local)},
+			arg:         time.Date(2021, 6, 7, 0, 0, 0, 0, time.Local),
+			want:        false},
+		{name: "有効期限が営業日よりも未来なら期限切れにならない",
+			futureOrder: &futureOrder{OrderStatus: OrderStatusInOrder, ExpiredAt: time.Date(2021, 6, 7, 0, 0, 0, 0, time.Local)},
+			arg:         time.Date(2021, 6, 6, 0, 0, 0, 0, time.Local),
+			want:        false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.futureOrder.isExpired(test.arg)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futureOrder_contract(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name                   string
+		futureOrder            *futureOrder
+		arg                    *Contract
+		wantContractedQuantity float64
+		wantStatus             OrderStatus
+	}{
+		{name: "引数がnilなら何もしない",
+			futureOrder:            &futureOrder{OrderQuantity: 3, ContractedQuantity: 0, OrderStatus: OrderStatusUnspecified},
+			arg:                    nil,
+			wantContractedQuantity: 0,
+			wantStatus:             OrderStatusUnspecified},
+		{name: "約定後、約定数量が0なら注文中",
+			futureOrder:            &futureOrder{OrderQuantity: 3, ContractedQuantity: 0, OrderStatus: OrderStatusUnspecified},
+			arg:                    &Contract{Quantity: 0},
+			wantContractedQuantity: 0,
+			wantStatus:             OrderStatusInOrder},
+		{name: "約定後、約定数量が注文数量未満なら部分約定",
+			futureOrder:            &futureOrder{OrderQuantity: 3, ContractedQuantity: 0, OrderStatus: OrderStatusUnspecified},
+			arg:                    &Contract{Quantity: 1},
+			wantContractedQuantity: 1,
+			wantStatus:             OrderStatusPart},
+		{name: "約定後、約定数量が注文数量以上なら全約定",
+			futureOrder:            &futureOrder{OrderQuantity: 3, ContractedQuantity: 1, OrderStatus: OrderStatusUnspecified},
+			arg:                    &Contract{Quantity: 2},
+			wantContractedQuantity: 3,
+			wantStatus:             OrderStatusDone},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			test.futureOrder.contract(test.arg)
+			got1 := test.futureOrder.ContractedQuantity
+			got2 := test.futureOrder.OrderStatus
+			if !reflect.DeepEqual(test.wantContractedQuantity, got1) || !reflect.DeepEqual(test.wantStatus, got2) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.wantContractedQuantity, test.wantStatus, got1, got2)
+			}
+		})
+	}
+}
+
+func Test_futureOrder_cancel(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name                 string
+		futureOrder          *futureOrder
+		arg                  time.Time
+		wantOrderStatus      OrderStatus
+		wantCanceledAt       time.Time
+		wantCanceledQuantity float64
+	}{
+		{name: "ステータスがnewなら取消状態に更新",
+			futureOrder:     &futureOrder{OrderStatus: OrderStatusNew},
+			arg:             time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local),
+			wantOrderStatus: OrderStatusCanceled,
+			wantCanceledAt:  time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local)},
+		{name: "ステータスがin_orderなら取消状態に更新",
+			futureOrder:     &futureOrder{OrderStatus: OrderStatusInOrder},
+			arg:             time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local),
+			wantOrderStatus: OrderStatusCanceled,
+			wantCanceledAt:  time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local)},
+		{name: "ステータスがpartなら取消状態に更新",
+			futureOrder:          &futureOrder{OrderStatus: OrderStatusPart, OrderQuantity: 300, ContractedQuantity: 100},
+			arg:                  time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local),
+			wantOrderStatus:      OrderStatusCanceled,
+			wantCanceledAt:       time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local),
+			wantCanceledQuantity: 200},
+		{name: "ステータスがdoneなら取消状態に更新できない",
+			futureOrder:     &futureOrder{OrderStatus: OrderStatusDone},
+			arg:             time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local),
+			wantOrderStatus: OrderStatusDone,
+			wantCanceledAt:  time.Time{}},
+		{name: "ステータスがcanceledなら取消状態に更新できない",
+			futureOrder:     &futureOrder{OrderStatus: OrderStatusCanceled, CanceledAt: time.Date(2021, 5, 18, 10, 0, 0, 0, time.Local)},
+			arg:             time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local),
+			wantOrderStatus: OrderStatusCanceled,
+			wantCanceledAt:  time.Date(2021, 5, 18, 10, 0, 0, 0, time.Local)},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			test.futureOrder.cancel(test.arg)
+			got1 := test.futureOrder.OrderStatus
+			got2 := test.futureOrder.CanceledAt
+			got3 := test.futureOrder.CanceledQuantity
+			if !reflect.DeepEqual(test.wantOrderStatus, got1) || !reflect.DeepEqual(test.wantCanceledAt, got2) || !reflect.DeepEqual(test.wantCanceledQuantity, got3) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(), test.wantOrderStatus, test.wantCanceledAt, test.wantCanceledQuantity, got1, got2, got3)
+			}
+		})
+	}
+}
+
+func Test_futureOrder_addHoldPosition(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		order *futureOrder
+		arg1  string
+		arg2  float64
+		want  *futureOrder
+	}{
+		{name: "sliceがnilなら空sliceを作ってからappendする",
+			order: &futureOrder{},
+			arg1:  "spo-uuid-01",
+			arg2:  100,
+			want:  &futureOrder{HoldPositions: []*HoldPosition{{PositionCode: "spo-uuid-01", HoldQuantity: 100}}}},
+		{name: "sliceに要素があったら末尾にappendする",
+			order: &futureOrder{HoldPositions: []*HoldPosition{{PositionCode: "spo-uuid-01", HoldQuantity: 100}}},
+			arg1:  "spo-uuid-02",
+			arg2:  1000,
+			want:  &futureOrder{HoldPositions: []*HoldPosition{{PositionCode: "spo-uuid-01", HoldQuantity: 100}, {PositionCode: "spo-uuid-02", HoldQuantity: 1000}}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			test.order.addHoldPosition(test.arg1, test.arg2)
+			if !reflect.DeepEqual(test.want, test.order) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, test.order)
+			}
+		})
+	}
+}
+
+func Test_futureOrder_addExitPosition(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		order     *futureOrder
+		arg1      string
+		arg2      float64
+		wantOrder *futureOrder
+	}{
+		{name: "注文でHoldしているポジションがnilなら何もしない",
+			order:     &futureOrder{HoldPositions: nil},
+			arg1:      "spo-uuid-01",
+			arg2:      50,
+			wantOrder: &futureOrder{HoldPositions: nil}},
+		{name: "注文でHoldしているポジションと一致しないなら何もしない",
+			order:     &futureOrder{HoldPositions: []*HoldPosition{{PositionCode: "spo-uuid-02", HoldQuantity: 100, ExitQuantity: 100}, {PositionCode: "spo-uuid-03", HoldQuantity: 300, ExitQuantity: 200}}},
+			arg1:      "spo-uuid-01",
+			arg2:      50,
+			wantOrder: &futureOrder{HoldPositions: []*HoldPosition{{PositionCode: "spo-uuid-02", HoldQuantity: 100, ExitQuantity: 100}, {PositionCode: "spo-uuid-03", HoldQuantity: 300, ExitQuantity: 200}}}},
+		{name: "注文でHoldしているポジションをExitした場合、Exit数に加算しておく",
+			order:     &futureOrder{HoldPositions: []*HoldPosition{{PositionCode: "spo-uuid-02", HoldQuantity: 100, ExitQuantity: 100}, {PositionCode: "spo-uuid-03", HoldQuantity: 300, ExitQuantity: 200}}},
+			arg1:      "spo-uuid-03",
+			arg2:      50,
+			wantOrder: &futureOrder{HoldPositions: []*HoldPosition{{PositionCode: "spo-uuid-02", HoldQuantity: 100, ExitQuantity: 100}, {PositionCode: "spo-uuid-03", HoldQuantity: 300, ExitQuantity: 250}}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			test.order.addExitPosition(test.arg1, test.arg2)
+			if !reflect.DeepEqual(test.wantOrder, test.order) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.wantOrder, test.order)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package virtual_security
+
+import (
+	"sync"
+	"time"
+)
+
+// futurePosition - 先物ポジション
+type futurePosition struct {
+	Code               string    // ポジションコード
+	OrderCode          string    // 注文コード
+	SymbolCode         string    // 銘柄コード
+	Side               Side      // 方向
+	ContractedQuantity float64   // 約定数量
+	OwnedQuantity      float64   // 保有数量
+	HoldQuantity       float64   // 拘束数量
+	Price              float64   // 約定価格
+	SettlementPrice    float64   // 値洗いの基準になる価格
+	Multiplier         float64   // 取引単位
+	ContractedAt       time.Time // 約定日時
+	mtx                sync.Mutex
+}
+
+// exitable - 拘束されているポジションをエグジットできるかのチェック
+func (p *futurePosition) exitable(quantity float64) error {
+	if p.OwnedQuantity < quantity {
+		return NotEnoughOwnedQuantityError
+	}
+	if p.HoldQuantity < quantity {
+		return NotEnoughHoldQuantityError
+	}
+	return nil
+}
+
+// exit - 拘束されているポジションをエグジットする
+func (p *futurePosition) exit(quantity float64) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if err := p.exitable(quantity); err != nil {
+		return err
+	}
+	p.OwnedQuantity -= quantity
+	p.HoldQuantity -= quantity
+	return nil
+}
+
+// holdable - ポジションの保有数を拘束できるかのチェック
+func (p *futurePosition) holdable(quantity float64) error {
+	if p.OwnedQuantity < p.HoldQuantity+quantity {
+		return NotEnoughOwnedQuantityError
+	}
+	return nil
+}
+
+// hold - ポジションの保有数を拘束する
+func (p *futurePosition) hold(quantity float64) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if err := p.holdable(quantity); err != nil {
+		return err
+	}
+	p.HoldQuantity += quantity
+	return nil
+}
+
+// release - ポジションの拘束数を開放する
+func (p *futurePosition) release(quantity float64) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.HoldQuantity-quantity < 0 {
+		return NotEnoughHoldQuantityError
+	}
+	p.HoldQuantity -= quantity
+	return nil
+}
+
+// profit - 値洗いの基準になる価格から指定した価格までの、指定した数量分の損益
+func (p *futurePosition) profit(price float64, quantity float64) float64 {
+	profit := (price - p.SettlementPrice) * quantity * p.Multiplier
+	if p.Side == SideSell {
+		return -profit
+	}
+	return profit
+}
+
+// settle - 清算値で値洗いし、値洗いで確定した損益を返す
+func (p *futurePosition) settle(price float64) float64 {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.OwnedQuantity <= 0 || price <= 0 {
+		return 0
+	}
+	profit := p.profit(price, p.OwnedQuantity)
+	p.SettlementPrice = price
+	return profit
+}
+
+func (p *futurePosition) isDied() bool {
+	return p.OwnedQuantity <= 0
+}
+
+func (p *futurePosition) orderableQuantity() float64 {
+	return p.OwnedQuantity - p.HoldQuantity
+}
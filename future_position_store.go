@@ -0,0 +1,108 @@
+package virtual_security
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	futurePositionStoreSingleton      iFuturePositionStore
+	futurePositionStoreSingletonMutex sync.Mutex
+)
+
+func getFuturePositionStore() iFuturePositionStore {
+	futurePositionStoreSingletonMutex.Lock()
+	defer futurePositionStoreSingletonMutex.Unlock()
+
+	if futurePositionStoreSingleton == nil {
+		futurePositionStoreSingleton = newFuturePositionStore()
+	}
+	return futurePositionStoreSingleton
+}
+
+func newFuturePositionStore() iFuturePositionStore {
+	return &futurePositionStore{
+		store: map[string]*futurePosition{},
+	}
+}
+
+// iFuturePositionStore - 先物ポジションストアのインターフェース
+type iFuturePositionStore interface {
+	getAll() []*futurePosition
+	getByCode(code string) (*futurePosition, error)
+	getBySymbolCode(symbolCode string) ([]*futurePosition, error)
+	save(futurePosition *futurePosition)
+	removeByCode(code string)
+}
+
+// futurePositionStore - 先物ポジションのストア
+type futurePositionStore struct {
+	store map[string]*futurePosition
+	mtx   sync.Mutex
+}
+
+// getAll - ストアのすべてのポジションをコード順に並べて返す
+func (s *futurePositionStore) getAll() []*futurePosition {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	positions := make([]*futurePosition, len(s.store))
+	var i int
+	for _, position := range s.store {
+		positions[i] = position
+		i++
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].Code < positions[j].Code
+	})
+	return positions
+}
+
+// getByCode - コードを指定してデータを取得する
+func (s *futurePositionStore) getByCode(code string) (*futurePosition, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if position, ok := s.store[code]; ok {
+		return position, nil
+	} else {
+		return nil, NoDataError
+	}
+}
+
+// getBySymbolCode - 銘柄コードを指定してデータを取得する
+func (s *futurePositionStore) getBySymbolCode(symbolCode string) ([]*futurePosition, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	positions := make([]*futurePosition, 0)
+	for _, position := range s.store {
+		if symbolCode == position.SymbolCode {
+			positions = append(positions, position)
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].Code < positions[j].Code
+	})
+	return positions, nil
+}
+
+// save - ポジションをストアに追加する
+func (s *futurePositionStore) save(futurePosition *futurePosition) {
+	if futurePosition == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.store[futurePosition.Code] = futurePosition
+}
+
+// removeByCode - コードを指定して削除する
+func (s *futurePositionStore) removeByCode(code string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.store, code)
+}
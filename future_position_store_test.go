@@ -0,0 +1,291 @@
+package virtual_security
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testFuturePositionStore struct {
+	getAll1                []*futurePosition
+	getByCode1             *futurePosition
+	getByCode2             error
+	getByCodeHistory       []string
+	getBySymbolCode1       []*futurePosition
+	getBySymbolCode2       error
+	getBySymbolCodeHistory []string
+	saveHistory            []*futurePosition
+	removeByCodeHistory    []string
+}
+
+func (t *testFuturePositionStore) getAll() []*futurePosition { return t.getAll1 }
+func (t *testFuturePositionStore) getByCode(code string) (*futurePosition, error) {
+	t.getByCodeHistory = append(t.getByCodeHistory, code)
+	return t.getByCode1, t.getByCode2
+}
+func (t *testFuturePositionStore) getBySymbolCode(symbolCode string) ([]*futurePosition, error) {
+	t.getBySymbolCodeHistory = append(t.getBySymbolCodeHistory, symbolCode)
+	return t.getBySymbolCode1, t.getBySymbolCode2
+}
+func (t *testFuturePositionStore) save(futurePosition *futurePosition) {
+	t.saveHistory = append(t.saveHistory, futurePosition)
+}
+func (t *testFuturePositionStore) removeByCode(code string) {
+	t.removeByCodeHistory = append(t.removeByCodeHistory, code)
+}
+
+func Test_newFuturePositionStore(t *testing.T) {
+	t.Parallel()
+	want := &futurePositionStore{store: map[string]*futurePosition{}}
+	got := newFuturePositionStore()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+	if got == newFuturePositionStore() {
+		t.Errorf("%s error\nwant: different store\ngot: same store\n", t.Name())
+	}
+}
+
+func Test_getFuturePositionStore(t *testing.T) {
+	got := getFuturePositionStore()
+	want := &futurePositionStore{
+		store: map[string]*futurePosition{},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_futurePositionStore_GetAll(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		store *futurePositionStore
+		want  []*futurePosition
+	}{
+		{name: "storeが空なら空配列を返す",
+			store: &futurePositionStore{store: map[string]*futurePosition{}},
+			want:  []*futurePosition{}},
+		{name: "storeが空でないなら配列にして返す",
+			store: &futurePositionStore{store: map[string]*futurePosition{
+				"pos_1234": {Code: "pos_1234"},
+				"pos_2345": {Code: "pos_2345"},
+				"pos_3456": {Code: "pos_3456"},
+			}},
+			want: []*futurePosition{
+				{Code: "pos_1234"},
+				{Code: "pos_2345"},
+				{Code: "pos_3456"},
+			}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.store.getAll()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futurePositionStore_GetByCode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		store *futurePositionStore
+		arg   string
+		want1 *futurePosition
+		want2 error
+	}{
+		{name: "引数にマッチするデータがあればデータを返す",
+			store: &futurePositionStore{store: map[string]*futurePosition{
+				"pos_1234": {Code: "pos_1234"},
+				"pos_2345": {Code: "pos_2345"},
+				"pos_3456": {Code: "pos_3456"},
+			}},
+			arg:   "pos_2345",
+			want1: &futurePosition{Code: "pos_2345"},
+			want2: nil,
+		},
+		{name: "引数にマッチするデータがなければエラーを返す",
+			store: &futurePositionStore{store: map[string]*futurePosition{
+				"pos_1234": {Code: "pos_1234"},
+				"pos_2345": {Code: "pos_2345"},
+				"pos_3456": {Code: "pos_3456"},
+			}},
+			arg:   "pos_0000",
+			want1: nil,
+			want2: NoDataError,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got1, got2 := test.store.getByCode(test.arg)
+			if !reflect.DeepEqual(test.want1, got1) || !errors.Is(got2, test.want2) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want1, test.want2, got1, got2)
+			}
+		})
+	}
+}
+
+func Test_futurePositionStore_Save(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		store *futurePositionStore
+		arg   *futurePosition
+		want  map[string]*futurePosition
+	}{
+		{name: "引数がnilなら何もしない",
+			store: &futurePositionStore{
+				store: map[string]*futurePosition{
+					"pos_1234": {Code: "pos_1234"},
+					"pos_2345": {Code: "pos_2345"},
+					"pos_3456": {Code: "pos_3456"},
+				}},
+			arg: nil,
+			want: map[string]*futurePosition{
+				"pos_1234": {Code: "pos_1234"},
+				"pos_2345": {Code: "pos_2345"},
+				"pos_3456": {Code: "pos_3456"},
+			}},
+		{name: "キーが重複しなければ新しいデータが追加される",
+			store: &futurePositionStore{
+				store: map[string]*futurePosition{
+					"pos_1234": {Code: "pos_1234"},
+					"pos_2345": {Code: "pos_2345"},
+					"pos_3456": {Code: "pos_3456"},
+				}},
+			arg: &futurePosition{Code: "pos_9999"},
+			want: map[string]*futurePosition{
+				"pos_1234": {Code: "pos_1234"},
+				"pos_2345": {Code: "pos_2345"},
+				"pos_3456": {Code: "pos_3456"},
+				"pos_9999": {Code: "pos_9999"},
+			}},
+		{name: "キーが重複したら新しいデータを上書きする",
+			store: &futurePositionStore{
+				store: map[string]*futurePosition{
+					"pos_1234": {Code: "pos_1234"},
+					"pos_2345": {Code: "pos_2345"},
+					"pos_3456": {Code: "pos_3456"},
+				}},
+			arg: &futurePosition{Code: "pos_2345", OrderCode: "ord_5555"},
+			want: map[string]*futurePosition{
+				"pos_1234": {Code: "pos_1234"},
+				"pos_2345": {Code: "pos_2345", OrderCode: "ord_5555"},
+				"pos_3456": {Code: "pos_3456"},
+			}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			test.store.save(test.arg)
+			got := test.store.store
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futurePositionStore_RemoveByCode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		store *futurePositionStore
+		arg   string
+		want  map[string]*futurePosition
+	}{
+		{name: "指定したコードがなければ何もしない",
+			store: &futurePositionStore{
+				store: map[string]*futurePosition{
+					"pos_1234": {Code: "pos_1234"},
+					"pos_2345": {Code: "pos_2345"},
+					"pos_3456": {Code: "pos_3456"},
+				}},
+			arg: "pos_0000",
+			want: map[string]*futurePosition{
+				"pos_1234": {Code: "pos_1234"},
+				"pos_2345": {Code: "pos_2345"},
+				"pos_3456": {Code: "pos_3456"},
+			}},
+		{name: "指定したコードがあればstoreから消す",
+			store: &futurePositionStore{
+				store: map[string]*futurePosition{
+					"pos_1234": {Code: "pos_1234"},
+					"pos_2345": {Code: "pos_2345"},
+					"pos_3456": {Code: "pos_3456"},
+				}},
+			arg: "pos_2345",
+			want: map[string]*futurePosition{
+				"pos_1234": {Code: "pos_1234"},
+				"pos_3456": {Code: "pos_3456"},
+			}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			test.store.removeByCode(test.arg)
+			got := test.store.store
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futurePositionStore_getBySymbolCode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		store iFuturePositionStore
+		arg   string
+		want1 []*futurePosition
+		want2 error
+	}{
+		{name: "storeにデータがないなら空配列が返される",
+			store: &futurePositionStore{store: map[string]*futurePosition{}},
+			arg:   "1234",
+			want1: []*futurePosition{}},
+		{name: "storeに指定した銘柄コードと一致するデータがないなら空配列が返される",
+			store: &futurePositionStore{store: map[string]*futurePosition{
+				"spo-1": {Code: "spo-1", SymbolCode: "0001"},
+				"spo-2": {Code: "spo-2", SymbolCode: "0002"},
+				"spo-3": {Code: "spo-3", SymbolCode: "0003"},
+			}},
+			arg:   "1234",
+			want1: []*futurePosition{}},
+		{name: "storeに指定した銘柄コードと一致するデータがあればポジションコード順に並べて返される",
+			store: &futurePositionStore{store: map[string]*futurePosition{
+				"spo-1": {Code: "spo-1", SymbolCode: "1234"},
+				"spo-2": {Code: "spo-2", SymbolCode: "0002"},
+				"spo-3": {Code: "spo-3", SymbolCode: "1234"},
+			}},
+			arg: "1234",
+			want1: []*futurePosition{
+				{Code: "spo-1", SymbolCode: "1234"},
+				{Code: "spo-3", SymbolCode: "1234"}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got1, got2 := test.store.getBySymbolCode(test.arg)
+			if !reflect.DeepEqual(test.want1, got1) || !errors.Is(got2, test.want2) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want1, test.want2, got1, got2)
+			}
+		})
+	}
+}
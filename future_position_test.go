@@ -0,0 +1,302 @@
+package virtual_security
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_futurePosition_exitable(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		position *futurePosition
+		arg      float64
+		want     error
+	}{
+		{name: "保有数不足でエグジットできないなら、エラーを返す",
+			position: &futurePosition{OwnedQuantity: 300, HoldQuantity: 300},
+			arg:      500,
+			want:     NotEnoughOwnedQuantityError},
+		{name: "拘束数不足でエグジットできないなら、エラーを返す",
+			position: &futurePosition{OwnedQuantity: 300, HoldQuantity: 200},
+			arg:      300,
+			want:     NotEnoughHoldQuantityError},
+		{name: "exit可能ならnilを返す",
+			position: &futurePosition{OwnedQuantity: 300, HoldQuantity: 300},
+			arg:      300,
+			want:     nil},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.position.exitable(test.arg)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futurePosition_exit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name              string
+		position          *futurePosition
+		arg               float64
+		wantOwnedQuantity float64
+		wantHoldQuantity  float64
+		want              error
+	}{
+		{name: "エグジットできるなら保有数と拘束数を減らす",
+			position:          &futurePosition{OwnedQuantity: 300, HoldQuantity: 200},
+			arg:               100,
+			wantOwnedQuantity: 200,
+			wantHoldQuantity:  100,
+			want:              nil},
+		{name: "保有数不足でエグジットできないなら、エラーを返す",
+			position:          &futurePosition{OwnedQuantity: 300, HoldQuantity: 300},
+			arg:               500,
+			wantOwnedQuantity: 300,
+			wantHoldQuantity:  300,
+			want:              NotEnoughOwnedQuantityError},
+		{name: "拘束数不足でエグジットできないなら、エラーを返す",
+			position:          &futurePosition{OwnedQuantity: 300, HoldQuantity: 200},
+			arg:               300,
+			wantOwnedQuantity: 300,
+			wantHoldQuantity:  200,
+			want:              NotEnoughHoldQuantityError},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.position.exit(test.arg)
+			if !reflect.DeepEqual(test.wantOwnedQuantity, test.position.OwnedQuantity) ||
+				!reflect.DeepEqual(test.wantHoldQuantity, test.position.HoldQuantity) ||
+				!errors.Is(got, test.want) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(),
+					test.wantOwnedQuantity, test.wantHoldQuantity, test.want,
+					test.position.OwnedQuantity, test.position.HoldQuantity, got)
+			}
+		})
+	}
+}
+
+func Test_futurePosition_holdable(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		position *futurePosition
+		arg      float64
+		want     error
+	}{
+		{name: "拘束できないなら、エラーを返す",
+			position: &futurePosition{OwnedQuantity: 300, HoldQuantity: 100},
+			arg:      300,
+			want:     NotEnoughOwnedQuantityError},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.position.holdable(test.arg)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futurePosition_hold(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name             string
+		position         *futurePosition
+		arg              float64
+		wantHoldQuantity float64
+		want             error
+	}{
+		{name: "拘束できるなら拘束数を増やす",
+			position:         &futurePosition{OwnedQuantity: 300, HoldQuantity: 200},
+			arg:              100,
+			wantHoldQuantity: 300,
+			want:             nil},
+		{name: "拘束できないなら拘束数を増やさず、エラーを返す",
+			position:         &futurePosition{OwnedQuantity: 300, HoldQuantity: 100},
+			arg:              300,
+			wantHoldQuantity: 100,
+			want:             NotEnoughOwnedQuantityError},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.position.hold(test.arg)
+			if !reflect.DeepEqual(test.wantHoldQuantity, test.position.HoldQuantity) || !errors.Is(got, test.want) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.wantHoldQuantity, test.want, test.position.HoldQuantity, got)
+			}
+		})
+	}
+}
+
+func Test_futurePosition_release(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name             string
+		position         *futurePosition
+		arg              float64
+		wantHoldQuantity float64
+		want             error
+	}{
+		{name: "拘束を解放できるなら拘束数を減らす",
+			position:         &futurePosition{HoldQuantity: 300},
+			arg:              100,
+			wantHoldQuantity: 200,
+			want:             nil},
+		{name: "拘束を解放できないなら拘束数を減らさず、エラーを返す",
+			position:         &futurePosition{HoldQuantity: 100},
+			arg:              200,
+			wantHoldQuantity: 100,
+			want:             NotEnoughHoldQuantityError},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.position.release(test.arg)
+			if !reflect.DeepEqual(test.wantHoldQuantity, test.position.HoldQuantity) || !errors.Is(got, test.want) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.wantHoldQuantity, test.want, test.position.HoldQuantity, got)
+			}
+		})
+	}
+}
+
+func Test_futurePosition_isDied(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name           string
+		futurePosition *futurePosition
+		want           bool
+	}{
+		{name: "保有数がなければ死んでいる", futurePosition: &futurePosition{OwnedQuantity: 0}, want: true},
+		{name: "保有数があれば生きている", futurePosition: &futurePosition{OwnedQuantity: 100}, want: false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.futurePosition.isDied()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futurePosition_orderableQuantity(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		position *futurePosition
+		want     float64
+	}{
+		{name: "保有数と拘束数が同じなら0", position: &futurePosition{OwnedQuantity: 300, HoldQuantity: 300}, want: 0},
+		{name: "拘束されていなければ保有数がそのまま出る", position: &futurePosition{OwnedQuantity: 300, HoldQuantity: 0}, want: 300},
+		{name: "部分的に拘束されているなら、保有数-拘束数の値が出る", position: &futurePosition{OwnedQuantity: 300, HoldQuantity: 200}, want: 100},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.position.orderableQuantity()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futurePosition_profit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		position *futurePosition
+		price    float64
+		quantity float64
+		want     float64
+	}{
+		{name: "買いポジションは値洗いの基準より高ければ利益",
+			position: &futurePosition{Side: SideBuy, Price: 28000, SettlementPrice: 28100, Multiplier: 100},
+			price:    28200,
+			quantity: 2,
+			want:     20_000},
+		{name: "買いポジションは値洗いの基準より安ければ損失",
+			position: &futurePosition{Side: SideBuy, Price: 28000, SettlementPrice: 28000, Multiplier: 100},
+			price:    27900,
+			quantity: 1,
+			want:     -10_000},
+		{name: "売りポジションは値洗いの基準より安ければ利益",
+			position: &futurePosition{Side: SideSell, Price: 28000, SettlementPrice: 28000, Multiplier: 1000},
+			price:    27900,
+			quantity: 1,
+			want:     100_000},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.position.profit(test.price, test.quantity)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futurePosition_settle(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		position     *futurePosition
+		price        float64
+		want         float64
+		wantPosition *futurePosition
+	}{
+		{name: "保有数量の分だけ値洗いし、値洗いの基準を清算値にする",
+			position:     &futurePosition{Side: SideBuy, OwnedQuantity: 3, HoldQuantity: 1, Price: 28000, SettlementPrice: 28000, Multiplier: 100},
+			price:        28050,
+			want:         15_000,
+			wantPosition: &futurePosition{Side: SideBuy, OwnedQuantity: 3, HoldQuantity: 1, Price: 28000, SettlementPrice: 28050, Multiplier: 100}},
+		{name: "保有数量がなければ値洗いしない",
+			position:     &futurePosition{Side: SideBuy, OwnedQuantity: 0, Price: 28000, SettlementPrice: 28000, Multiplier: 100},
+			price:        28050,
+			want:         0,
+			wantPosition: &futurePosition{Side: SideBuy, OwnedQuantity: 0, Price: 28000, SettlementPrice: 28000, Multiplier: 100}},
+		{name: "清算値がなければ値洗いしない",
+			position:     &futurePosition{Side: SideSell, OwnedQuantity: 1, Price: 28000, SettlementPrice: 28000, Multiplier: 100},
+			price:        0,
+			want:         0,
+			wantPosition: &futurePosition{Side: SideSell, OwnedQuantity: 1, Price: 28000, SettlementPrice: 28000, Multiplier: 100}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.position.settle(test.price)
+			if !reflect.DeepEqual(test.want, got) || !reflect.DeepEqual(test.wantPosition, test.position) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want, test.wantPosition, got, test.position)
+			}
+		})
+	}
+}
@@ -0,0 +1,588 @@
+package virtual_security
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+func newFutureService(
+	clock iClock,
+	uuidGenerator iUUIDGenerator,
+	futureOrderStore iFutureOrderStore,
+	futurePositionStore iFuturePositionStore,
+	futureSymbolStore iFutureSymbolStore,
+	validatorComponent iValidatorComponent,
+	stockContractComponent iStockContractComponent,
+	accountService iAccountService,
+) iFutureService {
+	return &futureService{
+		clock:                  clock,
+		uuidGenerator:          uuidGenerator,
+		futureOrderStore:       futureOrderStore,
+		futurePositionStore:    futurePositionStore,
+		futureSymbolStore:      futureSymbolStore,
+		validatorComponent:     validatorComponent,
+		stockContractComponent: stockContractComponent,
+		accountService:         accountService,
+	}
+}
+
+type iFutureService interface {
+	registerSymbol(symbol *FutureSymbol) error
+	toFutureOrder(order *FutureOrderRequest, now time.Time) *futureOrder
+	validation(order *futureOrder, now time.Time) error
+	confirmContract(order *futureOrder, price *symbolPrice, now time.Time) error
+	holdExitOrderPositions(order *futureOrder) error
+	getFutureOrders() []*futureOrder
+	getFutureOrderByCode(orderCode string) (*futureOrder, error)
+	saveFutureOrder(order *futureOrder)
+	removeFutureOrderByCode(orderCode string)
+	getFuturePositions() []*futurePosition
+	removeFuturePositionByCode(positionCode string)
+	cancelAndRelease(order *futureOrder, now time.Time) error
+	isExpired(order *futureOrder, now time.Time) bool
+	settle(price *symbolPrice) error
+	settleSQ(symbolCode string, sqPrice float64, now time.Time) error
+	expireSymbols(now time.Time) error
+	isExpiredSymbol(symbolCode string, now time.Time) bool
+	getSymbols() []*futureSymbol
+	restore(orders []*futureOrder, positions []*futurePosition, symbols []*futureSymbol)
+}
+
+type futureService struct {
+	clock                  iClock
+	uuidGenerator          iUUIDGenerator
+	futureOrderStore       iFutureOrderStore
+	futurePositionStore    iFuturePositionStore
+	futureSymbolStore      iFutureSymbolStore
+	validatorComponent     iValidatorComponent
+	stockContractComponent iStockContractComponent
+	accountService         iAccountService
+}
+
+func (s *futureService) newOrderCode() string {
+	return "for-" + s.uuidGenerator.generate()
+}
+
+func (s *futureService) newContractCode() string {
+	return "fco-" + s.uuidGenerator.generate()
+}
+
+func (s *futureService) newPositionCode() string {
+	return "fpo-" + s.uuidGenerator.generate()
+}
+
+// registerSymbol - 先物銘柄を登録する
+//   登録済みの銘柄なら、清算値などの状態は引き継いで取引単位と取引最終日を更新する
+func (s *futureService) registerSymbol(symbol *FutureSymbol) error {
+	if symbol == nil {
+		return NilArgumentError
+	}
+	if symbol.SymbolCode == "" {
+		return InvalidSymbolCodeError
+	}
+	if symbol.Multiplier <= 0 {
+		return InvalidMultiplierError
+	}
+
+	lastTradingDay := symbol.LastTradingDay
+	if !lastTradingDay.IsZero() {
+		lastTradingDay = time.Date(lastTradingDay.Year(), lastTradingDay.Month(), lastTradingDay.Day(), 0, 0, 0, 0, time.Local)
+	}
+
+	if registered, err := s.futureSymbolStore.getByCode(symbol.SymbolCode); err == nil {
+		registered.Multiplier = symbol.Multiplier
+		registered.LastTradingDay = lastTradingDay
+		return nil
+	}
+	s.futureSymbolStore.save(&futureSymbol{
+		SymbolCode:     symbol.SymbolCode,
+		Multiplier:     symbol.Multiplier,
+		LastTradingDay: lastTradingDay,
+	})
+	return nil
+}
+
+func (s *futureService) toFutureOrder(order *FutureOrderRequest, now time.Time) *futureOrder {
+	if order == nil {
+		return nil
+	}
+
+	o := &futureOrder{
+		Code:               s.newOrderCode(),
+		OrderStatus:        OrderStatusInOrder,
+		TradeType:          order.TradeType,
+		Side:               order.Side,
+		ExecutionCondition: order.ExecutionCondition,
+		SymbolCode:         order.SymbolCode,
+		OrderQuantity:      order.Quantity,
+		LimitPrice:         order.LimitPrice,
+		ExpiredAt:          time.Time{},
+		StopCondition:      order.StopCondition,
+		OrderedAt:          now,
+		ExitPositionList:   order.ExitPositionList,
+		Contracts:          []*Contract{},
+	}
+
+	// 逆指値注文は逆指値条件を満たすまで待機させる
+	if o.ExecutionCondition.IsStop() {
+		o.OrderStatus = OrderStatusWait
+	}
+
+	// 有効期限の指定がなければ、注文した営業日の間だけ有効にする
+	//   取引最終日を過ぎても約定しないように、有効期限は銘柄の取引最終日までにする
+	o.ExpiredAt = orderExpiredAt(s.clock, ExchangeTypeFuture, order.ExpiredAt, order.ExpireBusinessDays, now)
+	if symbol, err := s.futureSymbolStore.getByCode(order.SymbolCode); err == nil && !symbol.LastTradingDay.IsZero() && o.ExpiredAt.After(symbol.LastTradingDay) {
+		o.ExpiredAt = symbol.LastTradingDay
+	}
+	return o
+}
+
+func (s *futureService) validation(order *futureOrder, now time.Time) error {
+	if order == nil {
+		return NilArgumentError
+	}
+
+	symbol, err := s.futureSymbolStore.getByCode(order.SymbolCode)
+	if err != nil && err != NoDataError {
+		return err
+	}
+	return s.validatorComponent.isValidFutureOrder(order, s.clock.getBusinessDay(ExchangeTypeFuture, now), symbol, s.futurePositionStore.getAll())
+}
+
+func (s *futureService) confirmContract(order *futureOrder, price *symbolPrice, now time.Time) error {
+	// 最低限のvalidation
+	if order == nil || price == nil {
+		return NilArgumentError
+	}
+
+	// 取引最終日を過ぎた銘柄の注文は約定させずに取り消す
+	if order.SymbolCode == price.SymbolCode && order.OrderStatus.IsCancelable() {
+		if symbol, err := s.futureSymbolStore.getByCode(order.SymbolCode); err == nil && !symbol.isTradable(s.clock.getBusinessDay(ExchangeTypeFuture, now)) {
+			order.Message = expiredSymbolCancelMessage
+			return s.cancelAndRelease(order, now)
+		}
+	}
+
+	// 待機中の逆指値注文なら、発動条件を満たしているかを確認する
+	order.activate(price, now)
+
+	switch order.TradeType {
+	case TradeTypeEntry:
+		return s.entry(order, price, now)
+	case TradeTypeExit:
+		return s.exit(order, price, now)
+	default:
+		return InvalidTradeTypeError
+	}
+}
+
+func (s *futureService) entry(order *futureOrder, price *symbolPrice, now time.Time) error {
+	// 最低限のvalidation
+	if order == nil || price == nil {
+		return NilArgumentError
+	}
+
+	symbol, err := s.futureSymbolStore.getByCode(order.SymbolCode)
+	if err != nil {
+		return fmt.Errorf("symbol code: %s: %w", order.SymbolCode, err)
+	}
+
+	// 約定可能かのチェック
+	contractResult := s.stockContractComponent.confirmFutureOrderContract(order, price, now)
+	if !contractResult.isContracted {
+		return nil
+	}
+
+	// 板を複数の気配値で約定した場合は、気配値ごとに約定とポジションを作る
+	for _, fill := range contractResult.contractFills() {
+		contractCode := s.newContractCode()
+		positionCode := s.newPositionCode()
+		contract := &Contract{
+			ContractCode: contractCode,
+			OrderCode:    order.Code,
+			PositionCode: positionCode,
+			Price:        fill.price,
+			Quantity:     fill.quantity,
+			ContractedAt: contractResult.contractedAt,
+		}
+		order.contract(contract)
+		s.accountService.chargeContractFee(newFeeTarget(ExchangeTypeFuture, order.SymbolCode, order.Side, TradeTypeEntry, symbol.Multiplier, contract), contract)
+
+		// 約定ごとにポジションを作る
+		s.futurePositionStore.save(&futurePosition{
+			Code:               positionCode,
+			OrderCode:          order.Code,
+			SymbolCode:         order.SymbolCode,
+			Side:               order.Side,
+			ContractedQuantity: contract.Quantity,
+			OwnedQuantity:      contract.Quantity,
+			Price:              fill.price,
+			SettlementPrice:    fill.price,
+			Multiplier:         symbol.Multiplier,
+			ContractedAt:       contractResult.contractedAt,
+			mtx:                sync.Mutex{},
+		})
+	}
+
+	return nil
+}
+
+func (s *futureService) exit(order *futureOrder, price *symbolPrice, now time.Time) error {
+	// 最低限のvalidation
+	if order == nil || price == nil {
+		return NilArgumentError
+	}
+
+	// 約定可能かのチェック
+	contractResult := s.stockContractComponent.confirmFutureOrderContract(order, price, now)
+	if !contractResult.isContracted {
+		return nil
+	}
+
+	// 指定されたポジションの一覧を取得し、約定した数量の分だけ先頭から返済する数量を決める
+	//   板を複数の気配値で約定した場合は、気配値ごとに返済する数量を決める
+	positions := make(map[string]*futurePosition)
+	quantities := make(map[string]float64)
+	var exits []*futureExit
+	for _, fill := range contractResult.contractFills() {
+		contractQuantity := fill.quantity
+		for _, ep := range order.ExitPositionList {
+			if contractQuantity <= 0 {
+				break
+			}
+
+			// 一部約定で返済済みの数量と、他の気配値で返済する数量は除く
+			quantity := ep.Quantity - order.exitedQuantity(ep.PositionCode) - quantities[ep.PositionCode]
+			if quantity <= 0 {
+				continue
+			}
+			if contractQuantity < quantity {
+				quantity = contractQuantity
+			}
+
+			p, ok := positions[ep.PositionCode]
+			if !ok {
+				var err error
+				p, err = s.futurePositionStore.getByCode(ep.PositionCode)
+				if err != nil {
+					return fmt.Errorf("position code: %s: %w", ep.PositionCode, err)
+				}
+			}
+			if err := p.exitable(quantities[ep.PositionCode] + quantity); err != nil {
+				return fmt.Errorf("position code: %s: %w", ep.PositionCode, err)
+			}
+			positions[p.Code] = p
+			quantities[p.Code] += quantity
+			exits = append(exits, &futureExit{position: p, price: fill.price, quantity: quantity})
+			contractQuantity -= quantity
+		}
+	}
+
+	for _, e := range exits {
+		if err := s.exitPosition(order, e, contractResult.contractedAt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// futureExit - 約定した気配値ごとのポジションの返済内容
+type futureExit struct {
+	position *futurePosition
+	price    float64
+	quantity float64
+}
+
+// exitPosition - ポジションを返済し、値洗いの基準になる価格からの損益を口座に反映する
+//   ポジションを返済できなければ、注文や口座を変更せずにエラーを返す
+func (s *futureService) exitPosition(order *futureOrder, e *futureExit, contractedAt time.Time) error {
+	// 返済前に損益を計算しておく
+	profit := e.position.profit(e.price, e.quantity)
+
+	// ポジションの保有数量を返済する
+	if err := e.position.exit(e.quantity); err != nil {
+		return fmt.Errorf("position code: %s: %w", e.position.Code, err)
+	}
+	order.addExitPosition(e.position.Code, e.quantity) // 注文による返済数に加算しておく
+
+	// 注文に約定情報を追加
+	contractCode := s.newContractCode()
+	contract := &Contract{
+		ContractCode: contractCode,
+		OrderCode:    order.Code,
+		PositionCode: e.position.Code,
+		Price:        e.price,
+		Quantity:     e.quantity,
+		ContractedAt: contractedAt,
+	}
+	order.contract(contract)
+
+	// 返済で確定した損益を口座に反映する
+	s.accountService.settleFutureProfit(profit)
+	s.accountService.chargeContractFee(newFeeTarget(ExchangeTypeFuture, order.SymbolCode, order.Side, TradeTypeExit, e.position.Multiplier, contract), contract)
+	return nil
+}
+
+func (s *futureService) holdExitOrderPositions(order *futureOrder) error {
+	// 最低限のvalidation
+	if order == nil {
+		return NilArgumentError
+	}
+	if order.TradeType != TradeTypeExit {
+		return InvalidTradeTypeError
+	}
+	if order.ExitPositionList == nil || len(order.ExitPositionList) == 0 {
+		return InvalidExitPositionError
+	}
+
+	// positionをhold可能かのチェック
+	//   同じポジションが複数回指定されていれば、合計した数量をholdできる必要がある
+	posList := make([]*futurePosition, len(order.ExitPositionList))
+	holdQuantities := map[string]float64{}
+	for i, exit := range order.ExitPositionList {
+		pos, err := s.futurePositionStore.getByCode(exit.PositionCode)
+		if err != nil {
+			return fmt.Errorf("error position_code = %s: %w", exit.PositionCode, err)
+		}
+		holdQuantities[exit.PositionCode] += exit.Quantity
+		if err := pos.holdable(holdQuantities[exit.PositionCode]); err != nil {
+			return fmt.Errorf("error position_code = %s, owned_quantity = %.2f, hold_quantity = %.2f, exit_quantity = %.2f: %w",
+				exit.PositionCode, pos.OwnedQuantity, pos.HoldQuantity, holdQuantities[exit.PositionCode], err)
+		}
+		posList[i] = pos
+	}
+
+	// チェック済みのポジションをholdする
+	for i, exit := range order.ExitPositionList {
+		pos := posList[i]
+		if err := pos.hold(exit.Quantity); err != nil {
+			return fmt.Errorf("error position_code = %s: %w", exit.PositionCode, err)
+		}
+		order.addHoldPosition(pos.Code, exit.Quantity)
+	}
+
+	return nil
+}
+
+func (s *futureService) getFutureOrders() []*futureOrder {
+	return s.futureOrderStore.getAll()
+}
+
+func (s *futureService) getFutureOrderByCode(orderCode string) (*futureOrder, error) {
+	return s.futureOrderStore.getByCode(orderCode)
+}
+
+func (s *futureService) saveFutureOrder(order *futureOrder) {
+	s.futureOrderStore.save(order)
+}
+
+func (s *futureService) removeFutureOrderByCode(orderCode string) {
+	s.futureOrderStore.removeByCode(orderCode)
+}
+
+func (s *futureService) getFuturePositions() []*futurePosition {
+	return s.futurePositionStore.getAll()
+}
+
+func (s *futureService) removeFuturePositionByCode(positionCode string) {
+	s.futurePositionStore.removeByCode(positionCode)
+}
+
+// getSymbols - 登録されている先物銘柄の一覧
+func (s *futureService) getSymbols() []*futureSymbol {
+	return s.futureSymbolStore.getAll()
+}
+
+// restore - 注文、ポジション、銘柄をストアに戻す
+//   ストアにある注文とポジションは消してから戻し、銘柄は上書きする
+func (s *futureService) restore(orders []*futureOrder, positions []*futurePosition, symbols []*futureSymbol) {
+	for _, o := range s.futureOrderStore.getAll() {
+		s.futureOrderStore.removeByCode(o.Code)
+	}
+	for _, p := range s.futurePositionStore.getAll() {
+		s.futurePositionStore.removeByCode(p.Code)
+	}
+
+	for _, o := range orders {
+		if o == nil {
+			continue
+		}
+		o.StopCondition.restoreActivation()
+		s.futureOrderStore.save(o)
+	}
+	for _, p := range positions {
+		s.futurePositionStore.save(p)
+	}
+	for _, symbol := range symbols {
+		s.futureSymbolStore.save(symbol)
+	}
+}
+
+func (s *futureService) cancelAndRelease(order *futureOrder, now time.Time) error {
+	if order == nil {
+		return NilArgumentError
+	}
+	if !order.OrderStatus.IsCancelable() {
+		return UncancellableOrderError
+	}
+	order.cancel(now)
+
+	// 返済注文なら拘束したポジションを開放する
+	var res error
+	if order.TradeType == TradeTypeExit {
+		for _, hp := range order.HoldPositions {
+			// Exit済みならスキップ
+			if hp.HoldQuantity-hp.ExitQuantity == 0 {
+				continue
+			}
+			pos, err := s.futurePositionStore.getByCode(hp.PositionCode)
+			if err != nil {
+				res = fmt.Errorf("拘束中の一部のポジションを解放できませんでした: %w", err)
+				continue
+			}
+			if err := pos.release(hp.HoldQuantity - hp.ExitQuantity); err != nil {
+				res = fmt.Errorf("拘束中の一部のポジションを解放できませんでした: %w", err)
+			}
+			hp.HoldQuantity = hp.ExitQuantity
+		}
+	}
+
+	return res
+}
+
+// isExpired - 注文が有効期限切れかを、先物の営業日でチェックする
+func (s *futureService) isExpired(order *futureOrder, now time.Time) bool {
+	if order == nil {
+		return false
+	}
+	return order.isExpired(s.clock.getBusinessDay(ExchangeTypeFuture, now))
+}
+
+// settle - 日中の引けの価格を清算値として、銘柄のポジションを値洗いする
+//   値洗いで確定した損益は口座に反映し、同じ営業日に2回以上は値洗いしない
+func (s *futureService) settle(price *symbolPrice) error {
+	if price == nil {
+		return NilArgumentError
+	}
+	if price.ExchangeType != ExchangeTypeFuture || price.session != SessionDay || price.Price <= 0 ||
+		(price.kind != PriceKindClosing && price.kind != PriceKindOpeningAndClosing) {
+		return nil
+	}
+
+	symbol, err := s.futureSymbolStore.getByCode(price.SymbolCode)
+	if err != nil {
+		return fmt.Errorf("symbol code: %s: %w", price.SymbolCode, err)
+	}
+	if symbol.SettledDay.Equal(price.priceBusinessDay) {
+		return nil
+	}
+	symbol.SettlementPrice = price.Price
+	symbol.SettledDay = price.priceBusinessDay
+
+	positions, err := s.futurePositionStore.getBySymbolCode(price.SymbolCode)
+	if err != nil {
+		return err
+	}
+	for _, p := range positions {
+		s.accountService.settleFutureProfit(p.settle(price.Price))
+	}
+	return nil
+}
+
+// expiredSymbolCancelMessage - 取引最終日を過ぎた銘柄の注文を取り消したときのメッセージ
+const expiredSymbolCancelMessage = "取引最終日を過ぎたため取消"
+
+// expireSymbols - 取引最終日を過ぎた銘柄の有効な注文を取り消す
+//   SQ値はわからないので、残っているポジションはSettleFutureSQで決済されるまで期限切れの銘柄のポジションとして残す
+func (s *futureService) expireSymbols(now time.Time) error {
+	businessDay := s.clock.getBusinessDay(ExchangeTypeFuture, now)
+
+	var res error
+	for _, symbol := range s.futureSymbolStore.getAll() {
+		if symbol.isTradable(businessDay) {
+			continue
+		}
+
+		for _, o := range s.futureOrderStore.getAll() {
+			if o.SymbolCode == symbol.SymbolCode && o.OrderStatus.IsCancelable() {
+				o.Message = expiredSymbolCancelMessage
+				if err := s.cancelAndRelease(o, now); err != nil {
+					res = err
+				}
+			}
+		}
+	}
+	return res
+}
+
+// isExpiredSymbol - 取引最終日を過ぎてもSQで決済されていない銘柄か
+func (s *futureService) isExpiredSymbol(symbolCode string, now time.Time) bool {
+	symbol, err := s.futureSymbolStore.getByCode(symbolCode)
+	if err != nil {
+		return false
+	}
+	return !symbol.IsSQSettled && !symbol.isTradable(s.clock.getBusinessDay(ExchangeTypeFuture, now))
+}
+
+// settleSQ - SQ値で銘柄の注文を取り消し、ポジションをすべて決済する
+//   決済は返済注文として記録し、値洗いの基準になる価格からの損益を口座に反映する
+func (s *futureService) settleSQ(symbolCode string, sqPrice float64, now time.Time) error {
+	if sqPrice <= 0 {
+		return InvalidPriceError
+	}
+
+	symbol, err := s.futureSymbolStore.getByCode(symbolCode)
+	if err != nil {
+		return fmt.Errorf("symbol code: %s: %w", symbolCode, err)
+	}
+	if symbol.IsSQSettled {
+		return fmt.Errorf("symbol code: %s: %w", symbolCode, ExpiredSymbolError)
+	}
+	symbol.IsSQSettled = true
+
+	// 有効な注文は取り消して、拘束しているポジションを開放する
+	for _, o := range s.futureOrderStore.getAll() {
+		if o.SymbolCode == symbolCode && o.OrderStatus.IsCancelable() {
+			_ = s.cancelAndRelease(o, now)
+		}
+	}
+
+	positions, err := s.futurePositionStore.getBySymbolCode(symbolCode)
+	if err != nil {
+		return err
+	}
+	for _, p := range positions {
+		quantity := p.orderableQuantity()
+		if quantity <= 0 {
+			continue
+		}
+		_ = p.hold(quantity)
+
+		side := SideSell
+		if p.Side == SideSell {
+			side = SideBuy
+		}
+		o := &futureOrder{
+			Code:               s.newOrderCode(),
+			OrderStatus:        OrderStatusInOrder,
+			TradeType:          TradeTypeExit,
+			Side:               side,
+			ExecutionCondition: FutureExecutionConditionMO,
+			SymbolCode:         symbolCode,
+			OrderQuantity:      quantity,
+			OrderedAt:          now,
+			ExitPositionList:   []ExitPosition{{PositionCode: p.Code, Quantity: quantity}},
+			Contracts:          []*Contract{},
+			Message:            "SQによる決済",
+		}
+		o.addHoldPosition(p.Code, quantity)
+		if err := s.exitPosition(o, &futureExit{position: p, price: sqPrice, quantity: quantity}, now); err != nil {
+			return err
+		}
+		s.futureOrderStore.save(o)
+	}
+	return nil
+}
@@ -0,0 +1,755 @@
+package virtual_security
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testFutureService struct {
+	iFutureService
+	registerSymbol1             error
+	registerSymbolHistory       []*FutureSymbol
+	toFutureOrder1              *futureOrder
+	validation1                 error
+	confirmContract1            error
+	confirmContractCount        int
+	holdExitOrderPositions1     error
+	holdExitOrderPositionsCount int
+	getFutureOrders1            []*futureOrder
+	getFutureOrderByCode1       *futureOrder
+	getFutureOrderByCode2       error
+	saveFutureOrderHistory      []*futureOrder
+	getFuturePositions1         []*futurePosition
+	cancelAndRelease1           error
+	cancelAndReleaseCount       int
+	isExpired1                  bool
+	settle1                     error
+	settleCount                 int
+	settleSQ1                   error
+	settleSQHistory             []string
+	expireSymbols1              error
+	expireSymbolsCount          int
+	isExpiredSymbol1            bool
+	getSymbols1                 []*futureSymbol
+}
+
+func (t *testFutureService) registerSymbol(symbol *FutureSymbol) error {
+	t.registerSymbolHistory = append(t.registerSymbolHistory, symbol)
+	return t.registerSymbol1
+}
+func (t *testFutureService) toFutureOrder(*FutureOrderRequest, time.Time) *futureOrder {
+	return t.toFutureOrder1
+}
+func (t *testFutureService) validation(*futureOrder, time.Time) error { return t.validation1 }
+func (t *testFutureService) confirmContract(*futureOrder, *symbolPrice, time.Time) error {
+	t.confirmContractCount++
+	return t.confirmContract1
+}
+func (t *testFutureService) holdExitOrderPositions(*futureOrder) error {
+	t.holdExitOrderPositionsCount++
+	return t.holdExitOrderPositions1
+}
+func (t *testFutureService) getFutureOrders() []*futureOrder { return t.getFutureOrders1 }
+func (t *testFutureService) getFutureOrderByCode(string) (*futureOrder, error) {
+	return t.getFutureOrderByCode1, t.getFutureOrderByCode2
+}
+func (t *testFutureService) saveFutureOrder(order *futureOrder) {
+	t.saveFutureOrderHistory = append(t.saveFutureOrderHistory, order)
+}
+func (t *testFutureService) removeFutureOrderByCode(string)        {}
+func (t *testFutureService) getFuturePositions() []*futurePosition { return t.getFuturePositions1 }
+func (t *testFutureService) removeFuturePositionByCode(string)     {}
+func (t *testFutureService) cancelAndRelease(*futureOrder, time.Time) error {
+	t.cancelAndReleaseCount++
+	return t.cancelAndRelease1
+}
+func (t *testFutureService) isExpired(*futureOrder, time.Time) bool { return t.isExpired1 }
+func (t *testFutureService) settle(*symbolPrice) error {
+	t.settleCount++
+	return t.settle1
+}
+func (t *testFutureService) getSymbols() []*futureSymbol { return t.getSymbols1 }
+func (t *testFutureService) expireSymbols(time.Time) error {
+	t.expireSymbolsCount++
+	return t.expireSymbols1
+}
+func (t *testFutureService) isExpiredSymbol(string, time.Time) bool { return t.isExpiredSymbol1 }
+func (t *testFutureService) settleSQ(symbolCode string, _ float64, _ time.Time) error {
+	t.settleSQHistory = append(t.settleSQHistory, symbolCode)
+	return t.settleSQ1
+}
+
+func Test_futureService_newOrderCode(t *testing.T) {
+	t.Parallel()
+	want := "for-1234"
+	service := &futureService{uuidGenerator: &testUUIDGenerator{generator1: []string{"1234"}}}
+	got := service.newOrderCode()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_futureService_newContractCode(t *testing.T) {
+	t.Parallel()
+	want := "fco-1234"
+	service := &futureService{uuidGenerator: &testUUIDGenerator{generator1: []string{"1234"}}}
+	got := service.newContractCode()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_futureService_newPositionCode(t *testing.T) {
+	t.Parallel()
+	want := "fpo-1234"
+	service := &futureService{uuidGenerator: &testUUIDGenerator{generator1: []string{"1234"}}}
+	got := service.newPositionCode()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_newFutureService(t *testing.T) {
+	t.Parallel()
+	clock := &testClock{}
+	uuid := &testUUIDGenerator{}
+	orderStore := &testFutureOrderStore{}
+	positionStore := &testFuturePositionStore{}
+	symbolStore := &testFutureSymbolStore{}
+	validatorComponent := &testValidatorComponent{}
+	stockContractComponent := &testStockContractComponent{}
+	accountService := &testAccountService{}
+	want := &futureService{
+		clock:                  clock,
+		uuidGenerator:          uuid,
+		futureOrderStore:       orderStore,
+		futurePositionStore:    positionStore,
+		futureSymbolStore:      symbolStore,
+		validatorComponent:     validatorComponent,
+		stockContractComponent: stockContractComponent,
+		accountService:         accountService,
+	}
+	got := newFutureService(clock, uuid, orderStore, positionStore, symbolStore, validatorComponent, stockContractComponent, accountService)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_futureService_registerSymbol(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name            string
+		symbolStore     *testFutureSymbolStore
+		arg             *FutureSymbol
+		want            error
+		wantSaveHistory []*futureSymbol
+		wantRegistered  *futureSymbol
+	}{
+		{name: "引数がnilならエラー",
+			symbolStore: &testFutureSymbolStore{},
+			arg:         nil,
+			want:        NilArgumentError},
+		{name: "銘柄コードがなければエラー",
+			symbolStore: &testFutureSymbolStore{},
+			arg:         &FutureSymbol{Multiplier: 100},
+			want:        InvalidSymbolCodeError},
+		{name: "取引単位が0以下ならエラー",
+			symbolStore: &testFutureSymbolStore{},
+			arg:         &FutureSymbol{SymbolCode: "167060019"},
+			want:        InvalidMultiplierError},
+		{name: "未登録の銘柄なら取引最終日を日付に丸めて保存する",
+			symbolStore:     &testFutureSymbolStore{getByCode2: NoDataError},
+			arg:             &FutureSymbol{SymbolCode: "167060019", Multiplier: 100, LastTradingDay: time.Date(2021, 6, 10, 15, 0, 0, 0, time.Local)},
+			want:            nil,
+			wantSaveHistory: []*futureSymbol{{SymbolCode: "167060019", Multiplier: 100, LastTradingDay: time.Date(2021, 6, 10, 0, 0, 0, 0, time.Local)}}},
+		{name: "登録済みの銘柄なら清算値を引き継いで取引単位と取引最終日を更新する",
+			symbolStore:    &testFutureSymbolStore{getByCode1: &futureSymbol{SymbolCode: "167060019", Multiplier: 1000, SettlementPrice: 28000, SettledDay: time.Date(2021, 6, 9, 0, 0, 0, 0, time.Local)}},
+			arg:            &FutureSymbol{SymbolCode: "167060019", Multiplier: 100},
+			want:           nil,
+			wantRegistered: &futureSymbol{SymbolCode: "167060019", Multiplier: 100, SettlementPrice: 28000, SettledDay: time.Date(2021, 6, 9, 0, 0, 0, 0, time.Local)}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := &futureService{futureSymbolStore: test.symbolStore}
+			got := service.registerSymbol(test.arg)
+			if !errors.Is(got, test.want) ||
+				!reflect.DeepEqual(test.wantSaveHistory, test.symbolStore.saveHistory) ||
+				!reflect.DeepEqual(test.wantRegistered, test.symbolStore.getByCode1) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantSaveHistory, test.wantRegistered,
+					got, test.symbolStore.saveHistory, test.symbolStore.getByCode1)
+			}
+		})
+	}
+}
+
+func Test_futureService_toFutureOrder(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		service *futureService
+		arg1    *FutureOrderRequest
+		arg2    time.Time
+		want    *futureOrder
+	}{
+		{name: "nilを与えたらnilが返される",
+			service: &futureService{},
+			arg1:    nil,
+			want:    nil},
+		{name: "有効期限がなければ先物の営業日が有効期限になる",
+			service: &futureService{
+				clock:             &testClock{getBusinessDay1: time.Date(2021, 6, 7, 0, 0, 0, 0, time.Local)},
+				uuidGenerator:     &testUUIDGenerator{generator1: []string{"01"}},
+				futureSymbolStore: &testFutureSymbolStore{getByCode2: NoDataError}},
+			arg1: &FutureOrderRequest{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionMO, SymbolCode: "167060019", Quantity: 1},
+			arg2: time.Date(2021, 6, 4, 20, 0, 0, 0, time.Local),
+			want: &futureOrder{Code: "for-01", OrderStatus: OrderStatusInOrder, TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionMO, SymbolCode: "167060019", OrderQuantity: 1, ExpiredAt: time.Date(2021, 6, 7, 0, 0, 0, 0, time.Local), OrderedAt: time.Date(2021, 6, 4, 20, 0, 0, 0, time.Local), Contracts: []*Contract{}}},
+		{name: "有効期限があれば日付に丸めて有効期限にする",
+			service: &futureService{
+				uuidGenerator:     &testUUIDGenerator{generator1: []string{"01"}},
+				futureSymbolStore: &testFutureSymbolStore{getByCode2: NoDataError}},
+			arg1: &FutureOrderRequest{TradeType: TradeTypeEntry, Side: SideSell, ExecutionCondition: FutureExecutionConditionLO, SymbolCode: "167060019", Quantity: 2, LimitPrice: 29000, ExpiredAt: time.Date(2021, 6, 10, 15, 0, 0, 0, time.Local)},
+			arg2: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local),
+			want: &futureOrder{Code: "for-01", OrderStatus: OrderStatusInOrder, TradeType: TradeTypeEntry, Side: SideSell, ExecutionCondition: FutureExecutionConditionLO, SymbolCode: "167060019", OrderQuantity: 2, LimitPrice: 29000, ExpiredAt: time.Date(2021, 6, 10, 0, 0, 0, 0, time.Local), OrderedAt: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local), Contracts: []*Contract{}}},
+		{name: "逆指値注文なら待機状態にする",
+			service: &futureService{
+				clock:             &testClock{getBusinessDay1: time.Date(2021, 6, 4, 0, 0, 0, 0, time.Local)},
+				uuidGenerator:     &testUUIDGenerator{generator1: []string{"01"}},
+				futureSymbolStore: &testFutureSymbolStore{getByCode2: NoDataError}},
+			arg1: &FutureOrderRequest{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionStop, SymbolCode: "167060019", Quantity: 1, StopCondition: &FutureStopCondition{StopPrice: 29000, ComparisonOperator: ComparisonOperatorGE, ExecutionConditionAfterHit: FutureExecutionConditionMO}},
+			arg2: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local),
+			want: &futureOrder{Code: "for-01", OrderStatus: OrderStatusWait, TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionStop, SymbolCode: "167060019", OrderQuantity: 1, StopCondition: &FutureStopCondition{StopPrice: 29000, ComparisonOperator: ComparisonOperatorGE, ExecutionConditionAfterHit: FutureExecutionConditionMO}, ExpiredAt: time.Date(2021, 6, 4, 0, 0, 0, 0, time.Local), OrderedAt: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local), Contracts: []*Contract{}}},
+		{name: "有効期限が取引最終日より後なら取引最終日を有効期限にする",
+			service: &futureService{
+				uuidGenerator:     &testUUIDGenerator{generator1: []string{"01"}},
+				futureSymbolStore: &testFutureSymbolStore{getByCode1: &futureSymbol{SymbolCode: "167060019", LastTradingDay: time.Date(2021, 6, 10, 0, 0, 0, 0, time.Local)}}},
+			arg1: &FutureOrderRequest{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionLO, SymbolCode: "167060019", Quantity: 1, LimitPrice: 29000, ExpiredAt: time.Date(2021, 6, 14, 0, 0, 0, 0, time.Local)},
+			arg2: time.Date(2021, 6, 10, 10, 0, 0, 0, time.Local),
+			want: &futureOrder{Code: "for-01", OrderStatus: OrderStatusInOrder, TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionLO, SymbolCode: "167060019", OrderQuantity: 1, LimitPrice: 29000, ExpiredAt: time.Date(2021, 6, 10, 0, 0, 0, 0, time.Local), OrderedAt: time.Date(2021, 6, 10, 10, 0, 0, 0, time.Local), Contracts: []*Contract{}}},
+		{name: "有効期限が取引最終日までならそのまま",
+			service: &futureService{
+				uuidGenerator:     &testUUIDGenerator{generator1: []string{"01"}},
+				futureSymbolStore: &testFutureSymbolStore{getByCode1: &futureSymbol{SymbolCode: "167060019", LastTradingDay: time.Date(2021, 6, 10, 0, 0, 0, 0, time.Local)}}},
+			arg1: &FutureOrderRequest{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionLO, SymbolCode: "167060019", Quantity: 1, LimitPrice: 29000, ExpiredAt: time.Date(2021, 6, 9, 0, 0, 0, 0, time.Local)},
+			arg2: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local),
+			want: &futureOrder{Code: "for-01", OrderStatus: OrderStatusInOrder, TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionLO, SymbolCode: "167060019", OrderQuantity: 1, LimitPrice: 29000, ExpiredAt: time.Date(2021, 6, 9, 0, 0, 0, 0, time.Local), OrderedAt: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local), Contracts: []*Contract{}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.service.toFutureOrder(test.arg1, test.arg2)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futureService_validation(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		symbolStore *testFutureSymbolStore
+		validator   *testValidatorComponent
+		arg         *futureOrder
+		want        error
+	}{
+		{name: "引数がnilならエラー",
+			symbolStore: &testFutureSymbolStore{},
+			validator:   &testValidatorComponent{},
+			arg:         nil,
+			want:        NilArgumentError},
+		{name: "銘柄の取得でNoData以外のエラーがあればエラー",
+			symbolStore: &testFutureSymbolStore{getByCode2: InvalidSymbolCodeError},
+			validator:   &testValidatorComponent{},
+			arg:         &futureOrder{SymbolCode: "167060019"},
+			want:        InvalidSymbolCodeError},
+		{name: "銘柄が未登録ならvalidatorの結果を返す",
+			symbolStore: &testFutureSymbolStore{getByCode2: NoDataError},
+			validator:   &testValidatorComponent{isValidFutureOrder1: UnregisteredSymbolError},
+			arg:         &futureOrder{SymbolCode: "167060019"},
+			want:        UnregisteredSymbolError},
+		{name: "validatorがエラーを返さなければnil",
+			symbolStore: &testFutureSymbolStore{getByCode1: &futureSymbol{SymbolCode: "167060019", Multiplier: 100}},
+			validator:   &testValidatorComponent{isValidFutureOrder1: nil},
+			arg:         &futureOrder{SymbolCode: "167060019"},
+			want:        nil},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := &futureService{
+				clock:               &testClock{},
+				futureSymbolStore:   test.symbolStore,
+				futurePositionStore: &testFuturePositionStore{},
+				validatorComponent:  test.validator,
+			}
+			got := service.validation(test.arg, time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local))
+			if !errors.Is(got, test.want) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futureService_entry(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name                  string
+		service               *futureService
+		arg1                  *futureOrder
+		arg2                  *symbolPrice
+		want                  error
+		wantPositionStoreSave []*futurePosition
+		wantArg1              *futureOrder
+	}{
+		{name: "注文がnilならエラー",
+			service:  &futureService{},
+			arg1:     nil,
+			arg2:     &symbolPrice{},
+			want:     NilArgumentError,
+			wantArg1: nil},
+		{name: "銘柄が登録されていなければエラー",
+			service: &futureService{
+				futureSymbolStore: &testFutureSymbolStore{getByCode2: NoDataError}},
+			arg1:     &futureOrder{Code: "for-01", SymbolCode: "167060019"},
+			arg2:     &symbolPrice{},
+			want:     NoDataError,
+			wantArg1: &futureOrder{Code: "for-01", SymbolCode: "167060019"}},
+		{name: "約定しなければポジションを作らない",
+			service: &futureService{
+				futureSymbolStore:      &testFutureSymbolStore{getByCode1: &futureSymbol{SymbolCode: "167060019", Multiplier: 100}},
+				stockContractComponent: &testStockContractComponent{confirmFutureOrderContract1: &confirmContractResult{isContracted: false}}},
+			arg1:     &futureOrder{Code: "for-01", SymbolCode: "167060019"},
+			arg2:     &symbolPrice{},
+			want:     nil,
+			wantArg1: &futureOrder{Code: "for-01", SymbolCode: "167060019"}},
+		{name: "約定すれば銘柄の取引単位と約定価格を値洗いの基準にしたポジションを保存する",
+			service: &futureService{
+				uuidGenerator:          &testUUIDGenerator{generator1: []string{"01", "02"}},
+				futureSymbolStore:      &testFutureSymbolStore{getByCode1: &futureSymbol{SymbolCode: "167060019", Multiplier: 100}},
+				stockContractComponent: &testStockContractComponent{confirmFutureOrderContract1: &confirmContractResult{isContracted: true, price: 29000, quantity: 2, contractedAt: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local)}}},
+			arg1:                  &futureOrder{Code: "for-01", SymbolCode: "167060019", Side: SideBuy, OrderQuantity: 2},
+			arg2:                  &symbolPrice{},
+			want:                  nil,
+			wantPositionStoreSave: []*futurePosition{{Code: "fpo-02", OrderCode: "for-01", SymbolCode: "167060019", Side: SideBuy, ContractedQuantity: 2, OwnedQuantity: 2, Price: 29000, SettlementPrice: 29000, Multiplier: 100, ContractedAt: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local)}},
+			wantArg1:              &futureOrder{Code: "for-01", SymbolCode: "167060019", Side: SideBuy, OrderStatus: OrderStatusDone, OrderQuantity: 2, ContractedQuantity: 2, Contracts: []*Contract{{ContractCode: "fco-01", OrderCode: "for-01", PositionCode: "fpo-02", Price: 29000, Quantity: 2, ContractedAt: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local)}}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			positionStore := &testFuturePositionStore{}
+			test.service.futurePositionStore = positionStore
+			test.service.accountService = &testAccountService{}
+			got := test.service.entry(test.arg1, test.arg2, time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local))
+			if !errors.Is(got, test.want) ||
+				!reflect.DeepEqual(test.wantPositionStoreSave, positionStore.saveHistory) ||
+				!reflect.DeepEqual(test.wantArg1, test.arg1) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantPositionStoreSave, test.wantArg1,
+					got, positionStore.saveHistory, test.arg1)
+			}
+		})
+	}
+}
+
+func Test_futureService_exit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		positionStore *testFuturePositionStore
+		contract      *confirmContractResult
+		arg           *futureOrder
+		want          error
+		wantPosition  *futurePosition
+		wantProfits   []float64
+	}{
+		{name: "約定しなければ何もしない",
+			positionStore: &testFuturePositionStore{getByCode1: &futurePosition{Code: "fpo-01", Side: SideBuy, OwnedQuantity: 2, HoldQuantity: 2, SettlementPrice: 29000, Multiplier: 100}},
+			contract:      &confirmContractResult{isContracted: false},
+			arg:           &futureOrder{Code: "for-02", TradeType: TradeTypeExit, Side: SideSell, OrderQuantity: 2, ExitPositionList: []ExitPosition{{PositionCode: "fpo-01", Quantity: 2}}},
+			want:          nil,
+			wantPosition:  &futurePosition{Code: "fpo-01", Side: SideBuy, OwnedQuantity: 2, HoldQuantity: 2, SettlementPrice: 29000, Multiplier: 100}},
+		{name: "ポジションがなければエラー",
+			positionStore: &testFuturePositionStore{getByCode2: NoDataError},
+			contract:      &confirmContractResult{isContracted: true, price: 29100, quantity: 2},
+			arg:           &futureOrder{Code: "for-02", TradeType: TradeTypeExit, Side: SideSell, OrderQuantity: 2, ExitPositionList: []ExitPosition{{PositionCode: "fpo-01", Quantity: 2}}},
+			want:          NoDataError},
+		{name: "買いポジションを返済したら値洗いの基準からの損益に取引単位を掛けて口座に反映する",
+			positionStore: &testFuturePositionStore{getByCode1: &futurePosition{Code: "fpo-01", Side: SideBuy, OwnedQuantity: 2, HoldQuantity: 2, Price: 28900, SettlementPrice: 29000, Multiplier: 100}},
+			contract:      &confirmContractResult{isContracted: true, price: 29100, quantity: 2},
+			arg:           &futureOrder{Code: "for-02", TradeType: TradeTypeExit, Side: SideSell, OrderQuantity: 2, ExitPositionList: []ExitPosition{{PositionCode: "fpo-01", Quantity: 2}}},
+			want:          nil,
+			wantPosition:  &futurePosition{Code: "fpo-01", Side: SideBuy, OwnedQuantity: 0, HoldQuantity: 0, Price: 28900, SettlementPrice: 29000, Multiplier: 100},
+			wantProfits:   []float64{20000}},
+		{name: "売りポジションを返済したら損益の符号が逆になる",
+			positionStore: &testFuturePositionStore{getByCode1: &futurePosition{Code: "fpo-01", Side: SideSell, OwnedQuantity: 2, HoldQuantity: 2, Price: 29000, SettlementPrice: 29000, Multiplier: 1000}},
+			contract:      &confirmContractResult{isContracted: true, price: 29100, quantity: 1},
+			arg:           &futureOrder{Code: "for-02", TradeType: TradeTypeExit, Side: SideBuy, OrderQuantity: 1, ExitPositionList: []ExitPosition{{PositionCode: "fpo-01", Quantity: 1}}},
+			want:          nil,
+			wantPosition:  &futurePosition{Code: "fpo-01", Side: SideSell, OwnedQuantity: 1, HoldQuantity: 1, Price: 29000, SettlementPrice: 29000, Multiplier: 1000},
+			wantProfits:   []float64{-100000}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			accountService := &testAccountService{}
+			service := &futureService{
+				uuidGenerator:          &testUUIDGenerator{generator1: []string{"01"}},
+				futurePositionStore:    test.positionStore,
+				stockContractComponent: &testStockContractComponent{confirmFutureOrderContract1: test.contract},
+				accountService:         accountService,
+			}
+			got := service.exit(test.arg, &symbolPrice{}, time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local))
+			if !errors.Is(got, test.want) ||
+				!reflect.DeepEqual(test.wantPosition, test.positionStore.getByCode1) ||
+				!reflect.DeepEqual(test.wantProfits, accountService.settleFutureProfitHistory) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantPosition, test.wantProfits,
+					got, test.positionStore.getByCode1, accountService.settleFutureProfitHistory)
+			}
+		})
+	}
+}
+
+func Test_futureService_cancelAndRelease(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		positionStore *testFuturePositionStore
+		arg           *futureOrder
+		want          error
+		wantArg       *futureOrder
+		wantPosition  *futurePosition
+	}{
+		{name: "引数がnilならエラー",
+			positionStore: &testFuturePositionStore{},
+			arg:           nil,
+			want:          NilArgumentError},
+		{name: "キャンセル可能な状態じゃなければエラー",
+			positionStore: &testFuturePositionStore{},
+			arg:           &futureOrder{OrderStatus: OrderStatusDone},
+			want:          UncancellableOrderError,
+			wantArg:       &futureOrder{OrderStatus: OrderStatusDone}},
+		{name: "注文がExitで拘束中のポジションがあれば解放する",
+			positionStore: &testFuturePositionStore{getByCode1: &futurePosition{OwnedQuantity: 2, HoldQuantity: 2}},
+			arg:           &futureOrder{TradeType: TradeTypeExit, OrderStatus: OrderStatusInOrder, HoldPositions: []*HoldPosition{{PositionCode: "fpo-01", HoldQuantity: 2, ExitQuantity: 1}}},
+			want:          nil,
+			wantArg:       &futureOrder{TradeType: TradeTypeExit, OrderStatus: OrderStatusCanceled, CanceledAt: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local), HoldPositions: []*HoldPosition{{PositionCode: "fpo-01", HoldQuantity: 1, ExitQuantity: 1}}},
+			wantPosition:  &futurePosition{OwnedQuantity: 2, HoldQuantity: 1}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := &futureService{futurePositionStore: test.positionStore}
+			got := service.cancelAndRelease(test.arg, time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local))
+			if !errors.Is(got, test.want) ||
+				!reflect.DeepEqual(test.wantArg, test.arg) ||
+				!reflect.DeepEqual(test.wantPosition, test.positionStore.getByCode1) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantArg, test.wantPosition,
+					got, test.arg, test.positionStore.getByCode1)
+			}
+		})
+	}
+}
+
+func Test_futureService_isExpired(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		clock *testClock
+		arg   *futureOrder
+		want  bool
+	}{
+		{name: "nilなら期限切れにしない", clock: &testClock{}, arg: nil, want: false},
+		{name: "先物の営業日が有効期限を過ぎていたら期限切れ",
+			clock: &testClock{getBusinessDay1: time.Date(2021, 6, 7, 0, 0, 0, 0, time.Local)},
+			arg:   &futureOrder{ExpiredAt: time.Date(2021, 6, 4, 0, 0, 0, 0, time.Local)},
+			want:  true},
+		{name: "先物の営業日が有効期限と同じなら期限切れにしない",
+			clock: &testClock{getBusinessDay1: time.Date(2021, 6, 4, 0, 0, 0, 0, time.Local)},
+			arg:   &futureOrder{ExpiredAt: time.Date(2021, 6, 4, 0, 0, 0, 0, time.Local)},
+			want:  false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := &futureService{clock: test.clock}
+			got := service.isExpired(test.arg, time.Date(2021, 6, 4, 20, 0, 0, 0, time.Local))
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futureService_settle(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		symbolStore   *testFutureSymbolStore
+		positionStore *testFuturePositionStore
+		arg           *symbolPrice
+		want          error
+		wantSymbol    *futureSymbol
+		wantProfits   []float64
+	}{
+		{name: "引数がnilならエラー",
+			symbolStore:   &testFutureSymbolStore{},
+			positionStore: &testFuturePositionStore{},
+			arg:           nil,
+			want:          NilArgumentError},
+		{name: "先物の価格でなければ何もしない",
+			symbolStore:   &testFutureSymbolStore{},
+			positionStore: &testFuturePositionStore{},
+			arg:           &symbolPrice{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, session: SessionDay, kind: PriceKindClosing},
+			want:          nil},
+		{name: "夜間の引けの価格なら何もしない",
+			symbolStore:   &testFutureSymbolStore{getByCode1: &futureSymbol{SymbolCode: "167060019", Multiplier: 100}},
+			positionStore: &testFuturePositionStore{},
+			arg:           &symbolPrice{ExchangeType: ExchangeTypeFuture, SymbolCode: "167060019", Price: 29000, session: SessionNight, kind: PriceKindClosing},
+			want:          nil,
+			wantSymbol:    &futureSymbol{SymbolCode: "167060019", Multiplier: 100}},
+		{name: "日中のザラバの価格なら何もしない",
+			symbolStore:   &testFutureSymbolStore{getByCode1: &futureSymbol{SymbolCode: "167060019", Multiplier: 100}},
+			positionStore: &testFuturePositionStore{},
+			arg:           &symbolPrice{ExchangeType: ExchangeTypeFuture, SymbolCode: "167060019", Price: 29000, session: SessionDay, kind: PriceKindRegular},
+			want:          nil,
+			wantSymbol:    &futureSymbol{SymbolCode: "167060019", Multiplier: 100}},
+		{name: "銘柄が登録されていなければエラー",
+			symbolStore:   &testFutureSymbolStore{getByCode2: NoDataError},
+			positionStore: &testFuturePositionStore{},
+			arg:           &symbolPrice{ExchangeType: ExchangeTypeFuture, SymbolCode: "167060019", Price: 29000, session: SessionDay, kind: PriceKindClosing},
+			want:          NoDataError},
+		{name: "同じ営業日に値洗い済みなら何もしない",
+			symbolStore:   &testFutureSymbolStore{getByCode1: &futureSymbol{SymbolCode: "167060019", Multiplier: 100, SettlementPrice: 28900, SettledDay: time.Date(2021, 6, 4, 0, 0, 0, 0, time.Local)}},
+			positionStore: &testFuturePositionStore{getBySymbolCode1: []*futurePosition{{Side: SideBuy, OwnedQuantity: 1, SettlementPrice: 28900, Multiplier: 100}}},
+			arg:           &symbolPrice{ExchangeType: ExchangeTypeFuture, SymbolCode: "167060019", Price: 29000, session: SessionDay, kind: PriceKindClosing, priceBusinessDay: time.Date(2021, 6, 4, 0, 0, 0, 0, time.Local)},
+			want:          nil,
+			wantSymbol:    &futureSymbol{SymbolCode: "167060019", Multiplier: 100, SettlementPrice: 28900, SettledDay: time.Date(2021, 6, 4, 0, 0, 0, 0, time.Local)}},
+		{name: "日中の引けの価格で銘柄の清算値を更新し、ポジションごとの値洗いの損益を口座に反映する",
+			symbolStore: &testFutureSymbolStore{getByCode1: &futureSymbol{SymbolCode: "167060019", Multiplier: 100}},
+			positionStore: &testFuturePositionStore{getBySymbolCode1: []*futurePosition{
+				{Side: SideBuy, OwnedQuantity: 2, SettlementPrice: 28900, Multiplier: 100},
+				{Side: SideSell, OwnedQuantity: 1, SettlementPrice: 29100, Multiplier: 100}}},
+			arg:         &symbolPrice{ExchangeType: ExchangeTypeFuture, SymbolCode: "167060019", Price: 29000, session: SessionDay, kind: PriceKindClosing, priceBusinessDay: time.Date(2021, 6, 4, 0, 0, 0, 0, time.Local)},
+			want:        nil,
+			wantSymbol:  &futureSymbol{SymbolCode: "167060019", Multiplier: 100, SettlementPrice: 29000, SettledDay: time.Date(2021, 6, 4, 0, 0, 0, 0, time.Local)},
+			wantProfits: []float64{20000, 10000}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			accountService := &testAccountService{}
+			service := &futureService{
+				futureSymbolStore:   test.symbolStore,
+				futurePositionStore: test.positionStore,
+				accountService:      accountService,
+			}
+			got := service.settle(test.arg)
+			if !errors.Is(got, test.want) ||
+				!reflect.DeepEqual(test.wantSymbol, test.symbolStore.getByCode1) ||
+				!reflect.DeepEqual(test.wantProfits, accountService.settleFutureProfitHistory) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantSymbol, test.wantProfits,
+					got, test.symbolStore.getByCode1, accountService.settleFutureProfitHistory)
+			}
+		})
+	}
+}
+
+func Test_futureService_settleSQ(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2021, 6, 11, 9, 0, 0, 0, time.Local)
+
+	t.Run("SQ値が0以下ならエラー", func(t *testing.T) {
+		t.Parallel()
+		service := &futureService{}
+		got := service.settleSQ("167060019", 0, now)
+		if !errors.Is(got, InvalidPriceError) {
+			t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), InvalidPriceError, got)
+		}
+	})
+
+	t.Run("SQ済みの銘柄ならエラー", func(t *testing.T) {
+		t.Parallel()
+		service := &futureService{futureSymbolStore: &testFutureSymbolStore{getByCode1: &futureSymbol{SymbolCode: "167060019", IsSQSettled: true}}}
+		got := service.settleSQ("167060019", 29000, now)
+		if !errors.Is(got, ExpiredSymbolError) {
+			t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), ExpiredSymbolError, got)
+		}
+	})
+
+	t.Run("銘柄の注文を取り消し、ポジションをSQ値で決済する", func(t *testing.T) {
+		t.Parallel()
+		symbolStore := newFutureSymbolStore()
+		symbolStore.save(&futureSymbol{SymbolCode: "167060019", Multiplier: 100})
+		positionStore := newFuturePositionStore()
+		positionStore.save(&futurePosition{Code: "fpo-01", SymbolCode: "167060019", Side: SideBuy, ContractedQuantity: 2, OwnedQuantity: 2, HoldQuantity: 1, SettlementPrice: 28900, Multiplier: 100})
+		positionStore.save(&futurePosition{Code: "fpo-02", SymbolCode: "167060018", Side: SideBuy, ContractedQuantity: 1, OwnedQuantity: 1, SettlementPrice: 28900, Multiplier: 1000})
+		orderStore := newFutureOrderStore()
+		orderStore.save(&futureOrder{Code: "for-01", SymbolCode: "167060019", TradeType: TradeTypeExit, OrderStatus: OrderStatusInOrder, OrderQuantity: 1,
+			ExitPositionList: []ExitPosition{{PositionCode: "fpo-01", Quantity: 1}}, HoldPositions: []*HoldPosition{{PositionCode: "fpo-01", HoldQuantity: 1}}})
+		accountService := &testAccountService{}
+		service := &futureService{
+			uuidGenerator:       &testUUIDGenerator{generator1: []string{"10", "11"}},
+			futureOrderStore:    orderStore,
+			futurePositionStore: positionStore,
+			futureSymbolStore:   symbolStore,
+			accountService:      accountService,
+		}
+
+		got := service.settleSQ("167060019", 29000, now)
+		if got != nil {
+			t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), nil, got)
+		}
+
+		canceled, _ := orderStore.getByCode("for-01")
+		if canceled.OrderStatus != OrderStatusCanceled {
+			t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), OrderStatusCanceled, canceled.OrderStatus)
+		}
+		sqOrder, err := orderStore.getByCode("for-10")
+		if err != nil || sqOrder.OrderStatus != OrderStatusDone || sqOrder.Side != SideSell || sqOrder.ContractedQuantity != 2 || sqOrder.Message != "SQによる決済" {
+			t.Errorf("%s error\nwant: done sell order of 2 settled by SQ\ngot: %+v, %+v\n", t.Name(), sqOrder, err)
+		}
+		position, _ := positionStore.getByCode("fpo-01")
+		other, _ := positionStore.getByCode("fpo-02")
+		if position.OwnedQuantity != 0 || other.OwnedQuantity != 1 {
+			t.Errorf("%s error\nwant: 0, 1\ngot: %+v, %+v\n", t.Name(), position.OwnedQuantity, other.OwnedQuantity)
+		}
+		if want := []float64{20000}; !reflect.DeepEqual(want, accountService.settleFutureProfitHistory) {
+			t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, accountService.settleFutureProfitHistory)
+		}
+		symbol, _ := symbolStore.getByCode("167060019")
+		if !symbol.IsSQSettled {
+			t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), true, symbol.IsSQSettled)
+		}
+	})
+}
+
+func Test_futureService_confirmContract_expiredSymbol(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2021, 6, 14, 9, 0, 0, 0, time.Local)
+	order := &futureOrder{Code: "for-01", SymbolCode: "167060019", TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionMO, OrderStatus: OrderStatusInOrder, OrderQuantity: 1}
+	stockContractComponent := &testStockContractComponent{confirmFutureOrderContract1: &confirmContractResult{isContracted: true, price: 29000, quantity: 1, contractedAt: now}}
+	service := &futureService{
+		clock:                  &testClock{getBusinessDay1: time.Date(2021, 6, 14, 0, 0, 0, 0, time.Local)},
+		futureSymbolStore:      &testFutureSymbolStore{getByCode1: &futureSymbol{SymbolCode: "167060019", LastTradingDay: time.Date(2021, 6, 10, 0, 0, 0, 0, time.Local)}},
+		stockContractComponent: stockContractComponent,
+	}
+
+	got := service.confirmContract(order, &symbolPrice{SymbolCode: "167060019", Price: 29000}, now)
+	if got != nil || order.OrderStatus != OrderStatusCanceled || order.Message != expiredSymbolCancelMessage || len(order.Contracts) != 0 {
+		t.Errorf("%s error\nwant: canceled order without contracts\ngot: %+v, %+v\n", t.Name(), got, order)
+	}
+}
+
+func Test_futureService_expireSymbols(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2021, 6, 14, 9, 0, 0, 0, time.Local)
+	symbolStore := newFutureSymbolStore()
+	symbolStore.save(&futureSymbol{SymbolCode: "167060019", Multiplier: 100, LastTradingDay: time.Date(2021, 6, 10, 0, 0, 0, 0, time.Local)})
+	symbolStore.save(&futureSymbol{SymbolCode: "167090019", Multiplier: 100, LastTradingDay: time.Date(2021, 9, 9, 0, 0, 0, 0, time.Local)})
+	positionStore := newFuturePositionStore()
+	positionStore.save(&futurePosition{Code: "fpo-01", SymbolCode: "167060019", Side: SideBuy, ContractedQuantity: 2, OwnedQuantity: 2, HoldQuantity: 1, SettlementPrice: 28900, Multiplier: 100})
+	orderStore := newFutureOrderStore()
+	orderStore.save(&futureOrder{Code: "for-01", SymbolCode: "167060019", TradeType: TradeTypeExit, OrderStatus: OrderStatusInOrder, OrderQuantity: 1,
+		ExitPositionList: []ExitPosition{{PositionCode: "fpo-01", Quantity: 1}}, HoldPositions: []*HoldPosition{{PositionCode: "fpo-01", HoldQuantity: 1}}})
+	orderStore.save(&futureOrder{Code: "for-02", SymbolCode: "167090019", TradeType: TradeTypeEntry, OrderStatus: OrderStatusInOrder, OrderQuantity: 1})
+	accountService := &testAccountService{}
+	service := &futureService{
+		clock:               &testClock{getBusinessDay1: time.Date(2021, 6, 14, 0, 0, 0, 0, time.Local)},
+		futureOrderStore:    orderStore,
+		futurePositionStore: positionStore,
+		futureSymbolStore:   symbolStore,
+		accountService:      accountService,
+	}
+
+	got := service.expireSymbols(now)
+	if got != nil {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), nil, got)
+	}
+
+	// 取引最終日を過ぎた銘柄の注文だけ取り消し、拘束していたポジションを開放する
+	canceled, _ := orderStore.getByCode("for-01")
+	other, _ := orderStore.getByCode("for-02")
+	if canceled.OrderStatus != OrderStatusCanceled || canceled.Message != expiredSymbolCancelMessage || other.OrderStatus != OrderStatusInOrder {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), OrderStatusCanceled, OrderStatusInOrder, canceled, other)
+	}
+
+	// ポジションはSQで決済されるまで期限切れの銘柄のポジションとして残す
+	position, _ := positionStore.getByCode("fpo-01")
+	if position.OwnedQuantity != 2 || position.HoldQuantity != 0 || len(accountService.settleFutureProfitHistory) != 0 {
+		t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(), 2, 0, 0, position.OwnedQuantity, position.HoldQuantity, accountService.settleFutureProfitHistory)
+	}
+	if !service.isExpiredSymbol("167060019", now) || service.isExpiredSymbol("167090019", now) {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), true, false, service.isExpiredSymbol("167060019", now), service.isExpiredSymbol("167090019", now))
+	}
+}
+
+func Test_futureService_isExpiredSymbol(t *testing.T) {
+	t.Parallel()
+	lastTradingDay := time.Date(2021, 6, 10, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name        string
+		store       *testFutureSymbolStore
+		businessDay time.Time
+		want        bool
+	}{
+		{name: "銘柄がなければfalse", store: &testFutureSymbolStore{getByCode2: NoDataError}, businessDay: time.Date(2021, 6, 14, 0, 0, 0, 0, time.Local), want: false},
+		{name: "取引最終日までならfalse", store: &testFutureSymbolStore{getByCode1: &futureSymbol{LastTradingDay: lastTradingDay}}, businessDay: lastTradingDay, want: false},
+		{name: "取引最終日を過ぎていればtrue", store: &testFutureSymbolStore{getByCode1: &futureSymbol{LastTradingDay: lastTradingDay}}, businessDay: time.Date(2021, 6, 11, 0, 0, 0, 0, time.Local), want: true},
+		{name: "SQで決済済みならfalse", store: &testFutureSymbolStore{getByCode1: &futureSymbol{LastTradingDay: lastTradingDay, IsSQSettled: true}}, businessDay: time.Date(2021, 6, 11, 0, 0, 0, 0, time.Local), want: false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := &futureService{clock: &testClock{getBusinessDay1: test.businessDay}, futureSymbolStore: test.store}
+			got := service.isExpiredSymbol("167060019", time.Time{})
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_futureService_exitPosition(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local)
+
+	t.Run("ポジションを返済できなければ注文や口座を変更せずにエラー", func(t *testing.T) {
+		t.Parallel()
+		accountService := &testAccountService{}
+		service := &futureService{accountService: accountService}
+		order := &futureOrder{Code: "for-01", SymbolCode: "167060019", TradeType: TradeTypeExit, Side: SideSell, OrderStatus: OrderStatusInOrder, OrderQuantity: 1}
+		position := &futurePosition{Code: "fpo-01", SymbolCode: "167060019", Side: SideBuy, OwnedQuantity: 1, HoldQuantity: 0, SettlementPrice: 29000, Multiplier: 100}
+
+		got := service.exitPosition(order, &futureExit{position: position, price: 29100, quantity: 1}, now)
+		if !errors.Is(got, NotEnoughHoldQuantityError) || len(order.Contracts) != 0 || position.OwnedQuantity != 1 || len(accountService.settleFutureProfitHistory) != 0 {
+			t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v\n", t.Name(),
+				NotEnoughHoldQuantityError, 0, 1, 0, got, len(order.Contracts), position.OwnedQuantity, len(accountService.settleFutureProfitHistory))
+		}
+	})
+}
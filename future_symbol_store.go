@@ -0,0 +1,98 @@
+package virtual_security
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	futureSymbolStoreSingleton      iFutureSymbolStore
+	futureSymbolStoreSingletonMutex sync.Mutex
+)
+
+func getFutureSymbolStore() iFutureSymbolStore {
+	futureSymbolStoreSingletonMutex.Lock()
+	defer futureSymbolStoreSingletonMutex.Unlock()
+
+	if futureSymbolStoreSingleton == nil {
+		futureSymbolStoreSingleton = newFutureSymbolStore()
+	}
+	return futureSymbolStoreSingleton
+}
+
+func newFutureSymbolStore() iFutureSymbolStore {
+	return &futureSymbolStore{
+		store: map[string]*futureSymbol{},
+	}
+}
+
+// futureSymbol - 先物銘柄
+type futureSymbol struct {
+	SymbolCode      string    // 銘柄コード
+	Multiplier      float64   // 取引単位
+	LastTradingDay  time.Time // 取引最終日
+	SettlementPrice float64   // 直近の清算値
+	SettledDay      time.Time // 直近の清算値の営業日
+	IsSQSettled     bool      // SQで決済済みか
+}
+
+// isTradable - 指定した営業日に取引できる銘柄か
+func (s *futureSymbol) isTradable(businessDay time.Time) bool {
+	if s.IsSQSettled {
+		return false
+	}
+	return s.LastTradingDay.IsZero() || !businessDay.After(s.LastTradingDay)
+}
+
+// iFutureSymbolStore - 先物銘柄ストアのインターフェース
+type iFutureSymbolStore interface {
+	getAll() []*futureSymbol
+	getByCode(code string) (*futureSymbol, error)
+	save(futureSymbol *futureSymbol)
+}
+
+// futureSymbolStore - 先物銘柄のストア
+type futureSymbolStore struct {
+	store map[string]*futureSymbol
+	mtx   sync.Mutex
+}
+
+// getAll - ストアのすべての銘柄を銘柄コード順に並べて返す
+func (s *futureSymbolStore) getAll() []*futureSymbol {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	symbols := make([]*futureSymbol, 0, len(s.store))
+	for _, symbol := range s.store {
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].SymbolCode < symbols[j].SymbolCode
+	})
+	return symbols
+}
+
+// getByCode - 銘柄コードを指定してデータを取得する
+func (s *futureSymbolStore) getByCode(code string) (*futureSymbol, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if symbol, ok := s.store[code]; ok {
+		return symbol, nil
+	} else {
+		return nil, NoDataError
+	}
+}
+
+// save - 銘柄をストアに追加する
+func (s *futureSymbolStore) save(futureSymbol *futureSymbol) {
+	if futureSymbol == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.store[futureSymbol.SymbolCode] = futureSymbol
+}
@@ -0,0 +1,149 @@
+package virtual_security
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testFutureSymbolStore struct {
+	getAll1     []*futureSymbol
+	getByCode1  *futureSymbol
+	getByCode2  error
+	saveHistory []*futureSymbol
+}
+
+func (t *testFutureSymbolStore) getAll() []*futureSymbol {
+	return t.getAll1
+}
+
+func (t *testFutureSymbolStore) getByCode(string) (*futureSymbol, error) {
+	return t.getByCode1, t.getByCode2
+}
+func (t *testFutureSymbolStore) save(futureSymbol *futureSymbol) {
+	t.saveHistory = append(t.saveHistory, futureSymbol)
+}
+
+func Test_newFutureSymbolStore(t *testing.T) {
+	t.Parallel()
+	want := &futureSymbolStore{store: map[string]*futureSymbol{}}
+	got := newFutureSymbolStore()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+	if got == newFutureSymbolStore() {
+		t.Errorf("%s error\nwant: different store\ngot: same store\n", t.Name())
+	}
+}
+
+func Test_getFutureSymbolStore(t *testing.T) {
+	got := getFutureSymbolStore()
+	want := &futureSymbolStore{store: map[string]*futureSymbol{}}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_futureSymbolStore_GetByCode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		store *futureSymbolStore
+		arg   string
+		want1 *futureSymbol
+		want2 error
+	}{
+		{name: "storeに指定したコードがなければエラー",
+			store: &futureSymbolStore{store: map[string]*futureSymbol{}},
+			arg:   "167060019",
+			want1: nil,
+			want2: NoDataError},
+		{name: "storeに指定したコードがあればその銘柄を返す",
+			store: &futureSymbolStore{store: map[string]*futureSymbol{
+				"167060019": {SymbolCode: "167060019", Multiplier: 100},
+				"167060018": {SymbolCode: "167060018", Multiplier: 1000}}},
+			arg:   "167060019",
+			want1: &futureSymbol{SymbolCode: "167060019", Multiplier: 100},
+			want2: nil},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got1, got2 := test.store.getByCode(test.arg)
+			if !reflect.DeepEqual(test.want1, got1) || !errors.Is(got2, test.want2) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want1, test.want2, got1, got2)
+			}
+		})
+	}
+}
+
+func Test_futureSymbolStore_Save(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		store *futureSymbolStore
+		arg   *futureSymbol
+		want  map[string]*futureSymbol
+	}{
+		{name: "nilなら何もしない",
+			store: &futureSymbolStore{store: map[string]*futureSymbol{}},
+			arg:   nil,
+			want:  map[string]*futureSymbol{}},
+		{name: "同じコードがあれば上書きする",
+			store: &futureSymbolStore{store: map[string]*futureSymbol{"167060019": {SymbolCode: "167060019", Multiplier: 1000}}},
+			arg:   &futureSymbol{SymbolCode: "167060019", Multiplier: 100},
+			want:  map[string]*futureSymbol{"167060019": {SymbolCode: "167060019", Multiplier: 100}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			test.store.save(test.arg)
+			if !reflect.DeepEqual(test.want, test.store.store) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, test.store.store)
+			}
+		})
+	}
+}
+
+func Test_futureSymbol_isTradable(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		symbol *futureSymbol
+		arg    time.Time
+		want   bool
+	}{
+		{name: "取引最終日がなければ取引できる",
+			symbol: &futureSymbol{},
+			arg:    time.Date(2021, 9, 10, 0, 0, 0, 0, time.Local),
+			want:   true},
+		{name: "取引最終日までは取引できる",
+			symbol: &futureSymbol{LastTradingDay: time.Date(2021, 9, 9, 0, 0, 0, 0, time.Local)},
+			arg:    time.Date(2021, 9, 9, 0, 0, 0, 0, time.Local),
+			want:   true},
+		{name: "取引最終日を過ぎたら取引できない",
+			symbol: &futureSymbol{LastTradingDay: time.Date(2021, 9, 9, 0, 0, 0, 0, time.Local)},
+			arg:    time.Date(2021, 9, 10, 0, 0, 0, 0, time.Local),
+			want:   false},
+		{name: "SQで決済済みなら取引できない",
+			symbol: &futureSymbol{IsSQSettled: true},
+			arg:    time.Date(2021, 9, 9, 0, 0, 0, 0, time.Local),
+			want:   false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.symbol.isTradable(test.arg)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package virtual_security
+
+import (
+	"math"
+)
+
+// itayose - 買板と売板の気配から、板寄せの約定値段と約定数量を求める
+//   板の気配値のうち、その値段以上の買いとその値段以下の売りが最も多く約定する値段を約定値段にする
+//   約定数量が同じ値段が複数あれば、買いと売りの数量の差が小さい値段、基準の価格に近い値段、安い値段の順に選ぶ
+//   気配が交差していない、もしくは気配数量の情報がなければ板寄せできないので0を返す
+func itayose(bidBoard []BoardLevel, askBoard []BoardLevel, basePrice float64) (float64, float64) {
+	if len(bidBoard) == 0 || len(askBoard) == 0 {
+		return 0, 0
+	}
+	levels := append(append([]BoardLevel{}, bidBoard...), askBoard...)
+	for _, l := range levels {
+		if l.Price <= 0 || l.Quantity <= 0 {
+			return 0, 0
+		}
+	}
+
+	var price, quantity, imbalance float64
+	for _, candidate := range levels {
+		var demand, supply float64
+		for _, l := range bidBoard {
+			if l.Price >= candidate.Price {
+				demand += l.Quantity
+			}
+		}
+		for _, l := range askBoard {
+			if l.Price <= candidate.Price {
+				supply += l.Quantity
+			}
+		}
+
+		q := math.Min(demand, supply)
+		if q <= 0 {
+			continue
+		}
+		diff := math.Abs(demand - supply)
+		if price > 0 && !isBetterItayosePrice(candidate.Price, q, diff, price, quantity, imbalance, basePrice) {
+			continue
+		}
+		price, quantity, imbalance = candidate.Price, q, diff
+	}
+	return price, quantity
+}
+
+// isBetterItayosePrice - 板寄せの約定値段の候補が、今の約定値段より優先されるか
+func isBetterItayosePrice(price, quantity, imbalance, currentPrice, currentQuantity, currentImbalance, basePrice float64) bool {
+	if quantity != currentQuantity {
+		return quantity > currentQuantity
+	}
+	if imbalance != currentImbalance {
+		return imbalance < currentImbalance
+	}
+	if basePrice > 0 {
+		if d, currentD := math.Abs(price-basePrice), math.Abs(currentPrice-basePrice); d != currentD {
+			return d < currentD
+		}
+	}
+	return price < currentPrice
+}
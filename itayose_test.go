@@ -0,0 +1,71 @@
+package virtual_security
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_itayose(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		bidBoard     []BoardLevel
+		askBoard     []BoardLevel
+		basePrice    float64
+		wantPrice    float64
+		wantQuantity float64
+	}{
+		{name: "買板がなければ板寄せできない",
+			askBoard:     []BoardLevel{{Price: 1000, Quantity: 100}},
+			wantPrice:    0,
+			wantQuantity: 0},
+		{name: "売板がなければ板寄せできない",
+			bidBoard:     []BoardLevel{{Price: 1000, Quantity: 100}},
+			wantPrice:    0,
+			wantQuantity: 0},
+		{name: "気配数量の情報がなければ板寄せできない",
+			bidBoard:     []BoardLevel{{Price: 1010, Quantity: 100}, {Price: 1005}},
+			askBoard:     []BoardLevel{{Price: 1000, Quantity: 100}},
+			wantPrice:    0,
+			wantQuantity: 0},
+		{name: "気配が交差していなければ板寄せできない",
+			bidBoard:     []BoardLevel{{Price: 999, Quantity: 100}, {Price: 998, Quantity: 100}},
+			askBoard:     []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 1001, Quantity: 100}},
+			wantPrice:    0,
+			wantQuantity: 0},
+		{name: "最も多く約定する値段が約定値段になる",
+			bidBoard:     []BoardLevel{{Price: 1010, Quantity: 300}, {Price: 1005, Quantity: 200}, {Price: 1000, Quantity: 100}},
+			askBoard:     []BoardLevel{{Price: 995, Quantity: 100}, {Price: 1000, Quantity: 200}, {Price: 1005, Quantity: 300}},
+			basePrice:    1000,
+			wantPrice:    1005,
+			wantQuantity: 500},
+		{name: "約定数量が同じなら、買いと売りの数量の差が小さい値段が約定値段になる",
+			bidBoard:     []BoardLevel{{Price: 1010, Quantity: 100}, {Price: 1005, Quantity: 150}},
+			askBoard:     []BoardLevel{{Price: 1005, Quantity: 100}, {Price: 1010, Quantity: 20}},
+			basePrice:    1005,
+			wantPrice:    1010,
+			wantQuantity: 100},
+		{name: "約定数量も数量の差も同じなら、基準の価格に近い値段が約定値段になる",
+			bidBoard:     []BoardLevel{{Price: 1010, Quantity: 200}},
+			askBoard:     []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 1005, Quantity: 100}},
+			basePrice:    1009,
+			wantPrice:    1010,
+			wantQuantity: 200},
+		{name: "基準の価格がなければ、安い値段が約定値段になる",
+			bidBoard:     []BoardLevel{{Price: 1010, Quantity: 200}},
+			askBoard:     []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 1005, Quantity: 100}},
+			wantPrice:    1005,
+			wantQuantity: 200},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			gotPrice, gotQuantity := itayose(test.bidBoard, test.askBoard, test.basePrice)
+			if !reflect.DeepEqual(test.wantPrice, gotPrice) || !reflect.DeepEqual(test.wantQuantity, gotQuantity) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.wantPrice, test.wantQuantity, gotPrice, gotQuantity)
+			}
+		})
+	}
+}
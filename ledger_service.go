@@ -0,0 +1,198 @@
+package virtual_security
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+func newLedgerService(taxRate float64) iLedgerService {
+	return &ledgerService{
+		taxRate: taxRate,
+		trades:  []*Trade{},
+		codes:   map[string]struct{}{},
+		entries: map[string]*Trade{},
+		gains:   map[taxCategory]float64{},
+	}
+}
+
+type iLedgerService interface {
+	record(trade *Trade)
+	getTrades() []*Trade
+	dailyProfits() []*DailyProfit
+	totalProfit() float64
+	totalTax() float64
+	restore(trades []*Trade)
+}
+
+// ledgerService - 約定を記録し続ける台帳
+type ledgerService struct {
+	taxRate float64 // 譲渡益にかかる税率 (0なら税金を計算しない)
+	trades  []*Trade
+	codes   map[string]struct{}     // 記録済みの約定コード
+	entries map[string]*Trade       // ポジションコードごとの新規の約定
+	gains   map[taxCategory]float64 // 年と所得の区分ごとの確定損益の累計
+	mtx     sync.Mutex
+}
+
+// taxCategory - 損益を通算できる範囲
+//   上場株式等(現物と信用)と先物は損益を通算できず、年をまたいだ損益も通算しない
+type taxCategory struct {
+	year     int
+	isFuture bool
+}
+
+// record - 約定を記録する
+//   記録済みの約定は無視し、返済ならポジションを作った新規の約定から損益を計算する
+//   損益からは約定の手数料と信用建玉の保有にかかった費用を引き、新規の約定は手数料の分だけ損益をマイナスにする
+func (s *ledgerService) record(trade *Trade) {
+	if trade == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, ok := s.codes[trade.ContractCode]; ok {
+		return
+	}
+	s.codes[trade.ContractCode] = struct{}{}
+
+	t := *trade
+	if t.Multiplier <= 0 {
+		t.Multiplier = 1
+	}
+	switch t.TradeType {
+	case TradeTypeEntry:
+		s.entries[t.PositionCode] = &t
+	case TradeTypeExit:
+		// 台帳を作る前からあったポジションなら、新規の約定がわからないので手数料を除く損益は0にする
+		if entry, ok := s.entries[t.PositionCode]; ok {
+			t.EntryContractCode = entry.ContractCode
+			t.EntryPrice = entry.Price
+			t.Profit = (t.Price - entry.Price) * t.Quantity * t.Multiplier
+			if entry.Side == SideSell {
+				t.Profit = -t.Profit
+			}
+		}
+	}
+	t.Profit -= t.Fee + t.HoldingCost
+	t.Tax = s.tax(&t)
+	s.trades = append(s.trades, &t)
+}
+
+// tax - 約定で増減する税金
+//   年と所得の区分ごとに損益を累計し、累計の利益にかかる税金の増減を約定の税金にする
+//   損失で累計の利益が減れば、払いすぎた税金が戻るのでマイナスになる
+//   ロックは呼び出し元で取る
+func (s *ledgerService) tax(trade *Trade) float64 {
+	if s.taxRate <= 0 || trade.Profit == 0 {
+		return 0
+	}
+
+	category := taxCategory{year: trade.BusinessDay.Year(), isFuture: trade.ExchangeType == ExchangeTypeFuture}
+	before := math.Max(s.gains[category], 0) * s.taxRate
+	s.gains[category] += trade.Profit
+	after := math.Max(s.gains[category], 0) * s.taxRate
+	return after - before
+}
+
+// restore - 記録した約定を戻す
+//   約定の損益や税金は計算し直さずにそのまま戻し、記録済みの約定コード、新規の約定、確定損益の累計を作り直す
+func (s *ledgerService) restore(trades []*Trade) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.trades = []*Trade{}
+	s.codes = map[string]struct{}{}
+	s.entries = map[string]*Trade{}
+	s.gains = map[taxCategory]float64{}
+	for _, trade := range trades {
+		if trade == nil {
+			continue
+		}
+
+		t := *trade
+		s.trades = append(s.trades, &t)
+		s.codes[t.ContractCode] = struct{}{}
+		if t.TradeType == TradeTypeEntry {
+			s.entries[t.PositionCode] = &t
+		}
+		if s.taxRate > 0 && t.Profit != 0 {
+			s.gains[taxCategory{year: t.BusinessDay.Year(), isFuture: t.ExchangeType == ExchangeTypeFuture}] += t.Profit
+		}
+	}
+}
+
+// getTrades - 記録したすべての約定を約定日時の順に返す
+func (s *ledgerService) getTrades() []*Trade {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	trades := make([]*Trade, len(s.trades))
+	for i, t := range s.trades {
+		trade := *t
+		trades[i] = &trade
+	}
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].ContractedAt.Before(trades[j].ContractedAt)
+	})
+	return trades
+}
+
+// dailyProfits - 営業日ごとの確定損益を営業日の順に返す
+func (s *ledgerService) dailyProfits() []*DailyProfit {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	days := map[time.Time]*DailyProfit{}
+	for _, t := range s.trades {
+		d, ok := days[t.BusinessDay]
+		if !ok {
+			d = &DailyProfit{BusinessDay: t.BusinessDay}
+			days[t.BusinessDay] = d
+		}
+		d.TradeCount++
+		d.Profit += t.Profit
+		d.Fee += t.Fee
+		d.HoldingCost += t.HoldingCost
+		d.Tax += t.Tax
+		if t.TradeType == TradeTypeExit {
+			d.ExitCount++
+		}
+	}
+
+	profits := make([]*DailyProfit, 0, len(days))
+	for _, d := range days {
+		profits = append(profits, d)
+	}
+	sort.Slice(profits, func(i, j int) bool {
+		return profits[i].BusinessDay.Before(profits[j].BusinessDay)
+	})
+	return profits
+}
+
+// totalProfit - すべての確定損益の合計 (手数料を引いたもの)
+func (s *ledgerService) totalProfit() float64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var total float64
+	for _, t := range s.trades {
+		total += t.Profit
+	}
+	return total
+}
+
+// totalTax - すべての約定の税金の合計
+func (s *ledgerService) totalTax() float64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var total float64
+	for _, t := range s.trades {
+		total += t.Tax
+	}
+	return total
+}
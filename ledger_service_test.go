@@ -0,0 +1,238 @@
+package virtual_security
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testLedgerService struct {
+	iLedgerService
+	recordHistory []*Trade
+	getTrades1    []*Trade
+	dailyProfits1 []*DailyProfit
+	totalProfit1  float64
+}
+
+func (t *testLedgerService) record(trade *Trade) {
+	t.recordHistory = append(t.recordHistory, trade)
+}
+func (t *testLedgerService) getTrades() []*Trade          { return t.getTrades1 }
+func (t *testLedgerService) dailyProfits() []*DailyProfit { return t.dailyProfits1 }
+func (t *testLedgerService) totalProfit() float64         { return t.totalProfit1 }
+
+func Test_newLedgerService(t *testing.T) {
+	t.Parallel()
+	want := &ledgerService{taxRate: CapitalGainsTaxRate, trades: []*Trade{}, codes: map[string]struct{}{}, entries: map[string]*Trade{}, gains: map[taxCategory]float64{}}
+	got := newLedgerService(CapitalGainsTaxRate)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_ledgerService_record(t *testing.T) {
+	t.Parallel()
+	buyEntry := &Trade{ContractCode: "sco-1", PositionCode: "spo-1", TradeType: TradeTypeEntry, Side: SideBuy, Price: 1000, Quantity: 100, Multiplier: 1}
+	sellEntry := &Trade{ContractCode: "fco-1", PositionCode: "fpo-1", TradeType: TradeTypeEntry, Side: SideSell, Price: 28000, Quantity: 2, Multiplier: 100}
+	tests := []struct {
+		name    string
+		entries map[string]*Trade
+		arg     *Trade
+		want    []*Trade
+	}{
+		{name: "nilなら何もしない", arg: nil, want: []*Trade{}},
+		{name: "新規はそのまま記録する",
+			arg:  &Trade{ContractCode: "sco-1", PositionCode: "spo-1", TradeType: TradeTypeEntry, Side: SideBuy, Price: 1000, Quantity: 100, Multiplier: 1},
+			want: []*Trade{buyEntry}},
+		{name: "取引単位がなければ1にする",
+			arg:  &Trade{ContractCode: "sco-1", PositionCode: "spo-1", TradeType: TradeTypeEntry, Side: SideBuy, Price: 1000, Quantity: 100},
+			want: []*Trade{buyEntry}},
+		{name: "買いの新規の返済は、返済の価格から新規の価格を引いたものが損益",
+			entries: map[string]*Trade{"spo-1": buyEntry},
+			arg:     &Trade{ContractCode: "sco-2", PositionCode: "spo-1", TradeType: TradeTypeExit, Side: SideSell, Price: 1010, Quantity: 50, Multiplier: 1},
+			want:    []*Trade{{ContractCode: "sco-2", PositionCode: "spo-1", EntryContractCode: "sco-1", TradeType: TradeTypeExit, Side: SideSell, Price: 1010, Quantity: 50, Multiplier: 1, EntryPrice: 1000, Profit: 500}}},
+		{name: "売りの新規の返済は、新規の価格から返済の価格を引いたものに取引単位を掛けたものが損益",
+			entries: map[string]*Trade{"fpo-1": sellEntry},
+			arg:     &Trade{ContractCode: "fco-2", PositionCode: "fpo-1", TradeType: TradeTypeExit, Side: SideBuy, Price: 28050, Quantity: 2, Multiplier: 100},
+			want:    []*Trade{{ContractCode: "fco-2", PositionCode: "fpo-1", EntryContractCode: "fco-1", TradeType: TradeTypeExit, Side: SideBuy, Price: 28050, Quantity: 2, Multiplier: 100, EntryPrice: 28000, Profit: -10000}}},
+		{name: "新規の手数料は損益のマイナスにする",
+			arg:  &Trade{ContractCode: "sco-1", PositionCode: "spo-1", TradeType: TradeTypeEntry, Side: SideBuy, Price: 1000, Quantity: 100, Multiplier: 1, Fee: 55},
+			want: []*Trade{{ContractCode: "sco-1", PositionCode: "spo-1", TradeType: TradeTypeEntry, Side: SideBuy, Price: 1000, Quantity: 100, Multiplier: 1, Fee: 55, Profit: -55}}},
+		{name: "返済の損益から返済の手数料を引く",
+			entries: map[string]*Trade{"spo-1": buyEntry},
+			arg:     &Trade{ContractCode: "sco-2", PositionCode: "spo-1", TradeType: TradeTypeExit, Side: SideSell, Price: 1010, Quantity: 50, Multiplier: 1, Fee: 55},
+			want:    []*Trade{{ContractCode: "sco-2", PositionCode: "spo-1", EntryContractCode: "sco-1", TradeType: TradeTypeExit, Side: SideSell, Price: 1010, Quantity: 50, Multiplier: 1, Fee: 55, EntryPrice: 1000, Profit: 445}}},
+		{name: "返済の損益から信用建玉の保有にかかった費用を引く",
+			entries: map[string]*Trade{"spo-1": buyEntry},
+			arg:     &Trade{ContractCode: "mco-2", PositionCode: "spo-1", TradeType: TradeTypeExit, Side: SideSell, Price: 1010, Quantity: 50, Multiplier: 1, HoldingCost: 30},
+			want:    []*Trade{{ContractCode: "mco-2", PositionCode: "spo-1", EntryContractCode: "sco-1", TradeType: TradeTypeExit, Side: SideSell, Price: 1010, Quantity: 50, Multiplier: 1, HoldingCost: 30, EntryPrice: 1000, Profit: 470}}},
+		{name: "新規の約定がわからない返済は損益を0にする",
+			arg:  &Trade{ContractCode: "sco-2", PositionCode: "spo-9", TradeType: TradeTypeExit, Side: SideSell, Price: 1010, Quantity: 50, Multiplier: 1},
+			want: []*Trade{{ContractCode: "sco-2", PositionCode: "spo-9", TradeType: TradeTypeExit, Side: SideSell, Price: 1010, Quantity: 50, Multiplier: 1}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := newLedgerService(0).(*ledgerService)
+			for code, entry := range test.entries {
+				service.entries[code] = entry
+			}
+			service.record(test.arg)
+			if !reflect.DeepEqual(test.want, service.trades) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, service.trades)
+			}
+		})
+	}
+}
+
+func Test_ledgerService_record_duplicate(t *testing.T) {
+	t.Parallel()
+	service := newLedgerService(0)
+	trade := &Trade{ContractCode: "sco-1", PositionCode: "spo-1", TradeType: TradeTypeEntry, Side: SideBuy, Price: 1000, Quantity: 100}
+	service.record(trade)
+	service.record(trade)
+
+	if got := service.getTrades(); len(got) != 1 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 1, len(got))
+	}
+}
+
+func Test_ledgerService_getTrades(t *testing.T) {
+	t.Parallel()
+	service := &ledgerService{trades: []*Trade{
+		{ContractCode: "sco-2", ContractedAt: time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local)},
+		{ContractCode: "sco-1", ContractedAt: time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local)},
+		{ContractCode: "sco-3", ContractedAt: time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local)},
+	}}
+	want := []*Trade{
+		{ContractCode: "sco-1", ContractedAt: time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local)},
+		{ContractCode: "sco-2", ContractedAt: time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local)},
+		{ContractCode: "sco-3", ContractedAt: time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local)},
+	}
+	got := service.getTrades()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+
+	// 返したものを変更しても台帳は変わらない
+	got[0].Price = 9999
+	if service.trades[1].Price != 0 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 0, service.trades[1].Price)
+	}
+}
+
+func Test_ledgerService_profits(t *testing.T) {
+	t.Parallel()
+	day1 := time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)
+	day2 := time.Date(2021, 9, 2, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name  string
+		arg   []*Trade
+		want1 []*DailyProfit
+		want2 float64
+	}{
+		{name: "約定がなければ空", arg: []*Trade{}, want1: []*DailyProfit{}, want2: 0},
+		{name: "営業日ごとに返済の損益を集計する",
+			arg: []*Trade{
+				{BusinessDay: day2, TradeType: TradeTypeExit, Profit: -300},
+				{BusinessDay: day1, TradeType: TradeTypeEntry, Fee: 100, Profit: -100},
+				{BusinessDay: day1, TradeType: TradeTypeExit, Fee: 100, Profit: 500, Tax: 80},
+				{BusinessDay: day1, TradeType: TradeTypeExit, Profit: 200, Tax: 40},
+			},
+			want1: []*DailyProfit{
+				{BusinessDay: day1, Profit: 600, Fee: 200, Tax: 120, TradeCount: 3, ExitCount: 2},
+				{BusinessDay: day2, Profit: -300, TradeCount: 1, ExitCount: 1},
+			},
+			want2: 300},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := &ledgerService{trades: test.arg}
+			got1 := service.dailyProfits()
+			got2 := service.totalProfit()
+			if !reflect.DeepEqual(test.want1, got1) || test.want2 != got2 {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want1, test.want2, got1, got2)
+			}
+		})
+	}
+}
+
+func Test_ledgerService_tax(t *testing.T) {
+	t.Parallel()
+	day2021 := time.Date(2021, 12, 30, 0, 0, 0, 0, time.Local)
+	day2022 := time.Date(2022, 1, 4, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name    string
+		taxRate float64
+		arg     []*Trade
+		want    []float64
+	}{
+		{name: "税率がなければ税金は0",
+			taxRate: 0,
+			arg:     []*Trade{{ExchangeType: ExchangeTypeStock, Profit: 10_000, BusinessDay: day2021}},
+			want:    []float64{0}},
+		{name: "利益に税率を掛け、損失で累計の利益が減れば税金が戻る",
+			taxRate: 0.2,
+			arg: []*Trade{
+				{ExchangeType: ExchangeTypeStock, Profit: 10_000, BusinessDay: day2021},
+				{ExchangeType: ExchangeTypeMargin, Profit: -15_000, BusinessDay: day2021},
+				{ExchangeType: ExchangeTypeStock, Profit: 10_000, BusinessDay: day2021},
+			},
+			want: []float64{2_000, -2_000, 1_000}},
+		{name: "先物と株式、年の違う損益は通算しない",
+			taxRate: 0.2,
+			arg: []*Trade{
+				{ExchangeType: ExchangeTypeStock, Profit: -10_000, BusinessDay: day2021},
+				{ExchangeType: ExchangeTypeFuture, Profit: 10_000, BusinessDay: day2021},
+				{ExchangeType: ExchangeTypeStock, Profit: 10_000, BusinessDay: day2022},
+			},
+			want: []float64{0, 2_000, 2_000}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := &ledgerService{taxRate: test.taxRate, gains: map[taxCategory]float64{}}
+			got := make([]float64, len(test.arg))
+			for i, trade := range test.arg {
+				got[i] = service.tax(trade)
+			}
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_ledgerService_restore(t *testing.T) {
+	t.Parallel()
+	service := newLedgerService(0.2).(*ledgerService)
+	service.record(&Trade{ContractCode: "old", TradeType: TradeTypeEntry})
+
+	entry := &Trade{ContractCode: "con-1", PositionCode: "pos-1", TradeType: TradeTypeEntry, Profit: -100, BusinessDay: time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)}
+	exit := &Trade{ContractCode: "con-2", PositionCode: "pos-1", TradeType: TradeTypeExit, Profit: 1000, Tax: 180, BusinessDay: time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)}
+	service.restore([]*Trade{entry, nil, exit})
+
+	// 戻した約定の損益や税金は計算し直さず、今ある約定は捨てる
+	want := &ledgerService{
+		taxRate: 0.2,
+		trades:  []*Trade{entry, exit},
+		codes:   map[string]struct{}{"con-1": {}, "con-2": {}},
+		entries: map[string]*Trade{"pos-1": entry},
+		gains:   map[taxCategory]float64{{year: 2021}: 900},
+	}
+	if !reflect.DeepEqual(want, service) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, service)
+	}
+
+	// 戻した約定は引数と別のものになる
+	if service.trades[0] == entry {
+		t.Errorf("%s error\nwant: %p\ngot: %p\n", t.Name(), entry, service.trades[0])
+	}
+}
@@ -0,0 +1,93 @@
+package virtual_security
+
+import (
+	"math"
+	"time"
+)
+
+// settlementDays - 約定日から受渡日までの営業日数
+const settlementDays = 2
+
+// MarginCostRates - 信用建玉を保有している間にかかる費用の料率
+//   指定しなかった費用は0になる
+type MarginCostRates struct {
+	BuyInterestRate        float64            // 買方金利 (年率、買建玉の約定代金にかかる)
+	LendingFeeRate         float64            // 貸株料 (年率、売建玉の約定代金にかかる)
+	ShortPremiums          map[string]float64 // 逆日歩 (銘柄コードごとの1株1日あたりの金額、売建玉にかかる)
+	AdministrationFee      float64            // 管理費 (1株1か月あたりの金額)
+	AdministrationFeeLimit float64            // 管理費の1建玉1か月あたりの上限 (0なら上限なし)
+}
+
+// MarginCost - 信用建玉を保有している間にかかった費用
+type MarginCost struct {
+	Interest          float64 // 買方金利
+	LendingFee        float64 // 貸株料
+	Premium           float64 // 逆日歩
+	AdministrationFee float64 // 管理費
+}
+
+// Total - 費用の合計
+func (c MarginCost) Total() float64 {
+	return c.Interest + c.LendingFee + c.Premium + c.AdministrationFee
+}
+
+// cost - 建玉を新規の約定日時からnowまで保有したときにかかる費用
+//   金利、貸株料、逆日歩は、新規と返済の受渡日の両端を含めた日数分、日割りで計算する
+//   管理費は、新規の約定日から1か月経過するごとにかかる
+func (r MarginCostRates) cost(clock iClock, side Side, symbolCode string, price float64, quantity float64, contractedAt time.Time, now time.Time) MarginCost {
+	var cost MarginCost
+	if contractedAt.IsZero() || now.IsZero() || quantity <= 0 {
+		return cost
+	}
+
+	days := holdingDays(clock, contractedAt, now)
+	amount := price * quantity
+	switch side {
+	case SideBuy:
+		cost.Interest = floorYen(amount * r.BuyInterestRate * days / 365)
+	case SideSell:
+		cost.LendingFee = floorYen(amount * r.LendingFeeRate * days / 365)
+		cost.Premium = floorYen(r.ShortPremiums[symbolCode] * quantity * days)
+	}
+
+	if months := elapsedMonths(contractedAt, now); months > 0 {
+		fee := r.AdministrationFee * quantity
+		if r.AdministrationFeeLimit > 0 && fee > r.AdministrationFeeLimit {
+			fee = r.AdministrationFeeLimit
+		}
+		cost.AdministrationFee = fee * float64(months)
+	}
+	return cost
+}
+
+// floorYen - 円未満を切り捨てる
+//   浮動小数点数の誤差で1円少なくならないように、切り捨てる前に小数点以下6桁で丸める
+func floorYen(v float64) float64 {
+	return math.Floor(math.Round(v*1e6) / 1e6)
+}
+
+// holdingDays - 新規の受渡日から返済の受渡日までの、両端を含めた日数
+func holdingDays(clock iClock, contractedAt time.Time, now time.Time) float64 {
+	from := settlementDay(clock, contractedAt)
+	to := settlementDay(clock, now)
+	if to.Before(from) {
+		return 0
+	}
+	return math.Round(to.Sub(from).Hours()/24) + 1
+}
+
+// settlementDay - 約定日の受渡日 (約定した営業日から2営業日後)
+//   休業日はカレンダーに従うので、土日だけでなく祝日や年末年始も飛ばす
+func settlementDay(clock iClock, contractedAt time.Time) time.Time {
+	return clock.addBusinessDays(clock.getBusinessDay(ExchangeTypeMargin, contractedAt), settlementDays)
+}
+
+// elapsedMonths - 新規の約定日からnowまでに経過した月数
+func elapsedMonths(contractedAt time.Time, now time.Time) int {
+	from := time.Date(contractedAt.Year(), contractedAt.Month(), contractedAt.Day(), 0, 0, 0, 0, contractedAt.Location())
+	months := 0
+	for !from.AddDate(0, months+1, 0).After(now) {
+		months++
+	}
+	return months
+}
@@ -0,0 +1,145 @@
+package virtual_security
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_MarginCost_Total(t *testing.T) {
+	t.Parallel()
+	want := 1111.0
+	got := MarginCost{Interest: 1, LendingFee: 10, Premium: 100, AdministrationFee: 1000}.Total()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_MarginCostRates_cost(t *testing.T) {
+	t.Parallel()
+	rates := MarginCostRates{
+		BuyInterestRate:        0.028,
+		LendingFeeRate:         0.011,
+		ShortPremiums:          map[string]float64{"1234": 0.05},
+		AdministrationFee:      0.11,
+		AdministrationFeeLimit: 1100,
+	}
+	friday := time.Date(2021, 9, 3, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		name       string
+		side       Side
+		symbolCode string
+		quantity   float64
+		now        time.Time
+		want       MarginCost
+	}{
+		{name: "日時がなければ0", side: SideBuy, symbolCode: "1234", quantity: 100, now: time.Time{}, want: MarginCost{}},
+		{name: "日計りでも買方金利が1日分かかる", side: SideBuy, symbolCode: "1234", quantity: 100, now: friday.Add(time.Hour),
+			want: MarginCost{Interest: 7}},
+		{name: "週末をまたいでも受渡日で日数を数える", side: SideBuy, symbolCode: "1234", quantity: 100, now: time.Date(2021, 9, 6, 10, 0, 0, 0, time.Local),
+			want: MarginCost{Interest: 15}},
+		{name: "売建玉には貸株料と逆日歩がかかる", side: SideSell, symbolCode: "1234", quantity: 100, now: time.Date(2021, 9, 6, 10, 0, 0, 0, time.Local),
+			want: MarginCost{LendingFee: 6, Premium: 10}},
+		{name: "逆日歩も円未満を切り捨てる", side: SideSell, symbolCode: "1234", quantity: 30, now: time.Date(2021, 9, 6, 10, 0, 0, 0, time.Local),
+			want: MarginCost{LendingFee: 1, Premium: 3}},
+		{name: "逆日歩のない銘柄は貸株料だけ", side: SideSell, symbolCode: "0001", quantity: 100, now: time.Date(2021, 9, 6, 10, 0, 0, 0, time.Local),
+			want: MarginCost{LendingFee: 6}},
+		{name: "1か月経過したら管理費がかかる", side: SideBuy, symbolCode: "1234", quantity: 100, now: time.Date(2021, 10, 4, 10, 0, 0, 0, time.Local),
+			want: MarginCost{Interest: 230, AdministrationFee: 11}},
+		{name: "管理費は上限を超えない", side: SideBuy, symbolCode: "1234", quantity: 20_000, now: time.Date(2021, 11, 4, 10, 0, 0, 0, time.Local),
+			want: MarginCost{Interest: 96_657, AdministrationFee: 2_200}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := rates.cost(newClock(), test.side, test.symbolCode, 1000, test.quantity, friday, test.now)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_settlementDay(t *testing.T) {
+	t.Parallel()
+	holidays := newClockWithCalendar(nil, newCalendar([]time.Time{time.Date(2021, 9, 20, 0, 0, 0, 0, time.Local)}), defaultTradingSchedule)
+	tests := []struct {
+		name  string
+		clock iClock
+		arg   time.Time
+		want  time.Time
+	}{
+		{name: "平日は2日後", clock: newClock(), arg: time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local), want: time.Date(2021, 9, 3, 0, 0, 0, 0, time.Local)},
+		{name: "木曜日は土日を飛ばして月曜日", clock: newClock(), arg: time.Date(2021, 9, 2, 10, 0, 0, 0, time.Local), want: time.Date(2021, 9, 6, 0, 0, 0, 0, time.Local)},
+		{name: "金曜日は土日を飛ばして火曜日", clock: newClock(), arg: time.Date(2021, 9, 3, 10, 0, 0, 0, time.Local), want: time.Date(2021, 9, 7, 0, 0, 0, 0, time.Local)},
+		{name: "カレンダーの祝日も飛ばす", clock: holidays, arg: time.Date(2021, 9, 16, 10, 0, 0, 0, time.Local), want: time.Date(2021, 9, 21, 0, 0, 0, 0, time.Local)},
+		{name: "年末年始も飛ばす", clock: newClock(), arg: time.Date(2021, 12, 29, 10, 0, 0, 0, time.Local), want: time.Date(2022, 1, 4, 0, 0, 0, 0, time.Local)},
+		{name: "休業日の約定は翌営業日から数える", clock: holidays, arg: time.Date(2021, 9, 20, 10, 0, 0, 0, time.Local), want: time.Date(2021, 9, 23, 0, 0, 0, 0, time.Local)},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := settlementDay(test.clock, test.arg)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_holdingDays(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		arg1 time.Time
+		arg2 time.Time
+		want float64
+	}{
+		{name: "同じ日なら1日", arg1: time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local), arg2: time.Date(2021, 9, 1, 15, 0, 0, 0, time.Local), want: 1},
+		{name: "金曜日から月曜日なら火曜日と水曜日の受渡日で2日", arg1: time.Date(2021, 9, 3, 9, 0, 0, 0, time.Local), arg2: time.Date(2021, 9, 6, 9, 0, 0, 0, time.Local), want: 2},
+		{name: "木曜日から金曜日なら月曜日と火曜日の受渡日で2日", arg1: time.Date(2021, 9, 2, 9, 0, 0, 0, time.Local), arg2: time.Date(2021, 9, 3, 9, 0, 0, 0, time.Local), want: 2},
+		{name: "水曜日から木曜日なら金曜日と月曜日の受渡日で4日", arg1: time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local), arg2: time.Date(2021, 9, 2, 9, 0, 0, 0, time.Local), want: 4},
+		{name: "年末年始をまたぐと受渡日で日数を数える", arg1: time.Date(2021, 12, 29, 9, 0, 0, 0, time.Local), arg2: time.Date(2021, 12, 30, 9, 0, 0, 0, time.Local), want: 2},
+		{name: "返済の方が前なら0", arg1: time.Date(2021, 9, 2, 9, 0, 0, 0, time.Local), arg2: time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local), want: 0},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := holdingDays(newClock(), test.arg1, test.arg2)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_elapsedMonths(t *testing.T) {
+	t.Parallel()
+	contractedAt := time.Date(2021, 9, 3, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		name string
+		arg  time.Time
+		want int
+	}{
+		{name: "1か月経っていなければ0", arg: time.Date(2021, 10, 2, 15, 0, 0, 0, time.Local), want: 0},
+		{name: "翌月の同じ日になったら1", arg: time.Date(2021, 10, 3, 0, 0, 0, 0, time.Local), want: 1},
+		{name: "年をまたいでも数える", arg: time.Date(2022, 1, 5, 0, 0, 0, 0, time.Local), want: 4},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := elapsedMonths(contractedAt, test.arg)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
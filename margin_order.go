@@ -26,6 +26,7 @@ type marginOrder struct {
 	ConfirmingCount    int                     // 約定確認回数
 	Message            string                  // メッセージ
 	HoldPositions      []*HoldPosition         // Exit時に拘束しているポジション
+	Modifications      []*OrderModification    // 訂正履歴
 	mtx                sync.Mutex
 }
 
@@ -39,19 +40,18 @@ type marginOrder struct {
 //}
 
 // isDied - 約定やキャンセルで終了した注文が一定時間経過して保持する必要がなくなっているかどうか
-func (o *marginOrder) isDied(now time.Time) bool {
+//   borderより前に取消や最後の約定があった注文は、保持する必要がない
+func (o *marginOrder) isDied(border time.Time) bool {
 	// 未終了のステータスなら死んでいない
 	if !o.OrderStatus.IsFixed() {
 		return false
 	}
 
-	border := now.AddDate(0, 0, -1)
-
-	// キャンセル日時があって、1日以上前なら死んだ注文
+	// キャンセル日時があって、borderより前なら死んだ注文
 	if !o.CanceledAt.IsZero() && o.CanceledAt.Before(border) {
 		return true
 	}
-	// 約定情報があって、最後の約定情報が1日以上前なら死んだ注文
+	// 約定情報があって、最後の約定情報がborderより前なら死んだ注文
 	if o.Contracts != nil && len(o.Contracts) > 0 && o.Contracts[len(o.Contracts)-1].ContractedAt.Before(border) {
 		return true
 	}
@@ -164,10 +164,38 @@ func (o *marginOrder) cancel(canceledAt time.Time) {
 
 	if o.OrderStatus.IsCancelable() {
 		o.CanceledAt = canceledAt
+		o.CanceledQuantity = o.OrderQuantity - o.ContractedQuantity
 		o.OrderStatus = OrderStatusCanceled
 	}
 }
 
+// modify - 注文を訂正し、訂正履歴を残す
+//   指値価格を訂正した注文は、新しい注文と同じく板と約定できるように約定確認回数を戻す
+//   注文数量を減らした分だけ拘束しているポジションを減らし、ポジションごとに減らした数量を返す
+func (o *marginOrder) modify(modification *ModifyOrderRequest, now time.Time) []ExitPosition {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	m := applyModification(o.LimitPrice, o.OrderQuantity, o.ExpiredAt, modification, now)
+	if m.LimitPrice != m.PrevLimitPrice {
+		o.ConfirmingCount = 0
+	}
+
+	var reduced []ExitPosition
+	if m.OrderQuantity < m.PrevOrderQuantity {
+		o.ExitPositionList, reduced = reduceHoldPositions(o.HoldPositions, o.ExitPositionList, m.PrevOrderQuantity-m.OrderQuantity)
+	}
+
+	o.LimitPrice = m.LimitPrice
+	o.OrderQuantity = m.OrderQuantity
+	o.ExpiredAt = m.ExpiredAt
+	if o.Modifications == nil {
+		o.Modifications = []*OrderModification{}
+	}
+	o.Modifications = append(o.Modifications, m)
+	return reduced
+}
+
 // addHoldPosition - 注文が拘束したポジションの情報を追加する
 func (o *marginOrder) addHoldPosition(positionCode string, quantity float64) {
 	o.mtx.Lock()
@@ -180,6 +208,20 @@ func (o *marginOrder) addHoldPosition(positionCode string, quantity float64) {
 	o.HoldPositions = append(o.HoldPositions, &HoldPosition{PositionCode: positionCode, HoldQuantity: quantity})
 }
 
+// exitedQuantity - 指定したポジションのうち、注文で返済済みの数量
+func (o *marginOrder) exitedQuantity(positionCode string) float64 {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	var quantity float64
+	for _, hp := range o.HoldPositions {
+		if hp.PositionCode == positionCode {
+			quantity += hp.ExitQuantity
+		}
+	}
+	return quantity
+}
+
 func (o *marginOrder) addExitPosition(positionCode string, quantity float64) {
 	o.mtx.Lock()
 	defer o.mtx.Unlock()
@@ -15,13 +15,17 @@ func getMarginOrderStore() iMarginOrderStore {
 	defer marginOrderStoreSingletonMutex.Unlock()
 
 	if marginOrderStoreSingleton == nil {
-		marginOrderStoreSingleton = &marginOrderStore{
-			store: map[string]*marginOrder{},
-		}
+		marginOrderStoreSingleton = newMarginOrderStore()
 	}
 	return marginOrderStoreSingleton
 }
 
+func newMarginOrderStore() iMarginOrderStore {
+	return &marginOrderStore{
+		store: map[string]*marginOrder{},
+	}
+}
+
 // iMarginOrderStore - 信用株式注文ストアのインターフェース
 type iMarginOrderStore interface {
 	getAll() []*marginOrder
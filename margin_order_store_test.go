@@ -33,6 +33,18 @@ func (t *testMarginOrderStore) removeByCode(code string) {
 	t.removeByCodeHistory = append(t.removeByCodeHistory, code)
 }
 
+func Test_newMarginOrderStore(t *testing.T) {
+	t.Parallel()
+	want := &marginOrderStore{store: map[string]*marginOrder{}}
+	got := newMarginOrderStore()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+	if got == newMarginOrderStore() {
+		t.Errorf("%s error\nwant: different store\ngot: same store\n", t.Name())
+	}
+}
+
 func Test_getMarginOrderStore(t *testing.T) {
 	got := getMarginOrderStore()
 	want := &marginOrderStore{store: map[string]*marginOrder{}}
@@ -16,41 +16,41 @@ func Test_marginOrder_isDied(t *testing.T) {
 	}{
 		{name: "未終了の注文なら生きている",
 			marginOrder: &marginOrder{OrderStatus: OrderStatusInOrder},
-			arg:         time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local),
+			arg:         time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
 			want:        false},
-		{name: "取消済み注文で、取消から1日以内なら生きている",
+		{name: "取消済み注文で、取消がborderより後なら生きている",
 			marginOrder: &marginOrder{OrderStatus: OrderStatusCanceled, CanceledAt: time.Date(2021, 6, 14, 11, 0, 0, 0, time.Local)},
-			arg:         time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local),
+			arg:         time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
 			want:        false},
-		{name: "取消済み注文で、取消から1日丁度なら生きている",
+		{name: "取消済み注文で、取消がborder丁度なら生きている",
 			marginOrder: &marginOrder{OrderStatus: OrderStatusCanceled, CanceledAt: time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local)},
-			arg:         time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local),
+			arg:         time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
 			want:        false},
-		{name: "取消済み注文で、取消から1日以上経っていたら死んでいる",
+		{name: "取消済み注文で、取消がborderより前なら死んでいる",
 			marginOrder: &marginOrder{OrderStatus: OrderStatusCanceled, CanceledAt: time.Date(2021, 6, 14, 9, 0, 0, 0, time.Local)},
-			arg:         time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local),
+			arg:         time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
 			want:        true},
-		{name: "約定済み注文で、最後の約定から1日以内なら生きている",
+		{name: "約定済み注文で、最後の約定がborderより後なら生きている",
 			marginOrder: &marginOrder{OrderStatus: OrderStatusDone, Contracts: []*Contract{
 				{ContractedAt: time.Date(2021, 6, 14, 9, 0, 0, 0, time.Local)},
 				{ContractedAt: time.Date(2021, 6, 14, 11, 0, 0, 0, time.Local)}}},
-			arg:  time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local),
+			arg:  time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
 			want: false},
-		{name: "約定済み注文で、最後の約定から1日丁度なら生きている",
+		{name: "約定済み注文で、最後の約定がborder丁度なら生きている",
 			marginOrder: &marginOrder{OrderStatus: OrderStatusDone, Contracts: []*Contract{
 				{ContractedAt: time.Date(2021, 6, 14, 9, 0, 0, 0, time.Local)},
 				{ContractedAt: time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local)}}},
-			arg:  time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local),
+			arg:  time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
 			want: false},
-		{name: "約定済み注文で、最後の約定から1日以上経っていたら死んでいる",
+		{name: "約定済み注文で、最後の約定がborderより前なら死んでいる",
 			marginOrder: &marginOrder{OrderStatus: OrderStatusDone, Contracts: []*Contract{
 				{ContractedAt: time.Date(2021, 6, 14, 9, 0, 0, 0, time.Local)},
 				{ContractedAt: time.Date(2021, 6, 14, 9, 30, 0, 0, time.Local)}}},
-			arg:  time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local),
+			arg:  time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
 			want: true},
 		{name: "終了した注文で、取消も約定も情報が無かったら死んだものとする",
 			marginOrder: &marginOrder{OrderStatus: OrderStatusDone, Contracts: []*Contract{}},
-			arg:         time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local),
+			arg:         time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
 			want:        true},
 	}
 
@@ -366,11 +366,12 @@ func Test_marginOrder_contract(t *testing.T) {
 func Test_marginOrder_cancel(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
-		name            string
-		marginOrder     *marginOrder
-		arg             time.Time
-		wantOrderStatus OrderStatus
-		wantCanceledAt  time.Time
+		name                 string
+		marginOrder          *marginOrder
+		arg                  time.Time
+		wantOrderStatus      OrderStatus
+		wantCanceledAt       time.Time
+		wantCanceledQuantity float64
 	}{
 		{name: "ステータスがnewなら取消状態に更新",
 			marginOrder:     &marginOrder{OrderStatus: OrderStatusNew},
@@ -383,10 +384,11 @@ func Test_marginOrder_cancel(t *testing.T) {
 			wantOrderStatus: OrderStatusCanceled,
 			wantCanceledAt:  time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local)},
 		{name: "ステータスがpartなら取消状態に更新",
-			marginOrder:     &marginOrder{OrderStatus: OrderStatusPart},
-			arg:             time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local),
-			wantOrderStatus: OrderStatusCanceled,
-			wantCanceledAt:  time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local)},
+			marginOrder:          &marginOrder{OrderStatus: OrderStatusPart, OrderQuantity: 300, ContractedQuantity: 100},
+			arg:                  time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local),
+			wantOrderStatus:      OrderStatusCanceled,
+			wantCanceledAt:       time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local),
+			wantCanceledQuantity: 200},
 		{name: "ステータスがdoneなら取消状態に更新できない",
 			marginOrder:     &marginOrder{OrderStatus: OrderStatusDone},
 			arg:             time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local),
@@ -406,8 +408,9 @@ func Test_marginOrder_cancel(t *testing.T) {
 			test.marginOrder.cancel(test.arg)
 			got1 := test.marginOrder.OrderStatus
 			got2 := test.marginOrder.CanceledAt
-			if !reflect.DeepEqual(test.wantOrderStatus, got1) || !reflect.DeepEqual(test.wantCanceledAt, got2) {
-				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.wantOrderStatus, test.wantCanceledAt, got1, got2)
+			got3 := test.marginOrder.CanceledQuantity
+			if !reflect.DeepEqual(test.wantOrderStatus, got1) || !reflect.DeepEqual(test.wantCanceledAt, got2) || !reflect.DeepEqual(test.wantCanceledQuantity, got3) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(), test.wantOrderStatus, test.wantCanceledAt, test.wantCanceledQuantity, got1, got2, got3)
 			}
 		})
 	}
@@ -483,3 +486,23 @@ func Test_marginOrder_addExitPosition(t *testing.T) {
 		})
 	}
 }
+
+func Test_marginOrder_modify(t *testing.T) {
+	t.Parallel()
+	order := &marginOrder{TradeType: TradeTypeExit, Side: SideSell, LimitPrice: 1000, OrderQuantity: 100, ConfirmingCount: 1,
+		ExpiredAt:        time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local),
+		ExitPositionList: []ExitPosition{{PositionCode: "mpo-1", Quantity: 30}, {PositionCode: "mpo-2", Quantity: 70}},
+		HoldPositions:    []*HoldPosition{{PositionCode: "mpo-1", HoldQuantity: 30}, {PositionCode: "mpo-2", HoldQuantity: 70, ExitQuantity: 20}}}
+	got := order.modify(&ModifyOrderRequest{LimitPrice: 990, OrderQuantity: 40}, time.Date(2021, 11, 5, 10, 0, 0, 0, time.Local))
+
+	want := []ExitPosition{{PositionCode: "mpo-2", Quantity: 50}, {PositionCode: "mpo-1", Quantity: 10}}
+	wantOrder := &marginOrder{TradeType: TradeTypeExit, Side: SideSell, LimitPrice: 990, OrderQuantity: 40, ConfirmingCount: 0,
+		ExpiredAt:        time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local),
+		ExitPositionList: []ExitPosition{{PositionCode: "mpo-1", Quantity: 20}, {PositionCode: "mpo-2", Quantity: 20}},
+		HoldPositions:    []*HoldPosition{{PositionCode: "mpo-1", HoldQuantity: 20}, {PositionCode: "mpo-2", HoldQuantity: 20, ExitQuantity: 20}},
+		Modifications: []*OrderModification{{ModifiedAt: time.Date(2021, 11, 5, 10, 0, 0, 0, time.Local), PrevLimitPrice: 1000, LimitPrice: 990, PrevOrderQuantity: 100, OrderQuantity: 40,
+			PrevExpiredAt: time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local), ExpiredAt: time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local)}}}
+	if !reflect.DeepEqual(want, got) || !reflect.DeepEqual(wantOrder, order) {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), want, wantOrder, got, order)
+	}
+}
@@ -15,13 +15,17 @@ func getMarginPositionStore() iMarginPositionStore {
 	defer marginPositionStoreSingletonMutex.Unlock()
 
 	if marginPositionStoreSingleton == nil {
-		marginPositionStoreSingleton = &marginPositionStore{
-			store: map[string]*marginPosition{},
-		}
+		marginPositionStoreSingleton = newMarginPositionStore()
 	}
 	return marginPositionStoreSingleton
 }
 
+func newMarginPositionStore() iMarginPositionStore {
+	return &marginPositionStore{
+		store: map[string]*marginPosition{},
+	}
+}
+
 // iMarginPositionStore - 信用株式ポジションストアのインターフェース
 type iMarginPositionStore interface {
 	getAll() []*marginPosition
@@ -34,6 +34,18 @@ func (t *testMarginPositionStore) removeByCode(code string) {
 	t.removeByCodeHistory = append(t.removeByCodeHistory, code)
 }
 
+func Test_newMarginPositionStore(t *testing.T) {
+	t.Parallel()
+	want := &marginPositionStore{store: map[string]*marginPosition{}}
+	got := newMarginPositionStore()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+	if got == newMarginPositionStore() {
+		t.Errorf("%s error\nwant: different store\ngot: same store\n", t.Name())
+	}
+}
+
 func Test_getMarginPositionStore(t *testing.T) {
 	got := getMarginPositionStore()
 	want := &marginPositionStore{
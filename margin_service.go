@@ -7,18 +7,24 @@ import (
 )
 
 func newMarginService(
+	clock iClock,
 	uuidGenerator iUUIDGenerator,
 	marginOrderStore iMarginOrderStore,
 	marginPositionStore iMarginPositionStore,
+	stockSymbolStore iStockSymbolStore,
 	validatorComponent iValidatorComponent,
 	stockContractComponent iStockContractComponent,
+	accountService iAccountService,
 ) iMarginService {
 	return &marginService{
+		clock:                  clock,
 		uuidGenerator:          uuidGenerator,
 		marginOrderStore:       marginOrderStore,
 		marginPositionStore:    marginPositionStore,
+		stockSymbolStore:       stockSymbolStore,
 		validatorComponent:     validatorComponent,
 		stockContractComponent: stockContractComponent,
+		accountService:         accountService,
 	}
 }
 
@@ -34,14 +40,30 @@ type iMarginService interface {
 	getMarginPositions() []*marginPosition
 	removeMarginPositionByCode(positionCode string)
 	cancelAndRelease(order *marginOrder, now time.Time) error
+	modify(order *marginOrder, modification *ModifyOrderRequest, price *symbolPrice, now time.Time) error
+	reserveEntryOrderMargin(order *marginOrder, price *symbolPrice) error
+	evaluatePositions(prices map[string]float64, now time.Time) bool
+	restore(orders []*marginOrder, positions []*marginPosition)
 }
 
 type marginService struct {
+	clock                  iClock
 	uuidGenerator          iUUIDGenerator
 	marginOrderStore       iMarginOrderStore
 	marginPositionStore    iMarginPositionStore
+	stockSymbolStore       iStockSymbolStore
 	validatorComponent     iValidatorComponent
 	stockContractComponent iStockContractComponent
+	accountService         iAccountService
+}
+
+// getSymbol - 登録されている株式銘柄 (登録されていなければnil)
+func (s *marginService) getSymbol(symbolCode string) *stockSymbol {
+	symbol, err := s.stockSymbolStore.getByCode(symbolCode)
+	if err != nil {
+		return nil
+	}
+	return symbol
 }
 
 func (s *marginService) newOrderCode() string {
@@ -77,16 +99,22 @@ func (s *marginService) toMarginOrder(order *MarginOrderRequest, now time.Time)
 		Contracts:          []*Contract{},
 	}
 
-	if order.ExpiredAt.IsZero() {
-		o.ExpiredAt = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
-	} else {
-		o.ExpiredAt = time.Date(order.ExpiredAt.Year(), order.ExpiredAt.Month(), order.ExpiredAt.Day(), 0, 0, 0, 0, time.Local)
+	// 逆指値注文は逆指値条件を満たすまで待機させる
+	if o.ExecutionCondition.IsStop() {
+		o.OrderStatus = OrderStatusWait
 	}
+
+	o.ExpiredAt = orderExpiredAt(s.clock, ExchangeTypeMargin, order.ExpiredAt, order.ExpireBusinessDays, now)
 	return o
 }
 
 func (s *marginService) validation(order *marginOrder, now time.Time) error {
-	return s.validatorComponent.isValidMarginOrder(order, now, s.marginPositionStore.getAll())
+	if order == nil {
+		return NilArgumentError
+	}
+
+	// 有効期限と値幅制限は、休業日に注文しても次の営業日で確認する
+	return s.validatorComponent.isValidMarginOrder(order, s.clock.getBusinessDay(ExchangeTypeMargin, now), s.getSymbol(order.SymbolCode), s.marginPositionStore.getAll())
 }
 
 func (s *marginService) confirmContract(order *marginOrder, price *symbolPrice, now time.Time) error {
@@ -95,14 +123,27 @@ func (s *marginService) confirmContract(order *marginOrder, price *symbolPrice,
 		return NilArgumentError
 	}
 
+	// 待機中の逆指値注文なら、発動条件を満たしているかを確認する
+	order.activate(price, now)
+
+	var err error
 	switch order.TradeType {
 	case TradeTypeEntry:
-		return s.entry(order, price, now)
+		err = s.entry(order, price, now)
 	case TradeTypeExit:
-		return s.exit(order, price, now)
+		err = s.exit(order, price, now)
 	default:
 		return InvalidTradeTypeError
 	}
+	if err != nil {
+		return err
+	}
+
+	// IOC注文は初回の約定確認で約定しなかった数量を取り消す
+	if order.executionCondition().IsIOC() && order.ConfirmingCount > 0 && order.OrderStatus.IsCancelable() {
+		return s.cancelAndRelease(order, now)
+	}
+	return nil
 }
 
 func (s *marginService) entry(order *marginOrder, price *symbolPrice, now time.Time) error {
@@ -117,28 +158,37 @@ func (s *marginService) entry(order *marginOrder, price *symbolPrice, now time.T
 		return nil
 	}
 
-	contractCode := s.newContractCode()
-	positionCode := s.newPositionCode()
-	order.contract(&Contract{
-		ContractCode: contractCode,
-		OrderCode:    order.Code,
-		PositionCode: positionCode,
-		Price:        contractResult.price,
-		Quantity:     order.OrderQuantity,
-		ContractedAt: contractResult.contractedAt,
-	})
-
-	s.marginPositionStore.save(&marginPosition{
-		Code:               positionCode,
-		OrderCode:          order.Code,
-		SymbolCode:         order.SymbolCode,
-		Side:               order.Side,
-		ContractedQuantity: order.ContractedQuantity,
-		OwnedQuantity:      order.ContractedQuantity,
-		Price:              contractResult.price,
-		ContractedAt:       contractResult.contractedAt,
-		mtx:                sync.Mutex{},
-	})
+	// 板を複数の気配値で約定した場合は、気配値ごとに約定と建玉を作る
+	tickSizeTable := s.getSymbol(order.SymbolCode).tickSizeTable()
+	for _, fill := range contractResult.contractFills() {
+		fillPrice := tickPrice(tickSizeTable, order.Side, fill.price)
+		contractCode := s.newContractCode()
+		positionCode := s.newPositionCode()
+		contract := &Contract{
+			ContractCode: contractCode,
+			OrderCode:    order.Code,
+			PositionCode: positionCode,
+			Price:        fillPrice,
+			Quantity:     fill.quantity,
+			ContractedAt: contractResult.contractedAt,
+		}
+		order.contract(contract)
+		s.accountService.contractMarginEntry(order, contract)
+		s.accountService.chargeContractFee(newFeeTarget(ExchangeTypeMargin, order.SymbolCode, order.Side, TradeTypeEntry, 1, contract), contract)
+
+		// 約定ごとに建玉を作る
+		s.marginPositionStore.save(&marginPosition{
+			Code:               positionCode,
+			OrderCode:          order.Code,
+			SymbolCode:         order.SymbolCode,
+			Side:               order.Side,
+			ContractedQuantity: contract.Quantity,
+			OwnedQuantity:      contract.Quantity,
+			Price:              fillPrice,
+			ContractedAt:       contractResult.contractedAt,
+			mtx:                sync.Mutex{},
+		})
+	}
 
 	return nil
 }
@@ -155,42 +205,80 @@ func (s *marginService) exit(order *marginOrder, price *symbolPrice, now time.Ti
 		return nil
 	}
 
-	// 指定されたポジションの一覧を取得し、exit可能かのチェック
+	// 指定されたポジションの一覧を取得し、約定した数量の分だけ先頭から返済する数量を決める
+	//   板を複数の気配値で約定した場合は、気配値ごとに返済する数量を決める
 	positions := make(map[string]*marginPosition)
-	for _, ep := range order.ExitPositionList {
-		p, err := s.marginPositionStore.getByCode(ep.PositionCode)
-		if err != nil {
-			return fmt.Errorf("position code: %s: %w", ep.PositionCode, err)
-		}
-		if err := p.exitable(ep.Quantity); err != nil {
-			return fmt.Errorf("position code: %s: %w", ep.PositionCode, err)
+	quantities := make(map[string]float64)
+	var exits []*marginExit
+	tickSizeTable := s.getSymbol(order.SymbolCode).tickSizeTable()
+	for _, fill := range contractResult.contractFills() {
+		fillPrice := tickPrice(tickSizeTable, order.Side, fill.price)
+		contractQuantity := fill.quantity
+		for _, ep := range order.ExitPositionList {
+			if contractQuantity <= 0 {
+				break
+			}
+
+			// 一部約定で返済済みの数量と、他の気配値で返済する数量は除く
+			quantity := ep.Quantity - order.exitedQuantity(ep.PositionCode) - quantities[ep.PositionCode]
+			if quantity <= 0 {
+				continue
+			}
+			if contractQuantity < quantity {
+				quantity = contractQuantity
+			}
+
+			p, ok := positions[ep.PositionCode]
+			if !ok {
+				var err error
+				p, err = s.marginPositionStore.getByCode(ep.PositionCode)
+				if err != nil {
+					return fmt.Errorf("position code: %s: %w", ep.PositionCode, err)
+				}
+			}
+			if err := p.exitable(quantities[ep.PositionCode] + quantity); err != nil {
+				return fmt.Errorf("position code: %s: %w", ep.PositionCode, err)
+			}
+			positions[p.Code] = p
+			quantities[p.Code] += quantity
+			exits = append(exits, &marginExit{position: p, price: fillPrice, quantity: quantity})
+			contractQuantity -= quantity
 		}
-		positions[p.Code] = p
 	}
 
-	for _, ep := range order.ExitPositionList {
-		p := positions[ep.PositionCode]
-
+	for _, e := range exits {
 		// ポジションの保有数量を返済する
 		// TODO 先にexit可能かのチェックをしているから基本的にエラーは無視できるけど、必要ならエラーチェックを追加する
-		_ = p.exit(ep.Quantity)
-		order.addExitPosition(p.Code, ep.Quantity) // 注文による返済数に加算しておく
+		_ = e.position.exit(e.quantity)
+		order.addExitPosition(e.position.Code, e.quantity) // 注文による返済数に加算しておく
 
 		// 注文に約定情報を追加
 		contractCode := s.newContractCode()
-		order.contract(&Contract{
+		contract := &Contract{
 			ContractCode: contractCode,
 			OrderCode:    order.Code,
-			PositionCode: p.Code,
-			Price:        contractResult.price,
-			Quantity:     ep.Quantity,
+			PositionCode: e.position.Code,
+			Price:        e.price,
+			Quantity:     e.quantity,
 			ContractedAt: contractResult.contractedAt,
-		})
+		}
+		order.contract(contract)
+
+		// 返済で確定した損益を口座に反映する
+		s.accountService.settleMarginContract(e.position, contract)
+		s.accountService.chargeContractFee(newFeeTarget(ExchangeTypeMargin, order.SymbolCode, order.Side, TradeTypeExit, 1, contract), contract)
 	}
 
 	return nil
 }
 
+// marginExit - 約定した気配値ごとの建玉の返済内容
+type marginExit struct {
+	position *marginPosition
+	price    float64
+	quantity float64
+}
+
 func (s *marginService) holdExitOrderPositions(order *marginOrder) error {
 	// 最低限のvalidation
 	if order == nil {
@@ -204,15 +292,18 @@ func (s *marginService) holdExitOrderPositions(order *marginOrder) error {
 	}
 
 	// positionをhold可能かのチェック
+	//   同じポジションが複数回指定されていれば、合計した数量をholdできる必要がある
 	posList := make([]*marginPosition, len(order.ExitPositionList))
+	holdQuantities := map[string]float64{}
 	for i, exit := range order.ExitPositionList {
 		pos, err := s.marginPositionStore.getByCode(exit.PositionCode)
 		if err != nil {
 			return fmt.Errorf("error position_code = %s: %w", exit.PositionCode, err)
 		}
-		if err := pos.holdable(exit.Quantity); err != nil {
+		holdQuantities[exit.PositionCode] += exit.Quantity
+		if err := pos.holdable(holdQuantities[exit.PositionCode]); err != nil {
 			return fmt.Errorf("error position_code = %s, owned_quantity = %.2f, hold_quantity = %.2f, exit_quantity = %.2f: %w",
-				exit.PositionCode, pos.OwnedQuantity, pos.HoldQuantity, exit.Quantity, err)
+				exit.PositionCode, pos.OwnedQuantity, pos.HoldQuantity, holdQuantities[exit.PositionCode], err)
 		}
 		posList[i] = pos
 	}
@@ -220,7 +311,9 @@ func (s *marginService) holdExitOrderPositions(order *marginOrder) error {
 	// チェック済みのポジションをholdする
 	for i, exit := range order.ExitPositionList {
 		pos := posList[i]
-		_ = pos.hold(exit.Quantity)
+		if err := pos.hold(exit.Quantity); err != nil {
+			return fmt.Errorf("error position_code = %s: %w", exit.PositionCode, err)
+		}
 		order.addHoldPosition(pos.Code, exit.Quantity)
 	}
 
@@ -251,6 +344,64 @@ func (s *marginService) removeMarginPositionByCode(positionCode string) {
 	s.marginPositionStore.removeByCode(positionCode)
 }
 
+// modify - 注文を訂正する
+//   新規注文なら訂正後の指値と約定していない数量で建玉予定金額を見積もり直す
+//   注文数量を減らした返済注文は、減らした数量の分だけ拘束しているポジションを開放する
+func (s *marginService) modify(order *marginOrder, modification *ModifyOrderRequest, price *symbolPrice, now time.Time) error {
+	if order == nil || modification == nil {
+		return NilArgumentError
+	}
+	if err := s.validatorComponent.isValidMarginOrderModification(order, modification, s.clock.getBusinessDay(ExchangeTypeMargin, now), s.getSymbol(order.SymbolCode)); err != nil {
+		return err
+	}
+
+	limitPrice, quantity := order.LimitPrice, order.OrderQuantity
+	if modification.LimitPrice > 0 {
+		limitPrice = modification.LimitPrice
+	}
+	if modification.OrderQuantity > 0 {
+		quantity = modification.OrderQuantity
+	}
+	if err := s.accountService.reserveModifiedMarginOrder(order, limitPrice, quantity-order.ContractedQuantity, price); err != nil {
+		return err
+	}
+
+	var res error
+	for _, r := range order.modify(modification, now) {
+		pos, err := s.marginPositionStore.getByCode(r.PositionCode)
+		if err != nil {
+			res = fmt.Errorf("拘束中の一部のポジションを解放できませんでした: %w", err)
+			continue
+		}
+		if err := pos.release(r.Quantity); err != nil {
+			res = fmt.Errorf("拘束中の一部のポジションを解放できませんでした: %w", err)
+		}
+	}
+	return res
+}
+
+// restore - 注文とポジションをストアに戻す
+//   ストアにある注文とポジションは消してから戻す
+func (s *marginService) restore(orders []*marginOrder, positions []*marginPosition) {
+	for _, o := range s.marginOrderStore.getAll() {
+		s.marginOrderStore.removeByCode(o.Code)
+	}
+	for _, p := range s.marginPositionStore.getAll() {
+		s.marginPositionStore.removeByCode(p.Code)
+	}
+
+	for _, o := range orders {
+		if o == nil {
+			continue
+		}
+		o.StopCondition.restoreActivation()
+		s.marginOrderStore.save(o)
+	}
+	for _, p := range positions {
+		s.marginPositionStore.save(p)
+	}
+}
+
 func (s *marginService) cancelAndRelease(order *marginOrder, now time.Time) error {
 	if order == nil {
 		return NilArgumentError
@@ -260,7 +411,12 @@ func (s *marginService) cancelAndRelease(order *marginOrder, now time.Time) erro
 	}
 	order.cancel(now)
 
-	// 売り注文なら拘束したポジションを開放する
+	// 新規注文なら建玉予定金額を開放する
+	if order.TradeType == TradeTypeEntry {
+		s.accountService.releaseMarginOrder(order)
+	}
+
+	// 返済注文なら拘束したポジションを開放する
 	var res error
 	if order.TradeType == TradeTypeExit {
 		for _, hp := range order.HoldPositions {
@@ -282,3 +438,12 @@ func (s *marginService) cancelAndRelease(order *marginOrder, now time.Time) erro
 
 	return res
 }
+
+func (s *marginService) reserveEntryOrderMargin(order *marginOrder, price *symbolPrice) error {
+	return s.accountService.reserveMarginOrder(order, price)
+}
+
+// evaluatePositions - 保有中の建玉を評価し、追証による強制決済が必要かを返す
+func (s *marginService) evaluatePositions(prices map[string]float64, now time.Time) bool {
+	return s.accountService.evaluateMarginPositions(s.marginPositionStore.getAll(), prices, now)
+}
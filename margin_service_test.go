@@ -10,19 +10,32 @@ import (
 
 type testMarginService struct {
 	iMarginService
-	toMarginOrder1              *marginOrder
-	validation1                 error
-	confirmContract1            error
-	confirmContractCount        int
-	holdExitOrderPositions1     error
-	holdExitOrderPositionsCount int
-	getMarginOrders1            []*marginOrder
-	getMarginOrderByCode1       *marginOrder
-	getMarginOrderByCode2       error
-	saveMarginOrderHistory      []*marginOrder
-	getMarginPositions1         []*marginPosition
-	cancelAndRelease1           error
-	cancelAndReleaseCount       int
+	toMarginOrder1               *marginOrder
+	validation1                  error
+	confirmContract1             error
+	confirmContractCount         int
+	holdExitOrderPositions1      error
+	holdExitOrderPositionsCount  int
+	getMarginOrders1             []*marginOrder
+	getMarginOrderByCode1        *marginOrder
+	getMarginOrderByCode2        error
+	saveMarginOrderHistory       []*marginOrder
+	getMarginPositions1          []*marginPosition
+	cancelAndRelease1            error
+	cancelAndReleaseCount        int
+	reserveEntryOrderMargin1     error
+	reserveEntryOrderMarginCount int
+	evaluatePositions1           bool
+	evaluatePositionsCount       int
+}
+
+func (t *testMarginService) reserveEntryOrderMargin(*marginOrder, *symbolPrice) error {
+	t.reserveEntryOrderMarginCount++
+	return t.reserveEntryOrderMargin1
+}
+func (t *testMarginService) evaluatePositions(map[string]float64, time.Time) bool {
+	t.evaluatePositionsCount++
+	return t.evaluatePositions1
 }
 
 func (t *testMarginService) toMarginOrder(*MarginOrderRequest, time.Time) *marginOrder {
@@ -124,7 +137,7 @@ func Test_marginService_toMarginOrder(t *testing.T) {
 				OrderedAt:          time.Date(2021, 8, 17, 8, 0, 0, 0, time.Local),
 				Contracts:          []*Contract{},
 			}},
-		{name: "有効期限があれば指定された有効期限を設定する",
+		{name: "有効期限があれば指定された有効期限を設定し、逆指値注文なら待機状態になる",
 			arg1: &MarginOrderRequest{
 				TradeType:          TradeTypeExit,
 				Side:               SideSell,
@@ -142,7 +155,7 @@ func Test_marginService_toMarginOrder(t *testing.T) {
 			arg2: time.Date(2021, 8, 17, 11, 0, 0, 0, time.Local),
 			want: &marginOrder{
 				Code:               "mor-1234",
-				OrderStatus:        OrderStatusInOrder,
+				OrderStatus:        OrderStatusWait,
 				TradeType:          TradeTypeExit,
 				Side:               SideSell,
 				ExecutionCondition: StockExecutionConditionStop,
@@ -167,7 +180,7 @@ func Test_marginService_toMarginOrder(t *testing.T) {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			service := &marginService{uuidGenerator: &testUUIDGenerator{generator1: []string{"1234"}}}
+			service := &marginService{clock: &clock{}, uuidGenerator: &testUUIDGenerator{generator1: []string{"1234"}}}
 			got := service.toMarginOrder(test.arg1, test.arg2)
 			if !reflect.DeepEqual(test.want, got) {
 				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
@@ -195,7 +208,7 @@ func Test_marginService_validation(t *testing.T) {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			service := &marginService{marginPositionStore: &testMarginPositionStore{}, validatorComponent: &testValidatorComponent{isValidMarginOrder1: test.isValidMarginOrder1}}
+			service := &marginService{clock: &clock{}, marginPositionStore: &testMarginPositionStore{}, stockSymbolStore: &testStockSymbolStore{getByCode2: NoDataError}, validatorComponent: &testValidatorComponent{isValidMarginOrder1: test.isValidMarginOrder1}}
 			got := service.validation(&marginOrder{}, time.Now())
 			if !reflect.DeepEqual(test.want, got) {
 				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
@@ -215,6 +228,7 @@ func Test_marginService_entry(t *testing.T) {
 		want                  error
 		wantPositionStoreSave []*marginPosition
 		wantArg1              *marginOrder
+		wantContractEntry     []*Contract
 	}{
 		{name: "注文がnilならエラー",
 			service:               &marginService{},
@@ -241,12 +255,13 @@ func Test_marginService_entry(t *testing.T) {
 		{name: "約定チェックで約定すれば約定確認後の注文と新しいポジションを保存する",
 			service: &marginService{
 				uuidGenerator:          &testUUIDGenerator{generator1: []string{"01", "02", "03", "04", "05"}},
-				stockContractComponent: &testStockContractComponent{confirmMarginOrderContract1: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}}},
+				stockContractComponent: &testStockContractComponent{confirmMarginOrderContract1: &confirmContractResult{isContracted: true, price: 1000, quantity: 100, contractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}}},
 			arg1:                  &marginOrder{SymbolCode: "1234", Side: SideBuy, Code: "mor-01", OrderQuantity: 100},
 			arg2:                  &symbolPrice{},
 			want:                  nil,
 			wantPositionStoreSave: []*marginPosition{{Code: "mpo-02", OrderCode: "mor-01", SymbolCode: "1234", Side: SideBuy, ContractedQuantity: 100, OwnedQuantity: 100, HoldQuantity: 0, Price: 1000, ContractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}},
-			wantArg1:              &marginOrder{SymbolCode: "1234", OrderStatus: OrderStatusDone, Side: SideBuy, Code: "mor-01", OrderQuantity: 100, ContractedQuantity: 100, Contracts: []*Contract{{ContractCode: "mco-01", OrderCode: "mor-01", PositionCode: "mpo-02", Price: 1000, Quantity: 100, ContractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}}}},
+			wantArg1:              &marginOrder{SymbolCode: "1234", OrderStatus: OrderStatusDone, Side: SideBuy, Code: "mor-01", OrderQuantity: 100, ContractedQuantity: 100, Contracts: []*Contract{{ContractCode: "mco-01", OrderCode: "mor-01", PositionCode: "mpo-02", Price: 1000, Quantity: 100, ContractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}}},
+			wantContractEntry:     []*Contract{{ContractCode: "mco-01", OrderCode: "mor-01", PositionCode: "mpo-02", Price: 1000, Quantity: 100, ContractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}}},
 	}
 
 	for _, test := range tests {
@@ -257,13 +272,17 @@ func Test_marginService_entry(t *testing.T) {
 			test.service.marginOrderStore = marginOrderStore
 			marginPositionStore := &testMarginPositionStore{saveHistory: []*marginPosition{}}
 			test.service.marginPositionStore = marginPositionStore
+			test.service.stockSymbolStore = &testStockSymbolStore{getByCode2: NoDataError}
+			accountService := &testAccountService{}
+			test.service.accountService = accountService
 			got := test.service.entry(test.arg1, test.arg2, test.arg3)
 			if !errors.Is(got, test.want) ||
 				!reflect.DeepEqual(test.wantPositionStoreSave, marginPositionStore.saveHistory) ||
-				!reflect.DeepEqual(test.wantArg1, test.arg1) {
-				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(),
-					test.want, test.wantPositionStoreSave, test.wantArg1,
-					got, marginPositionStore.saveHistory, test.arg1)
+				!reflect.DeepEqual(test.wantArg1, test.arg1) ||
+				!reflect.DeepEqual(test.wantContractEntry, accountService.contractMarginEntryHistory) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantPositionStoreSave, test.wantArg1, test.wantContractEntry,
+					got, marginPositionStore.saveHistory, test.arg1, accountService.contractMarginEntryHistory)
 			}
 		})
 	}
@@ -282,6 +301,7 @@ func Test_marginService_exit(t *testing.T) {
 		want          error
 		wantArg1      *marginOrder
 		wantPosition  *marginPosition
+		wantSettle    []*Contract
 	}{
 		{name: "orderがnilならエラー",
 			service:       &marginService{},
@@ -314,7 +334,7 @@ func Test_marginService_exit(t *testing.T) {
 			wantArg1:      &marginOrder{},
 			wantPosition:  nil},
 		{name: "指定したポジションがなければエラー",
-			service:       &marginService{stockContractComponent: &testStockContractComponent{confirmMarginOrderContract1: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}}},
+			service:       &marginService{stockContractComponent: &testStockContractComponent{confirmMarginOrderContract1: &confirmContractResult{isContracted: true, price: 1000, quantity: 100, contractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}}},
 			orderStore:    &testMarginOrderStore{saveHistory: []*marginOrder{}},
 			positionStore: &testMarginPositionStore{getByCode1: nil, getByCode2: NoDataError},
 			arg1:          &marginOrder{ExitPositionList: []ExitPosition{{PositionCode: "mpo-01", Quantity: 100}}},
@@ -324,7 +344,7 @@ func Test_marginService_exit(t *testing.T) {
 			wantArg1:      &marginOrder{ExitPositionList: []ExitPosition{{PositionCode: "mpo-01", Quantity: 100}}},
 			wantPosition:  nil},
 		{name: "指定したポジションがexitできない状態ならエラー",
-			service:       &marginService{stockContractComponent: &testStockContractComponent{confirmMarginOrderContract1: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}}},
+			service:       &marginService{stockContractComponent: &testStockContractComponent{confirmMarginOrderContract1: &confirmContractResult{isContracted: true, price: 1000, quantity: 100, contractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}}},
 			orderStore:    &testMarginOrderStore{saveHistory: []*marginOrder{}},
 			positionStore: &testMarginPositionStore{getByCode1: &marginPosition{Code: "mpo-01", OwnedQuantity: 100, HoldQuantity: 50}, getByCode2: nil},
 			arg1:          &marginOrder{ExitPositionList: []ExitPosition{{PositionCode: "mpo-01", Quantity: 100}}},
@@ -335,7 +355,7 @@ func Test_marginService_exit(t *testing.T) {
 			wantPosition:  &marginPosition{Code: "mpo-01", OwnedQuantity: 100, HoldQuantity: 50}},
 		{name: "ポジションをexitし、約定状態を保存する",
 			service: &marginService{
-				stockContractComponent: &testStockContractComponent{confirmMarginOrderContract1: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}},
+				stockContractComponent: &testStockContractComponent{confirmMarginOrderContract1: &confirmContractResult{isContracted: true, price: 1000, quantity: 100, contractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}},
 				uuidGenerator:          &testUUIDGenerator{generator1: []string{"01", "02", "03"}}},
 			orderStore:    &testMarginOrderStore{saveHistory: []*marginOrder{}},
 			positionStore: &testMarginPositionStore{getByCode1: &marginPosition{Code: "mpo-01", OwnedQuantity: 100, HoldQuantity: 100}, getByCode2: nil},
@@ -344,22 +364,57 @@ func Test_marginService_exit(t *testing.T) {
 			arg3:          time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local),
 			want:          nil,
 			wantArg1:      &marginOrder{Code: "mor-01", OrderStatus: OrderStatusDone, OrderQuantity: 100, ContractedQuantity: 100, ExitPositionList: []ExitPosition{{PositionCode: "mpo-01", Quantity: 100}}, HoldPositions: []*HoldPosition{{PositionCode: "mpo-01", HoldQuantity: 100, ExitQuantity: 100}}, Contracts: []*Contract{{ContractCode: "mco-01", OrderCode: "mor-01", PositionCode: "mpo-01", Price: 1000, Quantity: 100, ContractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}}},
-			wantPosition:  &marginPosition{Code: "mpo-01", OwnedQuantity: 0, HoldQuantity: 0}},
+			wantPosition:  &marginPosition{Code: "mpo-01", OwnedQuantity: 0, HoldQuantity: 0},
+			wantSettle:    []*Contract{{ContractCode: "mco-01", OrderCode: "mor-01", PositionCode: "mpo-01", Price: 1000, Quantity: 100, ContractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}}},
+		{name: "一部約定なら約定した数量だけ、返済済みの数量を除いて返済する",
+			service: &marginService{
+				stockContractComponent: &testStockContractComponent{confirmMarginOrderContract1: &confirmContractResult{isContracted: true, price: 1000, quantity: 30, contractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}},
+				uuidGenerator:          &testUUIDGenerator{generator1: []string{"01", "02", "03"}}},
+			orderStore:    &testMarginOrderStore{saveHistory: []*marginOrder{}},
+			positionStore: &testMarginPositionStore{getByCode1: &marginPosition{Code: "mpo-01", OwnedQuantity: 60, HoldQuantity: 60}, getByCode2: nil},
+			arg1:          &marginOrder{Code: "mor-01", OrderStatus: OrderStatusPart, OrderQuantity: 100, ContractedQuantity: 40, ExitPositionList: []ExitPosition{{PositionCode: "mpo-01", Quantity: 100}}, HoldPositions: []*HoldPosition{{PositionCode: "mpo-01", HoldQuantity: 100, ExitQuantity: 40}}},
+			arg2:          &symbolPrice{},
+			arg3:          time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local),
+			want:          nil,
+			wantArg1:      &marginOrder{Code: "mor-01", OrderStatus: OrderStatusPart, OrderQuantity: 100, ContractedQuantity: 70, ExitPositionList: []ExitPosition{{PositionCode: "mpo-01", Quantity: 100}}, HoldPositions: []*HoldPosition{{PositionCode: "mpo-01", HoldQuantity: 100, ExitQuantity: 70}}, Contracts: []*Contract{{ContractCode: "mco-01", OrderCode: "mor-01", PositionCode: "mpo-01", Price: 1000, Quantity: 30, ContractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}}},
+			wantPosition:  &marginPosition{Code: "mpo-01", OwnedQuantity: 30, HoldQuantity: 30},
+			wantSettle:    []*Contract{{ContractCode: "mco-01", OrderCode: "mor-01", PositionCode: "mpo-01", Price: 1000, Quantity: 30, ContractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}}},
+		{name: "複数の気配値で約定したら、気配値ごとに返済する",
+			service: &marginService{
+				stockContractComponent: &testStockContractComponent{confirmMarginOrderContract1: &confirmContractResult{isContracted: true, price: 1000, quantity: 100, contractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local), fills: []*contractFill{{price: 1000, quantity: 60}, {price: 999, quantity: 40}}}},
+				uuidGenerator:          &testUUIDGenerator{generator1: []string{"01", "02", "03"}}},
+			orderStore:    &testMarginOrderStore{saveHistory: []*marginOrder{}},
+			positionStore: &testMarginPositionStore{getByCode1: &marginPosition{Code: "mpo-01", OwnedQuantity: 100, HoldQuantity: 100}, getByCode2: nil},
+			arg1:          &marginOrder{Code: "mor-01", OrderQuantity: 100, ExitPositionList: []ExitPosition{{PositionCode: "mpo-01", Quantity: 100}}, HoldPositions: []*HoldPosition{{PositionCode: "mpo-01", HoldQuantity: 100}}},
+			arg2:          &symbolPrice{},
+			arg3:          time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local),
+			want:          nil,
+			wantArg1: &marginOrder{Code: "mor-01", OrderStatus: OrderStatusDone, OrderQuantity: 100, ContractedQuantity: 100, ExitPositionList: []ExitPosition{{PositionCode: "mpo-01", Quantity: 100}}, HoldPositions: []*HoldPosition{{PositionCode: "mpo-01", HoldQuantity: 100, ExitQuantity: 100}}, Contracts: []*Contract{
+				{ContractCode: "mco-01", OrderCode: "mor-01", PositionCode: "mpo-01", Price: 1000, Quantity: 60, ContractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)},
+				{ContractCode: "mco-02", OrderCode: "mor-01", PositionCode: "mpo-01", Price: 999, Quantity: 40, ContractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}}},
+			wantPosition: &marginPosition{Code: "mpo-01", OwnedQuantity: 0, HoldQuantity: 0},
+			wantSettle: []*Contract{
+				{ContractCode: "mco-01", OrderCode: "mor-01", PositionCode: "mpo-01", Price: 1000, Quantity: 60, ContractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)},
+				{ContractCode: "mco-02", OrderCode: "mor-01", PositionCode: "mpo-01", Price: 999, Quantity: 40, ContractedAt: time.Date(2021, 8, 20, 14, 0, 0, 0, time.Local)}}},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
+			accountService := &testAccountService{}
 			test.service.marginOrderStore = test.orderStore
 			test.service.marginPositionStore = test.positionStore
+			test.service.stockSymbolStore = &testStockSymbolStore{getByCode2: NoDataError}
+			test.service.accountService = accountService
 			got := test.service.exit(test.arg1, test.arg2, test.arg3)
 			if !errors.Is(got, test.want) ||
 				!reflect.DeepEqual(test.wantArg1, test.arg1) ||
-				!reflect.DeepEqual(test.wantPosition, test.positionStore.getByCode1) {
-				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(),
-					test.want, test.wantArg1, test.wantPosition,
-					got, test.arg1, test.positionStore.getByCode1)
+				!reflect.DeepEqual(test.wantPosition, test.positionStore.getByCode1) ||
+				!reflect.DeepEqual(test.wantSettle, accountService.settleMarginContractHistory) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantArg1, test.wantPosition, test.wantSettle,
+					got, test.arg1, test.positionStore.getByCode1, accountService.settleMarginContractHistory)
 			}
 		})
 	}
@@ -582,6 +637,12 @@ func Test_marginService_holdExitOrderPositions(t *testing.T) {
 			want:                NotEnoughOwnedQuantityError,
 			wantPosition:        &marginPosition{OwnedQuantity: 100, HoldQuantity: 50},
 			wantArg1:            &marginOrder{TradeType: TradeTypeExit, ExitPositionList: []ExitPosition{{PositionCode: "mpo-01", Quantity: 100}}}},
+		{name: "同じポジションが複数回指定されていて、合計をhold出来なければエラー",
+			marginPositionStore: &testMarginPositionStore{getByCode1: &marginPosition{Code: "mpo-01", OwnedQuantity: 100, HoldQuantity: 0}, getByCode2: nil},
+			arg1:                &marginOrder{TradeType: TradeTypeExit, ExitPositionList: []ExitPosition{{PositionCode: "mpo-01", Quantity: 100}, {PositionCode: "mpo-01", Quantity: 100}}},
+			want:                NotEnoughOwnedQuantityError,
+			wantPosition:        &marginPosition{Code: "mpo-01", OwnedQuantity: 100, HoldQuantity: 0},
+			wantArg1:            &marginOrder{TradeType: TradeTypeExit, ExitPositionList: []ExitPosition{{PositionCode: "mpo-01", Quantity: 100}, {PositionCode: "mpo-01", Quantity: 100}}}},
 	}
 
 	for _, test := range tests {
@@ -608,30 +669,106 @@ func Test_marginService_confirmContract(t *testing.T) {
 		arg2                       *symbolPrice
 		arg3                       time.Time
 		want                       error
+		wantArg1                   *marginOrder
 	}{
 		{name: "注文がnilならエラー", arg2: &symbolPrice{}, want: NilArgumentError},
-		{name: "価格がnilならエラー", arg1: &marginOrder{}, want: NilArgumentError},
-		{name: "取引区分がentryでもexitでもなければエラー", arg1: &marginOrder{TradeType: TradeTypeUnspecified}, arg2: &symbolPrice{}, want: InvalidTradeTypeError},
+		{name: "価格がnilならエラー", arg1: &marginOrder{}, want: NilArgumentError, wantArg1: &marginOrder{}},
+		{name: "取引区分がentryでもexitでもなければエラー", arg1: &marginOrder{TradeType: TradeTypeUnspecified}, arg2: &symbolPrice{}, want: InvalidTradeTypeError, wantArg1: &marginOrder{TradeType: TradeTypeUnspecified}},
 		{name: "取引区分がentryならentryが叩かれる",
 			confirmMarginOrderContract: &confirmContractResult{isContracted: false},
 			arg1:                       &marginOrder{TradeType: TradeTypeEntry},
 			arg2:                       &symbolPrice{},
-			want:                       nil},
+			want:                       nil,
+			wantArg1:                   &marginOrder{TradeType: TradeTypeEntry}},
 		{name: "取引区分がexitならexitが叩かれる",
 			confirmMarginOrderContract: &confirmContractResult{isContracted: false},
 			arg1:                       &marginOrder{TradeType: TradeTypeExit},
 			arg2:                       &symbolPrice{},
-			want:                       nil},
+			want:                       nil,
+			wantArg1:                   &marginOrder{TradeType: TradeTypeExit}},
+		{name: "待機中の逆指値注文が逆指値条件を満たしたら注文中になる",
+			confirmMarginOrderContract: &confirmContractResult{isContracted: false},
+			arg1: &marginOrder{
+				TradeType:          TradeTypeEntry,
+				OrderStatus:        OrderStatusWait,
+				SymbolCode:         "1234",
+				ExecutionCondition: StockExecutionConditionStop,
+				StopCondition: &StockStopCondition{
+					StopPrice:                  1000,
+					ComparisonOperator:         ComparisonOperatorLE,
+					ExecutionConditionAfterHit: StockExecutionConditionMO,
+				}},
+			arg2: &symbolPrice{SymbolCode: "1234", Price: 1010, PriceTime: time.Date(2021, 8, 23, 10, 0, 0, 0, time.Local)},
+			arg3: time.Date(2021, 8, 23, 10, 0, 1, 0, time.Local),
+			want: nil,
+			wantArg1: &marginOrder{
+				TradeType:          TradeTypeEntry,
+				OrderStatus:        OrderStatusInOrder,
+				SymbolCode:         "1234",
+				ExecutionCondition: StockExecutionConditionStop,
+				StopCondition: &StockStopCondition{
+					StopPrice:                  1000,
+					ComparisonOperator:         ComparisonOperatorLE,
+					ExecutionConditionAfterHit: StockExecutionConditionMO,
+					ActivatedAt:                time.Date(2021, 8, 23, 10, 0, 1, 0, time.Local),
+					isActivate:                 true,
+				}}},
+		{name: "待機中の逆指値注文が逆指値条件を満たさなければ待機のまま",
+			confirmMarginOrderContract: &confirmContractResult{isContracted: false},
+			arg1: &marginOrder{
+				TradeType:          TradeTypeEntry,
+				OrderStatus:        OrderStatusWait,
+				SymbolCode:         "1234",
+				ExecutionCondition: StockExecutionConditionStop,
+				StopCondition: &StockStopCondition{
+					StopPrice:                  1000,
+					ComparisonOperator:         ComparisonOperatorLE,
+					ExecutionConditionAfterHit: StockExecutionConditionMO,
+				}},
+			arg2: &symbolPrice{SymbolCode: "1234", Price: 990, PriceTime: time.Date(2021, 8, 23, 10, 0, 0, 0, time.Local)},
+			arg3: time.Date(2021, 8, 23, 10, 0, 1, 0, time.Local),
+			want: nil,
+			wantArg1: &marginOrder{
+				TradeType:          TradeTypeEntry,
+				OrderStatus:        OrderStatusWait,
+				SymbolCode:         "1234",
+				ExecutionCondition: StockExecutionConditionStop,
+				StopCondition: &StockStopCondition{
+					StopPrice:                  1000,
+					ComparisonOperator:         ComparisonOperatorLE,
+					ExecutionConditionAfterHit: StockExecutionConditionMO,
+				}}},
+		{name: "IOC注文は初回の約定確認で約定しなかった数量を取り消す",
+			confirmMarginOrderContract: &confirmContractResult{isContracted: false},
+			arg1:                       &marginOrder{TradeType: TradeTypeEntry, OrderStatus: OrderStatusInOrder, ExecutionCondition: StockExecutionConditionIOCLO, OrderQuantity: 100, ConfirmingCount: 1},
+			arg2:                       &symbolPrice{},
+			arg3:                       time.Date(2021, 8, 23, 10, 0, 1, 0, time.Local),
+			want:                       nil,
+			wantArg1:                   &marginOrder{TradeType: TradeTypeEntry, OrderStatus: OrderStatusCanceled, ExecutionCondition: StockExecutionConditionIOCLO, OrderQuantity: 100, CanceledQuantity: 100, ConfirmingCount: 1, CanceledAt: time.Date(2021, 8, 23, 10, 0, 1, 0, time.Local)}},
+		{name: "約定確認をしていないIOC注文は取り消さない",
+			confirmMarginOrderContract: &confirmContractResult{isContracted: false},
+			arg1:                       &marginOrder{TradeType: TradeTypeEntry, OrderStatus: OrderStatusInOrder, ExecutionCondition: StockExecutionConditionIOCMO, OrderQuantity: 100},
+			arg2:                       &symbolPrice{},
+			arg3:                       time.Date(2021, 8, 23, 10, 0, 1, 0, time.Local),
+			want:                       nil,
+			wantArg1:                   &marginOrder{TradeType: TradeTypeEntry, OrderStatus: OrderStatusInOrder, ExecutionCondition: StockExecutionConditionIOCMO, OrderQuantity: 100}},
+		{name: "IOC以外の注文は約定確認後も取り消さない",
+			confirmMarginOrderContract: &confirmContractResult{isContracted: false},
+			arg1:                       &marginOrder{TradeType: TradeTypeEntry, OrderStatus: OrderStatusInOrder, ExecutionCondition: StockExecutionConditionLO, OrderQuantity: 100, ConfirmingCount: 1},
+			arg2:                       &symbolPrice{},
+			arg3:                       time.Date(2021, 8, 23, 10, 0, 1, 0, time.Local),
+			want:                       nil,
+			wantArg1:                   &marginOrder{TradeType: TradeTypeEntry, OrderStatus: OrderStatusInOrder, ExecutionCondition: StockExecutionConditionLO, OrderQuantity: 100, ConfirmingCount: 1}},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			service := &marginService{stockContractComponent: &testStockContractComponent{confirmMarginOrderContract1: test.confirmMarginOrderContract}}
+			service := &marginService{stockContractComponent: &testStockContractComponent{confirmMarginOrderContract1: test.confirmMarginOrderContract}, accountService: &testAccountService{}}
 			got := service.confirmContract(test.arg1, test.arg2, test.arg3)
-			if !reflect.DeepEqual(test.want, got) {
-				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			if !reflect.DeepEqual(test.want, got) || !reflect.DeepEqual(test.wantArg1, test.arg1) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want, test.wantArg1, got, test.arg1)
 			}
 		})
 	}
@@ -703,14 +840,102 @@ func Test_marginService_cancelAndRelease(t *testing.T) {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			service := &marginService{marginPositionStore: test.marginPositionStore}
+			accountService := &testAccountService{}
+			service := &marginService{marginPositionStore: test.marginPositionStore, accountService: accountService}
 			got := service.cancelAndRelease(test.arg1, test.arg2)
+			wantRelease := 0
+			if test.arg1 != nil && test.arg1.TradeType == TradeTypeEntry {
+				wantRelease = 1
+			}
 			if !errors.Is(got, test.want) ||
 				!reflect.DeepEqual(test.wantArg1, test.arg1) ||
-				!reflect.DeepEqual(test.wantPosition, test.marginPositionStore.getByCode1) {
-				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(),
-					test.want, test.wantArg1, test.wantPosition,
-					got, test.arg1, test.marginPositionStore.getByCode1)
+				!reflect.DeepEqual(test.wantPosition, test.marginPositionStore.getByCode1) ||
+				wantRelease != len(accountService.releaseMarginOrderHistory) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantArg1, test.wantPosition, wantRelease,
+					got, test.arg1, test.marginPositionStore.getByCode1, len(accountService.releaseMarginOrderHistory))
+			}
+		})
+	}
+}
+
+func Test_marginService_modify(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2021, 11, 5, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		name                   string
+		validatorComponent     *testValidatorComponent
+		accountService         *testAccountService
+		marginPositionStore    *testMarginPositionStore
+		order                  *marginOrder
+		modification           *ModifyOrderRequest
+		want                   error
+		wantOrderQuantity      float64
+		wantReserveModified    []float64
+		wantPosition           *marginPosition
+		wantModificationsCount int
+	}{
+		{name: "訂正がnilならエラー",
+			validatorComponent:  &testValidatorComponent{},
+			accountService:      &testAccountService{},
+			marginPositionStore: &testMarginPositionStore{},
+			order:               &marginOrder{TradeType: TradeTypeEntry, OrderQuantity: 100},
+			modification:        nil,
+			want:                NilArgumentError,
+			wantOrderQuantity:   100},
+		{name: "訂正がvalidationでエラーになったらエラーを返し、注文は訂正しない",
+			validatorComponent:  &testValidatorComponent{isValidModification1: UnmodifiableOrderError},
+			accountService:      &testAccountService{},
+			marginPositionStore: &testMarginPositionStore{},
+			order:               &marginOrder{TradeType: TradeTypeEntry, OrderQuantity: 100},
+			modification:        &ModifyOrderRequest{OrderQuantity: 50},
+			want:                UnmodifiableOrderError,
+			wantOrderQuantity:   100},
+		{name: "新規注文は、訂正後の指値と約定していない数量で建玉予定金額を登録し直して訂正する",
+			validatorComponent:     &testValidatorComponent{},
+			accountService:         &testAccountService{},
+			marginPositionStore:    &testMarginPositionStore{},
+			order:                  &marginOrder{TradeType: TradeTypeEntry, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100, ContractedQuantity: 20},
+			modification:           &ModifyOrderRequest{LimitPrice: 990, OrderQuantity: 50},
+			want:                   nil,
+			wantOrderQuantity:      50,
+			wantReserveModified:    []float64{990, 30},
+			wantModificationsCount: 1},
+		{name: "返済注文の数量を減らしたら、減らした分の建玉を開放する",
+			validatorComponent:  &testValidatorComponent{},
+			accountService:      &testAccountService{},
+			marginPositionStore: &testMarginPositionStore{getByCode1: &marginPosition{Code: "mpo-1", OwnedQuantity: 100, HoldQuantity: 100}},
+			order: &marginOrder{TradeType: TradeTypeExit, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100,
+				ExitPositionList: []ExitPosition{{PositionCode: "mpo-1", Quantity: 100}},
+				HoldPositions:    []*HoldPosition{{PositionCode: "mpo-1", HoldQuantity: 100}}},
+			modification:           &ModifyOrderRequest{OrderQuantity: 40},
+			want:                   nil,
+			wantOrderQuantity:      40,
+			wantReserveModified:    []float64{1000, 40},
+			wantPosition:           &marginPosition{Code: "mpo-1", OwnedQuantity: 100, HoldQuantity: 40},
+			wantModificationsCount: 1},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := &marginService{
+				clock:               &clock{},
+				validatorComponent:  test.validatorComponent,
+				accountService:      test.accountService,
+				marginPositionStore: test.marginPositionStore,
+				stockSymbolStore:    &testStockSymbolStore{getByCode2: NoDataError},
+			}
+			got := service.modify(test.order, test.modification, nil, now)
+			if !errors.Is(got, test.want) ||
+				!reflect.DeepEqual(test.wantOrderQuantity, test.order.OrderQuantity) ||
+				!reflect.DeepEqual(test.wantReserveModified, test.accountService.reserveModifiedHistory) ||
+				!reflect.DeepEqual(test.wantPosition, test.marginPositionStore.getByCode1) ||
+				!reflect.DeepEqual(test.wantModificationsCount, len(test.order.Modifications)) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantOrderQuantity, test.wantReserveModified, test.wantPosition, test.wantModificationsCount,
+					got, test.order.OrderQuantity, test.accountService.reserveModifiedHistory, test.marginPositionStore.getByCode1, len(test.order.Modifications))
 			}
 		})
 	}
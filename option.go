@@ -0,0 +1,107 @@
+package virtual_security
+
+import (
+	"time"
+)
+
+// Option - 仮想証券会社の生成時に指定できるオプション
+type Option func(o *option)
+
+type option struct {
+	clock                   Clock
+	isCashManaged           bool
+	cash                    float64
+	initialMarginRate       float64
+	maintenanceMarginRate   float64
+	isMarginCallLiquidation bool
+	feeSchedules            map[ExchangeType]FeeSchedule
+	taxRate                 float64
+	marginCostRates         MarginCostRates
+	holidays                []time.Time
+	sessionSchedules        map[ExchangeType]SessionSchedule
+}
+
+func newOption() *option {
+	return &option{
+		initialMarginRate:     0.3,
+		maintenanceMarginRate: 0.2,
+	}
+}
+
+// WithClock - 現在日時として利用する時計を指定する (指定しなければ実時間を利用する)
+func WithClock(clock Clock) Option {
+	return func(o *option) {
+		o.clock = clock
+	}
+}
+
+// WithCash - 初期の預り金を指定し、注文時に買付余力のチェックをするようにする
+func WithCash(cash float64) Option {
+	return func(o *option) {
+		o.isCashManaged = true
+		o.cash = cash
+	}
+}
+
+// WithMarginRate - 信用取引の委託保証金率と最低委託保証金維持率を指定する (初期値は30%と20%)
+func WithMarginRate(initialMarginRate float64, maintenanceMarginRate float64) Option {
+	return func(o *option) {
+		o.initialMarginRate = initialMarginRate
+		o.maintenanceMarginRate = maintenanceMarginRate
+	}
+}
+
+// WithMarginCallLiquidation - 追証が発生したら信用建玉を成行で強制決済するようにする
+func WithMarginCallLiquidation() Option {
+	return func(o *option) {
+		o.isMarginCallLiquidation = true
+	}
+}
+
+// WithFeeSchedule - 市場種別ごとの手数料の計算方法を指定する
+//   指定しなかった市場種別の手数料は0で、信用は手数料の代わりに金利がかかるので通常は指定しない
+func WithFeeSchedule(exchangeType ExchangeType, schedule FeeSchedule) Option {
+	return func(o *option) {
+		if o.feeSchedules == nil {
+			o.feeSchedules = map[ExchangeType]FeeSchedule{}
+		}
+		o.feeSchedules[exchangeType] = schedule
+	}
+}
+
+// WithCapitalGainsTax - 確定損益にかかる税金を、CapitalGainsTaxRate(20.315%)で計算するようにする
+//   税金は約定履歴と営業日ごとの確定損益に記録するだけで、預り金からは引かない
+func WithCapitalGainsTax() Option {
+	return func(o *option) {
+		o.taxRate = CapitalGainsTaxRate
+	}
+}
+
+// WithMarginCostRates - 信用建玉の保有にかかる買方金利、貸株料、逆日歩、管理費の料率を指定する (指定しなければかからない)
+func WithMarginCostRates(rates MarginCostRates) Option {
+	return func(o *option) {
+		o.marginCostRates = rates
+	}
+}
+
+// WithHolidays - 祝日などの休業日を指定する (土日と年末年始は指定しなくても休業日になる)
+//   休業日は営業日の計算に使い、注文の有効期限、価格の有効期限、セッションの判定が休業日を飛ばすようになる
+func WithHolidays(holidays ...time.Time) Option {
+	return func(o *option) {
+		o.holidays = append(o.holidays, holidays...)
+	}
+}
+
+// WithSessionSchedule - 市場種別ごとの取引時間を指定する (指定しなかった市場種別は通常の取引時間になる)
+//   信用は現物と同じ取引時間を使うので、ExchangeTypeMarginを指定しても現物と信用の取引時間になる
+func WithSessionSchedule(exchangeType ExchangeType, schedule SessionSchedule) Option {
+	return func(o *option) {
+		if o.sessionSchedules == nil {
+			o.sessionSchedules = map[ExchangeType]SessionSchedule{}
+		}
+		if exchangeType == ExchangeTypeMargin {
+			exchangeType = ExchangeTypeStock
+		}
+		o.sessionSchedules[exchangeType] = schedule
+	}
+}
@@ -0,0 +1,128 @@
+package virtual_security
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_WithClock(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 8, 24, 10, 0, 0, 0, time.Local)}
+	want := &option{clock: source}
+	got := &option{}
+	WithClock(source)(got)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_newOption(t *testing.T) {
+	t.Parallel()
+	want := &option{initialMarginRate: 0.3, maintenanceMarginRate: 0.2}
+	got := newOption()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_WithCash(t *testing.T) {
+	t.Parallel()
+	want := &option{isCashManaged: true, cash: 1_000_000}
+	got := &option{}
+	WithCash(1_000_000)(got)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_WithMarginRate(t *testing.T) {
+	t.Parallel()
+	want := &option{initialMarginRate: 0.33, maintenanceMarginRate: 0.25}
+	got := &option{}
+	WithMarginRate(0.33, 0.25)(got)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_WithMarginCallLiquidation(t *testing.T) {
+	t.Parallel()
+	want := &option{isMarginCallLiquidation: true}
+	got := &option{}
+	WithMarginCallLiquidation()(got)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_WithFeeSchedule(t *testing.T) {
+	t.Parallel()
+	want := &option{feeSchedules: map[ExchangeType]FeeSchedule{ExchangeTypeStock: FlatFee(55), ExchangeTypeFuture: FlatFee(33)}}
+	got := &option{}
+	WithFeeSchedule(ExchangeTypeStock, FlatFee(55))(got)
+	WithFeeSchedule(ExchangeTypeFuture, FlatFee(33))(got)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_WithCapitalGainsTax(t *testing.T) {
+	t.Parallel()
+	want := &option{taxRate: 0.20315}
+	got := &option{}
+	WithCapitalGainsTax()(got)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_WithMarginCostRates(t *testing.T) {
+	t.Parallel()
+	rates := MarginCostRates{BuyInterestRate: 0.028, LendingFeeRate: 0.011, ShortPremiums: map[string]float64{"1234": 0.05}, AdministrationFee: 0.11, AdministrationFeeLimit: 1100}
+	want := &option{marginCostRates: rates}
+	got := &option{}
+	WithMarginCostRates(rates)(got)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_WithHolidays(t *testing.T) {
+	t.Parallel()
+	want := &option{holidays: []time.Time{time.Date(2021, 9, 20, 0, 0, 0, 0, time.Local), time.Date(2021, 9, 23, 0, 0, 0, 0, time.Local)}}
+	got := &option{}
+	WithHolidays(time.Date(2021, 9, 20, 0, 0, 0, 0, time.Local))(got)
+	WithHolidays(time.Date(2021, 9, 23, 0, 0, 0, 0, time.Local))(got)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_WithSessionSchedule(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		arg  ExchangeType
+		want *option
+	}{
+		{name: "現物を指定したら現物の取引時間になる",
+			arg:  ExchangeTypeStock,
+			want: &option{sessionSchedules: map[ExchangeType]SessionSchedule{ExchangeTypeStock: ExtendedStockSessionSchedule()}}},
+		{name: "信用を指定しても現物の取引時間になる",
+			arg:  ExchangeTypeMargin,
+			want: &option{sessionSchedules: map[ExchangeType]SessionSchedule{ExchangeTypeStock: ExtendedStockSessionSchedule()}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := &option{}
+			WithSessionSchedule(test.arg, ExtendedStockSessionSchedule())(got)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
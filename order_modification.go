@@ -0,0 +1,75 @@
+package virtual_security
+
+import "time"
+
+// applyModification - 訂正前の値に訂正を反映した値と、その訂正履歴を返す
+//   有効期限は注文時と同じく日付に丸める
+func applyModification(limitPrice float64, orderQuantity float64, expiredAt time.Time, modification *ModifyOrderRequest, now time.Time) *OrderModification {
+	res := &OrderModification{
+		ModifiedAt:        now,
+		PrevLimitPrice:    limitPrice,
+		LimitPrice:        limitPrice,
+		PrevOrderQuantity: orderQuantity,
+		OrderQuantity:     orderQuantity,
+		PrevExpiredAt:     expiredAt,
+		ExpiredAt:         expiredAt,
+	}
+	if modification == nil {
+		return res
+	}
+
+	if modification.LimitPrice > 0 {
+		res.LimitPrice = modification.LimitPrice
+	}
+	if modification.OrderQuantity > 0 {
+		res.OrderQuantity = modification.OrderQuantity
+	}
+	if !modification.ExpiredAt.IsZero() {
+		res.ExpiredAt = time.Date(modification.ExpiredAt.Year(), modification.ExpiredAt.Month(), modification.ExpiredAt.Day(), 0, 0, 0, 0, time.Local)
+	}
+	return res
+}
+
+// reduceHoldPositions - 拘束しているポジションを、後ろから返済していない数量だけ指定した数量まで減らす
+//   減らしたポジションはエグジットポジションリストからも同じだけ減らし、
+//   新しいエグジットポジションリストと、ポジションごとに減らした数量を返す
+func reduceHoldPositions(holdPositions []*HoldPosition, exitPositionList []ExitPosition, quantity float64) ([]ExitPosition, []ExitPosition) {
+	reduced := make([]ExitPosition, 0)
+	for i := len(holdPositions) - 1; i >= 0 && quantity > 0; i-- {
+		hp := holdPositions[i]
+		q := hp.HoldQuantity - hp.ExitQuantity
+		if q <= 0 {
+			continue
+		}
+		if quantity < q {
+			q = quantity
+		}
+		hp.HoldQuantity -= q
+		quantity -= q
+		reduced = append(reduced, ExitPosition{PositionCode: hp.PositionCode, Quantity: q})
+	}
+
+	if len(exitPositionList) == 0 {
+		return exitPositionList, reduced
+	}
+
+	remaining := map[string]float64{}
+	for _, r := range reduced {
+		remaining[r.PositionCode] += r.Quantity
+	}
+
+	// 注文リクエストのスライスを書き換えないように作り直す
+	list := make([]ExitPosition, 0, len(exitPositionList))
+	for _, ep := range exitPositionList {
+		q := remaining[ep.PositionCode]
+		if ep.Quantity < q {
+			q = ep.Quantity
+		}
+		ep.Quantity -= q
+		remaining[ep.PositionCode] -= q
+		if ep.Quantity > 0 {
+			list = append(list, ep)
+		}
+	}
+	return list, reduced
+}
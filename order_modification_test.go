@@ -0,0 +1,142 @@
+package virtual_security
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_applyModification(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		limitPrice    float64
+		orderQuantity float64
+		expiredAt     time.Time
+		modification  *ModifyOrderRequest
+		now           time.Time
+		want          *OrderModification
+	}{
+		{name: "訂正がnilなら訂正前と同じ値の履歴を返す",
+			limitPrice:    1000,
+			orderQuantity: 300,
+			expiredAt:     time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local),
+			modification:  nil,
+			now:           time.Date(2021, 11, 5, 10, 0, 0, 0, time.Local),
+			want: &OrderModification{
+				ModifiedAt:        time.Date(2021, 11, 5, 10, 0, 0, 0, time.Local),
+				PrevLimitPrice:    1000,
+				LimitPrice:        1000,
+				PrevOrderQuantity: 300,
+				OrderQuantity:     300,
+				PrevExpiredAt:     time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local),
+				ExpiredAt:         time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local)}},
+		{name: "指値だけを訂正する",
+			limitPrice:    1000,
+			orderQuantity: 300,
+			expiredAt:     time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local),
+			modification:  &ModifyOrderRequest{LimitPrice: 1001},
+			now:           time.Date(2021, 11, 5, 10, 0, 0, 0, time.Local),
+			want: &OrderModification{
+				ModifiedAt:        time.Date(2021, 11, 5, 10, 0, 0, 0, time.Local),
+				PrevLimitPrice:    1000,
+				LimitPrice:        1001,
+				PrevOrderQuantity: 300,
+				OrderQuantity:     300,
+				PrevExpiredAt:     time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local),
+				ExpiredAt:         time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local)}},
+		{name: "すべてを訂正し、有効期限は日付に丸める",
+			limitPrice:    1000,
+			orderQuantity: 300,
+			expiredAt:     time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local),
+			modification:  &ModifyOrderRequest{LimitPrice: 999, OrderQuantity: 200, ExpiredAt: time.Date(2021, 11, 8, 15, 0, 0, 0, time.Local)},
+			now:           time.Date(2021, 11, 5, 10, 0, 0, 0, time.Local),
+			want: &OrderModification{
+				ModifiedAt:        time.Date(2021, 11, 5, 10, 0, 0, 0, time.Local),
+				PrevLimitPrice:    1000,
+				LimitPrice:        999,
+				PrevOrderQuantity: 300,
+				OrderQuantity:     200,
+				PrevExpiredAt:     time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local),
+				ExpiredAt:         time.Date(2021, 11, 8, 0, 0, 0, 0, time.Local)}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := applyModification(test.limitPrice, test.orderQuantity, test.expiredAt, test.modification, test.now)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_reduceHoldPositions(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name              string
+		holdPositions     []*HoldPosition
+		exitPositionList  []ExitPosition
+		quantity          float64
+		want1             []ExitPosition
+		want2             []ExitPosition
+		wantHoldPositions []*HoldPosition
+	}{
+		{name: "拘束しているポジションがなければ何もしない",
+			holdPositions:     []*HoldPosition{},
+			exitPositionList:  nil,
+			quantity:          100,
+			want1:             nil,
+			want2:             []ExitPosition{},
+			wantHoldPositions: []*HoldPosition{}},
+		{name: "後ろのポジションから、返済していない数量だけ減らす",
+			holdPositions: []*HoldPosition{
+				{PositionCode: "spo-1", HoldQuantity: 100},
+				{PositionCode: "spo-2", HoldQuantity: 100, ExitQuantity: 50},
+				{PositionCode: "spo-3", HoldQuantity: 100, ExitQuantity: 100}},
+			exitPositionList: nil,
+			quantity:         80,
+			want1:            nil,
+			want2:            []ExitPosition{{PositionCode: "spo-2", Quantity: 50}, {PositionCode: "spo-1", Quantity: 30}},
+			wantHoldPositions: []*HoldPosition{
+				{PositionCode: "spo-1", HoldQuantity: 70},
+				{PositionCode: "spo-2", HoldQuantity: 50, ExitQuantity: 50},
+				{PositionCode: "spo-3", HoldQuantity: 100, ExitQuantity: 100}}},
+		{name: "エグジットポジションリストも減らし、なくなったポジションはリストから消す",
+			holdPositions: []*HoldPosition{
+				{PositionCode: "spo-1", HoldQuantity: 100},
+				{PositionCode: "spo-2", HoldQuantity: 50}},
+			exitPositionList: []ExitPosition{{PositionCode: "spo-1", Quantity: 100}, {PositionCode: "spo-2", Quantity: 50}},
+			quantity:         70,
+			want1:            []ExitPosition{{PositionCode: "spo-1", Quantity: 80}},
+			want2:            []ExitPosition{{PositionCode: "spo-2", Quantity: 50}, {PositionCode: "spo-1", Quantity: 20}},
+			wantHoldPositions: []*HoldPosition{
+				{PositionCode: "spo-1", HoldQuantity: 80},
+				{PositionCode: "spo-2", HoldQuantity: 0}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got1, got2 := reduceHoldPositions(test.holdPositions, test.exitPositionList, test.quantity)
+			if !reflect.DeepEqual(test.want1, got1) || !reflect.DeepEqual(test.want2, got2) || !reflect.DeepEqual(test.wantHoldPositions, test.holdPositions) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(), test.want1, test.want2, test.wantHoldPositions, got1, got2, test.holdPositions)
+			}
+		})
+	}
+}
+
+func Test_reduceHoldPositions_notRewriteRequest(t *testing.T) {
+	t.Parallel()
+	exitPositionList := []ExitPosition{{PositionCode: "spo-1", Quantity: 100}}
+	holdPositions := []*HoldPosition{{PositionCode: "spo-1", HoldQuantity: 100}}
+	_, _ = reduceHoldPositions(holdPositions, exitPositionList, 30)
+
+	want := []ExitPosition{{PositionCode: "spo-1", Quantity: 100}}
+	if !reflect.DeepEqual(want, exitPositionList) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, exitPositionList)
+	}
+}
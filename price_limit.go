@@ -0,0 +1,70 @@
+package virtual_security
+
+// priceLimitLevel - 制限値幅の表の1段
+type priceLimitLevel struct {
+	maxBasePrice float64 // この段の基準値段の上限 (この値段未満、0なら上限なし)
+	width        float64 // 制限値幅
+}
+
+// 東証の制限値幅
+var priceLimitLevels = []priceLimitLevel{
+	{maxBasePrice: 100, width: 30},
+	{maxBasePrice: 200, width: 50},
+	{maxBasePrice: 500, width: 80},
+	{maxBasePrice: 700, width: 100},
+	{maxBasePrice: 1_000, width: 150},
+	{maxBasePrice: 1_500, width: 300},
+	{maxBasePrice: 2_000, width: 400},
+	{maxBasePrice: 3_000, width: 500},
+	{maxBasePrice: 5_000, width: 700},
+	{maxBasePrice: 7_000, width: 1_000},
+	{maxBasePrice: 10_000, width: 1_500},
+	{maxBasePrice: 15_000, width: 3_000},
+	{maxBasePrice: 20_000, width: 4_000},
+	{maxBasePrice: 30_000, width: 5_000},
+	{maxBasePrice: 50_000, width: 7_000},
+	{maxBasePrice: 70_000, width: 10_000},
+	{maxBasePrice: 100_000, width: 15_000},
+	{maxBasePrice: 150_000, width: 30_000},
+	{maxBasePrice: 200_000, width: 40_000},
+	{maxBasePrice: 300_000, width: 50_000},
+	{maxBasePrice: 500_000, width: 70_000},
+	{maxBasePrice: 700_000, width: 100_000},
+	{maxBasePrice: 1_000_000, width: 150_000},
+	{maxBasePrice: 1_500_000, width: 300_000},
+	{maxBasePrice: 2_000_000, width: 400_000},
+	{maxBasePrice: 3_000_000, width: 500_000},
+	{maxBasePrice: 5_000_000, width: 700_000},
+	{maxBasePrice: 7_000_000, width: 1_000_000},
+	{maxBasePrice: 10_000_000, width: 1_500_000},
+	{maxBasePrice: 15_000_000, width: 3_000_000},
+	{maxBasePrice: 20_000_000, width: 4_000_000},
+	{maxBasePrice: 30_000_000, width: 5_000_000},
+	{maxBasePrice: 50_000_000, width: 7_000_000},
+	{width: 10_000_000},
+}
+
+// priceLimitWidth - 基準値段に対する制限値幅
+func priceLimitWidth(basePrice float64) float64 {
+	for _, l := range priceLimitLevels {
+		if l.maxBasePrice <= 0 || basePrice < l.maxBasePrice {
+			return l.width
+		}
+	}
+	return priceLimitLevels[len(priceLimitLevels)-1].width
+}
+
+// priceLimits - 基準値段に対する値幅制限の下限(ストップ安)と上限(ストップ高)
+//   基準値段がなければ値幅制限はないので0を返し、下限は1円より安くしない
+func priceLimits(basePrice float64) (float64, float64) {
+	if basePrice <= 0 {
+		return 0, 0
+	}
+
+	width := priceLimitWidth(basePrice)
+	lower := basePrice - width
+	if lower < 1 {
+		lower = 1
+	}
+	return lower, basePrice + width
+}
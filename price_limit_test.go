@@ -0,0 +1,58 @@
+package virtual_security
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_priceLimitWidth(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		arg  float64
+		want float64
+	}{
+		{name: "100円未満は30円", arg: 99, want: 30},
+		{name: "100円は50円", arg: 100, want: 50},
+		{name: "1,000円未満は150円", arg: 999, want: 150},
+		{name: "1,000円は300円", arg: 1_000, want: 300},
+		{name: "3,000円以上5,000円未満は700円", arg: 4_000, want: 700},
+		{name: "50,000,000円以上は10,000,000円", arg: 60_000_000, want: 10_000_000},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := priceLimitWidth(test.arg)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_priceLimits(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		arg       float64
+		wantLower float64
+		wantUpper float64
+	}{
+		{name: "基準値段がなければ値幅制限はない", arg: 0, wantLower: 0, wantUpper: 0},
+		{name: "基準値段から制限値幅の上下が値幅制限", arg: 1_000, wantLower: 700, wantUpper: 1_300},
+		{name: "下限は1円より安くならない", arg: 20, wantLower: 1, wantUpper: 50},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			gotLower, gotUpper := priceLimits(test.arg)
+			if test.wantLower != gotLower || test.wantUpper != gotUpper {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.wantLower, test.wantUpper, gotLower, gotUpper)
+			}
+		})
+	}
+}
@@ -1,9 +1,10 @@
 package virtual_security
 
-func newPriceService(clock iClock, priceStore iPriceStore) iPriceService {
+func newPriceService(clock iClock, priceStore iPriceStore, schedule *tradingSchedule) iPriceService {
 	return &priceService{
 		clock:      clock,
 		priceStore: priceStore,
+		schedule:   schedule,
 	}
 }
 
@@ -12,11 +13,14 @@ type iPriceService interface {
 	set(price *symbolPrice) error
 	validation(price RegisterPriceRequest) error
 	toSymbolPrice(symbolPrice RegisterPriceRequest) (*symbolPrice, error)
+	getAll() []*symbolPrice
+	restore(prices []*symbolPrice)
 }
 
 type priceService struct {
 	clock      iClock
 	priceStore iPriceStore
+	schedule   *tradingSchedule // 取引時間 (nilなら通常の取引時間)
 }
 
 func (s *priceService) getBySymbolCode(code string) (*symbolPrice, error) {
@@ -27,6 +31,14 @@ func (s *priceService) set(price *symbolPrice) error {
 	return s.priceStore.set(price)
 }
 
+func (s *priceService) getAll() []*symbolPrice {
+	return s.priceStore.getAll()
+}
+
+func (s *priceService) restore(prices []*symbolPrice) {
+	s.priceStore.restore(prices)
+}
+
 func (s *priceService) validation(price RegisterPriceRequest) error {
 	// ExchangeType が想定外ならエラー
 	if price.ExchangeType == ExchangeTypeUnspecified {
@@ -43,6 +55,23 @@ func (s *priceService) validation(price RegisterPriceRequest) error {
 		return InvalidTimeError
 	}
 
+	// 出来高や気配数量が負ならエラー
+	if price.Volume < 0 || price.BidQuantity < 0 || price.AskQuantity < 0 {
+		return InvalidQuantityError
+	}
+
+	// 板の気配値は正、気配数量は0以上で、良い順に並んでいなければエラー
+	for i, l := range price.BidBoard {
+		if l.Price <= 0 || l.Quantity < 0 || (i > 0 && price.BidBoard[i-1].Price <= l.Price) {
+			return InvalidBoardError
+		}
+	}
+	for i, l := range price.AskBoard {
+		if l.Price <= 0 || l.Quantity < 0 || (i > 0 && price.AskBoard[i-1].Price >= l.Price) {
+			return InvalidBoardError
+		}
+	}
+
 	return nil
 }
 
@@ -56,12 +85,26 @@ func (s *priceService) toSymbolPrice(price RegisterPriceRequest) (*symbolPrice,
 		BidTime:          price.BidTime,
 		Ask:              price.Ask,
 		AskTime:          price.AskTime,
+		Volume:           price.Volume,
+		BidQuantity:      price.BidQuantity,
+		AskQuantity:      price.AskQuantity,
+		BidBoard:         price.BidBoard,
+		AskBoard:         price.AskBoard,
 		session:          s.clock.getSession(price.ExchangeType, price.PriceTime),
 		priceBusinessDay: s.clock.getBusinessDay(price.ExchangeType, price.PriceTime),
 	}
 
+	// 最良気配がなく板があれば、板の最良気配を最良気配にする
+	if res.Bid <= 0 && len(res.BidBoard) > 0 {
+		res.Bid, res.BidQuantity = res.BidBoard[0].Price, res.BidBoard[0].Quantity
+	}
+	if res.Ask <= 0 && len(res.AskBoard) > 0 {
+		res.Ask, res.AskQuantity = res.AskBoard[0].Price, res.AskBoard[0].Quantity
+	}
+
+	// 前回の価格が期限切れなら、前回の価格がないものとして扱う
 	prevPrice, err := s.priceStore.getBySymbolCode(price.SymbolCode)
-	if err != nil && err != NoDataError {
+	if err != nil && err != NoDataError && err != ExpiredDataError {
 		return nil, err
 	}
 
@@ -71,10 +114,9 @@ func (s *priceService) toSymbolPrice(price RegisterPriceRequest) (*symbolPrice,
 		kind = PriceKindOpening
 	}
 
-	switch res.ExchangeType {
-	case ExchangeTypeStock, ExchangeTypeMargin:
+	if p := s.schedule.sessionAt(res.ExchangeType, res.PriceTime); p != nil {
 		switch {
-		case contractableMorningSessionCloseTime.between(res.PriceTime) || contractableAfternoonSessionCloseTime.between(res.PriceTime):
+		case p.isClosing(res.PriceTime):
 			// その日・そのセッションの引け後の価格は終値
 			if kind == PriceKindOpening {
 				kind = PriceKindOpeningAndClosing
@@ -82,18 +124,32 @@ func (s *priceService) toSymbolPrice(price RegisterPriceRequest) (*symbolPrice,
 				kind = PriceKindClosing
 			}
 
-		case contractableStockPriceTime.between(res.PriceTime):
+		case p.isRegular(res.PriceTime):
 			if kind != PriceKindOpening {
 				// その日・そのセッションのザラバ中の価格は通常値
 				kind = PriceKindRegular
 			}
 		}
-	case ExchangeTypeFuture:
-		// その日・そのセッションの引け後の価格は終値
-		// その日・そのセッションのザラバ中の価格は通常値
-		// TODO 先物の種別
 	}
 	res.kind = kind
 
+	// 寄付や引けなら、気配から板寄せの約定値段を求める
+	//   約定値段が同じ数量で並んだときは、前回の価格に近い値段を選ぶ
+	if res.isItayose() {
+		basePrice := res.Price
+		if prevPrice != nil && prevPrice.Price > 0 {
+			basePrice = prevPrice.Price
+		}
+		res.itayosePrice, res.itayoseQuantity = itayose(s.itayoseBoard(res.BidBoard, res.Bid, res.BidQuantity), s.itayoseBoard(res.AskBoard, res.Ask, res.AskQuantity), basePrice)
+	}
+
 	return res, nil
 }
+
+// itayoseBoard - 板寄せに使う板 (板がなければ最良気配だけの板)
+func (s *priceService) itayoseBoard(board []BoardLevel, price float64, quantity float64) []BoardLevel {
+	if len(board) == 0 && price > 0 {
+		return []BoardLevel{{Price: price, Quantity: quantity}}
+	}
+	return board
+}
@@ -8,6 +8,8 @@ import (
 )
 
 type testPriceService struct {
+	getAll1          []*symbolPrice
+	restoreHistory   [][]*symbolPrice
 	getBySymbolCode1 *symbolPrice
 	getBySymbolCode2 error
 	set1             error
@@ -20,6 +22,10 @@ func (t *testPriceService) getBySymbolCode(string) (*symbolPrice, error) {
 	return t.getBySymbolCode1, t.getBySymbolCode2
 }
 func (t *testPriceService) set(*symbolPrice) error { return t.set1 }
+func (t *testPriceService) getAll() []*symbolPrice { return t.getAll1 }
+func (t *testPriceService) restore(prices []*symbolPrice) {
+	t.restoreHistory = append(t.restoreHistory, prices)
+}
 
 func (t *testPriceService) validation(RegisterPriceRequest) error { return t.validation1 }
 func (t *testPriceService) toSymbolPrice(RegisterPriceRequest) (*symbolPrice, error) {
@@ -36,6 +42,28 @@ func Test_priceService_validation(t *testing.T) {
 		{name: "ExchangeTypeが不明ならエラー", arg: RegisterPriceRequest{ExchangeType: ExchangeTypeUnspecified}, want: InvalidExchangeTypeError},
 		{name: "銘柄コードが不明ならエラー", arg: RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: ""}, want: InvalidSymbolCodeError},
 		{name: "いずれの時刻もなかったらエラー", arg: RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234"}, want: InvalidTimeError},
+		{name: "出来高が負ならエラー",
+			arg:  RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", PriceTime: time.Date(2021, 6, 30, 10, 0, 0, 0, time.Local), Volume: -1},
+			want: InvalidQuantityError},
+		{name: "気配数量が負ならエラー",
+			arg:  RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", PriceTime: time.Date(2021, 6, 30, 10, 0, 0, 0, time.Local), AskQuantity: -1},
+			want: InvalidQuantityError},
+		{name: "板の気配値が0以下ならエラー",
+			arg:  RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", PriceTime: time.Date(2021, 6, 30, 10, 0, 0, 0, time.Local), BidBoard: []BoardLevel{{Price: 0, Quantity: 100}}},
+			want: InvalidBoardError},
+		{name: "板の気配数量が負ならエラー",
+			arg:  RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", PriceTime: time.Date(2021, 6, 30, 10, 0, 0, 0, time.Local), AskBoard: []BoardLevel{{Price: 1000, Quantity: -1}}},
+			want: InvalidBoardError},
+		{name: "買い板が高い順に並んでいなければエラー",
+			arg:  RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", PriceTime: time.Date(2021, 6, 30, 10, 0, 0, 0, time.Local), BidBoard: []BoardLevel{{Price: 999, Quantity: 100}, {Price: 1000, Quantity: 100}}},
+			want: InvalidBoardError},
+		{name: "売り板が安い順に並んでいなければエラー",
+			arg:  RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", PriceTime: time.Date(2021, 6, 30, 10, 0, 0, 0, time.Local), AskBoard: []BoardLevel{{Price: 1001, Quantity: 100}, {Price: 1001, Quantity: 100}}},
+			want: InvalidBoardError},
+		{name: "板が良い順に並んでいればnil",
+			arg: RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", PriceTime: time.Date(2021, 6, 30, 10, 0, 0, 0, time.Local),
+				BidBoard: []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 999}}, AskBoard: []BoardLevel{{Price: 1001, Quantity: 100}, {Price: 1002}}},
+			want: nil},
 		{name: "上記をパスしていればnil",
 			arg:  RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", PriceTime: time.Date(2021, 6, 30, 10, 0, 0, 0, time.Local)},
 			want: nil},
@@ -56,8 +84,10 @@ func Test_priceService_validation(t *testing.T) {
 
 func Test_priceService_toSymbolPrice(t *testing.T) {
 	t.Parallel()
+	extended := newTradingSchedule(map[ExchangeType]SessionSchedule{ExchangeTypeStock: ExtendedStockSessionSchedule()})
 	tests := []struct {
 		name       string
+		schedule   *tradingSchedule
 		clock      *testClock
 		priceStore *testPriceStore
 		arg        RegisterPriceRequest
@@ -113,6 +143,27 @@ func Test_priceService_toSymbolPrice(t *testing.T) {
 				session:          SessionMorning,
 				priceBusinessDay: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local),
 			}},
+		{name: "前回の価格が期限切れなら寄りになる",
+			clock: &testClock{
+				getSession1:     SessionMorning,
+				getBusinessDay1: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local),
+			},
+			priceStore: &testPriceStore{getBySymbolCode2: ExpiredDataError},
+			arg: RegisterPriceRequest{
+				ExchangeType: ExchangeTypeStock,
+				SymbolCode:   "1234",
+				Price:        1000,
+				PriceTime:    time.Date(2021, 6, 30, 10, 0, 0, 0, time.Local),
+			},
+			want1: &symbolPrice{
+				ExchangeType:     ExchangeTypeStock,
+				SymbolCode:       "1234",
+				Price:            1000,
+				PriceTime:        time.Date(2021, 6, 30, 10, 0, 0, 0, time.Local),
+				kind:             PriceKindOpening,
+				session:          SessionMorning,
+				priceBusinessDay: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local),
+			}},
 		{name: "前回の価格と営業日が違えば寄り付きになる",
 			clock: &testClock{
 				getSession1:     SessionMorning,
@@ -330,13 +381,263 @@ func Test_priceService_toSymbolPrice(t *testing.T) {
 				session:          SessionAfternoon,
 				priceBusinessDay: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local),
 			}},
+		{name: "気配値がなければ板の最良気配を気配値と気配数量にし、気配値があればそのまま使う",
+			clock: &testClock{
+				getSession1:     SessionMorning,
+				getBusinessDay1: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local),
+			},
+			priceStore: &testPriceStore{},
+			arg: RegisterPriceRequest{
+				ExchangeType: ExchangeTypeStock,
+				SymbolCode:   "1234",
+				Price:        1000,
+				PriceTime:    time.Date(2021, 6, 30, 10, 0, 0, 0, time.Local),
+				Bid:          999,
+				BidQuantity:  300,
+				BidBoard:     []BoardLevel{{Price: 1000, Quantity: 100}},
+				AskBoard:     []BoardLevel{{Price: 1001, Quantity: 200}, {Price: 1002, Quantity: 400}},
+			},
+			want1: &symbolPrice{
+				ExchangeType:     ExchangeTypeStock,
+				SymbolCode:       "1234",
+				Price:            1000,
+				PriceTime:        time.Date(2021, 6, 30, 10, 0, 0, 0, time.Local),
+				Bid:              999,
+				BidQuantity:      300,
+				Ask:              1001,
+				AskQuantity:      200,
+				BidBoard:         []BoardLevel{{Price: 1000, Quantity: 100}},
+				AskBoard:         []BoardLevel{{Price: 1001, Quantity: 200}, {Price: 1002, Quantity: 400}},
+				kind:             PriceKindOpening,
+				session:          SessionMorning,
+				priceBusinessDay: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local),
+			}},
+		{name: "先物で同じセッションの日中の引けの価格なら終値になる",
+			clock: &testClock{
+				getSession1:     SessionDay,
+				getBusinessDay1: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local),
+			},
+			priceStore: &testPriceStore{getBySymbolCode1: &symbolPrice{
+				session:          SessionDay,
+				priceBusinessDay: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local),
+			}},
+			arg: RegisterPriceRequest{
+				ExchangeType: ExchangeTypeFuture,
+				SymbolCode:   "167060019",
+				Price:        29000,
+				PriceTime:    time.Date(2021, 6, 30, 15, 45, 0, 0, time.Local),
+			},
+			want1: &symbolPrice{
+				ExchangeType:     ExchangeTypeFuture,
+				SymbolCode:       "167060019",
+				Price:            29000,
+				PriceTime:        time.Date(2021, 6, 30, 15, 45, 0, 0, time.Local),
+				kind:             PriceKindClosing,
+				session:          SessionDay,
+				priceBusinessDay: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local),
+			}},
+		{name: "先物で同じセッションの夜間のザラバの価格なら通常値になる",
+			clock: &testClock{
+				getSession1:     SessionNight,
+				getBusinessDay1: time.Date(2021, 7, 1, 0, 0, 0, 0, time.Local),
+			},
+			priceStore: &testPriceStore{getBySymbolCode1: &symbolPrice{
+				session:          SessionNight,
+				priceBusinessDay: time.Date(2021, 7, 1, 0, 0, 0, 0, time.Local),
+			}},
+			arg: RegisterPriceRequest{
+				ExchangeType: ExchangeTypeFuture,
+				SymbolCode:   "167060019",
+				Price:        29000,
+				PriceTime:    time.Date(2021, 6, 30, 20, 0, 0, 0, time.Local),
+			},
+			want1: &symbolPrice{
+				ExchangeType:     ExchangeTypeFuture,
+				SymbolCode:       "167060019",
+				Price:            29000,
+				PriceTime:        time.Date(2021, 6, 30, 20, 0, 0, 0, time.Local),
+				kind:             PriceKindRegular,
+				session:          SessionNight,
+				priceBusinessDay: time.Date(2021, 7, 1, 0, 0, 0, 0, time.Local),
+			}},
+		{name: "延長した取引時間で15:10なら通常になる",
+			schedule: extended,
+			clock: &testClock{
+				getSession1:     SessionAfternoon,
+				getBusinessDay1: time.Date(2024, 11, 5, 0, 0, 0, 0, time.Local),
+			},
+			priceStore: &testPriceStore{getBySymbolCode1: &symbolPrice{
+				priceBusinessDay: time.Date(2024, 11, 5, 0, 0, 0, 0, time.Local),
+				session:          SessionAfternoon,
+			}},
+			arg: RegisterPriceRequest{
+				ExchangeType: ExchangeTypeStock,
+				SymbolCode:   "1234",
+				Price:        1000,
+				PriceTime:    time.Date(2024, 11, 5, 15, 10, 0, 0, time.Local),
+			},
+			want1: &symbolPrice{
+				ExchangeType:     ExchangeTypeStock,
+				SymbolCode:       "1234",
+				Price:            1000,
+				PriceTime:        time.Date(2024, 11, 5, 15, 10, 0, 0, time.Local),
+				kind:             PriceKindRegular,
+				session:          SessionAfternoon,
+				priceBusinessDay: time.Date(2024, 11, 5, 0, 0, 0, 0, time.Local),
+			}},
+		{name: "延長した取引時間でクロージング・オークション中なら種別はない",
+			schedule: extended,
+			clock: &testClock{
+				getSession1:     SessionAfternoon,
+				getBusinessDay1: time.Date(2024, 11, 5, 0, 0, 0, 0, time.Local),
+			},
+			priceStore: &testPriceStore{getBySymbolCode1: &symbolPrice{
+				priceBusinessDay: time.Date(2024, 11, 5, 0, 0, 0, 0, time.Local),
+				session:          SessionAfternoon,
+			}},
+			arg: RegisterPriceRequest{
+				ExchangeType: ExchangeTypeStock,
+				SymbolCode:   "1234",
+				Price:        1000,
+				PriceTime:    time.Date(2024, 11, 5, 15, 27, 0, 0, time.Local),
+			},
+			want1: &symbolPrice{
+				ExchangeType:     ExchangeTypeStock,
+				SymbolCode:       "1234",
+				Price:            1000,
+				PriceTime:        time.Date(2024, 11, 5, 15, 27, 0, 0, time.Local),
+				kind:             PriceKindUnspecified,
+				session:          SessionAfternoon,
+				priceBusinessDay: time.Date(2024, 11, 5, 0, 0, 0, 0, time.Local),
+			}},
+		{name: "延長した取引時間で15:30なら引けになる",
+			schedule: extended,
+			clock: &testClock{
+				getSession1:     SessionAfternoon,
+				getBusinessDay1: time.Date(2024, 11, 5, 0, 0, 0, 0, time.Local),
+			},
+			priceStore: &testPriceStore{getBySymbolCode1: &symbolPrice{
+				priceBusinessDay: time.Date(2024, 11, 5, 0, 0, 0, 0, time.Local),
+				session:          SessionAfternoon,
+			}},
+			arg: RegisterPriceRequest{
+				ExchangeType: ExchangeTypeStock,
+				SymbolCode:   "1234",
+				Price:        1000,
+				PriceTime:    time.Date(2024, 11, 5, 15, 30, 0, 0, time.Local),
+			},
+			want1: &symbolPrice{
+				ExchangeType:     ExchangeTypeStock,
+				SymbolCode:       "1234",
+				Price:            1000,
+				PriceTime:        time.Date(2024, 11, 5, 15, 30, 0, 0, time.Local),
+				kind:             PriceKindClosing,
+				session:          SessionAfternoon,
+				priceBusinessDay: time.Date(2024, 11, 5, 0, 0, 0, 0, time.Local),
+			}},
+		{name: "寄りで板が交差していれば、前回の価格を基準に板寄せの約定値段を求める",
+			clock: &testClock{
+				getSession1:     SessionMorning,
+				getBusinessDay1: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local),
+			},
+			priceStore: &testPriceStore{getBySymbolCode1: &symbolPrice{
+				Price:            1008,
+				priceBusinessDay: time.Date(2021, 6, 29, 0, 0, 0, 0, time.Local),
+			}},
+			arg: RegisterPriceRequest{
+				ExchangeType: ExchangeTypeStock,
+				SymbolCode:   "1234",
+				PriceTime:    time.Date(2021, 6, 30, 9, 0, 0, 0, time.Local),
+				BidBoard:     []BoardLevel{{Price: 1010, Quantity: 200}},
+				AskBoard:     []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 1005, Quantity: 100}},
+			},
+			want1: &symbolPrice{
+				ExchangeType:     ExchangeTypeStock,
+				SymbolCode:       "1234",
+				PriceTime:        time.Date(2021, 6, 30, 9, 0, 0, 0, time.Local),
+				Bid:              1010,
+				BidQuantity:      200,
+				Ask:              1000,
+				AskQuantity:      100,
+				BidBoard:         []BoardLevel{{Price: 1010, Quantity: 200}},
+				AskBoard:         []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 1005, Quantity: 100}},
+				kind:             PriceKindOpening,
+				session:          SessionMorning,
+				priceBusinessDay: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local),
+				itayosePrice:     1010,
+				itayoseQuantity:  200,
+			}},
+		{name: "寄りで板がなければ、最良気配と現値から板寄せの約定値段を求める",
+			clock: &testClock{
+				getSession1:     SessionMorning,
+				getBusinessDay1: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local),
+			},
+			priceStore: &testPriceStore{},
+			arg: RegisterPriceRequest{
+				ExchangeType: ExchangeTypeStock,
+				SymbolCode:   "1234",
+				Price:        1000,
+				PriceTime:    time.Date(2021, 6, 30, 9, 0, 0, 0, time.Local),
+				Bid:          1010,
+				BidQuantity:  200,
+				Ask:          1000,
+				AskQuantity:  100,
+			},
+			want1: &symbolPrice{
+				ExchangeType:     ExchangeTypeStock,
+				SymbolCode:       "1234",
+				Price:            1000,
+				PriceTime:        time.Date(2021, 6, 30, 9, 0, 0, 0, time.Local),
+				Bid:              1010,
+				BidQuantity:      200,
+				Ask:              1000,
+				AskQuantity:      100,
+				kind:             PriceKindOpening,
+				session:          SessionMorning,
+				priceBusinessDay: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local),
+				itayosePrice:     1000,
+				itayoseQuantity:  100,
+			}},
+		{name: "ザラバなら板が交差していても板寄せしない",
+			clock: &testClock{
+				getSession1:     SessionMorning,
+				getBusinessDay1: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local),
+			},
+			priceStore: &testPriceStore{getBySymbolCode1: &symbolPrice{
+				Price:            1000,
+				session:          SessionMorning,
+				priceBusinessDay: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local),
+			}},
+			arg: RegisterPriceRequest{
+				ExchangeType: ExchangeTypeStock,
+				SymbolCode:   "1234",
+				Price:        1000,
+				PriceTime:    time.Date(2021, 6, 30, 10, 0, 0, 0, time.Local),
+				Bid:          1010,
+				BidQuantity:  200,
+				Ask:          1000,
+				AskQuantity:  100,
+			},
+			want1: &symbolPrice{
+				ExchangeType:     ExchangeTypeStock,
+				SymbolCode:       "1234",
+				Price:            1000,
+				PriceTime:        time.Date(2021, 6, 30, 10, 0, 0, 0, time.Local),
+				Bid:              1010,
+				BidQuantity:      200,
+				Ask:              1000,
+				AskQuantity:      100,
+				kind:             PriceKindRegular,
+				session:          SessionMorning,
+				priceBusinessDay: time.Date(2021, 6, 30, 0, 0, 0, 0, time.Local),
+			}},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			service := &priceService{clock: test.clock, priceStore: test.priceStore}
+			service := &priceService{clock: test.clock, priceStore: test.priceStore, schedule: test.schedule}
 			got1, got2 := service.toSymbolPrice(test.arg)
 			if !reflect.DeepEqual(test.want1, got1) || !errors.Is(got2, test.want2) {
 				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want1, test.want2, got1, got2)
@@ -413,8 +714,9 @@ func Test_newPriceService(t *testing.T) {
 	want := &priceService{
 		clock:      clock,
 		priceStore: priceStore,
+		schedule:   defaultTradingSchedule,
 	}
-	got := newPriceService(clock, priceStore)
+	got := newPriceService(clock, priceStore, defaultTradingSchedule)
 
 	if !reflect.DeepEqual(want, got) {
 		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
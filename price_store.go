@@ -1,6 +1,7 @@
 package virtual_security
 
 import (
+	"sort"
 	"sync"
 	"time"
 )
@@ -16,20 +17,27 @@ func getPriceStore(clock iClock) iPriceStore {
 	defer priceStoreSingletonMutex.Unlock()
 
 	if priceStoreSingleton == nil {
-		store := &priceStore{
-			store: map[string]*symbolPrice{},
-			clock: clock,
-		}
-		store.setCalculatedExpireTime(clock.now())
-		priceStoreSingleton = store
+		priceStoreSingleton = newPriceStore(clock)
 	}
 	return priceStoreSingleton
 }
 
+// newPriceStore - 価格ストアの生成
+func newPriceStore(clock iClock) iPriceStore {
+	store := &priceStore{
+		store: map[string]*symbolPrice{},
+		clock: clock,
+	}
+	store.setCalculatedExpireTime(clock.now())
+	return store
+}
+
 // iPriceStore - 価格ストアのインターフェース
 type iPriceStore interface {
+	getAll() []*symbolPrice
 	getBySymbolCode(symbolCode string) (*symbolPrice, error)
 	set(price *symbolPrice) error
+	restore(prices []*symbolPrice)
 }
 
 // priceStore - 価格ストア
@@ -44,11 +52,33 @@ func (s *priceStore) isExpired(now time.Time) bool {
 	return !s.expireTime.After(now)
 }
 
+// setCalculatedExpireTime - 価格の有効期限を、次の営業日の8時にする
+//   休業日の間は価格を入れ替えないので、休業日の前の価格は次の営業日の8時まで有効
 func (s *priceStore) setCalculatedExpireTime(now time.Time) {
-	s.expireTime = time.Date(now.Year(), now.Month(), now.Day(), 8, 0, 0, 0, time.Local)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
 	if now.Hour() >= 8 {
-		s.expireTime = s.expireTime.AddDate(0, 0, 1)
+		day = day.AddDate(0, 0, 1)
+	}
+	day = s.clock.addBusinessDays(day, 0)
+	s.expireTime = time.Date(day.Year(), day.Month(), day.Day(), 8, 0, 0, 0, time.Local)
+}
+
+// getAll - 有効期限が切れていない価格を銘柄コード順に並べて返す
+func (s *priceStore) getAll() []*symbolPrice {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	prices := make([]*symbolPrice, 0, len(s.store))
+	if s.isExpired(s.clock.now()) {
+		return prices
 	}
+	for _, price := range s.store {
+		prices = append(prices, price)
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].SymbolCode < prices[j].SymbolCode
+	})
+	return prices
 }
 
 // GetBySymbolCode - ストアから指定した銘柄コードの価格を取り出す
@@ -89,3 +119,26 @@ func (s *priceStore) set(price *symbolPrice) error {
 	s.store[price.SymbolCode] = price
 	return nil
 }
+
+// restore - ストアの価格を入れ替える
+//   有効期限は、入れ替えた価格のうち最も新しい日時の次の8時まで
+func (s *priceStore) restore(prices []*symbolPrice) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.store = map[string]*symbolPrice{}
+	var latest time.Time
+	for _, price := range prices {
+		if price == nil || price.maxTime().IsZero() {
+			continue
+		}
+		s.store[price.SymbolCode] = price
+		if latest.Before(price.maxTime()) {
+			latest = price.maxTime()
+		}
+	}
+	if latest.IsZero() {
+		latest = s.clock.now()
+	}
+	s.setCalculatedExpireTime(latest)
+}
@@ -8,11 +8,21 @@ import (
 )
 
 type testPriceStore struct {
+	getAll1                []*symbolPrice
 	getBySymbolCode1       *symbolPrice
 	getBySymbolCode2       error
 	getBySymbolCodeHistory []string
 	set1                   error
 	setHistory             []*symbolPrice
+	restoreHistory         [][]*symbolPrice
+}
+
+func (t *testPriceStore) getAll() []*symbolPrice {
+	return t.getAll1
+}
+
+func (t *testPriceStore) restore(prices []*symbolPrice) {
+	t.restoreHistory = append(t.restoreHistory, prices)
 }
 
 func (t *testPriceStore) getBySymbolCode(symbolCode string) (*symbolPrice, error) {
@@ -26,12 +36,27 @@ func (t *testPriceStore) set(price *symbolPrice) error {
 }
 
 func Test_getPriceStore(t *testing.T) {
-	clock := &testClock{now1: time.Date(2021, 5, 22, 7, 11, 0, 0, time.Local)}
+	clock := &testClock{now1: time.Date(2021, 5, 22, 7, 11, 0, 0, time.Local), addBusinessDays1: time.Date(2021, 5, 24, 0, 0, 0, 0, time.Local)}
 	got := getPriceStore(clock)
 	want := &priceStore{
 		store:      map[string]*symbolPrice{},
 		clock:      clock,
-		expireTime: time.Date(2021, 5, 22, 8, 0, 0, 0, time.Local),
+		expireTime: time.Date(2021, 5, 24, 8, 0, 0, 0, time.Local),
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_newPriceStore(t *testing.T) {
+	t.Parallel()
+	clock := &testClock{now1: time.Date(2021, 5, 22, 9, 11, 0, 0, time.Local), addBusinessDays1: time.Date(2021, 5, 24, 0, 0, 0, 0, time.Local)}
+	got := newPriceStore(clock)
+	want := &priceStore{
+		store:      map[string]*symbolPrice{},
+		clock:      clock,
+		expireTime: time.Date(2021, 5, 24, 8, 0, 0, 0, time.Local),
 	}
 
 	if !reflect.DeepEqual(want, got) {
@@ -76,31 +101,39 @@ func Test_priceStore_isExpired(t *testing.T) {
 func Test_priceStore_setCalculatedExpireTime(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
-		name       string
-		priceStore *priceStore
-		arg        time.Time
-		want       time.Time
+		name     string
+		holidays []time.Time
+		arg      time.Time
+		want     time.Time
 	}{
 		{name: "現在時刻が8時以前なら当日の8時をセット",
-			priceStore: &priceStore{},
-			arg:        time.Date(2021, 5, 22, 7, 0, 0, 0, time.Local),
-			want:       time.Date(2021, 5, 22, 8, 0, 0, 0, time.Local)},
+			arg:  time.Date(2021, 5, 20, 7, 0, 0, 0, time.Local),
+			want: time.Date(2021, 5, 20, 8, 0, 0, 0, time.Local)},
 		{name: "現在時刻が8時なら翌日の8時をセット",
-			priceStore: &priceStore{},
-			arg:        time.Date(2021, 5, 22, 8, 0, 0, 0, time.Local),
-			want:       time.Date(2021, 5, 23, 8, 0, 0, 0, time.Local)},
+			arg:  time.Date(2021, 5, 20, 8, 0, 0, 0, time.Local),
+			want: time.Date(2021, 5, 21, 8, 0, 0, 0, time.Local)},
 		{name: "現在時刻が8時以降なら翌日の8時をセット",
-			priceStore: &priceStore{},
-			arg:        time.Date(2021, 5, 22, 9, 0, 0, 0, time.Local),
-			want:       time.Date(2021, 5, 23, 8, 0, 0, 0, time.Local)},
+			arg:  time.Date(2021, 5, 20, 9, 0, 0, 0, time.Local),
+			want: time.Date(2021, 5, 21, 8, 0, 0, 0, time.Local)},
+		{name: "金曜日の8時以降なら翌週月曜日の8時をセット",
+			arg:  time.Date(2021, 5, 21, 9, 0, 0, 0, time.Local),
+			want: time.Date(2021, 5, 24, 8, 0, 0, 0, time.Local)},
+		{name: "土曜日なら8時より前でも翌週月曜日の8時をセット",
+			arg:  time.Date(2021, 5, 22, 7, 0, 0, 0, time.Local),
+			want: time.Date(2021, 5, 24, 8, 0, 0, 0, time.Local)},
+		{name: "翌日が祝日なら祝日明けの8時をセット",
+			holidays: []time.Time{time.Date(2021, 7, 22, 0, 0, 0, 0, time.Local), time.Date(2021, 7, 23, 0, 0, 0, 0, time.Local)},
+			arg:      time.Date(2021, 7, 21, 9, 0, 0, 0, time.Local),
+			want:     time.Date(2021, 7, 26, 8, 0, 0, 0, time.Local)},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			test.priceStore.setCalculatedExpireTime(test.arg)
-			got := test.priceStore.expireTime
+			store := &priceStore{clock: &clock{calendar: newCalendar(test.holidays)}}
+			store.setCalculatedExpireTime(test.arg)
+			got := store.expireTime
 			if !reflect.DeepEqual(test.want, got) {
 				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
 			}
@@ -185,7 +218,7 @@ func Test_priceStore_Set(t *testing.T) {
 			wantExpireTime: time.Date(2021, 5, 26, 8, 0, 0, 0, time.Local)},
 		{name: "有効期限が切れていれば、storeをクリアし、有効期限を延長してから、storeに追加する",
 			priceStore: &priceStore{
-				clock:      &testClock{now1: time.Date(2021, 5, 25, 9, 0, 0, 0, time.Local)},
+				clock:      &testClock{now1: time.Date(2021, 5, 25, 9, 0, 0, 0, time.Local), addBusinessDays1: time.Date(2021, 5, 26, 0, 0, 0, 0, time.Local)},
 				store:      map[string]*symbolPrice{"1234": {SymbolCode: "1234", Price: 100}, "2345": {SymbolCode: "2345", Price: 200}, "3456": {SymbolCode: "3456", Price: 300}},
 				expireTime: time.Date(2021, 5, 25, 8, 0, 0, 0, time.Local)},
 			arg:            &symbolPrice{SymbolCode: "2345", Price: 400, PriceTime: time.Date(2021, 5, 25, 9, 0, 0, 0, time.Local)},
@@ -218,3 +251,78 @@ func Test_priceStore_Set(t *testing.T) {
 		})
 	}
 }
+
+func Test_priceStore_getAll(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		store *priceStore
+		want  []*symbolPrice
+	}{
+		{name: "銘柄コード順に並べて返す",
+			store: &priceStore{
+				store:      map[string]*symbolPrice{"5678": {SymbolCode: "5678"}, "1234": {SymbolCode: "1234"}},
+				clock:      &testClock{now1: time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local)},
+				expireTime: time.Date(2021, 8, 26, 8, 0, 0, 0, time.Local)},
+			want: []*symbolPrice{{SymbolCode: "1234"}, {SymbolCode: "5678"}}},
+		{name: "有効期限が切れていたら空",
+			store: &priceStore{
+				store:      map[string]*symbolPrice{"1234": {SymbolCode: "1234"}},
+				clock:      &testClock{now1: time.Date(2021, 8, 26, 8, 0, 0, 0, time.Local)},
+				expireTime: time.Date(2021, 8, 26, 8, 0, 0, 0, time.Local)},
+			want: []*symbolPrice{}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.store.getAll()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_priceStore_restore(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name           string
+		arg            []*symbolPrice
+		wantStore      map[string]*symbolPrice
+		wantExpireTime time.Time
+	}{
+		{name: "最新の価格日時から有効期限を決め直す",
+			arg: []*symbolPrice{
+				{SymbolCode: "1234", PriceTime: time.Date(2021, 8, 24, 14, 0, 0, 0, time.Local)},
+				nil,
+				{SymbolCode: "5678", PriceTime: time.Date(2021, 8, 24, 7, 0, 0, 0, time.Local), AskTime: time.Date(2021, 8, 24, 15, 0, 0, 0, time.Local)},
+				{SymbolCode: "9999"},
+			},
+			wantStore: map[string]*symbolPrice{
+				"1234": {SymbolCode: "1234", PriceTime: time.Date(2021, 8, 24, 14, 0, 0, 0, time.Local)},
+				"5678": {SymbolCode: "5678", PriceTime: time.Date(2021, 8, 24, 7, 0, 0, 0, time.Local), AskTime: time.Date(2021, 8, 24, 15, 0, 0, 0, time.Local)},
+			},
+			wantExpireTime: time.Date(2021, 8, 25, 8, 0, 0, 0, time.Local)},
+		{name: "価格がなければ現在日時から有効期限を決める",
+			arg:            nil,
+			wantStore:      map[string]*symbolPrice{},
+			wantExpireTime: time.Date(2021, 8, 26, 8, 0, 0, 0, time.Local)},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			store := &priceStore{
+				store: map[string]*symbolPrice{"0000": {SymbolCode: "0000"}},
+				clock: newClockWithSource(&testSourceClock{now1: time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local)}),
+			}
+			store.restore(test.arg)
+			if !reflect.DeepEqual(test.wantStore, store.store) || !test.wantExpireTime.Equal(store.expireTime) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.wantStore, test.wantExpireTime, store.store, store.expireTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package virtual_security
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// replayTimeLayouts - CSVの日時として受け付ける書式 (タイムゾーンがなければローカル時刻)
+var replayTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006/01/02 15:04:05.999999999"}
+
+// ReplayStrategy - 価格を登録するたびに呼ばれる戦略
+//   引数の仮想証券会社に注文を出したり、注文やポジションを確認したりできる
+//   エラーを返すとそこで再生を止める
+type ReplayStrategy func(security VirtualSecurity, price RegisterPriceRequest) error
+
+// NewReplayer - 記録された価格を再生する仕組みの生成
+//   時計はWithClockで仮想証券会社に渡したものを指定する
+func NewReplayer(security VirtualSecurity, clock *SimulatedClock) *Replayer {
+	return &Replayer{security: security, clock: clock, scheduler: NewScheduler(security, clock)}
+}
+
+// Replayer - 記録された価格を日時の順に仮想証券会社に登録し、バックテストをする仕組み
+type Replayer struct {
+	security  VirtualSecurity
+	clock     *SimulatedClock
+	scheduler *Scheduler
+}
+
+// Replay - 価格を日時の順に登録する
+//   登録する前に時計を価格の日時まで進め、登録したあとに戦略を呼び出す
+//   時計がゼロ値なら、最初の価格の日時から始める
+func (r *Replayer) Replay(prices []RegisterPriceRequest, strategy ReplayStrategy) error {
+	if r.security == nil || r.clock == nil {
+		return NilArgumentError
+	}
+
+	sorted := make([]RegisterPriceRequest, len(prices))
+	copy(sorted, prices)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return replayTime(sorted[i]).Before(replayTime(sorted[j]))
+	})
+
+	for i, price := range sorted {
+		at := replayTime(price)
+		if at.IsZero() {
+			return fmt.Errorf("price has no time(symbol: %s), %w", price.SymbolCode, InvalidTimeError)
+		}
+		if i == 0 && r.clock.Now().IsZero() {
+			r.clock.Set(at)
+		}
+
+		if err := r.scheduler.AdvanceTo(at); err != nil {
+			return err
+		}
+		if err := r.security.RegisterPrice(price); err != nil {
+			return fmt.Errorf("register price(symbol: %s, time: %s), %w", price.SymbolCode, at, err)
+		}
+		if strategy != nil {
+			if err := strategy(r.security, price); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// replayTime - 価格を再生する日時 (現値、買気配、売気配の日時のうち最も遅いもの)
+func replayTime(price RegisterPriceRequest) time.Time {
+	at := price.PriceTime
+	if at.Before(price.BidTime) {
+		at = price.BidTime
+	}
+	if at.Before(price.AskTime) {
+		at = price.AskTime
+	}
+	return at
+}
+
+// ReadPricesJSONL - 1行に1つのRegisterPriceRequestをJSONで書いたデータを読み込む
+//   空行は読み飛ばす
+func ReadPricesJSONL(r io.Reader) ([]RegisterPriceRequest, error) {
+	if r == nil {
+		return nil, NilArgumentError
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var prices []RegisterPriceRequest
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var price RegisterPriceRequest
+		if err := json.Unmarshal([]byte(text), &price); err != nil {
+			return nil, fmt.Errorf("line %d: %s, %w", line, err, InvalidReplayDataError)
+		}
+		prices = append(prices, price)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+// ReadPricesCSV - 1行目をヘッダにしたCSVを読み込む
+//   ヘッダにはRegisterPriceRequestのフィールド名を書き、知らない列は無視する
+//   日時はRFC3339か "2006-01-02 15:04:05" の書式で、板は "価格:数量" を良い順に ";" でつなげて書く
+func ReadPricesCSV(r io.Reader) ([]RegisterPriceRequest, error) {
+	if r == nil {
+		return nil, NilArgumentError
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("line 1: %s, %w", err, InvalidReplayDataError)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["SymbolCode"]; !ok {
+		return nil, fmt.Errorf("line 1: SymbolCode column is required, %w", InvalidReplayDataError)
+	}
+
+	var prices []RegisterPriceRequest
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s, %w", line, err, InvalidReplayDataError)
+		}
+		price, err := parsePriceRecord(columns, record)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s, %w", line, err, InvalidReplayDataError)
+		}
+		prices = append(prices, price)
+	}
+	return prices, nil
+}
+
+// parsePriceRecord - CSVの1行を価格にする
+func parsePriceRecord(columns map[string]int, record []string) (RegisterPriceRequest, error) {
+	var price RegisterPriceRequest
+	value := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var err error
+	price.ExchangeType = ExchangeType(value("ExchangeType"))
+	price.SymbolCode = value("SymbolCode")
+	floats := []struct {
+		name string
+		dst  *float64
+	}{
+		{"Price", &price.Price}, {"Bid", &price.Bid}, {"Ask", &price.Ask},
+		{"Volume", &price.Volume}, {"BidQuantity", &price.BidQuantity}, {"AskQuantity", &price.AskQuantity},
+	}
+	for _, f := range floats {
+		if *f.dst, err = parseReplayFloat(value(f.name)); err != nil {
+			return price, fmt.Errorf("%s: %s", f.name, err)
+		}
+	}
+	times := []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"PriceTime", &price.PriceTime}, {"BidTime", &price.BidTime}, {"AskTime", &price.AskTime},
+	}
+	for _, t := range times {
+		if *t.dst, err = parseReplayTime(value(t.name)); err != nil {
+			return price, fmt.Errorf("%s: %s", t.name, err)
+		}
+	}
+	if price.BidBoard, err = parseReplayBoard(value("BidBoard")); err != nil {
+		return price, fmt.Errorf("BidBoard: %s", err)
+	}
+	if price.AskBoard, err = parseReplayBoard(value("AskBoard")); err != nil {
+		return price, fmt.Errorf("AskBoard: %s", err)
+	}
+	return price, nil
+}
+
+// parseReplayFloat - 空文字ならゼロ値にする
+func parseReplayFloat(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// parseReplayTime - 空文字ならゼロ値にする
+func parseReplayTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range replayTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format(%s)", s)
+}
+
+// parseReplayBoard - "価格:数量;価格:数量" の形式の板を読み込む
+func parseReplayBoard(s string) ([]BoardLevel, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var board []BoardLevel
+	for _, level := range strings.Split(s, ";") {
+		pq := strings.SplitN(strings.TrimSpace(level), ":", 2)
+		if len(pq) != 2 {
+			return nil, fmt.Errorf("invalid board level(%s)", level)
+		}
+		p, err := strconv.ParseFloat(strings.TrimSpace(pq[0]), 64)
+		if err != nil {
+			return nil, err
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(pq[1]), 64)
+		if err != nil {
+			return nil, err
+		}
+		board = append(board, BoardLevel{Price: p, Quantity: q})
+	}
+	return board, nil
+}
@@ -0,0 +1,244 @@
+package virtual_security
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_replayTime(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		arg  RegisterPriceRequest
+		want time.Time
+	}{
+		{name: "日時がなければゼロ値", arg: RegisterPriceRequest{}, want: time.Time{}},
+		{name: "現値の日時が最も遅ければ現値の日時",
+			arg:  RegisterPriceRequest{PriceTime: time.Date(2021, 9, 1, 9, 0, 2, 0, time.Local), BidTime: time.Date(2021, 9, 1, 9, 0, 1, 0, time.Local), AskTime: time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local)},
+			want: time.Date(2021, 9, 1, 9, 0, 2, 0, time.Local)},
+		{name: "気配の日時が最も遅ければ気配の日時",
+			arg:  RegisterPriceRequest{PriceTime: time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local), BidTime: time.Date(2021, 9, 1, 9, 0, 1, 0, time.Local), AskTime: time.Date(2021, 9, 1, 9, 0, 2, 0, time.Local)},
+			want: time.Date(2021, 9, 1, 9, 0, 2, 0, time.Local)},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := replayTime(test.arg)
+			if !test.want.Equal(got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_ReadPricesJSONL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		arg   string
+		want1 []RegisterPriceRequest
+		want2 error
+	}{
+		{name: "空行を読み飛ばして1行ずつ読み込む",
+			arg: `{"ExchangeType":"stock","SymbolCode":"1234","Price":1000,"PriceTime":"2021-09-01T09:00:00+09:00"}
+
+{"ExchangeType":"stock","SymbolCode":"1234","Bid":999,"BidTime":"2021-09-01T09:00:01+09:00","AskBoard":[{"Price":1001,"Quantity":100}]}
+`,
+			want1: []RegisterPriceRequest{
+				{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 9, 1, 9, 0, 0, 0, time.FixedZone("", 9*60*60))},
+				{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Bid: 999, BidTime: time.Date(2021, 9, 1, 9, 0, 1, 0, time.FixedZone("", 9*60*60)), AskBoard: []BoardLevel{{Price: 1001, Quantity: 100}}},
+			}},
+		{name: "JSONとして読めなければエラー",
+			arg:   `{"SymbolCode":"1234"` + "\n",
+			want2: InvalidReplayDataError},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got1, got2 := ReadPricesJSONL(strings.NewReader(test.arg))
+			if !reflect.DeepEqual(len(test.want1), len(got1)) || !errors.Is(got2, test.want2) {
+				t.Fatalf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want1, test.want2, got1, got2)
+			}
+			for i := range test.want1 {
+				if !test.want1[i].PriceTime.Equal(got1[i].PriceTime) || !test.want1[i].BidTime.Equal(got1[i].BidTime) {
+					t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want1[i], got1[i])
+				}
+				test.want1[i].PriceTime, got1[i].PriceTime = time.Time{}, time.Time{}
+				test.want1[i].BidTime, got1[i].BidTime = time.Time{}, time.Time{}
+				if !reflect.DeepEqual(test.want1[i], got1[i]) {
+					t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want1[i], got1[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_ReadPricesJSONL_nil(t *testing.T) {
+	t.Parallel()
+	if _, err := ReadPricesJSONL(nil); !errors.Is(err, NilArgumentError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), NilArgumentError, err)
+	}
+	if _, err := ReadPricesCSV(nil); !errors.Is(err, NilArgumentError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), NilArgumentError, err)
+	}
+}
+
+func Test_ReadPricesCSV(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		arg   string
+		want1 []RegisterPriceRequest
+		want2 error
+	}{
+		{name: "空なら何も返さない", arg: "", want1: nil},
+		{name: "ヘッダの列名で読み込み、知らない列は無視する",
+			arg: "Memo,SymbolCode,ExchangeType,Price,PriceTime,Bid,BidTime,Ask,AskTime,Volume,BidBoard,AskBoard\n" +
+				"a,1234,stock,1000,2021-09-01 09:00:00,999,2021/09/01 09:00:01,1001,,100,999:200;998:300,1001:100\n" +
+				"b,1234,stock,1001,2021-09-01 09:00:02.5,,,,,,,\n",
+			want1: []RegisterPriceRequest{
+				{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local),
+					Bid: 999, BidTime: time.Date(2021, 9, 1, 9, 0, 1, 0, time.Local), Ask: 1001, Volume: 100,
+					BidBoard: []BoardLevel{{Price: 999, Quantity: 200}, {Price: 998, Quantity: 300}}, AskBoard: []BoardLevel{{Price: 1001, Quantity: 100}}},
+				{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1001, PriceTime: time.Date(2021, 9, 1, 9, 0, 2, 500000000, time.Local)},
+			}},
+		{name: "RFC3339の日時も読み込める",
+			arg: "SymbolCode,ExchangeType,Price,PriceTime\n1234,future,28000,2021-09-01T09:00:00Z\n",
+			want1: []RegisterPriceRequest{
+				{ExchangeType: ExchangeTypeFuture, SymbolCode: "1234", Price: 28000, PriceTime: time.Date(2021, 9, 1, 9, 0, 0, 0, time.UTC)},
+			}},
+		{name: "銘柄コードの列がなければエラー", arg: "Price\n1000\n", want2: InvalidReplayDataError},
+		{name: "数値が読めなければエラー", arg: "SymbolCode,Price\n1234,abc\n", want2: InvalidReplayDataError},
+		{name: "日時が読めなければエラー", arg: "SymbolCode,PriceTime\n1234,09:00\n", want2: InvalidReplayDataError},
+		{name: "板が読めなければエラー", arg: "SymbolCode,BidBoard\n1234,999\n", want2: InvalidReplayDataError},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got1, got2 := ReadPricesCSV(strings.NewReader(test.arg))
+			if !reflect.DeepEqual(test.want1, got1) || !errors.Is(got2, test.want2) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want1, test.want2, got1, got2)
+			}
+		})
+	}
+}
+
+func Test_Replayer_Replay(t *testing.T) {
+	t.Parallel()
+	clock := NewSimulatedClock(time.Time{})
+	security := NewVirtualSecurityWithOptions(WithClock(clock))
+	replayer := NewReplayer(security, clock)
+
+	// 日時の順に並んでいなくても、日時の順に登録される
+	prices := []RegisterPriceRequest{
+		{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1010, PriceTime: time.Date(2021, 9, 2, 9, 0, 0, 0, time.Local)},
+		{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local)},
+		{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 9, 1, 9, 0, 1, 0, time.Local),
+			Bid: 999, BidTime: time.Date(2021, 9, 1, 9, 0, 1, 0, time.Local), Ask: 1001, AskTime: time.Date(2021, 9, 1, 9, 0, 1, 0, time.Local)},
+		{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 990, PriceTime: time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local),
+			Bid: 989, BidTime: time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local), Ask: 990, AskTime: time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local)},
+	}
+
+	// 最初の価格で約定する指値を、2つめの価格で約定しない当日限りの指値を出す
+	var called []time.Time
+	var orderCodes []string
+	strategy := func(security VirtualSecurity, price RegisterPriceRequest) error {
+		called = append(called, clock.Now())
+		limitPrice := map[int]float64{0: 995, 1: 900}[len(called)-1]
+		if limitPrice == 0 {
+			return nil
+		}
+		res, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, SymbolCode: price.SymbolCode, Quantity: 100, LimitPrice: limitPrice})
+		if err != nil {
+			return err
+		}
+		orderCodes = append(orderCodes, res.OrderCode)
+		return nil
+	}
+
+	if err := replayer.Replay(prices, strategy); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	wantCalled := []time.Time{
+		time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local),
+		time.Date(2021, 9, 1, 9, 0, 1, 0, time.Local),
+		time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local),
+		time.Date(2021, 9, 2, 9, 0, 0, 0, time.Local),
+	}
+	if !reflect.DeepEqual(wantCalled, called) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), wantCalled, called)
+	}
+
+	orders, _ := security.StockOrders()
+	statuses := map[string]OrderStatus{}
+	for _, o := range orders {
+		statuses[o.Code] = o.OrderStatus
+	}
+	if len(orderCodes) != 2 || statuses[orderCodes[0]] != OrderStatusDone || statuses[orderCodes[1]] != OrderStatusCanceled {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), OrderStatusDone, OrderStatusCanceled, orderCodes, statuses)
+	}
+
+	// 日付が変わっても価格を登録できる
+	price, err := security.Price("1234")
+	if err != nil || price.Price != 1010 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v, %+v\n", t.Name(), 1010, price, err)
+	}
+}
+
+func Test_Replayer_Replay_error(t *testing.T) {
+	t.Parallel()
+	strategyError := errors.New("strategy error")
+	price := RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local)}
+	tests := []struct {
+		name     string
+		clock    time.Time
+		prices   []RegisterPriceRequest
+		strategy ReplayStrategy
+		want     error
+	}{
+		{name: "日時のない価格はエラー",
+			clock:  time.Date(2021, 9, 1, 8, 0, 0, 0, time.Local),
+			prices: []RegisterPriceRequest{{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000}},
+			want:   InvalidTimeError},
+		{name: "時計より前の価格はエラー",
+			clock:  time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local),
+			prices: []RegisterPriceRequest{price},
+			want:   InvalidTimeError},
+		{name: "登録できない価格はエラー",
+			clock:  time.Date(2021, 9, 1, 8, 0, 0, 0, time.Local),
+			prices: []RegisterPriceRequest{{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local)}},
+			want:   InvalidExchangeTypeError},
+		{name: "戦略がエラーを返したらエラー",
+			clock:    time.Date(2021, 9, 1, 8, 0, 0, 0, time.Local),
+			prices:   []RegisterPriceRequest{price},
+			strategy: func(VirtualSecurity, RegisterPriceRequest) error { return strategyError },
+			want:     strategyError},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			clock := NewSimulatedClock(test.clock)
+			replayer := NewReplayer(NewVirtualSecurityWithOptions(WithClock(clock)), clock)
+			got := replayer.Replay(test.prices, test.strategy)
+			if !errors.Is(got, test.want) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+
+	if got := NewReplayer(nil, nil).Replay(nil, nil); !errors.Is(got, NilArgumentError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), NilArgumentError, got)
+	}
+}
@@ -0,0 +1,125 @@
+package virtual_security
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	// 板寄せの執行条件ごとの、約定しなかった注文を取り消すセッション
+	//   寄付の注文は引けまで約定できるので、セッションの引けの板寄せが終わったときに取り消す
+	auctionSchedules = []*auctionSchedule{
+		{exchangeType: ExchangeTypeStock, session: SessionMorning,
+			stockExecutionConditions: []StockExecutionCondition{StockExecutionConditionMOMO, StockExecutionConditionLOMO, StockExecutionConditionMOMC, StockExecutionConditionLOMC}},
+		{exchangeType: ExchangeTypeStock, session: SessionAfternoon,
+			stockExecutionConditions: []StockExecutionCondition{StockExecutionConditionMOAO, StockExecutionConditionLOAO, StockExecutionConditionMOAC, StockExecutionConditionLOAC}},
+		{exchangeType: ExchangeTypeFuture, session: SessionDay,
+			futureExecutionConditions: []FutureExecutionCondition{FutureExecutionConditionMOC, FutureExecutionConditionLOC}},
+		{exchangeType: ExchangeTypeFuture, session: SessionNight,
+			futureExecutionConditions: []FutureExecutionCondition{FutureExecutionConditionMOC, FutureExecutionConditionLOC}},
+	}
+)
+
+func newScheduleService(clock iClock, schedule *tradingSchedule) iScheduleService {
+	return &scheduleService{clock: clock, schedule: schedule}
+}
+
+type iScheduleService interface {
+	closedAuctions(now time.Time) []*closedAuction
+}
+
+type scheduleService struct {
+	clock           iClock
+	schedule        *tradingSchedule // 取引時間 (nilなら通常の取引時間)
+	lastProcessedAt time.Time
+	mtx             sync.Mutex
+}
+
+// auctionSchedule - 板寄せで約定しなかった注文を取り消すスケジュール
+type auctionSchedule struct {
+	exchangeType              ExchangeType               // 市場種別
+	session                   Session                    // 引けの板寄せが終わったら取り消すセッション
+	stockExecutionConditions  []StockExecutionCondition  // 取り消す現物・信用の執行条件
+	futureExecutionConditions []FutureExecutionCondition // 取り消す先物の執行条件
+}
+
+// closedAuction - 前回の処理から今回の処理までに終わった板寄せ
+type closedAuction struct {
+	closedAt                  time.Time
+	stockExecutionConditions  []StockExecutionCondition
+	futureExecutionConditions []FutureExecutionCondition
+}
+
+// isCancelTarget - 板寄せで約定しなかったとして取り消す現物・信用注文か
+//   板寄せが終わってから出された注文は、次の板寄せを待つので取り消さない
+func (a *closedAuction) isCancelTarget(executionCondition StockExecutionCondition, orderedAt time.Time) bool {
+	if !orderedAt.Before(a.closedAt) {
+		return false
+	}
+	for _, ec := range a.stockExecutionConditions {
+		if ec == executionCondition {
+			return true
+		}
+	}
+	return false
+}
+
+// isFutureCancelTarget - 板寄せで約定しなかったとして取り消す先物注文か
+func (a *closedAuction) isFutureCancelTarget(executionCondition FutureExecutionCondition, orderedAt time.Time) bool {
+	if !orderedAt.Before(a.closedAt) {
+		return false
+	}
+	for _, ec := range a.futureExecutionConditions {
+		if ec == executionCondition {
+			return true
+		}
+	}
+	return false
+}
+
+// closedAuctions - 前回の処理から指定した日時までに終わった板寄せ
+//   同じ板寄せが複数回終わっていれば最後の1回だけを返す。初回は処理した日時を記録するだけ
+func (s *scheduleService) closedAuctions(now time.Time) []*closedAuction {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	last := s.lastProcessedAt
+	if !now.After(last) {
+		return nil
+	}
+	s.lastProcessedAt = now
+	if last.IsZero() {
+		return nil
+	}
+
+	var auctions []*closedAuction
+	for _, schedule := range auctionSchedules {
+		closedAt, ok := schedule.lastClosedAt(now, s.clock, s.schedule)
+		if !ok || !closedAt.After(last) {
+			continue
+		}
+		auctions = append(auctions, &closedAuction{
+			closedAt:                  closedAt,
+			stockExecutionConditions:  schedule.stockExecutionConditions,
+			futureExecutionConditions: schedule.futureExecutionConditions,
+		})
+	}
+	return auctions
+}
+
+// lastClosedAt - 指定した日時以前で最後にセッションの引けの板寄せが終わった日時
+//   セッションが営業日でなければ板寄せはないので、1か月以内に見つからなければ見つからなかったとする
+func (s *auctionSchedule) lastClosedAt(now time.Time, clock iClock, schedule *tradingSchedule) (time.Time, bool) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for i := 0; i < 31; i++ {
+		if clock.isBusinessDay(day) {
+			for _, p := range schedule.periodsOf(s.exchangeType, day) {
+				if p.session == s.session && !p.end.After(now) {
+					return p.end, true
+				}
+			}
+		}
+		day = day.AddDate(0, 0, -1)
+	}
+	return time.Time{}, false
+}
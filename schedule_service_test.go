@@ -0,0 +1,155 @@
+package virtual_security
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testScheduleService struct {
+	iScheduleService
+	closedAuctions1       []*closedAuction
+	closedAuctionsHistory []time.Time
+}
+
+func (t *testScheduleService) closedAuctions(now time.Time) []*closedAuction {
+	t.closedAuctionsHistory = append(t.closedAuctionsHistory, now)
+	return t.closedAuctions1
+}
+
+func Test_newScheduleService(t *testing.T) {
+	t.Parallel()
+	clock := &testClock{}
+	want := &scheduleService{clock: clock, schedule: defaultTradingSchedule}
+	got := newScheduleService(clock, defaultTradingSchedule)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_scheduleService_closedAuctions(t *testing.T) {
+	t.Parallel()
+	morning := []StockExecutionCondition{StockExecutionConditionMOMO, StockExecutionConditionLOMO, StockExecutionConditionMOMC, StockExecutionConditionLOMC}
+	afternoon := []StockExecutionCondition{StockExecutionConditionMOAO, StockExecutionConditionLOAO, StockExecutionConditionMOAC, StockExecutionConditionLOAC}
+	future := []FutureExecutionCondition{FutureExecutionConditionMOC, FutureExecutionConditionLOC}
+	extended := newTradingSchedule(map[ExchangeType]SessionSchedule{ExchangeTypeStock: ExtendedStockSessionSchedule().WithHalfDays(time.Date(2021, 9, 2, 0, 0, 0, 0, time.Local))})
+	tests := []struct {
+		name     string
+		schedule *tradingSchedule
+		holidays []time.Time
+		last     time.Time
+		arg      time.Time
+		want     []*closedAuction
+	}{
+		{name: "初回は何も返さない",
+			arg:  time.Date(2021, 9, 1, 16, 0, 0, 0, time.Local),
+			want: nil},
+		{name: "前回から時間が進んでいなければ何も返さない",
+			last: time.Date(2021, 9, 1, 16, 0, 0, 0, time.Local),
+			arg:  time.Date(2021, 9, 1, 16, 0, 0, 0, time.Local),
+			want: nil},
+		{name: "前場の引けの終了を過ぎたら前場の板寄せを返す",
+			last: time.Date(2021, 9, 1, 11, 30, 0, 0, time.Local),
+			arg:  time.Date(2021, 9, 1, 11, 30, 5, 0, time.Local),
+			want: []*closedAuction{{closedAt: time.Date(2021, 9, 1, 11, 30, 5, 0, time.Local), stockExecutionConditions: morning}}},
+		{name: "取り消す時刻ちょうどの前回の処理で返したものは返さない",
+			last: time.Date(2021, 9, 1, 11, 30, 5, 0, time.Local),
+			arg:  time.Date(2021, 9, 1, 12, 0, 0, 0, time.Local),
+			want: nil},
+		{name: "複数の板寄せをまたいだらすべて返す",
+			last: time.Date(2021, 9, 1, 11, 0, 0, 0, time.Local),
+			arg:  time.Date(2021, 9, 1, 16, 0, 0, 0, time.Local),
+			want: []*closedAuction{
+				{closedAt: time.Date(2021, 9, 1, 11, 30, 5, 0, time.Local), stockExecutionConditions: morning},
+				{closedAt: time.Date(2021, 9, 1, 15, 0, 5, 0, time.Local), stockExecutionConditions: afternoon},
+				{closedAt: time.Date(2021, 9, 1, 15, 45, 5, 0, time.Local), futureExecutionConditions: future}}},
+		{name: "同じ板寄せを複数回またいだら最後の1回だけを返す",
+			last: time.Date(2021, 9, 1, 11, 0, 0, 0, time.Local),
+			arg:  time.Date(2021, 9, 2, 12, 0, 0, 0, time.Local),
+			want: []*closedAuction{
+				{closedAt: time.Date(2021, 9, 2, 11, 30, 5, 0, time.Local), stockExecutionConditions: morning},
+				{closedAt: time.Date(2021, 9, 1, 15, 0, 5, 0, time.Local), stockExecutionConditions: afternoon},
+				{closedAt: time.Date(2021, 9, 1, 15, 45, 5, 0, time.Local), futureExecutionConditions: future},
+				{closedAt: time.Date(2021, 9, 2, 6, 0, 5, 0, time.Local), futureExecutionConditions: future}}},
+		{name: "金曜日の夜間の引けは土曜日の朝に返す",
+			last: time.Date(2021, 9, 4, 0, 0, 0, 0, time.Local),
+			arg:  time.Date(2021, 9, 4, 7, 0, 0, 0, time.Local),
+			want: []*closedAuction{{closedAt: time.Date(2021, 9, 4, 6, 0, 5, 0, time.Local), futureExecutionConditions: future}}},
+		{name: "土日には板寄せがない",
+			last: time.Date(2021, 9, 4, 7, 0, 0, 0, time.Local),
+			arg:  time.Date(2021, 9, 6, 7, 0, 0, 0, time.Local),
+			want: nil},
+		{name: "祝日と、祝日に始まる夜間には板寄せがない",
+			holidays: []time.Time{time.Date(2021, 9, 20, 0, 0, 0, 0, time.Local)},
+			last:     time.Date(2021, 9, 18, 7, 0, 0, 0, time.Local),
+			arg:      time.Date(2021, 9, 21, 7, 0, 0, 0, time.Local),
+			want:     nil},
+		{name: "年末年始には板寄せがない",
+			last: time.Date(2021, 12, 31, 7, 0, 0, 0, time.Local),
+			arg:  time.Date(2022, 1, 3, 16, 0, 0, 0, time.Local),
+			want: nil},
+		{name: "延長した取引時間なら後場の板寄せは15:30の引けの後に返す",
+			schedule: extended,
+			last:     time.Date(2021, 9, 1, 15, 10, 0, 0, time.Local),
+			arg:      time.Date(2021, 9, 1, 15, 30, 5, 0, time.Local),
+			want:     []*closedAuction{{closedAt: time.Date(2021, 9, 1, 15, 30, 5, 0, time.Local), stockExecutionConditions: afternoon}}},
+		{name: "半日立会の日には後場の板寄せがない",
+			schedule: extended,
+			last:     time.Date(2021, 9, 2, 11, 0, 0, 0, time.Local),
+			arg:      time.Date(2021, 9, 2, 16, 0, 0, 0, time.Local),
+			want: []*closedAuction{
+				{closedAt: time.Date(2021, 9, 2, 11, 30, 5, 0, time.Local), stockExecutionConditions: morning},
+				{closedAt: time.Date(2021, 9, 2, 15, 45, 5, 0, time.Local), futureExecutionConditions: future}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := &scheduleService{clock: &clock{calendar: newCalendar(test.holidays)}, schedule: test.schedule, lastProcessedAt: test.last}
+			got := service.closedAuctions(test.arg)
+			if !reflect.DeepEqual(test.want, got) || !service.lastProcessedAt.Equal(test.arg) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want, test.arg, got, service.lastProcessedAt)
+			}
+		})
+	}
+}
+
+func Test_closedAuction_isCancelTarget(t *testing.T) {
+	t.Parallel()
+	auction := &closedAuction{
+		closedAt:                  time.Date(2021, 9, 1, 11, 30, 5, 0, time.Local),
+		stockExecutionConditions:  []StockExecutionCondition{StockExecutionConditionMOMO},
+		futureExecutionConditions: []FutureExecutionCondition{FutureExecutionConditionMOC},
+	}
+	tests := []struct {
+		name                     string
+		stockExecutionCondition  StockExecutionCondition
+		futureExecutionCondition FutureExecutionCondition
+		orderedAt                time.Time
+		want1                    bool
+		want2                    bool
+	}{
+		{name: "対象の執行条件で板寄せの終了前に出された注文は対象",
+			stockExecutionCondition: StockExecutionConditionMOMO, futureExecutionCondition: FutureExecutionConditionMOC,
+			orderedAt: time.Date(2021, 9, 1, 8, 0, 0, 0, time.Local), want1: true, want2: true},
+		{name: "対象外の執行条件の注文は対象外",
+			stockExecutionCondition: StockExecutionConditionMOAO, futureExecutionCondition: FutureExecutionConditionLOC,
+			orderedAt: time.Date(2021, 9, 1, 8, 0, 0, 0, time.Local), want1: false, want2: false},
+		{name: "板寄せの終了後に出された注文は次の板寄せを待つので対象外",
+			stockExecutionCondition: StockExecutionConditionMOMO, futureExecutionCondition: FutureExecutionConditionMOC,
+			orderedAt: time.Date(2021, 9, 1, 11, 30, 5, 0, time.Local), want1: false, want2: false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got1 := auction.isCancelTarget(test.stockExecutionCondition, test.orderedAt)
+			got2 := auction.isFutureCancelTarget(test.futureExecutionCondition, test.orderedAt)
+			if test.want1 != got1 || test.want2 != got2 {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want1, test.want2, got1, got2)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package virtual_security
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// NewSimulatedClock - 指定した日時から始まる、手動で進める時計の生成
+func NewSimulatedClock(now time.Time) *SimulatedClock {
+	return &SimulatedClock{current: now}
+}
+
+// SimulatedClock - 手動で進める時計
+//   WithClockで仮想証券会社に渡し、Schedulerで時間を進める
+type SimulatedClock struct {
+	current time.Time
+	mtx     sync.Mutex
+}
+
+// Now - 現在日時
+func (c *SimulatedClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.current
+}
+
+// Set - 現在日時の変更
+func (c *SimulatedClock) Set(now time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.current = now
+}
+
+// NewScheduler - 仮想証券会社の日付やセッションの切り替わりを処理するスケジューラの生成
+//   時計がnilなら、Startで実時間に合わせて処理することだけができる
+//   日付やセッションの切り替わりは仮想証券会社の取引時間で決めるので、このパッケージで生成した仮想証券会社だけを処理できる
+func NewScheduler(security VirtualSecurity, clock *SimulatedClock) *Scheduler {
+	return &Scheduler{security: security, clock: clock}
+}
+
+// iScheduledSecurity - スケジューラが処理する仮想証券会社
+type iScheduledSecurity interface {
+	Tick() error
+	nextSessionBoundary(now time.Time) time.Time
+}
+
+// Scheduler - 仮想証券会社の日付やセッションの切り替わりを処理するスケジューラ
+type Scheduler struct {
+	security VirtualSecurity
+	clock    *SimulatedClock
+	stop     chan struct{}
+	done     chan struct{}
+	mtx      sync.Mutex
+}
+
+// AdvanceTo - 時計を指定した日時まで進める
+//   途中の日付やセッションの切り替わりごとに時計を止めて処理するので、進める幅に関係なく失効や取消の漏れがない
+func (s *Scheduler) AdvanceTo(to time.Time) error {
+	security, err := s.scheduledSecurity()
+	if err != nil {
+		return err
+	}
+	if s.clock == nil {
+		return NilArgumentError
+	}
+
+	now := s.clock.Now()
+	if to.Before(now) {
+		return fmt.Errorf("cannot advance to past(now: %s, to: %s), %w", now, to, InvalidTimeError)
+	}
+
+	if err := security.Tick(); err != nil {
+		return err
+	}
+	for boundary := security.nextSessionBoundary(now); boundary.Before(to); boundary = security.nextSessionBoundary(boundary) {
+		s.clock.Set(boundary)
+		if err := security.Tick(); err != nil {
+			return err
+		}
+	}
+
+	s.clock.Set(to)
+	return security.Tick()
+}
+
+// scheduledSecurity - スケジューラが処理する仮想証券会社
+//   このパッケージで生成した仮想証券会社でなければ、取引時間がわからないのでエラーを返す
+func (s *Scheduler) scheduledSecurity() (iScheduledSecurity, error) {
+	if s.security == nil {
+		return nil, NilArgumentError
+	}
+	security, ok := s.security.(iScheduledSecurity)
+	if !ok {
+		return nil, fmt.Errorf("security has no trading schedule(security: %T), %w", s.security, UnsupportedSecurityError)
+	}
+	return security, nil
+}
+
+// Start - 指定した間隔で日付やセッションの切り替わりを処理し続ける
+//   実時間で動かすときに使う。Stopを呼ぶまで止まらない
+func (s *Scheduler) Start(interval time.Duration) error {
+	security, err := s.scheduledSecurity()
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return fmt.Errorf("interval must be positive(interval: %s), %w", interval, InvalidIntervalError)
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.stop != nil {
+		return RunningSchedulerError
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				_ = security.Tick()
+			}
+		}
+	}(s.stop, s.done)
+
+	return nil
+}
+
+// Stop - Startで始めた処理を止める
+//   処理中のTickがあれば、終わるまで待つ
+func (s *Scheduler) Stop() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+	s.done = nil
+}
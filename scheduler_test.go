@@ -0,0 +1,238 @@
+package virtual_security
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testVirtualSecurity struct {
+	VirtualSecurity
+	tick1     error
+	tickCount int
+	mtx       sync.Mutex
+}
+
+func (t *testVirtualSecurity) Tick() error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.tickCount++
+	return t.tick1
+}
+
+func (t *testVirtualSecurity) nextSessionBoundary(now time.Time) time.Time {
+	return defaultTradingSchedule.nextBoundary(now)
+}
+
+func (t *testVirtualSecurity) getTickCount() int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.tickCount
+}
+
+func Test_SimulatedClock(t *testing.T) {
+	t.Parallel()
+	clock := NewSimulatedClock(time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local))
+	if want, got := time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local), clock.Now(); !want.Equal(got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+
+	clock.Set(time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local))
+	if want, got := time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local), clock.Now(); !want.Equal(got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_Scheduler_AdvanceTo(t *testing.T) {
+	t.Parallel()
+	clock := NewSimulatedClock(time.Date(2021, 9, 3, 12, 0, 0, 0, time.Local))
+	security := NewVirtualSecurityWithOptions(WithClock(clock))
+	scheduler := NewScheduler(security, clock)
+
+	// 金曜日の後場に、月曜日の前場の寄付を待つ寄成と、当日限りの指値を注文する
+	res1, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionMOMO, SymbolCode: "1234", Quantity: 100, ExpiredAt: time.Date(2021, 9, 10, 0, 0, 0, 0, time.Local)})
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	res2, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 900})
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 土曜日になると、当日限りの指値は日付の変わり目で失効し、寄成はそのまま残る
+	if err := scheduler.AdvanceTo(time.Date(2021, 9, 4, 10, 0, 0, 0, time.Local)); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	orders := map[string]*stockOrder{}
+	for _, o := range security.(*virtualSecurity).stockService.getStockOrders() {
+		orders[o.Code] = o
+	}
+	if o := orders[res1.OrderCode]; o == nil || o.OrderStatus != OrderStatusInOrder {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), OrderStatusInOrder, o)
+	}
+	if o := orders[res2.OrderCode]; o == nil || o.OrderStatus != OrderStatusCanceled || !o.CanceledAt.Equal(time.Date(2021, 9, 4, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v\n", t.Name(), OrderStatusCanceled, time.Date(2021, 9, 4, 0, 0, 0, 0, time.Local), o)
+	}
+
+	// 月曜日の前場が終わると寄成は取り消され、前の営業日の金曜日に失効した注文は一覧に残る
+	if err := scheduler.AdvanceTo(time.Date(2021, 9, 6, 12, 0, 0, 0, time.Local)); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	orders = map[string]*stockOrder{}
+	for _, o := range security.(*virtualSecurity).stockService.getStockOrders() {
+		orders[o.Code] = o
+	}
+	if o := orders[res1.OrderCode]; o == nil || o.OrderStatus != OrderStatusCanceled || !o.CanceledAt.Equal(time.Date(2021, 9, 6, 11, 30, 5, 0, time.Local)) || o.Message != "板寄せで約定しなかったため取消" {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v\n", t.Name(), OrderStatusCanceled, time.Date(2021, 9, 6, 11, 30, 5, 0, time.Local), o)
+	}
+	if o := orders[res2.OrderCode]; o == nil || o.OrderStatus != OrderStatusCanceled {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), OrderStatusCanceled, o)
+	}
+	if want, got := time.Date(2021, 9, 6, 12, 0, 0, 0, time.Local), clock.Now(); !want.Equal(got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+
+	// 火曜日になると、前の営業日より前に失効した注文は一覧から削除される
+	if err := scheduler.AdvanceTo(time.Date(2021, 9, 7, 12, 0, 0, 0, time.Local)); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if o, err := security.(*virtualSecurity).stockService.getStockOrderByCode(res2.OrderCode); o != nil || err == nil {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v, %+v\n", t.Name(), nil, o, err)
+	}
+
+	// 時間を戻すことはできない
+	if err := scheduler.AdvanceTo(time.Date(2021, 9, 6, 11, 0, 0, 0, time.Local)); !errors.Is(err, InvalidTimeError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), InvalidTimeError, err)
+	}
+}
+
+func Test_Scheduler_AdvanceTo_sessionSchedule(t *testing.T) {
+	t.Parallel()
+	clock := NewSimulatedClock(time.Date(2021, 9, 3, 12, 0, 0, 0, time.Local))
+	security := NewVirtualSecurityWithOptions(WithClock(clock), WithSessionSchedule(ExchangeTypeStock, ExtendedStockSessionSchedule()))
+	scheduler := NewScheduler(security, clock)
+
+	// 後場の寄付を待つ寄成は、延長した取引時間の15:30の引けの板寄せが終わってから取り消される
+	res, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionMOAO, SymbolCode: "1234", Quantity: 100})
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if err := scheduler.AdvanceTo(time.Date(2021, 9, 3, 15, 10, 0, 0, time.Local)); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if o, err := security.(*virtualSecurity).stockService.getStockOrderByCode(res.OrderCode); err != nil || o.OrderStatus != OrderStatusInOrder {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v, %+v\n", t.Name(), OrderStatusInOrder, o, err)
+	}
+
+	if err := scheduler.AdvanceTo(time.Date(2021, 9, 3, 16, 0, 0, 0, time.Local)); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if o, err := security.(*virtualSecurity).stockService.getStockOrderByCode(res.OrderCode); err != nil || o.OrderStatus != OrderStatusCanceled || !o.CanceledAt.Equal(time.Date(2021, 9, 3, 15, 30, 5, 0, time.Local)) {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), OrderStatusCanceled, time.Date(2021, 9, 3, 15, 30, 5, 0, time.Local), o, err)
+	}
+}
+
+func Test_Scheduler_AdvanceTo_error(t *testing.T) {
+	t.Parallel()
+	clock := NewSimulatedClock(time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local))
+	tests := []struct {
+		name      string
+		scheduler *Scheduler
+		want      error
+	}{
+		{name: "時計がなければエラー", scheduler: NewScheduler(&testVirtualSecurity{}, nil), want: NilArgumentError},
+		{name: "仮想証券会社がなければエラー", scheduler: NewScheduler(nil, clock), want: NilArgumentError},
+		{name: "取引時間のわからない仮想証券会社ならエラー", scheduler: NewScheduler(struct{ VirtualSecurity }{}, clock), want: UnsupportedSecurityError},
+		{name: "処理でエラーが出たらエラー", scheduler: NewScheduler(&testVirtualSecurity{tick1: NoDataError}, clock), want: NoDataError},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.scheduler.AdvanceTo(time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local))
+			if !errors.Is(got, test.want) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_Scheduler_StartStop(t *testing.T) {
+	t.Parallel()
+	security := &testVirtualSecurity{}
+	scheduler := NewScheduler(security, nil)
+
+	if err := NewScheduler(struct{ VirtualSecurity }{}, nil).Start(time.Millisecond); !errors.Is(err, UnsupportedSecurityError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), UnsupportedSecurityError, err)
+	}
+	if err := scheduler.Start(0); !errors.Is(err, InvalidIntervalError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), InvalidIntervalError, err)
+	}
+	if err := scheduler.Start(time.Millisecond); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if err := scheduler.Start(time.Millisecond); !errors.Is(err, RunningSchedulerError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), RunningSchedulerError, err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for security.getTickCount() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	scheduler.Stop()
+	scheduler.Stop()
+
+	count := security.getTickCount()
+	if count < 2 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), ">= 2", count)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if got := security.getTickCount(); count != got {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), count, got)
+	}
+
+	// 止めたあとは再び始められる
+	if err := scheduler.Start(time.Millisecond); err != nil {
+		t.Errorf("%s error\n%+v\n", t.Name(), err)
+	}
+	scheduler.Stop()
+}
+
+func Test_Scheduler_Start_concurrent(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local)}
+	security := NewVirtualSecurityWithOptions(WithClock(source))
+
+	// ハンドラの中から仮想証券会社を操作してもデッドロックしない
+	if _, err := security.Subscribe(func(Event) { _, _ = security.Account() }); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	scheduler := NewScheduler(security, nil)
+	if err := scheduler.Start(time.Millisecond); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// go test -raceで、スケジューラのTickと注文の処理が競合しないことを確認する
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_ = security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: source.Now(), Ask: 1001, AskTime: source.Now(), Bid: 999, BidTime: source.Now()})
+				if res, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 990}); err == nil {
+					_ = security.CancelStockOrder(&CancelOrderRequest{OrderCode: res.OrderCode})
+				}
+				_, _ = security.MarginOrder(&MarginOrderRequest{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 100})
+				_, _ = security.StockOrders()
+				_, _ = security.MarginPositions()
+				_, _ = security.Portfolio()
+			}
+		}()
+	}
+	wg.Wait()
+	scheduler.Stop()
+}
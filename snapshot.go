@@ -0,0 +1,162 @@
+package virtual_security
+
+import (
+	"sort"
+	"time"
+)
+
+// snapshotVersion - スナップショットの形式のバージョン
+const snapshotVersion = 1
+
+// snapshot - 一時停止した仮想証券会社を再開するために書き出す状態
+type snapshot struct {
+	Version         int               // 形式のバージョン
+	CreatedAt       time.Time         // 書き出した日時
+	Account         *accountState     // 口座
+	StockSymbols    []*stockSymbol    // 株式銘柄
+	StockOrders     []*stockOrder     // 現物注文
+	StockPositions  []*stockPosition  // 現物ポジション
+	MarginOrders    []*marginOrder    // 信用注文
+	MarginPositions []*marginPosition // 信用ポジション
+	FutureSymbols   []*futureSymbol   // 先物銘柄
+	FutureOrders    []*futureOrder    // 先物注文
+	FuturePositions []*futurePosition // 先物ポジション
+	Prices          []*storedPrice    // 価格
+	Trades          []*Trade          // 約定履歴
+}
+
+// accountState - 書き出す口座の状態 (注文の拘束金や手数料の計算に使う約定代金も含める)
+type accountState struct {
+	Cash                  float64                            // 預り金
+	InitialMarginRate     float64                            // 委託保証金率
+	MaintenanceMarginRate float64                            // 最低委託保証金維持率
+	MarginPositionAmount  float64                            // 信用建玉金額
+	MarginProfit          float64                            // 信用建玉の評価損益
+	IsMarginCall          bool                               // 追証が発生しているか
+	MarginCalledAt        time.Time                          // 追証の発生日時
+	Reservations          map[string]*reservationState       // 注文ごとの拘束金
+	MarginReservations    map[string]*reservationState       // 信用新規注文ごとの建玉予定金額
+	DailyAmounts          map[ExchangeType]*dailyAmountState // 市場種別ごとの1日の約定代金の合計
+}
+
+// reservationState - 書き出す注文の拘束金
+type reservationState struct {
+	Price    float64 // 拘束単価
+	Quantity float64 // 拘束数量
+}
+
+// dailyAmountState - 書き出す1日の約定代金の合計
+type dailyAmountState struct {
+	Day    time.Time // 日付
+	Amount float64   // 約定代金の合計
+}
+
+// storedPrice - 書き出す価格 (約定に使った数量や値幅制限も含める)
+type storedPrice struct {
+	symbolPrice
+	Kind             PriceKind           // 種別
+	Session          Session             // セッション
+	PriceBusinessDay time.Time           // 価格日時の営業日
+	ContractedVolume float64             // 出来高のうち仮想注文の約定に使った数量
+	ContractedBid    float64             // 買気配数量のうち仮想注文の約定に使った数量
+	ContractedAsk    float64             // 売気配数量のうち仮想注文の約定に使った数量
+	ContractedBoard  []storedBoardVolume // 板の気配値ごとの、仮想注文の約定に使った数量
+	LowerLimit       float64             // 値幅制限の下限
+	UpperLimit       float64             // 値幅制限の上限
+	ItayosePrice     float64             // 板寄せの約定値段
+	ItayoseQuantity  float64             // 板寄せの約定数量
+	ContractedBuy    float64             // 板寄せの約定数量のうち仮想注文の買いの約定に使った数量
+	ContractedSell   float64             // 板寄せの約定数量のうち仮想注文の売りの約定に使った数量
+}
+
+// storedBoardVolume - 書き出す板の気配値ごとの数量
+type storedBoardVolume struct {
+	Source   priceSource // 買板か売板か
+	Price    float64     // 気配値
+	Quantity float64     // 数量
+}
+
+// newStoredPrice - 価格を書き出せる形にする
+func newStoredPrice(price *symbolPrice) *storedPrice {
+	if price == nil {
+		return nil
+	}
+
+	board := make([]storedBoardVolume, 0)
+	for source, volumes := range price.contractedBoard {
+		for p, q := range volumes {
+			board = append(board, storedBoardVolume{Source: source, Price: p, Quantity: q})
+		}
+	}
+	sort.Slice(board, func(i, j int) bool {
+		if board[i].Source != board[j].Source {
+			return board[i].Source < board[j].Source
+		}
+		return board[i].Price < board[j].Price
+	})
+
+	return &storedPrice{
+		symbolPrice:      *price,
+		Kind:             price.kind,
+		Session:          price.session,
+		PriceBusinessDay: price.priceBusinessDay,
+		ContractedVolume: price.contractedVolume,
+		ContractedBid:    price.contractedBid,
+		ContractedAsk:    price.contractedAsk,
+		ContractedBoard:  board,
+		LowerLimit:       price.lowerLimit,
+		UpperLimit:       price.upperLimit,
+		ItayosePrice:     price.itayosePrice,
+		ItayoseQuantity:  price.itayoseQuantity,
+		ContractedBuy:    price.contractedBuy,
+		ContractedSell:   price.contractedSell,
+	}
+}
+
+// toSymbolPrice - 書き出した価格を戻す
+func (s *storedPrice) toSymbolPrice() *symbolPrice {
+	if s == nil {
+		return nil
+	}
+
+	price := s.symbolPrice
+	price.kind = s.Kind
+	price.session = s.Session
+	price.priceBusinessDay = s.PriceBusinessDay
+	price.contractedVolume = s.ContractedVolume
+	price.contractedBid = s.ContractedBid
+	price.contractedAsk = s.ContractedAsk
+	price.contractedBoard = nil
+	for _, b := range s.ContractedBoard {
+		price.contractBoard(b.Source, b.Price, b.Quantity)
+	}
+	price.lowerLimit = s.LowerLimit
+	price.upperLimit = s.UpperLimit
+	price.itayosePrice = s.ItayosePrice
+	price.itayoseQuantity = s.ItayoseQuantity
+	price.contractedBuy = s.ContractedBuy
+	price.contractedSell = s.ContractedSell
+	return &price
+}
+
+// newStoredPrices - 価格の一覧を書き出せる形にする
+func newStoredPrices(prices []*symbolPrice) []*storedPrice {
+	res := make([]*storedPrice, 0, len(prices))
+	for _, p := range prices {
+		if p != nil {
+			res = append(res, newStoredPrice(p))
+		}
+	}
+	return res
+}
+
+// toSymbolPrices - 書き出した価格の一覧を戻す
+func toSymbolPrices(prices []*storedPrice) []*symbolPrice {
+	res := make([]*symbolPrice, 0, len(prices))
+	for _, p := range prices {
+		if p != nil {
+			res = append(res, p.toSymbolPrice())
+		}
+	}
+	return res
+}
@@ -0,0 +1,115 @@
+package virtual_security
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_newStoredPrice(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		arg  *symbolPrice
+		want *storedPrice
+	}{
+		{name: "nilならnil", arg: nil, want: nil},
+		{name: "約定に使った板の数量は売買と気配値の順に並べて書き出せる形にする",
+			arg: &symbolPrice{
+				SymbolCode:       "1234",
+				Price:            1000,
+				kind:             PriceKindRegular,
+				session:          SessionMorning,
+				contractedVolume: 100,
+				contractedBoard:  boardVolumes{priceSourceAsk: {1002: 200, 1001: 100}, priceSourceBid: {999: 300}},
+				lowerLimit:       700,
+				upperLimit:       1300,
+			},
+			want: &storedPrice{
+				symbolPrice: symbolPrice{
+					SymbolCode:       "1234",
+					Price:            1000,
+					kind:             PriceKindRegular,
+					session:          SessionMorning,
+					contractedVolume: 100,
+					contractedBoard:  boardVolumes{priceSourceAsk: {1002: 200, 1001: 100}, priceSourceBid: {999: 300}},
+					lowerLimit:       700,
+					upperLimit:       1300,
+				},
+				Kind:             PriceKindRegular,
+				Session:          SessionMorning,
+				ContractedVolume: 100,
+				ContractedBoard: []storedBoardVolume{
+					{Source: priceSourceAsk, Price: 1001, Quantity: 100},
+					{Source: priceSourceAsk, Price: 1002, Quantity: 200},
+					{Source: priceSourceBid, Price: 999, Quantity: 300},
+				},
+				LowerLimit: 700,
+				UpperLimit: 1300,
+			}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := newStoredPrice(test.arg)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_storedPrice_toSymbolPrice(t *testing.T) {
+	t.Parallel()
+	price := &symbolPrice{
+		ExchangeType:     ExchangeTypeStock,
+		SymbolCode:       "1234",
+		Price:            1000,
+		PriceTime:        time.Date(2021, 8, 25, 10, 0, 0, 0, time.UTC),
+		Volume:           1000,
+		BidBoard:         []BoardLevel{{Price: 999, Quantity: 300}},
+		kind:             PriceKindClosing,
+		session:          SessionAfternoon,
+		priceBusinessDay: time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+		contractedVolume: 100,
+		contractedBid:    200,
+		contractedAsk:    300,
+		contractedBoard:  boardVolumes{priceSourceBid: {999: 100}},
+		lowerLimit:       700,
+		upperLimit:       1300,
+		itayosePrice:     1000,
+		itayoseQuantity:  500,
+		contractedBuy:    400,
+		contractedSell:   100,
+	}
+
+	// JSONに書き出して読み込んでも、非公開のフィールドまで戻せる
+	b, err := json.Marshal(newStoredPrice(price))
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	var stored *storedPrice
+	if err := json.Unmarshal(b, &stored); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	got := stored.toSymbolPrice()
+	if !reflect.DeepEqual(price, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), price, got)
+	}
+
+	var nilPrice *storedPrice
+	if got := nilPrice.toSymbolPrice(); got != nil {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), nil, got)
+	}
+}
+
+func Test_toSymbolPrices(t *testing.T) {
+	t.Parallel()
+	want := []*symbolPrice{{SymbolCode: "1234", Price: 1000}, {SymbolCode: "5678", Price: 2000}}
+	got := toSymbolPrices(newStoredPrices([]*symbolPrice{want[0], nil, want[1]}))
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
@@ -1,29 +1,58 @@
 package virtual_security
 
-import "time"
+import (
+	"math"
+	"time"
+)
 
-func newStockContractComponent() iStockContractComponent {
-	return &stockContractComponent{}
+func newStockContractComponent(schedule *tradingSchedule) iStockContractComponent {
+	return &stockContractComponent{schedule: schedule}
 }
 
 type iStockContractComponent interface {
 	isContractableTime(executionCondition StockExecutionCondition, now time.Time) bool
 	confirmStockOrderContract(order *stockOrder, price *symbolPrice, now time.Time) *confirmContractResult
 	confirmMarginOrderContract(order *marginOrder, price *symbolPrice, now time.Time) *confirmContractResult
+	isContractableFutureTime(executionCondition FutureExecutionCondition, now time.Time) bool
+	confirmFutureOrderContract(order *futureOrder, price *symbolPrice, now time.Time) *confirmContractResult
 }
 
-type stockContractComponent struct{}
+type stockContractComponent struct {
+	schedule *tradingSchedule // 取引時間 (nilなら通常の取引時間)
+}
 
 // isContractableTime - 注文が約定できるタイミングにあるか
+//   前場と後場以外のセッションはザラバだけなので、寄付や引けの執行条件では約定しない
 func (c *stockContractComponent) isContractableTime(executionCondition StockExecutionCondition, now time.Time) bool {
-	return (executionCondition.IsContractableMorningSession() && contractableMorningSessionAuctionTime.between(now)) ||
-		(executionCondition.IsContractableMorningSessionClosing() && contractableMorningSessionCloseTime.between(now)) ||
-		(executionCondition.IsContractableAfternoonSession() && contractableAfternoonSessionAuctionTime.between(now)) ||
-		(executionCondition.IsContractableAfternoonSessionClosing() && contractableAfternoonSessionCloseTime.between(now))
+	p := c.schedule.sessionAt(ExchangeTypeStock, now)
+	if p == nil {
+		return false
+	}
+
+	switch p.session {
+	case SessionMorning:
+		return (executionCondition.IsContractableMorningSession() && p.isRegular(now)) ||
+			(executionCondition.IsContractableMorningSessionClosing() && p.isClosing(now))
+	case SessionAfternoon:
+		return (executionCondition.IsContractableAfternoonSession() && p.isRegular(now)) ||
+			(executionCondition.IsContractableAfternoonSessionClosing() && p.isClosing(now))
+	}
+	return executionCondition.IsContractableMorningSession() && executionCondition.IsContractableAfternoonSession() && p.isRegular(now)
+}
+
+// isContractableFutureTime - 先物注文が約定できるタイミングにあるか
+func (c *stockContractComponent) isContractableFutureTime(executionCondition FutureExecutionCondition, now time.Time) bool {
+	p := c.schedule.sessionAt(ExchangeTypeFuture, now)
+	if p == nil {
+		return false
+	}
+	return (executionCondition.IsContractableSession() && p.isRegular(now)) ||
+		(executionCondition.IsContractableSessionClosing() && p.isClosing(now))
 }
 
 // confirmContractItayoseMO - 板寄せ方式での成行注文の約定確認と約定した場合の結果
-//   板寄せ方式では、5s以内の現値があれば現値で約定する
+//   気配から求めた板寄せの約定値段があれば、約定値段で約定する
+//   板寄せの約定値段がなければ、5s以内の現値があれば現値で約定する
 //   5s以内の現値がなくても、買い注文で売り気配値があれば売り気配値で約定する
 //   5s以内の現値がなくても、売り注文で買い気配値があれば買い気配値で約定する
 func (c *stockContractComponent) confirmContractItayoseMO(side Side, price *symbolPrice, now time.Time) *confirmContractResult {
@@ -31,17 +60,25 @@ func (c *stockContractComponent) confirmContractItayoseMO(side Side, price *symb
 	if price == nil {
 		return result
 	}
-	if price.Price > 0 && now.Add(-5*time.Second).Before(price.PriceTime) {
+	if price.itayosePrice > 0 {
+		result.isContracted = true
+		result.price = price.itayosePrice
+		result.source = itayoseSource(side)
+		result.contractedAt = now
+	} else if price.Price > 0 && now.Add(-5*time.Second).Before(price.PriceTime) {
 		result.isContracted = true
 		result.price = price.Price
+		result.source = priceSourcePrice
 		result.contractedAt = now
 	} else if side == SideBuy && price.Ask > 0 {
 		result.isContracted = true
 		result.price = price.Ask
+		result.source = priceSourceAsk
 		result.contractedAt = now
 	} else if side == SideSell && price.Bid > 0 {
 		result.isContracted = true
 		result.price = price.Bid
+		result.source = priceSourceBid
 		result.contractedAt = now
 	}
 	return result
@@ -50,6 +87,7 @@ func (c *stockContractComponent) confirmContractItayoseMO(side Side, price *symb
 // confirmContractAuctionMO - オークション方式での成行注文の約定確認と約定した場合の結果
 //   買い注文で売り気配値があれば売り気配値で約定する
 //   売り注文で買い気配値があれば買い気配値で約定する
+//   板があれば、板を良い順に約定していく
 func (c *stockContractComponent) confirmContractAuctionMO(side Side, price *symbolPrice, now time.Time) *confirmContractResult {
 	result := &confirmContractResult{isContracted: false}
 	if price == nil {
@@ -58,17 +96,22 @@ func (c *stockContractComponent) confirmContractAuctionMO(side Side, price *symb
 	if side == SideBuy && price.Ask > 0 {
 		result.isContracted = true
 		result.price = price.Ask
+		result.source = priceSourceAsk
 		result.contractedAt = now
+		result.levels = price.AskBoard
 	} else if side == SideSell && price.Bid > 0 {
 		result.isContracted = true
 		result.price = price.Bid
+		result.source = priceSourceBid
 		result.contractedAt = now
+		result.levels = price.BidBoard
 	}
 	return result
 }
 
 // confirmContractItayoseLO - 板寄せ方式での指値注文の約定確認と約定した場合の結果
-//   板寄せ方式では、5s以内の現値があれば現値で約定確認を行なう
+//   気配から求めた板寄せの約定値段があれば、買い注文は約定値段が指値価格以下、売り注文は約定値段が指値価格以上なら約定値段で約定する
+//   板寄せの約定値段がなければ、5s以内の現値があれば現値で約定確認を行なう
 //   買い注文の場合、現値が指値価格以下なら約定する
 //   売り注文の場合、現値が指値価格以上なら約定する
 //   5s以内の現値がなくても、買い注文で売り気配値があり、指値価格より売り気配値が安ければ約定する
@@ -78,24 +121,35 @@ func (c *stockContractComponent) confirmContractItayoseLO(side Side, limitPrice
 	if price == nil {
 		return result
 	}
-	if price.Price > 0 && now.Add(-5*time.Second).Before(price.PriceTime) {
+	if price.itayosePrice > 0 {
+		if (side == SideBuy && limitPrice >= price.itayosePrice) || (side == SideSell && limitPrice <= price.itayosePrice) {
+			result.isContracted = true
+			result.price = price.itayosePrice
+			result.source = itayoseSource(side)
+			result.contractedAt = now
+		}
+	} else if price.Price > 0 && now.Add(-5*time.Second).Before(price.PriceTime) {
 		if side == SideBuy && limitPrice >= price.Price {
 			result.isContracted = true
 			result.price = price.Price
+			result.source = priceSourcePrice
 			result.contractedAt = now
 		} else if side == SideSell && limitPrice <= price.Price {
 			result.isContracted = true
 			result.price = price.Price
+			result.source = priceSourcePrice
 			result.contractedAt = now
 		}
 	} else {
 		if side == SideBuy && price.Ask > 0 && limitPrice >= price.Ask {
 			result.isContracted = true
 			result.price = price.Ask
+			result.source = priceSourceAsk
 			result.contractedAt = now
 		} else if side == SideSell && price.Bid > 0 && limitPrice <= price.Bid {
 			result.isContracted = true
 			result.price = price.Bid
+			result.source = priceSourceBid
 			result.contractedAt = now
 		}
 	}
@@ -105,6 +159,7 @@ func (c *stockContractComponent) confirmContractItayoseLO(side Side, limitPrice
 // confirmContractAuctionLO - オークション方式での指値注文の約定確認と約定した場合の結果
 //   買い注文で売り気配値があり、指値価格より売り気配値が安ければ約定する
 //   売り注文で買い気配値があり、指値価格より買い気配値が高ければ約定する
+//   初回確認で板があれば、指値価格までの板を良い順に約定していく
 func (c *stockContractComponent) confirmContractAuctionLO(side Side, limitPrice float64, isConfirmed bool, price *symbolPrice, now time.Time) *confirmContractResult {
 	result := &confirmContractResult{isContracted: false}
 	if price == nil {
@@ -113,25 +168,41 @@ func (c *stockContractComponent) confirmContractAuctionLO(side Side, limitPrice
 	if side == SideBuy && price.Ask > 0 && limitPrice > price.Ask {
 		result.isContracted = true
 		result.price = limitPrice
+		result.source = priceSourceAsk
 		result.contractedAt = now
 
 		// 初回確認なら板で約定できる
 		if !isConfirmed {
 			result.price = price.Ask
+			result.levels = c.boardLevelsWithin(side, limitPrice, price.AskBoard)
 		}
 	} else if side == SideSell && price.Bid > 0 && limitPrice < price.Bid {
 		result.isContracted = true
 		result.price = limitPrice
+		result.source = priceSourceBid
 		result.contractedAt = now
 
 		// 初回確認なら板で約定できる
 		if !isConfirmed {
 			result.price = price.Bid
+			result.levels = c.boardLevelsWithin(side, limitPrice, price.BidBoard)
 		}
 	}
 	return result
 }
 
+// boardLevelsWithin - 板のうち、指値価格で約定できる気配だけを返す
+func (c *stockContractComponent) boardLevelsWithin(side Side, limitPrice float64, board []BoardLevel) []BoardLevel {
+	var levels []BoardLevel
+	for _, l := range board {
+		if (side == SideBuy && l.Price > limitPrice) || (side == SideSell && l.Price < limitPrice) {
+			break
+		}
+		levels = append(levels, l)
+	}
+	return levels
+}
+
 // confirmOrderContract - 注文の要素と価格情報を受け取り、約定可能かのチェックし、約定したらどんな約定状態になるのかを返す
 func (c *stockContractComponent) confirmOrderContract(executionCondition StockExecutionCondition, side Side, limitPrice float64, isConfirmed bool, price *symbolPrice, now time.Time) *confirmContractResult {
 	// 価格情報がなければ約定しない, 約定可能時間帯じゃなければ約定しない
@@ -239,7 +310,7 @@ func (c *stockContractComponent) confirmOrderContract(executionCondition StockEx
 	case StockExecutionConditionFunariM: // 不成(前場)
 		// 前場の引けでは引成注文と同じ
 		// 前場の引け以外は通常の指値と同じ
-		if contractableMorningSessionCloseTime.between(now) {
+		if c.schedule.isClosing(ExchangeTypeStock, SessionMorning, now) {
 			return c.confirmContractItayoseMO(side, price, now)
 		} else {
 			switch price.kind {
@@ -252,7 +323,7 @@ func (c *stockContractComponent) confirmOrderContract(executionCondition StockEx
 	case StockExecutionConditionFunariA: // 不成(後場)
 		// 後場の引けでは引成注文と同じ
 		// 後場の引け以外は通常の指値と同じ
-		if contractableAfternoonSessionCloseTime.between(now) {
+		if c.schedule.isClosing(ExchangeTypeStock, SessionAfternoon, now) {
 			return c.confirmContractItayoseMO(side, price, now)
 		} else {
 			switch price.kind {
@@ -278,7 +349,10 @@ func (c *stockContractComponent) confirmStockOrderContract(order *stockOrder, pr
 
 	res := c.confirmOrderContract(order.executionCondition(), order.Side, order.limitPrice(), order.ConfirmingCount > 0, price, now)
 	order.ConfirmingCount++
-	return res
+	if price.isLimitLocked(order.Side) {
+		return c.limitProRataQuantity(res, order.Side, order.OrderQuantity-order.ContractedQuantity, price)
+	}
+	return c.limitContractQuantity(res, order.OrderQuantity-order.ContractedQuantity, price)
 }
 
 // confirmMarginOrderContract - 信用注文の約定確認し、約定したらどんな約定状態になるのかを返す
@@ -290,5 +364,137 @@ func (c *stockContractComponent) confirmMarginOrderContract(order *marginOrder,
 
 	res := c.confirmOrderContract(order.executionCondition(), order.Side, order.limitPrice(), order.ConfirmingCount > 0, price, now)
 	order.ConfirmingCount++
-	return res
+	if price.isLimitLocked(order.Side) {
+		return c.limitProRataQuantity(res, order.Side, order.OrderQuantity-order.ContractedQuantity, price)
+	}
+	return c.limitContractQuantity(res, order.OrderQuantity-order.ContractedQuantity, price)
+}
+
+// confirmFutureContract - 先物注文の要素と価格情報を受け取り、約定可能かのチェックし、約定したらどんな約定状態になるのかを返す
+//   先物も株式と同じく、寄りと引けは板寄せ方式、ザラバはオークション方式で約定する
+func (c *stockContractComponent) confirmFutureContract(executionCondition FutureExecutionCondition, side Side, limitPrice float64, isConfirmed bool, price *symbolPrice, now time.Time) *confirmContractResult {
+	// 価格情報がなければ約定しない, 約定可能時間帯じゃなければ約定しない
+	if price == nil || !c.isContractableFutureTime(executionCondition, now) {
+		return &confirmContractResult{isContracted: false}
+	}
+
+	switch executionCondition {
+	case FutureExecutionConditionMO: // 成行
+		switch price.kind {
+		case PriceKindOpening, PriceKindClosing, PriceKindOpeningAndClosing:
+			return c.confirmContractItayoseMO(side, price, now)
+		case PriceKindRegular:
+			return c.confirmContractAuctionMO(side, price, now)
+		}
+	case FutureExecutionConditionLO: // 指値
+		switch price.kind {
+		case PriceKindOpening, PriceKindClosing, PriceKindOpeningAndClosing:
+			return c.confirmContractItayoseLO(side, limitPrice, price, now)
+		case PriceKindRegular:
+			return c.confirmContractAuctionLO(side, limitPrice, isConfirmed, price, now)
+		}
+	case FutureExecutionConditionMOC: // 引成
+		// 引け以外では約定確認されないので、引けの価格情報なら約定する
+		if price.kind == PriceKindClosing || price.kind == PriceKindOpeningAndClosing {
+			return c.confirmContractItayoseMO(side, price, now)
+		}
+	case FutureExecutionConditionLOC: // 引指
+		if price.kind == PriceKindClosing || price.kind == PriceKindOpeningAndClosing {
+			return c.confirmContractItayoseLO(side, limitPrice, price, now)
+		}
+
+		// 逆指値での約定確認はない
+		//   逆指値発動後は他の執行条件に則った方法で約定する
+	}
+	return &confirmContractResult{isContracted: false}
+}
+
+// confirmFutureOrderContract - 先物注文の約定確認し、約定したらどんな約定状態になるのかを返す
+func (c *stockContractComponent) confirmFutureOrderContract(order *futureOrder, price *symbolPrice, now time.Time) *confirmContractResult {
+	// 注文がnil, 価格情報がnil, 注文と価格情報の銘柄が一致しない, 注文が約定可能な状態じゃない, 約定可能時間でなければ約定しない のいずれかの場合、約定しない
+	if order == nil || price == nil || order.SymbolCode != price.SymbolCode || !order.OrderStatus.IsContractable() || !c.isContractableFutureTime(order.executionCondition(), now) {
+		return &confirmContractResult{isContracted: false}
+	}
+
+	res := c.confirmFutureContract(order.executionCondition(), order.Side, order.limitPrice(), order.ConfirmingCount > 0, price, now)
+	order.ConfirmingCount++
+	return c.limitContractQuantity(res, order.OrderQuantity-order.ContractedQuantity, price)
+}
+
+// limitContractQuantity - 約定する数量を、約定の根拠になった価格の出来高や気配数量で約定できる数量までに制限する
+//   約定できる数量が残っていなければ約定しない
+func (c *stockContractComponent) limitContractQuantity(result *confirmContractResult, quantity float64, price *symbolPrice) *confirmContractResult {
+	if result == nil || !result.isContracted || price == nil {
+		return result
+	}
+
+	// 板があれば、板を良い順に約定できるだけ約定していく
+	if len(result.levels) > 0 {
+		return c.limitBoardContractQuantity(result, quantity, price)
+	}
+
+	result.quantity = price.contractableQuantity(result.source, quantity)
+	if result.quantity <= 0 {
+		return &confirmContractResult{isContracted: false}
+	}
+	price.contract(result.source, result.quantity)
+	return result
+}
+
+// limitProRataQuantity - ストップ高の買い注文とストップ安の売り注文を、引けの比例配分で約定できる数量に制限する
+//   ザラバでは約定せず、引けの出来高を、約定しなかった気配数量と合わせた注文数量の比率で配分する
+//   気配数量の情報がなければすべて配分されたものとし、1株未満は切り捨てる
+func (c *stockContractComponent) limitProRataQuantity(result *confirmContractResult, side Side, quantity float64, price *symbolPrice) *confirmContractResult {
+	if result == nil || !result.isContracted || price == nil {
+		return result
+	}
+	if (price.kind != PriceKindClosing && price.kind != PriceKindOpeningAndClosing) || price.Volume <= 0 {
+		return &confirmContractResult{isContracted: false}
+	}
+
+	// ストップ高なら買気配、ストップ安なら売気配に、約定しなかった注文が残っている
+	remaining := price.BidQuantity
+	if side == SideSell {
+		remaining = price.AskQuantity
+	}
+	ratio := 1.0
+	if remaining > 0 {
+		ratio = price.Volume / (price.Volume + remaining)
+	}
+
+	result.quantity = math.Floor(quantity * ratio)
+	if result.quantity <= 0 {
+		return &confirmContractResult{isContracted: false}
+	}
+	result.price = price.Price
+	result.source = priceSourcePrice
+	result.levels = nil
+	return result
+}
+
+// limitBoardContractQuantity - 約定する数量を、板の気配数量で約定できる数量までに制限し、気配値ごとの約定に分ける
+func (c *stockContractComponent) limitBoardContractQuantity(result *confirmContractResult, quantity float64, price *symbolPrice) *confirmContractResult {
+	var fills []*contractFill
+	var total float64
+	for _, l := range result.levels {
+		if total >= quantity {
+			break
+		}
+
+		q := price.contractableBoardQuantity(result.source, l, quantity-total)
+		if q <= 0 {
+			continue
+		}
+		price.contractBoard(result.source, l.Price, q)
+		fills = append(fills, &contractFill{price: l.Price, quantity: q})
+		total += q
+	}
+	if total <= 0 {
+		return &confirmContractResult{isContracted: false}
+	}
+
+	result.price = fills[0].price
+	result.quantity = total
+	result.fills = fills
+	return result
 }
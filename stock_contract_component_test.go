@@ -11,6 +11,7 @@ type testStockContractComponent struct {
 	isContractableTime1         bool
 	confirmStockOrderContract1  *confirmContractResult
 	confirmMarginOrderContract1 *confirmContractResult
+	confirmFutureOrderContract1 *confirmContractResult
 }
 
 func (t *testStockContractComponent) isContractableTime(StockExecutionCondition, time.Time) bool {
@@ -22,11 +23,14 @@ func (t *testStockContractComponent) confirmStockOrderContract(*stockOrder, *sym
 func (t *testStockContractComponent) confirmMarginOrderContract(*marginOrder, *symbolPrice, time.Time) *confirmContractResult {
 	return t.confirmMarginOrderContract1
 }
+func (t *testStockContractComponent) confirmFutureOrderContract(*futureOrder, *symbolPrice, time.Time) *confirmContractResult {
+	return t.confirmFutureOrderContract1
+}
 
 func Test_newStockContractComponent(t *testing.T) {
 	t.Parallel()
-	want := &stockContractComponent{}
-	got := newStockContractComponent()
+	want := &stockContractComponent{schedule: defaultTradingSchedule}
+	got := newStockContractComponent(defaultTradingSchedule)
 
 	if !reflect.DeepEqual(want, got) {
 		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
@@ -35,8 +39,11 @@ func Test_newStockContractComponent(t *testing.T) {
 
 func Test_stockContractComponent_isContractableTime(t *testing.T) {
 	t.Parallel()
+	extended := newTradingSchedule(map[ExchangeType]SessionSchedule{ExchangeTypeStock: {Sessions: append(ExtendedStockSessionSchedule().Sessions,
+		TradingSession{Session: SessionNight, Open: 17 * time.Hour, Close: 23*time.Hour + 59*time.Minute})}})
 	tests := []struct {
 		name       string
+		schedule   *tradingSchedule
 		stockOrder *stockOrder
 		arg1       StockExecutionCondition
 		arg2       time.Time
@@ -83,13 +90,43 @@ func Test_stockContractComponent_isContractableTime(t *testing.T) {
 			arg1: StockExecutionConditionMOAC,
 			arg2: time.Date(0, 1, 1, 15, 0, 0, 0, time.Local),
 			want: true},
+		{name: "延長した取引時間で15:10にザラバで約定する注文であればtrue",
+			schedule: extended,
+			arg1:     StockExecutionConditionMO,
+			arg2:     time.Date(2024, 11, 5, 15, 10, 0, 0, time.Local),
+			want:     true},
+		{name: "延長した取引時間でクロージング・オークション中ならザラバで約定する注文でもfalse",
+			schedule: extended,
+			arg1:     StockExecutionConditionMO,
+			arg2:     time.Date(2024, 11, 5, 15, 27, 0, 0, time.Local),
+			want:     false},
+		{name: "延長した取引時間で15:00なら後場の引けで約定する注文でもfalse",
+			schedule: extended,
+			arg1:     StockExecutionConditionMOAC,
+			arg2:     time.Date(2024, 11, 5, 15, 0, 0, 0, time.Local),
+			want:     false},
+		{name: "延長した取引時間で15:30なら後場の引けで約定する注文であればtrue",
+			schedule: extended,
+			arg1:     StockExecutionConditionMOAC,
+			arg2:     time.Date(2024, 11, 5, 15, 30, 0, 0, time.Local),
+			want:     true},
+		{name: "前場と後場以外のセッションでザラバで約定する注文であればtrue",
+			schedule: extended,
+			arg1:     StockExecutionConditionLO,
+			arg2:     time.Date(2024, 11, 5, 20, 0, 0, 0, time.Local),
+			want:     true},
+		{name: "前場と後場以外のセッションで寄りで約定する注文であればfalse",
+			schedule: extended,
+			arg1:     StockExecutionConditionMOAO,
+			arg2:     time.Date(2024, 11, 5, 20, 0, 0, 0, time.Local),
+			want:     false},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			component := &stockContractComponent{}
+			component := &stockContractComponent{schedule: test.schedule}
 			got := component.isContractableTime(test.arg1, test.arg2)
 			if !reflect.DeepEqual(test.want, got) {
 				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
@@ -119,6 +156,7 @@ func Test_stockContractComponent_confirmContractItayoseMO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        1000,
+				source:       priceSourceAsk,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
 		{name: "現値がなく、買い注文でも、売り気配値がなければ約定しない",
@@ -133,6 +171,7 @@ func Test_stockContractComponent_confirmContractItayoseMO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        900,
+				source:       priceSourceBid,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
 		{name: "現値がなく、売り注文でも、買い気配値がなければ約定しない",
@@ -152,6 +191,27 @@ func Test_stockContractComponent_confirmContractItayoseMO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        1100,
+				source:       priceSourcePrice,
+				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+			}},
+		{name: "板寄せの約定値段があれば、現値があっても、買い注文は約定値段で約定する",
+			arg1: SideBuy,
+			arg2: &symbolPrice{Price: 1100, PriceTime: time.Date(2021, 5, 12, 10, 59, 56, 0, time.Local), Ask: 1110, itayosePrice: 1105, itayoseQuantity: 300},
+			arg3: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+			want: &confirmContractResult{
+				isContracted: true,
+				price:        1105,
+				source:       priceSourceItayoseBuy,
+				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+			}},
+		{name: "板寄せの約定値段があれば、売り注文は約定値段で約定する",
+			arg1: SideSell,
+			arg2: &symbolPrice{Bid: 1100, itayosePrice: 1105, itayoseQuantity: 300},
+			arg3: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+			want: &confirmContractResult{
+				isContracted: true,
+				price:        1105,
+				source:       priceSourceItayoseSell,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
 	}
@@ -190,6 +250,7 @@ func Test_stockContractComponent_confirmContractAuctionMO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        1000,
+				source:       priceSourceAsk,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
 		{name: "買い注文でも、売り気配値がなければ約定しない",
@@ -204,6 +265,7 @@ func Test_stockContractComponent_confirmContractAuctionMO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        900,
+				source:       priceSourceBid,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
 		{name: "売り注文でも、買い気配値がなければ約定しない",
@@ -211,6 +273,28 @@ func Test_stockContractComponent_confirmContractAuctionMO(t *testing.T) {
 			arg2: &symbolPrice{},
 			arg3: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			want: &confirmContractResult{isContracted: false}},
+		{name: "買い注文で売り板があれば、売り板を約定候補にする",
+			arg1: SideBuy,
+			arg2: &symbolPrice{Ask: 1000, AskBoard: []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 1001, Quantity: 200}}, BidBoard: []BoardLevel{{Price: 999, Quantity: 100}}},
+			arg3: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+			want: &confirmContractResult{
+				isContracted: true,
+				price:        1000,
+				source:       priceSourceAsk,
+				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+				levels:       []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 1001, Quantity: 200}},
+			}},
+		{name: "売り注文で買い板があれば、買い板を約定候補にする",
+			arg1: SideSell,
+			arg2: &symbolPrice{Bid: 999, AskBoard: []BoardLevel{{Price: 1000, Quantity: 100}}, BidBoard: []BoardLevel{{Price: 999, Quantity: 100}, {Price: 998, Quantity: 200}}},
+			arg3: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+			want: &confirmContractResult{
+				isContracted: true,
+				price:        999,
+				source:       priceSourceBid,
+				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+				levels:       []BoardLevel{{Price: 999, Quantity: 100}, {Price: 998, Quantity: 200}},
+			}},
 	}
 
 	for _, test := range tests {
@@ -250,6 +334,7 @@ func Test_stockContractComponent_confirmContractItayoseLO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        1000,
+				source:       priceSourceAsk,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
 		{name: "現値がなく、買い注文で、売り気配値があり、指値が売り気配値と同じなら、売り気配値で約定する",
@@ -260,6 +345,7 @@ func Test_stockContractComponent_confirmContractItayoseLO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        1000,
+				source:       priceSourceAsk,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
 		{name: "現値がなく、買い注文で、売り気配値があり、指値が売り気配値より安いなら、約定しない",
@@ -288,6 +374,7 @@ func Test_stockContractComponent_confirmContractItayoseLO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        1000,
+				source:       priceSourceBid,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
 		{name: "現値がなく、売り注文で、買い気配値があり、指値が買い気配値より安いなら、買い気配値で約定する",
@@ -298,6 +385,7 @@ func Test_stockContractComponent_confirmContractItayoseLO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        1000,
+				source:       priceSourceBid,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
 		{name: "現値がなく、売り注文で、買い気配値がなければ、約定しない",
@@ -320,6 +408,7 @@ func Test_stockContractComponent_confirmContractItayoseLO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        1000,
+				source:       priceSourcePrice,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
 		{name: "現値があり、現値時刻が5s以内で、買い注文で、指値が現値と同じなら、現値で約定する",
@@ -330,6 +419,7 @@ func Test_stockContractComponent_confirmContractItayoseLO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        1000,
+				source:       priceSourcePrice,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
 		{name: "現値があり、現値時刻が5s以内で、買い注文で、指値が現値より安いなら、約定しない",
@@ -352,6 +442,7 @@ func Test_stockContractComponent_confirmContractItayoseLO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        1000,
+				source:       priceSourcePrice,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
 		{name: "現値があり、現値時刻が5s以内で、売り注文で、指値が現値より安いなら、現値で約定する",
@@ -362,8 +453,43 @@ func Test_stockContractComponent_confirmContractItayoseLO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        1000,
+				source:       priceSourcePrice,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
+		{name: "板寄せの約定値段があり、買い注文で、指値が約定値段以上なら、約定値段で約定する",
+			arg1: SideBuy,
+			arg2: 1105,
+			arg3: &symbolPrice{Price: 1100, PriceTime: time.Date(2021, 5, 12, 10, 59, 56, 0, time.Local), itayosePrice: 1105, itayoseQuantity: 300},
+			arg4: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+			want: &confirmContractResult{
+				isContracted: true,
+				price:        1105,
+				source:       priceSourceItayoseBuy,
+				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+			}},
+		{name: "板寄せの約定値段があり、買い注文で、指値が約定値段より安ければ、現値で約定できても約定しない",
+			arg1: SideBuy,
+			arg2: 1100,
+			arg3: &symbolPrice{Price: 1100, PriceTime: time.Date(2021, 5, 12, 10, 59, 56, 0, time.Local), itayosePrice: 1105, itayoseQuantity: 300},
+			arg4: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+			want: &confirmContractResult{isContracted: false}},
+		{name: "板寄せの約定値段があり、売り注文で、指値が約定値段以下なら、約定値段で約定する",
+			arg1: SideSell,
+			arg2: 1100,
+			arg3: &symbolPrice{itayosePrice: 1105, itayoseQuantity: 300},
+			arg4: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+			want: &confirmContractResult{
+				isContracted: true,
+				price:        1105,
+				source:       priceSourceItayoseSell,
+				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+			}},
+		{name: "板寄せの約定値段があり、売り注文で、指値が約定値段より高ければ約定しない",
+			arg1: SideSell,
+			arg2: 1110,
+			arg3: &symbolPrice{Bid: 1110, itayosePrice: 1105, itayoseQuantity: 300},
+			arg4: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+			want: &confirmContractResult{isContracted: false}},
 	}
 
 	for _, test := range tests {
@@ -406,6 +532,7 @@ func Test_stockContractComponent_confirmContractAuctionLO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        1001,
+				source:       priceSourceAsk,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
 		{name: "買い注文で、売り気配値があり、指値が売り気配値より高く、初回約定確認なら、気配値で約定する",
@@ -417,6 +544,7 @@ func Test_stockContractComponent_confirmContractAuctionLO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        1000,
+				source:       priceSourceAsk,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
 		{name: "買い注文で、売り気配値があり、指値が売り気配値と同じなら、約定しない",
@@ -463,6 +591,7 @@ func Test_stockContractComponent_confirmContractAuctionLO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        999,
+				source:       priceSourceBid,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
 		{name: "売り注文で、買い気配値があり、指値が買い気配値でより安く、初回約定確認なら、板で約定する",
@@ -474,6 +603,7 @@ func Test_stockContractComponent_confirmContractAuctionLO(t *testing.T) {
 			want: &confirmContractResult{
 				isContracted: true,
 				price:        1000,
+				source:       priceSourceBid,
 				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			}},
 		{name: "売り注文で、買い気配値がなければ、約定しない",
@@ -483,6 +613,44 @@ func Test_stockContractComponent_confirmContractAuctionLO(t *testing.T) {
 			arg4: &symbolPrice{},
 			arg5: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
 			want: &confirmContractResult{isContracted: false}},
+		{name: "買い注文で、初回約定確認で売り板があれば、指値以下の売り板を約定候補にする",
+			arg1: SideBuy,
+			arg2: 1001,
+			arg3: false,
+			arg4: &symbolPrice{Ask: 1000, AskBoard: []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 1001, Quantity: 100}, {Price: 1002, Quantity: 100}}},
+			arg5: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+			want: &confirmContractResult{
+				isContracted: true,
+				price:        1000,
+				source:       priceSourceAsk,
+				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+				levels:       []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 1001, Quantity: 100}},
+			}},
+		{name: "売り注文で、初回約定確認で買い板があれば、指値以上の買い板を約定候補にする",
+			arg1: SideSell,
+			arg2: 999,
+			arg3: false,
+			arg4: &symbolPrice{Bid: 1000, BidBoard: []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 999, Quantity: 100}, {Price: 998, Quantity: 100}}},
+			arg5: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+			want: &confirmContractResult{
+				isContracted: true,
+				price:        1000,
+				source:       priceSourceBid,
+				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+				levels:       []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 999, Quantity: 100}},
+			}},
+		{name: "買い注文で、初回約定確認でなければ、売り板があっても指値で約定する",
+			arg1: SideBuy,
+			arg2: 1001,
+			arg3: true,
+			arg4: &symbolPrice{Ask: 1000, AskBoard: []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 1001, Quantity: 100}}},
+			arg5: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+			want: &confirmContractResult{
+				isContracted: true,
+				price:        1001,
+				source:       priceSourceAsk,
+				contractedAt: time.Date(2021, 5, 12, 11, 0, 0, 0, time.Local),
+			}},
 	}
 
 	for _, test := range tests {
@@ -521,7 +689,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local), kind: PriceKindOpening},
 			arg6: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
 		{name: "成行が引け価格で約定する",
 			arg1: StockExecutionConditionMO,
 			arg2: SideBuy,
@@ -529,7 +697,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 15, 0, 2, 0, time.Local), kind: PriceKindClosing},
 			arg6: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local)}},
 		{name: "成行がザラバで約定する",
 			arg1: StockExecutionConditionMO,
 			arg2: SideBuy,
@@ -537,7 +705,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Ask: 1000, kind: PriceKindRegular},
 			arg6: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourceAsk, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
 		{name: "成行がタイミング不明なら約定しない",
 			arg1: StockExecutionConditionMO,
 			arg2: SideBuy,
@@ -553,7 +721,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local), kind: PriceKindOpening},
 			arg6: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
 		{name: "寄成前場が2回目以降の確認では約定しない",
 			arg1: StockExecutionConditionMOMO,
 			arg2: SideBuy,
@@ -577,7 +745,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local), kind: PriceKindOpening},
 			arg6: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local)}},
 		{name: "寄成後場が2回目以降の確認では約定しない",
 			arg1: StockExecutionConditionMOAO,
 			arg2: SideBuy,
@@ -601,7 +769,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 11, 30, 0, 0, time.Local), kind: PriceKindClosing},
 			arg6: time.Date(2021, 5, 12, 11, 30, 3, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 11, 30, 3, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 11, 30, 3, 0, time.Local)}},
 		{name: "引成前場が2回目以降の確認では約定しない",
 			arg1: StockExecutionConditionMOMC,
 			arg2: SideBuy,
@@ -625,7 +793,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 15, 0, 0, 0, time.Local), kind: PriceKindClosing},
 			arg6: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local)}},
 		{name: "引成後場が2回目以降の確認では約定しない",
 			arg1: StockExecutionConditionMOAC,
 			arg2: SideBuy,
@@ -650,7 +818,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local), kind: PriceKindOpening},
 			arg6: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
 		{name: "IOC成行が引け価格で約定する",
 			arg1: StockExecutionConditionIOCMO,
 			arg2: SideBuy,
@@ -658,7 +826,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 15, 0, 2, 0, time.Local), kind: PriceKindClosing},
 			arg6: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local)}},
 		{name: "IOC成行がザラバで約定する",
 			arg1: StockExecutionConditionIOCMO,
 			arg2: SideBuy,
@@ -666,7 +834,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Ask: 1000, kind: PriceKindRegular},
 			arg6: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourceAsk, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
 		{name: "IOC成行がタイミング不明なら約定しない",
 			arg1: StockExecutionConditionIOCMO,
 			arg2: SideBuy,
@@ -690,7 +858,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local), kind: PriceKindOpening},
 			arg6: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
 		{name: "指値が引け価格で約定する",
 			arg1: StockExecutionConditionLO,
 			arg2: SideBuy,
@@ -698,7 +866,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 15, 0, 2, 0, time.Local), kind: PriceKindClosing},
 			arg6: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local)}},
 		{name: "指値がザラバで約定する",
 			arg1: StockExecutionConditionLO,
 			arg2: SideBuy,
@@ -706,7 +874,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: true,
 			arg5: &symbolPrice{SymbolCode: "1234", Ask: 990, kind: PriceKindRegular},
 			arg6: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourceAsk, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
 		{name: "指値がタイミング不明なら約定しない",
 			arg1: StockExecutionConditionLO,
 			arg2: SideBuy,
@@ -722,7 +890,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local), kind: PriceKindOpening},
 			arg6: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
 		{name: "寄指前場が2回目以降の確認では約定しない",
 			arg1: StockExecutionConditionLOMO,
 			arg2: SideBuy,
@@ -746,7 +914,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local), kind: PriceKindOpening},
 			arg6: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local)}},
 		{name: "寄指値後場が2回目以降の確認では約定しない",
 			arg1: StockExecutionConditionLOMO,
 			arg2: SideBuy,
@@ -770,7 +938,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 11, 30, 0, 0, time.Local), kind: PriceKindClosing},
 			arg6: time.Date(2021, 5, 12, 11, 30, 3, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 11, 30, 3, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 11, 30, 3, 0, time.Local)}},
 		{name: "引指前場が2回目以降の確認では約定しない",
 			arg1: StockExecutionConditionLOMC,
 			arg2: SideBuy,
@@ -794,7 +962,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 15, 0, 0, 0, time.Local), kind: PriceKindClosing},
 			arg6: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local)}},
 		{name: "引指後場が2回目以降の確認では約定しない",
 			arg1: StockExecutionConditionLOAC,
 			arg2: SideBuy,
@@ -818,7 +986,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local), kind: PriceKindOpening},
 			arg6: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
 		{name: "不成前場は前場のザラバでは指値で約定する",
 			arg1: StockExecutionConditionFunariM,
 			arg2: SideBuy,
@@ -826,7 +994,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Ask: 990, kind: PriceKindRegular},
 			arg6: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 990, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 990, source: priceSourceAsk, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
 		{name: "不成前場は前場の引けではオークションの成行で約定する",
 			arg1: StockExecutionConditionFunariM,
 			arg2: SideBuy,
@@ -834,7 +1002,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1200, PriceTime: time.Date(2021, 5, 12, 11, 30, 0, 0, time.Local), kind: PriceKindClosing},
 			arg6: time.Date(2021, 5, 12, 11, 30, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1200, contractedAt: time.Date(2021, 5, 12, 11, 30, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1200, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 11, 30, 0, 0, time.Local)}},
 		{name: "不成前場は後場の寄りではオークションの指値で約定する",
 			arg1: StockExecutionConditionFunariM,
 			arg2: SideBuy,
@@ -842,7 +1010,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local), kind: PriceKindOpening},
 			arg6: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local)}},
 		{name: "不成前場は後場のザラバでは指値で約定する",
 			arg1: StockExecutionConditionFunariM,
 			arg2: SideBuy,
@@ -850,7 +1018,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Ask: 990, kind: PriceKindRegular},
 			arg6: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 990, contractedAt: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 990, source: priceSourceAsk, contractedAt: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local)}},
 		{name: "不成前場は後場の引けではオークションの指値で約定する",
 			arg1: StockExecutionConditionFunariM,
 			arg2: SideBuy,
@@ -858,7 +1026,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 990, PriceTime: time.Date(2021, 5, 12, 15, 0, 0, 0, time.Local), kind: PriceKindClosing},
 			arg6: time.Date(2021, 5, 12, 15, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 990, contractedAt: time.Date(2021, 5, 12, 15, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 990, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 15, 0, 0, 0, time.Local)}},
 		{name: "不成後場は前場の寄りではオークションの指値で約定する",
 			arg1: StockExecutionConditionFunariA,
 			arg2: SideBuy,
@@ -866,7 +1034,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local), kind: PriceKindOpening},
 			arg6: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
 		{name: "不成後場は前場のザラバでは指値で約定する",
 			arg1: StockExecutionConditionFunariA,
 			arg2: SideBuy,
@@ -874,7 +1042,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Ask: 990, kind: PriceKindRegular},
 			arg6: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 990, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 990, source: priceSourceAsk, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
 		{name: "不成後場は前場の引けではオークションの指値で約定する",
 			arg1: StockExecutionConditionFunariA,
 			arg2: SideBuy,
@@ -882,7 +1050,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 990, PriceTime: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local), kind: PriceKindClosing},
 			arg6: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 990, contractedAt: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 990, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local)}},
 		{name: "不成後場は後場の寄りではオークションの指値で約定する",
 			arg1: StockExecutionConditionFunariA,
 			arg2: SideBuy,
@@ -890,7 +1058,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local), kind: PriceKindOpening},
 			arg6: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local)}},
 		{name: "不成後場は後場のザラバでは指値で約定する",
 			arg1: StockExecutionConditionFunariA,
 			arg2: SideBuy,
@@ -898,7 +1066,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Ask: 990, kind: PriceKindRegular},
 			arg6: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 990, contractedAt: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 990, source: priceSourceAsk, contractedAt: time.Date(2021, 5, 12, 12, 30, 0, 0, time.Local)}},
 		{name: "不成後場は後場の引けではオークションの成行で約定する",
 			arg1: StockExecutionConditionFunariA,
 			arg2: SideBuy,
@@ -906,7 +1074,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1200, PriceTime: time.Date(2021, 5, 12, 15, 0, 0, 0, time.Local), kind: PriceKindClosing},
 			arg6: time.Date(2021, 5, 12, 15, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1200, contractedAt: time.Date(2021, 5, 12, 15, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1200, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 15, 0, 0, 0, time.Local)}},
 		{name: "執行条件が逆指値の場合は約定しない",
 			arg1: StockExecutionConditionStop,
 			arg2: SideBuy,
@@ -922,7 +1090,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local), kind: PriceKindOpening},
 			arg6: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
 		{name: "IOC指値が引け価格で約定する",
 			arg1: StockExecutionConditionIOCLO,
 			arg2: SideBuy,
@@ -930,7 +1098,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Price: 1000, PriceTime: time.Date(2021, 5, 12, 15, 0, 2, 0, time.Local), kind: PriceKindClosing},
 			arg6: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice, contractedAt: time.Date(2021, 5, 12, 15, 0, 3, 0, time.Local)}},
 		{name: "IOC指値がザラバで約定する",
 			arg1: StockExecutionConditionIOCLO,
 			arg2: SideBuy,
@@ -938,7 +1106,7 @@ func Test_stockContractComponent_confirmOrderContract(t *testing.T) {
 			arg4: false,
 			arg5: &symbolPrice{SymbolCode: "1234", Ask: 990, kind: PriceKindRegular},
 			arg6: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 990, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 990, source: priceSourceAsk, contractedAt: time.Date(2021, 5, 12, 9, 0, 0, 0, time.Local)}},
 		{name: "IOC指値がタイミング不明なら約定しない",
 			arg1: StockExecutionConditionIOCLO,
 			arg2: SideBuy,
@@ -1003,7 +1171,12 @@ func Test_stockContractComponent_confirmStockOrderContract(t *testing.T) {
 			arg1: &stockOrder{SymbolCode: "1234", OrderStatus: OrderStatusInOrder, Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, OrderQuantity: 1},
 			arg2: &symbolPrice{SymbolCode: "1234", Ask: 1000, AskTime: time.Date(2021, 8, 13, 9, 0, 0, 0, time.Local), kind: PriceKindRegular},
 			arg3: time.Date(2021, 8, 13, 9, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 8, 13, 9, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, quantity: 1, source: priceSourceAsk, contractedAt: time.Date(2021, 8, 13, 9, 0, 0, 0, time.Local)}},
+		{name: "ストップ高ならザラバの買いは約定しない",
+			arg1: &stockOrder{SymbolCode: "1234", OrderStatus: OrderStatusInOrder, Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, OrderQuantity: 1},
+			arg2: &symbolPrice{SymbolCode: "1234", Price: 1300, PriceTime: time.Date(2021, 8, 13, 9, 0, 0, 0, time.Local), Ask: 1300, AskTime: time.Date(2021, 8, 13, 9, 0, 0, 0, time.Local), kind: PriceKindRegular, lowerLimit: 700, upperLimit: 1300},
+			arg3: time.Date(2021, 8, 13, 9, 0, 0, 0, time.Local),
+			want: &confirmContractResult{isContracted: false}},
 	}
 
 	for _, test := range tests {
@@ -1052,7 +1225,12 @@ func Test_stockContractComponent_confirmMarginOrderContract(t *testing.T) {
 			arg1: &marginOrder{SymbolCode: "1234", OrderStatus: OrderStatusInOrder, Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, OrderQuantity: 1},
 			arg2: &symbolPrice{SymbolCode: "1234", Ask: 1000, AskTime: time.Date(2021, 8, 13, 9, 0, 0, 0, time.Local), kind: PriceKindRegular},
 			arg3: time.Date(2021, 8, 13, 9, 0, 0, 0, time.Local),
-			want: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 8, 13, 9, 0, 0, 0, time.Local)}},
+			want: &confirmContractResult{isContracted: true, price: 1000, quantity: 1, source: priceSourceAsk, contractedAt: time.Date(2021, 8, 13, 9, 0, 0, 0, time.Local)}},
+		{name: "ストップ安なら引けの売りは比例配分で約定する",
+			arg1: &marginOrder{SymbolCode: "1234", OrderStatus: OrderStatusInOrder, Side: SideSell, ExecutionCondition: StockExecutionConditionMO, OrderQuantity: 300},
+			arg2: &symbolPrice{SymbolCode: "1234", Price: 700, PriceTime: time.Date(2021, 8, 13, 15, 0, 0, 0, time.Local), Volume: 1000, AskQuantity: 3000, kind: PriceKindClosing, lowerLimit: 700, upperLimit: 1300},
+			arg3: time.Date(2021, 8, 13, 15, 0, 0, 0, time.Local),
+			want: &confirmContractResult{isContracted: true, price: 700, quantity: 75, source: priceSourcePrice, contractedAt: time.Date(2021, 8, 13, 15, 0, 0, 0, time.Local)}},
 	}
 
 	for _, test := range tests {
@@ -1067,3 +1245,304 @@ func Test_stockContractComponent_confirmMarginOrderContract(t *testing.T) {
 		})
 	}
 }
+
+func Test_stockContractComponent_isContractableFutureTime(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		arg1 FutureExecutionCondition
+		arg2 time.Time
+		want bool
+	}{
+		{name: "日中のザラバで成行注文ならtrue",
+			arg1: FutureExecutionConditionMO,
+			arg2: time.Date(0, 1, 1, 10, 0, 0, 0, time.Local),
+			want: true},
+		{name: "夜間のザラバで指値注文ならtrue",
+			arg1: FutureExecutionConditionLO,
+			arg2: time.Date(0, 1, 1, 2, 0, 0, 0, time.Local),
+			want: true},
+		{name: "日中のザラバで引成注文ならfalse",
+			arg1: FutureExecutionConditionMOC,
+			arg2: time.Date(0, 1, 1, 10, 0, 0, 0, time.Local),
+			want: false},
+		{name: "日中の引けで引成注文ならtrue",
+			arg1: FutureExecutionConditionMOC,
+			arg2: time.Date(0, 1, 1, 15, 45, 0, 0, time.Local),
+			want: true},
+		{name: "夜間の引けで引指注文ならtrue",
+			arg1: FutureExecutionConditionLOC,
+			arg2: time.Date(0, 1, 1, 6, 0, 0, 0, time.Local),
+			want: true},
+		{name: "日中の引けで成行注文ならtrue",
+			arg1: FutureExecutionConditionMO,
+			arg2: time.Date(0, 1, 1, 15, 45, 0, 0, time.Local),
+			want: true},
+		{name: "日中と夜間の間なら成行注文でもfalse",
+			arg1: FutureExecutionConditionMO,
+			arg2: time.Date(0, 1, 1, 16, 0, 0, 0, time.Local),
+			want: false},
+		{name: "プレクロージングなら成行注文でもfalse",
+			arg1: FutureExecutionConditionMO,
+			arg2: time.Date(0, 1, 1, 15, 42, 0, 0, time.Local),
+			want: false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			component := &stockContractComponent{}
+			got := component.isContractableFutureTime(test.arg1, test.arg2)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_stockContractComponent_confirmFutureContract(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		arg1 FutureExecutionCondition
+		arg2 Side
+		arg3 float64
+		arg4 *symbolPrice
+		arg5 time.Time
+		want *confirmContractResult
+	}{
+		{name: "価格がnilなら約定しない",
+			arg1: FutureExecutionConditionMO,
+			arg2: SideBuy,
+			arg4: nil,
+			arg5: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local),
+			want: &confirmContractResult{isContracted: false}},
+		{name: "約定可能時間でなければ約定しない",
+			arg1: FutureExecutionConditionMO,
+			arg2: SideBuy,
+			arg4: &symbolPrice{Ask: 29000, kind: PriceKindRegular},
+			arg5: time.Date(2021, 6, 4, 16, 0, 0, 0, time.Local),
+			want: &confirmContractResult{isContracted: false}},
+		{name: "ザラバの成行は売り気配値で約定する",
+			arg1: FutureExecutionConditionMO,
+			arg2: SideBuy,
+			arg4: &symbolPrice{Ask: 29000, kind: PriceKindRegular},
+			arg5: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local),
+			want: &confirmContractResult{isContracted: true, price: 29000, source: priceSourceAsk, contractedAt: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local)}},
+		{name: "寄りの指値は現値で約定する",
+			arg1: FutureExecutionConditionLO,
+			arg2: SideSell,
+			arg3: 28900,
+			arg4: &symbolPrice{Price: 29000, PriceTime: time.Date(2021, 6, 4, 17, 0, 0, 0, time.Local), kind: PriceKindOpening},
+			arg5: time.Date(2021, 6, 4, 17, 0, 1, 0, time.Local),
+			want: &confirmContractResult{isContracted: true, price: 29000, source: priceSourcePrice, contractedAt: time.Date(2021, 6, 4, 17, 0, 1, 0, time.Local)}},
+		{name: "引成は引けの価格なら約定する",
+			arg1: FutureExecutionConditionMOC,
+			arg2: SideBuy,
+			arg4: &symbolPrice{Price: 29000, PriceTime: time.Date(2021, 6, 4, 15, 45, 0, 0, time.Local), kind: PriceKindClosing},
+			arg5: time.Date(2021, 6, 4, 15, 45, 1, 0, time.Local),
+			want: &confirmContractResult{isContracted: true, price: 29000, source: priceSourcePrice, contractedAt: time.Date(2021, 6, 4, 15, 45, 1, 0, time.Local)}},
+		{name: "引指は指値より不利な引けの価格なら約定しない",
+			arg1: FutureExecutionConditionLOC,
+			arg2: SideBuy,
+			arg3: 28900,
+			arg4: &symbolPrice{Price: 29000, PriceTime: time.Date(2021, 6, 4, 15, 45, 0, 0, time.Local), kind: PriceKindClosing},
+			arg5: time.Date(2021, 6, 4, 15, 45, 1, 0, time.Local),
+			want: &confirmContractResult{isContracted: false}},
+		{name: "逆指値は約定しない",
+			arg1: FutureExecutionConditionStop,
+			arg2: SideBuy,
+			arg4: &symbolPrice{Ask: 29000, kind: PriceKindRegular},
+			arg5: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local),
+			want: &confirmContractResult{isContracted: false}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			component := &stockContractComponent{}
+			got := component.confirmFutureContract(test.arg1, test.arg2, test.arg3, false, test.arg4, test.arg5)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_stockContractComponent_confirmFutureOrderContract(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		arg1 *futureOrder
+		arg2 *symbolPrice
+		arg3 time.Time
+		want *confirmContractResult
+	}{
+		{name: "注文がnilなら約定しない",
+			arg1: nil,
+			want: &confirmContractResult{isContracted: false}},
+		{name: "価格がnilなら約定しない",
+			arg1: &futureOrder{},
+			arg2: nil,
+			want: &confirmContractResult{isContracted: false}},
+		{name: "注文と価格の銘柄が一致しないなら約定しない",
+			arg1: &futureOrder{SymbolCode: "167060019"},
+			arg2: &symbolPrice{SymbolCode: "167060018"},
+			want: &confirmContractResult{isContracted: false}},
+		{name: "注文が約定可能な状態でないなら約定しない",
+			arg1: &futureOrder{SymbolCode: "167060019", OrderStatus: OrderStatusDone},
+			arg2: &symbolPrice{SymbolCode: "167060019"},
+			want: &confirmContractResult{isContracted: false}},
+		{name: "約定すれば出来高や気配数量で数量を制限した結果を返す",
+			arg1: &futureOrder{SymbolCode: "167060019", OrderStatus: OrderStatusInOrder, Side: SideBuy, ExecutionCondition: FutureExecutionConditionMO, OrderQuantity: 3},
+			arg2: &symbolPrice{SymbolCode: "167060019", Ask: 29000, AskQuantity: 2, AskTime: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local), kind: PriceKindRegular},
+			arg3: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local),
+			want: &confirmContractResult{isContracted: true, price: 29000, quantity: 2, source: priceSourceAsk, contractedAt: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local)}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			component := &stockContractComponent{}
+			got := component.confirmFutureOrderContract(test.arg1, test.arg2, test.arg3)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_stockContractComponent_limitContractQuantity(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		result    *confirmContractResult
+		quantity  float64
+		price     *symbolPrice
+		want      *confirmContractResult
+		wantPrice *symbolPrice
+	}{
+		{name: "約定しないならそのまま返す",
+			result:    &confirmContractResult{isContracted: false},
+			quantity:  100,
+			price:     &symbolPrice{AskQuantity: 100},
+			want:      &confirmContractResult{isContracted: false},
+			wantPrice: &symbolPrice{AskQuantity: 100}},
+		{name: "数量の情報がなければ指定した数量で約定する",
+			result:    &confirmContractResult{isContracted: true, price: 1000, source: priceSourceAsk},
+			quantity:  100,
+			price:     &symbolPrice{},
+			want:      &confirmContractResult{isContracted: true, price: 1000, quantity: 100, source: priceSourceAsk},
+			wantPrice: &symbolPrice{contractedAsk: 100}},
+		{name: "気配数量が足りなければ気配数量だけ約定する",
+			result:    &confirmContractResult{isContracted: true, price: 1000, source: priceSourceAsk},
+			quantity:  300,
+			price:     &symbolPrice{AskQuantity: 100},
+			want:      &confirmContractResult{isContracted: true, price: 1000, quantity: 100, source: priceSourceAsk},
+			wantPrice: &symbolPrice{AskQuantity: 100, contractedAsk: 100}},
+		{name: "出来高を使い切っていれば約定しない",
+			result:    &confirmContractResult{isContracted: true, price: 1000, source: priceSourcePrice},
+			quantity:  300,
+			price:     &symbolPrice{Volume: 100, contractedVolume: 100},
+			want:      &confirmContractResult{isContracted: false},
+			wantPrice: &symbolPrice{Volume: 100, contractedVolume: 100}},
+		{name: "板があれば、良い気配値から順に気配数量の分だけ約定する",
+			result:   &confirmContractResult{isContracted: true, price: 1000, source: priceSourceAsk, levels: []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 1001, Quantity: 100}, {Price: 1002, Quantity: 100}}},
+			quantity: 250,
+			price:    &symbolPrice{},
+			want: &confirmContractResult{isContracted: true, price: 1000, quantity: 250, source: priceSourceAsk,
+				levels: []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 1001, Quantity: 100}, {Price: 1002, Quantity: 100}},
+				fills:  []*contractFill{{price: 1000, quantity: 100}, {price: 1001, quantity: 100}, {price: 1002, quantity: 50}}},
+			wantPrice: &symbolPrice{contractedBoard: boardVolumes{priceSourceAsk: {1000: 100, 1001: 100, 1002: 50}}}},
+		{name: "板で約定済みの気配値は飛ばし、約定価格は最初に約定した気配値になる",
+			result:   &confirmContractResult{isContracted: true, price: 1000, source: priceSourceBid, levels: []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 999, Quantity: 100}}},
+			quantity: 300,
+			price:    &symbolPrice{contractedBoard: boardVolumes{priceSourceBid: {1000: 100}}},
+			want: &confirmContractResult{isContracted: true, price: 999, quantity: 100, source: priceSourceBid,
+				levels: []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 999, Quantity: 100}},
+				fills:  []*contractFill{{price: 999, quantity: 100}}},
+			wantPrice: &symbolPrice{contractedBoard: boardVolumes{priceSourceBid: {1000: 100, 999: 100}}}},
+		{name: "板の気配数量を使い切っていれば約定しない",
+			result:    &confirmContractResult{isContracted: true, price: 1000, source: priceSourceAsk, levels: []BoardLevel{{Price: 1000, Quantity: 100}}},
+			quantity:  300,
+			price:     &symbolPrice{contractedBoard: boardVolumes{priceSourceAsk: {1000: 100}}},
+			want:      &confirmContractResult{isContracted: false},
+			wantPrice: &symbolPrice{contractedBoard: boardVolumes{priceSourceAsk: {1000: 100}}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			component := &stockContractComponent{}
+			got := component.limitContractQuantity(test.result, test.quantity, test.price)
+			if !reflect.DeepEqual(test.want, got) || !reflect.DeepEqual(test.wantPrice, test.price) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want, test.wantPrice, got, test.price)
+			}
+		})
+	}
+}
+
+func Test_stockContractComponent_limitProRataQuantity(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		result   *confirmContractResult
+		side     Side
+		quantity float64
+		price    *symbolPrice
+		want     *confirmContractResult
+	}{
+		{name: "約定しないならそのまま返す",
+			result:   &confirmContractResult{isContracted: false},
+			side:     SideBuy,
+			quantity: 100,
+			price:    &symbolPrice{Price: 1300, Volume: 1000, kind: PriceKindClosing},
+			want:     &confirmContractResult{isContracted: false}},
+		{name: "引けでなければ約定しない",
+			result:   &confirmContractResult{isContracted: true, price: 1300, source: priceSourceAsk},
+			side:     SideBuy,
+			quantity: 100,
+			price:    &symbolPrice{Price: 1300, Volume: 1000, kind: PriceKindRegular},
+			want:     &confirmContractResult{isContracted: false}},
+		{name: "引けの出来高がなければ約定しない",
+			result:   &confirmContractResult{isContracted: true, price: 1300, source: priceSourcePrice},
+			side:     SideBuy,
+			quantity: 100,
+			price:    &symbolPrice{Price: 1300, kind: PriceKindClosing},
+			want:     &confirmContractResult{isContracted: false}},
+		{name: "買いは引けの出来高と買気配数量の比率で配分し、1株未満は切り捨てる",
+			result:   &confirmContractResult{isContracted: true, price: 1300, source: priceSourcePrice},
+			side:     SideBuy,
+			quantity: 100,
+			price:    &symbolPrice{Price: 1300, Volume: 1000, BidQuantity: 2000, kind: PriceKindClosing},
+			want:     &confirmContractResult{isContracted: true, price: 1300, quantity: 33, source: priceSourcePrice}},
+		{name: "気配数量の情報がなければすべて配分する",
+			result:   &confirmContractResult{isContracted: true, price: 700, source: priceSourceBid},
+			side:     SideSell,
+			quantity: 100,
+			price:    &symbolPrice{Price: 700, Volume: 1000, kind: PriceKindOpeningAndClosing},
+			want:     &confirmContractResult{isContracted: true, price: 700, quantity: 100, source: priceSourcePrice}},
+		{name: "配分が1株に満たなければ約定しない",
+			result:   &confirmContractResult{isContracted: true, price: 700, source: priceSourcePrice},
+			side:     SideSell,
+			quantity: 1,
+			price:    &symbolPrice{Price: 700, Volume: 100, AskQuantity: 900, kind: PriceKindClosing},
+			want:     &confirmContractResult{isContracted: false}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			component := &stockContractComponent{}
+			got := component.limitProRataQuantity(test.result, test.side, test.quantity, test.price)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
@@ -15,13 +15,17 @@ func getStockOrderStore() iStockOrderStore {
 	defer stockOrderStoreSingletonMutex.Unlock()
 
 	if stockOrderStoreSingleton == nil {
-		stockOrderStoreSingleton = &stockOrderStore{
-			store: map[string]*stockOrder{},
-		}
+		stockOrderStoreSingleton = newStockOrderStore()
 	}
 	return stockOrderStoreSingleton
 }
 
+func newStockOrderStore() iStockOrderStore {
+	return &stockOrderStore{
+		store: map[string]*stockOrder{},
+	}
+}
+
 // iStockOrderStore - 現物株式注文ストアのインターフェース
 type iStockOrderStore interface {
 	getAll() []*stockOrder
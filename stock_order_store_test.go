@@ -33,6 +33,18 @@ func (t *testStockOrderStore) removeByCode(code string) {
 	t.removeByCodeHistory = append(t.removeByCodeHistory, code)
 }
 
+func Test_newStockOrderStore(t *testing.T) {
+	t.Parallel()
+	want := &stockOrderStore{store: map[string]*stockOrder{}}
+	got := newStockOrderStore()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+	if got == newStockOrderStore() {
+		t.Errorf("%s error\nwant: different store\ngot: same store\n", t.Name())
+	}
+}
+
 func Test_getStockOrderStore(t *testing.T) {
 	got := getStockOrderStore()
 	want := &stockOrderStore{store: map[string]*stockOrder{}}
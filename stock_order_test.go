@@ -54,11 +54,12 @@ func Test_stockOrder_contract(t *testing.T) {
 func Test_stockOrder_cancel(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
-		name            string
-		stockOrder      *stockOrder
-		arg             time.Time
-		wantOrderStatus OrderStatus
-		wantCanceledAt  time.Time
+		name                 string
+		stockOrder           *stockOrder
+		arg                  time.Time
+		wantOrderStatus      OrderStatus
+		wantCanceledAt       time.Time
+		wantCanceledQuantity float64
 	}{
 		{name: "ステータスがnewなら取消状態に更新",
 			stockOrder:      &stockOrder{OrderStatus: OrderStatusNew},
@@ -71,10 +72,11 @@ func Test_stockOrder_cancel(t *testing.T) {
 			wantOrderStatus: OrderStatusCanceled,
 			wantCanceledAt:  time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local)},
 		{name: "ステータスがpartなら取消状態に更新",
-			stockOrder:      &stockOrder{OrderStatus: OrderStatusPart},
-			arg:             time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local),
-			wantOrderStatus: OrderStatusCanceled,
-			wantCanceledAt:  time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local)},
+			stockOrder:           &stockOrder{OrderStatus: OrderStatusPart, OrderQuantity: 300, ContractedQuantity: 100},
+			arg:                  time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local),
+			wantOrderStatus:      OrderStatusCanceled,
+			wantCanceledAt:       time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local),
+			wantCanceledQuantity: 200},
 		{name: "ステータスがdoneなら取消状態に更新できない",
 			stockOrder:      &stockOrder{OrderStatus: OrderStatusDone},
 			arg:             time.Date(2021, 5, 18, 11, 0, 0, 0, time.Local),
@@ -94,8 +96,9 @@ func Test_stockOrder_cancel(t *testing.T) {
 			test.stockOrder.cancel(test.arg)
 			got1 := test.stockOrder.OrderStatus
 			got2 := test.stockOrder.CanceledAt
-			if !reflect.DeepEqual(test.wantOrderStatus, got1) || !reflect.DeepEqual(test.wantCanceledAt, got2) {
-				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.wantOrderStatus, test.wantCanceledAt, got1, got2)
+			got3 := test.stockOrder.CanceledQuantity
+			if !reflect.DeepEqual(test.wantOrderStatus, got1) || !reflect.DeepEqual(test.wantCanceledAt, got2) || !reflect.DeepEqual(test.wantCanceledQuantity, got3) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(), test.wantOrderStatus, test.wantCanceledAt, test.wantCanceledQuantity, got1, got2, got3)
 			}
 		})
 	}
@@ -363,41 +366,41 @@ func Test_stockOrder_isDied(t *testing.T) {
 	}{
 		{name: "未終了の注文なら生きている",
 			stockOrder: &stockOrder{OrderStatus: OrderStatusInOrder},
-			arg:        time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local),
+			arg:        time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
 			want:       false},
-		{name: "取消済み注文で、取消から1日以内なら生きている",
+		{name: "取消済み注文で、取消がborderより後なら生きている",
 			stockOrder: &stockOrder{OrderStatus: OrderStatusCanceled, CanceledAt: time.Date(2021, 6, 14, 11, 0, 0, 0, time.Local)},
-			arg:        time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local),
+			arg:        time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
 			want:       false},
-		{name: "取消済み注文で、取消から1日丁度なら生きている",
+		{name: "取消済み注文で、取消がborder丁度なら生きている",
 			stockOrder: &stockOrder{OrderStatus: OrderStatusCanceled, CanceledAt: time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local)},
-			arg:        time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local),
+			arg:        time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
 			want:       false},
-		{name: "取消済み注文で、取消から1日以上経っていたら死んでいる",
+		{name: "取消済み注文で、取消がborderより前なら死んでいる",
 			stockOrder: &stockOrder{OrderStatus: OrderStatusCanceled, CanceledAt: time.Date(2021, 6, 14, 9, 0, 0, 0, time.Local)},
-			arg:        time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local),
+			arg:        time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
 			want:       true},
-		{name: "約定済み注文で、最後の約定から1日以内なら生きている",
+		{name: "約定済み注文で、最後の約定がborderより後なら生きている",
 			stockOrder: &stockOrder{OrderStatus: OrderStatusDone, Contracts: []*Contract{
 				{ContractedAt: time.Date(2021, 6, 14, 9, 0, 0, 0, time.Local)},
 				{ContractedAt: time.Date(2021, 6, 14, 11, 0, 0, 0, time.Local)}}},
-			arg:  time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local),
+			arg:  time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
 			want: false},
-		{name: "約定済み注文で、最後の約定から1日丁度なら生きている",
+		{name: "約定済み注文で、最後の約定がborder丁度なら生きている",
 			stockOrder: &stockOrder{OrderStatus: OrderStatusDone, Contracts: []*Contract{
 				{ContractedAt: time.Date(2021, 6, 14, 9, 0, 0, 0, time.Local)},
 				{ContractedAt: time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local)}}},
-			arg:  time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local),
+			arg:  time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
 			want: false},
-		{name: "約定済み注文で、最後の約定から1日以上経っていたら死んでいる",
+		{name: "約定済み注文で、最後の約定がborderより前なら死んでいる",
 			stockOrder: &stockOrder{OrderStatus: OrderStatusDone, Contracts: []*Contract{
 				{ContractedAt: time.Date(2021, 6, 14, 9, 0, 0, 0, time.Local)},
 				{ContractedAt: time.Date(2021, 6, 14, 9, 30, 0, 0, time.Local)}}},
-			arg:  time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local),
+			arg:  time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
 			want: true},
 		{name: "終了した注文で、取消も約定も情報が無かったら死んだものとする",
 			stockOrder: &stockOrder{OrderStatus: OrderStatusDone, Contracts: []*Contract{}},
-			arg:        time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local),
+			arg:        time.Date(2021, 6, 14, 10, 0, 0, 0, time.Local),
 			want:       true},
 	}
 
@@ -483,3 +486,53 @@ func Test_stockOrder_addExitPosition(t *testing.T) {
 		})
 	}
 }
+
+func Test_stockOrder_modify(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		stockOrder   *stockOrder
+		modification *ModifyOrderRequest
+		now          time.Time
+		want         []ExitPosition
+		wantOrder    *stockOrder
+	}{
+		{name: "指値を訂正したら約定確認回数を戻し、訂正履歴を残す",
+			stockOrder:   &stockOrder{LimitPrice: 1000, OrderQuantity: 100, ExpiredAt: time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local), ConfirmingCount: 3},
+			modification: &ModifyOrderRequest{LimitPrice: 1001},
+			now:          time.Date(2021, 11, 5, 10, 0, 0, 0, time.Local),
+			want:         nil,
+			wantOrder: &stockOrder{LimitPrice: 1001, OrderQuantity: 100, ExpiredAt: time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local), ConfirmingCount: 0,
+				Modifications: []*OrderModification{{ModifiedAt: time.Date(2021, 11, 5, 10, 0, 0, 0, time.Local), PrevLimitPrice: 1000, LimitPrice: 1001, PrevOrderQuantity: 100, OrderQuantity: 100,
+					PrevExpiredAt: time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local), ExpiredAt: time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local)}}}},
+		{name: "有効期限だけの訂正なら約定確認回数は戻さない",
+			stockOrder:   &stockOrder{LimitPrice: 1000, OrderQuantity: 100, ExpiredAt: time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local), ConfirmingCount: 3},
+			modification: &ModifyOrderRequest{ExpiredAt: time.Date(2021, 11, 8, 0, 0, 0, 0, time.Local)},
+			now:          time.Date(2021, 11, 5, 10, 0, 0, 0, time.Local),
+			want:         nil,
+			wantOrder: &stockOrder{LimitPrice: 1000, OrderQuantity: 100, ExpiredAt: time.Date(2021, 11, 8, 0, 0, 0, 0, time.Local), ConfirmingCount: 3,
+				Modifications: []*OrderModification{{ModifiedAt: time.Date(2021, 11, 5, 10, 0, 0, 0, time.Local), PrevLimitPrice: 1000, LimitPrice: 1000, PrevOrderQuantity: 100, OrderQuantity: 100,
+					PrevExpiredAt: time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local), ExpiredAt: time.Date(2021, 11, 8, 0, 0, 0, 0, time.Local)}}}},
+		{name: "売り注文の数量を減らしたら、減らした分だけ拘束しているポジションを減らす",
+			stockOrder: &stockOrder{Side: SideSell, LimitPrice: 1000, OrderQuantity: 100, ExpiredAt: time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local),
+				HoldPositions: []*HoldPosition{{PositionCode: "spo-1", HoldQuantity: 100}}},
+			modification: &ModifyOrderRequest{OrderQuantity: 60},
+			now:          time.Date(2021, 11, 5, 10, 0, 0, 0, time.Local),
+			want:         []ExitPosition{{PositionCode: "spo-1", Quantity: 40}},
+			wantOrder: &stockOrder{Side: SideSell, LimitPrice: 1000, OrderQuantity: 60, ExpiredAt: time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local),
+				HoldPositions: []*HoldPosition{{PositionCode: "spo-1", HoldQuantity: 60}},
+				Modifications: []*OrderModification{{ModifiedAt: time.Date(2021, 11, 5, 10, 0, 0, 0, time.Local), PrevLimitPrice: 1000, LimitPrice: 1000, PrevOrderQuantity: 100, OrderQuantity: 60,
+					PrevExpiredAt: time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local), ExpiredAt: time.Date(2021, 11, 5, 0, 0, 0, 0, time.Local)}}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.stockOrder.modify(test.modification, test.now)
+			if !reflect.DeepEqual(test.want, got) || !reflect.DeepEqual(test.wantOrder, test.stockOrder) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want, test.wantOrder, got, test.stockOrder)
+			}
+		})
+	}
+}
@@ -15,13 +15,17 @@ func getStockPositionStore() iStockPositionStore {
 	defer stockPositionStoreSingletonMutex.Unlock()
 
 	if stockPositionStoreSingleton == nil {
-		stockPositionStoreSingleton = &stockPositionStore{
-			store: map[string]*stockPosition{},
-		}
+		stockPositionStoreSingleton = newStockPositionStore()
 	}
 	return stockPositionStoreSingleton
 }
 
+func newStockPositionStore() iStockPositionStore {
+	return &stockPositionStore{
+		store: map[string]*stockPosition{},
+	}
+}
+
 // iStockPositionStore - 現物株式ポジションストアのインターフェース
 type iStockPositionStore interface {
 	getAll() []*stockPosition
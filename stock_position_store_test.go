@@ -34,6 +34,18 @@ func (t *testStockPositionStore) removeByCode(code string) {
 	t.removeByCodeHistory = append(t.removeByCodeHistory, code)
 }
 
+func Test_newStockPositionStore(t *testing.T) {
+	t.Parallel()
+	want := &stockPositionStore{store: map[string]*stockPosition{}}
+	got := newStockPositionStore()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+	if got == newStockPositionStore() {
+		t.Errorf("%s error\nwant: different store\ngot: same store\n", t.Name())
+	}
+}
+
 func Test_getStockPositionStore(t *testing.T) {
 	got := getStockPositionStore()
 	want := &stockPositionStore{
@@ -2,27 +2,36 @@ package virtual_security
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
 
 func newStockService(
+	clock iClock,
 	uuidGenerator iUUIDGenerator,
 	stockOrderStore iStockOrderStore,
 	stockPositionStore iStockPositionStore,
+	stockSymbolStore iStockSymbolStore,
 	validatorComponent iValidatorComponent,
 	stockContractComponent iStockContractComponent,
+	accountService iAccountService,
 ) iStockService {
 	return &stockService{
+		clock:                  clock,
 		uuidGenerator:          uuidGenerator,
 		stockOrderStore:        stockOrderStore,
 		stockPositionStore:     stockPositionStore,
+		stockSymbolStore:       stockSymbolStore,
 		validatorComponent:     validatorComponent,
 		stockContractComponent: stockContractComponent,
+		accountService:         accountService,
 	}
 }
 
 type iStockService interface {
+	registerSymbol(symbol *StockSymbol) error
+	updateSymbolPrice(price *symbolPrice) error
 	toStockOrder(order *StockOrderRequest, now time.Time) *stockOrder
 	confirmContract(order *stockOrder, price *symbolPrice, now time.Time) error
 	getStockOrders() []*stockOrder
@@ -34,14 +43,83 @@ type iStockService interface {
 	holdSellOrderPositions(order *stockOrder) error
 	validation(order *stockOrder, now time.Time) error
 	cancelAndRelease(order *stockOrder, now time.Time) error
+	modify(order *stockOrder, modification *ModifyOrderRequest, price *symbolPrice, now time.Time) error
+	reserveBuyOrderCash(order *stockOrder, price *symbolPrice) error
+	getSymbols() []*stockSymbol
+	restore(orders []*stockOrder, positions []*stockPosition, symbols []*stockSymbol)
 }
 
 type stockService struct {
+	clock                  iClock
 	uuidGenerator          iUUIDGenerator
 	stockOrderStore        iStockOrderStore
 	stockPositionStore     iStockPositionStore
+	stockSymbolStore       iStockSymbolStore
 	validatorComponent     iValidatorComponent
 	stockContractComponent iStockContractComponent
+	accountService         iAccountService
+}
+
+// registerSymbol - 株式銘柄を登録する
+//   登録済みの銘柄なら、呼値の単位の表を更新する
+func (s *stockService) registerSymbol(symbol *StockSymbol) error {
+	if symbol == nil {
+		return NilArgumentError
+	}
+	if symbol.SymbolCode == "" {
+		return InvalidSymbolCodeError
+	}
+	if !symbol.TickSizeTable.isValid() {
+		return InvalidTickSizeTableError
+	}
+	if symbol.BasePrice < 0 {
+		return InvalidBasePriceError
+	}
+
+	if registered, err := s.stockSymbolStore.getByCode(symbol.SymbolCode); err == nil {
+		registered.TickSizeTable = symbol.TickSizeTable
+		if symbol.BasePrice > 0 {
+			// 登録した基準値段を優先するため、前の営業日までの現値は基準値段にしない
+			registered.BasePrice = symbol.BasePrice
+			registered.LastPrice = 0
+		}
+		return nil
+	}
+	s.stockSymbolStore.save(&stockSymbol{
+		SymbolCode:    symbol.SymbolCode,
+		TickSizeTable: symbol.TickSizeTable,
+		BasePrice:     symbol.BasePrice,
+	})
+	return nil
+}
+
+// updateSymbolPrice - 株式の価格情報で銘柄の現値を記録し、価格情報に値幅制限を付ける
+//   銘柄が登録されていなければ、その他の銘柄の表を使う銘柄として登録する
+func (s *stockService) updateSymbolPrice(price *symbolPrice) error {
+	if price == nil {
+		return NilArgumentError
+	}
+	if price.ExchangeType != ExchangeTypeStock && price.ExchangeType != ExchangeTypeMargin {
+		return nil
+	}
+
+	symbol := s.getSymbol(price.SymbolCode)
+	if symbol == nil {
+		symbol = &stockSymbol{SymbolCode: price.SymbolCode}
+		s.stockSymbolStore.save(symbol)
+	}
+	symbol.setPrice(price.Price, price.priceBusinessDay)
+	price.lowerLimit, price.upperLimit = symbol.priceLimits(price.priceBusinessDay)
+	return nil
+}
+
+// getSymbol - 登録されている株式銘柄 (登録されていなければnil)
+func (s *stockService) getSymbol(symbolCode string) *stockSymbol {
+	symbol, err := s.stockSymbolStore.getByCode(symbolCode)
+	if err != nil {
+		return nil
+	}
+	return symbol
 }
 
 func (s *stockService) newOrderCode() string {
@@ -62,14 +140,27 @@ func (s *stockService) confirmContract(order *stockOrder, price *symbolPrice, no
 		return NilArgumentError
 	}
 
+	// 待機中の逆指値注文なら、発動条件を満たしているかを確認する
+	order.activate(price, now)
+
+	var err error
 	switch order.Side {
 	case SideBuy:
-		return s.entry(order, price, now)
+		err = s.entry(order, price, now)
 	case SideSell:
-		return s.exit(order, price, now)
+		err = s.exit(order, price, now)
 	default:
 		return InvalidSideError
 	}
+	if err != nil {
+		return err
+	}
+
+	// IOC注文は初回の約定確認で約定しなかった数量を取り消す
+	if order.executionCondition().IsIOC() && order.ConfirmingCount > 0 && order.OrderStatus.IsCancelable() {
+		return s.cancelAndRelease(order, now)
+	}
+	return nil
 }
 
 func (s *stockService) entry(order *stockOrder, price *symbolPrice, now time.Time) error {
@@ -84,28 +175,37 @@ func (s *stockService) entry(order *stockOrder, price *symbolPrice, now time.Tim
 		return nil
 	}
 
-	contractCode := s.newContractCode()
-	positionCode := s.newPositionCode()
-	order.contract(&Contract{
-		ContractCode: contractCode,
-		OrderCode:    order.Code,
-		PositionCode: positionCode,
-		Price:        contractResult.price,
-		Quantity:     order.OrderQuantity,
-		ContractedAt: contractResult.contractedAt,
-	})
-
-	s.stockPositionStore.save(&stockPosition{
-		Code:               positionCode,
-		OrderCode:          order.Code,
-		SymbolCode:         order.SymbolCode,
-		Side:               order.Side,
-		ContractedQuantity: order.ContractedQuantity,
-		OwnedQuantity:      order.ContractedQuantity,
-		Price:              contractResult.price,
-		ContractedAt:       contractResult.contractedAt,
-		mtx:                sync.Mutex{},
-	})
+	// 板を複数の気配値で約定した場合は、気配値ごとに約定とポジションを作る
+	tickSizeTable := s.getSymbol(order.SymbolCode).tickSizeTable()
+	for _, fill := range contractResult.contractFills() {
+		fillPrice := tickPrice(tickSizeTable, order.Side, fill.price)
+		contractCode := s.newContractCode()
+		positionCode := s.newPositionCode()
+		contract := &Contract{
+			ContractCode: contractCode,
+			OrderCode:    order.Code,
+			PositionCode: positionCode,
+			Price:        fillPrice,
+			Quantity:     fill.quantity,
+			ContractedAt: contractResult.contractedAt,
+		}
+		order.contract(contract)
+		s.accountService.payStockContract(order, contract)
+		s.accountService.chargeContractFee(newFeeTarget(ExchangeTypeStock, order.SymbolCode, order.Side, TradeTypeEntry, 1, contract), contract)
+
+		// 約定ごとにポジションを作る
+		s.stockPositionStore.save(&stockPosition{
+			Code:               positionCode,
+			OrderCode:          order.Code,
+			SymbolCode:         order.SymbolCode,
+			Side:               order.Side,
+			ContractedQuantity: contract.Quantity,
+			OwnedQuantity:      contract.Quantity,
+			Price:              fillPrice,
+			ContractedAt:       contractResult.contractedAt,
+			mtx:                sync.Mutex{},
+		})
+	}
 
 	return nil
 }
@@ -122,28 +222,51 @@ func (s *stockService) exit(order *stockOrder, price *symbolPrice, now time.Time
 		return nil
 	}
 
-	// 注文が拘束しているポジションをexitしていく
-	for _, hp := range order.HoldPositions {
-		p, err := s.stockPositionStore.getByCode(hp.PositionCode)
-		if err != nil {
-			// TODO 注文エラーにする
-			continue
-		}
+	// 板を複数の気配値で約定した場合は、気配値ごとに約定を作る
+	tickSizeTable := s.getSymbol(order.SymbolCode).tickSizeTable()
+	for _, fill := range contractResult.contractFills() {
+		fillPrice := tickPrice(tickSizeTable, order.Side, fill.price)
+		// 注文が拘束しているポジションを、約定した数量の分だけ先頭からexitしていく
+		contractQuantity := fill.quantity
+		for _, hp := range order.HoldPositions {
+			if contractQuantity <= 0 {
+				break
+			}
 
-		// TODO exit時にエラーが出る可能性があれば、エラーをコントロールできるようにする
-		_ = p.exit(hp.HoldQuantity)
-		order.addExitPosition(p.Code, hp.HoldQuantity) // 注文による返済数に加算しておく
+			// 一部約定でexit済みの数量は除く
+			quantity := hp.HoldQuantity - hp.ExitQuantity
+			if quantity <= 0 {
+				continue
+			}
+			if contractQuantity < quantity {
+				quantity = contractQuantity
+			}
 
-		// 注文に約定情報を追加
-		contractCode := s.newContractCode()
-		order.contract(&Contract{
-			ContractCode: contractCode,
-			OrderCode:    order.Code,
-			PositionCode: p.Code,
-			Price:        contractResult.price,
-			Quantity:     hp.HoldQuantity,
-			ContractedAt: contractResult.contractedAt,
-		})
+			p, err := s.stockPositionStore.getByCode(hp.PositionCode)
+			if err != nil {
+				// TODO 注文エラーにする
+				continue
+			}
+
+			// TODO exit時にエラーが出る可能性があれば、エラーをコントロールできるようにする
+			_ = p.exit(quantity)
+			order.addExitPosition(p.Code, quantity) // 注文による返済数に加算しておく
+			contractQuantity -= quantity
+
+			// 注文に約定情報を追加
+			contractCode := s.newContractCode()
+			contract := &Contract{
+				ContractCode: contractCode,
+				OrderCode:    order.Code,
+				PositionCode: p.Code,
+				Price:        fillPrice,
+				Quantity:     quantity,
+				ContractedAt: contractResult.contractedAt,
+			}
+			order.contract(contract)
+			s.accountService.receiveStockContract(contract)
+			s.accountService.chargeContractFee(newFeeTarget(ExchangeTypeStock, order.SymbolCode, order.Side, TradeTypeExit, 1, contract), contract)
+		}
 	}
 
 	return nil
@@ -173,6 +296,36 @@ func (s *stockService) removeStockPositionByCode(positionCode string) {
 	s.stockPositionStore.removeByCode(positionCode)
 }
 
+// getSymbols - 登録されている株式銘柄の一覧
+func (s *stockService) getSymbols() []*stockSymbol {
+	return s.stockSymbolStore.getAll()
+}
+
+// restore - 注文、ポジション、銘柄をストアに戻す
+//   ストアにある注文とポジションは消してから戻し、銘柄は上書きする
+func (s *stockService) restore(orders []*stockOrder, positions []*stockPosition, symbols []*stockSymbol) {
+	for _, o := range s.stockOrderStore.getAll() {
+		s.stockOrderStore.removeByCode(o.Code)
+	}
+	for _, p := range s.stockPositionStore.getAll() {
+		s.stockPositionStore.removeByCode(p.Code)
+	}
+
+	for _, o := range orders {
+		if o == nil {
+			continue
+		}
+		o.StopCondition.restoreActivation()
+		s.stockOrderStore.save(o)
+	}
+	for _, p := range positions {
+		s.stockPositionStore.save(p)
+	}
+	for _, symbol := range symbols {
+		s.stockSymbolStore.save(symbol)
+	}
+}
+
 func (s *stockService) toStockOrder(order *StockOrderRequest, now time.Time) *stockOrder {
 	if order == nil {
 		return nil
@@ -190,13 +343,16 @@ func (s *stockService) toStockOrder(order *StockOrderRequest, now time.Time) *st
 		StopCondition:      order.StopCondition,
 		OrderedAt:          now,
 		Contracts:          []*Contract{},
+		ExitPositionList:   order.ExitPositionList,
+		ExitPositionOrder:  order.ExitPositionOrder,
 	}
 
-	if order.ExpiredAt.IsZero() {
-		o.ExpiredAt = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
-	} else {
-		o.ExpiredAt = time.Date(order.ExpiredAt.Year(), order.ExpiredAt.Month(), order.ExpiredAt.Day(), 0, 0, 0, 0, time.Local)
+	// 逆指値注文は逆指値条件を満たすまで待機させる
+	if o.ExecutionCondition.IsStop() {
+		o.OrderStatus = OrderStatusWait
 	}
+
+	o.ExpiredAt = orderExpiredAt(s.clock, ExchangeTypeStock, order.ExpiredAt, order.ExpireBusinessDays, now)
 	return o
 }
 
@@ -205,7 +361,12 @@ func (s *stockService) holdSellOrderPositions(order *stockOrder) error {
 		return NilArgumentError
 	}
 
-	positions := s.stockPositionStore.getAll()
+	// エグジットするポジションが指定されていれば、指定されたポジションをholdする
+	if len(order.ExitPositionList) > 0 {
+		return s.holdExitPositionList(order)
+	}
+
+	positions := sortExitPositions(symbolStockPositions(s.stockPositionStore.getAll(), order.SymbolCode), order.ExitPositionOrder)
 
 	// 全数が足りるか
 	var totalOrderableQuantity float64
@@ -221,6 +382,9 @@ func (s *stockService) holdSellOrderPositions(order *stockOrder) error {
 	for _, pos := range positions {
 		// ポジションの保有数が返したい数より少なければhold可能な数だけholdし、返したい数が少なければ返したい数だけ返す
 		orderableQuantity := pos.orderableQuantity()
+		if orderableQuantity <= 0 {
+			continue
+		}
 		if orderableQuantity < required {
 			_ = pos.hold(orderableQuantity)
 			order.addHoldPosition(pos.Code, orderableQuantity)
@@ -235,8 +399,112 @@ func (s *stockService) holdSellOrderPositions(order *stockOrder) error {
 	return nil
 }
 
+// holdExitPositionList - 注文で指定されたポジションをholdする
+func (s *stockService) holdExitPositionList(order *stockOrder) error {
+	// positionをhold可能かのチェック
+	//   同じポジションが複数回指定されていれば、合計した数量をholdできる必要がある
+	posList := make([]*stockPosition, len(order.ExitPositionList))
+	holdQuantities := map[string]float64{}
+	for i, exit := range order.ExitPositionList {
+		pos, err := s.stockPositionStore.getByCode(exit.PositionCode)
+		if err != nil {
+			return fmt.Errorf("error position_code = %s: %w", exit.PositionCode, err)
+		}
+		if pos.SymbolCode != order.SymbolCode {
+			return fmt.Errorf("error position_code = %s, symbol_code = %s: %w", exit.PositionCode, pos.SymbolCode, InvalidExitPositionCodeError)
+		}
+		holdQuantities[exit.PositionCode] += exit.Quantity
+		if pos.orderableQuantity() < holdQuantities[exit.PositionCode] {
+			return fmt.Errorf("error position_code = %s, owned_quantity = %.2f, hold_quantity = %.2f, exit_quantity = %.2f: %w",
+				exit.PositionCode, pos.OwnedQuantity, pos.HoldQuantity, holdQuantities[exit.PositionCode], NotEnoughOwnedQuantityError)
+		}
+		posList[i] = pos
+	}
+
+	// hold
+	for i, exit := range order.ExitPositionList {
+		if err := posList[i].hold(exit.Quantity); err != nil {
+			return fmt.Errorf("error position_code = %s: %w", exit.PositionCode, err)
+		}
+		order.addHoldPosition(exit.PositionCode, exit.Quantity)
+	}
+	return nil
+}
+
+// symbolStockPositions - 指定した銘柄のポジションだけを返す
+func symbolStockPositions(positions []*stockPosition, symbolCode string) []*stockPosition {
+	res := make([]*stockPosition, 0)
+	for _, p := range positions {
+		if p.SymbolCode == symbolCode {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// sortExitPositions - エグジット順序に従ってポジションを並べ替える
+//   同じ順位のポジションは元の並び順を維持する
+func sortExitPositions(positions []*stockPosition, exitPositionOrder ExitPositionOrder) []*stockPosition {
+	switch exitPositionOrder {
+	case ExitPositionOrderLIFO:
+		sort.SliceStable(positions, func(i, j int) bool {
+			return positions[i].ContractedAt.After(positions[j].ContractedAt)
+		})
+	case ExitPositionOrderHighestPrice:
+		sort.SliceStable(positions, func(i, j int) bool {
+			return positions[i].Price > positions[j].Price
+		})
+	default:
+		sort.SliceStable(positions, func(i, j int) bool {
+			return positions[i].ContractedAt.Before(positions[j].ContractedAt)
+		})
+	}
+	return positions
+}
+
 func (s *stockService) validation(order *stockOrder, now time.Time) error {
-	return s.validatorComponent.isValidStockOrder(order, now, s.stockPositionStore.getAll())
+	if order == nil {
+		return NilArgumentError
+	}
+
+	// 有効期限と値幅制限は、休業日に注文しても次の営業日で確認する
+	return s.validatorComponent.isValidStockOrder(order, s.clock.getBusinessDay(ExchangeTypeStock, now), s.getSymbol(order.SymbolCode), s.stockPositionStore.getAll())
+}
+
+// modify - 注文を訂正する
+//   買い注文なら訂正後の指値と約定していない数量で拘束する金額を見積もり直す
+//   注文数量を減らした売り注文は、減らした数量の分だけ拘束しているポジションを開放する
+func (s *stockService) modify(order *stockOrder, modification *ModifyOrderRequest, price *symbolPrice, now time.Time) error {
+	if order == nil || modification == nil {
+		return NilArgumentError
+	}
+	if err := s.validatorComponent.isValidStockOrderModification(order, modification, s.clock.getBusinessDay(ExchangeTypeStock, now), s.getSymbol(order.SymbolCode)); err != nil {
+		return err
+	}
+
+	limitPrice, quantity := order.LimitPrice, order.OrderQuantity
+	if modification.LimitPrice > 0 {
+		limitPrice = modification.LimitPrice
+	}
+	if modification.OrderQuantity > 0 {
+		quantity = modification.OrderQuantity
+	}
+	if err := s.accountService.reserveModifiedStockOrder(order, limitPrice, quantity-order.ContractedQuantity, price); err != nil {
+		return err
+	}
+
+	var res error
+	for _, r := range order.modify(modification, now) {
+		pos, err := s.stockPositionStore.getByCode(r.PositionCode)
+		if err != nil {
+			res = fmt.Errorf("拘束中の一部のポジションを解放できませんでした: %w", err)
+			continue
+		}
+		if err := pos.release(r.Quantity); err != nil {
+			res = fmt.Errorf("拘束中の一部のポジションを解放できませんでした: %w", err)
+		}
+	}
+	return res
 }
 
 func (s *stockService) cancelAndRelease(order *stockOrder, now time.Time) error {
@@ -248,6 +516,11 @@ func (s *stockService) cancelAndRelease(order *stockOrder, now time.Time) error
 	}
 	order.cancel(now)
 
+	// 買い注文なら拘束した金額を開放する
+	if order.Side == SideBuy {
+		s.accountService.releaseStockOrder(order)
+	}
+
 	// 売り注文なら拘束したポジションを開放する
 	var res error
 	if order.Side == SideSell {
@@ -270,3 +543,7 @@ func (s *stockService) cancelAndRelease(order *stockOrder, now time.Time) error
 
 	return res
 }
+
+func (s *stockService) reserveBuyOrderCash(order *stockOrder, price *symbolPrice) error {
+	return s.accountService.reserveStockOrder(order, price)
+}
@@ -28,6 +28,14 @@ type testStockService struct {
 	validation1                      error
 	cancelAndRelease1                error
 	cancelAndReleaseCount            int
+	reserveBuyOrderCash1             error
+	reserveBuyOrderCashCount         int
+	updateSymbolPriceHistory         []*symbolPrice
+}
+
+func (t *testStockService) updateSymbolPrice(price *symbolPrice) error {
+	t.updateSymbolPriceHistory = append(t.updateSymbolPriceHistory, price)
+	return nil
 }
 
 func (t *testStockService) saveStockOrder(order *stockOrder) {
@@ -82,6 +90,11 @@ func (t *testStockService) cancelAndRelease(*stockOrder, time.Time) error {
 	return t.cancelAndRelease1
 }
 
+func (t *testStockService) reserveBuyOrderCash(*stockOrder, *symbolPrice) error {
+	t.reserveBuyOrderCashCount++
+	return t.reserveBuyOrderCash1
+}
+
 func Test_stockService_entry(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -93,6 +106,7 @@ func Test_stockService_entry(t *testing.T) {
 		want                  error
 		wantArg1              *stockOrder
 		wantPositionStoreSave []*stockPosition
+		wantPayHistory        []*Contract
 	}{
 		{name: "引数1がnilならエラー",
 			stockService:          &stockService{},
@@ -121,6 +135,7 @@ func Test_stockService_entry(t *testing.T) {
 				stockContractComponent: &testStockContractComponent{confirmStockOrderContract1: &confirmContractResult{
 					isContracted: true,
 					price:        1000,
+					quantity:     100,
 					contractedAt: time.Date(2021, 6, 21, 10, 1, 0, 0, time.Local)}},
 				uuidGenerator: &testUUIDGenerator{generator1: []string{"uuid-1", "uuid-2", "uuid-3"}}},
 			arg1: &stockOrder{
@@ -158,7 +173,8 @@ func Test_stockService_entry(t *testing.T) {
 					Price:              1000,
 					ContractedAt:       time.Date(2021, 6, 21, 10, 1, 0, 0, time.Local),
 				},
-			}},
+			},
+			wantPayHistory: []*Contract{{ContractCode: "sco-uuid-1", OrderCode: "sor-1", PositionCode: "spo-uuid-2", Price: 1000, Quantity: 100, ContractedAt: time.Date(2021, 6, 21, 10, 1, 0, 0, time.Local)}}},
 	}
 
 	for _, test := range tests {
@@ -167,16 +183,20 @@ func Test_stockService_entry(t *testing.T) {
 			t.Parallel()
 			stockOrderStore := &testStockOrderStore{}
 			stockPositionStore := &testStockPositionStore{}
+			accountService := &testAccountService{}
 			test.stockService.stockOrderStore = stockOrderStore
 			test.stockService.stockPositionStore = stockPositionStore
+			test.stockService.stockSymbolStore = &testStockSymbolStore{getByCode2: NoDataError}
+			test.stockService.accountService = accountService
 
 			got := test.stockService.entry(test.arg1, test.arg2, test.arg3)
 			if !errors.Is(got, test.want) ||
 				!reflect.DeepEqual(test.wantArg1, test.arg1) ||
-				!reflect.DeepEqual(test.wantPositionStoreSave, stockPositionStore.saveHistory) {
-				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(),
-					test.want, test.wantArg1, test.wantPositionStoreSave,
-					got, test.arg1, stockPositionStore.saveHistory)
+				!reflect.DeepEqual(test.wantPositionStoreSave, stockPositionStore.saveHistory) ||
+				!reflect.DeepEqual(test.wantPayHistory, accountService.payStockContractHistory) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantArg1, test.wantPositionStoreSave, test.wantPayHistory,
+					got, test.arg1, stockPositionStore.saveHistory, accountService.payStockContractHistory)
 			}
 		})
 	}
@@ -194,6 +214,7 @@ func Test_stockService_exit(t *testing.T) {
 		want               error
 		wantArg1           *stockOrder
 		wantPosition       *stockPosition
+		wantReceiveHistory []*Contract
 	}{
 		{name: "引数1がnilならエラー",
 			stockService:       &stockService{},
@@ -220,7 +241,7 @@ func Test_stockService_exit(t *testing.T) {
 			wantArg1:           &stockOrder{},
 			wantPosition:       nil},
 		{name: "注文がholdしていたpositionが見つからなければそのポジションの処理をスキップする",
-			stockService:       &stockService{stockContractComponent: &testStockContractComponent{confirmStockOrderContract1: &confirmContractResult{isContracted: true}}},
+			stockService:       &stockService{stockContractComponent: &testStockContractComponent{confirmStockOrderContract1: &confirmContractResult{isContracted: true, quantity: 400}}},
 			stockPositionStore: &testStockPositionStore{getByCode1: nil, getByCode2: NoDataError},
 			arg1:               &stockOrder{Code: "sor-1", OrderQuantity: 400, HoldPositions: []*HoldPosition{{PositionCode: "spo-0", HoldQuantity: 400}}},
 			arg2:               &symbolPrice{},
@@ -230,7 +251,7 @@ func Test_stockService_exit(t *testing.T) {
 		{name: "注文がholdしていたpositionをexitする",
 			stockService: &stockService{
 				uuidGenerator:          &testUUIDGenerator{generator1: []string{"uuid-1", "uuid-2", "uuid-3", "uuid-4", "uuid-5"}},
-				stockContractComponent: &testStockContractComponent{confirmStockOrderContract1: &confirmContractResult{isContracted: true, price: 1000, contractedAt: time.Date(2021, 6, 21, 10, 1, 0, 0, time.Local)}}},
+				stockContractComponent: &testStockContractComponent{confirmStockOrderContract1: &confirmContractResult{isContracted: true, price: 1000, quantity: 400, contractedAt: time.Date(2021, 6, 21, 10, 1, 0, 0, time.Local)}}},
 			stockPositionStore: &testStockPositionStore{getByCode1: &stockPosition{Code: "spo-0", OwnedQuantity: 1000, HoldQuantity: 1000}},
 			arg1:               &stockOrder{Code: "sor-1", OrderQuantity: 400, HoldPositions: []*HoldPosition{{PositionCode: "spo-0", HoldQuantity: 400}}},
 			arg2:               &symbolPrice{},
@@ -249,21 +270,65 @@ func Test_stockService_exit(t *testing.T) {
 					ContractedAt: time.Date(2021, 6, 21, 10, 1, 0, 0, time.Local),
 				}},
 				HoldPositions: []*HoldPosition{{PositionCode: "spo-0", HoldQuantity: 400, ExitQuantity: 400}}},
-			wantPosition: &stockPosition{Code: "spo-0", OwnedQuantity: 600, HoldQuantity: 600}},
+			wantPosition: &stockPosition{Code: "spo-0", OwnedQuantity: 600, HoldQuantity: 600},
+			wantReceiveHistory: []*Contract{{
+				ContractCode: "sco-uuid-1",
+				OrderCode:    "sor-1",
+				PositionCode: "spo-0",
+				Price:        1000,
+				Quantity:     400,
+				ContractedAt: time.Date(2021, 6, 21, 10, 1, 0, 0, time.Local),
+			}}},
+		{name: "一部約定なら約定した数量だけexitする",
+			stockService: &stockService{
+				uuidGenerator:          &testUUIDGenerator{generator1: []string{"uuid-1", "uuid-2", "uuid-3", "uuid-4", "uuid-5"}},
+				stockContractComponent: &testStockContractComponent{confirmStockOrderContract1: &confirmContractResult{isContracted: true, price: 1000, quantity: 200, contractedAt: time.Date(2021, 6, 21, 10, 1, 0, 0, time.Local)}}},
+			stockPositionStore: &testStockPositionStore{getByCode1: &stockPosition{Code: "spo-0", OwnedQuantity: 900, HoldQuantity: 300}},
+			arg1:               &stockOrder{Code: "sor-1", OrderStatus: OrderStatusPart, OrderQuantity: 400, ContractedQuantity: 100, HoldPositions: []*HoldPosition{{PositionCode: "spo-0", HoldQuantity: 400, ExitQuantity: 100}}},
+			arg2:               &symbolPrice{},
+			want:               nil,
+			wantArg1: &stockOrder{
+				Code:               "sor-1",
+				OrderStatus:        OrderStatusPart,
+				OrderQuantity:      400,
+				ContractedQuantity: 300,
+				Contracts: []*Contract{{
+					ContractCode: "sco-uuid-1",
+					OrderCode:    "sor-1",
+					PositionCode: "spo-0",
+					Price:        1000,
+					Quantity:     200,
+					ContractedAt: time.Date(2021, 6, 21, 10, 1, 0, 0, time.Local),
+				}},
+				HoldPositions: []*HoldPosition{{PositionCode: "spo-0", HoldQuantity: 400, ExitQuantity: 300}},
+			},
+			wantPosition: &stockPosition{Code: "spo-0", OwnedQuantity: 700, HoldQuantity: 100},
+			wantReceiveHistory: []*Contract{{
+				ContractCode: "sco-uuid-1",
+				OrderCode:    "sor-1",
+				PositionCode: "spo-0",
+				Price:        1000,
+				Quantity:     200,
+				ContractedAt: time.Date(2021, 6, 21, 10, 1, 0, 0, time.Local),
+			}}},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
+			accountService := &testAccountService{}
 			test.stockService.stockPositionStore = test.stockPositionStore
+			test.stockService.stockSymbolStore = &testStockSymbolStore{getByCode2: NoDataError}
+			test.stockService.accountService = accountService
 			got := test.stockService.exit(test.arg1, test.arg2, test.arg3)
 			if !errors.Is(got, test.want) ||
 				!reflect.DeepEqual(test.wantArg1, test.arg1) ||
-				!reflect.DeepEqual(test.wantPosition, test.stockPositionStore.getByCode1) {
-				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(),
-					test.want, test.wantArg1, test.wantPosition,
-					got, test.arg1, test.stockPositionStore.getByCode1)
+				!reflect.DeepEqual(test.wantPosition, test.stockPositionStore.getByCode1) ||
+				!reflect.DeepEqual(test.wantReceiveHistory, accountService.receiveStockContractHistory) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantArg1, test.wantPosition, test.wantReceiveHistory,
+					got, test.arg1, test.stockPositionStore.getByCode1, accountService.receiveStockContractHistory)
 			}
 		})
 	}
@@ -459,18 +524,132 @@ func Test_stockService_saveStockOrder(t *testing.T) {
 
 func Test_newStockService(t *testing.T) {
 	t.Parallel()
+	clock := &testClock{}
 	uuid := &testUUIDGenerator{}
 	stockOrderStore := &testStockOrderStore{}
 	stockPositionStore := &testStockPositionStore{}
+	stockSymbolStore := &testStockSymbolStore{}
 	stockContractComponent := &testStockContractComponent{}
 	validatorComponent := &testValidatorComponent{}
-	want := &stockService{uuidGenerator: uuid, stockOrderStore: stockOrderStore, stockPositionStore: stockPositionStore, stockContractComponent: stockContractComponent, validatorComponent: validatorComponent}
-	got := newStockService(uuid, stockOrderStore, stockPositionStore, validatorComponent, stockContractComponent)
+	accountService := &testAccountService{}
+	want := &stockService{clock: clock, uuidGenerator: uuid, stockOrderStore: stockOrderStore, stockPositionStore: stockPositionStore, stockSymbolStore: stockSymbolStore, stockContractComponent: stockContractComponent, validatorComponent: validatorComponent, accountService: accountService}
+	got := newStockService(clock, uuid, stockOrderStore, stockPositionStore, stockSymbolStore, validatorComponent, stockContractComponent, accountService)
 	if !reflect.DeepEqual(want, got) {
 		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
 	}
 }
 
+func Test_stockService_registerSymbol(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name            string
+		symbolStore     *testStockSymbolStore
+		arg             *StockSymbol
+		want            error
+		wantSaveHistory []*stockSymbol
+		wantRegistered  *stockSymbol
+	}{
+		{name: "引数がnilならエラー",
+			symbolStore: &testStockSymbolStore{},
+			arg:         nil,
+			want:        NilArgumentError},
+		{name: "銘柄コードがなければエラー",
+			symbolStore: &testStockSymbolStore{},
+			arg:         &StockSymbol{TickSizeTable: TickSizeTableTopix500},
+			want:        InvalidSymbolCodeError},
+		{name: "呼値の単位の表が不正ならエラー",
+			symbolStore: &testStockSymbolStore{},
+			arg:         &StockSymbol{SymbolCode: "1234", TickSizeTable: "foo"},
+			want:        InvalidTickSizeTableError},
+		{name: "基準値段が負ならエラー",
+			symbolStore: &testStockSymbolStore{},
+			arg:         &StockSymbol{SymbolCode: "1234", BasePrice: -1},
+			want:        InvalidBasePriceError},
+		{name: "未登録の銘柄なら保存する",
+			symbolStore:     &testStockSymbolStore{getByCode2: NoDataError},
+			arg:             &StockSymbol{SymbolCode: "1234", TickSizeTable: TickSizeTableTopix500, BasePrice: 1_000},
+			want:            nil,
+			wantSaveHistory: []*stockSymbol{{SymbolCode: "1234", TickSizeTable: TickSizeTableTopix500, BasePrice: 1_000}}},
+		{name: "登録済みの銘柄なら呼値の単位の表を更新する",
+			symbolStore:    &testStockSymbolStore{getByCode1: &stockSymbol{SymbolCode: "1234", TickSizeTable: TickSizeTableStandard, BasePrice: 1_000, LastPrice: 1_100}},
+			arg:            &StockSymbol{SymbolCode: "1234", TickSizeTable: TickSizeTableTopix500},
+			want:           nil,
+			wantRegistered: &stockSymbol{SymbolCode: "1234", TickSizeTable: TickSizeTableTopix500, BasePrice: 1_000, LastPrice: 1_100}},
+		{name: "基準値段を指定すれば、前の営業日までの現値より優先して基準値段にする",
+			symbolStore:    &testStockSymbolStore{getByCode1: &stockSymbol{SymbolCode: "1234", BasePrice: 1_000, LastPrice: 1_100, LastBusinessDay: time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)}},
+			arg:            &StockSymbol{SymbolCode: "1234", BasePrice: 1_200},
+			want:           nil,
+			wantRegistered: &stockSymbol{SymbolCode: "1234", BasePrice: 1_200, LastBusinessDay: time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := &stockService{stockSymbolStore: test.symbolStore}
+			got := service.registerSymbol(test.arg)
+			if !errors.Is(got, test.want) ||
+				!reflect.DeepEqual(test.wantSaveHistory, test.symbolStore.saveHistory) ||
+				!reflect.DeepEqual(test.wantRegistered, test.symbolStore.getByCode1) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantSaveHistory, test.wantRegistered,
+					got, test.symbolStore.saveHistory, test.symbolStore.getByCode1)
+			}
+		})
+	}
+}
+
+func Test_stockService_updateSymbolPrice(t *testing.T) {
+	t.Parallel()
+	day1 := time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)
+	day2 := time.Date(2021, 9, 2, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name            string
+		symbolStore     *testStockSymbolStore
+		arg             *symbolPrice
+		want            error
+		wantPrice       *symbolPrice
+		wantSaveHistory []*stockSymbol
+	}{
+		{name: "引数がnilならエラー",
+			symbolStore: &testStockSymbolStore{},
+			arg:         nil,
+			want:        NilArgumentError},
+		{name: "先物の価格なら何もしない",
+			symbolStore: &testStockSymbolStore{},
+			arg:         &symbolPrice{ExchangeType: ExchangeTypeFuture, SymbolCode: "1234", Price: 1_000, priceBusinessDay: day1},
+			want:        nil,
+			wantPrice:   &symbolPrice{ExchangeType: ExchangeTypeFuture, SymbolCode: "1234", Price: 1_000, priceBusinessDay: day1}},
+		{name: "未登録の銘柄なら現値を記録して登録し、基準値段がないので値幅制限はない",
+			symbolStore:     &testStockSymbolStore{getByCode2: NoDataError},
+			arg:             &symbolPrice{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1_000, priceBusinessDay: day1},
+			want:            nil,
+			wantPrice:       &symbolPrice{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1_000, priceBusinessDay: day1},
+			wantSaveHistory: []*stockSymbol{{SymbolCode: "1234", LastPrice: 1_000, LastBusinessDay: day1}}},
+		{name: "前の営業日の現値があれば前日終値から値幅制限を付ける",
+			symbolStore: &testStockSymbolStore{getByCode1: &stockSymbol{SymbolCode: "1234", LastPrice: 1_000, LastBusinessDay: day1}},
+			arg:         &symbolPrice{ExchangeType: ExchangeTypeMargin, SymbolCode: "1234", Price: 1_300, priceBusinessDay: day2},
+			want:        nil,
+			wantPrice:   &symbolPrice{ExchangeType: ExchangeTypeMargin, SymbolCode: "1234", Price: 1_300, priceBusinessDay: day2, lowerLimit: 700, upperLimit: 1_300}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := &stockService{stockSymbolStore: test.symbolStore}
+			got := service.updateSymbolPrice(test.arg)
+			if !errors.Is(got, test.want) ||
+				(test.arg != nil && !reflect.DeepEqual(test.wantPrice, test.arg)) ||
+				!reflect.DeepEqual(test.wantSaveHistory, test.symbolStore.saveHistory) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantPrice, test.wantSaveHistory,
+					got, test.arg, test.symbolStore.saveHistory)
+			}
+		})
+	}
+}
+
 func Test_stockService_newContractCode(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -528,7 +707,7 @@ func Test_stockService_toStockOrder(t *testing.T) {
 	}{
 		{name: "nilを与えたらnilが返される", service: &stockService{}, arg1: nil, arg2: time.Time{}, want: nil},
 		{name: "有効期限がゼロ値なら当日の年月日が入る",
-			service: &stockService{uuidGenerator: &testUUIDGenerator{generator1: []string{"1", "2", "3"}}},
+			service: &stockService{clock: &clock{}, uuidGenerator: &testUUIDGenerator{generator1: []string{"1", "2", "3"}}},
 			arg1: &StockOrderRequest{
 				Side:               SideBuy,
 				ExecutionCondition: StockExecutionConditionMO,
@@ -558,7 +737,7 @@ func Test_stockService_toStockOrder(t *testing.T) {
 				Message:            "",
 			}},
 		{name: "有効期限がゼロ値でないなら、指定した有効期限の年月日が入る",
-			service: &stockService{uuidGenerator: &testUUIDGenerator{generator1: []string{"1", "2", "3"}}},
+			service: &stockService{clock: &clock{}, uuidGenerator: &testUUIDGenerator{generator1: []string{"1", "2", "3"}}},
 			arg1: &StockOrderRequest{
 				Side:               SideBuy,
 				ExecutionCondition: StockExecutionConditionMO,
@@ -587,6 +766,57 @@ func Test_stockService_toStockOrder(t *testing.T) {
 				ConfirmingCount:    0,
 				Message:            "",
 			}},
+		{name: "有効期限がなく営業日数の指定があるなら、注文した営業日から営業日数分の営業日が有効期限になる",
+			service: &stockService{clock: &clock{}, uuidGenerator: &testUUIDGenerator{generator1: []string{"1", "2", "3"}}},
+			arg1: &StockOrderRequest{
+				Side:               SideBuy,
+				ExecutionCondition: StockExecutionConditionMO,
+				SymbolCode:         "1234",
+				Quantity:           1000,
+				ExpireBusinessDays: 3,
+			},
+			arg2: time.Date(2021, 7, 16, 10, 0, 0, 0, time.Local),
+			want: &stockOrder{
+				Code:               "sor-1",
+				OrderStatus:        OrderStatusInOrder,
+				Side:               SideBuy,
+				ExecutionCondition: StockExecutionConditionMO,
+				SymbolCode:         "1234",
+				OrderQuantity:      1000,
+				ExpiredAt:          time.Date(2021, 7, 20, 0, 0, 0, 0, time.Local),
+				OrderedAt:          time.Date(2021, 7, 16, 10, 0, 0, 0, time.Local),
+				Contracts:          []*Contract{},
+			}},
+		{name: "逆指値注文なら待機状態になる",
+			service: &stockService{clock: &clock{}, uuidGenerator: &testUUIDGenerator{generator1: []string{"1", "2", "3"}}},
+			arg1: &StockOrderRequest{
+				Side:               SideSell,
+				ExecutionCondition: StockExecutionConditionStop,
+				SymbolCode:         "1234",
+				Quantity:           1000,
+				StopCondition: &StockStopCondition{
+					StopPrice:                  1000,
+					ComparisonOperator:         ComparisonOperatorLE,
+					ExecutionConditionAfterHit: StockExecutionConditionMO,
+				},
+			},
+			arg2: time.Date(2021, 7, 20, 10, 0, 0, 0, time.Local),
+			want: &stockOrder{
+				Code:               "sor-1",
+				OrderStatus:        OrderStatusWait,
+				Side:               SideSell,
+				ExecutionCondition: StockExecutionConditionStop,
+				SymbolCode:         "1234",
+				OrderQuantity:      1000,
+				ExpiredAt:          time.Date(2021, 7, 20, 0, 0, 0, 0, time.Local),
+				StopCondition: &StockStopCondition{
+					StopPrice:                  1000,
+					ComparisonOperator:         ComparisonOperatorLE,
+					ExecutionConditionAfterHit: StockExecutionConditionMO,
+				},
+				OrderedAt: time.Date(2021, 7, 20, 10, 0, 0, 0, time.Local),
+				Contracts: []*Contract{},
+			}},
 	}
 
 	for _, test := range tests {
@@ -629,6 +859,36 @@ func Test_stockService_holdSellOrderPositions(t *testing.T) {
 			want:          nil,
 			wantPositions: []*stockPosition{{Code: "spo-uuid-01", OwnedQuantity: 50, HoldQuantity: 50}, {Code: "spo-uuid-02", OwnedQuantity: 30, HoldQuantity: 30}, {Code: "spo-uuid-03", OwnedQuantity: 30, HoldQuantity: 20}},
 			wantArg:       &stockOrder{OrderQuantity: 100, HoldPositions: []*HoldPosition{{PositionCode: "spo-uuid-01", HoldQuantity: 50}, {PositionCode: "spo-uuid-02", HoldQuantity: 30}, {PositionCode: "spo-uuid-03", HoldQuantity: 20}}}},
+		{name: "別銘柄のポジションはholdしない",
+			getAll:        []*stockPosition{{Code: "spo-uuid-01", SymbolCode: "5678", OwnedQuantity: 100}, {Code: "spo-uuid-02", SymbolCode: "1234", OwnedQuantity: 100}},
+			arg:           &stockOrder{SymbolCode: "1234", OrderQuantity: 100},
+			want:          nil,
+			wantPositions: []*stockPosition{{Code: "spo-uuid-01", SymbolCode: "5678", OwnedQuantity: 100}, {Code: "spo-uuid-02", SymbolCode: "1234", OwnedQuantity: 100, HoldQuantity: 100}},
+			wantArg:       &stockOrder{SymbolCode: "1234", OrderQuantity: 100, HoldPositions: []*HoldPosition{{PositionCode: "spo-uuid-02", HoldQuantity: 100}}}},
+		{name: "別銘柄のポジションしか数量が足りなければエラー",
+			getAll:        []*stockPosition{{Code: "spo-uuid-01", SymbolCode: "5678", OwnedQuantity: 100}, {Code: "spo-uuid-02", SymbolCode: "1234", OwnedQuantity: 50}},
+			arg:           &stockOrder{SymbolCode: "1234", OrderQuantity: 100},
+			want:          NotEnoughOwnedQuantityError,
+			wantPositions: []*stockPosition{{Code: "spo-uuid-01", SymbolCode: "5678", OwnedQuantity: 100}, {Code: "spo-uuid-02", SymbolCode: "1234", OwnedQuantity: 50}},
+			wantArg:       &stockOrder{SymbolCode: "1234", OrderQuantity: 100}},
+		{name: "エグジット順序が未指定なら約定日時の古い順にholdする",
+			getAll:        []*stockPosition{{Code: "spo-uuid-01", OwnedQuantity: 100, Price: 1000, ContractedAt: time.Date(2021, 8, 19, 10, 0, 0, 0, time.Local)}, {Code: "spo-uuid-02", OwnedQuantity: 100, Price: 900, ContractedAt: time.Date(2021, 8, 18, 10, 0, 0, 0, time.Local)}, {Code: "spo-uuid-03", OwnedQuantity: 100, Price: 1100, ContractedAt: time.Date(2021, 8, 20, 10, 0, 0, 0, time.Local)}},
+			arg:           &stockOrder{OrderQuantity: 150},
+			want:          nil,
+			wantPositions: []*stockPosition{{Code: "spo-uuid-01", OwnedQuantity: 100, HoldQuantity: 50, Price: 1000, ContractedAt: time.Date(2021, 8, 19, 10, 0, 0, 0, time.Local)}, {Code: "spo-uuid-02", OwnedQuantity: 100, HoldQuantity: 100, Price: 900, ContractedAt: time.Date(2021, 8, 18, 10, 0, 0, 0, time.Local)}, {Code: "spo-uuid-03", OwnedQuantity: 100, Price: 1100, ContractedAt: time.Date(2021, 8, 20, 10, 0, 0, 0, time.Local)}},
+			wantArg:       &stockOrder{OrderQuantity: 150, HoldPositions: []*HoldPosition{{PositionCode: "spo-uuid-02", HoldQuantity: 100}, {PositionCode: "spo-uuid-01", HoldQuantity: 50}}}},
+		{name: "エグジット順序がLIFOなら約定日時の新しい順にholdする",
+			getAll:        []*stockPosition{{Code: "spo-uuid-01", OwnedQuantity: 100, Price: 1000, ContractedAt: time.Date(2021, 8, 19, 10, 0, 0, 0, time.Local)}, {Code: "spo-uuid-02", OwnedQuantity: 100, Price: 900, ContractedAt: time.Date(2021, 8, 18, 10, 0, 0, 0, time.Local)}, {Code: "spo-uuid-03", OwnedQuantity: 100, Price: 1100, ContractedAt: time.Date(2021, 8, 20, 10, 0, 0, 0, time.Local)}},
+			arg:           &stockOrder{OrderQuantity: 150, ExitPositionOrder: ExitPositionOrderLIFO},
+			want:          nil,
+			wantPositions: []*stockPosition{{Code: "spo-uuid-01", OwnedQuantity: 100, HoldQuantity: 50, Price: 1000, ContractedAt: time.Date(2021, 8, 19, 10, 0, 0, 0, time.Local)}, {Code: "spo-uuid-02", OwnedQuantity: 100, Price: 900, ContractedAt: time.Date(2021, 8, 18, 10, 0, 0, 0, time.Local)}, {Code: "spo-uuid-03", OwnedQuantity: 100, HoldQuantity: 100, Price: 1100, ContractedAt: time.Date(2021, 8, 20, 10, 0, 0, 0, time.Local)}},
+			wantArg:       &stockOrder{OrderQuantity: 150, ExitPositionOrder: ExitPositionOrderLIFO, HoldPositions: []*HoldPosition{{PositionCode: "spo-uuid-03", HoldQuantity: 100}, {PositionCode: "spo-uuid-01", HoldQuantity: 50}}}},
+		{name: "エグジット順序が約定価格の高い順なら約定価格の高い順にholdする",
+			getAll:        []*stockPosition{{Code: "spo-uuid-01", OwnedQuantity: 100, Price: 1000, ContractedAt: time.Date(2021, 8, 19, 10, 0, 0, 0, time.Local)}, {Code: "spo-uuid-02", OwnedQuantity: 100, Price: 900, ContractedAt: time.Date(2021, 8, 18, 10, 0, 0, 0, time.Local)}, {Code: "spo-uuid-03", OwnedQuantity: 100, Price: 1100, ContractedAt: time.Date(2021, 8, 20, 10, 0, 0, 0, time.Local)}},
+			arg:           &stockOrder{OrderQuantity: 150, ExitPositionOrder: ExitPositionOrderHighestPrice},
+			want:          nil,
+			wantPositions: []*stockPosition{{Code: "spo-uuid-01", OwnedQuantity: 100, HoldQuantity: 50, Price: 1000, ContractedAt: time.Date(2021, 8, 19, 10, 0, 0, 0, time.Local)}, {Code: "spo-uuid-02", OwnedQuantity: 100, Price: 900, ContractedAt: time.Date(2021, 8, 18, 10, 0, 0, 0, time.Local)}, {Code: "spo-uuid-03", OwnedQuantity: 100, HoldQuantity: 100, Price: 1100, ContractedAt: time.Date(2021, 8, 20, 10, 0, 0, 0, time.Local)}},
+			wantArg:       &stockOrder{OrderQuantity: 150, ExitPositionOrder: ExitPositionOrderHighestPrice, HoldPositions: []*HoldPosition{{PositionCode: "spo-uuid-03", HoldQuantity: 100}, {PositionCode: "spo-uuid-01", HoldQuantity: 50}}}},
 	}
 
 	for _, test := range tests {
@@ -644,19 +904,81 @@ func Test_stockService_holdSellOrderPositions(t *testing.T) {
 	}
 }
 
+func Test_stockService_holdSellOrderPositions_exitPositionList(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		store         *testStockPositionStore
+		arg           *stockOrder
+		want          error
+		wantPositions *stockPosition
+		wantArg       *stockOrder
+	}{
+		{name: "指定したポジションがなければエラー",
+			store:   &testStockPositionStore{getByCode2: NoDataError},
+			arg:     &stockOrder{SymbolCode: "1234", OrderQuantity: 100, ExitPositionList: []ExitPosition{{PositionCode: "spo-uuid-01", Quantity: 100}}},
+			want:    NoDataError,
+			wantArg: &stockOrder{SymbolCode: "1234", OrderQuantity: 100, ExitPositionList: []ExitPosition{{PositionCode: "spo-uuid-01", Quantity: 100}}}},
+		{name: "指定したポジションが別銘柄ならエラー",
+			store:         &testStockPositionStore{getByCode1: &stockPosition{Code: "spo-uuid-01", SymbolCode: "5678", OwnedQuantity: 100}},
+			arg:           &stockOrder{SymbolCode: "1234", OrderQuantity: 100, ExitPositionList: []ExitPosition{{PositionCode: "spo-uuid-01", Quantity: 100}}},
+			want:          InvalidExitPositionCodeError,
+			wantPositions: &stockPosition{Code: "spo-uuid-01", SymbolCode: "5678", OwnedQuantity: 100},
+			wantArg:       &stockOrder{SymbolCode: "1234", OrderQuantity: 100, ExitPositionList: []ExitPosition{{PositionCode: "spo-uuid-01", Quantity: 100}}}},
+		{name: "指定したポジションの数量が足りなければエラー",
+			store:         &testStockPositionStore{getByCode1: &stockPosition{Code: "spo-uuid-01", SymbolCode: "1234", OwnedQuantity: 100, HoldQuantity: 50}},
+			arg:           &stockOrder{SymbolCode: "1234", OrderQuantity: 100, ExitPositionList: []ExitPosition{{PositionCode: "spo-uuid-01", Quantity: 100}}},
+			want:          NotEnoughOwnedQuantityError,
+			wantPositions: &stockPosition{Code: "spo-uuid-01", SymbolCode: "1234", OwnedQuantity: 100, HoldQuantity: 50},
+			wantArg:       &stockOrder{SymbolCode: "1234", OrderQuantity: 100, ExitPositionList: []ExitPosition{{PositionCode: "spo-uuid-01", Quantity: 100}}}},
+		{name: "同じポジションが複数回指定されていて、合計の数量が足りなければエラー",
+			store:         &testStockPositionStore{getByCode1: &stockPosition{Code: "spo-uuid-01", SymbolCode: "1234", OwnedQuantity: 100}},
+			arg:           &stockOrder{SymbolCode: "1234", OrderQuantity: 200, ExitPositionList: []ExitPosition{{PositionCode: "spo-uuid-01", Quantity: 100}, {PositionCode: "spo-uuid-01", Quantity: 100}}},
+			want:          NotEnoughOwnedQuantityError,
+			wantPositions: &stockPosition{Code: "spo-uuid-01", SymbolCode: "1234", OwnedQuantity: 100},
+			wantArg:       &stockOrder{SymbolCode: "1234", OrderQuantity: 200, ExitPositionList: []ExitPosition{{PositionCode: "spo-uuid-01", Quantity: 100}, {PositionCode: "spo-uuid-01", Quantity: 100}}}},
+		{name: "指定したポジションの数量が足りればholdする",
+			store:         &testStockPositionStore{getByCode1: &stockPosition{Code: "spo-uuid-01", SymbolCode: "1234", OwnedQuantity: 100, HoldQuantity: 50}},
+			arg:           &stockOrder{SymbolCode: "1234", OrderQuantity: 30, ExitPositionList: []ExitPosition{{PositionCode: "spo-uuid-01", Quantity: 30}}},
+			want:          nil,
+			wantPositions: &stockPosition{Code: "spo-uuid-01", SymbolCode: "1234", OwnedQuantity: 100, HoldQuantity: 80},
+			wantArg:       &stockOrder{SymbolCode: "1234", OrderQuantity: 30, ExitPositionList: []ExitPosition{{PositionCode: "spo-uuid-01", Quantity: 30}}, HoldPositions: []*HoldPosition{{PositionCode: "spo-uuid-01", HoldQuantity: 30}}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := &stockService{stockPositionStore: test.store}
+			got := service.holdSellOrderPositions(test.arg)
+			if !errors.Is(got, test.want) || !reflect.DeepEqual(test.wantPositions, test.store.getByCode1) || !reflect.DeepEqual(test.wantArg, test.arg) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(), test.want, test.wantPositions, test.wantArg, got, test.store.getByCode1, test.arg)
+			}
+		})
+	}
+}
+
 func Test_stockService_validation(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
 		name              string
+		arg               *stockOrder
 		getAll            []*stockPosition
 		isValidStockOrder error
 		want              error
 	}{
+		{name: "注文がnilならエラー",
+			arg:               nil,
+			getAll:            []*stockPosition{},
+			isValidStockOrder: nil,
+			want:              NilArgumentError},
 		{name: "errorを返されたらerrorを返す",
+			arg:               &stockOrder{},
 			getAll:            []*stockPosition{},
 			isValidStockOrder: NilArgumentError,
 			want:              NilArgumentError},
 		{name: "nilを返されたらnilを返す",
+			arg:               &stockOrder{},
 			getAll:            []*stockPosition{},
 			isValidStockOrder: nil,
 			want:              nil},
@@ -666,8 +988,8 @@ func Test_stockService_validation(t *testing.T) {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			service := &stockService{stockPositionStore: &testStockPositionStore{getAll1: test.getAll}, validatorComponent: &testValidatorComponent{isValidStockOrder1: test.isValidStockOrder}}
-			got := service.validation(nil, time.Time{})
+			service := &stockService{clock: &clock{}, stockPositionStore: &testStockPositionStore{getAll1: test.getAll}, stockSymbolStore: &testStockSymbolStore{getByCode2: NoDataError}, validatorComponent: &testValidatorComponent{isValidStockOrder1: test.isValidStockOrder}}
+			got := service.validation(test.arg, time.Time{})
 			if !reflect.DeepEqual(test.want, got) {
 				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
 			}
@@ -684,30 +1006,106 @@ func Test_stockService_confirmContract(t *testing.T) {
 		arg2                      *symbolPrice
 		arg3                      time.Time
 		want                      error
+		wantArg1                  *stockOrder
 	}{
-		{name: "注文がnilならエラー", arg1: nil, arg2: &symbolPrice{}, want: NilArgumentError},
-		{name: "価格がnilならエラー", arg1: &stockOrder{}, arg2: nil, want: NilArgumentError},
-		{name: "売買方向が買いでも売りでもなければエラー", arg1: &stockOrder{Side: SideUnspecified}, arg2: &symbolPrice{}, want: InvalidSideError},
+		{name: "注文がnilならエラー", arg1: nil, arg2: &symbolPrice{}, want: NilArgumentError, wantArg1: nil},
+		{name: "価格がnilならエラー", arg1: &stockOrder{}, arg2: nil, want: NilArgumentError, wantArg1: &stockOrder{}},
+		{name: "売買方向が買いでも売りでもなければエラー", arg1: &stockOrder{Side: SideUnspecified}, arg2: &symbolPrice{}, want: InvalidSideError, wantArg1: &stockOrder{Side: SideUnspecified}},
 		{name: "売買方向が買いならentry",
 			confirmStockOrderContract: &confirmContractResult{isContracted: false},
 			arg1:                      &stockOrder{Side: SideBuy},
 			arg2:                      &symbolPrice{},
-			want:                      nil},
+			want:                      nil,
+			wantArg1:                  &stockOrder{Side: SideBuy}},
 		{name: "売買方向が売りならexit",
 			confirmStockOrderContract: &confirmContractResult{isContracted: false},
 			arg1:                      &stockOrder{Side: SideSell},
 			arg2:                      &symbolPrice{},
-			want:                      nil},
+			want:                      nil,
+			wantArg1:                  &stockOrder{Side: SideSell}},
+		{name: "待機中の逆指値注文が逆指値条件を満たしたら注文中になる",
+			confirmStockOrderContract: &confirmContractResult{isContracted: false},
+			arg1: &stockOrder{
+				Side:               SideSell,
+				OrderStatus:        OrderStatusWait,
+				SymbolCode:         "1234",
+				ExecutionCondition: StockExecutionConditionStop,
+				StopCondition: &StockStopCondition{
+					StopPrice:                  1000,
+					ComparisonOperator:         ComparisonOperatorGE,
+					ExecutionConditionAfterHit: StockExecutionConditionMO,
+				}},
+			arg2: &symbolPrice{SymbolCode: "1234", Price: 990, PriceTime: time.Date(2021, 8, 23, 10, 0, 0, 0, time.Local)},
+			arg3: time.Date(2021, 8, 23, 10, 0, 1, 0, time.Local),
+			want: nil,
+			wantArg1: &stockOrder{
+				Side:               SideSell,
+				OrderStatus:        OrderStatusInOrder,
+				SymbolCode:         "1234",
+				ExecutionCondition: StockExecutionConditionStop,
+				StopCondition: &StockStopCondition{
+					StopPrice:                  1000,
+					ComparisonOperator:         ComparisonOperatorGE,
+					ExecutionConditionAfterHit: StockExecutionConditionMO,
+					ActivatedAt:                time.Date(2021, 8, 23, 10, 0, 1, 0, time.Local),
+					isActivate:                 true,
+				}}},
+		{name: "待機中の逆指値注文が逆指値条件を満たさなければ待機のまま",
+			confirmStockOrderContract: &confirmContractResult{isContracted: false},
+			arg1: &stockOrder{
+				Side:               SideSell,
+				OrderStatus:        OrderStatusWait,
+				SymbolCode:         "1234",
+				ExecutionCondition: StockExecutionConditionStop,
+				StopCondition: &StockStopCondition{
+					StopPrice:                  1000,
+					ComparisonOperator:         ComparisonOperatorGE,
+					ExecutionConditionAfterHit: StockExecutionConditionMO,
+				}},
+			arg2: &symbolPrice{SymbolCode: "1234", Price: 1010, PriceTime: time.Date(2021, 8, 23, 10, 0, 0, 0, time.Local)},
+			arg3: time.Date(2021, 8, 23, 10, 0, 1, 0, time.Local),
+			want: nil,
+			wantArg1: &stockOrder{
+				Side:               SideSell,
+				OrderStatus:        OrderStatusWait,
+				SymbolCode:         "1234",
+				ExecutionCondition: StockExecutionConditionStop,
+				StopCondition: &StockStopCondition{
+					StopPrice:                  1000,
+					ComparisonOperator:         ComparisonOperatorGE,
+					ExecutionConditionAfterHit: StockExecutionConditionMO,
+				}}},
+		{name: "IOC注文は初回の約定確認で約定しなかった数量を取り消す",
+			confirmStockOrderContract: &confirmContractResult{isContracted: false},
+			arg1:                      &stockOrder{Side: SideBuy, OrderStatus: OrderStatusInOrder, ExecutionCondition: StockExecutionConditionIOCLO, OrderQuantity: 100, ConfirmingCount: 1},
+			arg2:                      &symbolPrice{},
+			arg3:                      time.Date(2021, 8, 23, 10, 0, 1, 0, time.Local),
+			want:                      nil,
+			wantArg1:                  &stockOrder{Side: SideBuy, OrderStatus: OrderStatusCanceled, ExecutionCondition: StockExecutionConditionIOCLO, OrderQuantity: 100, CanceledQuantity: 100, ConfirmingCount: 1, CanceledAt: time.Date(2021, 8, 23, 10, 0, 1, 0, time.Local)}},
+		{name: "約定確認をしていないIOC注文は取り消さない",
+			confirmStockOrderContract: &confirmContractResult{isContracted: false},
+			arg1:                      &stockOrder{Side: SideBuy, OrderStatus: OrderStatusInOrder, ExecutionCondition: StockExecutionConditionIOCMO, OrderQuantity: 100},
+			arg2:                      &symbolPrice{},
+			arg3:                      time.Date(2021, 8, 23, 10, 0, 1, 0, time.Local),
+			want:                      nil,
+			wantArg1:                  &stockOrder{Side: SideBuy, OrderStatus: OrderStatusInOrder, ExecutionCondition: StockExecutionConditionIOCMO, OrderQuantity: 100}},
+		{name: "IOC以外の注文は約定確認後も取り消さない",
+			confirmStockOrderContract: &confirmContractResult{isContracted: false},
+			arg1:                      &stockOrder{Side: SideBuy, OrderStatus: OrderStatusInOrder, ExecutionCondition: StockExecutionConditionLO, OrderQuantity: 100, ConfirmingCount: 1},
+			arg2:                      &symbolPrice{},
+			arg3:                      time.Date(2021, 8, 23, 10, 0, 1, 0, time.Local),
+			want:                      nil,
+			wantArg1:                  &stockOrder{Side: SideBuy, OrderStatus: OrderStatusInOrder, ExecutionCondition: StockExecutionConditionLO, OrderQuantity: 100, ConfirmingCount: 1}},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			service := &stockService{stockContractComponent: &testStockContractComponent{confirmStockOrderContract1: test.confirmStockOrderContract}}
+			service := &stockService{stockContractComponent: &testStockContractComponent{confirmStockOrderContract1: test.confirmStockOrderContract}, accountService: &testAccountService{}}
 			got := service.confirmContract(test.arg1, test.arg2, test.arg3)
-			if !reflect.DeepEqual(test.want, got) {
-				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			if !reflect.DeepEqual(test.want, got) || !reflect.DeepEqual(test.wantArg1, test.arg1) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want, test.wantArg1, got, test.arg1)
 			}
 		})
 	}
@@ -723,6 +1121,7 @@ func Test_stockService_cancelAndRelease(t *testing.T) {
 		want               error
 		wantArg1           *stockOrder
 		wantPosition       *stockPosition
+		wantReleaseCount   int
 	}{
 		{name: "引数がnilならエラー",
 			stockPositionStore: &testStockPositionStore{},
@@ -738,13 +1137,14 @@ func Test_stockService_cancelAndRelease(t *testing.T) {
 			want:               UncancellableOrderError,
 			wantArg1:           &stockOrder{OrderStatus: OrderStatusCanceled},
 			wantPosition:       nil},
-		{name: "注文がExitでなければ注文の状態だけ更新して終了",
+		{name: "注文がExitでなければ注文の状態を更新し、拘束した金額を開放して終了",
 			stockPositionStore: &testStockPositionStore{},
 			arg1:               &stockOrder{Side: SideBuy, OrderStatus: OrderStatusInOrder},
 			arg2:               time.Date(2021, 8, 31, 14, 0, 0, 0, time.Local),
 			want:               nil,
 			wantArg1:           &stockOrder{Side: SideBuy, OrderStatus: OrderStatusCanceled, CanceledAt: time.Date(2021, 8, 31, 14, 0, 0, 0, time.Local)},
-			wantPosition:       nil},
+			wantPosition:       nil,
+			wantReleaseCount:   1},
 		{name: "注文がExitでも拘束中のポジションがなければ何もしない",
 			stockPositionStore: &testStockPositionStore{},
 			arg1:               &stockOrder{Side: SideSell, OrderStatus: OrderStatusInOrder, HoldPositions: []*HoldPosition{{PositionCode: "mpo-uuid-01", HoldQuantity: 100, ExitQuantity: 100}}},
@@ -779,14 +1179,126 @@ func Test_stockService_cancelAndRelease(t *testing.T) {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			service := &stockService{stockPositionStore: test.stockPositionStore}
+			accountService := &testAccountService{}
+			service := &stockService{stockPositionStore: test.stockPositionStore, accountService: accountService}
 			got := service.cancelAndRelease(test.arg1, test.arg2)
 			if !errors.Is(got, test.want) ||
 				!reflect.DeepEqual(test.wantArg1, test.arg1) ||
-				!reflect.DeepEqual(test.wantPosition, test.stockPositionStore.getByCode1) {
-				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(),
-					test.want, test.wantArg1, test.wantPosition,
-					got, test.arg1, test.stockPositionStore.getByCode1)
+				!reflect.DeepEqual(test.wantPosition, test.stockPositionStore.getByCode1) ||
+				!reflect.DeepEqual(test.wantReleaseCount, len(accountService.releaseStockOrderHistory)) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantArg1, test.wantPosition, test.wantReleaseCount,
+					got, test.arg1, test.stockPositionStore.getByCode1, len(accountService.releaseStockOrderHistory))
+			}
+		})
+	}
+}
+
+func Test_stockService_modify(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2021, 11, 5, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		name                   string
+		validatorComponent     *testValidatorComponent
+		accountService         *testAccountService
+		stockPositionStore     *testStockPositionStore
+		order                  *stockOrder
+		modification           *ModifyOrderRequest
+		want                   error
+		wantOrderQuantity      float64
+		wantLimitPrice         float64
+		wantReserveModified    []float64
+		wantPosition           *stockPosition
+		wantModificationsCount int
+	}{
+		{name: "注文がnilならエラー",
+			validatorComponent: &testValidatorComponent{},
+			accountService:     &testAccountService{},
+			stockPositionStore: &testStockPositionStore{},
+			order:              nil,
+			modification:       &ModifyOrderRequest{LimitPrice: 1001},
+			want:               NilArgumentError},
+		{name: "訂正がvalidationでエラーになったらエラーを返し、注文は訂正しない",
+			validatorComponent: &testValidatorComponent{isValidModification1: InvalidQuantityError},
+			accountService:     &testAccountService{},
+			stockPositionStore: &testStockPositionStore{},
+			order:              &stockOrder{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100},
+			modification:       &ModifyOrderRequest{OrderQuantity: 200},
+			want:               InvalidQuantityError,
+			wantOrderQuantity:  100,
+			wantLimitPrice:     1000},
+		{name: "拘束し直せなければエラーを返し、注文は訂正しない",
+			validatorComponent:  &testValidatorComponent{},
+			accountService:      &testAccountService{reserveModified1: NotEnoughBuyingPowerError},
+			stockPositionStore:  &testStockPositionStore{},
+			order:               &stockOrder{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100},
+			modification:        &ModifyOrderRequest{LimitPrice: 1100},
+			want:                NotEnoughBuyingPowerError,
+			wantOrderQuantity:   100,
+			wantLimitPrice:      1000,
+			wantReserveModified: []float64{1100, 100}},
+		{name: "買い注文は、訂正後の指値と約定していない数量で拘束し直して訂正する",
+			validatorComponent:     &testValidatorComponent{},
+			accountService:         &testAccountService{},
+			stockPositionStore:     &testStockPositionStore{},
+			order:                  &stockOrder{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 300, ContractedQuantity: 100},
+			modification:           &ModifyOrderRequest{LimitPrice: 1100, OrderQuantity: 200},
+			want:                   nil,
+			wantOrderQuantity:      200,
+			wantLimitPrice:         1100,
+			wantReserveModified:    []float64{1100, 100},
+			wantModificationsCount: 1},
+		{name: "売り注文の数量を減らしたら、減らした分のポジションを開放する",
+			validatorComponent:     &testValidatorComponent{},
+			accountService:         &testAccountService{},
+			stockPositionStore:     &testStockPositionStore{getByCode1: &stockPosition{Code: "spo-1", OwnedQuantity: 100, HoldQuantity: 100}},
+			order:                  &stockOrder{Side: SideSell, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100, HoldPositions: []*HoldPosition{{PositionCode: "spo-1", HoldQuantity: 100}}},
+			modification:           &ModifyOrderRequest{OrderQuantity: 70},
+			want:                   nil,
+			wantOrderQuantity:      70,
+			wantLimitPrice:         1000,
+			wantReserveModified:    []float64{1000, 70},
+			wantPosition:           &stockPosition{Code: "spo-1", OwnedQuantity: 100, HoldQuantity: 70},
+			wantModificationsCount: 1},
+		{name: "開放するポジションがなくても注文は訂正し、エラーを返す",
+			validatorComponent:     &testValidatorComponent{},
+			accountService:         &testAccountService{},
+			stockPositionStore:     &testStockPositionStore{getByCode2: NoDataError},
+			order:                  &stockOrder{Side: SideSell, ExecutionCondition: StockExecutionConditionLO, LimitPrice: 1000, OrderQuantity: 100, HoldPositions: []*HoldPosition{{PositionCode: "spo-1", HoldQuantity: 100}}},
+			modification:           &ModifyOrderRequest{OrderQuantity: 70},
+			want:                   NoDataError,
+			wantOrderQuantity:      70,
+			wantLimitPrice:         1000,
+			wantReserveModified:    []float64{1000, 70},
+			wantModificationsCount: 1},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			service := &stockService{
+				clock:              &clock{},
+				validatorComponent: test.validatorComponent,
+				accountService:     test.accountService,
+				stockPositionStore: test.stockPositionStore,
+				stockSymbolStore:   &testStockSymbolStore{getByCode2: NoDataError},
+			}
+			got := service.modify(test.order, test.modification, nil, now)
+			var gotOrderQuantity, gotLimitPrice float64
+			var gotModificationsCount int
+			if test.order != nil {
+				gotOrderQuantity, gotLimitPrice, gotModificationsCount = test.order.OrderQuantity, test.order.LimitPrice, len(test.order.Modifications)
+			}
+			if !errors.Is(got, test.want) ||
+				!reflect.DeepEqual(test.wantOrderQuantity, gotOrderQuantity) ||
+				!reflect.DeepEqual(test.wantLimitPrice, gotLimitPrice) ||
+				!reflect.DeepEqual(test.wantReserveModified, test.accountService.reserveModifiedHistory) ||
+				!reflect.DeepEqual(test.wantPosition, test.stockPositionStore.getByCode1) ||
+				!reflect.DeepEqual(test.wantModificationsCount, gotModificationsCount) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantOrderQuantity, test.wantLimitPrice, test.wantReserveModified, test.wantPosition, test.wantModificationsCount,
+					got, gotOrderQuantity, gotLimitPrice, test.accountService.reserveModifiedHistory, test.stockPositionStore.getByCode1, gotModificationsCount)
 			}
 		})
 	}
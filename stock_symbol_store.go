@@ -0,0 +1,125 @@
+package virtual_security
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	stockSymbolStoreSingleton      iStockSymbolStore
+	stockSymbolStoreSingletonMutex sync.Mutex
+)
+
+func getStockSymbolStore() iStockSymbolStore {
+	stockSymbolStoreSingletonMutex.Lock()
+	defer stockSymbolStoreSingletonMutex.Unlock()
+
+	if stockSymbolStoreSingleton == nil {
+		stockSymbolStoreSingleton = newStockSymbolStore()
+	}
+	return stockSymbolStoreSingleton
+}
+
+func newStockSymbolStore() iStockSymbolStore {
+	return &stockSymbolStore{
+		store: map[string]*stockSymbol{},
+	}
+}
+
+// stockSymbol - 株式の銘柄 (現物と信用で共通)
+type stockSymbol struct {
+	SymbolCode      string        // 銘柄コード
+	TickSizeTable   TickSizeTable // 呼値の単位の表
+	BasePrice       float64       // 基準値段 (前日終値)
+	LastPrice       float64       // 最後に登録された現値
+	LastBusinessDay time.Time     // 最後に現値が登録された営業日
+}
+
+// tickSizeTable - 銘柄の呼値の単位の表 (登録されていない銘柄はその他の銘柄の表)
+func (s *stockSymbol) tickSizeTable() TickSizeTable {
+	if s == nil || s.TickSizeTable == TickSizeTableUnspecified {
+		return TickSizeTableStandard
+	}
+	return s.TickSizeTable
+}
+
+// basePrice - 指定した営業日の基準値段
+//   前の営業日に現値が登録されていれば、前の営業日の最後の現値(前日終値)を基準値段にする
+func (s *stockSymbol) basePrice(businessDay time.Time) float64 {
+	if s == nil {
+		return 0
+	}
+	if s.LastPrice > 0 && s.LastBusinessDay.Before(businessDay) {
+		return s.LastPrice
+	}
+	return s.BasePrice
+}
+
+// setPrice - 現値を記録する
+//   営業日が変わっていれば、前の営業日の最後の現値を基準値段にしてから記録する
+func (s *stockSymbol) setPrice(price float64, businessDay time.Time) {
+	if price <= 0 || businessDay.Before(s.LastBusinessDay) {
+		return
+	}
+	s.BasePrice = s.basePrice(businessDay)
+	s.LastPrice = price
+	s.LastBusinessDay = businessDay
+}
+
+// priceLimits - 指定した営業日の値幅制限の下限と上限 (基準値段がなければ0)
+func (s *stockSymbol) priceLimits(businessDay time.Time) (float64, float64) {
+	return priceLimits(s.basePrice(businessDay))
+}
+
+// iStockSymbolStore - 株式銘柄ストアのインターフェース
+type iStockSymbolStore interface {
+	getAll() []*stockSymbol
+	getByCode(code string) (*stockSymbol, error)
+	save(stockSymbol *stockSymbol)
+}
+
+// stockSymbolStore - 株式銘柄のストア
+type stockSymbolStore struct {
+	store map[string]*stockSymbol
+	mtx   sync.Mutex
+}
+
+// getAll - ストアのすべての銘柄を銘柄コード順に並べて返す
+func (s *stockSymbolStore) getAll() []*stockSymbol {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	symbols := make([]*stockSymbol, 0, len(s.store))
+	for _, symbol := range s.store {
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].SymbolCode < symbols[j].SymbolCode
+	})
+	return symbols
+}
+
+// getByCode - 銘柄コードを指定してデータを取得する
+func (s *stockSymbolStore) getByCode(code string) (*stockSymbol, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if symbol, ok := s.store[code]; ok {
+		return symbol, nil
+	} else {
+		return nil, NoDataError
+	}
+}
+
+// save - 銘柄をストアに追加する
+func (s *stockSymbolStore) save(stockSymbol *stockSymbol) {
+	if stockSymbol == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.store[stockSymbol.SymbolCode] = stockSymbol
+}
@@ -0,0 +1,221 @@
+package virtual_security
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testStockSymbolStore struct {
+	getAll1     []*stockSymbol
+	getByCode1  *stockSymbol
+	getByCode2  error
+	saveHistory []*stockSymbol
+}
+
+func (t *testStockSymbolStore) getAll() []*stockSymbol {
+	return t.getAll1
+}
+
+func (t *testStockSymbolStore) getByCode(string) (*stockSymbol, error) {
+	return t.getByCode1, t.getByCode2
+}
+func (t *testStockSymbolStore) save(stockSymbol *stockSymbol) {
+	t.saveHistory = append(t.saveHistory, stockSymbol)
+}
+
+func Test_newStockSymbolStore(t *testing.T) {
+	t.Parallel()
+	want := &stockSymbolStore{store: map[string]*stockSymbol{}}
+	got := newStockSymbolStore()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+	if got == newStockSymbolStore() {
+		t.Errorf("%s error\nwant: different store\ngot: same store\n", t.Name())
+	}
+}
+
+func Test_getStockSymbolStore(t *testing.T) {
+	got := getStockSymbolStore()
+	want := &stockSymbolStore{store: map[string]*stockSymbol{}}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_stockSymbolStore_GetByCode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		store *stockSymbolStore
+		arg   string
+		want1 *stockSymbol
+		want2 error
+	}{
+		{name: "storeに指定したコードがなければエラー",
+			store: &stockSymbolStore{store: map[string]*stockSymbol{}},
+			arg:   "1234",
+			want1: nil,
+			want2: NoDataError},
+		{name: "storeに指定したコードがあればその銘柄を返す",
+			store: &stockSymbolStore{store: map[string]*stockSymbol{
+				"1234": {SymbolCode: "1234", TickSizeTable: TickSizeTableTopix500},
+				"5678": {SymbolCode: "5678", TickSizeTable: TickSizeTableStandard}}},
+			arg:   "1234",
+			want1: &stockSymbol{SymbolCode: "1234", TickSizeTable: TickSizeTableTopix500},
+			want2: nil},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got1, got2 := test.store.getByCode(test.arg)
+			if !reflect.DeepEqual(test.want1, got1) || !errors.Is(got2, test.want2) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want1, test.want2, got1, got2)
+			}
+		})
+	}
+}
+
+func Test_stockSymbolStore_Save(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		store *stockSymbolStore
+		arg   *stockSymbol
+		want  map[string]*stockSymbol
+	}{
+		{name: "nilなら何もしない",
+			store: &stockSymbolStore{store: map[string]*stockSymbol{}},
+			arg:   nil,
+			want:  map[string]*stockSymbol{}},
+		{name: "同じコードがあれば上書きする",
+			store: &stockSymbolStore{store: map[string]*stockSymbol{"1234": {SymbolCode: "1234", TickSizeTable: TickSizeTableStandard}}},
+			arg:   &stockSymbol{SymbolCode: "1234", TickSizeTable: TickSizeTableTopix500},
+			want:  map[string]*stockSymbol{"1234": {SymbolCode: "1234", TickSizeTable: TickSizeTableTopix500}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			test.store.save(test.arg)
+			if !reflect.DeepEqual(test.want, test.store.store) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, test.store.store)
+			}
+		})
+	}
+}
+
+func Test_stockSymbol_tickSizeTable(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		symbol *stockSymbol
+		want   TickSizeTable
+	}{
+		{name: "銘柄がなければその他の銘柄の表", symbol: nil, want: TickSizeTableStandard},
+		{name: "表が未指定ならその他の銘柄の表", symbol: &stockSymbol{SymbolCode: "1234"}, want: TickSizeTableStandard},
+		{name: "表が指定されていればその表", symbol: &stockSymbol{SymbolCode: "1234", TickSizeTable: TickSizeTableTopix500}, want: TickSizeTableTopix500},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.symbol.tickSizeTable()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_stockSymbol_basePrice(t *testing.T) {
+	t.Parallel()
+	day1 := time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)
+	day2 := time.Date(2021, 9, 2, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name   string
+		symbol *stockSymbol
+		arg    time.Time
+		want   float64
+	}{
+		{name: "銘柄がなければ0", symbol: nil, arg: day1, want: 0},
+		{name: "同じ営業日の現値しかなければ登録された基準値段", symbol: &stockSymbol{BasePrice: 1_000, LastPrice: 1_100, LastBusinessDay: day1}, arg: day1, want: 1_000},
+		{name: "前の営業日の現値があれば前日終値を基準値段にする", symbol: &stockSymbol{BasePrice: 1_000, LastPrice: 1_100, LastBusinessDay: day1}, arg: day2, want: 1_100},
+		{name: "現値がなければ登録された基準値段", symbol: &stockSymbol{BasePrice: 1_000}, arg: day2, want: 1_000},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.symbol.basePrice(test.arg)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_stockSymbol_setPrice(t *testing.T) {
+	t.Parallel()
+	day1 := time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)
+	day2 := time.Date(2021, 9, 2, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name   string
+		symbol *stockSymbol
+		arg1   float64
+		arg2   time.Time
+		want   *stockSymbol
+	}{
+		{name: "現値がなければ何もしない",
+			symbol: &stockSymbol{BasePrice: 1_000, LastPrice: 1_100, LastBusinessDay: day1},
+			arg1:   0,
+			arg2:   day2,
+			want:   &stockSymbol{BasePrice: 1_000, LastPrice: 1_100, LastBusinessDay: day1}},
+		{name: "前の営業日の価格なら何もしない",
+			symbol: &stockSymbol{BasePrice: 1_000, LastPrice: 1_100, LastBusinessDay: day2},
+			arg1:   1_200,
+			arg2:   day1,
+			want:   &stockSymbol{BasePrice: 1_000, LastPrice: 1_100, LastBusinessDay: day2}},
+		{name: "同じ営業日なら現値だけ更新する",
+			symbol: &stockSymbol{BasePrice: 1_000, LastPrice: 1_100, LastBusinessDay: day1},
+			arg1:   1_200,
+			arg2:   day1,
+			want:   &stockSymbol{BasePrice: 1_000, LastPrice: 1_200, LastBusinessDay: day1}},
+		{name: "営業日が変われば前日終値を基準値段にして現値を更新する",
+			symbol: &stockSymbol{BasePrice: 1_000, LastPrice: 1_100, LastBusinessDay: day1},
+			arg1:   1_200,
+			arg2:   day2,
+			want:   &stockSymbol{BasePrice: 1_100, LastPrice: 1_200, LastBusinessDay: day2}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			test.symbol.setPrice(test.arg1, test.arg2)
+			if !reflect.DeepEqual(test.want, test.symbol) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, test.symbol)
+			}
+		})
+	}
+}
+
+func Test_stockSymbolStore_getAll(t *testing.T) {
+	t.Parallel()
+	store := &stockSymbolStore{store: map[string]*stockSymbol{
+		"5678": {SymbolCode: "5678"},
+		"1234": {SymbolCode: "1234"},
+	}}
+	want := []*stockSymbol{{SymbolCode: "1234"}, {SymbolCode: "5678"}}
+	got := store.getAll()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
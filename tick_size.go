@@ -0,0 +1,104 @@
+package virtual_security
+
+import "math"
+
+// tickSizeLevel - 呼値の単位の表の1段
+type tickSizeLevel struct {
+	maxPrice float64 // この段の価格の上限 (0なら上限なし)
+	tickSize float64 // 呼値の単位
+}
+
+var (
+	// その他の銘柄の呼値の単位
+	standardTickSizeLevels = []tickSizeLevel{
+		{maxPrice: 3_000, tickSize: 1},
+		{maxPrice: 5_000, tickSize: 5},
+		{maxPrice: 30_000, tickSize: 10},
+		{maxPrice: 50_000, tickSize: 50},
+		{maxPrice: 300_000, tickSize: 100},
+		{maxPrice: 500_000, tickSize: 500},
+		{maxPrice: 3_000_000, tickSize: 1_000},
+		{maxPrice: 5_000_000, tickSize: 5_000},
+		{maxPrice: 30_000_000, tickSize: 10_000},
+		{maxPrice: 50_000_000, tickSize: 50_000},
+		{tickSize: 100_000},
+	}
+
+	// TOPIX500構成銘柄の呼値の単位
+	topix500TickSizeLevels = []tickSizeLevel{
+		{maxPrice: 1_000, tickSize: 0.1},
+		{maxPrice: 3_000, tickSize: 0.5},
+		{maxPrice: 10_000, tickSize: 1},
+		{maxPrice: 30_000, tickSize: 5},
+		{maxPrice: 100_000, tickSize: 10},
+		{maxPrice: 300_000, tickSize: 50},
+		{maxPrice: 1_000_000, tickSize: 100},
+		{maxPrice: 3_000_000, tickSize: 500},
+		{maxPrice: 10_000_000, tickSize: 1_000},
+		{maxPrice: 30_000_000, tickSize: 5_000},
+		{tickSize: 10_000},
+	}
+)
+
+// levels - 表に対応する呼値の単位の段
+func (e TickSizeTable) levels() []tickSizeLevel {
+	if e == TickSizeTableTopix500 {
+		return topix500TickSizeLevels
+	}
+	return standardTickSizeLevels
+}
+
+// TickSize - 価格の呼値の単位
+func (e TickSizeTable) TickSize(price float64) float64 {
+	levels := e.levels()
+	for _, l := range levels {
+		if l.maxPrice <= 0 || price <= l.maxPrice {
+			return l.tickSize
+		}
+	}
+	return levels[len(levels)-1].tickSize
+}
+
+// IsValidPrice - 価格が呼値の単位に合っているか
+func (e TickSizeTable) IsValidPrice(price float64) bool {
+	if price <= 0 {
+		return false
+	}
+	n := price / e.TickSize(price)
+	return math.Abs(n-math.Round(n)) < 1e-9
+}
+
+// RoundDown - 価格を呼値の単位に切り下げる
+func (e TickSizeTable) RoundDown(price float64) float64 {
+	if e.IsValidPrice(price) {
+		return price
+	}
+	tickSize := e.TickSize(price)
+	return roundTickPrice(math.Floor(price/tickSize) * tickSize)
+}
+
+// RoundUp - 価格を呼値の単位に切り上げる
+func (e TickSizeTable) RoundUp(price float64) float64 {
+	if e.IsValidPrice(price) {
+		return price
+	}
+	tickSize := e.TickSize(price)
+	return roundTickPrice(math.Ceil(price/tickSize) * tickSize)
+}
+
+// roundTickPrice - 浮動小数点数の誤差を、最も小さい呼値の単位(0.1円)の桁で丸める
+func roundTickPrice(price float64) float64 {
+	return math.Round(price*10) / 10
+}
+
+// tickPrice - 板を成行で約定させた価格を呼値の単位に合わせる
+//   買いは高い方に、売りは安い方に合わせる
+func tickPrice(table TickSizeTable, side Side, price float64) float64 {
+	if price <= 0 {
+		return price
+	}
+	if side == SideSell {
+		return table.RoundDown(price)
+	}
+	return table.RoundUp(price)
+}
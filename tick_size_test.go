@@ -0,0 +1,120 @@
+package virtual_security
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_TickSizeTable_TickSize(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		table TickSizeTable
+		arg   float64
+		want  float64
+	}{
+		{name: "その他の銘柄の3,000円以下は1円", table: TickSizeTableStandard, arg: 3_000, want: 1},
+		{name: "その他の銘柄の3,000円超は5円", table: TickSizeTableStandard, arg: 3_005, want: 5},
+		{name: "その他の銘柄の50,000,000円超は100,000円", table: TickSizeTableStandard, arg: 60_000_000, want: 100_000},
+		{name: "未指定ならその他の銘柄の表", table: TickSizeTableUnspecified, arg: 4_000, want: 5},
+		{name: "TOPIX500構成銘柄の1,000円以下は0.1円", table: TickSizeTableTopix500, arg: 1_000, want: 0.1},
+		{name: "TOPIX500構成銘柄の1,000円超は0.5円", table: TickSizeTableTopix500, arg: 1_000.5, want: 0.5},
+		{name: "TOPIX500構成銘柄の30,000,000円超は10,000円", table: TickSizeTableTopix500, arg: 40_000_000, want: 10_000},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.table.TickSize(test.arg)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_TickSizeTable_IsValidPrice(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		table TickSizeTable
+		arg   float64
+		want  bool
+	}{
+		{name: "0円はfalse", table: TickSizeTableStandard, arg: 0, want: false},
+		{name: "その他の銘柄の1円刻みの価格はtrue", table: TickSizeTableStandard, arg: 2_999, want: true},
+		{name: "その他の銘柄の1円未満の価格はfalse", table: TickSizeTableStandard, arg: 999.5, want: false},
+		{name: "その他の銘柄の3,000円超の5円刻みでない価格はfalse", table: TickSizeTableStandard, arg: 3_001, want: false},
+		{name: "その他の銘柄の3,000円超の5円刻みの価格はtrue", table: TickSizeTableStandard, arg: 3_005, want: true},
+		{name: "TOPIX500構成銘柄の0.1円刻みの価格はtrue", table: TickSizeTableTopix500, arg: 999.9, want: true},
+		{name: "TOPIX500構成銘柄の1,000円超の0.5円刻みでない価格はfalse", table: TickSizeTableTopix500, arg: 1_000.3, want: false},
+		{name: "TOPIX500構成銘柄の10,000円超の5円刻みの価格はtrue", table: TickSizeTableTopix500, arg: 10_005, want: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.table.IsValidPrice(test.arg)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_TickSizeTable_Round(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		table    TickSizeTable
+		arg      float64
+		wantDown float64
+		wantUp   float64
+	}{
+		{name: "呼値の単位に合っていればそのまま", table: TickSizeTableStandard, arg: 3_005, wantDown: 3_005, wantUp: 3_005},
+		{name: "その他の銘柄は呼値の単位で切り下げ、切り上げる", table: TickSizeTableStandard, arg: 3_007, wantDown: 3_005, wantUp: 3_010},
+		{name: "1円未満は1円単位にする", table: TickSizeTableStandard, arg: 1_000.4, wantDown: 1_000, wantUp: 1_001},
+		{name: "TOPIX500構成銘柄は小数の呼値の単位でも誤差なく丸める", table: TickSizeTableTopix500, arg: 100.15, wantDown: 100.1, wantUp: 100.2},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			gotDown := test.table.RoundDown(test.arg)
+			gotUp := test.table.RoundUp(test.arg)
+			if test.wantDown != gotDown || test.wantUp != gotUp {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.wantDown, test.wantUp, gotDown, gotUp)
+			}
+		})
+	}
+}
+
+func Test_tickPrice(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		table TickSizeTable
+		side  Side
+		price float64
+		want  float64
+	}{
+		{name: "買いは切り上げる", table: TickSizeTableStandard, side: SideBuy, price: 1_000.5, want: 1_001},
+		{name: "売りは切り下げる", table: TickSizeTableStandard, side: SideSell, price: 1_000.5, want: 1_000},
+		{name: "呼値の単位に合っていればそのまま", table: TickSizeTableTopix500, side: SideBuy, price: 1_000.5, want: 1_000.5},
+		{name: "価格がなければそのまま", table: TickSizeTableStandard, side: SideBuy, price: 0, want: 0},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := tickPrice(test.table, test.side, test.price)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
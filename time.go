@@ -1,83 +1,227 @@
 package virtual_security
 
-import "time"
+import (
+	"time"
+)
 
-var (
-	dayStart = time.Date(0, 1, 1, 0, 0, 0, 0, time.Local)
-	dayEnd   = time.Date(0, 1, 2, 0, 0, 0, 0, time.Local).Add(-1 * time.Nanosecond)
+// closingAuctionDuration - 引けの板寄せで約定を確認する時間
+//   引けの時刻からこの時間だけは引けの価格として扱い、引けの注文を約定させる
+const closingAuctionDuration = 5 * time.Second
 
-	// 約定可能現値時間
-	contractableStockPriceTime = newTimeRanges(
-		newTimeRange(9, 0, 0, 11, 30, 0),
-		newTimeRange(12, 30, 0, 15, 0, 0))
+// SessionSchedule - 市場種別ごとの取引時間
+//   WithSessionScheduleで仮想証券会社ごとに指定し、指定しなかった市場種別は通常の取引時間になる
+type SessionSchedule struct {
+	Sessions        []TradingSession // 営業日の取引時間
+	SpecialSessions []SpecialSession // 半日立会など、特定の営業日だけの取引時間
+}
 
-	// 約定可能な前場時間
-	contractableMorningSessionTime = newTimeRanges(
-		newTimeRange(9, 0, 0, 11, 30, 5))
+// WithHalfDays - 指定した日を、最初のセッション(前場)だけの半日立会にした取引時間を返す
+func (s SessionSchedule) WithHalfDays(days ...time.Time) SessionSchedule {
+	var sessions []TradingSession
+	if len(s.Sessions) > 0 {
+		sessions = s.Sessions[:1]
+	}
 
-	// 約定可能な前場ザラバ時間
-	contractableMorningSessionAuctionTime = newTimeRanges(
-		newTimeRange(9, 0, 0, 11, 30, 0))
+	res := SessionSchedule{Sessions: s.Sessions, SpecialSessions: append([]SpecialSession{}, s.SpecialSessions...)}
+	for _, day := range days {
+		res.SpecialSessions = append(res.SpecialSessions, SpecialSession{Day: day, Sessions: sessions})
+	}
+	return res
+}
 
-	// 約定可能な前場引け時間
-	contractableMorningSessionCloseTime = newTimeRanges(
-		newTimeRange(11, 30, 0, 11, 30, 5))
+// TradingSession - 1つのセッションの取引時間
+//   時刻はセッションが始まる日の0時からの経過時間で指定し、CloseがOpenより前なら日付をまたぐセッションになる
+//   現物・信用の前場と後場以外のセッション(PTSのような夜間取引など)は、寄付や引けの執行条件が使えないザラバだけのセッションになる
+type TradingSession struct {
+	Session  Session       // セッション (現物・信用は前場か後場、先物は日中か夜間)
+	Open     time.Duration // 寄付の時刻
+	PreClose time.Duration // ザラバが終わり、引けの板寄せの注文だけを受け付けるプレ・クロージングの開始時刻 (0ならCloseまでザラバ)
+	Close    time.Duration // 引けの時刻
+}
 
-	// 約定可能な後場時間
-	contractableAfternoonSessionTime = newTimeRanges(
-		newTimeRange(12, 30, 0, 15, 0, 5))
+// SpecialSession - 特定の営業日だけの取引時間
+type SpecialSession struct {
+	Day      time.Time        // 対象の日付
+	Sessions []TradingSession // その日の取引時間 (空ならその日は取引がない)
+}
 
-	// 約定可能な前場ザラバ時間
-	contractableAfternoonSessionAuctionTime = newTimeRanges(
-		newTimeRange(12, 30, 0, 15, 0, 0))
+// DefaultStockSessionSchedule - 現物・信用の通常の取引時間 (前場 9:00-11:30, 後場 12:30-15:00)
+func DefaultStockSessionSchedule() SessionSchedule {
+	return SessionSchedule{Sessions: []TradingSession{
+		{Session: SessionMorning, Open: 9 * time.Hour, Close: 11*time.Hour + 30*time.Minute},
+		{Session: SessionAfternoon, Open: 12*time.Hour + 30*time.Minute, Close: 15 * time.Hour},
+	}}
+}
 
-	// 約定可能な後場引け時間
-	contractableAfternoonSessionCloseTime = newTimeRanges(
-		newTimeRange(15, 0, 0, 15, 0, 5))
-)
+// ExtendedStockSessionSchedule - 東証が2024年11月5日から延長した現物・信用の取引時間
+//   後場は15:30までで、15:25から15:30まではクロージング・オークションで引けの板寄せの注文だけを受け付ける
+func ExtendedStockSessionSchedule() SessionSchedule {
+	return SessionSchedule{Sessions: []TradingSession{
+		{Session: SessionMorning, Open: 9 * time.Hour, Close: 11*time.Hour + 30*time.Minute},
+		{Session: SessionAfternoon, Open: 12*time.Hour + 30*time.Minute, PreClose: 15*time.Hour + 25*time.Minute, Close: 15*time.Hour + 30*time.Minute},
+	}}
+}
 
-func newTimeRanges(ranges ...*timeRange) *timeRanges {
-	return &timeRanges{timeRanges: ranges}
+// DefaultFutureSessionSchedule - 先物の通常の取引時間 (日中 8:45-15:45, 夜間 17:00-翌6:00, いずれも最後の5分間はプレ・クロージング)
+func DefaultFutureSessionSchedule() SessionSchedule {
+	return SessionSchedule{Sessions: []TradingSession{
+		{Session: SessionDay, Open: 8*time.Hour + 45*time.Minute, PreClose: 15*time.Hour + 40*time.Minute, Close: 15*time.Hour + 45*time.Minute},
+		{Session: SessionNight, Open: 17 * time.Hour, PreClose: 5*time.Hour + 55*time.Minute, Close: 6 * time.Hour},
+	}}
 }
 
-type timeRanges struct {
-	timeRanges []*timeRange
+// defaultTradingSchedule - 取引時間を指定しなかったときの取引時間
+var defaultTradingSchedule = newTradingSchedule(nil)
+
+// newTradingSchedule - 市場種別ごとの取引時間から、仮想証券会社が使う取引時間を生成する
+//   指定しなかった市場種別は通常の取引時間を使い、信用は現物の取引時間を使う
+func newTradingSchedule(schedules map[ExchangeType]SessionSchedule) *tradingSchedule {
+	res := &tradingSchedule{schedules: map[ExchangeType]*exchangeSchedule{
+		ExchangeTypeStock:  newExchangeSchedule(DefaultStockSessionSchedule()),
+		ExchangeTypeFuture: newExchangeSchedule(DefaultFutureSessionSchedule()),
+	}}
+	for exchangeType, schedule := range schedules {
+		res.schedules[exchangeType] = newExchangeSchedule(schedule)
+	}
+	return res
 }
 
-func (t *timeRanges) between(target time.Time) bool {
-	for _, tr := range t.timeRanges {
-		if tr.between(target) {
-			return true
+// tradingSchedule - 仮想証券会社の取引時間
+//   nilなら通常の取引時間として扱う
+type tradingSchedule struct {
+	schedules map[ExchangeType]*exchangeSchedule
+}
+
+func (s *tradingSchedule) exchangeSchedule(exchangeType ExchangeType) *exchangeSchedule {
+	if s == nil {
+		s = defaultTradingSchedule
+	}
+	if exchangeType == ExchangeTypeMargin {
+		exchangeType = ExchangeTypeStock
+	}
+	return s.schedules[exchangeType]
+}
+
+// periodsOf - 指定した日に始まるセッションの日時
+func (s *tradingSchedule) periodsOf(exchangeType ExchangeType, day time.Time) []*sessionPeriod {
+	schedule := s.exchangeSchedule(exchangeType)
+	if schedule == nil {
+		return nil
+	}
+	return schedule.periodsOf(day)
+}
+
+// sessionAt - 指定した日時を含むセッションの日時 (セッション外ならnil)
+//   日付をまたぐセッションは、前日に始まったセッションも確認する
+func (s *tradingSchedule) sessionAt(exchangeType ExchangeType, now time.Time) *sessionPeriod {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for _, day := range []time.Time{today, today.AddDate(0, 0, -1)} {
+		for _, p := range s.periodsOf(exchangeType, day) {
+			if p.between(now) {
+				return p
+			}
 		}
 	}
-	return false
+	return nil
+}
+
+// isClosing - 指定した日時が、指定したセッションの引けの板寄せの時間か
+func (s *tradingSchedule) isClosing(exchangeType ExchangeType, session Session, now time.Time) bool {
+	p := s.sessionAt(exchangeType, now)
+	return p != nil && p.session == session && p.isClosing(now)
 }
 
-func newTimeRange(fromHour, fromMinute, fromSecond, toHour, toMinute, toSecond int) *timeRange {
-	from := time.Date(0, 1, 1, fromHour, fromMinute, fromSecond, 0, time.Local)
-	to := time.Date(0, 1, 1, toHour, toMinute, toSecond, 0, time.Local)
+// nextBoundary - 指定した日時より後で、最初に来る日付やセッションの切り替わりの日時
+func (s *tradingSchedule) nextBoundary(now time.Time) time.Time {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	next := today.AddDate(0, 0, 1)
+	for _, exchangeType := range []ExchangeType{ExchangeTypeStock, ExchangeTypeFuture} {
+		for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+			for _, p := range s.periodsOf(exchangeType, day) {
+				for _, t := range []time.Time{p.open, p.preClose, p.close, p.end} {
+					if t.After(now) && t.Before(next) {
+						next = t
+					}
+				}
+			}
+		}
+	}
+	return next
+}
 
-	return &timeRange{
-		from:   from,
-		to:     to,
-		isBack: !from.Before(to),
+func newExchangeSchedule(schedule SessionSchedule) *exchangeSchedule {
+	res := &exchangeSchedule{sessions: schedule.Sessions, specialSessions: map[string][]TradingSession{}}
+	for _, s := range schedule.SpecialSessions {
+		res.specialSessions[s.Day.Format("2006-01-02")] = s.Sessions
 	}
+	return res
 }
 
-type timeRange struct {
-	from   time.Time
-	to     time.Time
-	isBack bool
+// exchangeSchedule - 1つの市場種別の取引時間
+type exchangeSchedule struct {
+	sessions        []TradingSession            // 営業日の取引時間
+	specialSessions map[string][]TradingSession // 特定の日だけの取引時間 (2006-01-02の形式)
 }
 
-func (t *timeRange) between(target time.Time) bool {
-	targetTime := time.Date(0, 1, 1, target.Hour(), target.Minute(), target.Second(), target.Nanosecond(), target.Location())
-	if t.isBack {
-		// 00:00:00 <= target < to
-		// from <= target <= 23:59:59
-		return (!targetTime.Before(dayStart) && targetTime.Before(t.to)) || (!targetTime.Before(t.from) && !targetTime.After(dayEnd))
-	} else {
-		// from <= target < to
-		return !targetTime.Before(t.from) && targetTime.Before(t.to)
+// periodsOf - 指定した日に始まるセッションの日時
+func (s *exchangeSchedule) periodsOf(day time.Time) []*sessionPeriod {
+	sessions, ok := s.specialSessions[day.Format("2006-01-02")]
+	if !ok {
+		sessions = s.sessions
+	}
+
+	res := make([]*sessionPeriod, len(sessions))
+	for i, session := range sessions {
+		res[i] = newSessionPeriod(session, day)
+	}
+	return res
+}
+
+func newSessionPeriod(session TradingSession, day time.Time) *sessionPeriod {
+	open := day.Add(session.Open)
+	closeAt := day.Add(session.Close)
+	if !closeAt.After(open) {
+		closeAt = closeAt.AddDate(0, 0, 1)
+	}
+	preClose := closeAt
+	if session.PreClose > 0 {
+		preClose = day.Add(session.PreClose)
+		if preClose.Before(open) {
+			preClose = preClose.AddDate(0, 0, 1)
+		}
 	}
+
+	return &sessionPeriod{
+		session:  session.Session,
+		day:      day,
+		open:     open,
+		preClose: preClose,
+		close:    closeAt,
+		end:      closeAt.Add(closingAuctionDuration),
+	}
+}
+
+// sessionPeriod - ある日に始まるセッションの日時
+type sessionPeriod struct {
+	session  Session
+	day      time.Time // セッションが始まった日
+	open     time.Time // 寄付
+	preClose time.Time // ザラバの終了
+	close    time.Time // 引け
+	end      time.Time // 引けの板寄せの終了
+}
+
+// between - 寄付から引けの板寄せが終わるまでの間か
+func (p *sessionPeriod) between(now time.Time) bool {
+	return !now.Before(p.open) && now.Before(p.end)
+}
+
+// isRegular - ザラバの間か
+func (p *sessionPeriod) isRegular(now time.Time) bool {
+	return !now.Before(p.open) && now.Before(p.preClose)
+}
+
+// isClosing - 引けの板寄せの間か
+func (p *sessionPeriod) isClosing(now time.Time) bool {
+	return !now.Before(p.close) && now.Before(p.end)
 }
@@ -6,38 +6,50 @@ import (
 	"time"
 )
 
-func Test_newTimeRange(t *testing.T) {
+func Test_SessionSchedule_WithHalfDays(t *testing.T) {
+	t.Parallel()
+	schedule := DefaultStockSessionSchedule()
+	want := SessionSchedule{
+		Sessions: schedule.Sessions,
+		SpecialSessions: []SpecialSession{
+			{Day: time.Date(2021, 12, 30, 0, 0, 0, 0, time.Local), Sessions: schedule.Sessions[:1]},
+			{Day: time.Date(2022, 1, 4, 0, 0, 0, 0, time.Local), Sessions: schedule.Sessions[:1]},
+		},
+	}
+	got := schedule.WithHalfDays(time.Date(2021, 12, 30, 0, 0, 0, 0, time.Local), time.Date(2022, 1, 4, 0, 0, 0, 0, time.Local))
+	if !reflect.DeepEqual(want, got) || len(schedule.SpecialSessions) != 0 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v, %+v\n", t.Name(), want, got, schedule)
+	}
+}
+
+func Test_newTradingSchedule(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
-		name                               string
-		arg1, arg2, arg3, arg4, arg5, arg6 int
-		want                               *timeRange
+		name string
+		arg  map[ExchangeType]SessionSchedule
+		want *tradingSchedule
 	}{
-		{name: "from < to", arg1: 9, arg2: 0, arg3: 0, arg4: 15, arg5: 0, arg6: 0,
-			want: &timeRange{
-				from:   time.Date(0, 1, 1, 9, 0, 0, 0, time.Local),
-				to:     time.Date(0, 1, 1, 15, 0, 0, 0, time.Local),
-				isBack: false,
-			}},
-		{name: "from = to", arg1: 9, arg2: 0, arg3: 0, arg4: 9, arg5: 0, arg6: 0,
-			want: &timeRange{
-				from:   time.Date(0, 1, 1, 9, 0, 0, 0, time.Local),
-				to:     time.Date(0, 1, 1, 9, 0, 0, 0, time.Local),
-				isBack: true,
-			}},
-		{name: "from > to", arg1: 16, arg2: 30, arg3: 0, arg4: 5, arg5: 30, arg6: 5,
-			want: &timeRange{
-				from:   time.Date(0, 1, 1, 16, 30, 0, 0, time.Local),
-				to:     time.Date(0, 1, 1, 5, 30, 5, 0, time.Local),
-				isBack: true,
-			}},
+		{name: "nilなら通常の取引時間",
+			arg: nil,
+			want: &tradingSchedule{schedules: map[ExchangeType]*exchangeSchedule{
+				ExchangeTypeStock:  {sessions: DefaultStockSessionSchedule().Sessions, specialSessions: map[string][]TradingSession{}},
+				ExchangeTypeFuture: {sessions: DefaultFutureSessionSchedule().Sessions, specialSessions: map[string][]TradingSession{}},
+			}}},
+		{name: "指定した市場種別だけ指定した取引時間になる",
+			arg: map[ExchangeType]SessionSchedule{ExchangeTypeStock: ExtendedStockSessionSchedule().WithHalfDays(time.Date(2021, 12, 30, 0, 0, 0, 0, time.Local))},
+			want: &tradingSchedule{schedules: map[ExchangeType]*exchangeSchedule{
+				ExchangeTypeStock: {
+					sessions:        ExtendedStockSessionSchedule().Sessions,
+					specialSessions: map[string][]TradingSession{"2021-12-30": ExtendedStockSessionSchedule().Sessions[:1]}},
+				ExchangeTypeFuture: {sessions: DefaultFutureSessionSchedule().Sessions, specialSessions: map[string][]TradingSession{}},
+			}}},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			got := newTimeRange(test.arg1, test.arg2, test.arg3, test.arg4, test.arg5, test.arg6)
+			got := newTradingSchedule(test.arg)
 			if !reflect.DeepEqual(test.want, got) {
 				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
 			}
@@ -45,89 +57,47 @@ func Test_newTimeRange(t *testing.T) {
 	}
 }
 
-func Test_timeRange_between(t *testing.T) {
+func Test_newSessionPeriod(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
-		name      string
-		timeRange *timeRange
-		arg       time.Time
-		want      bool
+		name string
+		arg  TradingSession
+		want *sessionPeriod
 	}{
-		{name: "from < toでdayStartと一致",
-			timeRange: newTimeRange(9, 0, 0, 15, 0, 5),
-			arg:       time.Date(2021, 5, 4, 0, 0, 0, 0, time.Local),
-			want:      false},
-		{name: "from < toでdayStartとfromの間",
-			timeRange: newTimeRange(9, 0, 0, 15, 0, 5),
-			arg:       time.Date(2021, 5, 4, 8, 59, 59, 999999999, time.Local),
-			want:      false},
-		{name: "from < toでfromと一致",
-			timeRange: newTimeRange(9, 0, 0, 15, 0, 5),
-			arg:       time.Date(2021, 5, 4, 9, 0, 0, 0, time.Local),
-			want:      true},
-		{name: "from < toでfromとtoの間",
-			timeRange: newTimeRange(9, 0, 0, 15, 0, 5),
-			arg:       time.Date(2021, 5, 4, 12, 0, 0, 0, time.Local),
-			want:      true},
-		{name: "from < toでtoと一致",
-			timeRange: newTimeRange(9, 0, 0, 15, 0, 5),
-			arg:       time.Date(2021, 5, 4, 15, 0, 5, 0, time.Local),
-			want:      false},
-		{name: "from < toでtoとdayEndの間",
-			timeRange: newTimeRange(9, 0, 0, 15, 0, 5),
-			arg:       time.Date(2021, 5, 4, 15, 0, 6, 0, time.Local),
-			want:      false},
-		{name: "from < toでdayEndと一致",
-			timeRange: newTimeRange(9, 0, 0, 15, 0, 5),
-			arg:       time.Date(2021, 5, 4, 23, 59, 59, 999999999, time.Local),
-			want:      false},
-		{name: "from = toでdayStartとfromの間",
-			timeRange: newTimeRange(7, 0, 0, 7, 0, 0),
-			arg:       time.Date(2021, 5, 4, 6, 59, 59, 999999999, time.Local),
-			want:      true},
-		{name: "from = toでfromとtoと一致",
-			timeRange: newTimeRange(7, 0, 0, 7, 0, 0),
-			arg:       time.Date(2021, 5, 4, 7, 0, 0, 0, time.Local),
-			want:      true},
-		{name: "from = toでtoとdayEndの間",
-			timeRange: newTimeRange(7, 0, 0, 7, 0, 0),
-			arg:       time.Date(2021, 5, 4, 23, 59, 59, 999999999, time.Local),
-			want:      true},
-		{name: "from > toでdayStartと一致",
-			timeRange: newTimeRange(16, 30, 0, 5, 30, 5),
-			arg:       time.Date(2021, 5, 4, 0, 0, 0, 0, time.Local),
-			want:      true},
-		{name: "from > toでdayStartとtoの間",
-			timeRange: newTimeRange(16, 30, 0, 5, 30, 5),
-			arg:       time.Date(2021, 5, 4, 5, 30, 4, 999999999, time.Local),
-			want:      true},
-		{name: "from > toでtoと一致",
-			timeRange: newTimeRange(16, 30, 0, 5, 30, 5),
-			arg:       time.Date(2021, 5, 4, 5, 30, 5, 0, time.Local),
-			want:      false},
-		{name: "from > toでfromとtoの間",
-			timeRange: newTimeRange(16, 30, 0, 5, 30, 5),
-			arg:       time.Date(2021, 5, 4, 5, 30, 6, 0, time.Local),
-			want:      false},
-		{name: "from > toでfromと一致",
-			timeRange: newTimeRange(16, 30, 0, 5, 30, 5),
-			arg:       time.Date(2021, 5, 4, 16, 30, 0, 0, time.Local),
-			want:      true},
-		{name: "from > toでfromとdayEndの間",
-			timeRange: newTimeRange(16, 30, 0, 5, 30, 5),
-			arg:       time.Date(2021, 5, 4, 16, 30, 1, 0, time.Local),
-			want:      true},
-		{name: "from > toでdayEndと一致",
-			timeRange: newTimeRange(16, 30, 0, 5, 30, 5),
-			arg:       time.Date(2021, 5, 4, 23, 59, 59, 999999999, time.Local),
-			want:      true},
+		{name: "プレ・クロージングがなければ引けまでザラバ",
+			arg: TradingSession{Session: SessionMorning, Open: 9 * time.Hour, Close: 11*time.Hour + 30*time.Minute},
+			want: &sessionPeriod{
+				session:  SessionMorning,
+				day:      time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local),
+				open:     time.Date(2021, 9, 1, 9, 0, 0, 0, time.Local),
+				preClose: time.Date(2021, 9, 1, 11, 30, 0, 0, time.Local),
+				close:    time.Date(2021, 9, 1, 11, 30, 0, 0, time.Local),
+				end:      time.Date(2021, 9, 1, 11, 30, 5, 0, time.Local)}},
+		{name: "プレ・クロージングがあればその時刻でザラバが終わる",
+			arg: TradingSession{Session: SessionAfternoon, Open: 12*time.Hour + 30*time.Minute, PreClose: 15*time.Hour + 25*time.Minute, Close: 15*time.Hour + 30*time.Minute},
+			want: &sessionPeriod{
+				session:  SessionAfternoon,
+				day:      time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local),
+				open:     time.Date(2021, 9, 1, 12, 30, 0, 0, time.Local),
+				preClose: time.Date(2021, 9, 1, 15, 25, 0, 0, time.Local),
+				close:    time.Date(2021, 9, 1, 15, 30, 0, 0, time.Local),
+				end:      time.Date(2021, 9, 1, 15, 30, 5, 0, time.Local)}},
+		{name: "引けが寄付より前なら翌日の引けになる",
+			arg: TradingSession{Session: SessionNight, Open: 17 * time.Hour, PreClose: 5*time.Hour + 55*time.Minute, Close: 6 * time.Hour},
+			want: &sessionPeriod{
+				session:  SessionNight,
+				day:      time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local),
+				open:     time.Date(2021, 9, 1, 17, 0, 0, 0, time.Local),
+				preClose: time.Date(2021, 9, 2, 5, 55, 0, 0, time.Local),
+				close:    time.Date(2021, 9, 2, 6, 0, 0, 0, time.Local),
+				end:      time.Date(2021, 9, 2, 6, 0, 5, 0, time.Local)}},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			got := test.timeRange.between(test.arg)
+			got := newSessionPeriod(test.arg, time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local))
 			if !reflect.DeepEqual(test.want, got) {
 				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
 			}
@@ -135,30 +105,129 @@ func Test_timeRange_between(t *testing.T) {
 	}
 }
 
-func Test_newTimeRanges(t *testing.T) {
+func Test_sessionPeriod_phase(t *testing.T) {
+	t.Parallel()
+	period := newSessionPeriod(TradingSession{Session: SessionDay, Open: 8*time.Hour + 45*time.Minute, PreClose: 15*time.Hour + 40*time.Minute, Close: 15*time.Hour + 45*time.Minute}, time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local))
+	tests := []struct {
+		name          string
+		arg           time.Time
+		wantBetween   bool
+		wantRegular   bool
+		wantIsClosing bool
+	}{
+		{name: "寄付より前ならセッション外", arg: time.Date(2021, 9, 1, 8, 44, 59, 0, time.Local)},
+		{name: "寄付ならザラバ", arg: time.Date(2021, 9, 1, 8, 45, 0, 0, time.Local), wantBetween: true, wantRegular: true},
+		{name: "プレ・クロージングならザラバでも引けでもない", arg: time.Date(2021, 9, 1, 15, 40, 0, 0, time.Local), wantBetween: true},
+		{name: "引けなら引け", arg: time.Date(2021, 9, 1, 15, 45, 0, 0, time.Local), wantBetween: true, wantIsClosing: true},
+		{name: "引けの板寄せが終わったらセッション外", arg: time.Date(2021, 9, 1, 15, 45, 5, 0, time.Local)},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			gotBetween, gotRegular, gotIsClosing := period.between(test.arg), period.isRegular(test.arg), period.isClosing(test.arg)
+			if test.wantBetween != gotBetween || test.wantRegular != gotRegular || test.wantIsClosing != gotIsClosing {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(), test.wantBetween, test.wantRegular, test.wantIsClosing, gotBetween, gotRegular, gotIsClosing)
+			}
+		})
+	}
+}
+
+func Test_tradingSchedule_sessionAt(t *testing.T) {
+	t.Parallel()
+	extended := newTradingSchedule(map[ExchangeType]SessionSchedule{
+		ExchangeTypeStock: ExtendedStockSessionSchedule().WithHalfDays(time.Date(2021, 12, 30, 0, 0, 0, 0, time.Local)),
+	})
+	tests := []struct {
+		name         string
+		schedule     *tradingSchedule
+		arg1         ExchangeType
+		arg2         time.Time
+		wantSession  Session
+		wantDay      time.Time
+		wantNotFound bool
+	}{
+		{name: "nilなら通常の取引時間で前場",
+			arg1:        ExchangeTypeStock,
+			arg2:        time.Date(2021, 9, 1, 11, 30, 4, 0, time.Local),
+			wantSession: SessionMorning,
+			wantDay:     time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)},
+		{name: "nilなら通常の取引時間で15:00からは後場の引け後",
+			arg1:         ExchangeTypeStock,
+			arg2:         time.Date(2021, 9, 1, 15, 10, 0, 0, time.Local),
+			wantNotFound: true},
+		{name: "延長した取引時間なら15:10も後場",
+			schedule:    extended,
+			arg1:        ExchangeTypeStock,
+			arg2:        time.Date(2021, 9, 1, 15, 10, 0, 0, time.Local),
+			wantSession: SessionAfternoon,
+			wantDay:     time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)},
+		{name: "信用は現物の取引時間を使う",
+			schedule:    extended,
+			arg1:        ExchangeTypeMargin,
+			arg2:        time.Date(2021, 9, 1, 15, 29, 0, 0, time.Local),
+			wantSession: SessionAfternoon,
+			wantDay:     time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)},
+		{name: "半日立会の日は後場がない",
+			schedule:     extended,
+			arg1:         ExchangeTypeStock,
+			arg2:         time.Date(2021, 12, 30, 13, 0, 0, 0, time.Local),
+			wantNotFound: true},
+		{name: "半日立会の日も前場はある",
+			schedule:    extended,
+			arg1:        ExchangeTypeStock,
+			arg2:        time.Date(2021, 12, 30, 10, 0, 0, 0, time.Local),
+			wantSession: SessionMorning,
+			wantDay:     time.Date(2021, 12, 30, 0, 0, 0, 0, time.Local)},
+		{name: "先物の夜間の日付が変わる前は当日に始まったセッション",
+			arg1:        ExchangeTypeFuture,
+			arg2:        time.Date(2021, 9, 1, 23, 0, 0, 0, time.Local),
+			wantSession: SessionNight,
+			wantDay:     time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)},
+		{name: "先物の夜間の日付が変わった後は前日に始まったセッション",
+			arg1:        ExchangeTypeFuture,
+			arg2:        time.Date(2021, 9, 2, 3, 0, 0, 0, time.Local),
+			wantSession: SessionNight,
+			wantDay:     time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)},
+		{name: "市場種別が未指定ならセッションはない",
+			arg1:         ExchangeTypeUnspecified,
+			arg2:         time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local),
+			wantNotFound: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.schedule.sessionAt(test.arg1, test.arg2)
+			if (got == nil) != test.wantNotFound || (got != nil && (test.wantSession != got.session || !test.wantDay.Equal(got.day))) {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v\n", t.Name(), test.wantNotFound, test.wantSession, test.wantDay, got)
+			}
+		})
+	}
+}
+
+func Test_tradingSchedule_isClosing(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
 		name string
-		args []*timeRange
-		want *timeRanges
+		arg1 Session
+		arg2 time.Time
+		want bool
 	}{
-		{name: "引数ひとつ",
-			args: []*timeRange{newTimeRange(8, 0, 0, 15, 0, 0)},
-			want: &timeRanges{timeRanges: []*timeRange{newTimeRange(8, 0, 0, 15, 0, 0)}}},
-		{name: "引数みっつ",
-			args: []*timeRange{
-				newTimeRange(9, 0, 0, 11, 30, 0),
-				newTimeRange(12, 30, 0, 15, 0, 0)},
-			want: &timeRanges{[]*timeRange{
-				newTimeRange(9, 0, 0, 11, 30, 0),
-				newTimeRange(12, 30, 0, 15, 0, 0)}}},
+		{name: "前場の引けで前場を指定したらtrue", arg1: SessionMorning, arg2: time.Date(2021, 9, 1, 11, 30, 0, 0, time.Local), want: true},
+		{name: "前場の引けで後場を指定したらfalse", arg1: SessionAfternoon, arg2: time.Date(2021, 9, 1, 11, 30, 0, 0, time.Local), want: false},
+		{name: "後場のザラバで後場を指定したらfalse", arg1: SessionAfternoon, arg2: time.Date(2021, 9, 1, 14, 0, 0, 0, time.Local), want: false},
+		{name: "セッション外ならfalse", arg1: SessionAfternoon, arg2: time.Date(2021, 9, 1, 16, 0, 0, 0, time.Local), want: false},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			got := newTimeRanges(test.args...)
+			var schedule *tradingSchedule
+			got := schedule.isClosing(ExchangeTypeStock, test.arg1, test.arg2)
 			if !reflect.DeepEqual(test.want, got) {
 				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
 			}
@@ -166,39 +235,31 @@ func Test_newTimeRanges(t *testing.T) {
 	}
 }
 
-func Test_timeRanges_between(t *testing.T) {
+func Test_tradingSchedule_nextBoundary(t *testing.T) {
 	t.Parallel()
+	extended := newTradingSchedule(map[ExchangeType]SessionSchedule{ExchangeTypeStock: ExtendedStockSessionSchedule()})
 	tests := []struct {
-		name       string
-		timeRanges *timeRanges
-		arg        time.Time
-		want       bool
+		name     string
+		schedule *tradingSchedule
+		arg      time.Time
+		want     time.Time
 	}{
-		{name: "rangesが空",
-			timeRanges: &timeRanges{timeRanges: []*timeRange{}},
-			arg:        time.Date(2021, 5, 4, 5, 35, 0, 0, time.Local),
-			want:       false},
-		{name: "rangesに要素があるけどマッチしない",
-			timeRanges: &timeRanges{timeRanges: []*timeRange{
-				newTimeRange(8, 45, 0, 15, 15, 0),
-				newTimeRange(16, 30, 0, 5, 30, 0),
-			}},
-			arg:  time.Date(2021, 5, 4, 5, 35, 0, 0, time.Local),
-			want: false},
-		{name: "rangesに複数要素があり、マッチするものがある",
-			timeRanges: &timeRanges{timeRanges: []*timeRange{
-				newTimeRange(8, 45, 0, 15, 15, 0),
-				newTimeRange(16, 30, 0, 5, 30, 0),
-			}},
-			arg:  time.Date(2021, 5, 4, 5, 0, 0, 0, time.Local),
-			want: true},
+		{name: "日付の変わり目なら次の先物の夜間のザラバ終了", arg: time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local), want: time.Date(2021, 9, 1, 5, 55, 0, 0, time.Local)},
+		{name: "前場の途中なら前場の終了", arg: time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local), want: time.Date(2021, 9, 1, 11, 30, 0, 0, time.Local)},
+		{name: "前場の終了なら前場の引けの終了", arg: time.Date(2021, 9, 1, 11, 30, 0, 0, time.Local), want: time.Date(2021, 9, 1, 11, 30, 5, 0, time.Local)},
+		{name: "先物の日中の引けの途中なら日中の引けの終了", arg: time.Date(2021, 9, 1, 15, 45, 0, 0, time.Local), want: time.Date(2021, 9, 1, 15, 45, 5, 0, time.Local)},
+		{name: "先物の夜間の開始なら日付の変わり目", arg: time.Date(2021, 9, 1, 17, 0, 0, 0, time.Local), want: time.Date(2021, 9, 2, 0, 0, 0, 0, time.Local)},
+		{name: "日付の変わる直前なら翌日の日付の変わり目", arg: time.Date(2021, 9, 1, 23, 59, 59, 0, time.Local), want: time.Date(2021, 9, 2, 0, 0, 0, 0, time.Local)},
+		{name: "通常の取引時間なら後場の途中は後場の終了", arg: time.Date(2021, 9, 1, 14, 0, 0, 0, time.Local), want: time.Date(2021, 9, 1, 15, 0, 0, 0, time.Local)},
+		{name: "延長した取引時間なら後場の途中はクロージング・オークションの開始", schedule: extended, arg: time.Date(2021, 9, 1, 14, 0, 0, 0, time.Local), want: time.Date(2021, 9, 1, 15, 25, 0, 0, time.Local)},
+		{name: "延長した取引時間ならクロージング・オークションの途中は引け", schedule: extended, arg: time.Date(2021, 9, 1, 15, 25, 0, 0, time.Local), want: time.Date(2021, 9, 1, 15, 30, 0, 0, time.Local)},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			got := test.timeRanges.between(test.arg)
+			got := test.schedule.nextBoundary(test.arg)
 			if !reflect.DeepEqual(test.want, got) {
 				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
 			}
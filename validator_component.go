@@ -10,13 +10,19 @@ func newValidatorComponent() iValidatorComponent {
 }
 
 type iValidatorComponent interface {
-	isValidStockOrder(order *stockOrder, now time.Time, positions []*stockPosition) error
-	isValidMarginOrder(order *marginOrder, now time.Time, positions []*marginPosition) error
+	isValidStockOrder(order *stockOrder, businessDay time.Time, symbol *stockSymbol, positions []*stockPosition) error
+	isValidMarginOrder(order *marginOrder, businessDay time.Time, symbol *stockSymbol, positions []*marginPosition) error
+	isValidFutureOrder(order *futureOrder, businessDay time.Time, symbol *futureSymbol, positions []*futurePosition) error
+	isValidStockOrderModification(order *stockOrder, modification *ModifyOrderRequest, businessDay time.Time, symbol *stockSymbol) error
+	isValidMarginOrderModification(order *marginOrder, modification *ModifyOrderRequest, businessDay time.Time, symbol *stockSymbol) error
 }
 
 type validatorComponent struct{}
 
-func (c *validatorComponent) isValidStockOrder(order *stockOrder, now time.Time, positions []*stockPosition) error {
+// isValidStockOrder - 現物注文のチェック
+//   指値や逆指値の価格は、銘柄の呼値の単位に合っている必要がある (銘柄が登録されていなければその他の銘柄の表を使う)
+//   指値は、銘柄の基準値段から決まる値幅制限の範囲内である必要がある
+func (c *validatorComponent) isValidStockOrder(order *stockOrder, businessDay time.Time, symbol *stockSymbol, positions []*stockPosition) error {
 	if !order.Side.isValid() {
 		return InvalidSideError
 	}
@@ -32,7 +38,7 @@ func (c *validatorComponent) isValidStockOrder(order *stockOrder, now time.Time,
 	if order.ExecutionCondition.IsLimitOrder() && order.LimitPrice <= 0 {
 		return InvalidLimitPriceError
 	}
-	if order.ExpiredAt.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)) {
+	if order.ExpiredAt.Before(time.Date(businessDay.Year(), businessDay.Month(), businessDay.Day(), 0, 0, 0, 0, time.Local)) {
 		return InvalidExpiredError
 	}
 	if order.ExecutionCondition.IsStop() && (order.StopCondition == nil ||
@@ -41,11 +47,60 @@ func (c *validatorComponent) isValidStockOrder(order *stockOrder, now time.Time,
 		(order.StopCondition.ExecutionConditionAfterHit.IsLimitOrder() && order.StopCondition.LimitPriceAfterHit <= 0)) {
 		return InvalidStopConditionError
 	}
+	if err := c.isValidTickPrices(order.ExecutionCondition, order.LimitPrice, order.StopCondition, symbol.tickSizeTable()); err != nil {
+		return err
+	}
+	if err := c.isValidPriceLimits(order.ExecutionCondition, order.LimitPrice, order.StopCondition, symbol, businessDay); err != nil {
+		return err
+	}
+
+	if !order.ExitPositionOrder.isValid() {
+		return InvalidExitPositionOrderError
+	}
+
+	// 売りでなければエグジットするポジションは指定できない
+	if order.Side != SideSell && len(order.ExitPositionList) > 0 {
+		return InvalidExitPositionError
+	}
+
+	// 売りでエグジットするポジションが指定されていれば、指定したポジションの数量の合計と注文数量が一致している必要がある
+	if order.Side == SideSell && len(order.ExitPositionList) > 0 {
+		var totalExitQuantity float64
+		for _, e := range order.ExitPositionList {
+			totalExitQuantity += e.Quantity
+		}
+		if order.OrderQuantity != totalExitQuantity {
+			return InvalidExitQuantityError
+		}
+
+		// 指定したポジションは同じ銘柄で、エグジットできるだけの保有数が必要
+		//   同じポジションを複数回指定することはできない
+		positionMap := map[string]*stockPosition{}
+		for _, p := range positions {
+			if p.SymbolCode == order.SymbolCode && p.OwnedQuantity > 0 {
+				positionMap[p.Code] = p
+			}
+		}
+		exited := map[string]bool{}
+		for _, e := range order.ExitPositionList {
+			p, ok := positionMap[e.PositionCode]
+			if !ok || e.Quantity <= 0 || exited[e.PositionCode] {
+				return fmt.Errorf("position code: %s: %w", e.PositionCode, InvalidExitPositionCodeError)
+			}
+			exited[e.PositionCode] = true
+			if p.orderableQuantity() < e.Quantity {
+				return fmt.Errorf("position code: %s, exitable quantity: %.2f: %w", e.PositionCode, p.orderableQuantity(), NotEnoughOwnedQuantityError)
+			}
+		}
+	}
 
 	// 売りなら指定した銘柄の保有数が注文数以上必要
 	if order.Side == SideSell {
 		var totalQuantity float64
 		for _, p := range positions {
+			if p.SymbolCode != order.SymbolCode {
+				continue
+			}
 			totalQuantity += p.orderableQuantity()
 		}
 		if totalQuantity < order.OrderQuantity {
@@ -56,7 +111,10 @@ func (c *validatorComponent) isValidStockOrder(order *stockOrder, now time.Time,
 	return nil
 }
 
-func (c *validatorComponent) isValidMarginOrder(order *marginOrder, now time.Time, positions []*marginPosition) error {
+// isValidMarginOrder - 信用注文のチェック
+//   指値や逆指値の価格は、銘柄の呼値の単位に合っている必要がある (銘柄が登録されていなければその他の銘柄の表を使う)
+//   指値は、銘柄の基準値段から決まる値幅制限の範囲内である必要がある
+func (c *validatorComponent) isValidMarginOrder(order *marginOrder, businessDay time.Time, symbol *stockSymbol, positions []*marginPosition) error {
 	if !order.TradeType.isValid() {
 		return InvalidTradeTypeError
 	}
@@ -75,7 +133,7 @@ func (c *validatorComponent) isValidMarginOrder(order *marginOrder, now time.Tim
 	if order.ExecutionCondition.IsLimitOrder() && order.LimitPrice <= 0 {
 		return InvalidLimitPriceError
 	}
-	if order.ExpiredAt.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)) {
+	if order.ExpiredAt.Before(time.Date(businessDay.Year(), businessDay.Month(), businessDay.Day(), 0, 0, 0, 0, time.Local)) {
 		return InvalidExpiredError
 	}
 	if order.ExecutionCondition.IsStop() && (order.StopCondition == nil ||
@@ -84,6 +142,12 @@ func (c *validatorComponent) isValidMarginOrder(order *marginOrder, now time.Tim
 		(order.StopCondition.ExecutionConditionAfterHit.IsLimitOrder() && order.StopCondition.LimitPriceAfterHit <= 0)) {
 		return InvalidStopConditionError
 	}
+	if err := c.isValidTickPrices(order.ExecutionCondition, order.LimitPrice, order.StopCondition, symbol.tickSizeTable()); err != nil {
+		return err
+	}
+	if err := c.isValidPriceLimits(order.ExecutionCondition, order.LimitPrice, order.StopCondition, symbol, businessDay); err != nil {
+		return err
+	}
 
 	// Exitでエグジットポジションが指定されていなければエラー
 	if order.TradeType == TradeTypeExit && (order.ExitPositionList == nil || len(order.ExitPositionList) == 0) {
@@ -100,17 +164,177 @@ func (c *validatorComponent) isValidMarginOrder(order *marginOrder, now time.Tim
 	}
 
 	// Exitで指定されたポジションがエグジットできなければエラー
+	//   同じポジションを複数回指定することはできない
 	positionMap := map[string]*marginPosition{}
 	for _, p := range positions {
 		if p.OwnedQuantity > 0 {
 			positionMap[p.Code] = p
 		}
 	}
+	exited := map[string]bool{}
+	for _, e := range order.ExitPositionList {
+		p, ok := positionMap[e.PositionCode]
+		if !ok || exited[e.PositionCode] {
+			return fmt.Errorf("position code: %s: %w", e.PositionCode, InvalidExitPositionCodeError)
+		}
+		exited[e.PositionCode] = true
+		if p.OwnedQuantity-p.HoldQuantity < e.Quantity {
+			return fmt.Errorf("position code: %s, exitable quantity: %.2f: %w", e.PositionCode, p.OwnedQuantity-p.HoldQuantity, NotEnoughOwnedQuantityError)
+		}
+	}
+
+	return nil
+}
+
+// isValidStockOrderModification - 現物注文の訂正のチェック
+func (c *validatorComponent) isValidStockOrderModification(order *stockOrder, modification *ModifyOrderRequest, businessDay time.Time, symbol *stockSymbol) error {
+	if order == nil || modification == nil {
+		return NilArgumentError
+	}
+	return c.isValidModification(order.OrderStatus, order.ExecutionCondition, order.OrderQuantity, order.ContractedQuantity, modification, businessDay, symbol)
+}
+
+// isValidMarginOrderModification - 信用注文の訂正のチェック
+func (c *validatorComponent) isValidMarginOrderModification(order *marginOrder, modification *ModifyOrderRequest, businessDay time.Time, symbol *stockSymbol) error {
+	if order == nil || modification == nil {
+		return NilArgumentError
+	}
+	return c.isValidModification(order.OrderStatus, order.ExecutionCondition, order.OrderQuantity, order.ContractedQuantity, modification, businessDay, symbol)
+}
+
+// isValidModification - 注文の訂正のチェック
+//   訂正できるのは取消できる状態の注文で、指値価格、注文数量、有効期限の少なくとも1つを訂正する必要がある
+//   指値価格は指値の注文だけ訂正でき、新規の注文と同じく呼値の単位と値幅制限に合っている必要がある
+//   注文数量は減らすことだけでき、約定済みの数量より多く残す必要がある
+func (c *validatorComponent) isValidModification(status OrderStatus, executionCondition StockExecutionCondition, orderQuantity float64, contractedQuantity float64, modification *ModifyOrderRequest, businessDay time.Time, symbol *stockSymbol) error {
+	if !status.IsCancelable() {
+		return UnmodifiableOrderError
+	}
+	if modification.LimitPrice == 0 && modification.OrderQuantity == 0 && modification.ExpiredAt.IsZero() {
+		return InvalidModificationError
+	}
+	if modification.LimitPrice < 0 || (modification.LimitPrice > 0 && !executionCondition.IsLimitOrder()) {
+		return InvalidLimitPriceError
+	}
+	if modification.LimitPrice > 0 {
+		if err := c.isValidTickPrices(executionCondition, modification.LimitPrice, nil, symbol.tickSizeTable()); err != nil {
+			return err
+		}
+		if err := c.isValidPriceLimits(executionCondition, modification.LimitPrice, nil, symbol, businessDay); err != nil {
+			return err
+		}
+	}
+	if modification.OrderQuantity < 0 ||
+		(modification.OrderQuantity > 0 && (orderQuantity <= modification.OrderQuantity || modification.OrderQuantity <= contractedQuantity)) {
+		return InvalidQuantityError
+	}
+	if !modification.ExpiredAt.IsZero() && modification.ExpiredAt.Before(time.Date(businessDay.Year(), businessDay.Month(), businessDay.Day(), 0, 0, 0, 0, time.Local)) {
+		return InvalidExpiredError
+	}
+	return nil
+}
+
+// isValidTickPrices - 指値、逆指値の価格、逆指値発動後の指値が呼値の単位に合っているかのチェック
+func (c *validatorComponent) isValidTickPrices(executionCondition StockExecutionCondition, limitPrice float64, stopCondition *StockStopCondition, table TickSizeTable) error {
+	if executionCondition.IsLimitOrder() && !table.IsValidPrice(limitPrice) {
+		return fmt.Errorf("limit price: %v: %w", limitPrice, InvalidTickSizeError)
+	}
+	if executionCondition.IsStop() && stopCondition != nil {
+		if !table.IsValidPrice(stopCondition.StopPrice) {
+			return fmt.Errorf("stop price: %v: %w", stopCondition.StopPrice, InvalidTickSizeError)
+		}
+		if stopCondition.ExecutionConditionAfterHit.IsLimitOrder() && !table.IsValidPrice(stopCondition.LimitPriceAfterHit) {
+			return fmt.Errorf("limit price after hit: %v: %w", stopCondition.LimitPriceAfterHit, InvalidTickSizeError)
+		}
+	}
+	return nil
+}
+
+// isValidPriceLimits - 指値と逆指値発動後の指値が、値幅制限の範囲内かのチェック
+//   価格の登録で基準値段を決めたときと同じように、clockで求めた営業日の基準値段から値幅制限を決め、基準値段がなければチェックしない
+func (c *validatorComponent) isValidPriceLimits(executionCondition StockExecutionCondition, limitPrice float64, stopCondition *StockStopCondition, symbol *stockSymbol, businessDay time.Time) error {
+	lower, upper := symbol.priceLimits(businessDay)
+	if upper <= 0 {
+		return nil
+	}
+
+	if executionCondition.IsLimitOrder() && (limitPrice < lower || upper < limitPrice) {
+		return fmt.Errorf("limit price: %v, price limits: %v-%v: %w", limitPrice, lower, upper, PriceLimitExceededError)
+	}
+	if executionCondition.IsStop() && stopCondition != nil && stopCondition.ExecutionConditionAfterHit.IsLimitOrder() &&
+		(stopCondition.LimitPriceAfterHit < lower || upper < stopCondition.LimitPriceAfterHit) {
+		return fmt.Errorf("limit price after hit: %v, price limits: %v-%v: %w", stopCondition.LimitPriceAfterHit, lower, upper, PriceLimitExceededError)
+	}
+	return nil
+}
+
+// isValidFutureOrder - 先物注文のチェック
+//   有効期限と取引最終日は、夜間から翌営業日になる先物の営業日で比較する
+func (c *validatorComponent) isValidFutureOrder(order *futureOrder, businessDay time.Time, symbol *futureSymbol, positions []*futurePosition) error {
+	if !order.TradeType.isValid() {
+		return InvalidTradeTypeError
+	}
+	if !order.Side.isValid() {
+		return InvalidSideError
+	}
+	if !order.ExecutionCondition.isValid() {
+		return InvalidExecutionConditionError
+	}
+	if order.SymbolCode == "" {
+		return InvalidSymbolCodeError
+	}
+	if symbol == nil {
+		return fmt.Errorf("symbol code: %s: %w", order.SymbolCode, UnregisteredSymbolError)
+	}
+	if !symbol.isTradable(businessDay) {
+		return fmt.Errorf("symbol code: %s: %w", order.SymbolCode, ExpiredSymbolError)
+	}
+	if order.OrderQuantity <= 0 {
+		return InvalidQuantityError
+	}
+	if order.ExecutionCondition.IsLimitOrder() && order.LimitPrice <= 0 {
+		return InvalidLimitPriceError
+	}
+	if order.ExpiredAt.Before(businessDay) {
+		return InvalidExpiredError
+	}
+	if order.ExecutionCondition.IsStop() && (order.StopCondition == nil ||
+		order.StopCondition.StopPrice <= 0 ||
+		order.StopCondition.ExecutionConditionAfterHit.IsStop() ||
+		!order.StopCondition.ExecutionConditionAfterHit.isValid() ||
+		(order.StopCondition.ExecutionConditionAfterHit.IsLimitOrder() && order.StopCondition.LimitPriceAfterHit <= 0)) {
+		return InvalidStopConditionError
+	}
+
+	// Exitでエグジットポジションが指定されていなければエラー
+	if order.TradeType == TradeTypeExit && (order.ExitPositionList == nil || len(order.ExitPositionList) == 0) {
+		return InvalidExitPositionError
+	}
+
+	// ExitPositionListで指定された数量とQuantityが一致していなければエラー
+	var totalExitQuantity float64
+	for _, p := range order.ExitPositionList {
+		totalExitQuantity += p.Quantity
+	}
+	if order.TradeType == TradeTypeExit && order.OrderQuantity != totalExitQuantity {
+		return InvalidExitQuantityError
+	}
+
+	// Exitで指定されたポジションが注文の銘柄でないか、エグジットできなければエラー
+	//   同じポジションを複数回指定することはできない
+	positionMap := map[string]*futurePosition{}
+	for _, p := range positions {
+		if p.OwnedQuantity > 0 && p.SymbolCode == order.SymbolCode {
+			positionMap[p.Code] = p
+		}
+	}
+	exited := map[string]bool{}
 	for _, e := range order.ExitPositionList {
 		p, ok := positionMap[e.PositionCode]
-		if !ok {
+		if !ok || exited[e.PositionCode] {
 			return fmt.Errorf("position code: %s: %w", e.PositionCode, InvalidExitPositionCodeError)
 		}
+		exited[e.PositionCode] = true
 		if p.OwnedQuantity-p.HoldQuantity < e.Quantity {
 			return fmt.Errorf("position code: %s, exitable quantity: %.2f: %w", e.PositionCode, p.OwnedQuantity-p.HoldQuantity, NotEnoughOwnedQuantityError)
 		}
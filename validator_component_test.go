@@ -8,18 +8,32 @@ import (
 
 type testValidatorComponent struct {
 	iValidatorComponent
-	isValidStockOrder1  error
-	isValidMarginOrder1 error
+	isValidStockOrder1   error
+	isValidMarginOrder1  error
+	isValidFutureOrder1  error
+	isValidModification1 error
 }
 
-func (t *testValidatorComponent) isValidStockOrder(*stockOrder, time.Time, []*stockPosition) error {
+func (t *testValidatorComponent) isValidStockOrderModification(*stockOrder, *ModifyOrderRequest, time.Time, *stockSymbol) error {
+	return t.isValidModification1
+}
+
+func (t *testValidatorComponent) isValidMarginOrderModification(*marginOrder, *ModifyOrderRequest, time.Time, *stockSymbol) error {
+	return t.isValidModification1
+}
+
+func (t *testValidatorComponent) isValidStockOrder(*stockOrder, time.Time, *stockSymbol, []*stockPosition) error {
 	return t.isValidStockOrder1
 }
 
-func (t *testValidatorComponent) isValidMarginOrder(*marginOrder, time.Time, []*marginPosition) error {
+func (t *testValidatorComponent) isValidMarginOrder(*marginOrder, time.Time, *stockSymbol, []*marginPosition) error {
 	return t.isValidMarginOrder1
 }
 
+func (t *testValidatorComponent) isValidFutureOrder(*futureOrder, time.Time, *futureSymbol, []*futurePosition) error {
+	return t.isValidFutureOrder1
+}
+
 func Test_validatorComponent_isValidMarginOrder(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -242,6 +256,19 @@ func Test_validatorComponent_isValidMarginOrder(t *testing.T) {
 			arg2: time.Date(2021, 8, 19, 14, 0, 0, 0, time.Local),
 			arg3: []*marginPosition{{Code: "mpo-01", OwnedQuantity: 100, HoldQuantity: 0}, {Code: "mpo-02", OwnedQuantity: 100, HoldQuantity: 70}, {Code: "mpo-03", OwnedQuantity: 50, HoldQuantity: 40}},
 			want: NotEnoughOwnedQuantityError},
+		{name: "Exitで同じポジションを複数回指定したらエラー",
+			arg1: &marginOrder{
+				TradeType:          TradeTypeExit,
+				Side:               SideBuy,
+				ExecutionCondition: StockExecutionConditionMO,
+				SymbolCode:         "1234",
+				OrderQuantity:      100,
+				ExpiredAt:          time.Date(2021, 8, 19, 0, 0, 0, 0, time.Local),
+				ExitPositionList:   []ExitPosition{{PositionCode: "mpo-01", Quantity: 50}, {PositionCode: "mpo-01", Quantity: 50}},
+			},
+			arg2: time.Date(2021, 8, 19, 14, 0, 0, 0, time.Local),
+			arg3: []*marginPosition{{Code: "mpo-01", OwnedQuantity: 100, HoldQuantity: 0}},
+			want: InvalidExitPositionCodeError},
 		{name: "ExitでExitするポジションがあればエラーなし",
 			arg1: &marginOrder{
 				TradeType:          TradeTypeExit,
@@ -262,7 +289,7 @@ func Test_validatorComponent_isValidMarginOrder(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 			component := &validatorComponent{}
-			got := component.isValidMarginOrder(test.arg1, test.arg2, test.arg3)
+			got := component.isValidMarginOrder(test.arg1, test.arg2, nil, test.arg3)
 			if !errors.Is(got, test.want) {
 				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
 			}
@@ -427,7 +454,193 @@ func Test_validatorComponent_isValidStockOrder(t *testing.T) {
 				ExpiredAt:          time.Date(2021, 8, 19, 0, 0, 0, 0, time.Local),
 			},
 			arg2: time.Date(2021, 8, 19, 14, 0, 0, 0, time.Local),
-			arg3: []*stockPosition{{Code: "spo-01", OwnedQuantity: 100, HoldQuantity: 80}, {Code: "spo-02", OwnedQuantity: 100, HoldQuantity: 70}, {Code: "spo-03", OwnedQuantity: 50, HoldQuantity: 0}},
+			arg3: []*stockPosition{{Code: "spo-01", SymbolCode: "1234", OwnedQuantity: 100, HoldQuantity: 80}, {Code: "spo-02", SymbolCode: "1234", OwnedQuantity: 100, HoldQuantity: 70}, {Code: "spo-03", SymbolCode: "1234", OwnedQuantity: 50, HoldQuantity: 0}},
+			want: nil},
+		{name: "Sellで別銘柄のポジションは保有数に含めない",
+			arg1: &stockOrder{
+				Side:               SideSell,
+				ExecutionCondition: StockExecutionConditionMO,
+				SymbolCode:         "1234",
+				OrderQuantity:      100,
+				ExpiredAt:          time.Date(2021, 8, 19, 0, 0, 0, 0, time.Local),
+			},
+			arg2: time.Date(2021, 8, 19, 14, 0, 0, 0, time.Local),
+			arg3: []*stockPosition{{Code: "spo-01", SymbolCode: "1234", OwnedQuantity: 50}, {Code: "spo-02", SymbolCode: "5678", OwnedQuantity: 100}},
+			want: NotEnoughOwnedQuantityError},
+		{name: "エグジット順序が不正ならエラー",
+			arg1: &stockOrder{
+				Side:               SideSell,
+				ExecutionCondition: StockExecutionConditionMO,
+				SymbolCode:         "1234",
+				OrderQuantity:      100,
+				ExpiredAt:          time.Date(2021, 8, 19, 0, 0, 0, 0, time.Local),
+				ExitPositionOrder:  "foo",
+			},
+			arg2: time.Date(2021, 8, 19, 14, 0, 0, 0, time.Local),
+			arg3: []*stockPosition{{Code: "spo-01", SymbolCode: "1234", OwnedQuantity: 100}},
+			want: InvalidExitPositionOrderError},
+		{name: "Buyでエグジットポジションを指定したらエラー",
+			arg1: &stockOrder{
+				Side:               SideBuy,
+				ExecutionCondition: StockExecutionConditionMO,
+				SymbolCode:         "1234",
+				OrderQuantity:      100,
+				ExpiredAt:          time.Date(2021, 8, 19, 0, 0, 0, 0, time.Local),
+				ExitPositionList:   []ExitPosition{{PositionCode: "spo-01", Quantity: 100}},
+			},
+			arg2: time.Date(2021, 8, 19, 14, 0, 0, 0, time.Local),
+			arg3: []*stockPosition{{Code: "spo-01", SymbolCode: "1234", OwnedQuantity: 100}},
+			want: InvalidExitPositionError},
+		{name: "Sellでエグジットポジションの数量の合計と注文数量が一致しなければエラー",
+			arg1: &stockOrder{
+				Side:               SideSell,
+				ExecutionCondition: StockExecutionConditionMO,
+				SymbolCode:         "1234",
+				OrderQuantity:      100,
+				ExpiredAt:          time.Date(2021, 8, 19, 0, 0, 0, 0, time.Local),
+				ExitPositionList:   []ExitPosition{{PositionCode: "spo-01", Quantity: 50}},
+			},
+			arg2: time.Date(2021, 8, 19, 14, 0, 0, 0, time.Local),
+			arg3: []*stockPosition{{Code: "spo-01", SymbolCode: "1234", OwnedQuantity: 100}},
+			want: InvalidExitQuantityError},
+		{name: "Sellで別銘柄のポジションをエグジットポジションに指定したらエラー",
+			arg1: &stockOrder{
+				Side:               SideSell,
+				ExecutionCondition: StockExecutionConditionMO,
+				SymbolCode:         "1234",
+				OrderQuantity:      100,
+				ExpiredAt:          time.Date(2021, 8, 19, 0, 0, 0, 0, time.Local),
+				ExitPositionList:   []ExitPosition{{PositionCode: "spo-02", Quantity: 100}},
+			},
+			arg2: time.Date(2021, 8, 19, 14, 0, 0, 0, time.Local),
+			arg3: []*stockPosition{{Code: "spo-01", SymbolCode: "1234", OwnedQuantity: 100}, {Code: "spo-02", SymbolCode: "5678", OwnedQuantity: 100}},
+			want: InvalidExitPositionCodeError},
+		{name: "Sellでエグジットポジションの保有数が足りなければエラー",
+			arg1: &stockOrder{
+				Side:               SideSell,
+				ExecutionCondition: StockExecutionConditionMO,
+				SymbolCode:         "1234",
+				OrderQuantity:      100,
+				ExpiredAt:          time.Date(2021, 8, 19, 0, 0, 0, 0, time.Local),
+				ExitPositionList:   []ExitPosition{{PositionCode: "spo-01", Quantity: 100}},
+			},
+			arg2: time.Date(2021, 8, 19, 14, 0, 0, 0, time.Local),
+			arg3: []*stockPosition{{Code: "spo-01", SymbolCode: "1234", OwnedQuantity: 100, HoldQuantity: 30}, {Code: "spo-02", SymbolCode: "1234", OwnedQuantity: 100}},
+			want: NotEnoughOwnedQuantityError},
+		{name: "Sellで同じエグジットポジションを複数回指定したらエラー",
+			arg1: &stockOrder{
+				Side:               SideSell,
+				ExecutionCondition: StockExecutionConditionMO,
+				SymbolCode:         "1234",
+				OrderQuantity:      200,
+				ExpiredAt:          time.Date(2021, 8, 19, 0, 0, 0, 0, time.Local),
+				ExitPositionList:   []ExitPosition{{PositionCode: "spo-01", Quantity: 100}, {PositionCode: "spo-01", Quantity: 100}},
+			},
+			arg2: time.Date(2021, 8, 19, 14, 0, 0, 0, time.Local),
+			arg3: []*stockPosition{{Code: "spo-01", SymbolCode: "1234", OwnedQuantity: 100}, {Code: "spo-02", SymbolCode: "1234", OwnedQuantity: 100}},
+			want: InvalidExitPositionCodeError},
+		{name: "Sellでエグジットポジションを指定してエグジットできればエラーなし",
+			arg1: &stockOrder{
+				Side:               SideSell,
+				ExecutionCondition: StockExecutionConditionMO,
+				SymbolCode:         "1234",
+				OrderQuantity:      100,
+				ExpiredAt:          time.Date(2021, 8, 19, 0, 0, 0, 0, time.Local),
+				ExitPositionList:   []ExitPosition{{PositionCode: "spo-01", Quantity: 30}, {PositionCode: "spo-02", Quantity: 70}},
+			},
+			arg2: time.Date(2021, 8, 19, 14, 0, 0, 0, time.Local),
+			arg3: []*stockPosition{{Code: "spo-01", SymbolCode: "1234", OwnedQuantity: 100, HoldQuantity: 70}, {Code: "spo-02", SymbolCode: "1234", OwnedQuantity: 100}},
+			want: nil},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			component := &validatorComponent{}
+			got := component.isValidStockOrder(test.arg1, test.arg2, nil, test.arg3)
+			if !errors.Is(got, test.want) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_validatorComponent_isValidFutureOrder(t *testing.T) {
+	t.Parallel()
+	businessDay := time.Date(2021, 6, 4, 0, 0, 0, 0, time.Local)
+	symbol := &futureSymbol{SymbolCode: "167060019", Multiplier: 100, LastTradingDay: time.Date(2021, 6, 10, 0, 0, 0, 0, time.Local)}
+	tests := []struct {
+		name string
+		arg1 *futureOrder
+		arg3 *futureSymbol
+		arg4 []*futurePosition
+		want error
+	}{
+		{name: "取引種別が不明ならエラー",
+			arg1: &futureOrder{Side: SideBuy, ExecutionCondition: FutureExecutionConditionMO, SymbolCode: "167060019", OrderQuantity: 1, ExpiredAt: businessDay},
+			arg3: symbol,
+			want: InvalidTradeTypeError},
+		{name: "執行条件が不明ならエラー",
+			arg1: &futureOrder{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionCondition("foo"), SymbolCode: "167060019", OrderQuantity: 1, ExpiredAt: businessDay},
+			arg3: symbol,
+			want: InvalidExecutionConditionError},
+		{name: "銘柄が登録されていなければエラー",
+			arg1: &futureOrder{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionMO, SymbolCode: "167060019", OrderQuantity: 1, ExpiredAt: businessDay},
+			arg3: nil,
+			want: UnregisteredSymbolError},
+		{name: "取引最終日を過ぎた銘柄ならエラー",
+			arg1: &futureOrder{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionMO, SymbolCode: "167060019", OrderQuantity: 1, ExpiredAt: businessDay},
+			arg3: &futureSymbol{SymbolCode: "167060019", Multiplier: 100, LastTradingDay: time.Date(2021, 6, 3, 0, 0, 0, 0, time.Local)},
+			want: ExpiredSymbolError},
+		{name: "SQ済みの銘柄ならエラー",
+			arg1: &futureOrder{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionMO, SymbolCode: "167060019", OrderQuantity: 1, ExpiredAt: businessDay},
+			arg3: &futureSymbol{SymbolCode: "167060019", Multiplier: 100, IsSQSettled: true},
+			want: ExpiredSymbolError},
+		{name: "数量が0以下ならエラー",
+			arg1: &futureOrder{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionMO, SymbolCode: "167060019", OrderQuantity: 0, ExpiredAt: businessDay},
+			arg3: symbol,
+			want: InvalidQuantityError},
+		{name: "指値で指値価格がなければエラー",
+			arg1: &futureOrder{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionLOC, SymbolCode: "167060019", OrderQuantity: 1, ExpiredAt: businessDay},
+			arg3: symbol,
+			want: InvalidLimitPriceError},
+		{name: "有効期限が営業日より前ならエラー",
+			arg1: &futureOrder{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionMO, SymbolCode: "167060019", OrderQuantity: 1, ExpiredAt: time.Date(2021, 6, 3, 0, 0, 0, 0, time.Local)},
+			arg3: symbol,
+			want: InvalidExpiredError},
+		{name: "逆指値で発動後の執行条件が逆指値ならエラー",
+			arg1: &futureOrder{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionStop, SymbolCode: "167060019", OrderQuantity: 1, ExpiredAt: businessDay,
+				StopCondition: &FutureStopCondition{StopPrice: 29000, ComparisonOperator: ComparisonOperatorGE, ExecutionConditionAfterHit: FutureExecutionConditionStop}},
+			arg3: symbol,
+			want: InvalidStopConditionError},
+		{name: "返済で別の銘柄のポジションを指定したらエラー",
+			arg1: &futureOrder{TradeType: TradeTypeExit, Side: SideSell, ExecutionCondition: FutureExecutionConditionMO, SymbolCode: "167060019", OrderQuantity: 1, ExpiredAt: businessDay,
+				ExitPositionList: []ExitPosition{{PositionCode: "fpo-01", Quantity: 1}}},
+			arg3: symbol,
+			arg4: []*futurePosition{{Code: "fpo-01", SymbolCode: "167060018", OwnedQuantity: 1}},
+			want: InvalidExitPositionCodeError},
+		{name: "返済で同じポジションを複数回指定したらエラー",
+			arg1: &futureOrder{TradeType: TradeTypeExit, Side: SideSell, ExecutionCondition: FutureExecutionConditionMO, SymbolCode: "167060019", OrderQuantity: 2, ExpiredAt: businessDay,
+				ExitPositionList: []ExitPosition{{PositionCode: "fpo-01", Quantity: 1}, {PositionCode: "fpo-01", Quantity: 1}}},
+			arg3: symbol,
+			arg4: []*futurePosition{{Code: "fpo-01", SymbolCode: "167060019", OwnedQuantity: 2}},
+			want: InvalidExitPositionCodeError},
+		{name: "返済でポジションの返済可能数量が足りなければエラー",
+			arg1: &futureOrder{TradeType: TradeTypeExit, Side: SideSell, ExecutionCondition: FutureExecutionConditionMO, SymbolCode: "167060019", OrderQuantity: 2, ExpiredAt: businessDay,
+				ExitPositionList: []ExitPosition{{PositionCode: "fpo-01", Quantity: 2}}},
+			arg3: symbol,
+			arg4: []*futurePosition{{Code: "fpo-01", SymbolCode: "167060019", OwnedQuantity: 2, HoldQuantity: 1}},
+			want: NotEnoughOwnedQuantityError},
+		{name: "取引最終日の新規注文ならエラーなし",
+			arg1: &futureOrder{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionMO, SymbolCode: "167060019", OrderQuantity: 1, ExpiredAt: time.Date(2021, 6, 10, 0, 0, 0, 0, time.Local)},
+			arg3: &futureSymbol{SymbolCode: "167060019", Multiplier: 100, LastTradingDay: businessDay},
+			want: nil},
+		{name: "返済可能なポジションを指定した返済注文ならエラーなし",
+			arg1: &futureOrder{TradeType: TradeTypeExit, Side: SideSell, ExecutionCondition: FutureExecutionConditionLO, LimitPrice: 29000, SymbolCode: "167060019", OrderQuantity: 2, ExpiredAt: businessDay,
+				ExitPositionList: []ExitPosition{{PositionCode: "fpo-01", Quantity: 2}}},
+			arg3: symbol,
+			arg4: []*futurePosition{{Code: "fpo-01", SymbolCode: "167060019", OwnedQuantity: 2}},
 			want: nil},
 	}
 
@@ -436,10 +649,168 @@ func Test_validatorComponent_isValidStockOrder(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 			component := &validatorComponent{}
-			got := component.isValidStockOrder(test.arg1, test.arg2, test.arg3)
+			got := component.isValidFutureOrder(test.arg1, businessDay, test.arg3, test.arg4)
 			if !errors.Is(got, test.want) {
 				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
 			}
 		})
 	}
 }
+
+func Test_validatorComponent_isValidTickPrices(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name               string
+		executionCondition StockExecutionCondition
+		limitPrice         float64
+		stopCondition      *StockStopCondition
+		table              TickSizeTable
+		want               error
+	}{
+		{name: "成行なら指値を見ない", executionCondition: StockExecutionConditionMO, limitPrice: 1000.5, table: TickSizeTableStandard, want: nil},
+		{name: "指値が呼値の単位に合っていなければエラー", executionCondition: StockExecutionConditionLO, limitPrice: 3001, table: TickSizeTableStandard, want: InvalidTickSizeError},
+		{name: "指値が呼値の単位に合っていればエラーなし", executionCondition: StockExecutionConditionLO, limitPrice: 3005, table: TickSizeTableStandard, want: nil},
+		{name: "TOPIX500構成銘柄なら小数の指値も呼値の単位に合う", executionCondition: StockExecutionConditionLO, limitPrice: 999.9, table: TickSizeTableTopix500, want: nil},
+		{name: "逆指値の価格が呼値の単位に合っていなければエラー",
+			executionCondition: StockExecutionConditionStop,
+			stopCondition:      &StockStopCondition{StopPrice: 1000.5, ComparisonOperator: ComparisonOperatorGE, ExecutionConditionAfterHit: StockExecutionConditionMO},
+			table:              TickSizeTableStandard,
+			want:               InvalidTickSizeError},
+		{name: "逆指値発動後の指値が呼値の単位に合っていなければエラー",
+			executionCondition: StockExecutionConditionStop,
+			stopCondition:      &StockStopCondition{StopPrice: 1000, ComparisonOperator: ComparisonOperatorGE, ExecutionConditionAfterHit: StockExecutionConditionLO, LimitPriceAfterHit: 1000.5},
+			table:              TickSizeTableStandard,
+			want:               InvalidTickSizeError},
+		{name: "逆指値の価格と発動後の指値が呼値の単位に合っていればエラーなし",
+			executionCondition: StockExecutionConditionStop,
+			stopCondition:      &StockStopCondition{StopPrice: 1000, ComparisonOperator: ComparisonOperatorGE, ExecutionConditionAfterHit: StockExecutionConditionLO, LimitPriceAfterHit: 1001},
+			table:              TickSizeTableStandard,
+			want:               nil},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			component := &validatorComponent{}
+			got := component.isValidTickPrices(test.executionCondition, test.limitPrice, test.stopCondition, test.table)
+			if !errors.Is(got, test.want) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_validatorComponent_isValidPriceLimits(t *testing.T) {
+	t.Parallel()
+	businessDay := time.Date(2021, 9, 2, 0, 0, 0, 0, time.Local)
+	symbol := &stockSymbol{SymbolCode: "1234", BasePrice: 1_000}
+	tests := []struct {
+		name               string
+		executionCondition StockExecutionCondition
+		limitPrice         float64
+		stopCondition      *StockStopCondition
+		symbol             *stockSymbol
+		businessDay        time.Time
+		want               error
+	}{
+		{name: "基準値段がなければチェックしない", executionCondition: StockExecutionConditionLO, limitPrice: 5_000, symbol: nil, want: nil},
+		{name: "成行なら指値を見ない", executionCondition: StockExecutionConditionMO, limitPrice: 5_000, symbol: symbol, want: nil},
+		{name: "指値が上限より高ければエラー", executionCondition: StockExecutionConditionLO, limitPrice: 1_301, symbol: symbol, want: PriceLimitExceededError},
+		{name: "指値が下限より安ければエラー", executionCondition: StockExecutionConditionLO, limitPrice: 699, symbol: symbol, want: PriceLimitExceededError},
+		{name: "指値が上限ちょうどならエラーなし", executionCondition: StockExecutionConditionLO, limitPrice: 1_300, symbol: symbol, want: nil},
+		{name: "指値が下限ちょうどならエラーなし", executionCondition: StockExecutionConditionLO, limitPrice: 700, symbol: symbol, want: nil},
+		{name: "前日終値があれば前日終値から値幅制限を決める",
+			executionCondition: StockExecutionConditionLO,
+			limitPrice:         1_301,
+			symbol:             &stockSymbol{SymbolCode: "1234", BasePrice: 1_000, LastPrice: 1_100, LastBusinessDay: time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)},
+			want:               nil},
+		{name: "価格を登録した営業日と同じ営業日なら、その日の基準値段から値幅制限を決める",
+			executionCondition: StockExecutionConditionLO,
+			limitPrice:         1_301,
+			symbol:             &stockSymbol{SymbolCode: "1234", BasePrice: 1_000, LastPrice: 1_100, LastBusinessDay: time.Date(2021, 9, 2, 0, 0, 0, 0, time.FixedZone("UTC+14", 14*60*60))},
+			businessDay:        time.Date(2021, 9, 2, 0, 0, 0, 0, time.FixedZone("UTC+14", 14*60*60)),
+			want:               PriceLimitExceededError},
+		{name: "逆指値発動後の指値が範囲外ならエラー",
+			executionCondition: StockExecutionConditionStop,
+			stopCondition:      &StockStopCondition{StopPrice: 1_200, ComparisonOperator: ComparisonOperatorGE, ExecutionConditionAfterHit: StockExecutionConditionLO, LimitPriceAfterHit: 1_400},
+			symbol:             symbol,
+			want:               PriceLimitExceededError},
+		{name: "逆指値発動後が成行なら指値を見ない",
+			executionCondition: StockExecutionConditionStop,
+			stopCondition:      &StockStopCondition{StopPrice: 1_200, ComparisonOperator: ComparisonOperatorGE, ExecutionConditionAfterHit: StockExecutionConditionMO, LimitPriceAfterHit: 1_400},
+			symbol:             symbol,
+			want:               nil},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			day := test.businessDay
+			if day.IsZero() {
+				day = businessDay
+			}
+			component := &validatorComponent{}
+			got := component.isValidPriceLimits(test.executionCondition, test.limitPrice, test.stopCondition, test.symbol, day)
+			if !errors.Is(got, test.want) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_validatorComponent_isValidModification(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2021, 9, 2, 10, 0, 0, 0, time.Local)
+	symbol := &stockSymbol{SymbolCode: "1234", BasePrice: 1_000}
+	tests := []struct {
+		name               string
+		status             OrderStatus
+		executionCondition StockExecutionCondition
+		modification       *ModifyOrderRequest
+		want               error
+	}{
+		{name: "取消できない状態の注文ならエラー", status: OrderStatusDone, executionCondition: StockExecutionConditionLO, modification: &ModifyOrderRequest{LimitPrice: 1_001}, want: UnmodifiableOrderError},
+		{name: "何も訂正しなければエラー", status: OrderStatusInOrder, executionCondition: StockExecutionConditionLO, modification: &ModifyOrderRequest{}, want: InvalidModificationError},
+		{name: "指値がマイナスならエラー", status: OrderStatusInOrder, executionCondition: StockExecutionConditionLO, modification: &ModifyOrderRequest{LimitPrice: -1}, want: InvalidLimitPriceError},
+		{name: "指値でない注文の指値は訂正できない", status: OrderStatusInOrder, executionCondition: StockExecutionConditionMO, modification: &ModifyOrderRequest{LimitPrice: 1_001}, want: InvalidLimitPriceError},
+		{name: "逆指値注文の指値は訂正できない", status: OrderStatusWait, executionCondition: StockExecutionConditionStop, modification: &ModifyOrderRequest{LimitPrice: 1_001}, want: InvalidLimitPriceError},
+		{name: "指値が呼値の単位に合っていなければエラー", status: OrderStatusInOrder, executionCondition: StockExecutionConditionLO, modification: &ModifyOrderRequest{LimitPrice: 1_000.5}, want: InvalidTickSizeError},
+		{name: "指値が値幅制限を超えていればエラー", status: OrderStatusInOrder, executionCondition: StockExecutionConditionLO, modification: &ModifyOrderRequest{LimitPrice: 1_301}, want: PriceLimitExceededError},
+		{name: "指値を訂正できる", status: OrderStatusPart, executionCondition: StockExecutionConditionLO, modification: &ModifyOrderRequest{LimitPrice: 1_300}, want: nil},
+		{name: "注文数量がマイナスならエラー", status: OrderStatusInOrder, executionCondition: StockExecutionConditionMO, modification: &ModifyOrderRequest{OrderQuantity: -1}, want: InvalidQuantityError},
+		{name: "注文数量は増やせない", status: OrderStatusInOrder, executionCondition: StockExecutionConditionMO, modification: &ModifyOrderRequest{OrderQuantity: 400}, want: InvalidQuantityError},
+		{name: "注文数量が同じならエラー", status: OrderStatusInOrder, executionCondition: StockExecutionConditionMO, modification: &ModifyOrderRequest{OrderQuantity: 300}, want: InvalidQuantityError},
+		{name: "約定済みの数量以下にはできない", status: OrderStatusPart, executionCondition: StockExecutionConditionMO, modification: &ModifyOrderRequest{OrderQuantity: 100}, want: InvalidQuantityError},
+		{name: "注文数量を減らせる", status: OrderStatusPart, executionCondition: StockExecutionConditionMO, modification: &ModifyOrderRequest{OrderQuantity: 200}, want: nil},
+		{name: "有効期限が過去ならエラー", status: OrderStatusInOrder, executionCondition: StockExecutionConditionMO, modification: &ModifyOrderRequest{ExpiredAt: time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)}, want: InvalidExpiredError},
+		{name: "有効期限を当日にできる", status: OrderStatusInOrder, executionCondition: StockExecutionConditionMO, modification: &ModifyOrderRequest{ExpiredAt: time.Date(2021, 9, 2, 0, 0, 0, 0, time.Local)}, want: nil},
+		{name: "逆指値注文の有効期限を訂正できる", status: OrderStatusWait, executionCondition: StockExecutionConditionStop, modification: &ModifyOrderRequest{ExpiredAt: time.Date(2021, 9, 3, 0, 0, 0, 0, time.Local)}, want: nil},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			component := &validatorComponent{}
+			got := component.isValidModification(test.status, test.executionCondition, 300, 100, test.modification, now, symbol)
+			if !errors.Is(got, test.want) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_validatorComponent_isValidOrderModification_nil(t *testing.T) {
+	t.Parallel()
+	component := &validatorComponent{}
+	now := time.Date(2021, 9, 2, 10, 0, 0, 0, time.Local)
+	got1 := component.isValidStockOrderModification(nil, &ModifyOrderRequest{}, now, nil)
+	got2 := component.isValidStockOrderModification(&stockOrder{}, nil, now, nil)
+	got3 := component.isValidMarginOrderModification(nil, &ModifyOrderRequest{}, now, nil)
+	got4 := component.isValidMarginOrderModification(&marginOrder{}, nil, now, nil)
+	if !errors.Is(got1, NilArgumentError) || !errors.Is(got2, NilArgumentError) || !errors.Is(got3, NilArgumentError) || !errors.Is(got4, NilArgumentError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v, %+v, %+v, %+v\n", t.Name(), NilArgumentError, got1, got2, got3, got4)
+	}
+}
@@ -14,6 +14,35 @@ type RegisterPriceRequest struct {
 	BidTime      time.Time    // 買気配日時
 	Ask          float64      // 売気配値
 	AskTime      time.Time    // 売気配日時
+	Volume       float64      // 前回の価格登録からの出来高 (0なら出来高の情報なし)
+	BidQuantity  float64      // 買気配数量 (0なら数量の情報なし)
+	AskQuantity  float64      // 売気配数量 (0なら数量の情報なし)
+	BidBoard     []BoardLevel // 買板 (最良気配から順に、kabuステーションなら10本)
+	AskBoard     []BoardLevel // 売板 (最良気配から順に、kabuステーションなら10本)
+}
+
+// BoardLevel - 板の1本分の気配
+type BoardLevel struct {
+	Price    float64 // 気配値
+	Quantity float64 // 気配数量 (0なら数量の情報なし)
+}
+
+// SymbolPrice - 登録されている銘柄の価格
+type SymbolPrice struct {
+	ExchangeType ExchangeType // 市場種別
+	SymbolCode   string       // 銘柄コード
+	Price        float64      // 価格
+	PriceTime    time.Time    // 価格日時
+	Bid          float64      // 買気配値
+	BidTime      time.Time    // 買気配日時
+	Ask          float64      // 売気配値
+	AskTime      time.Time    // 売気配日時
+	BidQuantity  float64      // 買気配数量
+	AskQuantity  float64      // 売気配数量
+	BidBoard     []BoardLevel // 買板
+	AskBoard     []BoardLevel // 売板
+	LowerLimit   float64      // 値幅制限の下限 (ストップ安の価格、値幅制限がなければ0)
+	UpperLimit   float64      // 値幅制限の上限 (ストップ高の価格、値幅制限がなければ0)
 }
 
 // symbolPrice - 銘柄の価格
@@ -26,9 +55,24 @@ type symbolPrice struct {
 	BidTime          time.Time    // 買気配日時
 	Ask              float64      // 売気配値
 	AskTime          time.Time    // 売気配日時
+	Volume           float64      // 前回の価格登録からの出来高
+	BidQuantity      float64      // 買気配数量
+	AskQuantity      float64      // 売気配数量
+	BidBoard         []BoardLevel // 買板
+	AskBoard         []BoardLevel // 売板
 	kind             PriceKind    // 種別
 	session          Session      // セッション
 	priceBusinessDay time.Time    // 価格日時の営業日
+	contractedVolume float64      // 出来高のうち仮想注文の約定に使った数量
+	contractedBid    float64      // 買気配数量のうち仮想注文の約定に使った数量
+	contractedAsk    float64      // 売気配数量のうち仮想注文の約定に使った数量
+	contractedBoard  boardVolumes // 板の気配値ごとの、仮想注文の約定に使った数量
+	lowerLimit       float64      // 値幅制限の下限 (値幅制限がなければ0)
+	upperLimit       float64      // 値幅制限の上限 (値幅制限がなければ0)
+	itayosePrice     float64      // 気配から求めた板寄せの約定値段 (板寄せできなければ0)
+	itayoseQuantity  float64      // 気配から求めた板寄せの約定数量
+	contractedBuy    float64      // 板寄せの約定数量のうち仮想注文の買いの約定に使った数量
+	contractedSell   float64      // 板寄せの約定数量のうち仮想注文の売りの約定に使った数量
 }
 
 func (e *symbolPrice) maxTime() time.Time {
@@ -45,6 +89,127 @@ func (e *symbolPrice) maxTime() time.Time {
 	return maxTime
 }
 
+// isItayose - 寄付や引けの、板寄せで約定させる価格か
+func (e *symbolPrice) isItayose() bool {
+	switch e.kind {
+	case PriceKindOpening, PriceKindClosing, PriceKindOpeningAndClosing:
+		return true
+	}
+	return false
+}
+
+// isLimitLocked - 現値がストップ高で買い、ストップ安で売りの注文が、ザラバでは約定しない状態か
+func (e *symbolPrice) isLimitLocked(side Side) bool {
+	if e.Price <= 0 {
+		return false
+	}
+	switch side {
+	case SideBuy:
+		return e.upperLimit > 0 && e.Price >= e.upperLimit
+	case SideSell:
+		return e.lowerLimit > 0 && e.Price <= e.lowerLimit
+	}
+	return false
+}
+
+// contractableQuantity - 約定の根拠になった価格で、指定した数量のうち約定できる数量
+//   数量の情報がなければ、指定した数量をすべて約定できるものとする
+func (e *symbolPrice) contractableQuantity(source priceSource, quantity float64) float64 {
+	var total, contracted float64
+	switch source {
+	case priceSourcePrice:
+		total, contracted = e.Volume, e.contractedVolume
+	case priceSourceBid:
+		total, contracted = e.BidQuantity, e.contractedBid
+	case priceSourceAsk:
+		total, contracted = e.AskQuantity, e.contractedAsk
+	case priceSourceItayoseBuy:
+		total, contracted = e.itayoseQuantity, e.contractedBuy
+	case priceSourceItayoseSell:
+		total, contracted = e.itayoseQuantity, e.contractedSell
+	default:
+		return quantity
+	}
+	if total <= 0 {
+		return quantity
+	}
+
+	remaining := total - contracted
+	if remaining <= 0 {
+		return 0
+	}
+	if remaining < quantity {
+		return remaining
+	}
+	return quantity
+}
+
+// contract - 仮想注文の約定に使った数量を記録し、同じ価格情報で約定できる数量を減らす
+func (e *symbolPrice) contract(source priceSource, quantity float64) {
+	switch source {
+	case priceSourcePrice:
+		e.contractedVolume += quantity
+	case priceSourceBid:
+		e.contractedBid += quantity
+	case priceSourceAsk:
+		e.contractedAsk += quantity
+	case priceSourceItayoseBuy:
+		e.contractedBuy += quantity
+	case priceSourceItayoseSell:
+		e.contractedSell += quantity
+	}
+}
+
+// boardVolumes - 板の気配値ごとの数量
+type boardVolumes map[priceSource]map[float64]float64
+
+// contractableBoardQuantity - 板の1本分の気配で、指定した数量のうち約定できる数量
+//   気配数量の情報がなければ、指定した数量をすべて約定できるものとする
+func (e *symbolPrice) contractableBoardQuantity(source priceSource, level BoardLevel, quantity float64) float64 {
+	if level.Quantity <= 0 {
+		return quantity
+	}
+
+	remaining := level.Quantity - e.contractedBoard[source][level.Price]
+	if remaining <= 0 {
+		return 0
+	}
+	if remaining < quantity {
+		return remaining
+	}
+	return quantity
+}
+
+// askBoardPrice - 買いの成行で指定した数量を売板の良い順に約定させたときに、約定する最も高い気配値 (売板がなければ0)
+//   ほかの仮想注文の約定に使った数量を除き、売板で約定しきれない数量は見積もらない
+func (e *symbolPrice) askBoardPrice(quantity float64) float64 {
+	var price, total float64
+	for _, l := range e.AskBoard {
+		if total >= quantity {
+			break
+		}
+
+		q := e.contractableBoardQuantity(priceSourceAsk, l, quantity-total)
+		if q <= 0 {
+			continue
+		}
+		price = l.Price
+		total += q
+	}
+	return price
+}
+
+// contractBoard - 板の気配で仮想注文の約定に使った数量を記録する
+func (e *symbolPrice) contractBoard(source priceSource, price float64, quantity float64) {
+	if e.contractedBoard == nil {
+		e.contractedBoard = boardVolumes{}
+	}
+	if e.contractedBoard[source] == nil {
+		e.contractedBoard[source] = map[float64]float64{}
+	}
+	e.contractedBoard[source][price] += quantity
+}
+
 // UpdatedOrders - 更新された注文
 type UpdatedOrders struct {
 	Orders []OrderSummary // 更新された注文
@@ -58,6 +223,21 @@ type OrderSummary struct {
 	Status       OrderStatus
 }
 
+// Event - 注文やポジションの変化を通知するイベント
+type Event struct {
+	EventType    EventType    // イベント種別
+	ExchangeType ExchangeType // 市場種別
+	SymbolCode   string       // 銘柄コード
+	OrderCode    string       // 注文コード (ポジションのイベントなら、ポジションを新規した注文のコード)
+	OrderStatus  OrderStatus  // 変化後の注文の状態 (ポジションのイベントなら未指定)
+	PositionCode string       // ポジションコード (注文のイベントなら、約定したポジションのコード)
+	Contract     *Contract    // 約定情報 (約定のイベントのみ)
+	OccurredAt   time.Time    // 発生日時
+}
+
+// EventHandler - イベントを受け取るハンドラ
+type EventHandler func(event Event)
+
 // StockOrder - 現物注文
 type StockOrder struct {
 	Code               string                  // 注文コード
@@ -75,6 +255,9 @@ type StockOrder struct {
 	CanceledAt         time.Time               // 取消日時
 	Contracts          []*Contract             // 約定一覧
 	Message            string                  // メッセージ
+	ExitPositionList   []ExitPosition          // エグジットポジションリスト
+	ExitPositionOrder  ExitPositionOrder       // エグジット順序
+	Modifications      []*OrderModification    // 訂正履歴
 }
 
 // StockOrderRequest - 現物注文リクエスト
@@ -85,7 +268,10 @@ type StockOrderRequest struct {
 	Quantity           float64                 // 注文数量
 	LimitPrice         float64                 // 指値価格
 	ExpiredAt          time.Time               // 有効期限
+	ExpireBusinessDays int                     // 有効期限の営業日数 (ExpiredAtの指定がなければ使い、1なら注文した営業日、2なら翌営業日までになる)
 	StopCondition      *StockStopCondition     // 現物逆指値条件
+	ExitPositionList   []ExitPosition          // エグジットポジションリスト (Sellで指定すれば、指定したポジションだけをエグジットする)
+	ExitPositionOrder  ExitPositionOrder       // エグジット順序 (Sellでエグジットポジションリストを指定しなければ、この順でポジションを割り当てる)
 }
 
 // OrderResult - 注文結果
@@ -98,6 +284,25 @@ type CancelOrderRequest struct {
 	OrderCode string // 取消対象の注文コード
 }
 
+// ModifyOrderRequest - 注文の訂正リクエスト
+type ModifyOrderRequest struct {
+	OrderCode     string    // 訂正対象の注文コード
+	LimitPrice    float64   // 訂正後の指値価格 (0なら訂正しない、指値の注文だけ訂正できる)
+	OrderQuantity float64   // 訂正後の注文数量 (0なら訂正しない、減らすことだけできる)
+	ExpiredAt     time.Time // 訂正後の有効期限 (ゼロ値なら訂正しない)
+}
+
+// OrderModification - 注文の訂正履歴
+type OrderModification struct {
+	ModifiedAt        time.Time // 訂正日時
+	PrevLimitPrice    float64   // 訂正前の指値価格
+	LimitPrice        float64   // 訂正後の指値価格
+	PrevOrderQuantity float64   // 訂正前の注文数量
+	OrderQuantity     float64   // 訂正後の注文数量
+	PrevExpiredAt     time.Time // 訂正前の有効期限
+	ExpiredAt         time.Time // 訂正後の有効期限
+}
+
 // Contract - 約定
 type Contract struct {
 	ContractCode string
@@ -105,6 +310,8 @@ type Contract struct {
 	PositionCode string
 	Price        float64
 	Quantity     float64
+	Fee          float64 // 手数料
+	HoldingCost  float64 // 返済した信用建玉の保有にかかった費用 (金利、貸株料、逆日歩、管理費)
 	ContractedAt time.Time
 }
 
@@ -128,13 +335,56 @@ type StockPosition struct {
 	HoldQuantity       float64   // 拘束数量
 	Price              float64   // 約定価格
 	ContractedAt       time.Time // 約定日時
+	CurrentPrice       float64   // 現在値 (価格がなければ0)
+	MarketValue        float64   // 評価額 (現在値がなければ0)
+	UnrealizedProfit   float64   // 評価損益 (現在値がなければ0)
 }
 
 // confirmContractResult - 約定可能かの結果
 type confirmContractResult struct {
 	isContracted bool
 	price        float64
+	quantity     float64
 	contractedAt time.Time
+	source       priceSource
+	levels       []BoardLevel    // 板を使って約定できる気配 (良い順)
+	fills        []*contractFill // 板を使って約定した価格と数量
+}
+
+// contractFill - 1つの価格での約定
+type contractFill struct {
+	price    float64
+	quantity float64
+}
+
+// contractFills - 約定した価格ごとの約定数量
+//   板を使って約定していなければ、約定価格で約定数量のすべてが約定したものとする
+func (r *confirmContractResult) contractFills() []*contractFill {
+	if len(r.fills) > 0 {
+		return r.fills
+	}
+	return []*contractFill{{price: r.price, quantity: r.quantity}}
+}
+
+// priceSource - 約定の根拠になった価格の種類
+type priceSource string
+
+const (
+	priceSourceUnspecified priceSource = ""             // 未指定
+	priceSourcePrice       priceSource = "price"        // 現値
+	priceSourceBid         priceSource = "bid"          // 買気配値
+	priceSourceAsk         priceSource = "ask"          // 売気配値
+	priceSourceItayoseBuy  priceSource = "itayose_buy"  // 板寄せの約定値段 (買い)
+	priceSourceItayoseSell priceSource = "itayose_sell" // 板寄せの約定値段 (売り)
+)
+
+// itayoseSource - 板寄せの約定値段で約定したときの、約定の根拠になった価格の種類
+//   板寄せの約定数量は、買いと売りでそれぞれ約定に使う
+func itayoseSource(side Side) priceSource {
+	if side == SideSell {
+		return priceSourceItayoseSell
+	}
+	return priceSourceItayoseBuy
 }
 
 // StockStopCondition - 逆指値条件
@@ -147,6 +397,14 @@ type StockStopCondition struct {
 	isActivate                 bool
 }
 
+// restoreActivation - 書き出されない逆指値の発動状態を、逆指値条件が満たされた日時から戻す
+func (e *StockStopCondition) restoreActivation() {
+	if e == nil {
+		return
+	}
+	e.isActivate = !e.ActivatedAt.IsZero()
+}
+
 // MarginOrderRequest - 信用注文リクエスト
 type MarginOrderRequest struct {
 	TradeType          TradeType               // 取引区分
@@ -156,6 +414,7 @@ type MarginOrderRequest struct {
 	Quantity           float64                 // 注文数量 (複数のポジションを指定してエグジットする場合は、合計数量を入れる)
 	LimitPrice         float64                 // 指値価格
 	ExpiredAt          time.Time               // 有効期限
+	ExpireBusinessDays int                     // 有効期限の営業日数 (ExpiredAtの指定がなければ使い、1なら注文した営業日、2なら翌営業日までになる)
 	StopCondition      *StockStopCondition     // 現物逆指値条件
 	ExitPositionList   []ExitPosition          // エグジットポジションリスト
 }
@@ -184,19 +443,178 @@ type MarginOrder struct {
 	CanceledAt         time.Time               // 取消日時
 	Contracts          []*Contract             // 約定一覧
 	Message            string                  // メッセージ
+	Modifications      []*OrderModification    // 訂正履歴
 }
 
 // MarginPosition - 信用ポジション
 type MarginPosition struct {
+	Code               string     // ポジションコード
+	OrderCode          string     // 注文コード
+	SymbolCode         string     // 銘柄コード
+	Side               Side       // 売買方向
+	ContractedQuantity float64    // 約定数量
+	OwnedQuantity      float64    // 保有数量
+	HoldQuantity       float64    // 拘束数量
+	Price              float64    // 約定価格
+	ContractedAt       time.Time  // 約定日時
+	CurrentPrice       float64    // 現在値 (価格がなければ0)
+	MarketValue        float64    // 評価額 (現在値がなければ0)
+	UnrealizedProfit   float64    // 評価損益 (現在値がなければ0)
+	HoldingCost        MarginCost // 保有数量を今返済したときにかかる費用 (返済時に損益から差し引かれる)
+}
+
+// StockSymbol - 株式銘柄の情報 (現物と信用で共通)
+type StockSymbol struct {
+	SymbolCode    string        // 銘柄コード
+	TickSizeTable TickSizeTable // 呼値の単位の表 (未指定ならその他の銘柄の表)
+	BasePrice     float64       // 基準値段 (前日終値、0なら変更しない)
+}
+
+// FutureSymbol - 先物銘柄の情報
+type FutureSymbol struct {
+	SymbolCode     string    // 銘柄コード
+	Multiplier     float64   // 取引単位 (日経225miniなら100)
+	LastTradingDay time.Time // 取引最終日 (ゼロ値なら期限なし)
+}
+
+// FutureStopCondition - 先物逆指値条件
+type FutureStopCondition struct {
+	StopPrice                  float64                  // 逆指値発動価格
+	ComparisonOperator         ComparisonOperator       // 比較方法
+	ExecutionConditionAfterHit FutureExecutionCondition // 逆指値発動後注文条件
+	LimitPriceAfterHit         float64                  // 逆指値発動後指値価格
+	ActivatedAt                time.Time                // 逆指値条件が満たされた日時
+	isActivate                 bool
+}
+
+// restoreActivation - 書き出されない逆指値の発動状態を、逆指値条件が満たされた日時から戻す
+func (e *FutureStopCondition) restoreActivation() {
+	if e == nil {
+		return
+	}
+	e.isActivate = !e.ActivatedAt.IsZero()
+}
+
+// FutureOrderRequest - 先物注文リクエスト
+type FutureOrderRequest struct {
+	TradeType          TradeType                // 取引区分
+	Side               Side                     // 売買方向
+	ExecutionCondition FutureExecutionCondition // 先物執行条件
+	SymbolCode         string                   // 銘柄コード
+	Quantity           float64                  // 注文数量 (複数のポジションを指定してエグジットする場合は、合計数量を入れる)
+	LimitPrice         float64                  // 指値価格
+	ExpiredAt          time.Time                // 有効期限 (営業日で指定する)
+	ExpireBusinessDays int                      // 有効期限の営業日数 (ExpiredAtの指定がなければ使い、1なら注文した営業日、2なら翌営業日までになる)
+	StopCondition      *FutureStopCondition     // 先物逆指値条件
+	ExitPositionList   []ExitPosition           // エグジットポジションリスト
+}
+
+// FutureOrder - 先物注文
+type FutureOrder struct {
+	Code               string                   // 注文コード
+	OrderStatus        OrderStatus              // 状態
+	TradeType          TradeType                // 取引区分
+	Side               Side                     // 売買方向
+	ExecutionCondition FutureExecutionCondition // 先物執行条件
+	SymbolCode         string                   // 銘柄コード
+	OrderQuantity      float64                  // 注文数量
+	ContractedQuantity float64                  // 約定数量
+	CanceledQuantity   float64                  // 取消数量
+	LimitPrice         float64                  // 指値価格
+	ExpiredAt          time.Time                // 有効期限
+	StopCondition      *FutureStopCondition     // 先物逆指値条件
+	ExitPositionList   []ExitPosition           // エグジットポジションリスト
+	OrderedAt          time.Time                // 注文日時
+	CanceledAt         time.Time                // 取消日時
+	Contracts          []*Contract              // 約定一覧
+	Message            string                   // メッセージ
+}
+
+// FuturePosition - 先物ポジション
+type FuturePosition struct {
 	Code               string    // ポジションコード
 	OrderCode          string    // 注文コード
 	SymbolCode         string    // 銘柄コード
-	Side               Side      // 売買方向
+	Side               Side      // 方向
 	ContractedQuantity float64   // 約定数量
 	OwnedQuantity      float64   // 保有数量
 	HoldQuantity       float64   // 拘束数量
 	Price              float64   // 約定価格
+	SettlementPrice    float64   // 値洗いの基準になる価格 (値洗い前なら約定価格、値洗い後なら清算値)
+	Multiplier         float64   // 取引単位
 	ContractedAt       time.Time // 約定日時
+	CurrentPrice       float64   // 現在値 (価格がなければ0)
+	MarketValue        float64   // 評価額 (現在値がなければ0)
+	UnrealizedProfit   float64   // 約定価格からの評価損益 (現在値がなければ0)
+	IsExpired          bool      // 取引最終日を過ぎた銘柄のポジションか (SettleFutureSQで決済する)
+}
+
+// Account - 口座情報
+type Account struct {
+	Cash                 float64   // 預り金
+	ReservedCash         float64   // 注文で拘束している金額
+	BuyingPower          float64   // 買付余力
+	MarginDeposit        float64   // 受入保証金
+	MarginPositionAmount float64   // 信用建玉金額
+	MarginProfit         float64   // 信用建玉の評価損益
+	RequiredMargin       float64   // 必要保証金
+	MaintenanceRatio     float64   // 委託保証金維持率
+	IsMarginCall         bool      // 追証が発生しているか
+	MarginCalledAt       time.Time // 追証の発生日時
+}
+
+// Trade - 約定履歴
+//   注文やポジションが一覧から削除されても残り続ける
+type Trade struct {
+	ContractCode      string       // 約定コード
+	OrderCode         string       // 注文コード
+	PositionCode      string       // 新規なら作られたポジション、返済なら返済したポジションのコード
+	EntryContractCode string       // 返済なら返済したポジションを作った新規の約定コード
+	ExchangeType      ExchangeType // 市場種別
+	SymbolCode        string       // 銘柄コード
+	TradeType         TradeType    // 取引区分 (現物は買いが新規、売りが返済)
+	Side              Side         // 売買方向
+	Price             float64      // 約定価格
+	Quantity          float64      // 約定数量
+	Multiplier        float64      // 取引単位 (現物と信用は1)
+	EntryPrice        float64      // 返済なら新規の約定価格
+	Fee               float64      // 手数料
+	HoldingCost       float64      // 返済した信用建玉の保有にかかった費用
+	Profit            float64      // 確定した損益 (手数料と保有にかかった費用を引いたもの、新規なら手数料の分のマイナス)
+	Tax               float64      // 損益にかかる税金 (税金を計算しない場合は0、損失で戻る分はマイナス)
+	BusinessDay       time.Time    // 約定した営業日
+	ContractedAt      time.Time    // 約定日時
+}
+
+// DailyProfit - 営業日ごとの確定損益
+type DailyProfit struct {
+	BusinessDay time.Time // 営業日
+	Profit      float64   // 確定損益 (手数料を引いたもの)
+	Fee         float64   // 手数料
+	HoldingCost float64   // 信用建玉の保有にかかった費用
+	Tax         float64   // 税金
+	TradeCount  int       // 約定数
+	ExitCount   int       // 返済の約定数
+}
+
+// Portfolio - 保有しているポジションの評価の集計
+type Portfolio struct {
+	MarketValue      float64            // 評価額の合計
+	UnrealizedProfit float64            // 評価損益の合計
+	RealizedProfit   float64            // 確定損益の合計
+	HoldingCost      float64            // 信用建玉を今返済したときにかかる費用の合計
+	Symbols          []*SymbolValuation // 銘柄ごとの評価
+}
+
+// SymbolValuation - 市場種別と銘柄ごとのポジションの評価
+type SymbolValuation struct {
+	ExchangeType     ExchangeType // 市場種別
+	SymbolCode       string       // 銘柄コード
+	CurrentPrice     float64      // 現在値 (価格がなければ0)
+	BuyQuantity      float64      // 買いポジションの保有数量
+	SellQuantity     float64      // 売りポジションの保有数量
+	MarketValue      float64      // 評価額
+	UnrealizedProfit float64      // 評価損益
 }
 
 // HoldPosition - 注文が拘束しているポジションの情報
@@ -66,3 +66,311 @@ func Test_SymbolPrice_maxTime(t *testing.T) {
 		})
 	}
 }
+
+func Test_symbolPrice_isItayose(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		symbolPrice *symbolPrice
+		want        bool
+	}{
+		{name: "種別がなければfalse", symbolPrice: &symbolPrice{}, want: false},
+		{name: "寄付ならtrue", symbolPrice: &symbolPrice{kind: PriceKindOpening}, want: true},
+		{name: "ザラバならfalse", symbolPrice: &symbolPrice{kind: PriceKindRegular}, want: false},
+		{name: "引けならtrue", symbolPrice: &symbolPrice{kind: PriceKindClosing}, want: true},
+		{name: "寄り引けならtrue", symbolPrice: &symbolPrice{kind: PriceKindOpeningAndClosing}, want: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.symbolPrice.isItayose()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_itayoseSource(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		side Side
+		want priceSource
+	}{
+		{name: "買いなら板寄せの買い", side: SideBuy, want: priceSourceItayoseBuy},
+		{name: "売りなら板寄せの売り", side: SideSell, want: priceSourceItayoseSell},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := itayoseSource(test.side)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_symbolPrice_isLimitLocked(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		symbolPrice *symbolPrice
+		side        Side
+		want        bool
+	}{
+		{name: "値幅制限がなければfalse", symbolPrice: &symbolPrice{Price: 1300}, side: SideBuy, want: false},
+		{name: "現値がなければfalse", symbolPrice: &symbolPrice{lowerLimit: 700, upperLimit: 1300}, side: SideSell, want: false},
+		{name: "ストップ高の買いはtrue", symbolPrice: &symbolPrice{Price: 1300, lowerLimit: 700, upperLimit: 1300}, side: SideBuy, want: true},
+		{name: "ストップ高の売りはfalse", symbolPrice: &symbolPrice{Price: 1300, lowerLimit: 700, upperLimit: 1300}, side: SideSell, want: false},
+		{name: "ストップ安の売りはtrue", symbolPrice: &symbolPrice{Price: 700, lowerLimit: 700, upperLimit: 1300}, side: SideSell, want: true},
+		{name: "値幅制限の範囲内の買いはfalse", symbolPrice: &symbolPrice{Price: 1299, lowerLimit: 700, upperLimit: 1300}, side: SideBuy, want: false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.symbolPrice.isLimitLocked(test.side)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_symbolPrice_contractableQuantity(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		symbolPrice *symbolPrice
+		source      priceSource
+		quantity    float64
+		want        float64
+	}{
+		{name: "数量の情報がなければ指定した数量をすべて約定できる",
+			symbolPrice: &symbolPrice{},
+			source:      priceSourceAsk,
+			quantity:    300,
+			want:        300},
+		{name: "根拠が不明なら指定した数量をすべて約定できる",
+			symbolPrice: &symbolPrice{Volume: 100, BidQuantity: 100, AskQuantity: 100},
+			source:      priceSourceUnspecified,
+			quantity:    300,
+			want:        300},
+		{name: "現値なら出来高の分だけ約定できる",
+			symbolPrice: &symbolPrice{Volume: 100, BidQuantity: 200, AskQuantity: 300},
+			source:      priceSourcePrice,
+			quantity:    300,
+			want:        100},
+		{name: "買気配値なら買気配数量の分だけ約定できる",
+			symbolPrice: &symbolPrice{Volume: 100, BidQuantity: 200, AskQuantity: 300},
+			source:      priceSourceBid,
+			quantity:    300,
+			want:        200},
+		{name: "売気配値なら売気配数量から約定済みの数量を除いた分だけ約定できる",
+			symbolPrice: &symbolPrice{Volume: 100, BidQuantity: 200, AskQuantity: 300, contractedAsk: 250},
+			source:      priceSourceAsk,
+			quantity:    300,
+			want:        50},
+		{name: "数量が残っていなければ約定できない",
+			symbolPrice: &symbolPrice{Volume: 100, contractedVolume: 100},
+			source:      priceSourcePrice,
+			quantity:    300,
+			want:        0},
+		{name: "指定した数量が残りより少なければ指定した数量",
+			symbolPrice: &symbolPrice{AskQuantity: 300},
+			source:      priceSourceAsk,
+			quantity:    100,
+			want:        100},
+		{name: "板寄せの買いなら板寄せの約定数量から買いで約定済みの数量を除いた分だけ約定できる",
+			symbolPrice: &symbolPrice{Volume: 1000, itayoseQuantity: 300, contractedBuy: 200, contractedSell: 300},
+			source:      priceSourceItayoseBuy,
+			quantity:    300,
+			want:        100},
+		{name: "板寄せの売りなら板寄せの約定数量から売りで約定済みの数量を除いた分だけ約定できる",
+			symbolPrice: &symbolPrice{Volume: 1000, itayoseQuantity: 300, contractedBuy: 300, contractedSell: 50},
+			source:      priceSourceItayoseSell,
+			quantity:    300,
+			want:        250},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.symbolPrice.contractableQuantity(test.source, test.quantity)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_symbolPrice_contract(t *testing.T) {
+	t.Parallel()
+	want := &symbolPrice{Volume: 100, BidQuantity: 200, AskQuantity: 300, contractedVolume: 10, contractedBid: 20, contractedAsk: 60, contractedBuy: 50, contractedSell: 70}
+	got := &symbolPrice{Volume: 100, BidQuantity: 200, AskQuantity: 300}
+	got.contract(priceSourcePrice, 10)
+	got.contract(priceSourceBid, 20)
+	got.contract(priceSourceAsk, 30)
+	got.contract(priceSourceAsk, 30)
+	got.contract(priceSourceItayoseBuy, 50)
+	got.contract(priceSourceItayoseSell, 70)
+	got.contract(priceSourceUnspecified, 40)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_symbolPrice_contractableBoardQuantity(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		symbolPrice *symbolPrice
+		source      priceSource
+		level       BoardLevel
+		quantity    float64
+		want        float64
+	}{
+		{name: "気配数量の情報がなければ指定した数量をすべて約定できる",
+			symbolPrice: &symbolPrice{},
+			source:      priceSourceAsk,
+			level:       BoardLevel{Price: 100},
+			quantity:    300,
+			want:        300},
+		{name: "気配数量の分だけ約定できる",
+			symbolPrice: &symbolPrice{},
+			source:      priceSourceAsk,
+			level:       BoardLevel{Price: 100, Quantity: 200},
+			quantity:    300,
+			want:        200},
+		{name: "同じ気配値で約定済みの数量は除く",
+			symbolPrice: &symbolPrice{contractedBoard: boardVolumes{priceSourceAsk: {100: 150}}},
+			source:      priceSourceAsk,
+			level:       BoardLevel{Price: 100, Quantity: 200},
+			quantity:    300,
+			want:        50},
+		{name: "別の気配値や別の板で約定済みの数量は除かない",
+			symbolPrice: &symbolPrice{contractedBoard: boardVolumes{priceSourceAsk: {101: 150}, priceSourceBid: {100: 150}}},
+			source:      priceSourceAsk,
+			level:       BoardLevel{Price: 100, Quantity: 200},
+			quantity:    300,
+			want:        200},
+		{name: "数量が残っていなければ約定できない",
+			symbolPrice: &symbolPrice{contractedBoard: boardVolumes{priceSourceBid: {100: 200}}},
+			source:      priceSourceBid,
+			level:       BoardLevel{Price: 100, Quantity: 200},
+			quantity:    300,
+			want:        0},
+		{name: "指定した数量が残りより少なければ指定した数量",
+			symbolPrice: &symbolPrice{},
+			source:      priceSourceBid,
+			level:       BoardLevel{Price: 100, Quantity: 200},
+			quantity:    100,
+			want:        100},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.symbolPrice.contractableBoardQuantity(test.source, test.level, test.quantity)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_symbolPrice_askBoardPrice(t *testing.T) {
+	t.Parallel()
+	board := []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 1001, Quantity: 100}, {Price: 1002, Quantity: 100}}
+	tests := []struct {
+		name        string
+		symbolPrice *symbolPrice
+		quantity    float64
+		want        float64
+	}{
+		{name: "売板がなければ0",
+			symbolPrice: &symbolPrice{Ask: 1000},
+			quantity:    100,
+			want:        0},
+		{name: "最良気配で約定しきれば最良気配値",
+			symbolPrice: &symbolPrice{AskBoard: board},
+			quantity:    100,
+			want:        1000},
+		{name: "約定しきるまで上の気配値に進む",
+			symbolPrice: &symbolPrice{AskBoard: board},
+			quantity:    150,
+			want:        1001},
+		{name: "売板で約定しきれなければ最も高い気配値",
+			symbolPrice: &symbolPrice{AskBoard: board},
+			quantity:    500,
+			want:        1002},
+		{name: "ほかの仮想注文で約定済みの数量は除く",
+			symbolPrice: &symbolPrice{AskBoard: board, contractedBoard: boardVolumes{priceSourceAsk: {1000: 100, 1001: 50}}},
+			quantity:    100,
+			want:        1002},
+		{name: "気配数量の情報がない気配ではすべて約定できる",
+			symbolPrice: &symbolPrice{AskBoard: []BoardLevel{{Price: 1000}, {Price: 1001}}},
+			quantity:    500,
+			want:        1000},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.symbolPrice.askBoardPrice(test.quantity)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_symbolPrice_contractBoard(t *testing.T) {
+	t.Parallel()
+	want := &symbolPrice{contractedBoard: boardVolumes{priceSourceAsk: {100: 30, 101: 10}, priceSourceBid: {99: 20}}}
+	got := &symbolPrice{}
+	got.contractBoard(priceSourceAsk, 100, 10)
+	got.contractBoard(priceSourceAsk, 100, 20)
+	got.contractBoard(priceSourceAsk, 101, 10)
+	got.contractBoard(priceSourceBid, 99, 20)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_confirmContractResult_contractFills(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		result *confirmContractResult
+		want   []*contractFill
+	}{
+		{name: "気配値ごとの約定がなければ約定値と数量で1件の約定",
+			result: &confirmContractResult{isContracted: true, price: 100, quantity: 300},
+			want:   []*contractFill{{price: 100, quantity: 300}}},
+		{name: "気配値ごとの約定があればそのまま返す",
+			result: &confirmContractResult{isContracted: true, price: 100, quantity: 300, fills: []*contractFill{{price: 100, quantity: 100}, {price: 101, quantity: 200}}},
+			want:   []*contractFill{{price: 100, quantity: 100}, {price: 101, quantity: 200}}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.result.contractFills()
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
@@ -1,41 +1,206 @@
 package virtual_security
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 )
 
 func NewVirtualSecurity() VirtualSecurity {
+	accountService := newAccountService(newClock(), &account{}, false, false, map[ExchangeType]FeeSchedule{}, MarginCostRates{})
+	stockSymbolStore := getStockSymbolStore() // 現物と信用で同じ銘柄を使う
 	return &virtualSecurity{
-		clock:         newClock(),
-		priceService:  newPriceService(newClock(), getPriceStore(newClock())),
-		stockService:  newStockService(newUUIDGenerator(), getStockOrderStore(), getStockPositionStore(), newValidatorComponent(), newStockContractComponent()),
-		marginService: newMarginService(newUUIDGenerator(), getMarginOrderStore(), getMarginPositionStore(), newValidatorComponent(), newStockContractComponent()),
+		clock:           newClock(),
+		schedule:        defaultTradingSchedule,
+		priceService:    newPriceService(newClock(), getPriceStore(newClock()), defaultTradingSchedule),
+		stockService:    newStockService(newClock(), newUUIDGenerator(), getStockOrderStore(), getStockPositionStore(), stockSymbolStore, newValidatorComponent(), newStockContractComponent(defaultTradingSchedule), accountService),
+		marginService:   newMarginService(newClock(), newUUIDGenerator(), getMarginOrderStore(), getMarginPositionStore(), stockSymbolStore, newValidatorComponent(), newStockContractComponent(defaultTradingSchedule), accountService),
+		futureService:   newFutureService(newClock(), newUUIDGenerator(), getFutureOrderStore(), getFuturePositionStore(), getFutureSymbolStore(), newValidatorComponent(), newStockContractComponent(defaultTradingSchedule), accountService),
+		accountService:  accountService,
+		eventService:    newEventService(),
+		scheduleService: newScheduleService(newClock(), defaultTradingSchedule),
+		ledgerService:   newLedgerService(0),
+	}
+}
+
+// NewVirtualSecurityWithOptions - オプションを指定して仮想証券会社を生成する
+//   生成した仮想証券会社ごとに独立したストアを持つため、複数の仮想証券会社を同時に動かしても状態は共有されない
+func NewVirtualSecurityWithOptions(options ...Option) VirtualSecurity {
+	opt, clock, schedule := applyOptions(options)
+	return newVirtualSecurity(opt, clock, schedule, newStockOrderStore(), newStockPositionStore(), newMarginOrderStore(), newMarginPositionStore(), newStockSymbolStore(),
+		newFutureOrderStore(), newFuturePositionStore(), newFutureSymbolStore(), newPriceStore(clock))
+}
+
+// NewVirtualSecurityWithStoreDirectory - 口座、銘柄、注文、ポジション、価格、約定履歴をディレクトリのファイルに書き出す仮想証券会社を生成する
+//   ディレクトリにファイルがあれば読み込んでから始め、状態を変化させた処理のたびに書き出すので、プロセスを再起動しても続きから動かせる
+//   口座のファイルがあれば、WithCashで指定した預り金よりファイルの口座の状態を優先する
+//   約定履歴もファイルに書き出すので、再起動しても確定損益や税金の累計を引き継ぐ
+//   ファイルに書き出せなければ、処理そのものは反映したうえでPersistErrorを返す
+func NewVirtualSecurityWithStoreDirectory(dir string, options ...Option) (VirtualSecurity, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	opt, clock, schedule := applyOptions(options)
+	stockOrderStore, err := newFileStockOrderStore(filepath.Join(dir, "stock_orders.json"))
+	if err != nil {
+		return nil, err
+	}
+	stockPositionStore, err := newFileStockPositionStore(filepath.Join(dir, "stock_positions.json"))
+	if err != nil {
+		return nil, err
+	}
+	marginOrderStore, err := newFileMarginOrderStore(filepath.Join(dir, "margin_orders.json"))
+	if err != nil {
+		return nil, err
+	}
+	marginPositionStore, err := newFileMarginPositionStore(filepath.Join(dir, "margin_positions.json"))
+	if err != nil {
+		return nil, err
+	}
+	stockSymbolStore, err := newFileStockSymbolStore(filepath.Join(dir, "stock_symbols.json"))
+	if err != nil {
+		return nil, err
+	}
+	futureOrderStore, err := newFileFutureOrderStore(filepath.Join(dir, "future_orders.json"))
+	if err != nil {
+		return nil, err
+	}
+	futurePositionStore, err := newFileFuturePositionStore(filepath.Join(dir, "future_positions.json"))
+	if err != nil {
+		return nil, err
+	}
+	futureSymbolStore, err := newFileFutureSymbolStore(filepath.Join(dir, "future_symbols.json"))
+	if err != nil {
+		return nil, err
+	}
+	priceStore, err := newFilePriceStore(clock, filepath.Join(dir, "prices.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	security := newVirtualSecurity(opt, clock, schedule, stockOrderStore, stockPositionStore, marginOrderStore, marginPositionStore, stockSymbolStore,
+		futureOrderStore, futurePositionStore, futureSymbolStore, priceStore)
+	accountStore, err := newFileAccountStore(security.accountService, filepath.Join(dir, "account.json"))
+	if err != nil {
+		return nil, err
+	}
+	ledgerStore, err := newFileLedgerStore(security.ledgerService, filepath.Join(dir, "trades.json"))
+	if err != nil {
+		return nil, err
+	}
+	security.persistentStores = []iPersistentStore{accountStore, ledgerStore, stockOrderStore, stockPositionStore, marginOrderStore, marginPositionStore, stockSymbolStore,
+		futureOrderStore, futurePositionStore, futureSymbolStore, priceStore}
+	return security, nil
+}
+
+// applyOptions - オプションを反映した設定と、設定から決まる時計と取引時間を返す
+func applyOptions(options []Option) (*option, iClock, *tradingSchedule) {
+	opt := newOption()
+	for _, o := range options {
+		if o != nil {
+			o(opt)
+		}
+	}
+
+	schedule := newTradingSchedule(opt.sessionSchedules)
+	return opt, newClockWithCalendar(opt.clock, newCalendar(opt.holidays), schedule), schedule
+}
+
+// newVirtualSecurity - 設定と、生成する仮想証券会社だけが使うストアから仮想証券会社を生成する
+//   株式銘柄ストアは現物と信用で同じものを使う
+func newVirtualSecurity(opt *option, clock iClock, schedule *tradingSchedule,
+	stockOrderStore iStockOrderStore, stockPositionStore iStockPositionStore,
+	marginOrderStore iMarginOrderStore, marginPositionStore iMarginPositionStore, stockSymbolStore iStockSymbolStore,
+	futureOrderStore iFutureOrderStore, futurePositionStore iFuturePositionStore, futureSymbolStore iFutureSymbolStore,
+	priceStore iPriceStore) *virtualSecurity {
+	// 買付余力を管理しない場合は委託保証金も管理しない
+	acc := &account{Cash: opt.cash}
+	if opt.isCashManaged {
+		acc.InitialMarginRate = opt.initialMarginRate
+		acc.MaintenanceMarginRate = opt.maintenanceMarginRate
+	}
+	accountService := newAccountService(clock, acc, opt.isCashManaged, opt.isMarginCallLiquidation, opt.feeSchedules, opt.marginCostRates)
+
+	return &virtualSecurity{
+		clock:           clock,
+		schedule:        schedule,
+		priceService:    newPriceService(clock, priceStore, schedule),
+		stockService:    newStockService(clock, newUUIDGenerator(), stockOrderStore, stockPositionStore, stockSymbolStore, newValidatorComponent(), newStockContractComponent(schedule), accountService),
+		marginService:   newMarginService(clock, newUUIDGenerator(), marginOrderStore, marginPositionStore, stockSymbolStore, newValidatorComponent(), newStockContractComponent(schedule), accountService),
+		futureService:   newFutureService(clock, newUUIDGenerator(), futureOrderStore, futurePositionStore, futureSymbolStore, newValidatorComponent(), newStockContractComponent(schedule), accountService),
+		accountService:  accountService,
+		eventService:    newEventService(),
+		scheduleService: newScheduleService(clock, schedule),
+		ledgerService:   newLedgerService(opt.taxRate),
 	}
 }
 
 type VirtualSecurity interface {
 	RegisterPrice(symbolPrice RegisterPriceRequest) error // 銘柄価格の登録
+	Price(symbolCode string) (*SymbolPrice, error)        // 銘柄価格の取得
 
+	RegisterStockSymbol(symbol *StockSymbol) error             // 株式銘柄の登録
 	StockOrder(order *StockOrderRequest) (*OrderResult, error) // 現物注文
 	CancelStockOrder(cancelOrder *CancelOrderRequest) error    // 現物注文の取り消し
+	ModifyStockOrder(modifyOrder *ModifyOrderRequest) error    // 現物注文の訂正
 	StockOrders() ([]*StockOrder, error)                       // 現物注文一覧
 	StockPositions() ([]*StockPosition, error)                 // 現物ポジション一覧
 
 	MarginOrder(order *MarginOrderRequest) (*OrderResult, error) // 信用注文
 	CancelMarginOrder(cancelOrder *CancelOrderRequest) error     // 信用注文の取り消し
+	ModifyMarginOrder(modifyOrder *ModifyOrderRequest) error     // 信用注文の訂正
 	MarginOrders() ([]*MarginOrder, error)                       // 信用注文一覧
 	MarginPositions() ([]*MarginPosition, error)                 // 信用ポジション一覧
+
+	RegisterFutureSymbol(symbol *FutureSymbol) error             // 先物銘柄の登録
+	FutureOrder(order *FutureOrderRequest) (*OrderResult, error) // 先物注文
+	CancelFutureOrder(cancelOrder *CancelOrderRequest) error     // 先物注文の取り消し
+	FutureOrders() ([]*FutureOrder, error)                       // 先物注文一覧
+	FuturePositions() ([]*FuturePosition, error)                 // 先物ポジション一覧
+	SettleFutureSQ(symbolCode string, sqPrice float64) error     // 先物のSQによる決済
+
+	Subscribe(handler EventHandler) (func(), error) // 注文やポジションの変化の購読
+	Tick() error                                    // 現在日時までの日付やセッションの切り替わりの処理
+
+	Deposit(amount float64) error  // 入金
+	Withdraw(amount float64) error // 出金
+	Account() (*Account, error)    // 口座情報
+
+	Trades() ([]*Trade, error)             // 約定履歴
+	DailyProfits() ([]*DailyProfit, error) // 営業日ごとの確定損益
+	TotalProfit() (float64, error)         // 確定損益の合計
+	TotalTax() (float64, error)            // 確定損益にかかる税金の合計
+	Portfolio() (*Portfolio, error)        // 保有ポジションの評価
+
+	Snapshot() ([]byte, error) // 状態の書き出し
+	Restore(data []byte) error // 書き出した状態に戻す
 }
 
 type virtualSecurity struct {
-	clock         iClock
-	priceService  iPriceService
-	stockService  iStockService
-	marginService iMarginService
+	clock             iClock
+	schedule          *tradingSchedule // 取引時間 (nilなら通常の取引時間)
+	priceService      iPriceService
+	stockService      iStockService
+	marginService     iMarginService
+	futureService     iFutureService
+	accountService    iAccountService
+	eventService      iEventService
+	scheduleService   iScheduleService
+	ledgerService     iLedgerService
+	persistentStores  []iPersistentStore // 処理のたびに中身を書き出すストア
+	recordedContracts map[string]int     // 注文コードごとの台帳に記録済みの約定数
+	mtx               sync.Mutex         // 公開メソッドの処理を1つずつ行なうためのロック
 }
 
 // RegisterPrice - 価格の登録
-func (s *virtualSecurity) RegisterPrice(symbolPrice RegisterPriceRequest) error {
+func (s *virtualSecurity) RegisterPrice(symbolPrice RegisterPriceRequest) (err error) {
+	defer s.unlock(s.lock(), &err)
+
 	if err := s.priceService.validation(symbolPrice); err != nil {
 		return err
 	}
@@ -51,22 +216,167 @@ func (s *virtualSecurity) RegisterPrice(symbolPrice RegisterPriceRequest) error
 		return err
 	}
 
-	// 現物約定確認
+	// 株式なら銘柄の基準値段を更新し、値幅制限を価格情報に付ける
+	//   保存できなかった価格で銘柄の基準値段が変わらないように、保存してから更新する
+	_ = s.stockService.updateSymbolPrice(price)
+
+	// 有効期限切れの注文は約定させない
 	now := s.clock.now()
-	for _, o := range s.stockService.getStockOrders() {
-		_ = s.stockService.confirmContract(o, price, now)
+	s.expireOrders(now)
+
+	// 現物・信用・先物約定確認
+	//   板寄せなら、現物と信用は同じ板に並ぶので合わせて優先順位の順に約定させる
+	for _, o := range s.contractPriorityOrders(price, now) {
+		o.confirm()
 	}
 
-	// 信用約定確認
-	for _, o := range s.marginService.getMarginOrders() {
-		_ = s.marginService.confirmContract(o, price, now)
+	// 先物の日中の引けなら、清算値で値洗いする
+	_ = s.futureService.settle(price)
+
+	// 信用建玉を評価して委託保証金維持率を更新し、必要なら強制決済する
+	if s.marginService.evaluatePositions(s.marginPositionPrices(), now) {
+		s.liquidateMarginPositions(now)
 	}
 
 	return nil
 }
 
+// Price - 銘柄価格の取得
+//   登録されていない銘柄や、有効期限の切れた価格ならエラーを返す
+func (s *virtualSecurity) Price(symbolCode string) (*SymbolPrice, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	price, err := s.priceService.getBySymbolCode(symbolCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SymbolPrice{
+		ExchangeType: price.ExchangeType,
+		SymbolCode:   price.SymbolCode,
+		Price:        price.Price,
+		PriceTime:    price.PriceTime,
+		Bid:          price.Bid,
+		BidTime:      price.BidTime,
+		Ask:          price.Ask,
+		AskTime:      price.AskTime,
+		BidQuantity:  price.BidQuantity,
+		AskQuantity:  price.AskQuantity,
+		BidBoard:     price.BidBoard,
+		AskBoard:     price.AskBoard,
+		LowerLimit:   price.lowerLimit,
+		UpperLimit:   price.upperLimit,
+	}, nil
+}
+
+// currentPrices - 1回の処理の中で、同じ銘柄の現在値を何度も取り出さないように覚えておくもの
+type currentPrices struct {
+	priceService iPriceService
+	prices       map[string]float64
+}
+
+func (s *virtualSecurity) newCurrentPrices() *currentPrices {
+	return &currentPrices{priceService: s.priceService, prices: map[string]float64{}}
+}
+
+// get - 銘柄の現在値 (価格がないか有効期限が切れていれば0)
+func (c *currentPrices) get(symbolCode string) float64 {
+	if price, ok := c.prices[symbolCode]; ok {
+		return price
+	}
+
+	var current float64
+	if price, err := c.priceService.getBySymbolCode(symbolCode); err == nil && price != nil && price.Price > 0 {
+		current = price.Price
+	}
+	c.prices[symbolCode] = current
+	return current
+}
+
+// valuePosition - ポジションの評価額と、約定価格からの評価損益
+//   現在値がなければどちらも0にする
+func valuePosition(side Side, price float64, current float64, quantity float64, multiplier float64) (float64, float64) {
+	if current <= 0 {
+		return 0, 0
+	}
+	profit := (current - price) * quantity * multiplier
+	if side == SideSell {
+		profit = -profit
+	}
+	return current * quantity * multiplier, profit
+}
+
+// marginPositionPrices - 保有中の信用建玉の銘柄ごとの現在値
+func (s *virtualSecurity) marginPositionPrices() map[string]float64 {
+	prices := map[string]float64{}
+	for _, p := range s.marginService.getMarginPositions() {
+		if _, ok := prices[p.SymbolCode]; ok {
+			continue
+		}
+		price, err := s.priceService.getBySymbolCode(p.SymbolCode)
+		if err != nil || price.Price <= 0 {
+			continue
+		}
+		prices[p.SymbolCode] = price.Price
+	}
+	return prices
+}
+
+// liquidateMarginPositions - 追証による強制決済
+//   建玉を増やす新規注文を取り消し、返済注文で拘束されていない建玉を成行で返済する
+func (s *virtualSecurity) liquidateMarginPositions(now time.Time) {
+	for _, o := range s.marginService.getMarginOrders() {
+		if o.TradeType == TradeTypeEntry && o.OrderStatus.IsCancelable() {
+			_ = s.marginService.cancelAndRelease(o, now)
+		}
+	}
+
+	for _, p := range s.marginService.getMarginPositions() {
+		quantity := p.orderableQuantity()
+		if quantity <= 0 {
+			continue
+		}
+
+		side := SideSell
+		if p.Side == SideSell {
+			side = SideBuy
+		}
+		o := s.marginService.toMarginOrder(&MarginOrderRequest{
+			TradeType:          TradeTypeExit,
+			Side:               side,
+			ExecutionCondition: StockExecutionConditionMO,
+			SymbolCode:         p.SymbolCode,
+			Quantity:           quantity,
+			ExitPositionList:   []ExitPosition{{PositionCode: p.Code, Quantity: quantity}},
+		}, now)
+		o.Message = "追証による強制決済"
+		if err := s.marginService.holdExitOrderPositions(o); err != nil {
+			continue
+		}
+		s.marginService.saveMarginOrder(o)
+
+		if price, err := s.priceService.getBySymbolCode(p.SymbolCode); err == nil {
+			_ = s.marginService.confirmContract(o, price, now)
+		}
+	}
+}
+
+// RegisterStockSymbol - 株式銘柄の登録
+//   登録した呼値の単位の表で、現物注文と信用注文の価格をチェックする
+//   登録していない銘柄は、TOPIX500構成銘柄以外の表でチェックする
+//   基準値段を登録すると、その日は基準値段から決まる値幅制限の範囲外の指値を受け付けない
+//   翌営業日からは、価格を登録した最後の現値を前日終値として基準値段にする
+func (s *virtualSecurity) RegisterStockSymbol(symbol *StockSymbol) (err error) {
+	defer s.unlock(s.lock(), &err)
+
+	return s.stockService.registerSymbol(symbol)
+}
+
 // StockOrder - 現物注文
-func (s *virtualSecurity) StockOrder(order *StockOrderRequest) (*OrderResult, error) {
+func (s *virtualSecurity) StockOrder(order *StockOrderRequest) (_ *OrderResult, err error) {
+	defer s.unlock(s.lock(), &err)
+
 	now := s.clock.now()
 
 	// 事前処理として、管理中の注文のうち有効期限切れのものをcancelしておく
@@ -87,6 +397,15 @@ func (s *virtualSecurity) StockOrder(order *StockOrderRequest) (*OrderResult, er
 		return nil, err
 	}
 
+	// buy注文なら注文に必要な金額を拘束する
+	if o.Side == SideBuy {
+		// 成行注文の金額の見積もりに使うだけなので、価格情報がなくても続ける
+		estimatePrice, _ := s.priceService.getBySymbolCode(o.SymbolCode)
+		if err := s.stockService.reserveBuyOrderCash(o, estimatePrice); err != nil {
+			return nil, err
+		}
+	}
+
 	// sell注文ならsellするポジションをholdする
 	if o.Side == SideSell {
 		if err := s.stockService.holdSellOrderPositions(o); err != nil {
@@ -114,7 +433,9 @@ func (s *virtualSecurity) StockOrder(order *StockOrderRequest) (*OrderResult, er
 }
 
 // CancelStockOrder - 現物注文の取消
-func (s *virtualSecurity) CancelStockOrder(cancelOrder *CancelOrderRequest) error {
+func (s *virtualSecurity) CancelStockOrder(cancelOrder *CancelOrderRequest) (err error) {
+	defer s.unlock(s.lock(), &err)
+
 	if cancelOrder == nil {
 		return fmt.Errorf("cancelOrder is nil, %w", NilArgumentError)
 	}
@@ -127,9 +448,42 @@ func (s *virtualSecurity) CancelStockOrder(cancelOrder *CancelOrderRequest) erro
 	return s.stockService.cancelAndRelease(order, s.clock.now())
 }
 
+// ModifyStockOrder - 現物注文の訂正
+//   注文コードを変えずに、指値価格、注文数量(減らすことだけできる)、有効期限を訂正する
+//   指値価格を訂正した注文は、新しい指値ですぐに約定確認をする
+func (s *virtualSecurity) ModifyStockOrder(modifyOrder *ModifyOrderRequest) (err error) {
+	defer s.unlock(s.lock(), &err)
+
+	if modifyOrder == nil {
+		return fmt.Errorf("modifyOrder is nil, %w", NilArgumentError)
+	}
+
+	order, err := s.stockService.getStockOrderByCode(modifyOrder.OrderCode)
+	if err != nil {
+		return fmt.Errorf("not found stock order(code: %s), %w", modifyOrder.OrderCode, err)
+	}
+
+	now := s.clock.now()
+
+	// 価格情報は成行注文の金額の見積もりと約定確認に使うだけなので、なくても続ける
+	price, priceErr := s.priceService.getBySymbolCode(order.SymbolCode)
+	if err := s.stockService.modify(order, modifyOrder, price, now); err != nil {
+		return err
+	}
+
+	// 注文でエラーがでても使い道がないので捨てる
+	if modifyOrder.LimitPrice > 0 && priceErr == nil {
+		_ = s.stockService.confirmContract(order, price, now)
+	}
+	return nil
+}
+
 // StockOrders - 現物注文一覧
-func (s *virtualSecurity) StockOrders() ([]*StockOrder, error) {
+func (s *virtualSecurity) StockOrders() (_ []*StockOrder, err error) {
+	defer s.unlock(s.lock(), &err)
+
 	now := s.clock.now()
+	border := s.diedBorder(ExchangeTypeStock, now)
 	orders := s.stockService.getStockOrders()
 
 	res := make([]*StockOrder, len(orders))
@@ -139,8 +493,9 @@ func (s *virtualSecurity) StockOrders() ([]*StockOrder, error) {
 		if o.isExpired(now) {
 			_ = s.stockService.cancelAndRelease(o, now)
 		}
-		if o.isDied(now) {
+		if o.isDied(border) {
 			s.stockService.removeStockOrderByCode(o.Code)
+			delete(s.recordedContracts, o.Code)
 			res = res[:len(res)-1]
 			continue
 		}
@@ -160,6 +515,9 @@ func (s *virtualSecurity) StockOrders() ([]*StockOrder, error) {
 			CanceledAt:         o.CanceledAt,
 			Contracts:          o.Contracts,
 			Message:            o.Message,
+			ExitPositionList:   o.ExitPositionList,
+			ExitPositionOrder:  o.ExitPositionOrder,
+			Modifications:      o.Modifications,
 		}
 		i++
 	}
@@ -168,7 +526,16 @@ func (s *virtualSecurity) StockOrders() ([]*StockOrder, error) {
 
 // StockPositions - 現物ポジション一覧
 func (s *virtualSecurity) StockPositions() ([]*StockPosition, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.stockPositions(), nil
+}
+
+// stockPositions - 現在値で評価した現物ポジション一覧
+func (s *virtualSecurity) stockPositions() []*StockPosition {
 	positions := s.stockService.getStockPositions()
+	prices := s.newCurrentPrices()
 
 	res := make([]*StockPosition, len(positions))
 	i := 0
@@ -178,6 +545,8 @@ func (s *virtualSecurity) StockPositions() ([]*StockPosition, error) {
 			res = res[:len(res)-1]
 			continue
 		}
+		current := prices.get(p.SymbolCode)
+		marketValue, profit := valuePosition(p.Side, p.Price, current, p.OwnedQuantity, 1)
 		res[i] = &StockPosition{
 			Code:               p.Code,
 			OrderCode:          p.OrderCode,
@@ -188,14 +557,19 @@ func (s *virtualSecurity) StockPositions() ([]*StockPosition, error) {
 			HoldQuantity:       p.HoldQuantity,
 			ContractedAt:       p.ContractedAt,
 			Price:              p.Price,
+			CurrentPrice:       current,
+			MarketValue:        marketValue,
+			UnrealizedProfit:   profit,
 		}
 		i++
 	}
-	return res, nil
+	return res
 }
 
 // MarginOrder - 信用注文
-func (s *virtualSecurity) MarginOrder(order *MarginOrderRequest) (*OrderResult, error) {
+func (s *virtualSecurity) MarginOrder(order *MarginOrderRequest) (_ *OrderResult, err error) {
+	defer s.unlock(s.lock(), &err)
+
 	now := s.clock.now()
 
 	// 事前処理として、管理中の注文のうち有効期限切れのものをcancelしておく
@@ -217,6 +591,15 @@ func (s *virtualSecurity) MarginOrder(order *MarginOrderRequest) (*OrderResult,
 		return nil, err
 	}
 
+	// entry注文なら建玉に必要な委託保証金があるかを確認する
+	if o.TradeType == TradeTypeEntry {
+		// 成行注文の金額の見積もりに使うだけなので、価格情報がなくても続ける
+		estimatePrice, _ := s.priceService.getBySymbolCode(o.SymbolCode)
+		if err := s.marginService.reserveEntryOrderMargin(o, estimatePrice); err != nil {
+			return nil, err
+		}
+	}
+
 	// exit注文ならexitするポジションをholdする
 	if o.TradeType == TradeTypeExit {
 		if err := s.marginService.holdExitOrderPositions(o); err != nil {
@@ -244,7 +627,9 @@ func (s *virtualSecurity) MarginOrder(order *MarginOrderRequest) (*OrderResult,
 }
 
 // CancelMarginOrder - 信用注文の取消
-func (s *virtualSecurity) CancelMarginOrder(cancelOrder *CancelOrderRequest) error {
+func (s *virtualSecurity) CancelMarginOrder(cancelOrder *CancelOrderRequest) (err error) {
+	defer s.unlock(s.lock(), &err)
+
 	if cancelOrder == nil {
 		return fmt.Errorf("cancelOrder is nil, %w", NilArgumentError)
 	}
@@ -257,9 +642,42 @@ func (s *virtualSecurity) CancelMarginOrder(cancelOrder *CancelOrderRequest) err
 	return s.marginService.cancelAndRelease(order, s.clock.now())
 }
 
+// ModifyMarginOrder - 信用注文の訂正
+//   注文コードを変えずに、指値価格、注文数量(減らすことだけできる)、有効期限を訂正する
+//   指値価格を訂正した注文は、新しい指値ですぐに約定確認をする
+func (s *virtualSecurity) ModifyMarginOrder(modifyOrder *ModifyOrderRequest) (err error) {
+	defer s.unlock(s.lock(), &err)
+
+	if modifyOrder == nil {
+		return fmt.Errorf("modifyOrder is nil, %w", NilArgumentError)
+	}
+
+	order, err := s.marginService.getMarginOrderByCode(modifyOrder.OrderCode)
+	if err != nil {
+		return fmt.Errorf("not found margin order(code: %s), %w", modifyOrder.OrderCode, err)
+	}
+
+	now := s.clock.now()
+
+	// 価格情報は成行注文の金額の見積もりと約定確認に使うだけなので、なくても続ける
+	price, priceErr := s.priceService.getBySymbolCode(order.SymbolCode)
+	if err := s.marginService.modify(order, modifyOrder, price, now); err != nil {
+		return err
+	}
+
+	// 注文でエラーがでても使い道がないので捨てる
+	if modifyOrder.LimitPrice > 0 && priceErr == nil {
+		_ = s.marginService.confirmContract(order, price, now)
+	}
+	return nil
+}
+
 // MarginOrders - 信用注文一覧
-func (s *virtualSecurity) MarginOrders() ([]*MarginOrder, error) {
+func (s *virtualSecurity) MarginOrders() (_ []*MarginOrder, err error) {
+	defer s.unlock(s.lock(), &err)
+
 	now := s.clock.now()
+	border := s.diedBorder(ExchangeTypeMargin, now)
 	orders := s.marginService.getMarginOrders()
 
 	res := make([]*MarginOrder, len(orders))
@@ -269,8 +687,9 @@ func (s *virtualSecurity) MarginOrders() ([]*MarginOrder, error) {
 		if o.isExpired(now) {
 			_ = s.marginService.cancelAndRelease(o, now)
 		}
-		if o.isDied(now) {
+		if o.isDied(border) {
 			s.marginService.removeMarginOrderByCode(o.Code)
+			delete(s.recordedContracts, o.Code)
 			res = res[:len(res)-1]
 			continue
 		}
@@ -292,6 +711,7 @@ func (s *virtualSecurity) MarginOrders() ([]*MarginOrder, error) {
 			CanceledAt:         o.CanceledAt,
 			Contracts:          o.Contracts,
 			Message:            o.Message,
+			Modifications:      o.Modifications,
 		}
 		i++
 	}
@@ -300,7 +720,17 @@ func (s *virtualSecurity) MarginOrders() ([]*MarginOrder, error) {
 
 // MarginPositions - 信用ポジション一覧
 func (s *virtualSecurity) MarginPositions() ([]*MarginPosition, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.marginPositions(), nil
+}
+
+// marginPositions - 現在値で評価した信用ポジション一覧
+func (s *virtualSecurity) marginPositions() []*MarginPosition {
 	positions := s.marginService.getMarginPositions()
+	prices := s.newCurrentPrices()
+	now := s.clock.now()
 
 	res := make([]*MarginPosition, len(positions))
 	i := 0
@@ -310,6 +740,8 @@ func (s *virtualSecurity) MarginPositions() ([]*MarginPosition, error) {
 			res = res[:len(res)-1]
 			continue
 		}
+		current := prices.get(p.SymbolCode)
+		marketValue, profit := valuePosition(p.Side, p.Price, current, p.OwnedQuantity, 1)
 		res[i] = &MarginPosition{
 			Code:               p.Code,
 			OrderCode:          p.OrderCode,
@@ -320,8 +752,746 @@ func (s *virtualSecurity) MarginPositions() ([]*MarginPosition, error) {
 			HoldQuantity:       p.HoldQuantity,
 			ContractedAt:       p.ContractedAt,
 			Price:              p.Price,
+			CurrentPrice:       current,
+			MarketValue:        marketValue,
+			UnrealizedProfit:   profit,
+			HoldingCost:        s.accountService.marginHoldingCost(p, p.OwnedQuantity, now),
+		}
+		i++
+	}
+	return res
+}
+
+// RegisterFutureSymbol - 先物銘柄の登録
+//   先物注文の前に、取引単位と取引最終日を登録しておく
+func (s *virtualSecurity) RegisterFutureSymbol(symbol *FutureSymbol) (err error) {
+	defer s.unlock(s.lock(), &err)
+
+	return s.futureService.registerSymbol(symbol)
+}
+
+// FutureOrder - 先物注文
+func (s *virtualSecurity) FutureOrder(order *FutureOrderRequest) (_ *OrderResult, err error) {
+	defer s.unlock(s.lock(), &err)
+
+	now := s.clock.now()
+
+	// 事前処理として、管理中の注文のうち有効期限切れのものをcancelしておく
+	for _, order := range s.futureService.getFutureOrders() {
+		if s.futureService.isExpired(order, now) {
+			_ = s.futureService.cancelAndRelease(order, now)
+		}
+	}
+
+	if order == nil {
+		return nil, NilArgumentError
+	}
+
+	// 内部用注文に変換
+	o := s.futureService.toFutureOrder(order, now)
+
+	// validation
+	if err := s.futureService.validation(o, now); err != nil {
+		return nil, err
+	}
+
+	// exit注文ならexitするポジションをholdする
+	if o.TradeType == TradeTypeExit {
+		if err := s.futureService.holdExitOrderPositions(o); err != nil {
+			return nil, err
+		}
+	}
+
+	// ここまでこれば有効な注文なので、処理後に保存する
+	defer s.futureService.saveFutureOrder(o)
+
+	// 該当銘柄の価格取得
+	price, priceErr := s.priceService.getBySymbolCode(order.SymbolCode)
+	if priceErr != nil && priceErr != NoDataError {
+		return nil, priceErr
+	}
+
+	// 価格情報がNoDataでなければ最初の約定確認処理をする
+	// 注文でエラーがでても使い道がないので捨てる
+	if priceErr != NoDataError {
+		_ = s.futureService.confirmContract(o, price, now)
+	}
+
+	// 注文番号を返す
+	return &OrderResult{OrderCode: o.Code}, nil
+}
+
+// CancelFutureOrder - 先物注文の取消
+func (s *virtualSecurity) CancelFutureOrder(cancelOrder *CancelOrderRequest) (err error) {
+	defer s.unlock(s.lock(), &err)
+
+	if cancelOrder == nil {
+		return fmt.Errorf("cancelOrder is nil, %w", NilArgumentError)
+	}
+
+	order, err := s.futureService.getFutureOrderByCode(cancelOrder.OrderCode)
+	if err != nil {
+		return fmt.Errorf("not found future order(code: %s), %w", cancelOrder.OrderCode, err)
+	}
+
+	return s.futureService.cancelAndRelease(order, s.clock.now())
+}
+
+// FutureOrders - 先物注文一覧
+func (s *virtualSecurity) FutureOrders() (_ []*FutureOrder, err error) {
+	defer s.unlock(s.lock(), &err)
+
+	now := s.clock.now()
+	border := s.diedBorder(ExchangeTypeFuture, now)
+	orders := s.futureService.getFutureOrders()
+
+	res := make([]*FutureOrder, len(orders))
+	i := 0
+	for _, o := range orders {
+		// 有効期限切れの注文があれば更新しておく
+		if s.futureService.isExpired(o, now) {
+			_ = s.futureService.cancelAndRelease(o, now)
+		}
+		if o.isDied(border) {
+			s.futureService.removeFutureOrderByCode(o.Code)
+			delete(s.recordedContracts, o.Code)
+			res = res[:len(res)-1]
+			continue
+		}
+		res[i] = &FutureOrder{
+			Code:               o.Code,
+			OrderStatus:        o.OrderStatus,
+			TradeType:          o.TradeType,
+			Side:               o.Side,
+			ExecutionCondition: o.ExecutionCondition,
+			SymbolCode:         o.SymbolCode,
+			OrderQuantity:      o.OrderQuantity,
+			ContractedQuantity: o.ContractedQuantity,
+			CanceledQuantity:   o.CanceledQuantity,
+			LimitPrice:         o.LimitPrice,
+			ExpiredAt:          o.ExpiredAt,
+			StopCondition:      o.StopCondition,
+			ExitPositionList:   o.ExitPositionList,
+			OrderedAt:          o.OrderedAt,
+			CanceledAt:         o.CanceledAt,
+			Contracts:          o.Contracts,
+			Message:            o.Message,
 		}
 		i++
 	}
 	return res, nil
 }
+
+// FuturePositions - 先物ポジション一覧
+func (s *virtualSecurity) FuturePositions() ([]*FuturePosition, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.futurePositions(), nil
+}
+
+// futurePositions - 現在値で評価した先物ポジション一覧
+func (s *virtualSecurity) futurePositions() []*FuturePosition {
+	positions := s.futureService.getFuturePositions()
+	prices := s.newCurrentPrices()
+	now := s.clock.now()
+
+	res := make([]*FuturePosition, len(positions))
+	i := 0
+	for _, p := range positions {
+		if p.isDied() {
+			s.futureService.removeFuturePositionByCode(p.Code)
+			res = res[:len(res)-1]
+			continue
+		}
+		current := prices.get(p.SymbolCode)
+		marketValue, profit := valuePosition(p.Side, p.Price, current, p.OwnedQuantity, p.Multiplier)
+		res[i] = &FuturePosition{
+			Code:               p.Code,
+			OrderCode:          p.OrderCode,
+			SymbolCode:         p.SymbolCode,
+			Side:               p.Side,
+			ContractedQuantity: p.ContractedQuantity,
+			OwnedQuantity:      p.OwnedQuantity,
+			HoldQuantity:       p.HoldQuantity,
+			Price:              p.Price,
+			SettlementPrice:    p.SettlementPrice,
+			Multiplier:         p.Multiplier,
+			ContractedAt:       p.ContractedAt,
+			CurrentPrice:       current,
+			MarketValue:        marketValue,
+			UnrealizedProfit:   profit,
+			IsExpired:          s.futureService.isExpiredSymbol(p.SymbolCode, now),
+		}
+		i++
+	}
+	return res
+}
+
+// SettleFutureSQ - 先物のSQによる決済
+//   銘柄の有効な注文を取り消し、残っているポジションをSQ値ですべて決済する
+func (s *virtualSecurity) SettleFutureSQ(symbolCode string, sqPrice float64) (err error) {
+	defer s.unlock(s.lock(), &err)
+
+	return s.futureService.settleSQ(symbolCode, sqPrice, s.clock.now())
+}
+
+// Deposit - 入金
+func (s *virtualSecurity) Deposit(amount float64) (err error) {
+	defer s.unlock(s.lock(), &err)
+
+	return s.accountService.deposit(amount)
+}
+
+// Withdraw - 出金
+func (s *virtualSecurity) Withdraw(amount float64) (err error) {
+	defer s.unlock(s.lock(), &err)
+
+	return s.accountService.withdraw(amount)
+}
+
+// Account - 口座情報
+func (s *virtualSecurity) Account() (*Account, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.accountService.getAccount(), nil
+}
+
+// Trades - 約定履歴
+//   注文やポジションが一覧から削除されても、すべての約定を約定日時の順に返す
+func (s *virtualSecurity) Trades() ([]*Trade, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.ledgerService.getTrades(), nil
+}
+
+// DailyProfits - 確定した損益と手数料、税金を営業日ごとに集計したもの
+func (s *virtualSecurity) DailyProfits() ([]*DailyProfit, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.ledgerService.dailyProfits(), nil
+}
+
+// TotalProfit - 確定した損益の合計
+//   返済で確定した損益から、新規と返済の手数料を引いたもの
+func (s *virtualSecurity) TotalProfit() (float64, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.ledgerService.totalProfit(), nil
+}
+
+// TotalTax - 確定した損益にかかる税金の合計
+//   WithCapitalGainsTaxを指定していなければ0
+func (s *virtualSecurity) TotalTax() (float64, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.ledgerService.totalTax(), nil
+}
+
+// Portfolio - 保有しているポジションを現在値で評価し、市場種別と銘柄ごとに集計したもの
+func (s *virtualSecurity) Portfolio() (*Portfolio, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	portfolio := &Portfolio{Symbols: []*SymbolValuation{}}
+	symbols := map[ExchangeType]map[string]*SymbolValuation{}
+	add := func(exchangeType ExchangeType, symbolCode string, side Side, quantity float64, current float64, marketValue float64, profit float64) {
+		if quantity <= 0 {
+			return
+		}
+		if _, ok := symbols[exchangeType]; !ok {
+			symbols[exchangeType] = map[string]*SymbolValuation{}
+		}
+		v, ok := symbols[exchangeType][symbolCode]
+		if !ok {
+			v = &SymbolValuation{ExchangeType: exchangeType, SymbolCode: symbolCode, CurrentPrice: current}
+			symbols[exchangeType][symbolCode] = v
+			portfolio.Symbols = append(portfolio.Symbols, v)
+		}
+		if side == SideSell {
+			v.SellQuantity += quantity
+		} else {
+			v.BuyQuantity += quantity
+		}
+		v.MarketValue += marketValue
+		v.UnrealizedProfit += profit
+		portfolio.MarketValue += marketValue
+		portfolio.UnrealizedProfit += profit
+	}
+
+	for _, p := range s.stockPositions() {
+		add(ExchangeTypeStock, p.SymbolCode, p.Side, p.OwnedQuantity, p.CurrentPrice, p.MarketValue, p.UnrealizedProfit)
+	}
+	for _, p := range s.marginPositions() {
+		add(ExchangeTypeMargin, p.SymbolCode, p.Side, p.OwnedQuantity, p.CurrentPrice, p.MarketValue, p.UnrealizedProfit)
+		portfolio.HoldingCost += p.HoldingCost.Total()
+	}
+	for _, p := range s.futurePositions() {
+		add(ExchangeTypeFuture, p.SymbolCode, p.Side, p.OwnedQuantity, p.CurrentPrice, p.MarketValue, p.UnrealizedProfit)
+	}
+
+	// 現物、信用、先物の順に、市場種別の中では銘柄コードの順に並べる
+	exchangeOrder := map[ExchangeType]int{ExchangeTypeStock: 0, ExchangeTypeMargin: 1, ExchangeTypeFuture: 2}
+	sort.SliceStable(portfolio.Symbols, func(i, j int) bool {
+		if portfolio.Symbols[i].ExchangeType != portfolio.Symbols[j].ExchangeType {
+			return exchangeOrder[portfolio.Symbols[i].ExchangeType] < exchangeOrder[portfolio.Symbols[j].ExchangeType]
+		}
+		return portfolio.Symbols[i].SymbolCode < portfolio.Symbols[j].SymbolCode
+	})
+	portfolio.RealizedProfit = s.ledgerService.totalProfit()
+	return portfolio, nil
+}
+
+// Snapshot - 一時停止した仮想証券会社をRestoreで再開できるように、口座、銘柄、注文、ポジション、価格、約定履歴をJSONで書き出す
+//   イベントの購読者は書き出さない
+func (s *virtualSecurity) Snapshot() ([]byte, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return json.Marshal(&snapshot{
+		Version:         snapshotVersion,
+		CreatedAt:       s.clock.now(),
+		Account:         s.accountService.getState(),
+		StockSymbols:    s.stockService.getSymbols(),
+		StockOrders:     s.stockService.getStockOrders(),
+		StockPositions:  s.stockService.getStockPositions(),
+		MarginOrders:    s.marginService.getMarginOrders(),
+		MarginPositions: s.marginService.getMarginPositions(),
+		FutureSymbols:   s.futureService.getSymbols(),
+		FutureOrders:    s.futureService.getFutureOrders(),
+		FuturePositions: s.futureService.getFuturePositions(),
+		Prices:          newStoredPrices(s.priceService.getAll()),
+		Trades:          s.ledgerService.getTrades(),
+	})
+}
+
+// Restore - Snapshotで書き出した状態に戻す
+//   今ある注文、ポジション、価格、約定履歴は捨て、銘柄は上書きする
+//   戻した注文やポジションの変化はイベントとして通知しない
+func (s *virtualSecurity) Restore(data []byte) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var ss snapshot
+	if err := json.Unmarshal(data, &ss); err != nil {
+		return fmt.Errorf("%v: %w", err, InvalidSnapshotError)
+	}
+	if ss.Version != snapshotVersion {
+		return fmt.Errorf("version: %d: %w", ss.Version, InvalidSnapshotError)
+	}
+
+	s.accountService.restore(ss.Account)
+	s.stockService.restore(ss.StockOrders, ss.StockPositions, ss.StockSymbols)
+	s.marginService.restore(ss.MarginOrders, ss.MarginPositions)
+	s.futureService.restore(ss.FutureOrders, ss.FuturePositions, ss.FutureSymbols)
+	s.priceService.restore(toSymbolPrices(ss.Prices))
+	s.ledgerService.restore(ss.Trades)
+	s.recordedContracts = nil
+	return s.persist()
+}
+
+// Tick - 現在日時までの日付やセッションの切り替わりの処理
+//   有効期限切れの注文の失効、板寄せで約定しなかった寄付や引けの注文の取消、終了した注文やポジションの削除、取引最終日を過ぎた先物銘柄の注文の取消をする
+func (s *virtualSecurity) Tick() (err error) {
+	defer s.unlock(s.lock(), &err)
+
+	now := s.clock.now()
+	s.expireOrders(now)
+	s.cancelAuctionOrders(s.scheduleService.closedAuctions(now), now)
+	s.removeDiedOrdersAndPositions(now)
+
+	// 取引最終日を過ぎた先物銘柄の注文を取り消す
+	return s.futureService.expireSymbols(now)
+}
+
+// priorityOrder - 約定確認する注文と、約定の優先順位に使う要素
+type priorityOrder struct {
+	exchangeType ExchangeType // 市場種別 (信用は現物と同じ板に並ぶので現物)
+	side         Side         // 売買方向
+	isMarket     bool         // 成行として約定するか
+	limitPrice   float64      // 指値価格 (指値でなければ0)
+	orderedAt    time.Time    // 注文日時
+	confirm      func()       // 約定確認
+}
+
+// contractPriorityOrders - 約定確認する注文を、約定確認する順に並べて返す
+//   板寄せでは、成行、指値の価格が良い順(買いは高い順、売りは安い順)、注文日時の順に約定させる
+//   板寄せでなければ、現物、信用、先物の順に注文を並べる
+func (s *virtualSecurity) contractPriorityOrders(price *symbolPrice, now time.Time) []*priorityOrder {
+	isClosing := price.kind == PriceKindClosing || price.kind == PriceKindOpeningAndClosing
+
+	var orders []*priorityOrder
+	for _, o := range s.stockService.getStockOrders() {
+		o := o
+		ec := o.executionCondition()
+		orders = append(orders, &priorityOrder{
+			exchangeType: ExchangeTypeStock,
+			side:         o.Side,
+			isMarket:     ec.IsMarketOrder() || (ec.IsFunari() && isClosing),
+			limitPrice:   o.limitPrice(),
+			orderedAt:    o.OrderedAt,
+			confirm:      func() { _ = s.stockService.confirmContract(o, price, now) },
+		})
+	}
+	for _, o := range s.marginService.getMarginOrders() {
+		o := o
+		ec := o.executionCondition()
+		orders = append(orders, &priorityOrder{
+			exchangeType: ExchangeTypeStock,
+			side:         o.Side,
+			isMarket:     ec.IsMarketOrder() || (ec.IsFunari() && isClosing),
+			limitPrice:   o.limitPrice(),
+			orderedAt:    o.OrderedAt,
+			confirm:      func() { _ = s.marginService.confirmContract(o, price, now) },
+		})
+	}
+	for _, o := range s.futureService.getFutureOrders() {
+		o := o
+		orders = append(orders, &priorityOrder{
+			exchangeType: ExchangeTypeFuture,
+			side:         o.Side,
+			isMarket:     o.executionCondition().IsMarketOrder(),
+			limitPrice:   o.limitPrice(),
+			orderedAt:    o.OrderedAt,
+			confirm:      func() { _ = s.futureService.confirmContract(o, price, now) },
+		})
+	}
+
+	if price.isItayose() {
+		sort.SliceStable(orders, func(i, j int) bool {
+			return orders[i].isPrior(orders[j])
+		})
+	}
+	return orders
+}
+
+// isPrior - 板寄せで、比較対象の注文より先に約定するか
+//   市場種別や売買方向が違う注文は、同じ約定数量を取り合わないので並び順だけを決める
+func (o *priorityOrder) isPrior(other *priorityOrder) bool {
+	if o.exchangeType != other.exchangeType {
+		return o.exchangeType < other.exchangeType
+	}
+	if o.side != other.side {
+		return o.side < other.side
+	}
+	if o.isMarket != other.isMarket {
+		return o.isMarket
+	}
+	if !o.isMarket && o.limitPrice != other.limitPrice {
+		// 逆指値の待機中など、指値価格のない注文は最後
+		if o.limitPrice <= 0 || other.limitPrice <= 0 {
+			return o.limitPrice > 0
+		}
+		if o.side == SideBuy {
+			return o.limitPrice > other.limitPrice
+		}
+		return o.limitPrice < other.limitPrice
+	}
+	return o.orderedAt.Before(other.orderedAt)
+}
+
+// expireOrders - 有効期限切れの注文を失効させる
+func (s *virtualSecurity) expireOrders(now time.Time) {
+	for _, o := range s.stockService.getStockOrders() {
+		if o.OrderStatus.IsCancelable() && o.isExpired(now) {
+			_ = s.stockService.cancelAndRelease(o, now)
+		}
+	}
+	for _, o := range s.marginService.getMarginOrders() {
+		if o.OrderStatus.IsCancelable() && o.isExpired(now) {
+			_ = s.marginService.cancelAndRelease(o, now)
+		}
+	}
+	for _, o := range s.futureService.getFutureOrders() {
+		if o.OrderStatus.IsCancelable() && s.futureService.isExpired(o, now) {
+			_ = s.futureService.cancelAndRelease(o, now)
+		}
+	}
+}
+
+// cancelAuctionOrders - 板寄せで約定しなかった寄付や引けの注文を取り消す
+func (s *virtualSecurity) cancelAuctionOrders(auctions []*closedAuction, now time.Time) {
+	const message = "板寄せで約定しなかったため取消"
+	for _, a := range auctions {
+		for _, o := range s.stockService.getStockOrders() {
+			if o.OrderStatus.IsCancelable() && a.isCancelTarget(o.ExecutionCondition, o.OrderedAt) {
+				o.Message = message
+				_ = s.stockService.cancelAndRelease(o, now)
+			}
+		}
+		for _, o := range s.marginService.getMarginOrders() {
+			if o.OrderStatus.IsCancelable() && a.isCancelTarget(o.ExecutionCondition, o.OrderedAt) {
+				o.Message = message
+				_ = s.marginService.cancelAndRelease(o, now)
+			}
+		}
+		for _, o := range s.futureService.getFutureOrders() {
+			if o.OrderStatus.IsCancelable() && a.isFutureCancelTarget(o.ExecutionCondition, o.OrderedAt) {
+				o.Message = message
+				_ = s.futureService.cancelAndRelease(o, now)
+			}
+		}
+	}
+}
+
+// removeDiedOrdersAndPositions - 終了してから時間の経った注文やポジションを一覧から削除する
+func (s *virtualSecurity) removeDiedOrdersAndPositions(now time.Time) {
+	stockBorder, futureBorder := s.diedBorder(ExchangeTypeStock, now), s.diedBorder(ExchangeTypeFuture, now)
+	for _, o := range s.stockService.getStockOrders() {
+		if o.isDied(stockBorder) {
+			s.stockService.removeStockOrderByCode(o.Code)
+			delete(s.recordedContracts, o.Code)
+		}
+	}
+	for _, p := range s.stockService.getStockPositions() {
+		if p.isDied() {
+			s.stockService.removeStockPositionByCode(p.Code)
+		}
+	}
+	for _, o := range s.marginService.getMarginOrders() {
+		if o.isDied(stockBorder) {
+			s.marginService.removeMarginOrderByCode(o.Code)
+			delete(s.recordedContracts, o.Code)
+		}
+	}
+	for _, p := range s.marginService.getMarginPositions() {
+		if p.isDied() {
+			s.marginService.removeMarginPositionByCode(p.Code)
+		}
+	}
+	for _, o := range s.futureService.getFutureOrders() {
+		if o.isDied(futureBorder) {
+			s.futureService.removeFutureOrderByCode(o.Code)
+			delete(s.recordedContracts, o.Code)
+		}
+	}
+	for _, p := range s.futureService.getFuturePositions() {
+		if p.isDied() {
+			s.futureService.removeFuturePositionByCode(p.Code)
+		}
+	}
+}
+
+// nextSessionBoundary - 指定した日時より後で、最初に来る日付やセッションの切り替わりの日時
+func (s *virtualSecurity) nextSessionBoundary(now time.Time) time.Time {
+	return s.schedule.nextBoundary(now)
+}
+
+// diedBorder - 終了した注文を一覧に残しておく境目の日時
+//   前の営業日からの注文は残し、それより前に終了した注文は一覧から削除する
+func (s *virtualSecurity) diedBorder(exchangeType ExchangeType, now time.Time) time.Time {
+	return s.clock.addBusinessDays(s.clock.getBusinessDay(exchangeType, now), -1)
+}
+
+// Subscribe - 注文やポジションの変化をイベントとして受け取るハンドラの登録
+//   ハンドラは状態を変化させた処理の最後に同期的に呼ばれる。戻り値の関数を呼ぶと登録を解除する
+func (s *virtualSecurity) Subscribe(handler EventHandler) (func(), error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.eventService.subscribe(handler)
+}
+
+// tradingState - イベントの判定に使う、現時点の注文とポジションの状態
+//   購読者がいなければ記録しない
+func (s *virtualSecurity) tradingState() *tradingState {
+	if s.eventService == nil || !s.eventService.isSubscribed() {
+		return nil
+	}
+
+	now := s.clock.now()
+	state := &tradingState{}
+	for _, o := range s.stockService.getStockOrders() {
+		state.orders = append(state.orders, &orderState{
+			exchangeType:    ExchangeTypeStock,
+			code:            o.Code,
+			symbolCode:      o.SymbolCode,
+			status:          o.OrderStatus,
+			orderQuantity:   o.OrderQuantity,
+			contracts:       o.Contracts,
+			isStopActivated: o.StopCondition != nil && o.StopCondition.isActivate,
+			isExpired:       o.OrderStatus == OrderStatusCanceled && o.isExpired(now),
+			orderedAt:       o.OrderedAt,
+		})
+	}
+	for _, o := range s.marginService.getMarginOrders() {
+		state.orders = append(state.orders, &orderState{
+			exchangeType:    ExchangeTypeMargin,
+			code:            o.Code,
+			symbolCode:      o.SymbolCode,
+			status:          o.OrderStatus,
+			orderQuantity:   o.OrderQuantity,
+			contracts:       o.Contracts,
+			isStopActivated: o.StopCondition != nil && o.StopCondition.isActivate,
+			isExpired:       o.OrderStatus == OrderStatusCanceled && o.isExpired(now),
+			orderedAt:       o.OrderedAt,
+		})
+	}
+	for _, o := range s.futureService.getFutureOrders() {
+		state.orders = append(state.orders, &orderState{
+			exchangeType:    ExchangeTypeFuture,
+			code:            o.Code,
+			symbolCode:      o.SymbolCode,
+			status:          o.OrderStatus,
+			orderQuantity:   o.OrderQuantity,
+			contracts:       o.Contracts,
+			isStopActivated: o.StopCondition != nil && o.StopCondition.isActivate,
+			isExpired:       o.OrderStatus == OrderStatusCanceled && s.futureService.isExpired(o, now),
+			orderedAt:       o.OrderedAt,
+		})
+	}
+
+	for _, p := range s.stockService.getStockPositions() {
+		state.positions = append(state.positions, &positionState{
+			exchangeType:  ExchangeTypeStock,
+			code:          p.Code,
+			orderCode:     p.OrderCode,
+			symbolCode:    p.SymbolCode,
+			ownedQuantity: p.OwnedQuantity,
+			contractedAt:  p.ContractedAt,
+		})
+	}
+	for _, p := range s.marginService.getMarginPositions() {
+		state.positions = append(state.positions, &positionState{
+			exchangeType:  ExchangeTypeMargin,
+			code:          p.Code,
+			orderCode:     p.OrderCode,
+			symbolCode:    p.SymbolCode,
+			ownedQuantity: p.OwnedQuantity,
+			contractedAt:  p.ContractedAt,
+		})
+	}
+	for _, p := range s.futureService.getFuturePositions() {
+		state.positions = append(state.positions, &positionState{
+			exchangeType:  ExchangeTypeFuture,
+			code:          p.Code,
+			orderCode:     p.OrderCode,
+			symbolCode:    p.SymbolCode,
+			ownedQuantity: p.OwnedQuantity,
+			contractedAt:  p.ContractedAt,
+		})
+	}
+
+	state.sort()
+	return state
+}
+
+// lock - 状態を変化させる処理の前にロックを取り、イベントの判定に使う処理前の状態を返す
+func (s *virtualSecurity) lock() *tradingState {
+	s.mtx.Lock()
+	return s.tradingState()
+}
+
+// unlock - 状態を変化させた処理の後でロックを外し、処理前の状態と現在の状態を比べて、変化をイベントとして通知する
+//   イベントを受け取ったハンドラが約定履歴を見られるように、通知の前に約定を台帳に記録する
+//   ハンドラの中から仮想証券会社を操作できるように、ロックを外してからハンドラを呼び出す
+//   処理が成功してもファイルに書き出せなければ、処理の戻り値のエラーを書き出せなかったエラーにする
+func (s *virtualSecurity) unlock(before *tradingState, err *error) {
+	s.recordTrades()
+	if persistErr := s.persist(); persistErr != nil && err != nil && *err == nil {
+		*err = persistErr
+	}
+
+	if before == nil {
+		s.mtx.Unlock()
+		return
+	}
+	after, now := s.tradingState(), s.clock.now()
+	s.mtx.Unlock()
+
+	s.eventService.publish(before, after, now)
+}
+
+// recordTrades - 台帳に記録されていない約定を記録する
+//   約定した注文は終了してから1日で一覧から削除されるので、状態を変化させた処理のたびに記録する
+//   注文ごとに記録済みの約定数を覚えておき、新しく増えた約定だけを記録する
+//   先物の取引単位は、ポジションが決済されて消えていても取れるように銘柄から取る
+func (s *virtualSecurity) recordTrades() {
+	if s.ledgerService == nil {
+		return
+	}
+	if s.recordedContracts == nil {
+		s.recordedContracts = map[string]int{}
+	}
+
+	for _, o := range s.stockService.getStockOrders() {
+		tradeType := TradeTypeEntry
+		if o.Side == SideSell {
+			tradeType = TradeTypeExit
+		}
+		for _, c := range s.unrecordedContracts(o.Code, o.Contracts) {
+			s.ledgerService.record(s.newTrade(ExchangeTypeStock, o.SymbolCode, tradeType, o.Side, 1, c))
+		}
+	}
+	for _, o := range s.marginService.getMarginOrders() {
+		for _, c := range s.unrecordedContracts(o.Code, o.Contracts) {
+			s.ledgerService.record(s.newTrade(ExchangeTypeMargin, o.SymbolCode, o.TradeType, o.Side, 1, c))
+		}
+	}
+
+	var multipliers map[string]float64
+	for _, o := range s.futureService.getFutureOrders() {
+		contracts := s.unrecordedContracts(o.Code, o.Contracts)
+		if len(contracts) == 0 {
+			continue
+		}
+		if multipliers == nil {
+			multipliers = map[string]float64{}
+			for _, symbol := range s.futureService.getSymbols() {
+				multipliers[symbol.SymbolCode] = symbol.Multiplier
+			}
+		}
+		for _, c := range contracts {
+			s.ledgerService.record(s.newTrade(ExchangeTypeFuture, o.SymbolCode, o.TradeType, o.Side, multipliers[o.SymbolCode], c))
+		}
+	}
+}
+
+// unrecordedContracts - 注文の約定のうち、台帳にまだ記録していない約定を返して記録済みにする
+//   約定は注文に追加されるだけで消えないので、記録済みの約定数より後ろの約定が未記録の約定になる
+func (s *virtualSecurity) unrecordedContracts(orderCode string, contracts []*Contract) []*Contract {
+	recorded := s.recordedContracts[orderCode]
+	if len(contracts) <= recorded {
+		return nil
+	}
+	s.recordedContracts[orderCode] = len(contracts)
+	return contracts[recorded:]
+}
+
+// persist - ファイルに書き出すストアの中身を書き出す
+//   約定などでストアにある注文やポジションが変わっても保存し直さないので、状態を変化させた処理のたびに書き出す
+//   書き出せないストアがあっても残りのストアは書き出し、最初に書き出せなかったエラーを返す
+func (s *virtualSecurity) persist() error {
+	var res error
+	for _, store := range s.persistentStores {
+		if err := store.persist(); err != nil && res == nil {
+			res = fmt.Errorf("%v: %w", err, PersistError)
+		}
+	}
+	return res
+}
+
+// newTrade - 注文の約定から約定履歴を作る
+func (s *virtualSecurity) newTrade(exchangeType ExchangeType, symbolCode string, tradeType TradeType, side Side, multiplier float64, contract *Contract) *Trade {
+	return &Trade{
+		ContractCode: contract.ContractCode,
+		OrderCode:    contract.OrderCode,
+		PositionCode: contract.PositionCode,
+		ExchangeType: exchangeType,
+		SymbolCode:   symbolCode,
+		TradeType:    tradeType,
+		Side:         side,
+		Price:        contract.Price,
+		Quantity:     contract.Quantity,
+		Multiplier:   multiplier,
+		Fee:          contract.Fee,
+		HoldingCost:  contract.HoldingCost,
+		BusinessDay:  s.clock.getBusinessDay(exchangeType, contract.ContractedAt),
+		ContractedAt: contract.ContractedAt,
+	}
+}
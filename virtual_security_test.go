@@ -1,7 +1,11 @@
 package virtual_security
 
 import (
+	"encoding/json"
 	"errors"
+	"math"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -11,21 +15,21 @@ func Test_virtualSecurity_StockOrders(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
 		name                      string
-		security                  virtualSecurity
+		security                  *virtualSecurity
 		service                   *testStockService
 		want1                     []*StockOrder
 		want2                     error
 		wantCancelAndReleaseCount int
 	}{
 		{name: "storeに注文がなければ空配列",
-			security: virtualSecurity{
+			security: &virtualSecurity{
 				clock: &testClock{now1: time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local)},
 			},
 			service: &testStockService{getStockOrders1: []*stockOrder{}},
 			want1:   []*StockOrder{},
 			want2:   nil},
 		{name: "storeにある注文をStockOrderに入れ替えて返す",
-			security: virtualSecurity{
+			security: &virtualSecurity{
 				clock: &testClock{now1: time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local)},
 			},
 			service: &testStockService{getStockOrders1: []*stockOrder{
@@ -69,7 +73,7 @@ func Test_virtualSecurity_StockOrders(t *testing.T) {
 			},
 			want2: nil},
 		{name: "storeに複数注文があれば全部返す",
-			security: virtualSecurity{
+			security: &virtualSecurity{
 				clock: &testClock{now1: time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local)},
 			},
 			service: &testStockService{getStockOrders1: []*stockOrder{
@@ -84,7 +88,7 @@ func Test_virtualSecurity_StockOrders(t *testing.T) {
 			},
 			want2: nil},
 		{name: "storeにある注文が死んだ注文なら返さない",
-			security: virtualSecurity{
+			security: &virtualSecurity{
 				clock: &testClock{now1: time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local)},
 			},
 			service: &testStockService{getStockOrders1: []*stockOrder{
@@ -97,7 +101,7 @@ func Test_virtualSecurity_StockOrders(t *testing.T) {
 			},
 			want2: nil},
 		{name: "storeにある注文が有効期限切れになっていたらcancelAndReleaseを通す",
-			security: virtualSecurity{
+			security: &virtualSecurity{
 				clock: &testClock{now1: time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local)},
 			},
 			service: &testStockService{getStockOrders1: []*stockOrder{
@@ -131,18 +135,18 @@ func Test_virtualSecurity_StockPositions(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
 		name     string
-		security virtualSecurity
+		security *virtualSecurity
 		want1    []*StockPosition
 		want2    error
 	}{
 		{name: "storeにデータが無ければ空配列を返す",
-			security: virtualSecurity{stockService: &testStockService{
+			security: &virtualSecurity{priceService: &testPriceService{getBySymbolCode2: NoDataError}, stockService: &testStockService{
 				getStockPositions1: []*stockPosition{},
 			}},
 			want1: []*StockPosition{},
 			want2: nil},
 		{name: "storeにあるデータをStockPositionに詰め替えて返す",
-			security: virtualSecurity{stockService: &testStockService{
+			security: &virtualSecurity{priceService: &testPriceService{getBySymbolCode2: NoDataError}, stockService: &testStockService{
 				getStockPositions1: []*stockPosition{
 					{
 						Code:               "spo_1234",
@@ -172,7 +176,7 @@ func Test_virtualSecurity_StockPositions(t *testing.T) {
 			},
 			want2: nil},
 		{name: "storeに複数データがあれば全部返す",
-			security: virtualSecurity{stockService: &testStockService{
+			security: &virtualSecurity{priceService: &testPriceService{getBySymbolCode2: NoDataError}, stockService: &testStockService{
 				getStockPositions1: []*stockPosition{
 					{Code: "spo_1234", OwnedQuantity: 100},
 					{Code: "spo_2345", OwnedQuantity: 100},
@@ -185,8 +189,14 @@ func Test_virtualSecurity_StockPositions(t *testing.T) {
 				{Code: "spo_3456", OwnedQuantity: 100},
 			},
 			want2: nil},
+		{name: "現在値があれば評価額と評価損益を計算する",
+			security: &virtualSecurity{priceService: &testPriceService{getBySymbolCode1: &symbolPrice{SymbolCode: "1234", Price: 1100}}, stockService: &testStockService{
+				getStockPositions1: []*stockPosition{{Code: "spo_1234", SymbolCode: "1234", Side: SideBuy, OwnedQuantity: 100, Price: 1000}},
+			}},
+			want1: []*StockPosition{{Code: "spo_1234", SymbolCode: "1234", Side: SideBuy, OwnedQuantity: 100, Price: 1000, CurrentPrice: 1100, MarketValue: 110000, UnrealizedProfit: 10000}},
+			want2: nil},
 		{name: "storeのデータが死んでいたら返さない",
-			security: virtualSecurity{stockService: &testStockService{
+			security: &virtualSecurity{priceService: &testPriceService{getBySymbolCode2: NoDataError}, stockService: &testStockService{
 				getStockPositions1: []*stockPosition{
 					{Code: "spo_1234", OwnedQuantity: 0},
 					{Code: "spo_2345", OwnedQuantity: 100},
@@ -307,6 +317,13 @@ func Test_virtualSecurity_StockOrder(t *testing.T) {
 			arg:          &StockOrderRequest{},
 			want1:        nil,
 			want2:        InvalidSideError},
+		{name: "buy注文の金額の拘束に失敗したらエラーを返す",
+			clock:        &testClock{now1: time.Date(2021, 6, 25, 10, 0, 0, 0, time.Local)},
+			priceService: &testPriceService{getBySymbolCode1: nil, getBySymbolCode2: NoDataError},
+			stockService: &testStockService{toStockOrder1: &stockOrder{Side: SideBuy}, validation1: nil, reserveBuyOrderCash1: NotEnoughBuyingPowerError, getStockOrders1: []*stockOrder{}},
+			arg:          &StockOrderRequest{Side: SideBuy},
+			want1:        nil,
+			want2:        NotEnoughBuyingPowerError},
 		{name: "sell注文のholdに失敗したらエラーを返す",
 			clock:        &testClock{now1: time.Date(2021, 6, 25, 10, 0, 0, 0, time.Local)},
 			stockService: &testStockService{toStockOrder1: &stockOrder{Side: SideSell}, validation1: nil, holdSellOrderPositions1: NotEnoughOwnedQuantityError, getStockOrders1: []*stockOrder{}},
@@ -391,8 +408,10 @@ func Test_virtualSecurity_RegisterPrice(t *testing.T) {
 		marginService                  *testMarginService
 		arg                            RegisterPriceRequest
 		want                           error
+		futureService                  *testFutureService
 		wantStockConfirmContractCount  int
 		wantMarginConfirmContractCount int
+		wantFutureConfirmContractCount int
 	}{
 		{name: "validationでエラーがあればエラーを返す",
 			priceService:  &testPriceService{validation1: InvalidTimeError},
@@ -410,17 +429,13 @@ func Test_virtualSecurity_RegisterPrice(t *testing.T) {
 			marginService: &testMarginService{},
 			want:          NilArgumentError},
 		{name: "保存された注文がなければ何もしない",
-			clock: &testClock{
-				now1:             time.Date(2021, 7, 5, 10, 0, 0, 0, time.Local),
-				getStockSession1: SessionMorning},
+			clock:         &testClock{now1: time.Date(2021, 7, 5, 10, 0, 0, 0, time.Local)},
 			priceService:  &testPriceService{toSymbolPrice1: &symbolPrice{}},
 			stockService:  &testStockService{getStockOrders1: []*stockOrder{}},
 			marginService: &testMarginService{getMarginOrders1: []*marginOrder{}},
 			want:          nil},
 		{name: "保存された現物注文がbuyならentryを叩く",
-			clock: &testClock{
-				now1:             time.Date(2021, 7, 5, 10, 0, 0, 0, time.Local),
-				getStockSession1: SessionMorning},
+			clock: &testClock{now1: time.Date(2021, 7, 5, 10, 0, 0, 0, time.Local)},
 			priceService: &testPriceService{toSymbolPrice1: &symbolPrice{
 				SymbolCode: "1234",
 				Price:      1000,
@@ -434,9 +449,7 @@ func Test_virtualSecurity_RegisterPrice(t *testing.T) {
 			marginService:                 &testMarginService{getMarginOrders1: []*marginOrder{}},
 			wantStockConfirmContractCount: 1},
 		{name: "保存された現物注文がsellならexitを叩く",
-			clock: &testClock{
-				now1:             time.Date(2021, 7, 5, 10, 0, 0, 0, time.Local),
-				getStockSession1: SessionMorning},
+			clock: &testClock{now1: time.Date(2021, 7, 5, 10, 0, 0, 0, time.Local)},
 			priceService: &testPriceService{toSymbolPrice1: &symbolPrice{
 				SymbolCode: "1234",
 				Price:      1000,
@@ -450,9 +463,7 @@ func Test_virtualSecurity_RegisterPrice(t *testing.T) {
 			marginService:                 &testMarginService{getMarginOrders1: []*marginOrder{}},
 			wantStockConfirmContractCount: 1},
 		{name: "保存された信用注文がentryならentryを叩く",
-			clock: &testClock{
-				now1:             time.Date(2021, 7, 5, 10, 0, 0, 0, time.Local),
-				getStockSession1: SessionMorning},
+			clock: &testClock{now1: time.Date(2021, 7, 5, 10, 0, 0, 0, time.Local)},
 			priceService: &testPriceService{toSymbolPrice1: &symbolPrice{
 				SymbolCode: "1234",
 				Price:      1000,
@@ -466,9 +477,7 @@ func Test_virtualSecurity_RegisterPrice(t *testing.T) {
 			marginService:                  &testMarginService{getMarginOrders1: []*marginOrder{{TradeType: TradeTypeEntry}}},
 			wantMarginConfirmContractCount: 1},
 		{name: "保存された信用注文がexitならexitを叩く",
-			clock: &testClock{
-				now1:             time.Date(2021, 7, 5, 10, 0, 0, 0, time.Local),
-				getStockSession1: SessionMorning},
+			clock: &testClock{now1: time.Date(2021, 7, 5, 10, 0, 0, 0, time.Local)},
 			priceService: &testPriceService{toSymbolPrice1: &symbolPrice{
 				SymbolCode: "1234",
 				Price:      1000,
@@ -481,32 +490,65 @@ func Test_virtualSecurity_RegisterPrice(t *testing.T) {
 			stockService:                   &testStockService{getStockOrders1: []*stockOrder{}},
 			marginService:                  &testMarginService{getMarginOrders1: []*marginOrder{{TradeType: TradeTypeExit}}},
 			wantMarginConfirmContractCount: 1},
+		{name: "保存された先物注文があれば約定確認を叩く",
+			clock: &testClock{now1: time.Date(2021, 7, 5, 10, 0, 0, 0, time.Local)},
+			priceService: &testPriceService{toSymbolPrice1: &symbolPrice{
+				ExchangeType: ExchangeTypeFuture,
+				SymbolCode:   "167060019",
+				Price:        28000,
+				PriceTime:    time.Date(2021, 7, 5, 10, 0, 0, 0, time.Local),
+				kind:         PriceKindRegular}},
+			stockService:                   &testStockService{getStockOrders1: []*stockOrder{}},
+			marginService:                  &testMarginService{getMarginOrders1: []*marginOrder{}},
+			futureService:                  &testFutureService{getFutureOrders1: []*futureOrder{{TradeType: TradeTypeEntry}, {TradeType: TradeTypeExit}}},
+			wantFutureConfirmContractCount: 2},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			security := &virtualSecurity{clock: test.clock, priceService: test.priceService, stockService: test.stockService, marginService: test.marginService}
+			futureService := test.futureService
+			if futureService == nil {
+				futureService = &testFutureService{}
+			}
+			security := &virtualSecurity{clock: test.clock, priceService: test.priceService, stockService: test.stockService, marginService: test.marginService, futureService: futureService}
 			got := security.RegisterPrice(test.arg)
+
+			// 価格の登録に成功したら必ず銘柄の基準値段の更新と信用建玉の評価をする
+			wantEvaluatePositionsCount := 0
+			if test.want == nil {
+				wantEvaluatePositionsCount = 1
+			}
 			if !errors.Is(got, test.want) ||
 				!reflect.DeepEqual(test.wantStockConfirmContractCount, test.stockService.confirmContractCount) ||
-				!reflect.DeepEqual(test.wantMarginConfirmContractCount, test.marginService.confirmContractCount) {
+				!reflect.DeepEqual(test.wantMarginConfirmContractCount, test.marginService.confirmContractCount) ||
+				!reflect.DeepEqual(wantEvaluatePositionsCount, len(test.stockService.updateSymbolPriceHistory)) ||
+				!reflect.DeepEqual(wantEvaluatePositionsCount, test.marginService.evaluatePositionsCount) ||
+				!reflect.DeepEqual(test.wantFutureConfirmContractCount, futureService.confirmContractCount) ||
+				!reflect.DeepEqual(wantEvaluatePositionsCount, futureService.settleCount) {
 
-				t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v, %+v\n", t.Name(),
-					test.want, test.wantStockConfirmContractCount, test.wantMarginConfirmContractCount,
-					got, test.stockService.confirmContractCount, test.marginService.confirmContractCount)
+				t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v, %+v, %+v\n", t.Name(),
+					test.want, test.wantStockConfirmContractCount, test.wantMarginConfirmContractCount, test.wantFutureConfirmContractCount, wantEvaluatePositionsCount, wantEvaluatePositionsCount,
+					got, test.stockService.confirmContractCount, test.marginService.confirmContractCount, futureService.confirmContractCount, len(test.stockService.updateSymbolPriceHistory), test.marginService.evaluatePositionsCount)
 			}
 		})
 	}
 }
 
 func Test_NewVirtualSecurity(t *testing.T) {
+	accountService := newAccountService(newClock(), &account{}, false, false, map[ExchangeType]FeeSchedule{}, MarginCostRates{})
 	want := &virtualSecurity{
-		clock:         newClock(),
-		priceService:  newPriceService(newClock(), getPriceStore(newClock())),
-		stockService:  newStockService(newUUIDGenerator(), getStockOrderStore(), getStockPositionStore(), newValidatorComponent(), newStockContractComponent()),
-		marginService: newMarginService(newUUIDGenerator(), getMarginOrderStore(), getMarginPositionStore(), newValidatorComponent(), newStockContractComponent()),
+		clock:           newClock(),
+		schedule:        defaultTradingSchedule,
+		priceService:    newPriceService(newClock(), getPriceStore(newClock()), defaultTradingSchedule),
+		stockService:    newStockService(newClock(), newUUIDGenerator(), getStockOrderStore(), getStockPositionStore(), getStockSymbolStore(), newValidatorComponent(), newStockContractComponent(defaultTradingSchedule), accountService),
+		marginService:   newMarginService(newClock(), newUUIDGenerator(), getMarginOrderStore(), getMarginPositionStore(), getStockSymbolStore(), newValidatorComponent(), newStockContractComponent(defaultTradingSchedule), accountService),
+		futureService:   newFutureService(newClock(), newUUIDGenerator(), getFutureOrderStore(), getFuturePositionStore(), getFutureSymbolStore(), newValidatorComponent(), newStockContractComponent(defaultTradingSchedule), accountService),
+		accountService:  accountService,
+		eventService:    newEventService(),
+		scheduleService: newScheduleService(newClock(), defaultTradingSchedule),
+		ledgerService:   newLedgerService(0),
 	}
 
 	got := NewVirtualSecurity()
@@ -515,25 +557,89 @@ func Test_NewVirtualSecurity(t *testing.T) {
 	}
 }
 
+func Test_NewVirtualSecurityWithOptions(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 8, 24, 7, 0, 0, 0, time.Local)}
+	schedule := newTradingSchedule(map[ExchangeType]SessionSchedule{ExchangeTypeStock: ExtendedStockSessionSchedule()})
+	clock := newClockWithCalendar(source, newCalendar(nil), schedule)
+	accountService := newAccountService(clock, &account{Cash: 1_000_000, InitialMarginRate: 0.3, MaintenanceMarginRate: 0.2}, true, false, nil, MarginCostRates{})
+	want := &virtualSecurity{
+		clock:           clock,
+		schedule:        schedule,
+		priceService:    newPriceService(clock, newPriceStore(clock), schedule),
+		stockService:    newStockService(clock, newUUIDGenerator(), newStockOrderStore(), newStockPositionStore(), newStockSymbolStore(), newValidatorComponent(), newStockContractComponent(schedule), accountService),
+		marginService:   newMarginService(clock, newUUIDGenerator(), newMarginOrderStore(), newMarginPositionStore(), newStockSymbolStore(), newValidatorComponent(), newStockContractComponent(schedule), accountService),
+		futureService:   newFutureService(clock, newUUIDGenerator(), newFutureOrderStore(), newFuturePositionStore(), newFutureSymbolStore(), newValidatorComponent(), newStockContractComponent(schedule), accountService),
+		accountService:  accountService,
+		eventService:    newEventService(),
+		scheduleService: newScheduleService(clock, schedule),
+		ledgerService:   newLedgerService(0),
+	}
+
+	got := NewVirtualSecurityWithOptions(WithClock(source), WithCash(1_000_000), WithSessionSchedule(ExchangeTypeMargin, ExtendedStockSessionSchedule()))
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_NewVirtualSecurityWithOptions_isolated(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 8, 24, 10, 0, 0, 0, time.Local)}
+	security1 := NewVirtualSecurityWithOptions(WithClock(source))
+	security2 := NewVirtualSecurityWithOptions(WithClock(source))
+
+	if err := security1.RegisterPrice(RegisterPriceRequest{
+		ExchangeType: ExchangeTypeStock,
+		SymbolCode:   "1234",
+		Price:        1000,
+		PriceTime:    time.Date(2021, 8, 24, 10, 0, 0, 0, time.Local),
+		Bid:          990,
+		BidTime:      time.Date(2021, 8, 24, 10, 0, 0, 0, time.Local),
+		Ask:          1010,
+		AskTime:      time.Date(2021, 8, 24, 10, 0, 0, 0, time.Local),
+	}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if _, err := security1.StockOrder(&StockOrderRequest{
+		Side:               SideBuy,
+		ExecutionCondition: StockExecutionConditionMO,
+		SymbolCode:         "1234",
+		Quantity:           100,
+	}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	orders1, _ := security1.StockOrders()
+	positions1, _ := security1.StockPositions()
+	orders2, _ := security2.StockOrders()
+	positions2, _ := security2.StockPositions()
+	if len(orders1) != 1 || len(positions1) != 1 || len(orders2) != 0 || len(positions2) != 0 {
+		t.Errorf("%s error\nwant: 1, 1, 0, 0\ngot: %d, %d, %d, %d\n", t.Name(), len(orders1), len(positions1), len(orders2), len(positions2))
+	}
+	if len(positions1) == 1 && (positions1[0].Price != 1000 || !positions1[0].ContractedAt.Equal(source.now1)) {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), 1000, source.now1, positions1[0].Price, positions1[0].ContractedAt)
+	}
+}
+
 func Test_virtualSecurity_MarginOrders(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
 		name                      string
-		security                  virtualSecurity
+		security                  *virtualSecurity
 		marginService             *testMarginService
 		want1                     []*MarginOrder
 		want2                     error
 		wantCancelAndReleaseCount int
 	}{
 		{name: "storeに注文がなければ空配列",
-			security: virtualSecurity{
+			security: &virtualSecurity{
 				clock: &testClock{now1: time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local)},
 			},
 			marginService: &testMarginService{getMarginOrders1: []*marginOrder{}},
 			want1:         []*MarginOrder{},
 			want2:         nil},
 		{name: "storeにある注文をMarginOrderに入れ替えて返す",
-			security: virtualSecurity{
+			security: &virtualSecurity{
 				clock: &testClock{now1: time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local)},
 			},
 			marginService: &testMarginService{getMarginOrders1: []*marginOrder{
@@ -577,7 +683,7 @@ func Test_virtualSecurity_MarginOrders(t *testing.T) {
 			},
 			want2: nil},
 		{name: "storeに複数注文があれば全部返す",
-			security: virtualSecurity{
+			security: &virtualSecurity{
 				clock: &testClock{now1: time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local)},
 			},
 			marginService: &testMarginService{getMarginOrders1: []*marginOrder{
@@ -592,7 +698,7 @@ func Test_virtualSecurity_MarginOrders(t *testing.T) {
 			},
 			want2: nil},
 		{name: "storeにある注文が死んだ注文なら返さない",
-			security: virtualSecurity{
+			security: &virtualSecurity{
 				clock: &testClock{now1: time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local)},
 			},
 			marginService: &testMarginService{getMarginOrders1: []*marginOrder{
@@ -605,7 +711,7 @@ func Test_virtualSecurity_MarginOrders(t *testing.T) {
 			},
 			want2: nil},
 		{name: "storeにある注文が有効期限切れならcancelAndReleaseを通す",
-			security: virtualSecurity{
+			security: &virtualSecurity{
 				clock: &testClock{now1: time.Date(2021, 6, 15, 10, 0, 0, 0, time.Local)},
 			},
 			marginService: &testMarginService{getMarginOrders1: []*marginOrder{
@@ -639,18 +745,18 @@ func Test_virtualSecurity_MarginPositions(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
 		name     string
-		security virtualSecurity
+		security *virtualSecurity
 		want1    []*MarginPosition
 		want2    error
 	}{
 		{name: "storeにデータが無ければ空配列を返す",
-			security: virtualSecurity{marginService: &testMarginService{
+			security: &virtualSecurity{clock: &testClock{}, accountService: &testAccountService{}, priceService: &testPriceService{getBySymbolCode2: NoDataError}, marginService: &testMarginService{
 				getMarginPositions1: []*marginPosition{},
 			}},
 			want1: []*MarginPosition{},
 			want2: nil},
 		{name: "storeにあるデータをMarginPositionに詰め替えて返す",
-			security: virtualSecurity{marginService: &testMarginService{
+			security: &virtualSecurity{clock: &testClock{}, accountService: &testAccountService{}, priceService: &testPriceService{getBySymbolCode2: NoDataError}, marginService: &testMarginService{
 				getMarginPositions1: []*marginPosition{
 					{
 						Code:               "spo_1234",
@@ -680,7 +786,7 @@ func Test_virtualSecurity_MarginPositions(t *testing.T) {
 			},
 			want2: nil},
 		{name: "storeに複数データがあれば全部返す",
-			security: virtualSecurity{marginService: &testMarginService{
+			security: &virtualSecurity{clock: &testClock{}, accountService: &testAccountService{}, priceService: &testPriceService{getBySymbolCode2: NoDataError}, marginService: &testMarginService{
 				getMarginPositions1: []*marginPosition{
 					{Code: "spo_1234", OwnedQuantity: 100},
 					{Code: "spo_2345", OwnedQuantity: 100},
@@ -693,8 +799,14 @@ func Test_virtualSecurity_MarginPositions(t *testing.T) {
 				{Code: "spo_3456", OwnedQuantity: 100},
 			},
 			want2: nil},
+		{name: "現在値があれば評価額と評価損益を計算する",
+			security: &virtualSecurity{clock: &testClock{}, accountService: &testAccountService{}, priceService: &testPriceService{getBySymbolCode1: &symbolPrice{SymbolCode: "1234", Price: 1100}}, marginService: &testMarginService{
+				getMarginPositions1: []*marginPosition{{Code: "mpo_1234", SymbolCode: "1234", Side: SideSell, OwnedQuantity: 100, Price: 1000}},
+			}},
+			want1: []*MarginPosition{{Code: "mpo_1234", SymbolCode: "1234", Side: SideSell, OwnedQuantity: 100, Price: 1000, CurrentPrice: 1100, MarketValue: 110000, UnrealizedProfit: -10000}},
+			want2: nil},
 		{name: "storeのデータが死んでいたら返さない",
-			security: virtualSecurity{marginService: &testMarginService{
+			security: &virtualSecurity{clock: &testClock{}, accountService: &testAccountService{}, priceService: &testPriceService{getBySymbolCode2: NoDataError}, marginService: &testMarginService{
 				getMarginPositions1: []*marginPosition{
 					{Code: "spo_1234", OwnedQuantity: 0},
 					{Code: "spo_2345", OwnedQuantity: 100},
@@ -816,6 +928,13 @@ func Test_virtualSecurity_MarginOrder(t *testing.T) {
 			arg:           &MarginOrderRequest{},
 			want1:         nil,
 			want2:         InvalidTradeTypeError},
+		{name: "entry注文で委託保証金の確認に失敗したらエラーを返す",
+			clock:         &testClock{now1: time.Date(2021, 8, 23, 10, 0, 0, 0, time.Local)},
+			priceService:  &testPriceService{},
+			marginService: &testMarginService{toMarginOrder1: &marginOrder{Code: "sor-1", TradeType: TradeTypeEntry}, reserveEntryOrderMargin1: NotEnoughMarginError, getMarginOrders1: []*marginOrder{}},
+			arg:           &MarginOrderRequest{TradeType: TradeTypeEntry},
+			want1:         nil,
+			want2:         NotEnoughMarginError},
 		{name: "該当銘柄の価格情報を取得し、価格情報がなくエラーが返されたらエラーを返す",
 			clock:                      &testClock{now1: time.Date(2021, 8, 23, 10, 0, 0, 0, time.Local)},
 			priceService:               &testPriceService{getBySymbolCode1: nil, getBySymbolCode2: InvalidSymbolCodeError},
@@ -833,7 +952,7 @@ func Test_virtualSecurity_MarginOrder(t *testing.T) {
 			want2:                      nil,
 			wantSaveMarginOrderHistory: []*marginOrder{{Code: "sor-1", TradeType: TradeTypeEntry}}},
 		{name: "該当銘柄の価格情報を取得できたEntry注文なら、entryの約定確認をする",
-			clock: &testClock{now1: time.Date(2021, 8, 23, 10, 0, 0, 0, time.Local), getStockSession1: SessionMorning},
+			clock: &testClock{now1: time.Date(2021, 8, 23, 10, 0, 0, 0, time.Local)},
 			priceService: &testPriceService{getBySymbolCode1: &symbolPrice{
 				SymbolCode: "1234",
 				Bid:        1000,
@@ -849,7 +968,7 @@ func Test_virtualSecurity_MarginOrder(t *testing.T) {
 			wantConfirmContract:        1,
 			wantSaveMarginOrderHistory: []*marginOrder{{Code: "sor-1", TradeType: TradeTypeEntry}}},
 		{name: "該当銘柄の価格情報を取得できたEntry注文なら、entryの約定確認をし、エラーがあってもエラーは返さない",
-			clock: &testClock{now1: time.Date(2021, 8, 23, 10, 0, 0, 0, time.Local), getStockSession1: SessionMorning},
+			clock: &testClock{now1: time.Date(2021, 8, 23, 10, 0, 0, 0, time.Local)},
 			priceService: &testPriceService{getBySymbolCode1: &symbolPrice{
 				SymbolCode: "1234",
 				Bid:        1000,
@@ -865,7 +984,7 @@ func Test_virtualSecurity_MarginOrder(t *testing.T) {
 			wantConfirmContract:        1,
 			wantSaveMarginOrderHistory: []*marginOrder{{Code: "sor-1", TradeType: TradeTypeEntry}}},
 		{name: "該当銘柄の価格情報を取得できたExit注文なら、exitの約定確認をする",
-			clock: &testClock{now1: time.Date(2021, 8, 23, 10, 0, 0, 0, time.Local), getStockSession1: SessionMorning},
+			clock: &testClock{now1: time.Date(2021, 8, 23, 10, 0, 0, 0, time.Local)},
 			priceService: &testPriceService{getBySymbolCode1: &symbolPrice{
 				SymbolCode: "1234",
 				Bid:        1000,
@@ -882,7 +1001,7 @@ func Test_virtualSecurity_MarginOrder(t *testing.T) {
 			wantHoldExitOrderPositions: 1,
 			wantSaveMarginOrderHistory: []*marginOrder{{Code: "sor-1", TradeType: TradeTypeExit}}},
 		{name: "該当銘柄の価格情報を取得できたExit注文なら、exitの約定確認をし、エラーがあってもエラーは返さない",
-			clock: &testClock{now1: time.Date(2021, 8, 23, 10, 0, 0, 0, time.Local), getStockSession1: SessionMorning},
+			clock: &testClock{now1: time.Date(2021, 8, 23, 10, 0, 0, 0, time.Local)},
 			priceService: &testPriceService{getBySymbolCode1: &symbolPrice{
 				SymbolCode: "1234",
 				Bid:        1000,
@@ -899,7 +1018,7 @@ func Test_virtualSecurity_MarginOrder(t *testing.T) {
 			wantHoldExitOrderPositions: 1,
 			wantSaveMarginOrderHistory: []*marginOrder{{Code: "sor-1", TradeType: TradeTypeExit}}},
 		{name: "exit注文でもholdに失敗したらエラー",
-			clock:                      &testClock{now1: time.Date(2021, 8, 23, 10, 0, 0, 0, time.Local), getStockSession1: SessionMorning},
+			clock:                      &testClock{now1: time.Date(2021, 8, 23, 10, 0, 0, 0, time.Local)},
 			priceService:               &testPriceService{},
 			marginService:              &testMarginService{toMarginOrder1: &marginOrder{Code: "sor-1", TradeType: TradeTypeExit}, holdExitOrderPositions1: InvalidExitPositionError, getMarginOrders1: []*marginOrder{}},
 			arg:                        &MarginOrderRequest{TradeType: TradeTypeExit},
@@ -927,3 +1046,1336 @@ func Test_virtualSecurity_MarginOrder(t *testing.T) {
 		})
 	}
 }
+
+func Test_virtualSecurity_Deposit(t *testing.T) {
+	t.Parallel()
+	accountService := &testAccountService{deposit1: InvalidAmountError}
+	security := &virtualSecurity{accountService: accountService}
+	got := security.Deposit(-100)
+	if !errors.Is(got, InvalidAmountError) || !reflect.DeepEqual([]float64{-100}, accountService.depositHistory) {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), InvalidAmountError, []float64{-100}, got, accountService.depositHistory)
+	}
+}
+
+func Test_virtualSecurity_Withdraw(t *testing.T) {
+	t.Parallel()
+	accountService := &testAccountService{withdraw1: NotEnoughCashError}
+	security := &virtualSecurity{accountService: accountService}
+	got := security.Withdraw(100)
+	if !errors.Is(got, NotEnoughCashError) || !reflect.DeepEqual([]float64{100}, accountService.withdrawHistory) {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), NotEnoughCashError, []float64{100}, got, accountService.withdrawHistory)
+	}
+}
+
+func Test_virtualSecurity_Price(t *testing.T) {
+	t.Parallel()
+	priceTime := time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		name         string
+		priceService *testPriceService
+		want1        *SymbolPrice
+		want2        error
+	}{
+		{name: "価格が取れなければエラー",
+			priceService: &testPriceService{getBySymbolCode2: ExpiredDataError},
+			want1:        nil,
+			want2:        ExpiredDataError},
+		{name: "価格が取れたら公開用の価格にして返す",
+			priceService: &testPriceService{getBySymbolCode1: &symbolPrice{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: priceTime, Bid: 999, BidTime: priceTime, Ask: 1001, AskTime: priceTime,
+				BidBoard: []BoardLevel{{Price: 999, Quantity: 100}}, AskBoard: []BoardLevel{{Price: 1001, Quantity: 200}}, kind: PriceKindRegular, contractedVolume: 100}},
+			want1: &SymbolPrice{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: priceTime, Bid: 999, BidTime: priceTime, Ask: 1001, AskTime: priceTime,
+				BidBoard: []BoardLevel{{Price: 999, Quantity: 100}}, AskBoard: []BoardLevel{{Price: 1001, Quantity: 200}}},
+			want2: nil},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			security := &virtualSecurity{priceService: test.priceService}
+			got1, got2 := security.Price("1234")
+			if !reflect.DeepEqual(test.want1, got1) || !errors.Is(got2, test.want2) {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want1, test.want2, got1, got2)
+			}
+		})
+	}
+}
+
+func Test_virtualSecurity_Account(t *testing.T) {
+	t.Parallel()
+	want := &Account{Cash: 1_000_000, ReservedCash: 100_000, BuyingPower: 900_000, MarginDeposit: 900_000}
+	security := &virtualSecurity{accountService: &testAccountService{getAccount1: want}}
+	got1, got2 := security.Account()
+	if !reflect.DeepEqual(want, got1) || got2 != nil {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), want, nil, got1, got2)
+	}
+}
+
+func Test_virtualSecurity_buyingPower(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local)}
+	security := NewVirtualSecurityWithOptions(WithClock(source), WithCash(150_000))
+	if err := security.RegisterPrice(RegisterPriceRequest{
+		ExchangeType: ExchangeTypeStock,
+		SymbolCode:   "1234",
+		Price:        1000,
+		PriceTime:    time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local),
+	}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 余力を超える注文はできない
+	if _, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 200, LimitPrice: 900}); !errors.Is(err, NotEnoughBuyingPowerError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), NotEnoughBuyingPowerError, err)
+	}
+
+	// 余力の範囲内なら注文でき、注文中は拘束される
+	res, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 900})
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want1 := &Account{Cash: 150_000, ReservedCash: 90_000, BuyingPower: 60_000, MarginDeposit: 60_000}
+	got1, _ := security.Account()
+
+	// 取り消したら拘束が解かれる
+	if err := security.CancelStockOrder(&CancelOrderRequest{OrderCode: res.OrderCode}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want2 := &Account{Cash: 150_000, ReservedCash: 0, BuyingPower: 150_000, MarginDeposit: 150_000}
+	got2, _ := security.Account()
+
+	if !reflect.DeepEqual(want1, got1) || !reflect.DeepEqual(want2, got2) {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), want1, want2, got1, got2)
+	}
+}
+
+func Test_virtualSecurity_marginCall(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local)}
+	security := NewVirtualSecurityWithOptions(WithClock(source), WithCash(100_000), WithMarginCallLiquidation())
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 委託保証金が足りなければ新規注文できない
+	if _, err := security.MarginOrder(&MarginOrderRequest{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 400, LimitPrice: 1000}); !errors.Is(err, NotEnoughMarginError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), NotEnoughMarginError, err)
+	}
+
+	// 委託保証金の範囲内なら新規注文でき、約定すれば建玉になる
+	if _, err := security.MarginOrder(&MarginOrderRequest{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 300}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want1 := &Account{Cash: 100_000, BuyingPower: 10_000, MarginDeposit: 100_000, MarginPositionAmount: 300_000, RequiredMargin: 90_000, MaintenanceRatio: 100_000.0 / 300_000}
+	got1, _ := security.Account()
+
+	// 値下がりで維持率が最低維持率を下回ったら追証になり、強制決済される
+	source.now1 = time.Date(2021, 8, 25, 10, 1, 0, 0, time.Local)
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 850, PriceTime: source.now1, Bid: 850, BidTime: source.now1, Ask: 860, AskTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	got2, _ := security.Account()
+	positions, _ := security.MarginPositions()
+
+	if !reflect.DeepEqual(want1, got1) ||
+		!got2.IsMarginCall ||
+		!got2.MarginCalledAt.Equal(source.now1) ||
+		got2.Cash != 55_000 ||
+		len(positions) != 0 {
+		t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v\n", t.Name(),
+			want1, true, 55_000, 0,
+			got1, got2.IsMarginCall, got2.Cash, len(positions))
+	}
+}
+
+func Test_virtualSecurity_StockOrder_sellSymbol(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local)}
+	security := NewVirtualSecurityWithOptions(WithClock(source))
+	for _, symbolCode := range []string{"1234", "5678"} {
+		if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: symbolCode, Price: 1000, PriceTime: source.now1}); err != nil {
+			t.Fatalf("%s error\n%+v\n", t.Name(), err)
+		}
+		if _, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, SymbolCode: symbolCode, Quantity: 100}); err != nil {
+			t.Fatalf("%s error\n%+v\n", t.Name(), err)
+		}
+	}
+
+	// 別銘柄のポジションを使って売ることはできない
+	if _, err := security.StockOrder(&StockOrderRequest{Side: SideSell, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 200, LimitPrice: 1100}); !errors.Is(err, NotEnoughOwnedQuantityError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), NotEnoughOwnedQuantityError, err)
+	}
+
+	// 同じ銘柄のポジションだけが拘束される
+	if _, err := security.StockOrder(&StockOrderRequest{Side: SideSell, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 1100}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	positions, _ := security.StockPositions()
+	got := map[string]float64{}
+	for _, p := range positions {
+		got[p.SymbolCode] = p.HoldQuantity
+	}
+	want := map[string]float64{"1234": 100, "5678": 0}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+}
+
+func Test_virtualSecurity_StockOrder_partialContract(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local)}
+	security := NewVirtualSecurityWithOptions(WithClock(source))
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 売気配数量の分だけ約定し、残りは注文中のまま
+	source.now1 = time.Date(2021, 8, 25, 10, 1, 0, 0, time.Local)
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: source.now1, Ask: 1010, AskTime: source.now1, AskQuantity: 100}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	res, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 300})
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	orders1, _ := security.StockOrders()
+	var got1 *StockOrder
+	for _, o := range orders1 {
+		if o.Code == res.OrderCode {
+			got1 = o
+		}
+	}
+
+	// 次の価格で残りが約定する
+	source.now1 = time.Date(2021, 8, 25, 10, 2, 0, 0, time.Local)
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1010, PriceTime: source.now1, Ask: 1020, AskTime: source.now1, AskQuantity: 500}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	positions, _ := security.StockPositions()
+
+	if got1 == nil || got1.OrderStatus != OrderStatusPart || got1.ContractedQuantity != 100 || len(positions) != 2 ||
+		positions[0].OwnedQuantity+positions[1].OwnedQuantity != 300 {
+		t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v\n", t.Name(), OrderStatusPart, 100, 2, got1, positions)
+	}
+}
+
+func Test_virtualSecurity_StockOrder_boardContract(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local)}
+	security := NewVirtualSecurityWithOptions(WithClock(source))
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 成行買いは売り板を安い順に約定していく
+	source.now1 = time.Date(2021, 8, 25, 10, 1, 0, 0, time.Local)
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: source.now1, AskTime: source.now1,
+		AskBoard: []BoardLevel{{Price: 1001, Quantity: 100}, {Price: 1002, Quantity: 100}, {Price: 1003, Quantity: 100}}}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	res, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 250})
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	orders, _ := security.StockOrders()
+	var got *StockOrder
+	for _, o := range orders {
+		if o.Code == res.OrderCode {
+			got = o
+		}
+	}
+	positions, _ := security.StockPositions()
+
+	want := []float64{1001, 1002, 1003}
+	wantQuantity := []float64{100, 100, 50}
+	if got == nil || got.OrderStatus != OrderStatusDone || len(got.Contracts) != 3 || len(positions) != 3 {
+		t.Fatalf("%s error\nwant: %+v, %+v, %+v\ngot: %+v, %+v\n", t.Name(), OrderStatusDone, 3, 3, got, positions)
+	}
+	for i, c := range got.Contracts {
+		if c.Price != want[i] || c.Quantity != wantQuantity[i] {
+			t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v\n", t.Name(), want[i], wantQuantity[i], c)
+		}
+	}
+}
+
+func Test_virtualSecurity_StockOrder_boardContract_buyingPower(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		cash     float64
+		want     error
+		wantCash float64
+	}{
+		{name: "売板を上の気配値まで約定する代金が買付余力を超えるならエラー", cash: 260_000, want: NotEnoughBuyingPowerError, wantCash: 260_000},
+		{name: "売板を上の気配値まで約定しても買付余力が足りるなら約定する", cash: 300_000, want: nil, wantCash: 30_000},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			source := &testSourceClock{now1: time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local)}
+			security := NewVirtualSecurityWithOptions(WithClock(source), WithCash(test.cash))
+			if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: source.now1}); err != nil {
+				t.Fatalf("%s error\n%+v\n", t.Name(), err)
+			}
+			source.now1 = time.Date(2021, 8, 25, 10, 1, 0, 0, time.Local)
+			if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: source.now1, AskTime: source.now1,
+				AskBoard: []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 1100, Quantity: 100}, {Price: 1200, Quantity: 100}}}); err != nil {
+				t.Fatalf("%s error\n%+v\n", t.Name(), err)
+			}
+
+			// 売り気配値の1000円で見積もると買付余力が足りても、約定代金は270,000円になる
+			_, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 250})
+			account, _ := security.Account()
+			if !errors.Is(err, test.want) || account.Cash != test.wantCash || account.Cash < 0 || account.ReservedCash != 0 {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want, test.wantCash, err, account)
+			}
+		})
+	}
+}
+
+func Test_virtualSecurity_StockOrder_itayose(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 8, 25, 8, 50, 0, 0, time.Local)}
+	security := NewVirtualSecurityWithOptions(WithClock(source))
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 寄付前の注文は寄付まで約定しない
+	source.now1 = time.Date(2021, 8, 25, 8, 55, 0, 0, time.Local)
+	var codes []string
+	for _, req := range []*StockOrderRequest{
+		{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 1000},
+		{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 1010},
+		{Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 100},
+	} {
+		res, err := security.StockOrder(req)
+		if err != nil {
+			t.Fatalf("%s error\n%+v\n", t.Name(), err)
+		}
+		codes = append(codes, res.OrderCode)
+	}
+
+	// 寄付の板寄せでは、成行、指値の高い順に、板寄せの約定値段で約定数量まで約定する
+	source.now1 = time.Date(2021, 8, 25, 9, 0, 0, 0, time.Local)
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", PriceTime: source.now1, BidTime: source.now1, AskTime: source.now1,
+		BidBoard: []BoardLevel{{Price: 1005, Quantity: 150}},
+		AskBoard: []BoardLevel{{Price: 1000, Quantity: 100}, {Price: 1005, Quantity: 100}}}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	orders, _ := security.StockOrders()
+	got := map[string]*StockOrder{}
+	for _, o := range orders {
+		got[o.Code] = o
+	}
+
+	want := []struct {
+		status   OrderStatus
+		quantity float64
+	}{{OrderStatusInOrder, 0}, {OrderStatusPart, 50}, {OrderStatusDone, 100}}
+	for i, code := range codes {
+		o := got[code]
+		if o == nil || o.OrderStatus != want[i].status || o.ContractedQuantity != want[i].quantity {
+			t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want[i], o)
+		}
+		for _, c := range o.Contracts {
+			if c.Price != 1005 {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 1005, c)
+			}
+		}
+	}
+}
+
+func Test_priorityOrder_isPrior(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		order *priorityOrder
+		other *priorityOrder
+		want  bool
+	}{
+		{name: "成行は指値より優先される",
+			order: &priorityOrder{exchangeType: ExchangeTypeStock, side: SideBuy, isMarket: true, orderedAt: time.Date(2021, 8, 25, 8, 55, 0, 0, time.Local)},
+			other: &priorityOrder{exchangeType: ExchangeTypeStock, side: SideBuy, limitPrice: 1100, orderedAt: time.Date(2021, 8, 25, 8, 50, 0, 0, time.Local)},
+			want:  true},
+		{name: "指値は成行より優先されない",
+			order: &priorityOrder{exchangeType: ExchangeTypeStock, side: SideSell, limitPrice: 900},
+			other: &priorityOrder{exchangeType: ExchangeTypeStock, side: SideSell, isMarket: true},
+			want:  false},
+		{name: "買いの指値は高い方が優先される",
+			order: &priorityOrder{exchangeType: ExchangeTypeStock, side: SideBuy, limitPrice: 1010, orderedAt: time.Date(2021, 8, 25, 8, 55, 0, 0, time.Local)},
+			other: &priorityOrder{exchangeType: ExchangeTypeStock, side: SideBuy, limitPrice: 1000, orderedAt: time.Date(2021, 8, 25, 8, 50, 0, 0, time.Local)},
+			want:  true},
+		{name: "売りの指値は安い方が優先される",
+			order: &priorityOrder{exchangeType: ExchangeTypeStock, side: SideSell, limitPrice: 1010},
+			other: &priorityOrder{exchangeType: ExchangeTypeStock, side: SideSell, limitPrice: 1000},
+			want:  false},
+		{name: "指値価格のない注文は指値より優先されない",
+			order: &priorityOrder{exchangeType: ExchangeTypeStock, side: SideSell},
+			other: &priorityOrder{exchangeType: ExchangeTypeStock, side: SideSell, limitPrice: 1100},
+			want:  false},
+		{name: "同じ価格なら注文日時の早い方が優先される",
+			order: &priorityOrder{exchangeType: ExchangeTypeStock, side: SideBuy, limitPrice: 1000, orderedAt: time.Date(2021, 8, 25, 8, 50, 0, 0, time.Local)},
+			other: &priorityOrder{exchangeType: ExchangeTypeStock, side: SideBuy, limitPrice: 1000, orderedAt: time.Date(2021, 8, 25, 8, 55, 0, 0, time.Local)},
+			want:  true},
+		{name: "成行同士なら注文日時の早い方が優先される",
+			order: &priorityOrder{exchangeType: ExchangeTypeFuture, side: SideBuy, isMarket: true, orderedAt: time.Date(2021, 8, 25, 8, 55, 0, 0, time.Local)},
+			other: &priorityOrder{exchangeType: ExchangeTypeFuture, side: SideBuy, isMarket: true, orderedAt: time.Date(2021, 8, 25, 8, 50, 0, 0, time.Local)},
+			want:  false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := test.order.isPrior(test.other)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_virtualSecurity_StockOrder_ioc(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local)}
+	security := NewVirtualSecurityWithOptions(WithClock(source))
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	source.now1 = time.Date(2021, 8, 25, 10, 1, 0, 0, time.Local)
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: source.now1, AskTime: source.now1, BidTime: source.now1,
+		AskBoard: []BoardLevel{{Price: 1001, Quantity: 100}, {Price: 1003, Quantity: 100}},
+		BidBoard: []BoardLevel{{Price: 999, Quantity: 100}}}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// IOC指値の買いは指値までの板で約定し、残りは取り消される
+	res1, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionIOCLO, SymbolCode: "1234", Quantity: 300, LimitPrice: 1002})
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// IOC指値の売りは約定しなければすべて取り消され、拘束したポジションを開放する
+	res2, err := security.StockOrder(&StockOrderRequest{Side: SideSell, ExecutionCondition: StockExecutionConditionIOCLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 1100})
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	orders, _ := security.StockOrders()
+	got := map[string]*StockOrder{}
+	for _, o := range orders {
+		got[o.Code] = o
+	}
+	if o := got[res1.OrderCode]; o == nil || o.OrderStatus != OrderStatusCanceled || o.ContractedQuantity != 100 || o.CanceledQuantity != 200 || !o.CanceledAt.Equal(source.now1) {
+		t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v\n", t.Name(), OrderStatusCanceled, 100, 200, o)
+	}
+	if o := got[res2.OrderCode]; o == nil || o.OrderStatus != OrderStatusCanceled || o.ContractedQuantity != 0 || o.CanceledQuantity != 100 {
+		t.Errorf("%s error\nwant: %+v, %+v, %+v\ngot: %+v\n", t.Name(), OrderStatusCanceled, 0, 100, o)
+	}
+	positions, _ := security.StockPositions()
+	if len(positions) != 1 || positions[0].OwnedQuantity != 100 || positions[0].HoldQuantity != 0 {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v\n", t.Name(), 100, 0, positions)
+	}
+}
+
+func Test_virtualSecurity_FutureOrder_settlement(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 6, 4, 10, 0, 0, 0, time.Local)}
+	security := NewVirtualSecurityWithOptions(WithClock(source), WithCash(1_000_000))
+	if err := security.RegisterFutureSymbol(&FutureSymbol{SymbolCode: "167060019", Multiplier: 100, LastTradingDay: time.Date(2021, 6, 10, 0, 0, 0, 0, time.Local)}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 日中のザラバで成行買いをすると売り気配値で約定する
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeFuture, SymbolCode: "167060019", Price: 28950, PriceTime: source.now1, Ask: 29000, AskTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	source.now1 = time.Date(2021, 6, 4, 10, 0, 1, 0, time.Local)
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeFuture, SymbolCode: "167060019", Price: 28950, PriceTime: source.now1, Ask: 29000, AskTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if _, err := security.FutureOrder(&FutureOrderRequest{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionMO, SymbolCode: "167060019", Quantity: 1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	positions, _ := security.FuturePositions()
+	if len(positions) != 1 || positions[0].Price != 29000 || positions[0].SettlementPrice != 29000 || positions[0].Multiplier != 100 {
+		t.Fatalf("%s error\nwant: 1 position at 29000\ngot: %+v\n", t.Name(), positions)
+	}
+
+	// 日中の引けの価格で値洗いされ、損益が預り金に反映される
+	source.now1 = time.Date(2021, 6, 4, 15, 45, 0, 0, time.Local)
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeFuture, SymbolCode: "167060019", Price: 29100, PriceTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if got, _ := security.Account(); got.Cash != 1_010_000 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 1_010_000, got.Cash)
+	}
+	positions, _ = security.FuturePositions()
+	if len(positions) != 1 || positions[0].SettlementPrice != 29100 {
+		t.Errorf("%s error\nwant: settlement price 29100\ngot: %+v\n", t.Name(), positions)
+	}
+
+	// SQ値で決済され、前回の清算値からの損益が預り金に反映される
+	source.now1 = time.Date(2021, 6, 11, 9, 0, 0, 0, time.Local)
+	if err := security.SettleFutureSQ("167060019", 28900); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if got, _ := security.Account(); got.Cash != 990_000 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 990_000, got.Cash)
+	}
+	positions, _ = security.FuturePositions()
+	if len(positions) != 0 {
+		t.Errorf("%s error\nwant: no positions\ngot: %+v\n", t.Name(), positions)
+	}
+
+	// SQ後の銘柄は注文できない
+	if _, err := security.FutureOrder(&FutureOrderRequest{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionMO, SymbolCode: "167060019", Quantity: 1}); !errors.Is(err, ExpiredSymbolError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), ExpiredSymbolError, err)
+	}
+}
+
+func Test_virtualSecurity_FutureOrder_expiredSymbol(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 6, 10, 10, 0, 0, 0, time.Local)}
+	security := NewVirtualSecurityWithOptions(WithClock(source), WithCash(1_000_000))
+	if err := security.RegisterFutureSymbol(&FutureSymbol{SymbolCode: "167060019", Multiplier: 100, LastTradingDay: time.Date(2021, 6, 10, 0, 0, 0, 0, time.Local)}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	for _, now := range []time.Time{source.now1, source.now1.Add(time.Second)} {
+		source.now1 = now
+		if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeFuture, SymbolCode: "167060019", Price: 28950, PriceTime: now, Ask: 29000, AskTime: now, Bid: 28900, BidTime: now}); err != nil {
+			t.Fatalf("%s error\n%+v\n", t.Name(), err)
+		}
+	}
+	if _, err := security.FutureOrder(&FutureOrderRequest{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionMO, SymbolCode: "167060019", Quantity: 1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 取引最終日の注文は、有効期限の営業日数を指定しても取引最終日までしか有効にならない
+	res, err := security.FutureOrder(&FutureOrderRequest{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: FutureExecutionConditionLO, SymbolCode: "167060019", Quantity: 1, LimitPrice: 28000, ExpireBusinessDays: 5})
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	orders, _ := security.FutureOrders()
+	for _, o := range orders {
+		if o.Code == res.OrderCode && !o.ExpiredAt.Equal(time.Date(2021, 6, 10, 0, 0, 0, 0, time.Local)) {
+			t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), time.Date(2021, 6, 10, 0, 0, 0, 0, time.Local), o.ExpiredAt)
+		}
+	}
+
+	// 取引最終日を過ぎると、残ったポジションは期限切れの銘柄のポジションになる
+	source.now1 = time.Date(2021, 6, 11, 9, 0, 0, 0, time.Local)
+	if err := security.Tick(); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	orders, _ = security.FutureOrders()
+	for _, o := range orders {
+		if o.Code == res.OrderCode && o.OrderStatus != OrderStatusCanceled {
+			t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), OrderStatusCanceled, o.OrderStatus)
+		}
+	}
+	positions, _ := security.FuturePositions()
+	if len(positions) != 1 || !positions[0].IsExpired {
+		t.Errorf("%s error\nwant: 1 expired position\ngot: %+v\n", t.Name(), positions)
+	}
+
+	// SQ値を登録すれば決済される
+	if err := security.SettleFutureSQ("167060019", 28900); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	positions, _ = security.FuturePositions()
+	if len(positions) != 0 {
+		t.Errorf("%s error\nwant: no positions\ngot: %+v\n", t.Name(), positions)
+	}
+
+	// ポジションがなくなっても、SQによる決済は銘柄の取引単位で約定履歴に記録される
+	trades, _ := security.Trades()
+	if len(trades) != 2 || trades[1].TradeType != TradeTypeExit || trades[1].Multiplier != 100 || trades[1].Profit != -10000 {
+		t.Errorf("%s error\nwant: exit trade with multiplier 100 and profit -10000\ngot: %+v\n", t.Name(), trades)
+	}
+}
+
+func Test_virtualSecurity_Subscribe(t *testing.T) {
+	t.Parallel()
+	unsubscribe := func() {}
+	security := &virtualSecurity{eventService: &testEventService{subscribe1: unsubscribe, subscribe2: nil}}
+	got1, got2 := security.Subscribe(func(Event) {})
+	if got1 == nil || got2 != nil {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), true, nil, got1 != nil, got2)
+	}
+}
+
+func Test_virtualSecurity_tradingState(t *testing.T) {
+	t.Parallel()
+
+	t.Run("購読者がいなければ状態を記録しない", func(t *testing.T) {
+		t.Parallel()
+		security := &virtualSecurity{eventService: &testEventService{isSubscribed1: false}}
+		if got := security.tradingState(); got != nil {
+			t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), nil, got)
+		}
+	})
+
+	t.Run("購読者がいれば注文とポジションの状態を記録する", func(t *testing.T) {
+		t.Parallel()
+		security := &virtualSecurity{
+			clock:        &testClock{now1: time.Date(2021, 9, 2, 10, 0, 0, 0, time.Local)},
+			eventService: &testEventService{isSubscribed1: true},
+			stockService: &testStockService{
+				getStockOrders1:    []*stockOrder{{Code: "sor-01", SymbolCode: "1234", OrderStatus: OrderStatusCanceled, OrderQuantity: 100, ExpiredAt: time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)}},
+				getStockPositions1: []*stockPosition{{Code: "spo-01", OrderCode: "sor-00", SymbolCode: "1234", OwnedQuantity: 100}}},
+			marginService: &testMarginService{
+				getMarginOrders1: []*marginOrder{{Code: "mor-01", SymbolCode: "1234", OrderStatus: OrderStatusInOrder, OrderQuantity: 100, StopCondition: &StockStopCondition{isActivate: true}}}},
+			futureService: &testFutureService{},
+		}
+		want := &tradingState{
+			orders: []*orderState{
+				{exchangeType: ExchangeTypeMargin, code: "mor-01", symbolCode: "1234", status: OrderStatusInOrder, orderQuantity: 100, isStopActivated: true},
+				{exchangeType: ExchangeTypeStock, code: "sor-01", symbolCode: "1234", status: OrderStatusCanceled, orderQuantity: 100, isExpired: true}},
+			positions: []*positionState{
+				{exchangeType: ExchangeTypeStock, code: "spo-01", orderCode: "sor-00", symbolCode: "1234", ownedQuantity: 100}},
+		}
+		got := security.tradingState()
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+		}
+	})
+}
+
+func Test_virtualSecurity_events(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 9, 2, 10, 0, 0, 0, time.Local)}
+	security := NewVirtualSecurityWithOptions(WithClock(source))
+	var got []EventType
+	if _, err := security.Subscribe(func(e Event) { got = append(got, e.EventType) }); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	var unsubscribedCount int
+	unsubscribe, err := security.Subscribe(func(Event) { unsubscribedCount++ })
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: source.now1, Ask: 1001, AskTime: source.now1, Bid: 999, BidTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 成行の買い注文は、受付・全約定・ポジションの新規を通知する
+	if _, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 100}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want := []EventType{EventTypeOrderAccepted, EventTypeOrderContracted, EventTypePositionOpened}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+
+	// 約定しない指値の売り注文を取り消すと、受付と取消を通知する
+	got = nil
+	unsubscribe()
+	res, err := security.StockOrder(&StockOrderRequest{Side: SideSell, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 1100})
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if err := security.CancelStockOrder(&CancelOrderRequest{OrderCode: res.OrderCode}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want = []EventType{EventTypeOrderAccepted, EventTypeOrderCanceled}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+
+	// 逆指値の売り注文は、価格の登録で発動・全約定・ポジションの返済を通知する
+	got = nil
+	if _, err := security.StockOrder(&StockOrderRequest{Side: SideSell, ExecutionCondition: StockExecutionConditionStop, SymbolCode: "1234", Quantity: 100,
+		StopCondition: &StockStopCondition{StopPrice: 990, ComparisonOperator: ComparisonOperatorLE, ExecutionConditionAfterHit: StockExecutionConditionMO}}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	source.now1 = time.Date(2021, 9, 2, 10, 1, 0, 0, time.Local)
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 990, PriceTime: source.now1, Ask: 991, AskTime: source.now1, Bid: 989, BidTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want = []EventType{EventTypeOrderAccepted, EventTypeOrderStopTriggered, EventTypeOrderContracted, EventTypePositionClosed}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+
+	// 有効期限切れの注文は、翌日の注文一覧の取得で失効を通知する
+	got = nil
+	if _, err := security.MarginOrder(&MarginOrderRequest{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 900}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	source.now1 = time.Date(2021, 9, 3, 10, 0, 0, 0, time.Local)
+	if _, err := security.MarginOrders(); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want = []EventType{EventTypeOrderAccepted, EventTypeOrderExpired}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+
+	// 購読を解除したハンドラには通知しない
+	if unsubscribedCount != 3 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 3, unsubscribedCount)
+	}
+}
+
+func Test_virtualSecurity_Tick(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2021, 9, 2, 11, 30, 5, 0, time.Local)
+	auction := &closedAuction{closedAt: now, stockExecutionConditions: []StockExecutionCondition{StockExecutionConditionMOMO}}
+	tests := []struct {
+		name                              string
+		getStockOrders                    []*stockOrder
+		getStockPositions                 []*stockPosition
+		closedAuctions                    []*closedAuction
+		wantCancelAndReleaseCount         int
+		wantMessage                       string
+		wantRemoveStockOrderByCodeHistory []string
+		wantRemoveStockPositionHistory    []string
+	}{
+		{name: "何もなければ何もしない"},
+		{name: "有効期限切れの注文は失効させる",
+			getStockOrders:            []*stockOrder{{Code: "sor-01", OrderStatus: OrderStatusInOrder, ExecutionCondition: StockExecutionConditionLO, ExpiredAt: time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)}},
+			wantCancelAndReleaseCount: 1},
+		{name: "終わった板寄せで約定しなかった注文は取り消す",
+			getStockOrders:            []*stockOrder{{Code: "sor-01", OrderStatus: OrderStatusInOrder, ExecutionCondition: StockExecutionConditionMOMO, OrderedAt: time.Date(2021, 9, 2, 8, 0, 0, 0, time.Local)}},
+			closedAuctions:            []*closedAuction{auction},
+			wantCancelAndReleaseCount: 1,
+			wantMessage:               "板寄せで約定しなかったため取消"},
+		{name: "終わった板寄せで約定した注文は取り消さない",
+			getStockOrders: []*stockOrder{{Code: "sor-01", OrderStatus: OrderStatusDone, ExecutionCondition: StockExecutionConditionMOMO, OrderedAt: time.Date(2021, 9, 2, 8, 0, 0, 0, time.Local),
+				Contracts: []*Contract{{ContractedAt: time.Date(2021, 9, 2, 9, 0, 0, 0, time.Local)}}}},
+			closedAuctions: []*closedAuction{auction}},
+		{name: "終了してから時間の経った注文やポジションは削除する",
+			getStockOrders:                    []*stockOrder{{Code: "sor-01", OrderStatus: OrderStatusCanceled, CanceledAt: time.Date(2021, 8, 31, 9, 0, 0, 0, time.Local)}},
+			getStockPositions:                 []*stockPosition{{Code: "spo-01", OwnedQuantity: 0, HoldQuantity: 0}},
+			wantRemoveStockOrderByCodeHistory: []string{"sor-01"},
+			wantRemoveStockPositionHistory:    []string{"spo-01"}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			stockService := &testStockService{getStockOrders1: test.getStockOrders, getStockPositions1: test.getStockPositions}
+			scheduleService := &testScheduleService{closedAuctions1: test.closedAuctions}
+			futureService := &testFutureService{}
+			security := &virtualSecurity{
+				clock:           &testClock{now1: now, addBusinessDays1: time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)},
+				stockService:    stockService,
+				marginService:   &testMarginService{},
+				futureService:   futureService,
+				scheduleService: scheduleService,
+			}
+			got := security.Tick()
+			var gotMessage string
+			if len(test.getStockOrders) > 0 {
+				gotMessage = test.getStockOrders[0].Message
+			}
+			if got != nil ||
+				test.wantCancelAndReleaseCount != stockService.cancelAndReleaseCount ||
+				test.wantMessage != gotMessage ||
+				!reflect.DeepEqual(test.wantRemoveStockOrderByCodeHistory, stockService.removeStockOrderByCodeHistory) ||
+				!reflect.DeepEqual(test.wantRemoveStockPositionHistory, stockService.removeStockPositionByCodeHistory) ||
+				!reflect.DeepEqual([]time.Time{now}, scheduleService.closedAuctionsHistory) ||
+				futureService.expireSymbolsCount != 1 {
+				t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v, %+v\n", t.Name(),
+					test.wantCancelAndReleaseCount, test.wantMessage, test.wantRemoveStockOrderByCodeHistory, test.wantRemoveStockPositionHistory,
+					got, stockService.cancelAndReleaseCount, gotMessage, stockService.removeStockOrderByCodeHistory, stockService.removeStockPositionByCodeHistory)
+			}
+		})
+	}
+
+	t.Run("取引最終日を過ぎた先物銘柄の注文を取り消せなければエラーを返す", func(t *testing.T) {
+		t.Parallel()
+		futureService := &testFutureService{expireSymbols1: NoDataError}
+		security := &virtualSecurity{
+			clock:           &testClock{now1: now, addBusinessDays1: time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)},
+			stockService:    &testStockService{},
+			marginService:   &testMarginService{},
+			futureService:   futureService,
+			scheduleService: &testScheduleService{},
+		}
+		got := security.Tick()
+		if !errors.Is(got, NoDataError) || futureService.expireSymbolsCount != 1 {
+			t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), NoDataError, 1, got, futureService.expireSymbolsCount)
+		}
+	})
+}
+
+func Test_virtualSecurity_recordTrades(t *testing.T) {
+	t.Parallel()
+	day := time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local)
+	contractedAt := time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local)
+	ledgerService := &testLedgerService{}
+	security := &virtualSecurity{
+		clock: &testClock{getBusinessDay1: day},
+		stockService: &testStockService{getStockOrders1: []*stockOrder{
+			{Code: "sor-1", SymbolCode: "1234", Side: SideBuy, Contracts: []*Contract{{ContractCode: "sco-1", OrderCode: "sor-1", PositionCode: "spo-1", Price: 1000, Quantity: 100, ContractedAt: contractedAt}}},
+			{Code: "sor-2", SymbolCode: "1234", Side: SideSell, Contracts: []*Contract{{ContractCode: "sco-2", OrderCode: "sor-2", PositionCode: "spo-1", Price: 1010, Quantity: 100, ContractedAt: contractedAt}}},
+		}},
+		marginService: &testMarginService{getMarginOrders1: []*marginOrder{
+			{Code: "mor-1", SymbolCode: "1234", TradeType: TradeTypeEntry, Side: SideSell, Contracts: []*Contract{{ContractCode: "mco-1", OrderCode: "mor-1", PositionCode: "mpo-1", Price: 1000, Quantity: 100, ContractedAt: contractedAt}}},
+		}},
+		futureService: &testFutureService{
+			getFutureOrders1: []*futureOrder{
+				{Code: "for-1", SymbolCode: "167060019", TradeType: TradeTypeEntry, Side: SideBuy, Contracts: []*Contract{{ContractCode: "fco-1", OrderCode: "for-1", PositionCode: "fpo-1", Price: 28000, Quantity: 1, ContractedAt: contractedAt}}},
+			},
+			getSymbols1: []*futureSymbol{{SymbolCode: "167060019", Multiplier: 100}},
+		},
+		ledgerService: ledgerService,
+	}
+	security.recordTrades()
+
+	want := []*Trade{
+		{ContractCode: "sco-1", OrderCode: "sor-1", PositionCode: "spo-1", ExchangeType: ExchangeTypeStock, SymbolCode: "1234", TradeType: TradeTypeEntry, Side: SideBuy, Price: 1000, Quantity: 100, Multiplier: 1, BusinessDay: day, ContractedAt: contractedAt},
+		{ContractCode: "sco-2", OrderCode: "sor-2", PositionCode: "spo-1", ExchangeType: ExchangeTypeStock, SymbolCode: "1234", TradeType: TradeTypeExit, Side: SideSell, Price: 1010, Quantity: 100, Multiplier: 1, BusinessDay: day, ContractedAt: contractedAt},
+		{ContractCode: "mco-1", OrderCode: "mor-1", PositionCode: "mpo-1", ExchangeType: ExchangeTypeMargin, SymbolCode: "1234", TradeType: TradeTypeEntry, Side: SideSell, Price: 1000, Quantity: 100, Multiplier: 1, BusinessDay: day, ContractedAt: contractedAt},
+		{ContractCode: "fco-1", OrderCode: "for-1", PositionCode: "fpo-1", ExchangeType: ExchangeTypeFuture, SymbolCode: "167060019", TradeType: TradeTypeEntry, Side: SideBuy, Price: 28000, Quantity: 1, Multiplier: 100, BusinessDay: day, ContractedAt: contractedAt},
+	}
+	if !reflect.DeepEqual(want, ledgerService.recordHistory) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, ledgerService.recordHistory)
+	}
+
+	// 2回目以降は、記録済みの約定を飛ばして新しく増えた約定だけを記録する
+	order := security.futureService.getFutureOrders()[0]
+	order.Contracts = append(order.Contracts, &Contract{ContractCode: "fco-2", OrderCode: "for-1", PositionCode: "fpo-2", Price: 28010, Quantity: 1, ContractedAt: contractedAt})
+	security.recordTrades()
+
+	want = append(want, &Trade{ContractCode: "fco-2", OrderCode: "for-1", PositionCode: "fpo-2", ExchangeType: ExchangeTypeFuture, SymbolCode: "167060019", TradeType: TradeTypeEntry, Side: SideBuy, Price: 28010, Quantity: 1, Multiplier: 100, BusinessDay: day, ContractedAt: contractedAt})
+	if !reflect.DeepEqual(want, ledgerService.recordHistory) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, ledgerService.recordHistory)
+	}
+}
+
+func Test_virtualSecurity_Trades(t *testing.T) {
+	t.Parallel()
+	clock := NewSimulatedClock(time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local))
+	security := NewVirtualSecurityWithOptions(WithClock(clock))
+	scheduler := NewScheduler(security, clock)
+	registerPrice := func(at time.Time, price float64, bid float64, ask float64) {
+		if err := scheduler.AdvanceTo(at); err != nil {
+			t.Fatalf("%s error\n%+v\n", t.Name(), err)
+		}
+		if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: price, PriceTime: at, Bid: bid, BidTime: at, Ask: ask, AskTime: at}); err != nil {
+			t.Fatalf("%s error\n%+v\n", t.Name(), err)
+		}
+	}
+	registerPrice(time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local), 1000, 999, 1001)
+	registerPrice(time.Date(2021, 9, 1, 10, 1, 0, 0, time.Local), 1000, 999, 1001)
+
+	// 現物を買って売り、信用で売り建ててから買い戻す
+	if _, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 100}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	registerPrice(time.Date(2021, 9, 1, 10, 2, 0, 0, time.Local), 1010, 1009, 1011)
+	if _, err := security.StockOrder(&StockOrderRequest{Side: SideSell, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 100}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if _, err := security.MarginOrder(&MarginOrderRequest{TradeType: TradeTypeEntry, Side: SideSell, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 100}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	registerPrice(time.Date(2021, 9, 1, 10, 3, 0, 0, time.Local), 1000, 999, 1000)
+	positions, _ := security.MarginPositions()
+	if len(positions) != 1 {
+		t.Fatalf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 1, positions)
+	}
+	if _, err := security.MarginOrder(&MarginOrderRequest{TradeType: TradeTypeExit, Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 100,
+		ExitPositionList: []ExitPosition{{PositionCode: positions[0].Code, Quantity: 100}}}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 注文やポジションが一覧から削除されても、約定履歴と損益は残る
+	if err := scheduler.AdvanceTo(time.Date(2021, 9, 3, 12, 0, 0, 0, time.Local)); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if orders, _ := security.StockOrders(); len(orders) != 0 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 0, orders)
+	}
+
+	trades, _ := security.Trades()
+	type summary struct {
+		exchangeType ExchangeType
+		tradeType    TradeType
+		price        float64
+		entryPrice   float64
+		profit       float64
+	}
+	got := make([]summary, 0)
+	for _, trade := range trades {
+		got = append(got, summary{exchangeType: trade.ExchangeType, tradeType: trade.TradeType, price: trade.Price, entryPrice: trade.EntryPrice, profit: trade.Profit})
+	}
+	want := []summary{
+		{exchangeType: ExchangeTypeStock, tradeType: TradeTypeEntry, price: 1001},
+		{exchangeType: ExchangeTypeStock, tradeType: TradeTypeExit, price: 1009, entryPrice: 1001, profit: 800},
+		{exchangeType: ExchangeTypeMargin, tradeType: TradeTypeEntry, price: 1009},
+		{exchangeType: ExchangeTypeMargin, tradeType: TradeTypeExit, price: 1000, entryPrice: 1009, profit: 900},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want, got)
+	}
+
+	wantDaily := []*DailyProfit{{BusinessDay: time.Date(2021, 9, 1, 0, 0, 0, 0, time.Local), Profit: 1700, TradeCount: 4, ExitCount: 2}}
+	if daily, _ := security.DailyProfits(); !reflect.DeepEqual(wantDaily, daily) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), wantDaily, daily)
+	}
+	if total, _ := security.TotalProfit(); total != 1700 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 1700, total)
+	}
+}
+
+func Test_valuePosition(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		side       Side
+		price      float64
+		current    float64
+		quantity   float64
+		multiplier float64
+		want1      float64
+		want2      float64
+	}{
+		{name: "現在値がなければ0", side: SideBuy, price: 1000, current: 0, quantity: 100, multiplier: 1, want1: 0, want2: 0},
+		{name: "買いは現在値が上がれば利益", side: SideBuy, price: 1000, current: 1010, quantity: 100, multiplier: 1, want1: 101000, want2: 1000},
+		{name: "売りは現在値が上がれば損失", side: SideSell, price: 1000, current: 1010, quantity: 100, multiplier: 1, want1: 101000, want2: -1000},
+		{name: "取引単位を掛ける", side: SideSell, price: 28000, current: 27950, quantity: 2, multiplier: 1000, want1: 55900000, want2: 100000},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got1, got2 := valuePosition(test.side, test.price, test.current, test.quantity, test.multiplier)
+			if test.want1 != got1 || test.want2 != got2 {
+				t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), test.want1, test.want2, got1, got2)
+			}
+		})
+	}
+}
+
+func Test_virtualSecurity_Portfolio(t *testing.T) {
+	t.Parallel()
+	security := &virtualSecurity{
+		clock:          &testClock{},
+		accountService: &testAccountService{marginHoldingCost1: MarginCost{Interest: 100, AdministrationFee: 11}},
+		priceService:   &testPriceService{getBySymbolCode1: &symbolPrice{Price: 1100}},
+		stockService: &testStockService{getStockPositions1: []*stockPosition{
+			{Code: "spo-1", SymbolCode: "1234", Side: SideBuy, OwnedQuantity: 100, Price: 1000},
+			{Code: "spo-2", SymbolCode: "0001", Side: SideBuy, OwnedQuantity: 100, Price: 1200},
+			{Code: "spo-3", SymbolCode: "1234", Side: SideBuy, OwnedQuantity: 200, Price: 1050},
+		}},
+		marginService: &testMarginService{getMarginPositions1: []*marginPosition{
+			{Code: "mpo-1", SymbolCode: "1234", Side: SideSell, OwnedQuantity: 100, Price: 1000},
+			{Code: "mpo-2", SymbolCode: "1234", Side: SideBuy, OwnedQuantity: 100, Price: 1000},
+		}},
+		futureService: &testFutureService{getFuturePositions1: []*futurePosition{
+			{Code: "fpo-1", SymbolCode: "167090019", Side: SideSell, OwnedQuantity: 1, Price: 1000, Multiplier: 1000},
+		}},
+		ledgerService: &testLedgerService{totalProfit1: 5000},
+	}
+	want := &Portfolio{
+		MarketValue:      1760000,
+		UnrealizedProfit: -90000,
+		RealizedProfit:   5000,
+		HoldingCost:      222,
+		Symbols: []*SymbolValuation{
+			{ExchangeType: ExchangeTypeStock, SymbolCode: "0001", CurrentPrice: 1100, BuyQuantity: 100, MarketValue: 110000, UnrealizedProfit: -10000},
+			{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", CurrentPrice: 1100, BuyQuantity: 300, MarketValue: 330000, UnrealizedProfit: 20000},
+			{ExchangeType: ExchangeTypeMargin, SymbolCode: "1234", CurrentPrice: 1100, BuyQuantity: 100, SellQuantity: 100, MarketValue: 220000, UnrealizedProfit: 0},
+			{ExchangeType: ExchangeTypeFuture, SymbolCode: "167090019", CurrentPrice: 1100, SellQuantity: 1, MarketValue: 1100000, UnrealizedProfit: -100000},
+		},
+	}
+	got, err := security.Portfolio()
+	if !reflect.DeepEqual(want, got) || err != nil {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), want, nil, got, err)
+	}
+}
+
+func Test_virtualSecurity_fee(t *testing.T) {
+	t.Parallel()
+	clock := NewSimulatedClock(time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local))
+	security := NewVirtualSecurityWithOptions(WithClock(clock), WithCash(1_000_000), WithFeeSchedule(ExchangeTypeStock, FlatFee(100)), WithCapitalGainsTax())
+	registerPrice := func(price float64, bid float64, ask float64) {
+		now := clock.Now()
+		if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: price, PriceTime: now, Bid: bid, BidTime: now, Ask: ask, AskTime: now}); err != nil {
+			t.Fatalf("%s error\n%+v\n", t.Name(), err)
+		}
+	}
+	registerPrice(1000, 999, 1001)
+	registerPrice(1000, 999, 1001)
+
+	// 現物を1001円で買って1009円で売ると、800円の利益から手数料の200円を引いた600円が損益になる
+	if _, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 100}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	clock.Set(clock.Now().Add(time.Minute))
+	registerPrice(1010, 1009, 1011)
+	if _, err := security.StockOrder(&StockOrderRequest{Side: SideSell, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 100}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	orders, _ := security.StockOrders()
+	var fees []float64
+	for _, o := range orders {
+		for _, c := range o.Contracts {
+			fees = append(fees, c.Fee)
+		}
+	}
+	if !reflect.DeepEqual([]float64{100, 100}, fees) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), []float64{100, 100}, fees)
+	}
+	if account, _ := security.Account(); account.Cash != 1_000_600 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 1_000_600, account.Cash)
+	}
+	if total, _ := security.TotalProfit(); total != 600 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 600, total)
+	}
+	if tax, _ := security.TotalTax(); math.Abs(tax-600*CapitalGainsTaxRate) > 1e-9 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 600*CapitalGainsTaxRate, tax)
+	}
+}
+
+func Test_virtualSecurity_tickSize(t *testing.T) {
+	t.Parallel()
+	clock := NewSimulatedClock(time.Date(2021, 9, 1, 10, 0, 0, 0, time.Local))
+	security := NewVirtualSecurityWithOptions(WithClock(clock))
+
+	// 未登録の銘柄はその他の銘柄の表なので、1円未満の指値は受け付けない
+	if _, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 999.5}); !errors.Is(err, InvalidTickSizeError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), InvalidTickSizeError, err)
+	}
+
+	// TOPIX500構成銘柄として登録すれば、現物でも信用でも0.5円刻みの指値を受け付ける
+	if err := security.RegisterStockSymbol(&StockSymbol{SymbolCode: "1234", TickSizeTable: TickSizeTableTopix500}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if _, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 999.5}); err != nil {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), nil, err)
+	}
+	if _, err := security.MarginOrder(&MarginOrderRequest{TradeType: TradeTypeEntry, Side: SideSell, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 1_000.5}); err != nil {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), nil, err)
+	}
+	if _, err := security.MarginOrder(&MarginOrderRequest{TradeType: TradeTypeEntry, Side: SideSell, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 1_000.3}); !errors.Is(err, InvalidTickSizeError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), InvalidTickSizeError, err)
+	}
+}
+
+func Test_virtualSecurity_priceLimit(t *testing.T) {
+	t.Parallel()
+	clock := NewSimulatedClock(time.Date(2021, 9, 1, 14, 0, 0, 0, time.Local))
+	security := NewVirtualSecurityWithOptions(WithClock(clock))
+	registerPrice := func(price RegisterPriceRequest) {
+		price.ExchangeType, price.SymbolCode, price.PriceTime = ExchangeTypeStock, "1234", clock.Now()
+		if err := security.RegisterPrice(price); err != nil {
+			t.Fatalf("%s error\n%+v\n", t.Name(), err)
+		}
+	}
+
+	// 前日の最後の現値1,000円が基準値段になり、値幅制限は700円から1,300円になる
+	registerPrice(RegisterPriceRequest{Price: 1000})
+	clock.Set(time.Date(2021, 9, 2, 14, 0, 0, 0, time.Local))
+	registerPrice(RegisterPriceRequest{Price: 1300, Bid: 1300, BidTime: clock.Now(), Ask: 1300, AskTime: clock.Now()})
+	if price, _ := security.Price("1234"); price.LowerLimit != 700 || price.UpperLimit != 1300 {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v\n", t.Name(), 700, 1300, price)
+	}
+	if _, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 400, LimitPrice: 1301}); !errors.Is(err, PriceLimitExceededError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), PriceLimitExceededError, err)
+	}
+
+	// ストップ高ではザラバで買えない
+	res, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 400})
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	registerPrice(RegisterPriceRequest{Price: 1300, Bid: 1300, BidTime: clock.Now(), Ask: 1300, AskTime: clock.Now()})
+	if positions, _ := security.StockPositions(); len(positions) != 0 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 0, len(positions))
+	}
+
+	// 引けでは、出来高1,000株と約定しなかった買気配3,000株の比率で比例配分され、400株のうち100株が約定する
+	clock.Set(time.Date(2021, 9, 2, 15, 0, 0, 0, time.Local))
+	registerPrice(RegisterPriceRequest{Price: 1300, Volume: 1000, Bid: 1300, BidTime: clock.Now(), BidQuantity: 3000})
+	orders, _ := security.StockOrders()
+	if len(orders) != 1 || orders[0].Code != res.OrderCode || orders[0].ContractedQuantity != 100 || orders[0].Contracts[0].Price != 1300 {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v\n", t.Name(), 100, 1300, orders)
+	}
+}
+
+func Test_virtualSecurity_modifyOrder(t *testing.T) {
+	t.Parallel()
+	clock := NewSimulatedClock(time.Date(2021, 9, 2, 10, 0, 0, 0, time.Local))
+	security := NewVirtualSecurityWithOptions(WithClock(clock))
+	if err := security.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: clock.Now(), Bid: 999, BidTime: clock.Now(), Ask: 1000, AskTime: clock.Now()}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 買い気配より安い指値は約定せず、売り気配まで指値を訂正すると同じ注文コードのまま約定する
+	buy, err := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 990})
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if err := security.ModifyStockOrder(&ModifyOrderRequest{OrderCode: buy.OrderCode, LimitPrice: 1000}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	orders, _ := security.StockOrders()
+	if len(orders) != 1 || orders[0].Code != buy.OrderCode || orders[0].OrderStatus != OrderStatusDone || orders[0].Contracts[0].Price != 1000 ||
+		len(orders[0].Modifications) != 1 || orders[0].Modifications[0].PrevLimitPrice != 990 {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v\n", t.Name(), OrderStatusDone, 1000, orders)
+	}
+
+	// 約定した注文は訂正できない
+	if err := security.ModifyStockOrder(&ModifyOrderRequest{OrderCode: buy.OrderCode, LimitPrice: 990}); !errors.Is(err, UnmodifiableOrderError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), UnmodifiableOrderError, err)
+	}
+
+	// 売り注文の数量を減らすと、減らした分だけポジションの拘束が外れる
+	sell, err := security.StockOrder(&StockOrderRequest{Side: SideSell, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 1100})
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if err := security.ModifyStockOrder(&ModifyOrderRequest{OrderCode: sell.OrderCode, OrderQuantity: 40}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if positions, _ := security.StockPositions(); len(positions) != 1 || positions[0].HoldQuantity != 40 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 40, positions)
+	}
+
+	// 存在しない注文は訂正できない
+	if err := security.ModifyMarginOrder(&ModifyOrderRequest{OrderCode: sell.OrderCode, OrderQuantity: 40}); !errors.Is(err, NoDataError) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), NoDataError, err)
+	}
+}
+
+func Test_virtualSecurity_Snapshot_Restore(t *testing.T) {
+	t.Parallel()
+	source := &testSourceClock{now1: time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local)}
+	security1 := NewVirtualSecurityWithOptions(WithClock(source), WithCash(1_000_000))
+	if err := security1.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if _, err := security1.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 100}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if _, err := security1.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 900}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	data, err := security1.Snapshot()
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 別の仮想証券会社に戻すと、注文、ポジション、口座、約定履歴が同じになる
+	security2 := NewVirtualSecurityWithOptions(WithClock(source))
+	if err := security2.Restore(data); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want1, _ := security1.StockOrders()
+	want2, _ := security1.StockPositions()
+	want3, _ := security1.Account()
+	want4, _ := security1.Trades()
+	got1, _ := security2.StockOrders()
+	got2, _ := security2.StockPositions()
+	got3, _ := security2.Account()
+	got4, _ := security2.Trades()
+	if len(got1) != 2 || len(got2) != 1 || len(got4) != 1 ||
+		!reflect.DeepEqual(toJSON(want1), toJSON(got1)) ||
+		!reflect.DeepEqual(toJSON(want2), toJSON(got2)) ||
+		!reflect.DeepEqual(want3, got3) ||
+		!reflect.DeepEqual(toJSON(want4), toJSON(got4)) {
+		t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v\n", t.Name(),
+			toJSON(want1), toJSON(want2), want3, toJSON(want4),
+			toJSON(got1), toJSON(got2), got3, toJSON(got4))
+	}
+
+	// 戻した注文は、その後の価格で約定する
+	source.now1 = time.Date(2021, 8, 25, 10, 1, 0, 0, time.Local)
+	if err := security2.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 890, PriceTime: source.now1, Bid: 880, BidTime: source.now1, Ask: 890, AskTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	got5, _ := security2.StockPositions()
+	if len(got5) != 2 {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), 2, len(got5))
+	}
+}
+
+func Test_virtualSecurity_Restore_invalid(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		arg  []byte
+		want error
+	}{
+		{name: "JSONでなければエラー", arg: []byte("{"), want: InvalidSnapshotError},
+		{name: "バージョンが違えばエラー", arg: []byte(`{"Version":0}`), want: InvalidSnapshotError},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got := NewVirtualSecurity().Restore(test.arg)
+			if !errors.Is(got, test.want) {
+				t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), test.want, got)
+			}
+		})
+	}
+}
+
+func Test_NewVirtualSecurityWithStoreDirectory(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "store")
+	source := &testSourceClock{now1: time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local)}
+	security1, err := NewVirtualSecurityWithStoreDirectory(dir, WithClock(source))
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if err := security1.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if _, err := security1.MarginOrder(&MarginOrderRequest{TradeType: TradeTypeEntry, Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 100}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 同じディレクトリで開き直すと、約定で変わった注文とポジションが読み込まれる
+	security2, err := NewVirtualSecurityWithStoreDirectory(dir, WithClock(source))
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want1, _ := security1.MarginOrders()
+	want2, _ := security1.MarginPositions()
+	got1, _ := security2.MarginOrders()
+	got2, _ := security2.MarginPositions()
+	if len(got1) != 1 || got1[0].OrderStatus != OrderStatusDone || len(got2) != 1 ||
+		!reflect.DeepEqual(toJSON(want1), toJSON(got1)) ||
+		!reflect.DeepEqual(toJSON(want2), toJSON(got2)) {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), toJSON(want1), toJSON(want2), toJSON(got1), toJSON(got2))
+	}
+}
+
+func Test_NewVirtualSecurityWithStoreDirectory_account(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "store")
+	source := &testSourceClock{now1: time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local)}
+	security1, err := NewVirtualSecurityWithStoreDirectory(dir, WithClock(source), WithCash(1_000_000))
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if err := security1.RegisterStockSymbol(&StockSymbol{SymbolCode: "1234", TickSizeTable: TickSizeTableTopix500, BasePrice: 1000}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if err := security1.RegisterFutureSymbol(&FutureSymbol{SymbolCode: "NK225mini", Multiplier: 100}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if err := security1.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if _, err := security1.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 900}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if err := security1.Deposit(10_000); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 同じディレクトリで開き直すと、WithCashの預り金ではなく、注文の拘束金を含めた口座と銘柄が読み込まれる
+	security2, err := NewVirtualSecurityWithStoreDirectory(dir, WithClock(source), WithCash(1_000_000))
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want1, _ := security1.Account()
+	got1, _ := security2.Account()
+	if got1.Cash != 1_010_000 || got1.ReservedCash != 90_000 || !reflect.DeepEqual(want1, got1) {
+		t.Errorf("%s error\nwant: %+v\ngot: %+v\n", t.Name(), want1, got1)
+	}
+	want2 := security1.(*virtualSecurity).stockService.getSymbols()
+	got2 := security2.(*virtualSecurity).stockService.getSymbols()
+	want3 := security1.(*virtualSecurity).futureService.getSymbols()
+	got3 := security2.(*virtualSecurity).futureService.getSymbols()
+	if len(got2) != 1 || len(got3) != 1 || !reflect.DeepEqual(toJSON(want2), toJSON(got2)) || !reflect.DeepEqual(toJSON(want3), toJSON(got3)) {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), toJSON(want2), toJSON(want3), toJSON(got2), toJSON(got3))
+	}
+}
+
+func Test_NewVirtualSecurityWithStoreDirectory_trades(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "store")
+	source := &testSourceClock{now1: time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local)}
+	security1, err := NewVirtualSecurityWithStoreDirectory(dir, WithClock(source))
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if err := security1.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1000, PriceTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if _, err := security1.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 100}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	source.now1 = time.Date(2021, 8, 25, 10, 1, 0, 0, time.Local)
+	if err := security1.RegisterPrice(RegisterPriceRequest{ExchangeType: ExchangeTypeStock, SymbolCode: "1234", Price: 1099, PriceTime: source.now1, Bid: 1099, BidTime: source.now1}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if _, err := security1.StockOrder(&StockOrderRequest{Side: SideSell, ExecutionCondition: StockExecutionConditionMO, SymbolCode: "1234", Quantity: 100}); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 終了した注文が削除されてから開き直しても、約定履歴と確定損益が読み込まれる
+	source.now1 = time.Date(2021, 8, 27, 10, 0, 0, 0, time.Local)
+	if orders, _ := security1.StockOrders(); len(orders) != 0 {
+		t.Fatalf("%s error\n%+v\n", t.Name(), toJSON(orders))
+	}
+	security2, err := NewVirtualSecurityWithStoreDirectory(dir, WithClock(source))
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	want1, _ := security1.Trades()
+	got1, _ := security2.Trades()
+	got2, _ := security2.TotalProfit()
+	if len(got1) != 2 || got2 != 9_900 || !reflect.DeepEqual(toJSON(want1), toJSON(got1)) {
+		t.Errorf("%s error\nwant: %+v, %+v\ngot: %+v, %+v\n", t.Name(), toJSON(want1), 9_900, toJSON(got1), got2)
+	}
+}
+
+func Test_NewVirtualSecurityWithStoreDirectory_persistError(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "store")
+	source := &testSourceClock{now1: time.Date(2021, 8, 25, 10, 0, 0, 0, time.Local)}
+	security, err := NewVirtualSecurityWithStoreDirectory(dir, WithClock(source), WithCash(1_000_000))
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+
+	// 処理は成功しても、ファイルに書き出せなければエラーになる
+	err1 := security.Deposit(10_000)
+	_, err2 := security.StockOrder(&StockOrderRequest{Side: SideBuy, ExecutionCondition: StockExecutionConditionLO, SymbolCode: "1234", Quantity: 100, LimitPrice: 900})
+	err3 := security.Restore(mustSnapshot(t, security))
+
+	// 処理そのもののエラーは、書き出せなかったエラーより優先される
+	err4 := security.Withdraw(100_000_000)
+
+	got, _ := security.Account()
+	if !errors.Is(err1, PersistError) || !errors.Is(err2, PersistError) || !errors.Is(err3, PersistError) ||
+		errors.Is(err4, PersistError) || err4 == nil || got.Cash != 1_010_000 {
+		t.Errorf("%s error\nwant: %+v, %+v, %+v, %+v, %+v\ngot: %+v, %+v, %+v, %+v, %+v\n", t.Name(),
+			PersistError, PersistError, PersistError, "error", 1_010_000,
+			err1, err2, err3, err4, got.Cash)
+	}
+}
+
+func mustSnapshot(t *testing.T, security VirtualSecurity) []byte {
+	t.Helper()
+	b, err := security.Snapshot()
+	if err != nil {
+		t.Fatalf("%s error\n%+v\n", t.Name(), err)
+	}
+	return b
+}
+
+func toJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}